@@ -2,52 +2,88 @@ package account
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"ds2api/internal/config"
 )
 
+// groupSelectorPrefix marks targets that select a group of accounts rather
+// than one account.
+const groupSelectorPrefix = "group:"
+
+// Acquire takes a free account without waiting. target is empty for any
+// account, an account identifier, or a "group:<name>" selector.
 func (p *Pool) Acquire(target string, exclude map[string]bool) (config.Account, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.acquireLocked(target, normalizeExclude(exclude))
 }
 
-func (p *Pool) AcquireWait(ctx context.Context, target string, exclude map[string]bool) (config.Account, bool) {
+// AcquireWait acquires an account, waiting in caller's priority class while
+// none is free. The wait ends with the context or the class wait timeout.
+func (p *Pool) AcquireWait(ctx context.Context, target string, exclude map[string]bool, caller Caller) (config.Account, bool) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 	exclude = normalizeExclude(exclude)
+	class := p.priorityClass(&caller)
+	if class.WaitTimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(class.WaitTimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	if ctx.Err() != nil {
+		return config.Account{}, false
+	}
+	var seq uint64
 	for {
-		if ctx.Err() != nil {
-			return config.Account{}, false
-		}
-
 		p.mu.Lock()
 		if acc, ok := p.acquireLocked(target, exclude); ok {
 			p.mu.Unlock()
 			return acc, true
 		}
-		if !p.canQueueLocked(target, exclude) {
+		if !p.canQueueLocked(target, exclude, caller, class) {
 			p.mu.Unlock()
 			return config.Account{}, false
 		}
-		waiter := make(chan struct{})
-		p.waiters = append(p.waiters, waiter)
+		w := &waiter{ch: make(chan struct{}), done: ctx.Done(), target: target, class: caller.Class, caller: caller.ID, seq: seq}
+		p.waiters.push(w, class.Weight)
+		seq = w.seq
 		p.mu.Unlock()
 
 		select {
 		case <-ctx.Done():
-			p.mu.Lock()
-			p.removeWaiterLocked(waiter)
-			p.mu.Unlock()
-			return config.Account{}, false
-		case <-waiter:
+		case <-w.ch:
+			if ctx.Err() == nil {
+				continue
+			}
 		}
+		p.mu.Lock()
+		if !p.removeWaiterLocked(w) && w.woken {
+			// The wakeup came as the context ended: hand it on.
+			p.notifyWaiterLocked(w.freed)
+		}
+		p.mu.Unlock()
+		return config.Account{}, false
 	}
 }
 
+// priorityClass resolves caller's class, falling back to the default class
+// for names that are not configured, and stores the resolved name.
+func (p *Pool) priorityClass(caller *Caller) config.PriorityClass {
+	sched := p.schedulingConfig()
+	class, ok := sched.Class(caller.Class)
+	if !ok {
+		class, _ = sched.Class(sched.ClassFor("", ""))
+	}
+	caller.Class = class.Name
+	return class
+}
+
 func (p *Pool) acquireLocked(target string, exclude map[string]bool) (config.Account, bool) {
-	if target != "" {
+	group, byGroup := GroupOf(target)
+	if target != "" && !byGroup {
 		if exclude[target] || !p.canAcquireIDLocked(target) {
 			return config.Account{}, false
 		}
@@ -60,16 +96,18 @@ func (p *Pool) acquireLocked(target string, exclude map[string]bool) (config.Acc
 		return acc, true
 	}
 
-	if acc, ok := p.tryAcquire(exclude, true); ok {
+	if acc, ok := p.tryAcquire(group, exclude, true); ok {
 		return acc, true
 	}
-	if acc, ok := p.tryAcquire(exclude, false); ok {
+	if acc, ok := p.tryAcquire(group, exclude, false); ok {
 		return acc, true
 	}
 	return config.Account{}, false
 }
 
-func (p *Pool) tryAcquire(exclude map[string]bool, requireToken bool) (config.Account, bool) {
+// tryAcquire takes the least recently used free account, from group only
+// when group is set, so each group is served round-robin on its own.
+func (p *Pool) tryAcquire(group string, exclude map[string]bool, requireToken bool) (config.Account, bool) {
 	for i := 0; i < len(p.queue); i++ {
 		id := p.queue[i]
 		if exclude[id] || !p.canAcquireIDLocked(id) {
@@ -82,6 +120,9 @@ func (p *Pool) tryAcquire(exclude map[string]bool, requireToken bool) (config.Ac
 		if requireToken && acc.Token == "" {
 			continue
 		}
+		if group != "" && !acc.InGroup(group) {
+			continue
+		}
 		p.inUse[id]++
 		p.bumpQueue(id)
 		return acc, true
@@ -106,3 +147,16 @@ func normalizeExclude(exclude map[string]bool) map[string]bool {
 	}
 	return exclude
 }
+
+// GroupTarget is the target selector for any account of group.
+func GroupTarget(group string) string {
+	return groupSelectorPrefix + strings.TrimSpace(group)
+}
+
+// GroupOf reports the group a "group:<name>" target selects.
+func GroupOf(target string) (string, bool) {
+	if len(target) < len(groupSelectorPrefix) || !strings.EqualFold(target[:len(groupSelectorPrefix)], groupSelectorPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(target[len(groupSelectorPrefix):]), true
+}
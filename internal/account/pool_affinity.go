@@ -0,0 +1,111 @@
+package account
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+// ringReplicas is how many points each account gets on the affinity ring;
+// more points even out the share of keys each account receives.
+const ringReplicas = 64
+
+type ringPoint struct {
+	hash uint64
+	id   string
+}
+
+// affinityRing maps affinity keys onto accounts by consistent hashing, so
+// adding or removing an account only moves the keys of the ring segments
+// that account gains or loses.
+type affinityRing []ringPoint
+
+func newAffinityRing(ids []string) affinityRing {
+	ring := make(affinityRing, 0, len(ids)*ringReplicas)
+	for _, id := range ids {
+		for i := 0; i < ringReplicas; i++ {
+			ring = append(ring, ringPoint{hash: ringHash(id + "#" + strconv.Itoa(i)), id: id})
+		}
+	}
+	slices.SortFunc(ring, func(a, b ringPoint) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), strings.Compare(a.id, b.id))
+	})
+	return ring
+}
+
+// successors lists up to n distinct accounts for which keep holds, walking
+// the ring clockwise from key's point.
+func (r affinityRing) successors(key string, n int, keep func(id string) bool) []string {
+	if len(r) == 0 || n <= 0 {
+		return nil
+	}
+	start, _ := slices.BinarySearchFunc(r, ringHash(key), func(p ringPoint, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	seen := map[string]bool{}
+	var out []string
+	for i := 0; i < len(r) && len(out) < n; i++ {
+		id := r[(start+i)%len(r)].id
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if keep(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func ringHash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// AcquireAffinity moves the slot a request holds on holding to the account
+// key hashes to, among the accounts target allows. While that account is
+// saturated the next fallback accounts on the ring are tried in turn; if
+// they are saturated too, the request keeps the account it holds. It reports
+// whether the slot moved. Requests pinned to one account never move.
+func (p *Pool) AcquireAffinity(key, target, holding string, exclude map[string]bool, fallback int) (config.Account, bool) {
+	group, byGroup := GroupOf(target)
+	if key == "" || holding == "" || (target != "" && !byGroup) {
+		return config.Account{}, false
+	}
+	exclude = normalizeExclude(exclude)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	candidates := p.ring.successors(key, 1+max(fallback, 0), func(id string) bool {
+		if exclude[id] && id != holding {
+			return false
+		}
+		if !byGroup {
+			return true
+		}
+		acc, ok := p.store.FindAccount(id)
+		return ok && acc.InGroup(group)
+	})
+	for _, id := range candidates {
+		if id == holding {
+			return config.Account{}, false
+		}
+		// The slot only moves, so the global limit is not checked again.
+		if p.inUse[id] >= p.maxInflightPerAccount {
+			continue
+		}
+		acc, ok := p.store.FindAccount(id)
+		if !ok {
+			continue
+		}
+		p.inUse[id]++
+		p.bumpQueue(id)
+		p.releaseLocked(holding)
+		return acc, true
+	}
+	return config.Account{}, false
+}
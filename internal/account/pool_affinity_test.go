@@ -0,0 +1,89 @@
+package account
+
+import (
+	"fmt"
+	"testing"
+
+	"ds2api/internal/config"
+)
+
+func ringOwner(r affinityRing, key string) string {
+	ids := r.successors(key, 1, func(string) bool { return true })
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+func TestAffinityRingRemapsOnlyTheChangedAccountsKeys(t *testing.T) {
+	ids := make([]string, 0, 11)
+	for i := 0; i < 11; i++ {
+		ids = append(ids, fmt.Sprintf("acc%d@example.com", i))
+	}
+	before := newAffinityRing(ids[:10])
+	after := newAffinityRing(ids)
+	const keys = 5000
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		from, to := ringOwner(before, key), ringOwner(after, key)
+		if from == to {
+			continue
+		}
+		if to != ids[10] {
+			t.Fatalf("key %s moved from %s to %s instead of the new account", key, from, to)
+		}
+		moved++
+	}
+	// An even share would be keys/11; allow for the ring's unevenness.
+	if moved == 0 || moved > keys*2/11 {
+		t.Fatalf("expected about %d keys to move to the new account, got %d", keys/11, moved)
+	}
+
+	removed := newAffinityRing(append(append([]string{}, ids[:3]...), ids[4:10]...))
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		if from := ringOwner(before, key); from != ids[3] && ringOwner(removed, key) != from {
+			t.Fatalf("key %s moved although its account %s stayed", key, from)
+		}
+	}
+}
+
+func TestPoolAcquireAffinityFallsBackAlongTheRing(t *testing.T) {
+	t.Setenv("DS2API_ACCOUNT_MAX_INFLIGHT", "1")
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["k1"],
+		"accounts":[
+			{"email":"a1@example.com","token":"t1"},
+			{"email":"a2@example.com","token":"t2"},
+			{"email":"a3@example.com","token":"t3"},
+			{"email":"a4@example.com","token":"t4"}
+		]
+	}`)
+	pool := NewPool(config.LoadStore())
+	const key = "caller|user:alice"
+	prefs := pool.ring.successors(key, 4, func(string) bool { return true })
+
+	hold := func(id string) {
+		if _, ok := pool.Acquire(id, nil); !ok {
+			t.Fatalf("expected to acquire %s", id)
+		}
+	}
+	hold(prefs[3])
+	if acc, moved := pool.AcquireAffinity(key, "", prefs[3], nil, 1); !moved || acc.Identifier() != prefs[0] {
+		t.Fatalf("expected the slot to move to the preferred account %s, got %q", prefs[0], acc.Identifier())
+	}
+	hold(prefs[3])
+	if acc, moved := pool.AcquireAffinity(key, "", prefs[3], nil, 1); !moved || acc.Identifier() != prefs[1] {
+		t.Fatalf("expected a saturated preferred account to fall back to %s, got %q", prefs[1], acc.Identifier())
+	}
+	if _, moved := pool.AcquireAffinity(key, "", prefs[2], nil, 1); moved {
+		t.Fatal("expected the request to keep its account once the fallback accounts are saturated")
+	}
+	if _, moved := pool.AcquireAffinity(key, prefs[2], prefs[2], nil, 1); moved {
+		t.Fatal("expected a request pinned to one account not to move")
+	}
+	if status := pool.Status(); status["in_use"] != 2 {
+		t.Fatalf("expected moved slots to release the accounts they left, got %v", status)
+	}
+}
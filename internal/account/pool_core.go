@@ -11,8 +11,9 @@ type Pool struct {
 	store                  *config.Store
 	mu                     sync.Mutex
 	queue                  []string
+	ring                   affinityRing
 	inUse                  map[string]int
-	waiters                []chan struct{}
+	waiters                waitQueue
 	maxInflightPerAccount  int
 	recommendedConcurrency int
 	maxQueueSize           int
@@ -66,6 +67,7 @@ func (p *Pool) Reset() {
 	defer p.mu.Unlock()
 	p.drainWaitersLocked()
 	p.queue = ids
+	p.ring = newAffinityRing(ids)
 	p.inUse = map[string]int{}
 	p.recommendedConcurrency = recommended
 	p.maxQueueSize = queueLimit
@@ -86,17 +88,21 @@ func (p *Pool) Release(accountID string) {
 	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.releaseLocked(accountID)
+}
+
+func (p *Pool) releaseLocked(accountID string) {
 	count := p.inUse[accountID]
 	if count <= 0 {
 		return
 	}
 	if count == 1 {
 		delete(p.inUse, accountID)
-		p.notifyWaiterLocked()
+		p.notifyWaiterLocked(accountID)
 		return
 	}
 	p.inUse[accountID] = count - 1
-	p.notifyWaiterLocked()
+	p.notifyWaiterLocked(accountID)
 }
 
 func (p *Pool) Status() map[string]any {
@@ -117,6 +123,15 @@ func (p *Pool) Status() map[string]any {
 		}
 	}
 	sort.Strings(inUseAccounts)
+	waitingByClass := map[string]int{}
+	for _, name := range p.schedulingConfig().ClassNames() {
+		waitingByClass[name] = p.waiters.classDepth(name)
+	}
+	for name, class := range p.waiters.classes {
+		if class.depth > 0 {
+			waitingByClass[name] = class.depth
+		}
+	}
 	return map[string]any{
 		"available":                len(available),
 		"in_use":                   inUseSlots,
@@ -126,7 +141,31 @@ func (p *Pool) Status() map[string]any {
 		"max_inflight_per_account": p.maxInflightPerAccount,
 		"global_max_inflight":      p.globalMaxInflight,
 		"recommended_concurrency":  p.recommendedConcurrency,
-		"waiting":                  len(p.waiters),
+		"waiting":                  p.waiters.depth,
+		"waiting_by_class":         waitingByClass,
+		"groups":                   p.groupStatusLocked(),
 		"max_queue_size":           p.maxQueueSize,
 	}
 }
+
+// groupStatusLocked counts the accounts, free accounts and used slots of
+// each account group.
+func (p *Pool) groupStatusLocked() map[string]map[string]int {
+	out := map[string]map[string]int{}
+	for _, acc := range p.store.Accounts() {
+		id := acc.Identifier()
+		for _, group := range config.NormalizeGroups(acc.Groups) {
+			counts, ok := out[group]
+			if !ok {
+				counts = map[string]int{"total": 0, "available": 0, "in_use": 0}
+				out[group] = counts
+			}
+			counts["total"]++
+			counts["in_use"] += p.inUse[id]
+			if id != "" && p.inUse[id] < p.maxInflightPerAccount {
+				counts["available"]++
+			}
+		}
+	}
+	return out
+}
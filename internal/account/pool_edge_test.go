@@ -124,7 +124,7 @@ func TestPoolAcquireWaitContextCancelled(t *testing.T) {
 	var waitOK bool
 	go func() {
 		defer wg.Done()
-		_, waitOK = pool.AcquireWait(ctx, "", nil)
+		_, waitOK = pool.AcquireWait(ctx, "", nil, Caller{})
 	}()
 
 	// Wait until queued
@@ -151,7 +151,7 @@ func TestPoolAcquireWaitTargetAccount(t *testing.T) {
 
 	// Acquire acc2 directly (should succeed since acc2 is free)
 	ctx := context.Background()
-	acc2, ok := pool.AcquireWait(ctx, "acc2@example.com", nil)
+	acc2, ok := pool.AcquireWait(ctx, "acc2@example.com", nil, Caller{})
 	if !ok {
 		t.Fatal("expected acquire acc2 success via AcquireWait")
 	}
@@ -214,7 +214,7 @@ func TestPoolConcurrentAcquireWait(t *testing.T) {
 		go func() {
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
-			_, ok := pool.AcquireWait(ctx, "", nil)
+			_, ok := pool.AcquireWait(ctx, "", nil, Caller{})
 			results <- ok
 		}()
 	}
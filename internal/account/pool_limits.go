@@ -25,7 +25,7 @@ func (p *Pool) ApplyRuntimeLimits(maxInflightPerAccount, maxQueueSize, globalMax
 	p.maxQueueSize = maxQueueSize
 	p.globalMaxInflight = globalMaxInflight
 	p.recommendedConcurrency = defaultRecommendedConcurrency(len(p.queue), p.maxInflightPerAccount)
-	p.notifyWaiterLocked()
+	p.notifyWaiterLocked("")
 }
 
 func maxInflightFromEnv() int {
@@ -0,0 +1,212 @@
+package account
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+func newScheduledPoolForTest(t *testing.T, scheduling string) *Pool {
+	t.Helper()
+	t.Setenv("DS2API_ACCOUNT_MAX_INFLIGHT", "1")
+	t.Setenv("DS2API_ACCOUNT_CONCURRENCY", "")
+	t.Setenv("DS2API_ACCOUNT_MAX_QUEUE", "10")
+	t.Setenv("DS2API_ACCOUNT_QUEUE_SIZE", "")
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["k1"],
+		"accounts":[{"email":"acc1@example.com","token":"token1"}],
+		"scheduling":`+scheduling+`
+	}`)
+	return NewPool(config.LoadStore())
+}
+
+func anyWaiter(*waiter) bool { return true }
+
+func TestWaitQueueWeightsClassesAndRotatesCallers(t *testing.T) {
+	var q waitQueue
+	for i := 0; i < 6; i++ {
+		q.push(&waiter{class: "interactive", caller: "a"}, 3)
+		q.push(&waiter{class: "batch", caller: "noisy"}, 1)
+	}
+	var order strings.Builder
+	for i := 0; i < 8; i++ {
+		order.WriteString(q.pop(anyWaiter).class[:1])
+	}
+	if got := order.String(); strings.Count(got, "i") != 6 || strings.Count(got, "b") != 2 {
+		t.Fatalf("expected interactive woken three times as often as batch, got %s", got)
+	}
+
+	q = waitQueue{}
+	for _, caller := range []string{"noisy", "noisy", "noisy", "quiet"} {
+		q.push(&waiter{class: "default", caller: caller}, 1)
+	}
+	if first, second := q.pop(anyWaiter).caller, q.pop(anyWaiter).caller; first != "noisy" || second != "quiet" {
+		t.Fatalf("expected callers served round-robin, got %s then %s", first, second)
+	}
+	if q.depth != 2 || q.classDepth("default") != 2 || q.callerDepth("default", "noisy") != 2 {
+		t.Fatalf("unexpected depths after two pops: %d", q.depth)
+	}
+}
+
+func TestWaitQueueRequeuedWaiterKeepsItsPlace(t *testing.T) {
+	var q waitQueue
+	oldest := &waiter{class: "default", caller: "a"}
+	q.push(oldest, 1)
+	q.push(&waiter{class: "default", caller: "a"}, 1)
+	if w := q.pop(anyWaiter); w != oldest {
+		t.Fatal("expected the oldest waiter woken first")
+	}
+	q.push(&waiter{class: "default", caller: "a"}, 1)
+	// The wakeup could not be used, so the oldest waiter queues again.
+	q.push(&waiter{class: "default", caller: "a", seq: oldest.seq}, 1)
+	if w := q.pop(anyWaiter); w.seq != oldest.seq {
+		t.Fatalf("expected the requeued waiter ahead of later ones, got seq %d", w.seq)
+	}
+
+	q = waitQueue{}
+	first := &waiter{class: "default", caller: "x"}
+	q.push(first, 1)
+	q.pop(anyWaiter)
+	q.push(&waiter{class: "default", caller: "y"}, 1)
+	q.push(&waiter{class: "default", caller: "x", seq: first.seq}, 1)
+	if w := q.pop(anyWaiter); w.caller != "x" {
+		t.Fatalf("expected the requeued caller back ahead in the rotation, got %s", w.caller)
+	}
+}
+
+func TestPoolAcquireWaitEnforcesClassLimits(t *testing.T) {
+	pool := newScheduledPoolForTest(t, `{
+		"classes":[{"name":"batch","max_queue":2,"max_queue_per_caller":1,"wait_timeout_seconds":1}]
+	}`)
+	held, ok := pool.Acquire("", nil)
+	if !ok {
+		t.Fatal("expected first acquire to succeed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan bool, 1)
+	go func() {
+		_, ok := pool.AcquireWait(ctx, "", nil, Caller{ID: "a", Class: "batch"})
+		done <- ok
+	}()
+	waitForWaitingCount(t, pool, 1)
+
+	if _, ok := pool.AcquireWait(ctx, "", nil, Caller{ID: "a", Class: "batch"}); ok {
+		t.Fatal("expected a second waiter of the same caller to be refused")
+	}
+	go func() { _, _ = pool.AcquireWait(ctx, "", nil, Caller{ID: "b", Class: "batch"}) }()
+	waitForWaitingCount(t, pool, 2)
+	if _, ok := pool.AcquireWait(ctx, "", nil, Caller{ID: "c", Class: "batch"}); ok {
+		t.Fatal("expected the full batch class to refuse another caller")
+	}
+	go func() { _, _ = pool.AcquireWait(ctx, "", nil, Caller{ID: "c", Class: "unknown"}) }()
+	waitForWaitingCount(t, pool, 3)
+	byClass, _ := pool.Status()["waiting_by_class"].(map[string]int)
+	if byClass["batch"] != 2 || byClass["default"] != 1 {
+		t.Fatalf("expected per-class depth with unknown classes counted as default, got %v", byClass)
+	}
+
+	start := time.Now()
+	select {
+	case ok := <-done:
+		if ok || time.Since(start) > 2*time.Second {
+			t.Fatalf("expected the batch wait timeout to end the wait, ok=%v", ok)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the batch wait timeout to end the wait")
+	}
+	pool.Release(held.Identifier())
+}
+
+func TestPoolAcquiresByGroupRoundRobin(t *testing.T) {
+	t.Setenv("DS2API_ACCOUNT_MAX_INFLIGHT", "1")
+	t.Setenv("DS2API_ACCOUNT_MAX_QUEUE", "4")
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["k1"],
+		"accounts":[
+			{"email":"shared@example.com","token":"t0"},
+			{"email":"r1@example.com","token":"t1","groups":["research"]},
+			{"email":"r2@example.com","token":"t2","groups":["research"]}
+		]
+	}`)
+	pool := NewPool(config.LoadStore())
+	target := GroupTarget("research")
+	first, ok1 := pool.Acquire(target, nil)
+	second, ok2 := pool.Acquire(target, nil)
+	if !ok1 || !ok2 || first.Identifier() == second.Identifier() || !first.InGroup("research") || !second.InGroup("research") {
+		t.Fatalf("expected both research accounts, got %s and %s", first.Identifier(), second.Identifier())
+	}
+	if _, ok := pool.Acquire(target, nil); ok {
+		t.Fatal("expected the group to be exhausted while other accounts are free")
+	}
+
+	done := make(chan string, 1)
+	go func() {
+		acc, _ := pool.AcquireWait(context.Background(), target, nil, Caller{ID: "a"})
+		done <- acc.Identifier()
+	}()
+	waitForWaitingCount(t, pool, 1)
+	shared, _ := pool.Acquire("", nil)
+	pool.Release(shared.Identifier())
+	if status := pool.Status(); status["waiting"] != 1 {
+		t.Fatalf("expected a free account outside the group to leave the waiter queued, got %v", status)
+	}
+	pool.Release(first.Identifier())
+	select {
+	case id := <-done:
+		if id != first.Identifier() {
+			t.Fatalf("expected the freed research account, got %q", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the group waiter woken by its group's account")
+	}
+	groups, _ := pool.Status()["groups"].(map[string]map[string]int)
+	if groups["research"]["total"] != 2 || groups["research"]["in_use"] != 2 {
+		t.Fatalf("unexpected group status: %v", groups)
+	}
+}
+
+func TestPoolReleaseSkipsCancelledHeadWaiter(t *testing.T) {
+	pool := newScheduledPoolForTest(t, `{}`)
+	held, ok := pool.Acquire("", nil)
+	if !ok {
+		t.Fatal("expected first acquire to succeed")
+	}
+	headCtx, cancelHead := context.WithCancel(context.Background())
+	headDone := make(chan bool, 1)
+	go func() {
+		_, ok := pool.AcquireWait(headCtx, "", nil, Caller{ID: "a"})
+		headDone <- ok
+	}()
+	waitForWaitingCount(t, pool, 1)
+	nextDone := make(chan bool, 1)
+	go func() {
+		acc, ok := pool.AcquireWait(context.Background(), "", nil, Caller{ID: "a"})
+		if ok {
+			pool.Release(acc.Identifier())
+		}
+		nextDone <- ok
+	}()
+	waitForWaitingCount(t, pool, 2)
+
+	// The head waiter is cancelled but still queued when the slot is freed.
+	pool.mu.Lock()
+	cancelHead()
+	pool.releaseLocked(held.Identifier())
+	pool.mu.Unlock()
+
+	select {
+	case ok := <-nextDone:
+		if !ok {
+			t.Fatal("expected the live waiter to acquire the freed account")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the wakeup to reach the live waiter behind the cancelled one")
+	}
+	if <-headDone {
+		t.Fatal("expected the cancelled waiter to give up")
+	}
+}
@@ -234,7 +234,7 @@ func TestPoolAcquireWaitQueuesAndSucceedsAfterRelease(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	go func() {
-		acc, ok := pool.AcquireWait(ctx, "", nil)
+		acc, ok := pool.AcquireWait(ctx, "", nil, Caller{})
 		resCh <- result{id: acc.Identifier(), ok: ok}
 	}()
 
@@ -269,7 +269,7 @@ func TestPoolAcquireWaitQueueLimitReturnsFalse(t *testing.T) {
 	ctx1, cancel1 := context.WithTimeout(context.Background(), 1200*time.Millisecond)
 	defer cancel1()
 	go func() {
-		acc, ok := pool.AcquireWait(ctx1, "", nil)
+		acc, ok := pool.AcquireWait(ctx1, "", nil, Caller{})
 		firstWaiter <- result{id: acc.Identifier(), ok: ok}
 	}()
 	waitForWaitingCount(t, pool, 1)
@@ -277,7 +277,7 @@ func TestPoolAcquireWaitQueueLimitReturnsFalse(t *testing.T) {
 	ctx2, cancel2 := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel2()
 	start := time.Now()
-	if _, ok := pool.AcquireWait(ctx2, "", nil); ok {
+	if _, ok := pool.AcquireWait(ctx2, "", nil, Caller{}); ok {
 		t.Fatal("expected second queued acquire to fail when queue is full")
 	}
 	if time.Since(start) > 120*time.Millisecond {
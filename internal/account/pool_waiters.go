@@ -1,7 +1,194 @@
 package account
 
-func (p *Pool) canQueueLocked(target string, exclude map[string]bool) bool {
-	if target != "" {
+import (
+	"cmp"
+	"slices"
+
+	"ds2api/internal/config"
+)
+
+// strideUnit is divided by a class weight to get how far the class's pass
+// advances each time one of its waiters is woken.
+const strideUnit = 1 << 20
+
+// Caller identifies who waits for an account and in which priority class.
+// An empty Class is the default class.
+type Caller struct {
+	ID    string
+	Class string
+}
+
+type waiter struct {
+	ch     chan struct{}
+	done   <-chan struct{}
+	target string
+	class  string
+	caller string
+	// seq orders the waiter by when it first queued; a waiter that queues
+	// again after a wakeup it could not use keeps it.
+	seq uint64
+	// woken is set when a wakeup stopped at this waiter, for the account
+	// freed.
+	woken bool
+	freed string
+}
+
+// cancelled reports whether the waiter's context has ended, leaving it in
+// the queue only until it takes the lock to remove itself.
+func (w *waiter) cancelled() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitClass holds the waiters of one priority class, one FIFO sub-queue
+// per caller, served round-robin so one caller cannot starve the others.
+type waitClass struct {
+	weight  int
+	pass    uint64
+	callers []string
+	queues  map[string][]*waiter
+	depth   int
+}
+
+// waitQueue wakes waiters with stride scheduling: the non-empty class with
+// the lowest pass goes next and its pass then advances by strideUnit/weight,
+// so classes are woken in proportion to their weights.
+type waitQueue struct {
+	classes map[string]*waitClass
+	vtime   uint64
+	depth   int
+	seq     uint64
+}
+
+func (q *waitQueue) class(name string) *waitClass {
+	if q.classes == nil {
+		q.classes = map[string]*waitClass{}
+	}
+	c, ok := q.classes[name]
+	if !ok {
+		c = &waitClass{queues: map[string][]*waiter{}}
+		q.classes[name] = c
+	}
+	return c
+}
+
+func (q *waitQueue) push(w *waiter, weight int) {
+	c := q.class(w.class)
+	c.weight = max(weight, 1)
+	if c.depth == 0 {
+		// A class that was idle does not get to spend the time it sat out.
+		c.pass = max(c.pass, q.vtime)
+	}
+	if w.seq == 0 {
+		q.seq++
+		w.seq = q.seq
+	}
+	// Waiters and callers are kept in arrival order, so a waiter that queues
+	// again goes back to its place ahead of those that came after it.
+	queue := c.queues[w.caller]
+	if len(queue) == 0 {
+		i := slices.IndexFunc(c.callers, func(id string) bool { return c.queues[id][0].seq > w.seq })
+		if i < 0 {
+			i = len(c.callers)
+		}
+		c.callers = slices.Insert(c.callers, i, w.caller)
+	}
+	i := slices.IndexFunc(queue, func(o *waiter) bool { return o.seq > w.seq })
+	if i < 0 {
+		i = len(queue)
+	}
+	c.queues[w.caller] = slices.Insert(queue, i, w)
+	c.depth++
+	q.depth++
+}
+
+// pop removes the next waiter for which fits holds, or returns nil. Classes
+// are tried in pass order and, inside a class, callers in turn; a caller's
+// own waiters stay in FIFO order among those that fit.
+func (q *waitQueue) pop(fits func(*waiter) bool) *waiter {
+	names := q.sortedClasses()
+	slices.SortStableFunc(names, func(a, b string) int {
+		return cmp.Compare(q.classes[a].pass, q.classes[b].pass)
+	})
+	for _, name := range names {
+		c := q.classes[name]
+		for _, caller := range c.callers {
+			for _, w := range c.queues[caller] {
+				if !fits(w) {
+					continue
+				}
+				q.vtime = max(q.vtime, c.pass)
+				c.pass += uint64(strideUnit / c.weight)
+				q.remove(w)
+				// The caller goes to the back of the class's rotation.
+				if len(c.queues[caller]) > 0 {
+					c.callers = append(slices.DeleteFunc(c.callers, func(id string) bool { return id == caller }), caller)
+				}
+				return w
+			}
+		}
+	}
+	return nil
+}
+
+func (q *waitQueue) remove(w *waiter) bool {
+	c, ok := q.classes[w.class]
+	if !ok {
+		return false
+	}
+	queue := c.queues[w.caller]
+	i := slices.Index(queue, w)
+	if i < 0 {
+		return false
+	}
+	queue = slices.Delete(queue, i, i+1)
+	if len(queue) == 0 {
+		delete(c.queues, w.caller)
+		c.callers = slices.DeleteFunc(c.callers, func(id string) bool { return id == w.caller })
+	} else {
+		c.queues[w.caller] = queue
+	}
+	c.depth--
+	q.depth--
+	return true
+}
+
+// sortedClasses keeps ties between classes deterministic.
+func (q *waitQueue) sortedClasses() []string {
+	names := make([]string, 0, len(q.classes))
+	for name := range q.classes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (q *waitQueue) classDepth(name string) int {
+	if c, ok := q.classes[name]; ok {
+		return c.depth
+	}
+	return 0
+}
+
+func (q *waitQueue) callerDepth(class, caller string) int {
+	if c, ok := q.classes[class]; ok {
+		return len(c.queues[caller])
+	}
+	return 0
+}
+
+func (p *Pool) canQueueLocked(target string, exclude map[string]bool, caller Caller, class config.PriorityClass) bool {
+	if group, ok := GroupOf(target); ok {
+		if !slices.ContainsFunc(p.store.Accounts(), func(acc config.Account) bool {
+			return acc.InGroup(group) && !exclude[acc.Identifier()]
+		}) {
+			return false
+		}
+	} else if target != "" {
 		if exclude[target] {
 			return false
 		}
@@ -9,35 +196,66 @@ func (p *Pool) canQueueLocked(target string, exclude map[string]bool) bool {
 			return false
 		}
 	}
-	if p.maxQueueSize <= 0 {
+	if p.maxQueueSize <= 0 || p.waiters.depth >= p.maxQueueSize {
+		return false
+	}
+	if class.MaxQueue > 0 && p.waiters.classDepth(caller.Class) >= class.MaxQueue {
 		return false
 	}
-	return len(p.waiters) < p.maxQueueSize
+	return class.MaxQueuePerCaller <= 0 || p.waiters.callerDepth(caller.Class, caller.ID) < class.MaxQueuePerCaller
 }
 
-func (p *Pool) notifyWaiterLocked() {
-	if len(p.waiters) == 0 {
-		return
+// notifyWaiterLocked wakes the next waiter that can use the account freed
+// as accountID; an empty accountID wakes the next waiter of any kind.
+// Cancelled waiters are dropped on the way, so the wakeup reaches one that
+// is still waiting.
+func (p *Pool) notifyWaiterLocked(accountID string) {
+	var freed config.Account
+	if accountID != "" {
+		freed, _ = p.store.FindAccount(accountID)
 	}
-	waiter := p.waiters[0]
-	p.waiters = p.waiters[1:]
-	close(waiter)
+	fits := func(w *waiter) bool {
+		if accountID == "" || w.target == "" {
+			return true
+		}
+		if group, ok := GroupOf(w.target); ok {
+			return freed.InGroup(group)
+		}
+		return w.target == accountID
+	}
+	for {
+		w := p.waiters.pop(fits)
+		if w == nil {
+			return
+		}
+		close(w.ch)
+		if !w.cancelled() {
+			w.woken, w.freed = true, accountID
+			return
+		}
+	}
+}
+
+func (p *Pool) removeWaiterLocked(w *waiter) bool {
+	return p.waiters.remove(w)
 }
 
-func (p *Pool) removeWaiterLocked(waiter chan struct{}) bool {
-	for i, w := range p.waiters {
-		if w != waiter {
-			continue
+func (p *Pool) drainWaitersLocked() {
+	for _, c := range p.waiters.classes {
+		for _, queue := range c.queues {
+			for _, w := range queue {
+				close(w.ch)
+			}
 		}
-		p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
-		return true
 	}
-	return false
+	p.waiters = waitQueue{}
 }
 
-func (p *Pool) drainWaitersLocked() {
-	for _, waiter := range p.waiters {
-		close(waiter)
+// schedulingConfig is the store's scheduling section, or the lone default
+// class when the pool has no store.
+func (p *Pool) schedulingConfig() config.SchedulingConfig {
+	if p.store == nil {
+		return config.SchedulingConfig{DefaultClass: config.DefaultPriorityClass}
 	}
-	p.waiters = nil
+	return p.store.SchedulingConfig()
 }
@@ -6,6 +6,10 @@ import (
 
 const defaultClaudeModel = "claude-sonnet-4-5"
 
+func mapClaudeModel(model string, store ConfigReader) string {
+	return claudeconv.MapClaudeModel(model, store, defaultClaudeModel)
+}
+
 func convertClaudeToDeepSeek(claudeReq map[string]any, store ConfigReader) map[string]any {
 	return claudeconv.ConvertClaudeToDeepSeek(claudeReq, store, defaultClaudeModel)
 }
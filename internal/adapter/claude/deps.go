@@ -22,6 +22,15 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ClaudeMapping() map[string]string
+	Models() []config.ModelConfig
+	RoutingRules() []config.RoutingRule
+	ContextConfig() config.ContextConfig
+	PromptTemplates() []config.PromptTemplate
+	ToolPrompts() []config.ToolPrompt
+	CompatReasoningMode(key string) string
+	ToolcallMode() string
+	ToolcallEarlyEmitConfidence() string
+	ToolcallRepairAttempts() int
 }
 
 var _ AuthResolver = (*auth.Resolver)(nil)
@@ -1,12 +1,25 @@
 package claude
 
-import "testing"
+import (
+	"testing"
+
+	"ds2api/internal/config"
+)
 
 type mockClaudeConfig struct {
 	m map[string]string
 }
 
-func (m mockClaudeConfig) ClaudeMapping() map[string]string { return m.m }
+func (m mockClaudeConfig) ClaudeMapping() map[string]string         { return m.m }
+func (m mockClaudeConfig) CompatReasoningMode(string) string        { return "" }
+func (m mockClaudeConfig) ToolcallMode() string                     { return "" }
+func (m mockClaudeConfig) ToolcallEarlyEmitConfidence() string      { return "" }
+func (m mockClaudeConfig) ToolcallRepairAttempts() int              { return 0 }
+func (m mockClaudeConfig) Models() []config.ModelConfig             { return config.DefaultModels() }
+func (m mockClaudeConfig) RoutingRules() []config.RoutingRule       { return nil }
+func (m mockClaudeConfig) ContextConfig() config.ContextConfig      { return config.ContextConfig{} }
+func (m mockClaudeConfig) PromptTemplates() []config.PromptTemplate { return nil }
+func (m mockClaudeConfig) ToolPrompts() []config.ToolPrompt         { return nil }
 
 func TestNormalizeClaudeRequestUsesConfigInterfaceMapping(t *testing.T) {
 	req := map[string]any{
@@ -20,7 +33,7 @@ func TestNormalizeClaudeRequestUsesConfigInterfaceMapping(t *testing.T) {
 			"fast": "deepseek-chat",
 			"slow": "deepseek-reasoner-search",
 		},
-	}, req)
+	}, req, config.RouteInput{})
 	if err != nil {
 		t.Fatalf("normalizeClaudeRequest error: %v", err)
 	}
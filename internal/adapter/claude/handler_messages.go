@@ -2,6 +2,7 @@ package claude
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,8 +12,11 @@ import (
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
 	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/respcache"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
 )
 
 func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
@@ -36,29 +40,28 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 		writeClaudeError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
-	norm, err := normalizeClaudeRequest(h.Store, req)
+	norm, err := normalizeClaudeRequest(h.Store, req, auth.RouteInput(r))
 	if err != nil {
 		writeClaudeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	stdReq := norm.Standard
+	stdReq := upstream.FitContext(r.Context(), h.DS, a, w.Header(), norm.Standard)
 
-	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
+	completion, err := upstream.OpenShared(r.Context(), h.DS, h.sharing(r, a), a, stdReq, w.Header())
 	if err != nil {
-		writeClaudeError(w, http.StatusUnauthorized, "invalid token.")
-		return
-	}
-	pow, err := h.DS.GetPow(r.Context(), a, 3)
-	if err != nil {
-		writeClaudeError(w, http.StatusUnauthorized, "Failed to get PoW")
-		return
-	}
-	requestPayload := stdReq.CompletionPayload(sessionID)
-	resp, err := h.DS.CallCompletion(r.Context(), a, requestPayload, pow, 3)
-	if err != nil {
-		writeClaudeError(w, http.StatusInternalServerError, "Failed to get Claude response.")
+		switch upstream.StageOf(err) {
+		case upstream.StageAccount:
+			writeClaudeError(w, http.StatusTooManyRequests, errors.Unwrap(err).Error())
+		case upstream.StageSession:
+			writeClaudeError(w, http.StatusUnauthorized, "invalid token.")
+		case upstream.StagePow:
+			writeClaudeError(w, http.StatusUnauthorized, "Failed to get PoW")
+		default:
+			writeClaudeError(w, http.StatusInternalServerError, "Failed to get Claude response.")
+		}
 		return
 	}
+	resp := completion.Resp
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
@@ -66,23 +69,38 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limits := sse.LimitsFromRequest(stdReq)
+	tools := h.toolCallReader(r.Context(), a, stdReq)
 	if stdReq.Stream {
-		h.handleClaudeStreamRealtime(w, r, resp, stdReq.ResponseModel, norm.NormalizedMessages, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		h.handleClaudeStreamWithLimits(w, r, resp, stdReq.ResponseModel, norm.NormalizedMessages, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ReasoningMode, limits, tools)
 		return
 	}
-	result := sse.CollectStream(resp, stdReq.Thinking, true)
-	respBody := claudefmt.BuildMessageResponse(
+	result := sse.CollectStreamWithLimits(resp, stdReq.Thinking, true, limits)
+	finalText, citations := sse.ResolveCitations(result.Text, result.SearchResults, stdReq.Search)
+	respBody := claudefmt.BuildMessageResponseWithStop(
 		fmt.Sprintf("msg_%d", time.Now().UnixNano()),
 		stdReq.ResponseModel,
 		norm.NormalizedMessages,
 		result.Thinking,
-		result.Text,
+		finalText,
 		stdReq.ToolNames,
+		tools,
+		claudeStopReason(result.Limit),
+		result.StopSequence,
 	)
+	claudefmt.AddMessageCitations(respBody, citations)
+	if respcache.IsHit(w.Header()) {
+		claudefmt.MarkCachedInput(respBody["usage"].(map[string]any))
+	}
+	claudefmt.ApplyReasoningMode(respBody, stdReq.ReasoningMode)
 	writeJSON(w, http.StatusOK, respBody)
 }
 
 func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Request, resp *http.Response, model string, messages []any, thinkingEnabled, searchEnabled bool, toolNames []string) {
+	h.handleClaudeStreamWithLimits(w, r, resp, model, messages, thinkingEnabled, searchEnabled, toolNames, util.ReasoningSeparate, sse.OutputLimits{}, util.ToolCallReader{Dialects: h.toolcallDialects()})
+}
+
+func (h *Handler) handleClaudeStreamWithLimits(w http.ResponseWriter, r *http.Request, resp *http.Response, model string, messages []any, thinkingEnabled, searchEnabled bool, toolNames []string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -110,6 +128,10 @@ func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Requ
 		searchEnabled,
 		toolNames,
 	)
+	streamRuntime.cacheHit = respcache.IsHit(w.Header())
+	streamRuntime.limiter = sse.NewOutputLimiter(limits)
+	streamRuntime.reasoning = util.NewReasoningPresenter(reasoningMode)
+	streamRuntime.setToolCallReader(tools, h.toolcallFeatureMatchEnabled(), h.toolcallEarlyEmitHighConfidence())
 	streamRuntime.sendMessageStart()
 
 	initialType := "text"
@@ -132,3 +154,14 @@ func (h *Handler) handleClaudeStreamRealtime(w http.ResponseWriter, r *http.Requ
 		OnFinalize: streamRuntime.onFinalize,
 	})
 }
+
+// sharing lets the request share upstream work with identical requests. A
+// request that joins another's completion gives its account slot back.
+func (h *Handler) sharing(r *http.Request, a *auth.RequestAuth) upstream.Sharing {
+	return upstream.Sharing{
+		Cache:     h.Cache,
+		Flights:   h.Flights,
+		CallerKey: auth.RouteInput(r).Key,
+		Release:   func() { h.Auth.Release(a) },
+	}
+}
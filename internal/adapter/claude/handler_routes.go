@@ -6,8 +6,10 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"ds2api/internal/coalesce"
 	"ds2api/internal/config"
 	"ds2api/internal/deepseek"
+	"ds2api/internal/respcache"
 	"ds2api/internal/util"
 )
 
@@ -18,6 +20,10 @@ type Handler struct {
 	Store ConfigReader
 	Auth  AuthResolver
 	DS    DeepSeekCaller
+	// Cache answers repeated deterministic requests and Flights lets
+	// concurrent identical ones share a completion; nil disables either.
+	Cache   *respcache.Cache
+	Flights *coalesce.Group
 }
 
 var (
@@ -37,5 +43,5 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 }
 
 func (h *Handler) ListModels(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, config.ClaudeModelsResponse())
+	writeJSON(w, http.StatusOK, config.ClaudeModelsResponse(h.Store))
 }
@@ -2,6 +2,7 @@ package claude
 
 import (
 	"ds2api/internal/sse"
+	"ds2api/internal/util"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -183,6 +184,104 @@ func TestHandleClaudeStreamRealtimeToolSafety(t *testing.T) {
 	}
 }
 
+func TestHandleClaudeStreamToolUseStreamsInputJSONDelta(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"Searching now.\n"}`,
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"search\",\"input\":{\"q\":"}`,
+		`data: {"p":"response/content","v":"\"go\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "use tool"}}, false, false, []string{"search"})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	var order []string
+	partial := ""
+	for _, f := range frames {
+		switch f.Event {
+		case "content_block_start":
+			block, _ := f.Payload["content_block"].(map[string]any)
+			order = append(order, "start:"+asString(block["type"]))
+			if block["type"] == "tool_use" && len(block["input"].(map[string]any)) != 0 {
+				t.Fatalf("expected a streamed tool_use block to start empty, got %#v", block)
+			}
+		case "content_block_delta":
+			delta, _ := f.Payload["delta"].(map[string]any)
+			order = append(order, asString(delta["type"]))
+			partial += asString(delta["partial_json"])
+		case "message_delta":
+			delta, _ := f.Payload["delta"].(map[string]any)
+			order = append(order, "stop:"+asString(delta["stop_reason"]))
+		}
+	}
+	want := "start:text,text_delta,start:tool_use,input_json_delta,input_json_delta,stop:tool_use"
+	if strings.Join(order, ",") != want {
+		t.Fatalf("unexpected event order %v, body=%s", order, rec.Body.String())
+	}
+	if partial != `{"q":"go"}` {
+		t.Fatalf("expected the arguments streamed as partial JSON, got %q", partial)
+	}
+}
+
+func TestHandleClaudeStreamToolUseWithInputSchemaStreamsAndChecksAtStop(t *testing.T) {
+	tools := []any{map[string]any{
+		"name": "search",
+		"input_schema": map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"q": map[string]any{"type": "string"}, "limit": map[string]any{"type": "integer"}},
+			"required":             []any{"q"},
+			"additionalProperties": false,
+		},
+	}}
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"valid", `{\"q\":\"go\",\"limit\":3}`, "start:tool_use,input_json_delta,stop,stop:tool_use"},
+		{"missing required", `{\"limit\":3}`, "start:tool_use,input_json_delta,error"},
+		{"needs coercion", `{\"q\":\"go\",\"limit\":\"3\"}`, "start:tool_use,input_json_delta,error"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{}
+			resp := makeClaudeSSEHTTPResponse(
+				`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"search\",\"input\":"}`,
+				`data: {"p":"response/content","v":"`+tc.input+`}]}"}`,
+				`data: [DONE]`,
+			)
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+			reader := util.NewToolCallReader(util.ToolDialectJSON, util.CompileToolSchemas(tools))
+
+			h.handleClaudeStreamWithLimits(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "use tool"}}, false, false, []string{"search"}, util.ReasoningSeparate, sse.OutputLimits{}, reader)
+
+			var order []string
+			for _, f := range parseClaudeFrames(t, rec.Body.String()) {
+				switch f.Event {
+				case "content_block_start":
+					block, _ := f.Payload["content_block"].(map[string]any)
+					order = append(order, "start:"+asString(block["type"]))
+				case "content_block_delta":
+					delta, _ := f.Payload["delta"].(map[string]any)
+					order = append(order, asString(delta["type"]))
+				case "content_block_stop", "error":
+					order = append(order, strings.TrimPrefix(f.Event, "content_block_"))
+				case "message_delta":
+					delta, _ := f.Payload["delta"].(map[string]any)
+					order = append(order, "stop:"+asString(delta["stop_reason"]))
+				}
+			}
+			if strings.Join(order, ",") != tc.want {
+				t.Fatalf("unexpected event order %v, body=%s", order, rec.Body.String())
+			}
+		})
+	}
+}
+
 func TestHandleClaudeStreamRealtimeToolDetectionFromThinkingFallback(t *testing.T) {
 	h := &Handler{}
 	resp := makeClaudeSSEHTTPResponse(
@@ -315,3 +414,125 @@ func asString(v any) string {
 	s, _ := v.(string)
 	return s
 }
+
+func TestHandleClaudeStreamStopSequenceReportsMatch(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"Answer: 42</an"}`,
+		`data: {"p":"response/content","v":"swer> trailing"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamWithLimits(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, "", sse.OutputLimits{StopSequences: []string{"</answer>"}}, util.ToolCallReader{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	text := strings.Builder{}
+	for _, f := range findClaudeFrames(frames, "content_block_delta") {
+		delta, _ := f.Payload["delta"].(map[string]any)
+		if s, ok := delta["text"].(string); ok {
+			text.WriteString(s)
+		}
+	}
+	if text.String() != "Answer: 42" {
+		t.Fatalf("expected text cut before stop sequence, got %q body=%s", text.String(), rec.Body.String())
+	}
+	deltas := findClaudeFrames(frames, "message_delta")
+	if len(deltas) != 1 {
+		t.Fatalf("expected one message_delta, body=%s", rec.Body.String())
+	}
+	delta, _ := deltas[0].Payload["delta"].(map[string]any)
+	if delta["stop_reason"] != "stop_sequence" || delta["stop_sequence"] != "</answer>" {
+		t.Fatalf("expected stop_sequence stop reason, got %#v", delta)
+	}
+}
+
+func TestHandleClaudeStreamMaxTokensStopReason(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"abcdefghijklmnop"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamWithLimits(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, false, nil, "", sse.OutputLimits{MaxTokens: 2, CountThinking: true}, util.ToolCallReader{})
+
+	frames := parseClaudeFrames(t, rec.Body.String())
+	deltas := findClaudeFrames(frames, "message_delta")
+	if len(deltas) != 1 {
+		t.Fatalf("expected one message_delta, body=%s", rec.Body.String())
+	}
+	delta, _ := deltas[0].Payload["delta"].(map[string]any)
+	usage, _ := deltas[0].Payload["usage"].(map[string]any)
+	if delta["stop_reason"] != "max_tokens" || delta["stop_sequence"] != nil || usage["output_tokens"] != float64(2) {
+		t.Fatalf("expected max_tokens stop within budget, got %#v", deltas[0].Payload)
+	}
+}
+
+func TestHandleClaudeStreamRealtimeSearchCitationsDelta(t *testing.T) {
+	h := &Handler{}
+	resp := makeClaudeSSEHTTPResponse(
+		`data: {"p":"response/search_results","v":[{"url":"https://a.example","title":"A","snippet":"blue sky","cite_index":1}]}`,
+		`data: {"p":"response/content","v":"sky is blue"}`,
+		`data: {"p":"response/content","v":"[citation:1]."}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+	h.handleClaudeStreamRealtime(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, false, true, nil)
+
+	if strings.Contains(rec.Body.String(), "[citation:") {
+		t.Fatalf("expected citation markers stripped, body=%s", rec.Body.String())
+	}
+	var citation map[string]any
+	for _, f := range findClaudeFrames(parseClaudeFrames(t, rec.Body.String()), "content_block_delta") {
+		delta, _ := f.Payload["delta"].(map[string]any)
+		if delta["type"] == "citations_delta" {
+			citation, _ = delta["citation"].(map[string]any)
+		}
+	}
+	if citation == nil {
+		t.Fatalf("expected citations_delta, body=%s", rec.Body.String())
+	}
+	if citation["type"] != "web_search_result_location" || citation["url"] != "https://a.example" || citation["cited_text"] != "blue sky" {
+		t.Fatalf("unexpected citation %#v", citation)
+	}
+}
+
+func TestHandleClaudeStreamReasoningModes(t *testing.T) {
+	cases := []struct {
+		mode     util.ReasoningMode
+		thinking string
+		text     string
+	}{
+		{util.ReasoningInlineThinkTags, "", "<think>思考\n结论</think>\n\nok"},
+		{util.ReasoningHidden, "", "ok"},
+		{util.ReasoningSummaryOnly, "结论", "ok"},
+	}
+	for _, tc := range cases {
+		h := &Handler{}
+		resp := makeClaudeSSEHTTPResponse(
+			`data: {"p":"response/thinking_content","v":"思考\n"}`,
+			`data: {"p":"response/thinking_content","v":"结论"}`,
+			`data: {"p":"response/content","v":"ok"}`,
+			`data: [DONE]`,
+		)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/messages", nil)
+
+		h.handleClaudeStreamWithLimits(rec, req, resp, "claude-sonnet-4-5", []any{map[string]any{"role": "user", "content": "hi"}}, true, false, nil, tc.mode, sse.OutputLimits{}, util.ToolCallReader{})
+
+		var thinking, text strings.Builder
+		for _, f := range findClaudeFrames(parseClaudeFrames(t, rec.Body.String()), "content_block_delta") {
+			delta, _ := f.Payload["delta"].(map[string]any)
+			thinking.WriteString(asString(delta["thinking"]))
+			text.WriteString(asString(delta["text"]))
+		}
+		if thinking.String() != tc.thinking || text.String() != tc.text {
+			t.Fatalf("%s: got thinking=%q text=%q", tc.mode, thinking.String(), text.String())
+		}
+	}
+}
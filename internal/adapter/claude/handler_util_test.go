@@ -3,8 +3,13 @@ package claude
 import (
 	"strings"
 	"testing"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
 )
 
+var claudeDefaultToolPrompt = util.ToolInstructionsFor(nil, config.ModelSurfaceClaude, "", "")
+
 // ─── normalizeClaudeMessages ─────────────────────────────────────────
 
 func TestNormalizeClaudeMessagesSimpleString(t *testing.T) {
@@ -114,7 +119,7 @@ func TestBuildClaudeToolPromptSingleTool(t *testing.T) {
 			},
 		},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, claudeDefaultToolPrompt)
 	if prompt == "" {
 		t.Fatal("expected non-empty prompt")
 	}
@@ -135,7 +140,7 @@ func TestBuildClaudeToolPromptMultipleTools(t *testing.T) {
 		map[string]any{"name": "tool1", "description": "desc1"},
 		map[string]any{"name": "tool2", "description": "desc2"},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, claudeDefaultToolPrompt)
 	if !containsStr(prompt, "tool1") || !containsStr(prompt, "tool2") {
 		t.Fatalf("expected both tools in prompt")
 	}
@@ -157,7 +162,7 @@ func TestBuildClaudeToolPromptSupportsOpenAIStyleFunctionTool(t *testing.T) {
 			},
 		},
 	}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, claudeDefaultToolPrompt)
 	if !containsStr(prompt, "Tool: search") {
 		t.Fatalf("expected OpenAI-style function tool name in prompt, got: %q", prompt)
 	}
@@ -171,7 +176,7 @@ func TestBuildClaudeToolPromptSupportsOpenAIStyleFunctionTool(t *testing.T) {
 
 func TestBuildClaudeToolPromptSkipsNonMap(t *testing.T) {
 	tools := []any{"not a map"}
-	prompt := buildClaudeToolPrompt(tools)
+	prompt := buildClaudeToolPrompt(tools, claudeDefaultToolPrompt)
 	if prompt == "" {
 		t.Fatal("expected non-empty prompt even with invalid tools")
 	}
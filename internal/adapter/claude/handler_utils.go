@@ -1,9 +1,15 @@
 package claude
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/prompt"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
 )
 
 func normalizeClaudeMessages(messages []any) []any {
@@ -39,8 +45,8 @@ func normalizeClaudeMessages(messages []any) []any {
 	return out
 }
 
-func buildClaudeToolPrompt(tools []any) string {
-	parts := []string{"You are Claude, a helpful AI assistant. You have access to these tools:"}
+func buildClaudeToolPrompt(tools []any, instructions util.ToolInstructions) string {
+	specs := make([]prompt.ToolSpec, 0, len(tools))
 	for _, t := range tools {
 		m, ok := t.(map[string]any)
 		if !ok {
@@ -48,14 +54,9 @@ func buildClaudeToolPrompt(tools []any) string {
 		}
 		name, desc, schemaObj := extractClaudeToolMeta(m)
 		schema, _ := json.Marshal(schemaObj)
-		parts = append(parts, fmt.Sprintf("Tool: %s\nDescription: %s\nParameters: %s", name, desc, schema))
-	}
-	parts = append(parts,
-		"When you need to use tools, you can call multiple tools in one response. Output ONLY JSON like {\"tool_calls\":[{\"name\":\"tool\",\"input\":{}}]}",
-		"History markers in conversation: [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] are your previous tool calls; [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] are runtime tool outputs, not user input.",
-		"After a valid [TOOL_RESULT_HISTORY], continue with final answer instead of repeating the same call unless required fields are still missing.",
-	)
-	return strings.Join(parts, "\n\n")
+		specs = append(specs, prompt.ToolSpec{Name: name, Description: desc, Parameters: string(schema)})
+	}
+	return instructions.Render(specs, util.DefaultToolChoicePolicy())
 }
 
 func formatClaudeToolResultForPrompt(block map[string]any) string {
@@ -170,3 +171,45 @@ func cloneMap(in map[string]any) map[string]any {
 	}
 	return out
 }
+
+// toolcallFeatureMatchEnabled reports whether toolcall.mode lets the stream
+// sieve tool calls out of the text as it arrives.
+func (h *Handler) toolcallFeatureMatchEnabled() bool {
+	if h == nil || h.Store == nil {
+		return true
+	}
+	mode := strings.TrimSpace(strings.ToLower(h.Store.ToolcallMode()))
+	if mode == "off" {
+		return false
+	}
+	_, err := util.ParseToolcallMode(mode)
+	return err == nil
+}
+
+// toolcallEarlyEmitHighConfidence reports whether tool arguments stream as
+// they are written.
+func (h *Handler) toolcallEarlyEmitHighConfidence() bool {
+	if h == nil || h.Store == nil {
+		return true
+	}
+	level := strings.TrimSpace(strings.ToLower(h.Store.ToolcallEarlyEmitConfidence()))
+	return level == "" || level == "high"
+}
+
+// toolcallDialects returns the tool-call dialects toolcall.mode enables.
+func (h *Handler) toolcallDialects() util.ToolDialects {
+	if h == nil || h.Store == nil {
+		return util.ToolDialectJSON
+	}
+	return util.ToolDialectsForMode(h.Store.ToolcallMode())
+}
+
+// toolCallReader returns the reader for stdReq's tool calls. Invalid calls
+// are repaired on a's account, toolcall.repair_attempts times at most.
+func (h *Handler) toolCallReader(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest) util.ToolCallReader {
+	attempts := 0
+	if h != nil && h.Store != nil {
+		attempts = h.Store.ToolcallRepairAttempts()
+	}
+	return upstream.ToolCallReader(ctx, h.DS, a, stdReq, h.toolcallDialects(), attempts)
+}
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"ds2api/internal/config"
-	"ds2api/internal/deepseek"
 	"ds2api/internal/util"
 )
 
@@ -14,53 +13,81 @@ type claudeNormalizedRequest struct {
 	NormalizedMessages []any
 }
 
-func normalizeClaudeRequest(store ConfigReader, req map[string]any) (claudeNormalizedRequest, error) {
+func normalizeClaudeRequest(store ConfigReader, req map[string]any, route config.RouteInput) (claudeNormalizedRequest, error) {
 	model, _ := req["model"].(string)
 	messagesRaw, _ := req["messages"].([]any)
 	if strings.TrimSpace(model) == "" || len(messagesRaw) == 0 {
 		return claudeNormalizedRequest{}, fmt.Errorf("Request must include 'model' and 'messages'.")
 	}
+	// Only a client supplied max_tokens is enforced locally; the injected
+	// default just keeps the payload Anthropic-shaped.
+	maxTokens := util.MaxTokensFrom(req["max_tokens"])
 	if _, ok := req["max_tokens"]; !ok {
 		req["max_tokens"] = 8192
 	}
 	normalizedMessages := normalizeClaudeMessages(messagesRaw)
 	payload := cloneMap(req)
 	payload["messages"] = normalizedMessages
-	toolsRequested, _ := req["tools"].([]any)
-	payload["messages"] = injectClaudeToolPrompt(payload, normalizedMessages, toolsRequested)
+	toolsRequested, webSearch := splitClaudeServerTools(req["tools"])
 
+	dsModel := mapClaudeModel(model, store)
+	thinkingEnabled, searchEnabled, _ := config.ModelConfigFor(store, dsModel)
+	route.Model, route.Surface = model, config.ModelSurfaceClaude
+	accountGroup := ""
+	if decision, ok := config.RouteByRule(store.RoutingRules(), store, route); ok {
+		dsModel, thinkingEnabled, searchEnabled = decision.Model, decision.Thinking, decision.Search
+		accountGroup = decision.AccountGroup
+	}
+	toolPrompt := util.ToolInstructionsFor(store, config.ModelSurfaceClaude, dsModel, route.Key)
+	payload["messages"] = injectClaudeToolPrompt(payload, normalizedMessages, toolsRequested, toolPrompt)
 	dsPayload := convertClaudeToDeepSeek(payload, store)
-	dsModel, _ := dsPayload["model"].(string)
-	thinkingEnabled, searchEnabled, ok := config.GetModelConfig(dsModel)
-	if !ok {
-		thinkingEnabled = false
-		searchEnabled = false
+	dsPayload["model"] = dsModel
+	if webSearch {
+		searchEnabled = true
+	}
+	if enabled, ok := claudeThinkingEnabled(req["thinking"]); ok {
+		thinkingEnabled = enabled
+	}
+	reasoningMode, err := claudeReasoningMode(store, req, route)
+	if err != nil {
+		return claudeNormalizedRequest{}, err
 	}
-	finalPrompt := deepseek.MessagesPrepare(toMessageMaps(dsPayload["messages"]))
 	toolNames := extractClaudeToolNames(toolsRequested)
 
-	return claudeNormalizedRequest{
+	norm := claudeNormalizedRequest{
 		Standard: util.StandardRequest{
 			Surface:        "anthropic_messages",
 			RequestedModel: strings.TrimSpace(model),
 			ResolvedModel:  dsModel,
 			ResponseModel:  strings.TrimSpace(model),
 			Messages:       payload["messages"].([]any),
-			FinalPrompt:    finalPrompt,
 			ToolNames:      toolNames,
+			ToolSchemas:    util.CompileToolSchemas(toolsRequested),
 			Stream:         util.ToBool(req["stream"]),
 			Thinking:       thinkingEnabled,
 			Search:         searchEnabled,
+			StopSequences:  util.StopSequencesFrom(req["stop_sequences"]),
+
+			ReasoningMode:            reasoningMode,
+			MaxOutputTokens:          maxTokens,
+			MaxTokensIncludeThinking: true,
+			AccountGroup:             accountGroup,
 		},
 		NormalizedMessages: normalizedMessages,
-	}, nil
+	}
+	if metadata, ok := req["metadata"].(map[string]any); ok {
+		norm.Standard.EndUser, _ = metadata["user_id"].(string)
+	}
+	norm.Standard.Template = util.ChatTemplateFor(store, dsModel, route.Key)
+	norm.Standard.FitPrompt(toMessageMaps(dsPayload["messages"]), util.NewContextPolicy(store.ContextConfig(), store, dsModel))
+	return norm, nil
 }
 
-func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, tools []any) []any {
+func injectClaudeToolPrompt(payload map[string]any, normalizedMessages []any, tools []any, instructions util.ToolInstructions) []any {
 	if len(tools) == 0 {
 		return normalizedMessages
 	}
-	toolPrompt := strings.TrimSpace(buildClaudeToolPrompt(tools))
+	toolPrompt := strings.TrimSpace(buildClaudeToolPrompt(tools, instructions))
 	if toolPrompt == "" {
 		return normalizedMessages
 	}
@@ -111,3 +138,46 @@ func cloneAnySlice(in []any) []any {
 	copy(out, in)
 	return out
 }
+
+// splitClaudeServerTools drops Anthropic server tools from the client tool
+// list. The web_search server tool is served by DeepSeek's own search, so it
+// only switches search on instead of reaching the tool prompt.
+func splitClaudeServerTools(raw any) ([]any, bool) {
+	tools, _ := raw.([]any)
+	out := make([]any, 0, len(tools))
+	webSearch := false
+	for _, t := range tools {
+		m, _ := t.(map[string]any)
+		typ, _ := m["type"].(string)
+		if strings.HasPrefix(typ, "web_search_") {
+			webSearch = true
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, webSearch
+}
+
+// claudeThinkingEnabled reads the extended thinking switch
+// ({"type":"enabled"|"disabled"}); anything else keeps the model default.
+func claudeThinkingEnabled(raw any) (bool, bool) {
+	m, _ := raw.(map[string]any)
+	switch typ, _ := m["type"].(string); typ {
+	case "enabled":
+		return true, true
+	case "disabled":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// claudeReasoningMode reads reasoning_mode, defaulting to the configured
+// compat default for the caller's key.
+func claudeReasoningMode(store ConfigReader, req map[string]any, route config.RouteInput) (util.ReasoningMode, error) {
+	fallback := ""
+	if store != nil {
+		fallback = store.CompatReasoningMode(route.Key)
+	}
+	return util.ReasoningModeFrom(req["reasoning_mode"], fallback)
+}
@@ -19,7 +19,7 @@ func TestNormalizeClaudeRequest(t *testing.T) {
 			map[string]any{"name": "search", "description": "Search"},
 		},
 	}
-	norm, err := normalizeClaudeRequest(store, req)
+	norm, err := normalizeClaudeRequest(store, req, config.RouteInput{})
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -51,7 +51,7 @@ func TestNormalizeClaudeRequestInjectsToolsIntoExistingSystemMessage(t *testing.
 		},
 	}
 
-	norm, err := normalizeClaudeRequest(store, req)
+	norm, err := normalizeClaudeRequest(store, req, config.RouteInput{})
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -78,7 +78,7 @@ func TestNormalizeClaudeRequestInjectsToolsIntoTopLevelSystem(t *testing.T) {
 		},
 	}
 
-	norm, err := normalizeClaudeRequest(store, req)
+	norm, err := normalizeClaudeRequest(store, req, config.RouteInput{})
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -90,3 +90,54 @@ func TestNormalizeClaudeRequestInjectsToolsIntoTopLevelSystem(t *testing.T) {
 		t.Fatalf("expected tool prompt injected, got=%q", norm.Standard.FinalPrompt)
 	}
 }
+
+func TestNormalizeClaudeRequestCapabilityToggles(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{}`)
+	store := config.LoadStore()
+	req := map[string]any{
+		"model":    "claude-sonnet-4-5",
+		"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+		"thinking": map[string]any{"type": "enabled", "budget_tokens": 2048},
+		"tools": []any{
+			map[string]any{"type": "web_search_20250305", "name": "web_search", "max_uses": 3},
+			map[string]any{"name": "lookup", "description": "Lookup"},
+		},
+	}
+	norm, err := normalizeClaudeRequest(store, req, config.RouteInput{})
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !norm.Standard.Search || !norm.Standard.Thinking {
+		t.Fatalf("expected search and thinking on, got %#v", norm.Standard)
+	}
+	if len(norm.Standard.ToolNames) != 1 || norm.Standard.ToolNames[0] != "lookup" {
+		t.Fatalf("expected only client tools, got %#v", norm.Standard.ToolNames)
+	}
+}
+
+func TestNormalizeClaudeRequestRoutingRuleOverridesMapping(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"routing_rules":[{"model":"claude-haiku-*","surfaces":["claude"],"keys":["key-x"],"target":"deepseek-reasoner-search"}]
+	}`)
+	store := config.LoadStore()
+	newReq := func() map[string]any {
+		return map[string]any{
+			"model":    "claude-haiku-4-5",
+			"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+		}
+	}
+	norm, err := normalizeClaudeRequest(store, newReq(), config.RouteInput{Key: "key-x"})
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if norm.Standard.ResolvedModel != "deepseek-reasoner-search" || !norm.Standard.Thinking || !norm.Standard.Search {
+		t.Fatalf("expected routing rule target, got %#v", norm.Standard)
+	}
+	norm, err = normalizeClaudeRequest(store, newReq(), config.RouteInput{Key: "other"})
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if norm.Standard.ResolvedModel != "deepseek-chat" {
+		t.Fatalf("expected claude_mapping fast model without a rule, got %q", norm.Standard.ResolvedModel)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"strings"
 	"time"
 
+	claudefmt "ds2api/internal/format/claude"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/toolsieve"
+	"ds2api/internal/util"
 )
 
 type claudeStreamRuntime struct {
@@ -17,13 +20,25 @@ type claudeStreamRuntime struct {
 
 	model     string
 	toolNames []string
+	tools     util.ToolCallReader
 	messages  []any
 
-	thinkingEnabled   bool
-	searchEnabled     bool
-	bufferToolContent bool
+	thinkingEnabled bool
+	searchEnabled   bool
+	// bufferToolContent holds all text back until the output ends, when
+	// tool calls are read from it. sieveToolCalls instead streams text and
+	// sends each tool_use block as soon as the sieve finds the call.
+	bufferToolContent   bool
+	sieveToolCalls      bool
+	emitEarlyToolDeltas bool
+	toolSieve           toolsieve.State
 
 	messageID string
+	// cacheHit reports the input as cache reads in usage.
+	cacheHit  bool
+	limiter   *sse.OutputLimiter
+	citations *sse.SearchCitations
+	reasoning *util.ReasoningPresenter
 	thinking  strings.Builder
 	text      strings.Builder
 
@@ -32,6 +47,11 @@ type claudeStreamRuntime struct {
 	thinkingBlockIndex int
 	textBlockOpen      bool
 	textBlockIndex     int
+	toolBlockOpen      bool
+	toolBlockIndex     int
+	toolBlockName      string
+	toolArgsStreamed   bool
+	toolUses           int
 	ended              bool
 	upstreamErr        string
 }
@@ -46,6 +66,10 @@ func newClaudeStreamRuntime(
 	searchEnabled bool,
 	toolNames []string,
 ) *claudeStreamRuntime {
+	var citations *sse.SearchCitations
+	if searchEnabled {
+		citations = sse.NewSearchCitations()
+	}
 	return &claudeStreamRuntime{
 		w:                  w,
 		rc:                 rc,
@@ -54,15 +78,35 @@ func newClaudeStreamRuntime(
 		messages:           messages,
 		thinkingEnabled:    thinkingEnabled,
 		searchEnabled:      searchEnabled,
+		citations:          citations,
 		bufferToolContent:  len(toolNames) > 0,
 		toolNames:          toolNames,
 		messageID:          fmt.Sprintf("msg_%d", time.Now().UnixNano()),
 		thinkingBlockIndex: -1,
 		textBlockIndex:     -1,
+		toolBlockIndex:     -1,
+	}
+}
+
+// setToolCallReader selects how tool calls are read from the output. With
+// sieve set, text streams while the sieve watches for calls, and argument
+// deltas stream too when earlyDeltas is set. Calls are checked against their
+// schemas when they close.
+func (s *claudeStreamRuntime) setToolCallReader(tools util.ToolCallReader, sieve, earlyDeltas bool) {
+	s.tools = tools
+	s.toolSieve.Dialects = tools.Dialects
+	s.toolSieve.StreamOpenCall = true
+	if sieve && s.bufferToolContent {
+		s.bufferToolContent = false
+		s.sieveToolCalls = true
+		s.emitEarlyToolDeltas = earlyDeltas
 	}
 }
 
 func (s *claudeStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedDecision {
+	if s.ended {
+		return streamengine.ParsedDecision{Stop: true}
+	}
 	if !parsed.Parsed {
 		return streamengine.ParsedDecision{}
 	}
@@ -74,12 +118,25 @@ func (s *claudeStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Parse
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
+	s.citations.AddResults(parsed.SearchResults)
+	contentSeen := s.emitParts(s.limiter.Apply(parsed.Parts))
+	if s.limiter.Reached() {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonOutputLimit, ContentSeen: contentSeen}
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *claudeStreamRuntime) emitParts(parts []sse.ContentPart) bool {
 	contentSeen := false
-	for _, p := range parsed.Parts {
-		if p.Text == "" {
-			continue
+	for _, p := range parts {
+		if s.ended {
+			break
+		}
+		var citations []sse.Citation
+		if p.Type != "thinking" {
+			p.Text, citations = s.citations.Strip(p.Text)
 		}
-		if p.Type != "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
+		if p.Text == "" && len(citations) == 0 {
 			continue
 		}
 		contentSeen = true
@@ -89,58 +146,102 @@ func (s *claudeStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Parse
 				continue
 			}
 			s.thinking.WriteString(p.Text)
-			s.closeTextBlock()
-			if !s.thinkingBlockOpen {
-				s.thinkingBlockIndex = s.nextBlockIndex
-				s.nextBlockIndex++
-				s.send("content_block_start", map[string]any{
-					"type":  "content_block_start",
-					"index": s.thinkingBlockIndex,
-					"content_block": map[string]any{
-						"type":     "thinking",
-						"thinking": "",
-					},
-				})
-				s.thinkingBlockOpen = true
-			}
-			s.send("content_block_delta", map[string]any{
-				"type":  "content_block_delta",
-				"index": s.thinkingBlockIndex,
-				"delta": map[string]any{
-					"type":     "thinking_delta",
-					"thinking": p.Text,
-				},
-			})
+			s.presentThinking(s.reasoning.Thinking(p.Text))
 			continue
 		}
 
+		s.presentThinking(s.reasoning.EndThinking())
 		s.text.WriteString(p.Text)
 		if s.bufferToolContent {
 			continue
 		}
-		s.closeThinkingBlock()
-		if !s.textBlockOpen {
-			s.textBlockIndex = s.nextBlockIndex
+		if s.sieveToolCalls {
+			s.emitToolEvents(s.toolSieve.Process(p.Text, s.toolNames))
+		} else if p.Text != "" || s.textBlockOpen {
+			s.sendTextDelta(p.Text)
+		}
+		s.sendCitations(citations)
+	}
+	return contentSeen
+}
+
+// sendCitations attaches citations to the text block that is streaming.
+func (s *claudeStreamRuntime) sendCitations(citations []sse.Citation) {
+	if len(citations) == 0 || !s.textBlockOpen {
+		return
+	}
+	finalText := s.text.String()
+	for _, c := range citations {
+		s.send("content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": s.textBlockIndex,
+			"delta": map[string]any{
+				"type":     "citations_delta",
+				"citation": claudefmt.BuildWebSearchCitation(finalText, c),
+			},
+		})
+	}
+}
+
+// presentThinking streams thinking as the reasoning mode shows it: in a
+// thinking block, or inline as text.
+func (s *claudeStreamRuntime) presentThinking(reasoning, inline string) {
+	if reasoning != "" {
+		s.closeTextBlock()
+		s.closeToolBlock()
+		if !s.thinkingBlockOpen {
+			s.thinkingBlockIndex = s.nextBlockIndex
 			s.nextBlockIndex++
 			s.send("content_block_start", map[string]any{
 				"type":  "content_block_start",
-				"index": s.textBlockIndex,
+				"index": s.thinkingBlockIndex,
 				"content_block": map[string]any{
-					"type": "text",
-					"text": "",
+					"type":     "thinking",
+					"thinking": "",
 				},
 			})
-			s.textBlockOpen = true
+			s.thinkingBlockOpen = true
 		}
+		s.send("content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": s.thinkingBlockIndex,
+			"delta": map[string]any{
+				"type":     "thinking_delta",
+				"thinking": reasoning,
+			},
+		})
+	}
+	if inline != "" {
+		s.sendTextDelta(inline)
+	}
+}
+
+// sendTextDelta streams text into the open text block, opening one after
+// closing any thinking block.
+func (s *claudeStreamRuntime) sendTextDelta(text string) {
+	s.closeThinkingBlock()
+	s.closeToolBlock()
+	if !s.textBlockOpen {
+		s.textBlockIndex = s.nextBlockIndex
+		s.nextBlockIndex++
+		s.send("content_block_start", map[string]any{
+			"type":  "content_block_start",
+			"index": s.textBlockIndex,
+			"content_block": map[string]any{
+				"type": "text",
+				"text": "",
+			},
+		})
+		s.textBlockOpen = true
+	}
+	if text != "" {
 		s.send("content_block_delta", map[string]any{
 			"type":  "content_block_delta",
 			"index": s.textBlockIndex,
 			"delta": map[string]any{
 				"type": "text_delta",
-				"text": p.Text,
+				"text": text,
 			},
 		})
 	}
-
-	return streamengine.ParsedDecision{ContentSeen: contentSeen}
 }
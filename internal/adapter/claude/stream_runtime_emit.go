@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	claudefmt "ds2api/internal/format/claude"
 	"ds2api/internal/util"
 )
 
@@ -42,7 +43,10 @@ func (s *claudeStreamRuntime) sendPing() {
 }
 
 func (s *claudeStreamRuntime) sendMessageStart() {
-	inputTokens := util.EstimateTokens(fmt.Sprintf("%v", s.messages))
+	usage := map[string]any{"input_tokens": util.EstimateTokens(fmt.Sprintf("%v", s.messages)), "output_tokens": 0}
+	if s.cacheHit {
+		claudefmt.MarkCachedInput(usage)
+	}
 	s.send("message_start", map[string]any{
 		"type": "message_start",
 		"message": map[string]any{
@@ -53,7 +57,7 @@ func (s *claudeStreamRuntime) sendMessageStart() {
 			"content":       []any{},
 			"stop_reason":   nil,
 			"stop_sequence": nil,
-			"usage":         map[string]any{"input_tokens": inputTokens, "output_tokens": 0},
+			"usage":         usage,
 		},
 	})
 }
@@ -1,9 +1,8 @@
 package claude
 
 import (
-	"fmt"
-	"time"
-
+	claudefmt "ds2api/internal/format/claude"
+	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
 	"ds2api/internal/util"
 )
@@ -38,69 +37,49 @@ func (s *claudeStreamRuntime) finalize(stopReason string) {
 	}
 	s.ended = true
 
+	s.emitParts(s.limiter.Flush())
+	s.emitParts(s.citations.Flush())
+	if s.sieveToolCalls {
+		s.emitToolEvents(s.toolSieve.Flush(s.toolNames))
+	}
+	s.presentThinking(s.reasoning.EndThinking())
 	s.closeThinkingBlock()
 	s.closeTextBlock()
+	s.closeToolBlock()
 
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
 
-	if s.bufferToolContent {
-		detected := util.ParseToolCalls(finalText, s.toolNames)
+	switch {
+	case s.bufferToolContent:
+		detected := s.tools.Parse(finalText, s.toolNames)
 		if len(detected) == 0 && finalText == "" && finalThinking != "" {
-			detected = util.ParseToolCalls(finalThinking, s.toolNames)
+			detected = s.tools.Parse(finalThinking, s.toolNames)
 		}
 		if len(detected) > 0 {
-			stopReason = "tool_use"
-			for i, tc := range detected {
-				idx := s.nextBlockIndex + i
-				s.send("content_block_start", map[string]any{
-					"type":  "content_block_start",
-					"index": idx,
-					"content_block": map[string]any{
-						"type":  "tool_use",
-						"id":    fmt.Sprintf("toolu_%d_%d", time.Now().Unix(), idx),
-						"name":  tc.Name,
-						"input": tc.Input,
-					},
-				})
-				s.send("content_block_stop", map[string]any{
-					"type":  "content_block_stop",
-					"index": idx,
-				})
-			}
-			s.nextBlockIndex += len(detected)
+			s.sendToolUses(detected)
 		} else if finalText != "" {
-			idx := s.nextBlockIndex
-			s.nextBlockIndex++
-			s.send("content_block_start", map[string]any{
-				"type":  "content_block_start",
-				"index": idx,
-				"content_block": map[string]any{
-					"type": "text",
-					"text": "",
-				},
-			})
-			s.send("content_block_delta", map[string]any{
-				"type":  "content_block_delta",
-				"index": idx,
-				"delta": map[string]any{
-					"type": "text_delta",
-					"text": finalText,
-				},
-			})
-			s.send("content_block_stop", map[string]any{
-				"type":  "content_block_stop",
-				"index": idx,
-			})
+			for _, block := range claudefmt.SplitCitedText(finalText, s.citations.Citations()) {
+				s.sendBufferedTextBlock(block)
+			}
 		}
+	case s.sieveToolCalls && s.toolUses == 0 && finalText == "" && finalThinking != "":
+		s.sendToolUses(s.tools.Parse(finalThinking, s.toolNames))
+	}
+	if s.toolUses > 0 {
+		stopReason = "tool_use"
 	}
 
+	var stopSequence any
+	if stopReason == "stop_sequence" {
+		stopSequence = s.limiter.StopSequence()
+	}
 	outputTokens := util.EstimateTokens(finalThinking) + util.EstimateTokens(finalText)
 	s.send("message_delta", map[string]any{
 		"type": "message_delta",
 		"delta": map[string]any{
 			"stop_reason":   stopReason,
-			"stop_sequence": nil,
+			"stop_sequence": stopSequence,
 		},
 		"usage": map[string]any{
 			"output_tokens": outputTokens,
@@ -109,6 +88,44 @@ func (s *claudeStreamRuntime) finalize(stopReason string) {
 	s.send("message_stop", map[string]any{"type": "message_stop"})
 }
 
+// sendBufferedTextBlock streams a text block that was held back while
+// watching for tool calls, including any citations it carries.
+func (s *claudeStreamRuntime) sendBufferedTextBlock(block map[string]any) {
+	idx := s.nextBlockIndex
+	s.nextBlockIndex++
+	s.send("content_block_start", map[string]any{
+		"type":  "content_block_start",
+		"index": idx,
+		"content_block": map[string]any{
+			"type": "text",
+			"text": "",
+		},
+	})
+	s.send("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": idx,
+		"delta": map[string]any{
+			"type": "text_delta",
+			"text": block["text"],
+		},
+	})
+	citations, _ := block["citations"].([]any)
+	for _, c := range citations {
+		s.send("content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": idx,
+			"delta": map[string]any{
+				"type":     "citations_delta",
+				"citation": c,
+			},
+		})
+	}
+	s.send("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": idx,
+	})
+}
+
 func (s *claudeStreamRuntime) onFinalize(reason streamengine.StopReason, scannerErr error) {
 	if string(reason) == "upstream_error" {
 		s.sendError(s.upstreamErr)
@@ -118,5 +135,17 @@ func (s *claudeStreamRuntime) onFinalize(reason streamengine.StopReason, scanner
 		s.sendError(scannerErr.Error())
 		return
 	}
-	s.finalize("end_turn")
+	s.finalize(claudeStopReason(s.limiter.Reason()))
+}
+
+// claudeStopReason maps a local output limit to the Anthropic stop_reason.
+func claudeStopReason(reason sse.LimitReason) string {
+	switch reason {
+	case sse.LimitStopSequence:
+		return "stop_sequence"
+	case sse.LimitMaxTokens:
+		return "max_tokens"
+	default:
+		return "end_turn"
+	}
 }
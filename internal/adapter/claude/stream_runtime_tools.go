@@ -0,0 +1,142 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"ds2api/internal/toolsieve"
+	"ds2api/internal/util"
+)
+
+// emitToolEvents sends sieved output: text as text deltas, a call being
+// written as a tool_use block fed by input_json_delta, and complete calls
+// as whole tool_use blocks.
+func (s *claudeStreamRuntime) emitToolEvents(events []toolsieve.Event) {
+	for _, evt := range events {
+		if s.ended {
+			return
+		}
+		switch {
+		case len(evt.ToolCallDeltas) > 0:
+			if s.emitEarlyToolDeltas {
+				s.sendToolDeltas(evt.ToolCallDeltas)
+			}
+		case len(evt.ToolCalls) > 0:
+			calls := evt.ToolCalls
+			if s.toolBlockOpen && s.toolBlockName == calls[0].Name {
+				if !s.closeStreamedToolUse(calls[0]) {
+					return
+				}
+				calls = calls[1:]
+			}
+			s.sendToolUses(s.tools.Check(calls))
+		case evt.Content != "":
+			s.sendTextDelta(evt.Content)
+		}
+	}
+}
+
+// sendToolDeltas opens a tool_use block once the call's name is known and
+// streams its arguments. Arguments written as a JSON string are not valid
+// partial JSON; they are sent whole when the call completes.
+func (s *claudeStreamRuntime) sendToolDeltas(deltas []toolsieve.CallDelta) {
+	for _, d := range deltas {
+		if d.Name != "" && !s.toolBlockOpen && slices.Contains(s.toolNames, d.Name) {
+			s.openToolBlock(d.Name, map[string]any{})
+		}
+		if !s.toolBlockOpen || d.Arguments == "" || d.Escaped {
+			continue
+		}
+		s.sendInputJSONDelta(d.Arguments)
+		s.toolArgsStreamed = true
+	}
+}
+
+// closeStreamedToolUse completes the tool_use block opened by argument
+// deltas with its call, checked before content_block_stop. Streamed
+// arguments reach the client as written, so they must match the schema
+// unchanged; arguments held back are checked and repaired like any call. A
+// call that fails ends the stream with an error instead of a tool_use the
+// client would run.
+func (s *claudeStreamRuntime) closeStreamedToolUse(tc util.ParsedToolCall) bool {
+	if s.toolArgsStreamed {
+		if problems := s.tools.CheckWritten(tc); len(problems) > 0 {
+			s.failToolUse(fmt.Sprintf("tool_use input for %s does not match its input_schema: %s", tc.Name, strings.Join(problems, "; ")))
+			return false
+		}
+	} else {
+		checked := s.tools.Check([]util.ParsedToolCall{tc})
+		if len(checked) == 0 {
+			s.failToolUse(fmt.Sprintf("tool_use input for %s does not match its input_schema", tc.Name))
+			return false
+		}
+		input, _ := json.Marshal(checked[0].Input)
+		s.sendInputJSONDelta(string(input))
+	}
+	s.closeToolBlock()
+	return true
+}
+
+// failToolUse ends the stream with an error in place of the open block.
+func (s *claudeStreamRuntime) failToolUse(message string) {
+	s.sendError(message)
+	s.toolBlockOpen = false
+	s.ended = true
+}
+
+// sendToolUses sends complete, checked calls as whole tool_use blocks.
+func (s *claudeStreamRuntime) sendToolUses(calls []util.ParsedToolCall) {
+	for _, tc := range calls {
+		s.openToolBlock(tc.Name, tc.Input)
+		s.closeToolBlock()
+	}
+}
+
+func (s *claudeStreamRuntime) openToolBlock(name string, input map[string]any) {
+	s.closeThinkingBlock()
+	s.closeTextBlock()
+	s.closeToolBlock()
+	s.toolBlockIndex = s.nextBlockIndex
+	s.nextBlockIndex++
+	s.send("content_block_start", map[string]any{
+		"type":  "content_block_start",
+		"index": s.toolBlockIndex,
+		"content_block": map[string]any{
+			"type":  "tool_use",
+			"id":    fmt.Sprintf("toolu_%d_%d", time.Now().Unix(), s.toolBlockIndex),
+			"name":  name,
+			"input": input,
+		},
+	})
+	s.toolBlockOpen = true
+	s.toolBlockName = name
+	s.toolArgsStreamed = false
+	s.toolUses++
+}
+
+func (s *claudeStreamRuntime) sendInputJSONDelta(partial string) {
+	s.send("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.toolBlockIndex,
+		"delta": map[string]any{
+			"type":         "input_json_delta",
+			"partial_json": partial,
+		},
+	})
+}
+
+func (s *claudeStreamRuntime) closeToolBlock() {
+	if !s.toolBlockOpen {
+		return
+	}
+	s.send("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": s.toolBlockIndex,
+	})
+	s.toolBlockOpen = false
+	s.toolBlockIndex = -1
+	s.toolBlockName = ""
+}
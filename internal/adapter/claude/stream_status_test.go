@@ -11,6 +11,7 @@ import (
 	chimw "github.com/go-chi/chi/v5/middleware"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/config"
 )
 
 type streamStatusClaudeAuthStub struct{}
@@ -53,6 +54,21 @@ func (ioNopCloser) Close() error { return nil }
 
 type streamStatusClaudeStoreStub struct{}
 
+func (streamStatusClaudeStoreStub) CompatReasoningMode(string) string   { return "" }
+func (streamStatusClaudeStoreStub) ToolcallMode() string                { return "" }
+func (streamStatusClaudeStoreStub) ToolcallEarlyEmitConfidence() string { return "" }
+func (streamStatusClaudeStoreStub) ToolcallRepairAttempts() int         { return 0 }
+
+func (streamStatusClaudeStoreStub) Models() []config.ModelConfig { return config.DefaultModels() }
+
+func (streamStatusClaudeStoreStub) RoutingRules() []config.RoutingRule { return nil }
+
+func (streamStatusClaudeStoreStub) ContextConfig() config.ContextConfig {
+	return config.ContextConfig{}
+}
+func (streamStatusClaudeStoreStub) PromptTemplates() []config.PromptTemplate { return nil }
+func (streamStatusClaudeStoreStub) ToolPrompts() []config.ToolPrompt         { return nil }
+
 func (streamStatusClaudeStoreStub) ClaudeMapping() map[string]string {
 	return map[string]string{
 		"fast": "deepseek-chat",
@@ -6,41 +6,105 @@ import (
 
 	"ds2api/internal/adapter/openai"
 	"ds2api/internal/config"
+	"ds2api/internal/upstream"
 	"ds2api/internal/util"
 )
 
-func normalizeGeminiRequest(store ConfigReader, routeModel string, req map[string]any, stream bool) (util.StandardRequest, error) {
+func normalizeGeminiRequest(store ConfigReader, routeModel string, req map[string]any, stream bool, route config.RouteInput) (util.StandardRequest, error) {
 	requestedModel := strings.TrimSpace(routeModel)
 	if requestedModel == "" {
 		return util.StandardRequest{}, fmt.Errorf("model is required in request path")
 	}
 
-	resolvedModel, ok := config.ResolveModel(store, requestedModel)
+	route.Model, route.Surface = requestedModel, config.ModelSurfaceGemini
+	decision, ok := config.ResolveRoute(store, route)
 	if !ok {
 		return util.StandardRequest{}, fmt.Errorf("Model '%s' is not available.", requestedModel)
 	}
-	thinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
+	resolvedModel, thinkingEnabled, searchEnabled := decision.Model, decision.Thinking, decision.Search
+	generationConfig, _ := req["generationConfig"].(map[string]any)
+	thinkingEnabled, searchEnabled = applyGeminiCapabilities(req["tools"], generationConfig, thinkingEnabled, searchEnabled)
+
+	reasoningMode, err := geminiReasoningMode(store, req, route)
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
 
 	messagesRaw := geminiMessagesFromRequest(req)
 	if len(messagesRaw) == 0 {
 		return util.StandardRequest{}, fmt.Errorf("Request must include non-empty contents.")
 	}
 
+	choices, err := upstream.ParseChoiceCount(generationConfig["candidateCount"], "generationConfig.candidateCount")
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
+
 	toolsRaw := convertGeminiTools(req["tools"])
-	finalPrompt, toolNames := openai.BuildPromptForAdapter(messagesRaw, toolsRaw, "")
+	toolPrompt := util.ToolInstructionsFor(store, config.ModelSurfaceGemini, resolvedModel, route.Key)
+	promptMessages, toolNames := openai.BuildPromptMessagesForAdapter(messagesRaw, toolsRaw, "", toolPrompt)
 	passThrough := collectGeminiPassThrough(req)
 
-	return util.StandardRequest{
+	stdReq := util.StandardRequest{
 		Surface:        "google_gemini",
 		RequestedModel: requestedModel,
 		ResolvedModel:  resolvedModel,
 		ResponseModel:  requestedModel,
 		Messages:       messagesRaw,
-		FinalPrompt:    finalPrompt,
 		ToolNames:      toolNames,
+		ToolSchemas:    util.CompileToolSchemas(toolsRaw),
 		Stream:         stream,
 		Thinking:       thinkingEnabled,
 		Search:         searchEnabled,
+		Choices:        choices,
+		StopSequences:  util.StopSequencesFrom(generationConfig["stopSequences"]),
 		PassThrough:    passThrough,
-	}, nil
+
+		IncludeThoughts:          thinkingEnabled && geminiIncludeThoughts(generationConfig),
+		ReasoningMode:            reasoningMode,
+		MaxOutputTokens:          util.MaxTokensFrom(generationConfig["maxOutputTokens"]),
+		MaxTokensIncludeThinking: true,
+	}
+	stdReq.AccountGroup = decision.AccountGroup
+	stdReq.Template = util.ChatTemplateFor(store, resolvedModel, route.Key)
+	stdReq.FitPrompt(promptMessages, util.NewContextPolicy(store.ContextConfig(), store, resolvedModel))
+	return stdReq, nil
+}
+
+// applyGeminiCapabilities lets the request override the model defaults: a
+// googleSearch tool turns search on and thinkingConfig.thinkingBudget toggles
+// thinking, 0 meaning off and any other budget (including -1, dynamic) on.
+func applyGeminiCapabilities(toolsRaw any, generationConfig map[string]any, thinking, search bool) (bool, bool) {
+	tools, _ := toolsRaw.([]any)
+	for _, t := range tools {
+		tool, ok := t.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"googleSearch", "google_search", "googleSearchRetrieval", "google_search_retrieval"} {
+			if _, ok := tool[key]; ok {
+				search = true
+			}
+		}
+	}
+	thinkingConfig, _ := generationConfig["thinkingConfig"].(map[string]any)
+	if budget, ok := thinkingConfig["thinkingBudget"].(float64); ok {
+		thinking = budget != 0
+	}
+	return thinking, search
+}
+
+func geminiIncludeThoughts(generationConfig map[string]any) bool {
+	thinkingConfig, _ := generationConfig["thinkingConfig"].(map[string]any)
+	return util.ToBool(thinkingConfig["includeThoughts"])
+}
+
+// geminiReasoningMode reads reasoning_mode, defaulting to the configured
+// compat default for the caller's key.
+func geminiReasoningMode(store ConfigReader, req map[string]any, route config.RouteInput) (util.ReasoningMode, error) {
+	fallback := ""
+	if store != nil {
+		fallback = store.CompatReasoningMode(route.Key)
+	}
+	return util.ReasoningModeFrom(req["reasoning_mode"], fallback)
 }
@@ -11,6 +11,7 @@ import (
 
 type AuthResolver interface {
 	Determine(req *http.Request) (*auth.RequestAuth, error)
+	Fork(ctx context.Context, a *auth.RequestAuth) (*auth.RequestAuth, error)
 	Release(a *auth.RequestAuth)
 }
 
@@ -22,6 +23,14 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ModelAliases() map[string]string
+	Models() []config.ModelConfig
+	RoutingRules() []config.RoutingRule
+	ContextConfig() config.ContextConfig
+	PromptTemplates() []config.PromptTemplate
+	ToolPrompts() []config.ToolPrompt
+	CompatReasoningMode(key string) string
+	ToolcallMode() string
+	ToolcallRepairAttempts() int
 }
 
 var _ AuthResolver = (*auth.Resolver)(nil)
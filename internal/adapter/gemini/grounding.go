@@ -0,0 +1,61 @@
+package gemini
+
+import "ds2api/internal/sse"
+
+// resolveGeminiGrounding strips citation markers from collected text and
+// returns the groundingMetadata describing them, or nil without results.
+// With search off the text is left as written.
+func resolveGeminiGrounding(text string, results []sse.SearchResult, search bool) (string, map[string]any) {
+	visible, citations := sse.ResolveSearchCitations(text, results, search)
+	return visible, buildGeminiGroundingMetadata(visible, citations)
+}
+
+// buildGeminiGroundingMetadata renders the search results of a candidate as
+// groundingChunks and every cited span as a groundingSupport pointing at them.
+// Segment indices are byte offsets, as in the Gemini API.
+func buildGeminiGroundingMetadata(text string, citations *sse.SearchCitations) map[string]any {
+	results := citations.Results()
+	if len(results) == 0 {
+		return nil
+	}
+	chunks := make([]map[string]any, 0, len(results))
+	chunkIndex := make(map[int]int, len(results))
+	for i, r := range results {
+		chunkIndex[r.Index] = i
+		chunks = append(chunks, map[string]any{
+			"web": map[string]any{"uri": r.URL, "title": r.Title},
+		})
+	}
+	supports := make([]map[string]any, 0)
+	for _, c := range citations.Citations() {
+		if c.Start >= c.End || c.End > len(text) {
+			continue
+		}
+		if n := len(supports); n > 0 {
+			last := supports[n-1]
+			segment, _ := last["segment"].(map[string]any)
+			if segment["startIndex"] == c.Start && segment["endIndex"] == c.End {
+				last["groundingChunkIndices"] = append(last["groundingChunkIndices"].([]int), chunkIndex[c.Result.Index])
+				continue
+			}
+		}
+		supports = append(supports, map[string]any{
+			"segment": map[string]any{
+				"startIndex": c.Start,
+				"endIndex":   c.End,
+				"text":       text[c.Start:c.End],
+			},
+			"groundingChunkIndices": []int{chunkIndex[c.Result.Index]},
+		})
+	}
+	return map[string]any{
+		"groundingChunks":   chunks,
+		"groundingSupports": supports,
+	}
+}
+
+func addGeminiGrounding(candidate map[string]any, grounding map[string]any) {
+	if grounding != nil {
+		candidate["groundingMetadata"] = grounding
+	}
+}
@@ -1,7 +1,9 @@
 package gemini
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -9,7 +11,9 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/respcache"
 	"ds2api/internal/sse"
+	"ds2api/internal/upstream"
 	"ds2api/internal/util"
 )
 
@@ -33,41 +37,63 @@ func (h *Handler) handleGenerateContent(w http.ResponseWriter, r *http.Request,
 	}
 
 	routeModel := strings.TrimSpace(chi.URLParam(r, "model"))
-	stdReq, err := normalizeGeminiRequest(h.Store, routeModel, req, stream)
+	stdReq, err := normalizeGeminiRequest(h.Store, routeModel, req, stream, auth.RouteInput(r))
 	if err != nil {
 		writeGeminiError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	stdReq = upstream.FitContext(r.Context(), h.DS, a, w.Header(), stdReq)
 
-	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
-	if err != nil {
-		if a.UseConfigToken {
-			writeGeminiError(w, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin.")
-		} else {
-			writeGeminiError(w, http.StatusUnauthorized, "Invalid token.")
-		}
-		return
-	}
-	pow, err := h.DS.GetPow(r.Context(), a, 3)
-	if err != nil {
-		writeGeminiError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
+	if stdReq.Choices > 1 {
+		h.handleGenerateCandidates(w, r, a, stdReq)
 		return
 	}
-	payload := stdReq.CompletionPayload(sessionID)
-	resp, err := h.DS.CallCompletion(r.Context(), a, payload, pow, 3)
+
+	completion, err := upstream.OpenShared(r.Context(), h.DS, h.sharing(r, a), a, stdReq, w.Header())
 	if err != nil {
-		writeGeminiError(w, http.StatusInternalServerError, "Failed to get completion.")
+		writeGeminiUpstreamError(w, a, err)
 		return
 	}
+	resp := completion.Resp
 
+	limits := sse.LimitsFromRequest(stdReq)
+	tools := h.toolCallReader(r.Context(), a, stdReq)
 	if stream {
-		h.handleStreamGenerateContent(w, r, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		h.handleStreamGenerateContent(w, r, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.IncludeThoughts, stdReq.Search, stdReq.ToolNames, stdReq.ReasoningMode, limits, tools)
 		return
 	}
-	h.handleNonStreamGenerateContent(w, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.ToolNames)
+	h.handleNonStreamGenerateContent(w, resp, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.IncludeThoughts, stdReq.ToolNames, stdReq.ReasoningMode, limits, tools)
+}
+
+// sharing lets the request share upstream work with identical requests. A
+// request that joins another's completion gives its account slot back.
+func (h *Handler) sharing(r *http.Request, a *auth.RequestAuth) upstream.Sharing {
+	return upstream.Sharing{
+		Cache:     h.Cache,
+		Flights:   h.Flights,
+		CallerKey: auth.RouteInput(r).Key,
+		Release:   func() { h.Auth.Release(a) },
+	}
+}
+
+func writeGeminiUpstreamError(w http.ResponseWriter, a *auth.RequestAuth, err error) {
+	switch upstream.StageOf(err) {
+	case upstream.StageAccount:
+		writeGeminiError(w, http.StatusTooManyRequests, errors.Unwrap(err).Error())
+	case upstream.StageSession:
+		if a.UseConfigToken {
+			writeGeminiError(w, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin.")
+		} else {
+			writeGeminiError(w, http.StatusUnauthorized, "Invalid token.")
+		}
+	case upstream.StagePow:
+		writeGeminiError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
+	default:
+		writeGeminiError(w, http.StatusInternalServerError, "Failed to get completion.")
+	}
 }
 
-func (h *Handler) handleNonStreamGenerateContent(w http.ResponseWriter, resp *http.Response, model, finalPrompt string, thinkingEnabled bool, toolNames []string) {
+func (h *Handler) handleNonStreamGenerateContent(w http.ResponseWriter, resp *http.Response, model, finalPrompt string, thinkingEnabled, searchEnabled, includeThoughts bool, toolNames []string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -75,27 +101,40 @@ func (h *Handler) handleNonStreamGenerateContent(w http.ResponseWriter, resp *ht
 		return
 	}
 
-	result := sse.CollectStream(resp, thinkingEnabled, true)
-	writeJSON(w, http.StatusOK, buildGeminiGenerateContentResponse(model, finalPrompt, result.Thinking, result.Text, toolNames))
+	result := sse.CollectStreamWithLimits(resp, thinkingEnabled, true, limits)
+	text, grounding := resolveGeminiGrounding(result.Text, result.SearchResults, searchEnabled)
+	out := buildGeminiGenerateContentResponse(model, finalPrompt, result.Thinking, text, toolNames, tools, includeThoughts, reasoningMode, geminiFinishReason(result.Limit))
+	addGeminiGrounding(out["candidates"].([]map[string]any)[0], grounding)
+	cachedGeminiUsage(respcache.IsHit(w.Header()), out["usageMetadata"].(map[string]any))
+	writeJSON(w, http.StatusOK, out)
 }
 
-func buildGeminiGenerateContentResponse(model, finalPrompt, finalThinking, finalText string, toolNames []string) map[string]any {
-	parts := buildGeminiPartsFromFinal(finalText, finalThinking, toolNames)
-	usage := buildGeminiUsage(finalPrompt, finalThinking, finalText)
+func buildGeminiGenerateContentResponse(model, finalPrompt, finalThinking, finalText string, toolNames []string, tools util.ToolCallReader, includeThoughts bool, reasoningMode util.ReasoningMode, finishReason string) map[string]any {
 	return map[string]any{
-		"candidates": []map[string]any{
-			{
-				"index": 0,
-				"content": map[string]any{
-					"role":  "model",
-					"parts": parts,
-				},
-				"finishReason": "STOP",
-			},
-		},
+		"candidates":    []map[string]any{buildGeminiCandidate(0, finalThinking, finalText, toolNames, tools, includeThoughts, reasoningMode, finishReason)},
 		"modelVersion":  model,
-		"usageMetadata": usage,
+		"usageMetadata": buildGeminiUsage(finalPrompt, finalThinking, finalText),
+	}
+}
+
+func buildGeminiCandidate(index int, finalThinking, finalText string, toolNames []string, tools util.ToolCallReader, includeThoughts bool, reasoningMode util.ReasoningMode, finishReason string) map[string]any {
+	return map[string]any{
+		"index": index,
+		"content": map[string]any{
+			"role":  "model",
+			"parts": buildGeminiPartsFromFinal(finalText, finalThinking, toolNames, tools, includeThoughts, reasoningMode),
+		},
+		"finishReason": finishReason,
+	}
+}
+
+// geminiFinishReason maps a local output limit to the Gemini finishReason;
+// stop sequences end with a normal STOP.
+func geminiFinishReason(reason sse.LimitReason) string {
+	if reason == sse.LimitMaxTokens {
+		return "MAX_TOKENS"
 	}
+	return "STOP"
 }
 
 func buildGeminiUsage(finalPrompt, finalThinking, finalText string) map[string]any {
@@ -109,27 +148,88 @@ func buildGeminiUsage(finalPrompt, finalThinking, finalText string) map[string]a
 	}
 }
 
-func buildGeminiPartsFromFinal(finalText, finalThinking string, toolNames []string) []map[string]any {
-	detected := util.ParseToolCalls(finalText, toolNames)
+// cachedGeminiUsage reports the prompt of usage as cached content when the
+// response was replayed from the response cache.
+func cachedGeminiUsage(hit bool, usage map[string]any) map[string]any {
+	if hit {
+		usage["cachedContentTokenCount"] = usage["promptTokenCount"]
+	}
+	return usage
+}
+
+// buildGeminiPartsFromFinal renders the final candidate parts. With
+// includeThoughts the reasoning leads as a thought part instead of standing in
+// for an empty answer. reasoningMode decides which reasoning is shown, and
+// inline reasoning leads the answer text.
+func buildGeminiPartsFromFinal(finalText, finalThinking string, toolNames []string, tools util.ToolCallReader, includeThoughts bool, reasoningMode util.ReasoningMode) []map[string]any {
+	detected := tools.Parse(finalText, toolNames)
 	if len(detected) == 0 && strings.TrimSpace(finalThinking) != "" {
-		detected = util.ParseToolCalls(finalThinking, toolNames)
+		detected = tools.Parse(finalThinking, toolNames)
+	}
+	reasoning, shownText := util.PresentReasoning(reasoningMode, finalThinking, finalText)
+	parts := make([]map[string]any, 0, len(detected)+2)
+	if includeThoughts && reasoning != "" {
+		parts = append(parts, map[string]any{"text": reasoning, "thought": true})
 	}
 	if len(detected) > 0 {
-		parts := make([]map[string]any, 0, len(detected))
-		for _, tc := range detected {
-			parts = append(parts, map[string]any{
-				"functionCall": map[string]any{
-					"name": tc.Name,
-					"args": tc.Input,
-				},
-			})
+		if prefix := strings.TrimSuffix(shownText, finalText); prefix != "" {
+			parts = append(parts, map[string]any{"text": prefix})
 		}
-		return parts
+		return append(parts, functionCallParts(detected)...)
 	}
 
-	text := finalText
+	if len(parts) > 0 && strings.TrimSpace(shownText) == "" {
+		return parts
+	}
+	text := shownText
 	if strings.TrimSpace(text) == "" {
-		text = finalThinking
+		text = reasoning
+	}
+	return append(parts, map[string]any{"text": text})
+}
+
+// functionCallParts renders tool calls as functionCall parts.
+func functionCallParts(calls []util.ParsedToolCall) []map[string]any {
+	parts := make([]map[string]any, 0, len(calls))
+	for _, tc := range calls {
+		parts = append(parts, map[string]any{
+			"functionCall": map[string]any{
+				"name": tc.Name,
+				"args": tc.Input,
+			},
+		})
+	}
+	return parts
+}
+
+// toolcallFeatureMatchEnabled reports whether toolcall.mode lets the stream
+// sieve tool calls out of the text as it arrives.
+func (h *Handler) toolcallFeatureMatchEnabled() bool {
+	if h == nil || h.Store == nil {
+		return true
+	}
+	mode := strings.TrimSpace(strings.ToLower(h.Store.ToolcallMode()))
+	if mode == "off" {
+		return false
+	}
+	_, err := util.ParseToolcallMode(mode)
+	return err == nil
+}
+
+// toolcallDialects returns the tool-call dialects toolcall.mode enables.
+func (h *Handler) toolcallDialects() util.ToolDialects {
+	if h == nil || h.Store == nil {
+		return util.ToolDialectJSON
+	}
+	return util.ToolDialectsForMode(h.Store.ToolcallMode())
+}
+
+// toolCallReader returns the reader for stdReq's tool calls. Invalid calls
+// are repaired on a's account, toolcall.repair_attempts times at most.
+func (h *Handler) toolCallReader(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest) util.ToolCallReader {
+	attempts := 0
+	if h != nil && h.Store != nil {
+		attempts = h.Store.ToolcallRepairAttempts()
 	}
-	return []map[string]any{{"text": text}}
+	return upstream.ToolCallReader(ctx, h.DS, a, stdReq, h.toolcallDialects(), attempts)
 }
@@ -0,0 +1,129 @@
+package gemini
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/sse"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
+)
+
+// handleGenerateCandidates serves generationConfig.candidateCount > 1 with one
+// upstream completion per candidate. If every branch fails the request fails
+// with the first branch's error; otherwise a failed branch is reported in
+// place with finishReason "OTHER" and the remaining candidates are returned.
+func (h *Handler) handleGenerateCandidates(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, stdReq util.StandardRequest) {
+	branches := upstream.OpenN(r.Context(), h.DS, h.Auth, a, stdReq, stdReq.Choices)
+	defer upstream.ReleaseForked(h.Auth, branches)
+	defer upstream.CloseBranches(branches)
+
+	if err := upstream.FirstError(branches); err != nil {
+		writeGeminiUpstreamError(w, a, err)
+		return
+	}
+	tools := h.toolCallReader(r.Context(), a, stdReq)
+	if stdReq.Stream {
+		h.streamGenerateCandidates(w, r, branches, stdReq, tools)
+		return
+	}
+
+	candidates := make([]map[string]any, len(branches))
+	outputs := make([]candidateOutput, len(branches))
+	var wg sync.WaitGroup
+	for i := range branches {
+		if message := branches[i].Failure(); message != "" {
+			candidates[i] = buildGeminiFailedCandidate(i, message)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, resp *http.Response) {
+			defer wg.Done()
+			result := sse.CollectStreamWithLimits(resp, stdReq.Thinking, false, sse.LimitsFromRequest(stdReq))
+			text, grounding := resolveGeminiGrounding(result.Text, result.SearchResults, stdReq.Search)
+			outputs[i] = candidateOutput{thinking: result.Thinking, text: text}
+			candidates[i] = buildGeminiCandidate(i, result.Thinking, text, stdReq.ToolNames, tools, stdReq.IncludeThoughts, stdReq.ReasoningMode, geminiFinishReason(result.Limit))
+			addGeminiGrounding(candidates[i], grounding)
+		}(i, branches[i].Completion.Resp)
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"candidates":    candidates,
+		"modelVersion":  stdReq.ResponseModel,
+		"usageMetadata": buildGeminiUsageForCandidates(stdReq.FinalPrompt, outputs),
+	})
+}
+
+func (h *Handler) streamGenerateCandidates(w http.ResponseWriter, r *http.Request, branches []upstream.Branch, stdReq util.StandardRequest, tools util.ToolCallReader) {
+	setGeminiStreamHeaders(w)
+	lw := &upstream.LockedWriter{ResponseWriter: w}
+	rc := http.NewResponseController(lw)
+	_, canFlush := w.(http.Flusher)
+
+	runtimes := make([]*geminiStreamRuntime, len(branches))
+	var wg sync.WaitGroup
+	for i, b := range branches {
+		rt := newGeminiStreamRuntime(lw, rc, canFlush, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		rt.limiter = sse.NewOutputLimiter(sse.LimitsFromRequest(stdReq))
+		rt.includeThoughts = stdReq.IncludeThoughts
+		rt.reasoning = util.NewReasoningPresenter(stdReq.ReasoningMode)
+		rt.setToolCallReader(tools, h.toolcallFeatureMatchEnabled())
+		rt.candidateIndex = i
+		rt.multiCandidate = true
+		runtimes[i] = rt
+		if message := b.Failure(); message != "" {
+			rt.sendChunk(map[string]any{
+				"candidates":   []map[string]any{buildGeminiFailedCandidate(i, message)},
+				"modelVersion": stdReq.ResponseModel,
+			})
+			continue
+		}
+		wg.Add(1)
+		go func(rt *geminiStreamRuntime, body io.Reader) {
+			defer wg.Done()
+			consumeGeminiStream(r, body, stdReq.Thinking, rt)
+		}(rt, b.Completion.Resp.Body)
+	}
+	wg.Wait()
+
+	outputs := make([]candidateOutput, 0, len(runtimes))
+	for _, rt := range runtimes {
+		outputs = append(outputs, candidateOutput{thinking: rt.thinking.String(), text: rt.text.String()})
+	}
+	runtimes[0].sendChunk(map[string]any{
+		"modelVersion":  stdReq.ResponseModel,
+		"usageMetadata": buildGeminiUsageForCandidates(stdReq.FinalPrompt, outputs),
+	})
+}
+
+type candidateOutput struct {
+	thinking string
+	text     string
+}
+
+func buildGeminiUsageForCandidates(finalPrompt string, outputs []candidateOutput) map[string]any {
+	promptTokens := util.EstimateTokens(finalPrompt)
+	candidateTokens := 0
+	for _, out := range outputs {
+		candidateTokens += util.EstimateTokens(out.thinking) + util.EstimateTokens(out.text)
+	}
+	return map[string]any{
+		"promptTokenCount":     promptTokens,
+		"candidatesTokenCount": candidateTokens,
+		"totalTokenCount":      promptTokens + candidateTokens,
+	}
+}
+
+func buildGeminiFailedCandidate(index int, message string) map[string]any {
+	return map[string]any{
+		"index": index,
+		"content": map[string]any{
+			"role":  "model",
+			"parts": []map[string]any{{"text": ""}},
+		},
+		"finishReason":  "OTHER",
+		"finishMessage": message,
+	}
+}
@@ -5,6 +5,9 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"ds2api/internal/coalesce"
+	"ds2api/internal/config"
+	"ds2api/internal/respcache"
 	"ds2api/internal/util"
 )
 
@@ -14,15 +17,24 @@ type Handler struct {
 	Store ConfigReader
 	Auth  AuthResolver
 	DS    DeepSeekCaller
+	// Cache answers repeated deterministic requests and Flights lets
+	// concurrent identical ones share a completion; nil disables either.
+	Cache   *respcache.Cache
+	Flights *coalesce.Group
 }
 
 func RegisterRoutes(r chi.Router, h *Handler) {
+	r.Get("/v1beta/models", h.ListModels)
 	r.Post("/v1beta/models/{model}:generateContent", h.GenerateContent)
 	r.Post("/v1beta/models/{model}:streamGenerateContent", h.StreamGenerateContent)
 	r.Post("/v1/models/{model}:generateContent", h.GenerateContent)
 	r.Post("/v1/models/{model}:streamGenerateContent", h.StreamGenerateContent)
 }
 
+func (h *Handler) ListModels(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, config.GeminiModelsResponse(h.Store))
+}
+
 func (h *Handler) GenerateContent(w http.ResponseWriter, r *http.Request) {
 	h.handleGenerateContent(w, r, false)
 }
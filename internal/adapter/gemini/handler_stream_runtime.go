@@ -8,11 +8,14 @@ import (
 	"time"
 
 	"ds2api/internal/deepseek"
+	"ds2api/internal/respcache"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/toolsieve"
+	"ds2api/internal/util"
 )
 
-func (h *Handler) handleStreamGenerateContent(w http.ResponseWriter, r *http.Request, resp *http.Response, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string) {
+func (h *Handler) handleStreamGenerateContent(w http.ResponseWriter, r *http.Request, resp *http.Response, model, finalPrompt string, thinkingEnabled, includeThoughts, searchEnabled bool, toolNames []string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -20,22 +23,33 @@ func (h *Handler) handleStreamGenerateContent(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	setGeminiStreamHeaders(w)
+	rc := http.NewResponseController(w)
+	_, canFlush := w.(http.Flusher)
+	runtime := newGeminiStreamRuntime(w, rc, canFlush, model, finalPrompt, thinkingEnabled, searchEnabled, toolNames)
+	runtime.cacheHit = respcache.IsHit(w.Header())
+	runtime.limiter = sse.NewOutputLimiter(limits)
+	runtime.includeThoughts = includeThoughts
+	runtime.reasoning = util.NewReasoningPresenter(reasoningMode)
+	runtime.setToolCallReader(tools, h.toolcallFeatureMatchEnabled())
+	consumeGeminiStream(r, resp.Body, thinkingEnabled, runtime)
+}
+
+func setGeminiStreamHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache, no-transform")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
+}
 
-	rc := http.NewResponseController(w)
-	_, canFlush := w.(http.Flusher)
-	runtime := newGeminiStreamRuntime(w, rc, canFlush, model, finalPrompt, thinkingEnabled, searchEnabled, toolNames)
-
+func consumeGeminiStream(r *http.Request, body io.Reader, thinkingEnabled bool, runtime *geminiStreamRuntime) {
 	initialType := "text"
 	if thinkingEnabled {
 		initialType = "thinking"
 	}
 	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
 		Context:             r.Context(),
-		Body:                resp.Body,
+		Body:                body,
 		ThinkingEnabled:     thinkingEnabled,
 		InitialType:         initialType,
 		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
@@ -58,12 +72,30 @@ type geminiStreamRuntime struct {
 	finalPrompt string
 
 	thinkingEnabled bool
+	includeThoughts bool
 	searchEnabled   bool
-	bufferContent   bool
-	toolNames       []string
+	// bufferContent holds all text back until the output ends, when tool
+	// calls are read from it. sieveToolCalls instead streams text and sends
+	// each functionCall part as soon as the sieve finds the call.
+	bufferContent  bool
+	sieveToolCalls bool
+	toolSieve      toolsieve.State
+	functionCalls  int
+	toolNames      []string
+	tools          util.ToolCallReader
+
+	// candidateIndex and multiCandidate are set when the runtime renders one
+	// branch of a candidateCount > 1 request; the caller then reports usage.
+	candidateIndex int
+	multiCandidate bool
 
-	thinking strings.Builder
-	text     strings.Builder
+	// cacheHit reports the prompt as cached content in usage.
+	cacheHit  bool
+	limiter   *sse.OutputLimiter
+	citations *sse.SearchCitations
+	reasoning *util.ReasoningPresenter
+	thinking  strings.Builder
+	text      strings.Builder
 }
 
 func newGeminiStreamRuntime(
@@ -76,6 +108,10 @@ func newGeminiStreamRuntime(
 	searchEnabled bool,
 	toolNames []string,
 ) *geminiStreamRuntime {
+	var citations *sse.SearchCitations
+	if searchEnabled {
+		citations = sse.NewSearchCitations()
+	}
 	return &geminiStreamRuntime{
 		w:               w,
 		rc:              rc,
@@ -86,14 +122,17 @@ func newGeminiStreamRuntime(
 		searchEnabled:   searchEnabled,
 		bufferContent:   len(toolNames) > 0,
 		toolNames:       toolNames,
+		citations:       citations,
 	}
 }
 
 func (s *geminiStreamRuntime) sendChunk(payload map[string]any) {
 	b, _ := json.Marshal(payload)
-	_, _ = s.w.Write([]byte("data: "))
-	_, _ = s.w.Write(b)
-	_, _ = s.w.Write([]byte("\n\n"))
+	frame := make([]byte, 0, len(b)+8)
+	frame = append(frame, "data: "...)
+	frame = append(frame, b...)
+	frame = append(frame, "\n\n"...)
+	_, _ = s.w.Write(frame)
 	if s.canFlush {
 		_ = s.rc.Flush()
 	}
@@ -107,75 +146,135 @@ func (s *geminiStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Parse
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
+	s.citations.AddResults(parsed.SearchResults)
+	contentSeen := s.emitParts(s.limiter.Apply(parsed.Parts))
+	if s.limiter.Reached() {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonOutputLimit, ContentSeen: contentSeen}
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *geminiStreamRuntime) emitParts(parts []sse.ContentPart) bool {
 	contentSeen := false
-	for _, p := range parsed.Parts {
-		if p.Text == "" {
-			continue
+	for _, p := range parts {
+		if p.Type != "thinking" {
+			p.Text, _ = s.citations.Strip(p.Text)
 		}
-		if p.Type != "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
+		if p.Text == "" {
 			continue
 		}
 		contentSeen = true
 		if p.Type == "thinking" {
 			if s.thinkingEnabled {
 				s.thinking.WriteString(p.Text)
+				s.presentThinking(s.reasoning.Thinking(p.Text))
 			}
 			continue
 		}
+		s.presentThinking(s.reasoning.EndThinking())
 		s.text.WriteString(p.Text)
 		if s.bufferContent {
 			continue
 		}
-		s.sendChunk(map[string]any{
-			"candidates": []map[string]any{
-				{
-					"index": 0,
-					"content": map[string]any{
-						"role":  "model",
-						"parts": []map[string]any{{"text": p.Text}},
-					},
-				},
-			},
-			"modelVersion": s.model,
-		})
+		if s.sieveToolCalls {
+			s.emitToolEvents(s.toolSieve.Process(p.Text, s.toolNames))
+			continue
+		}
+		s.sendPart(map[string]any{"text": p.Text})
 	}
-	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+	return contentSeen
 }
 
-func (s *geminiStreamRuntime) finalize() {
-	finalThinking := s.thinking.String()
-	finalText := s.text.String()
-
-	if s.bufferContent {
-		parts := buildGeminiPartsFromFinal(finalText, finalThinking, s.toolNames)
-		s.sendChunk(map[string]any{
-			"candidates": []map[string]any{
-				{
-					"index": 0,
-					"content": map[string]any{
-						"role":  "model",
-						"parts": parts,
-					},
-				},
-			},
-			"modelVersion": s.model,
-		})
+// presentThinking streams thinking as the reasoning mode shows it: as thought
+// parts when the client asked for them, or inline as text.
+func (s *geminiStreamRuntime) presentThinking(reasoning, inline string) {
+	if reasoning != "" && s.includeThoughts {
+		s.sendPart(map[string]any{"text": reasoning, "thought": true})
 	}
+	if inline != "" {
+		s.sendPart(map[string]any{"text": inline})
+	}
+}
 
+func (s *geminiStreamRuntime) sendPart(part map[string]any) {
+	s.sendParts([]map[string]any{part})
+}
+
+func (s *geminiStreamRuntime) sendParts(parts []map[string]any) {
 	s.sendChunk(map[string]any{
 		"candidates": []map[string]any{
 			{
-				"index": 0,
+				"index": s.candidateIndex,
 				"content": map[string]any{
-					"role": "model",
-					"parts": []map[string]any{
-						{"text": ""},
-					},
+					"role":  "model",
+					"parts": parts,
 				},
-				"finishReason": "STOP",
 			},
 		},
-		"modelVersion":  s.model,
-		"usageMetadata": buildGeminiUsage(s.finalPrompt, finalThinking, finalText),
+		"modelVersion": s.model,
 	})
 }
+
+func (s *geminiStreamRuntime) finalize() {
+	s.emitParts(s.limiter.Flush())
+	s.emitParts(s.citations.Flush())
+	if s.sieveToolCalls {
+		s.emitToolEvents(s.toolSieve.Flush(s.toolNames))
+	}
+	s.presentThinking(s.reasoning.EndThinking())
+	finalThinking := s.thinking.String()
+	finalText := s.text.String()
+
+	var parts []map[string]any
+	switch {
+	case s.bufferContent:
+		parts = buildGeminiPartsFromFinal(finalText, finalThinking, s.toolNames, s.tools, s.includeThoughts, s.reasoning.Mode())
+		parts = dropStreamedReasoning(parts, s.reasoning.Inline())
+	case s.sieveToolCalls && s.functionCalls == 0 && strings.TrimSpace(finalText) == "" && strings.TrimSpace(finalThinking) != "":
+		parts = functionCallParts(s.tools.Parse(finalThinking, s.toolNames))
+	}
+	if len(parts) > 0 {
+		s.sendParts(parts)
+	}
+
+	candidate := map[string]any{
+		"index": s.candidateIndex,
+		"content": map[string]any{
+			"role": "model",
+			"parts": []map[string]any{
+				{"text": ""},
+			},
+		},
+		"finishReason": geminiFinishReason(s.limiter.Reason()),
+	}
+	addGeminiGrounding(candidate, buildGeminiGroundingMetadata(finalText, s.citations))
+	final := map[string]any{
+		"candidates":   []map[string]any{candidate},
+		"modelVersion": s.model,
+	}
+	if !s.multiCandidate {
+		final["usageMetadata"] = cachedGeminiUsage(s.cacheHit, buildGeminiUsage(s.finalPrompt, finalThinking, finalText))
+	}
+	s.sendChunk(final)
+}
+
+// dropStreamedReasoning removes from buffered final parts the reasoning that
+// was already streamed: thought parts and the inline <think> prefix.
+func dropStreamedReasoning(parts []map[string]any, inline string) []map[string]any {
+	out := make([]map[string]any, 0, len(parts))
+	for _, part := range parts {
+		if part["thought"] == true {
+			continue
+		}
+		if text, ok := part["text"].(string); ok && inline != "" {
+			text = strings.TrimPrefix(text, inline)
+			inline = ""
+			if text == "" {
+				continue
+			}
+			part = map[string]any{"text": text}
+		}
+		out = append(out, part)
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package gemini
+
+import (
+	"ds2api/internal/toolsieve"
+	"ds2api/internal/util"
+)
+
+// setToolCallReader selects how tool calls are read from the output. With
+// sieve set, text streams while the sieve watches for calls; otherwise it is
+// held back and read once the output ends.
+func (s *geminiStreamRuntime) setToolCallReader(tools util.ToolCallReader, sieve bool) {
+	s.tools = tools
+	s.toolSieve.Dialects = tools.Dialects
+	if sieve && s.bufferContent {
+		s.bufferContent = false
+		s.sieveToolCalls = true
+	}
+}
+
+// emitToolEvents sends sieved output: text as text parts and each complete
+// call as a functionCall part. Gemini has no partial arguments, so argument
+// deltas are not sent.
+func (s *geminiStreamRuntime) emitToolEvents(events []toolsieve.Event) {
+	for _, evt := range events {
+		if calls := s.tools.Check(evt.ToolCalls); len(calls) > 0 {
+			s.sendParts(functionCallParts(calls))
+			s.functionCalls += len(calls)
+			continue
+		}
+		if evt.Content != "" {
+			s.sendPart(map[string]any{"text": evt.Content})
+		}
+	}
+}
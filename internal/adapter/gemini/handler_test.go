@@ -8,16 +8,26 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/config"
 )
 
 type testGeminiConfig struct{}
 
-func (testGeminiConfig) ModelAliases() map[string]string { return nil }
+func (testGeminiConfig) ModelAliases() map[string]string          { return nil }
+func (testGeminiConfig) CompatReasoningMode(string) string        { return "" }
+func (testGeminiConfig) ToolcallMode() string                     { return "" }
+func (testGeminiConfig) ToolcallRepairAttempts() int              { return 0 }
+func (testGeminiConfig) Models() []config.ModelConfig             { return config.DefaultModels() }
+func (testGeminiConfig) RoutingRules() []config.RoutingRule       { return nil }
+func (testGeminiConfig) ContextConfig() config.ContextConfig      { return config.ContextConfig{} }
+func (testGeminiConfig) PromptTemplates() []config.PromptTemplate { return nil }
+func (testGeminiConfig) ToolPrompts() []config.ToolPrompt         { return nil }
 
 type testGeminiAuth struct {
 	a   *auth.RequestAuth
@@ -39,6 +49,11 @@ func (m testGeminiAuth) Determine(_ *http.Request) (*auth.RequestAuth, error) {
 	}, nil
 }
 
+func (testGeminiAuth) Fork(_ context.Context, a *auth.RequestAuth) (*auth.RequestAuth, error) {
+	forked := *a
+	return &forked, nil
+}
+
 func (testGeminiAuth) Release(_ *auth.RequestAuth) {}
 
 type testGeminiDS struct {
@@ -193,6 +208,48 @@ func TestStreamGenerateContentEmitsSSE(t *testing.T) {
 	}
 }
 
+func TestStreamGenerateContentSendsFunctionCallAsSoonAsItCloses(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/content","v":"Checking.\n"}`,
+		`data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"eval_javascript\","}`,
+		`data: {"p":"response/content","v":"\"input\":{\"code\":\"1+1\"}}]}"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: testGeminiDS{resp: upstream}}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{
+		"contents":[{"role":"user","parts":[{"text":"call tool"}]}],
+		"tools":[{"functionDeclarations":[{"name":"eval_javascript","parameters":{"type":"object","properties":{"code":{"type":"string"}}}}]}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var kinds []string
+	for _, frame := range extractGeminiSSEFrames(t, rec.Body.String()) {
+		c0, _ := frame["candidates"].([]any)[0].(map[string]any)
+		if c0["finishReason"] != nil {
+			kinds = append(kinds, "finish")
+			continue
+		}
+		parts, _ := c0["content"].(map[string]any)["parts"].([]any)
+		for _, item := range parts {
+			part, _ := item.(map[string]any)
+			if fc, ok := part["functionCall"].(map[string]any); ok {
+				kinds = append(kinds, "call:"+fc["name"].(string))
+				continue
+			}
+			kinds = append(kinds, "text:"+strings.TrimSpace(part["text"].(string)))
+		}
+	}
+	if strings.Join(kinds, ",") != "text:Checking.,call:eval_javascript,finish" {
+		t.Fatalf("expected prose, then the call, then the finish frame, got %v body=%s", kinds, rec.Body.String())
+	}
+}
+
 func extractGeminiSSEFrames(t *testing.T, body string) []map[string]any {
 	t.Helper()
 	scanner := bufio.NewScanner(strings.NewReader(body))
@@ -214,3 +271,399 @@ func extractGeminiSSEFrames(t *testing.T, body string) []map[string]any {
 	}
 	return out
 }
+
+type candidatesDSStub struct {
+	calls *int32
+}
+
+func (m candidatesDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m candidatesDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m candidatesDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	atomic.AddInt32(m.calls, 1)
+	return makeGeminiUpstreamResponse(`data: {"p":"response/content","v":"candidate"}`, `data: [DONE]`), nil
+}
+
+func TestGenerateContentCandidateCountFansOut(t *testing.T) {
+	var calls int32
+	h := &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: candidatesDSStub{calls: &calls}}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"candidateCount":2}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 upstream completions, got %d", calls)
+	}
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	candidates, _ := out["candidates"].([]any)
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %#v", out)
+	}
+	for i, c := range candidates {
+		if idx := c.(map[string]any)["index"].(float64); int(idx) != i {
+			t.Fatalf("expected candidate index %d, got %v", i, idx)
+		}
+	}
+}
+
+func TestStreamGenerateContentCandidateCountUsesIndexes(t *testing.T) {
+	var calls int32
+	h := &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: candidatesDSStub{calls: &calls}}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"candidateCount":2}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	finished := map[int]bool{}
+	frames := extractGeminiSSEFrames(t, rec.Body.String())
+	for _, frame := range frames {
+		candidates, _ := frame["candidates"].([]any)
+		for _, c := range candidates {
+			cand := c.(map[string]any)
+			if cand["finishReason"] == "STOP" {
+				finished[int(cand["index"].(float64))] = true
+			}
+		}
+	}
+	if !finished[0] || !finished[1] {
+		t.Fatalf("expected both candidates to finish, body=%s", rec.Body.String())
+	}
+	if _, ok := frames[len(frames)-1]["usageMetadata"]; !ok {
+		t.Fatalf("expected trailing usage frame, got %#v", frames[len(frames)-1])
+	}
+}
+
+func TestStreamGenerateContentHonoursStopSequences(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/content","v":"first line\nSTO"}`,
+		`data: {"p":"response/content","v":"P second line"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{
+		Store: testGeminiConfig{},
+		Auth:  testGeminiAuth{},
+		DS:    testGeminiDS{resp: upstream},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"stopSequences":["STOP"]}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	text := strings.Builder{}
+	for _, frame := range extractGeminiSSEFrames(t, rec.Body.String()) {
+		candidates, _ := frame["candidates"].([]any)
+		for _, item := range candidates {
+			c, _ := item.(map[string]any)
+			content, _ := c["content"].(map[string]any)
+			parts, _ := content["parts"].([]any)
+			for _, p := range parts {
+				part, _ := p.(map[string]any)
+				s, _ := part["text"].(string)
+				text.WriteString(s)
+			}
+		}
+	}
+	if text.String() != "first line\n" {
+		t.Fatalf("expected text cut before stop sequence, got %q body=%s", text.String(), rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"finishReason":"STOP"`) {
+		t.Fatalf("expected STOP finish reason, body=%s", rec.Body.String())
+	}
+}
+
+func TestGenerateContentMaxOutputTokensFinishesWithMaxTokens(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/content","v":"abcdefghijklmnop"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{
+		Store: testGeminiConfig{},
+		Auth:  testGeminiAuth{},
+		DS:    testGeminiDS{resp: upstream},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"maxOutputTokens":2}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	candidates, _ := out["candidates"].([]any)
+	c0, _ := candidates[0].(map[string]any)
+	if c0["finishReason"] != "MAX_TOKENS" {
+		t.Fatalf("expected MAX_TOKENS, got %#v", c0)
+	}
+	usage, _ := out["usageMetadata"].(map[string]any)
+	if usage["candidatesTokenCount"] != float64(2) {
+		t.Fatalf("expected output within maxOutputTokens, got %#v", usage)
+	}
+}
+
+func TestGenerateContentRendersGroundingMetadata(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/search_results","v":[{"url":"https://a.example","title":"A","cite_index":1}]}`,
+		`data: {"p":"response/content","v":"sky is blue"}`,
+		`data: {"p":"response/content","v":"[citation:1]"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{
+		Store: testGeminiConfig{},
+		Auth:  testGeminiAuth{},
+		DS:    testGeminiDS{resp: upstream},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-chat-search:generateContent", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	candidates, _ := out["candidates"].([]any)
+	c0, _ := candidates[0].(map[string]any)
+	content, _ := c0["content"].(map[string]any)
+	parts, _ := content["parts"].([]any)
+	part0, _ := parts[0].(map[string]any)
+	if part0["text"] != "sky is blue" {
+		t.Fatalf("expected citation markers stripped, got %#v", part0)
+	}
+	grounding, _ := c0["groundingMetadata"].(map[string]any)
+	chunks, _ := grounding["groundingChunks"].([]any)
+	supports, _ := grounding["groundingSupports"].([]any)
+	if len(chunks) != 1 || len(supports) != 1 {
+		t.Fatalf("unexpected groundingMetadata %#v", grounding)
+	}
+	web, _ := chunks[0].(map[string]any)["web"].(map[string]any)
+	if web["uri"] != "https://a.example" {
+		t.Fatalf("unexpected grounding chunk %#v", chunks[0])
+	}
+	segment, _ := supports[0].(map[string]any)["segment"].(map[string]any)
+	if segment["text"] != "sky is blue" || segment["endIndex"] != float64(11) {
+		t.Fatalf("unexpected grounding support %#v", supports[0])
+	}
+}
+
+func TestStreamGenerateContentAddsGroundingToFinalChunk(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/search_results","v":[{"url":"https://a.example","title":"A","cite_index":1}]}`,
+		`data: {"p":"response/content","v":"sky is blue"}`,
+		`data: {"p":"response/content","v":"[citation:1]"}`,
+		`data: [DONE]`,
+	)
+	h := &Handler{
+		Store: testGeminiConfig{},
+		Auth:  testGeminiAuth{},
+		DS:    testGeminiDS{resp: upstream},
+	}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-chat-search:streamGenerateContent?alt=sse", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if strings.Contains(rec.Body.String(), "[citation:") {
+		t.Fatalf("expected citation markers stripped, body=%s", rec.Body.String())
+	}
+	frames := extractGeminiSSEFrames(t, rec.Body.String())
+	last := frames[len(frames)-1]
+	candidates, _ := last["candidates"].([]any)
+	c0, _ := candidates[0].(map[string]any)
+	grounding, _ := c0["groundingMetadata"].(map[string]any)
+	supports, _ := grounding["groundingSupports"].([]any)
+	if len(supports) != 1 {
+		t.Fatalf("expected grounding on final chunk, got %#v", last)
+	}
+}
+
+func TestNormalizeGeminiRequestCapabilityToggles(t *testing.T) {
+	req := map[string]any{
+		"contents": []any{map[string]any{"role": "user", "parts": []any{map[string]any{"text": "hello"}}}},
+		"tools":    []any{map[string]any{"googleSearch": map[string]any{}}},
+		"generationConfig": map[string]any{
+			"thinkingConfig": map[string]any{"thinkingBudget": float64(0)},
+		},
+	}
+	stdReq, err := normalizeGeminiRequest(testGeminiConfig{}, "deepseek-reasoner", req, false, config.RouteInput{})
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !stdReq.Search || stdReq.Thinking {
+		t.Fatalf("expected search on and thinking off, got search=%v thinking=%v", stdReq.Search, stdReq.Thinking)
+	}
+	if len(stdReq.ToolNames) != 0 {
+		t.Fatalf("googleSearch must not become a function tool: %#v", stdReq.ToolNames)
+	}
+}
+
+func TestGenerateContentIncludeThoughtsEmitsThoughtParts(t *testing.T) {
+	newRouter := func() http.Handler {
+		upstream := makeGeminiUpstreamResponse(
+			`data: {"p":"response/thinking_content","v":"pondering"}`,
+			`data: {"p":"response/content","v":"answer"}`,
+			`data: [DONE]`,
+		)
+		r := chi.NewRouter()
+		RegisterRoutes(r, &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: testGeminiDS{resp: upstream}})
+		return r
+	}
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"thinkingConfig":{"includeThoughts":true}}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-reasoner:generateContent", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	candidates, _ := out["candidates"].([]any)
+	content, _ := candidates[0].(map[string]any)["content"].(map[string]any)
+	parts, _ := content["parts"].([]any)
+	if len(parts) != 2 {
+		t.Fatalf("expected thought and text parts, got %#v", parts)
+	}
+	thought, _ := parts[0].(map[string]any)
+	answer, _ := parts[1].(map[string]any)
+	if thought["thought"] != true || thought["text"] != "pondering" || answer["text"] != "answer" || answer["thought"] != nil {
+		t.Fatalf("unexpected parts %#v", parts)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-reasoner:streamGenerateContent?alt=sse", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	frames := extractGeminiSSEFrames(t, rec.Body.String())
+	candidates, _ = frames[0]["candidates"].([]any)
+	content, _ = candidates[0].(map[string]any)["content"].(map[string]any)
+	parts, _ = content["parts"].([]any)
+	first, _ := parts[0].(map[string]any)
+	if first["thought"] != true || first["text"] != "pondering" {
+		t.Fatalf("expected streamed thought part first, body=%s", rec.Body.String())
+	}
+}
+
+func TestGenerateContentOmitsThoughtsByDefault(t *testing.T) {
+	upstream := makeGeminiUpstreamResponse(
+		`data: {"p":"response/thinking_content","v":"pondering"}`,
+		`data: {"p":"response/content","v":"answer"}`,
+		`data: [DONE]`,
+	)
+	r := chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: testGeminiDS{resp: upstream}})
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-reasoner:streamGenerateContent?alt=sse", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), `"thought"`) || strings.Contains(rec.Body.String(), "pondering") {
+		t.Fatalf("expected no thought parts without includeThoughts, body=%s", rec.Body.String())
+	}
+}
+
+func TestGenerateContentReasoningModeInlineThinkTags(t *testing.T) {
+	newRouter := func() http.Handler {
+		upstream := makeGeminiUpstreamResponse(
+			`data: {"p":"response/thinking_content","v":"pondering"}`,
+			`data: {"p":"response/content","v":"answer"}`,
+			`data: [DONE]`,
+		)
+		r := chi.NewRouter()
+		RegisterRoutes(r, &Handler{Store: testGeminiConfig{}, Auth: testGeminiAuth{}, DS: testGeminiDS{resp: upstream}})
+		return r
+	}
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"reasoning_mode":"inline_think_tags"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-reasoner:generateContent", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), `"thought"`) {
+		t.Fatalf("expected no thought parts in inline mode, body=%s", rec.Body.String())
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	candidates, _ := out["candidates"].([]any)
+	content, _ := candidates[0].(map[string]any)["content"].(map[string]any)
+	parts, _ := content["parts"].([]any)
+	if len(parts) != 1 || parts[0].(map[string]any)["text"] != "<think>pondering</think>\n\nanswer" {
+		t.Fatalf("expected reasoning inlined before the answer, got %#v", parts)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1beta/models/deepseek-reasoner:streamGenerateContent?alt=sse", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	var text strings.Builder
+	for _, frame := range extractGeminiSSEFrames(t, rec.Body.String()) {
+		candidates, _ := frame["candidates"].([]any)
+		content, _ := candidates[0].(map[string]any)["content"].(map[string]any)
+		parts, _ := content["parts"].([]any)
+		for _, p := range parts {
+			part, _ := p.(map[string]any)
+			if part["thought"] != nil {
+				t.Fatalf("unexpected thought part %#v", part)
+			}
+			s, _ := part["text"].(string)
+			text.WriteString(s)
+		}
+	}
+	if text.String() != "<think>pondering</think>\n\nanswer" {
+		t.Fatalf("unexpected streamed text %q", text.String())
+	}
+}
+
+func TestListModelsServesRegistry(t *testing.T) {
+	r := chi.NewRouter()
+	RegisterRoutes(r, &Handler{Store: testGeminiConfig{}})
+	req := httptest.NewRequest(http.MethodGet, "/v1beta/models", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Models []struct {
+			Name             string `json:"name"`
+			OutputTokenLimit int    `json:"outputTokenLimit"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(out.Models) == 0 || out.Models[0].Name != "models/deepseek-chat" || out.Models[0].OutputTokenLimit == 0 {
+		t.Fatalf("unexpected models %#v", out.Models)
+	}
+}
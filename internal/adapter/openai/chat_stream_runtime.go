@@ -8,6 +8,7 @@ import (
 	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/toolsieve"
 	"ds2api/internal/util"
 )
 
@@ -21,17 +22,31 @@ type chatStreamRuntime struct {
 	model        string
 	finalPrompt  string
 	toolNames    []string
+	tools        util.ToolCallReader
 
 	thinkingEnabled bool
 	searchEnabled   bool
 
+	// choiceIndex and multiChoice are set when the runtime renders one branch
+	// of an n > 1 request; the caller then owns usage and the [DONE] marker.
+	choiceIndex int
+	multiChoice bool
+
 	firstChunkSent       bool
 	bufferToolContent    bool
 	emitEarlyToolDeltas  bool
 	toolCallsEmitted     bool
 	toolCallsDoneEmitted bool
+	// toolCallsRejected is set when a call failed its schema at close; its
+	// streamed deltas then do not make the choice finish with tool_calls.
+	toolCallsRejected bool
 
-	toolSieve         toolStreamSieveState
+	// cacheHit reports the prompt as cached tokens in usage.
+	cacheHit          bool
+	limiter           *sse.OutputLimiter
+	citations         *sse.SearchCitations
+	reasoning         *util.ReasoningPresenter
+	toolSieve         toolsieve.State
 	streamToolCallIDs map[int]string
 	streamToolNames   map[int]string
 	thinking          strings.Builder
@@ -52,6 +67,10 @@ func newChatStreamRuntime(
 	bufferToolContent bool,
 	emitEarlyToolDeltas bool,
 ) *chatStreamRuntime {
+	var citations *sse.SearchCitations
+	if searchEnabled {
+		citations = sse.NewSearchCitations()
+	}
 	return &chatStreamRuntime{
 		w:                   w,
 		rc:                  rc,
@@ -63,6 +82,7 @@ func newChatStreamRuntime(
 		toolNames:           toolNames,
 		thinkingEnabled:     thinkingEnabled,
 		searchEnabled:       searchEnabled,
+		citations:           citations,
 		bufferToolContent:   bufferToolContent,
 		emitEarlyToolDeltas: emitEarlyToolDeltas,
 		streamToolCallIDs:   map[int]string{},
@@ -70,6 +90,15 @@ func newChatStreamRuntime(
 	}
 }
 
+// setToolCallReader selects how tool calls are read from the output.
+// Argument deltas stream as written; calls are checked against their schemas
+// when they close, and the closing tool_calls chunk carries the checked
+// arguments.
+func (s *chatStreamRuntime) setToolCallReader(tools util.ToolCallReader) {
+	s.tools = tools
+	s.toolSieve.Dialects = tools.Dialects
+}
+
 func (s *chatStreamRuntime) sendKeepAlive() {
 	if !s.canFlush {
 		return
@@ -80,9 +109,11 @@ func (s *chatStreamRuntime) sendKeepAlive() {
 
 func (s *chatStreamRuntime) sendChunk(v any) {
 	b, _ := json.Marshal(v)
-	_, _ = s.w.Write([]byte("data: "))
-	_, _ = s.w.Write(b)
-	_, _ = s.w.Write([]byte("\n\n"))
+	frame := make([]byte, 0, len(b)+8)
+	frame = append(frame, "data: "...)
+	frame = append(frame, b...)
+	frame = append(frame, "\n\n"...)
+	_, _ = s.w.Write(frame)
 	if s.canFlush {
 		_ = s.rc.Flush()
 	}
@@ -96,9 +127,17 @@ func (s *chatStreamRuntime) sendDone() {
 }
 
 func (s *chatStreamRuntime) finalize(finishReason string) {
+	s.emitParts(s.limiter.Flush())
+	s.emitParts(s.citations.Flush())
+	if end := s.appendReasoningEnd(nil); len(end) > 0 {
+		s.sendChunk(openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, end, nil))
+	}
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
-	detected := util.ParseToolCalls(finalText, s.toolNames)
+	detected := util.ParseToolCallsWith(finalText, s.toolNames, s.tools.Dialects)
+	if len(detected) > 0 && !s.toolCallsDoneEmitted {
+		detected = s.tools.Check(detected)
+	}
 	if len(detected) > 0 && !s.toolCallsDoneEmitted {
 		finishReason = "tool_calls"
 		delta := map[string]any{
@@ -112,13 +151,14 @@ func (s *chatStreamRuntime) finalize(finishReason string) {
 			s.completionID,
 			s.created,
 			s.model,
-			[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(0, delta)},
+			[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, delta)},
 			nil,
 		))
 		s.toolCallsEmitted = true
 		s.toolCallsDoneEmitted = true
 	} else if s.bufferToolContent {
-		for _, evt := range flushToolSieve(&s.toolSieve, s.toolNames) {
+		for _, evt := range s.toolSieve.Flush(s.toolNames) {
+			evt.ToolCalls = s.tools.Check(evt.ToolCalls)
 			if len(evt.ToolCalls) > 0 {
 				finishReason = "tool_calls"
 				s.toolCallsEmitted = true
@@ -134,7 +174,7 @@ func (s *chatStreamRuntime) finalize(finishReason string) {
 					s.completionID,
 					s.created,
 					s.model,
-					[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(0, tcDelta)},
+					[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, tcDelta)},
 					nil,
 				))
 			}
@@ -152,23 +192,29 @@ func (s *chatStreamRuntime) finalize(finishReason string) {
 				s.completionID,
 				s.created,
 				s.model,
-				[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(0, delta)},
+				[]map[string]any{openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, delta)},
 				nil,
 			))
 		}
 	}
 
-	if len(detected) > 0 || s.toolCallsEmitted {
+	if len(detected) > 0 || (s.toolCallsEmitted && !s.toolCallsRejected) {
 		finishReason = "tool_calls"
 	}
+	var usage map[string]any
+	if !s.multiChoice {
+		usage = cachedUsage(s.cacheHit, openaifmt.BuildChatUsage(s.finalPrompt, finalThinking, finalText))
+	}
 	s.sendChunk(openaifmt.BuildChatStreamChunk(
 		s.completionID,
 		s.created,
 		s.model,
-		[]map[string]any{openaifmt.BuildChatStreamFinishChoice(0, finishReason)},
-		openaifmt.BuildChatUsage(s.finalPrompt, finalThinking, finalText),
+		[]map[string]any{openaifmt.BuildChatStreamFinishChoice(s.choiceIndex, finishReason)},
+		usage,
 	))
-	s.sendDone()
+	if !s.multiChoice {
+		s.sendDone()
+	}
 }
 
 func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedDecision {
@@ -182,16 +228,29 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonHandlerRequested}
 	}
 
-	newChoices := make([]map[string]any, 0, len(parsed.Parts))
+	s.citations.AddResults(parsed.SearchResults)
+	contentSeen := s.emitParts(s.limiter.Apply(parsed.Parts))
+	if s.limiter.Reached() {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonOutputLimit, ContentSeen: contentSeen}
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *chatStreamRuntime) emitParts(parts []sse.ContentPart) bool {
+	newChoices := make([]map[string]any, 0, len(parts))
 	contentSeen := false
-	for _, p := range parsed.Parts {
-		if s.searchEnabled && sse.IsCitation(p.Text) {
-			continue
+	for _, p := range parts {
+		var citations []sse.Citation
+		if p.Type != "thinking" {
+			p.Text, citations = s.citations.Strip(p.Text)
 		}
-		if p.Text == "" {
+		if p.Text == "" && len(citations) == 0 {
 			continue
 		}
 		contentSeen = true
+		if p.Type != "thinking" {
+			newChoices = s.appendReasoningEnd(newChoices)
+		}
 		delta := map[string]any{}
 		if !s.firstChunkSent {
 			delta["role"] = "assistant"
@@ -200,15 +259,27 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 		if p.Type == "thinking" {
 			if s.thinkingEnabled {
 				s.thinking.WriteString(p.Text)
-				delta["reasoning_content"] = p.Text
+				reasoning, inline := s.reasoning.Thinking(p.Text)
+				presentReasoningDelta(delta, reasoning, inline)
 			}
 		} else {
 			s.text.WriteString(p.Text)
+			if len(citations) > 0 {
+				inline := s.reasoning.Inline()
+				delta["annotations"] = openaifmt.BuildChatURLCitations(inline+s.text.String(), sse.OffsetCitations(citations, len(inline)))
+			}
 			if !s.bufferToolContent {
-				delta["content"] = p.Text
+				if p.Text != "" {
+					delta["content"] = p.Text
+				}
 			} else {
-				events := processToolSieveChunk(&s.toolSieve, p.Text, s.toolNames)
+				events := s.toolSieve.Process(p.Text, s.toolNames)
 				for _, evt := range events {
+					checked := s.tools.Check(evt.ToolCalls)
+					if len(evt.ToolCalls) > 0 && len(checked) == 0 {
+						s.toolCallsRejected = true
+					}
+					evt.ToolCalls = checked
 					if len(evt.ToolCallDeltas) > 0 {
 						if !s.emitEarlyToolDeltas {
 							continue
@@ -229,7 +300,7 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 							tcDelta["role"] = "assistant"
 							s.firstChunkSent = true
 						}
-						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, tcDelta))
+						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, tcDelta))
 						continue
 					}
 					if len(evt.ToolCalls) > 0 {
@@ -242,7 +313,7 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 							tcDelta["role"] = "assistant"
 							s.firstChunkSent = true
 						}
-						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, tcDelta))
+						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, tcDelta))
 						continue
 					}
 					if evt.Content != "" {
@@ -253,18 +324,43 @@ func (s *chatStreamRuntime) onParsed(parsed sse.LineResult) streamengine.ParsedD
 							contentDelta["role"] = "assistant"
 							s.firstChunkSent = true
 						}
-						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, contentDelta))
+						newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, contentDelta))
 					}
 				}
 			}
 		}
 		if len(delta) > 0 {
-			newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(0, delta))
+			newChoices = append(newChoices, openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, delta))
 		}
 	}
 
 	if len(newChoices) > 0 {
 		s.sendChunk(openaifmt.BuildChatStreamChunk(s.completionID, s.created, s.model, newChoices, nil))
 	}
-	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+	return contentSeen
+}
+
+// appendReasoningEnd closes presented reasoning once the answer starts or the
+// stream ends: the </think> tag inline, or the summary in summary_only mode.
+func (s *chatStreamRuntime) appendReasoningEnd(choices []map[string]any) []map[string]any {
+	reasoning, inline := s.reasoning.EndThinking()
+	if reasoning == "" && inline == "" {
+		return choices
+	}
+	delta := map[string]any{}
+	if !s.firstChunkSent {
+		delta["role"] = "assistant"
+		s.firstChunkSent = true
+	}
+	presentReasoningDelta(delta, reasoning, inline)
+	return append(choices, openaifmt.BuildChatStreamDeltaChoice(s.choiceIndex, delta))
+}
+
+func presentReasoningDelta(delta map[string]any, reasoning, inline string) {
+	if reasoning != "" {
+		delta["reasoning_content"] = reasoning
+	}
+	if inline != "" {
+		delta["content"] = inline
+	}
 }
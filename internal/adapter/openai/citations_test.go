@@ -0,0 +1,128 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+var searchUpstreamLines = []string{
+	`data: {"p":"response/search_results","v":[{"url":"https://a.example","title":"A","cite_index":1}]}`,
+	`data: {"p":"response/content","v":"天空是蓝色的"}`,
+	`data: {"p":"response/content","v":"[citation:1]。"}`,
+	`data: [DONE]`,
+}
+
+func TestHandleStreamRendersURLCitationAnnotations(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(searchUpstreamLines...)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStreamWithLimits(rec, req, resp, "cid-search", "deepseek-chat-search", "prompt", false, true, nil, "", sse.OutputLimits{}, util.ToolCallReader{})
+
+	frames, _ := parseSSEDataFrames(t, rec.Body.String())
+	content := strings.Builder{}
+	var annotations []any
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			delta, _ := item.(map[string]any)["delta"].(map[string]any)
+			if c, ok := delta["content"].(string); ok {
+				content.WriteString(c)
+			}
+			if a, ok := delta["annotations"].([]any); ok {
+				annotations = append(annotations, a...)
+			}
+		}
+	}
+	if content.String() != "天空是蓝色的。" {
+		t.Fatalf("expected markers stripped, got %q", content.String())
+	}
+	if len(annotations) != 1 {
+		t.Fatalf("expected one annotation, body=%s", rec.Body.String())
+	}
+	cite, _ := annotations[0].(map[string]any)["url_citation"].(map[string]any)
+	if cite["url"] != "https://a.example" || cite["start_index"] != float64(0) || cite["end_index"] != float64(6) {
+		t.Fatalf("unexpected url_citation %#v", cite)
+	}
+}
+
+func TestHandleNonStreamAddsMessageAnnotations(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(searchUpstreamLines...)
+	rec := httptest.NewRecorder()
+
+	h.handleNonStreamWithLimits(rec, context.Background(), resp, "cid-search", "deepseek-chat-search", "prompt", false, true, nil, "", sse.OutputLimits{}, util.ToolCallReader{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	choices, _ := out["choices"].([]any)
+	message, _ := choices[0].(map[string]any)["message"].(map[string]any)
+	if message["content"] != "天空是蓝色的。" {
+		t.Fatalf("expected markers stripped, got %#v", message["content"])
+	}
+	annotations, _ := message["annotations"].([]any)
+	if len(annotations) != 1 {
+		t.Fatalf("expected one annotation, got %#v", message)
+	}
+}
+
+func TestHandleNonStreamKeepsCitationTextWithSearchOff(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(searchUpstreamLines...)
+	rec := httptest.NewRecorder()
+
+	h.handleNonStreamWithLimits(rec, context.Background(), resp, "cid-plain", "deepseek-chat", "prompt", false, false, nil, "", sse.OutputLimits{}, util.ToolCallReader{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	choices, _ := out["choices"].([]any)
+	message, _ := choices[0].(map[string]any)["message"].(map[string]any)
+	if message["content"] != "天空是蓝色的[citation:1]。" {
+		t.Fatalf("expected the text as written without search, got %#v", message["content"])
+	}
+	if _, ok := message["annotations"]; ok {
+		t.Fatalf("expected no annotations without search, got %#v", message)
+	}
+}
+
+func TestHandleResponsesStreamEmitsAnnotationEvents(t *testing.T) {
+	h := &Handler{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	rec := httptest.NewRecorder()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(strings.Join(searchUpstreamLines, "\n") + "\n")),
+	}
+
+	h.handleResponsesStreamWithLimits(rec, req, resp, "owner-a", "resp_test", "deepseek-chat-search", "prompt", false, true, nil, util.DefaultToolChoicePolicy(), "", "", sse.OutputLimits{}, util.ToolCallReader{}, nil)
+
+	added, ok := extractSSEEventPayload(rec.Body.String(), "response.output_text.annotation.added")
+	if !ok {
+		t.Fatalf("expected annotation event, body=%s", rec.Body.String())
+	}
+	annotation, _ := added["annotation"].(map[string]any)
+	if annotation["type"] != "url_citation" || annotation["url"] != "https://a.example" {
+		t.Fatalf("unexpected annotation %#v", added)
+	}
+	completed, ok := extractSSEEventPayload(rec.Body.String(), "response.completed")
+	if !ok {
+		t.Fatalf("expected response.completed, body=%s", rec.Body.String())
+	}
+	responseObj, _ := completed["response"].(map[string]any)
+	if responseObj["output_text"] != "天空是蓝色的。" {
+		t.Fatalf("expected markers stripped, got %#v", responseObj["output_text"])
+	}
+	output, _ := responseObj["output"].([]any)
+	item, _ := output[0].(map[string]any)
+	content, _ := item["content"].([]any)
+	part, _ := content[0].(map[string]any)
+	if annotations, _ := part["annotations"].([]any); len(annotations) != 1 {
+		t.Fatalf("expected annotations on output_text, got %#v", part)
+	}
+}
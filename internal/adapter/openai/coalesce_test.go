@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/coalesce"
+	"ds2api/internal/config"
+)
+
+type coalesceConfigStub struct{}
+
+func (coalesceConfigStub) CoalesceConfig() config.CoalesceConfig {
+	return config.CoalesceConfig{Enabled: true, MaxSubscribers: 8}
+}
+
+// gatedDSStub holds every completion open until gate is closed.
+type gatedDSStub struct {
+	calls *atomic.Int32
+	gate  chan struct{}
+}
+
+func (m gatedDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m gatedDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m gatedDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	m.calls.Add(1)
+	pr, pw := io.Pipe()
+	go func() {
+		<-m.gate
+		_, _ = pw.Write([]byte("data: {\"p\":\"response/content\",\"v\":\"shared answer\"}\ndata: [DONE]\n"))
+		_ = pw.Close()
+	}()
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: pr}, nil
+}
+
+func TestChatCompletionsCoalescesConcurrentIdenticalRequests(t *testing.T) {
+	var calls atomic.Int32
+	gate := make(chan struct{})
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: gatedDSStub{calls: &calls, gate: gate}, Flights: coalesce.New(coalesceConfigStub{})}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	body, _ := json.Marshal(map[string]any{
+		"model":       "deepseek-chat",
+		"temperature": 0,
+		"messages":    []any{map[string]any{"role": "user", "content": "hi"}},
+	})
+
+	recs := make([]*httptest.ResponseRecorder, 3)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+			req.Header.Set("Authorization", "Bearer direct-token")
+			r.ServeHTTP(recs[i], req)
+		}()
+		for deadline := time.Now().Add(time.Second); calls.Load() == 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected one upstream completion, got %d", calls.Load())
+	}
+	ids := map[string]bool{}
+	joined := 0
+	for _, rec := range recs {
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "shared answer") {
+			t.Fatalf("expected the shared answer, got %d %s", rec.Code, rec.Body.String())
+		}
+		ids[asString(out["id"])] = true
+		if rec.Header().Get(coalesce.Header) == "true" {
+			joined++
+		}
+	}
+	if len(ids) != 3 || joined != 2 {
+		t.Fatalf("expected separate ids and two joined requests, got ids=%v joined=%d", ids, joined)
+	}
+}
@@ -0,0 +1,110 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// promptRecordingDSStub answers the history summary call with a summary and
+// records every prompt it is sent.
+type promptRecordingDSStub struct {
+	mu      *sync.Mutex
+	prompts *[]string
+}
+
+func (m promptRecordingDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m promptRecordingDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m promptRecordingDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, payload map[string]any, _ string, _ int) (*http.Response, error) {
+	prompt, _ := payload["prompt"].(string)
+	m.mu.Lock()
+	*m.prompts = append(*m.prompts, prompt)
+	m.mu.Unlock()
+	if strings.HasPrefix(prompt, "Summarize the conversation") {
+		return makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"SUMMARY OF EARLIER TURNS"}`, "data: [DONE]"), nil
+	}
+	return makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"answer"}`, "data: [DONE]"), nil
+}
+
+func TestChatCompletionsSummarizesOverflowingHistory(t *testing.T) {
+	var prompts []string
+	ds := promptRecordingDSStub{mu: &sync.Mutex{}, prompts: &prompts}
+	cfg := mockOpenAIConfig{wideInput: true, context: config.ContextConfig{Strategy: "summarize", MaxPromptTokens: 300}}
+	h := &Handler{Store: cfg, Auth: streamStatusAuthStub{}, DS: ds}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+
+	long := strings.Repeat("old detail ", 150)
+	body, _ := json.Marshal(map[string]any{
+		"model": "deepseek-chat",
+		"messages": []any{
+			map[string]any{"role": "system", "content": "be brief"},
+			map[string]any{"role": "user", "content": "OLDEST " + long},
+			map[string]any{"role": "assistant", "content": long},
+			map[string]any{"role": "user", "content": "what now?"},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(util.ContextHeader); !strings.Contains(got, "strategy=summarize") || !strings.Contains(got, "summarized_messages=2") {
+		t.Fatalf("unexpected context header %q", got)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[0], "OLDEST") {
+		t.Fatalf("expected a summary call over the dropped turns, got %d prompts", len(prompts))
+	}
+	final := prompts[1]
+	if strings.Contains(final, "OLDEST") || !strings.Contains(final, "SUMMARY OF EARLIER TURNS") || !strings.Contains(final, "what now?") {
+		t.Fatalf("unexpected final prompt %q", final)
+	}
+}
+
+func TestNormalizeResponsesTruncationAuto(t *testing.T) {
+	cfg := mockOpenAIConfig{wideInput: true, context: config.ContextConfig{MaxPromptTokens: 200}}
+	long := strings.Repeat("old detail ", 150)
+	newReq := func(truncation string) map[string]any {
+		return map[string]any{
+			"model":      "deepseek-chat",
+			"truncation": truncation,
+			"input": []any{
+				map[string]any{"role": "user", "content": long},
+				map[string]any{"role": "user", "content": "what now?"},
+			},
+		}
+	}
+	n, err := normalizeOpenAIResponsesRequest(cfg, newReq("auto"), config.RouteInput{}, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if n.Context.Strategy != util.ContextDropOldest || n.Context.DroppedMessages != 1 || strings.Contains(n.FinalPrompt, "old detail") {
+		t.Fatalf("expected truncation=auto to drop the oldest turn, got %#v", n.Context)
+	}
+	n, err = normalizeOpenAIResponsesRequest(cfg, newReq("disabled"), config.RouteInput{}, "")
+	if err != nil || n.Context.Trimmed() {
+		t.Fatalf("expected truncation=disabled to keep the prompt, got %#v err=%v", n.Context, err)
+	}
+	if _, err := normalizeOpenAIResponsesRequest(cfg, newReq("sometimes"), config.RouteInput{}, ""); err == nil {
+		t.Fatal("expected invalid truncation to fail")
+	}
+}
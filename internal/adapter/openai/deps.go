@@ -12,6 +12,7 @@ import (
 type AuthResolver interface {
 	Determine(req *http.Request) (*auth.RequestAuth, error)
 	DetermineCaller(req *http.Request) (*auth.RequestAuth, error)
+	Fork(ctx context.Context, a *auth.RequestAuth) (*auth.RequestAuth, error)
 	Release(a *auth.RequestAuth)
 }
 
@@ -23,10 +24,18 @@ type DeepSeekCaller interface {
 
 type ConfigReader interface {
 	ModelAliases() map[string]string
+	Models() []config.ModelConfig
+	RoutingRules() []config.RoutingRule
+	ContextConfig() config.ContextConfig
+	PromptTemplates() []config.PromptTemplate
+	ToolPrompts() []config.ToolPrompt
 	CompatWideInputStrictOutput() bool
+	CompatReasoningMode(key string) string
 	ToolcallMode() string
 	ToolcallEarlyEmitConfidence() string
+	ToolcallRepairAttempts() int
 	ResponsesStoreTTLSeconds() int
+	ResponsesStreamGraceSeconds() int
 	EmbeddingsProvider() string
 }
 
@@ -1,23 +1,46 @@
 package openai
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"ds2api/internal/config"
+)
 
 type mockOpenAIConfig struct {
 	aliases      map[string]string
 	wideInput    bool
 	toolMode     string
+	repairs      int
 	earlyEmit    string
 	responsesTTL int
+	streamGrace  int
 	embedProv    string
+	reasoning    string
+	models       []config.ModelConfig
+	rules        []config.RoutingRule
+	context      config.ContextConfig
+	templates    []config.PromptTemplate
+	toolPrompts  []config.ToolPrompt
 }
 
 func (m mockOpenAIConfig) ModelAliases() map[string]string { return m.aliases }
+func (m mockOpenAIConfig) Models() []config.ModelConfig {
+	return config.MergeModels(config.DefaultModels(), m.models)
+}
+func (m mockOpenAIConfig) RoutingRules() []config.RoutingRule       { return m.rules }
+func (m mockOpenAIConfig) ContextConfig() config.ContextConfig      { return m.context }
+func (m mockOpenAIConfig) PromptTemplates() []config.PromptTemplate { return m.templates }
+func (m mockOpenAIConfig) ToolPrompts() []config.ToolPrompt         { return m.toolPrompts }
 func (m mockOpenAIConfig) CompatWideInputStrictOutput() bool {
 	return m.wideInput
 }
+func (m mockOpenAIConfig) CompatReasoningMode(string) string   { return m.reasoning }
 func (m mockOpenAIConfig) ToolcallMode() string                { return m.toolMode }
+func (m mockOpenAIConfig) ToolcallRepairAttempts() int         { return m.repairs }
 func (m mockOpenAIConfig) ToolcallEarlyEmitConfidence() string { return m.earlyEmit }
 func (m mockOpenAIConfig) ResponsesStoreTTLSeconds() int       { return m.responsesTTL }
+func (m mockOpenAIConfig) ResponsesStreamGraceSeconds() int    { return m.streamGrace }
 func (m mockOpenAIConfig) EmbeddingsProvider() string          { return m.embedProv }
 
 func TestNormalizeOpenAIChatRequestWithConfigInterface(t *testing.T) {
@@ -31,7 +54,7 @@ func TestNormalizeOpenAIChatRequestWithConfigInterface(t *testing.T) {
 		"model":    "my-model",
 		"messages": []any{map[string]any{"role": "user", "content": "hello"}},
 	}
-	out, err := normalizeOpenAIChatRequest(cfg, req, "")
+	out, err := normalizeOpenAIChatRequest(cfg, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("normalizeOpenAIChatRequest error: %v", err)
 	}
@@ -43,6 +66,66 @@ func TestNormalizeOpenAIChatRequestWithConfigInterface(t *testing.T) {
 	}
 }
 
+func TestNormalizeOpenAIChatRequestAppliesRoutingRules(t *testing.T) {
+	thinking := false
+	cfg := mockOpenAIConfig{
+		wideInput: true,
+		rules: []config.RoutingRule{
+			{Model: "*-mini", Keys: []string{"key-x"}, Target: "deepseek-chat"},
+			{Keys: []string{"key-x"}, Target: "deepseek-reasoner-search", Thinking: &thinking},
+		},
+	}
+	route := func(model string) (string, bool, bool) {
+		req := map[string]any{
+			"model":    model,
+			"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+		}
+		out, err := normalizeOpenAIChatRequest(cfg, req, config.RouteInput{Key: "key-x"}, "")
+		if err != nil {
+			t.Fatalf("normalizeOpenAIChatRequest(%s) error: %v", model, err)
+		}
+		if out.RequestedModel != model || out.ResponseModel != model {
+			t.Fatalf("expected requested model %q to be kept, got %#v", model, out)
+		}
+		return out.ResolvedModel, out.Thinking, out.Search
+	}
+	if got, thinking, search := route("gpt-4o-mini"); got != "deepseek-chat" || thinking || search {
+		t.Fatalf("expected mini models on chat, got %s thinking=%v search=%v", got, thinking, search)
+	}
+	if got, thinking, search := route("totally-custom-model"); got != "deepseek-reasoner-search" || thinking || !search {
+		t.Fatalf("expected catch-all rule with thinking override, got %s thinking=%v search=%v", got, thinking, search)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestUsesConfiguredToolPrompt(t *testing.T) {
+	cfg := mockOpenAIConfig{
+		wideInput: true,
+		toolPrompts: []config.ToolPrompt{
+			{Name: "terse", Surfaces: []string{"openai"}, Models: []string{"deepseek-reasoner"}, Template: "Call {{join .ToolNames \"|\"}} as JSON."},
+		},
+	}
+	normalize := func(model string) string {
+		req := map[string]any{
+			"model":    model,
+			"messages": []any{map[string]any{"role": "user", "content": "weather?"}},
+			"tools": []any{map[string]any{"type": "function", "function": map[string]any{
+				"name": "get_weather", "parameters": map[string]any{"type": "object"},
+			}}},
+		}
+		out, err := normalizeOpenAIChatRequest(cfg, req, config.RouteInput{}, "")
+		if err != nil {
+			t.Fatalf("normalizeOpenAIChatRequest(%s) error: %v", model, err)
+		}
+		return out.FinalPrompt
+	}
+	if got := normalize("deepseek-reasoner"); !strings.HasPrefix(got, "Call get_weather as JSON.") || strings.Contains(got, "You have access to these tools") {
+		t.Fatalf("expected configured tool prompt, got %q", got)
+	}
+	if got := normalize("deepseek-chat"); !strings.Contains(got, "You have access to these tools") {
+		t.Fatalf("expected built-in tool prompt for other models, got %q", got)
+	}
+}
+
 func TestNormalizeOpenAIResponsesRequestWideInputPolicyFromInterface(t *testing.T) {
 	req := map[string]any{
 		"model": "deepseek-chat",
@@ -52,7 +135,7 @@ func TestNormalizeOpenAIResponsesRequestWideInputPolicyFromInterface(t *testing.
 	_, err := normalizeOpenAIResponsesRequest(mockOpenAIConfig{
 		aliases:   map[string]string{},
 		wideInput: false,
-	}, req, "")
+	}, req, config.RouteInput{}, "")
 	if err == nil {
 		t.Fatal("expected error when wide input is disabled and only input is provided")
 	}
@@ -60,7 +143,7 @@ func TestNormalizeOpenAIResponsesRequestWideInputPolicyFromInterface(t *testing.
 	out, err := normalizeOpenAIResponsesRequest(mockOpenAIConfig{
 		aliases:   map[string]string{},
 		wideInput: true,
-	}, req, "")
+	}, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("unexpected error when wide input is enabled: %v", err)
 	}
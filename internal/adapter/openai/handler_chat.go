@@ -3,16 +3,18 @@ package openai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"time"
 
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
-	"ds2api/internal/deepseek"
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/respcache"
 	"ds2api/internal/sse"
-	streamengine "ds2api/internal/stream"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
 )
 
 func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
@@ -43,40 +45,83 @@ func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
-	stdReq, err := normalizeOpenAIChatRequest(h.Store, req, requestTraceID(r))
+	stdReq, err := normalizeOpenAIChatRequest(h.Store, req, auth.RouteInput(r), requestTraceID(r))
 	if err != nil {
 		writeOpenAIError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	stdReq = upstream.FitContext(r.Context(), h.DS, a, w.Header(), stdReq)
 
-	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
-	if err != nil {
-		if a.UseConfigToken {
-			writeOpenAIError(w, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin.")
-		} else {
-			writeOpenAIError(w, http.StatusUnauthorized, "Invalid token. If this should be a DS2API key, add it to config.keys first.")
-		}
+	if stdReq.Choices > 1 {
+		h.handleChatChoices(w, r, a, stdReq)
 		return
 	}
-	pow, err := h.DS.GetPow(r.Context(), a, 3)
-	if err != nil {
-		writeOpenAIError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
-		return
-	}
-	payload := stdReq.CompletionPayload(sessionID)
-	resp, err := h.DS.CallCompletion(r.Context(), a, payload, pow, 3)
+
+	completion, err := upstream.OpenShared(r.Context(), h.DS, h.sharing(r, a), a, stdReq, w.Header())
 	if err != nil {
-		writeOpenAIError(w, http.StatusInternalServerError, "Failed to get completion.")
+		writeOpenAIUpstreamError(w, a, err)
 		return
 	}
+	resp, sessionID := completion.Resp, completion.SessionID
+	limits := sse.LimitsFromRequest(stdReq)
+	tools := h.toolCallReader(r.Context(), a, stdReq)
 	if stdReq.Stream {
-		h.handleStream(w, r, resp, sessionID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames)
+		h.handleStreamWithLimits(w, r, resp, sessionID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ReasoningMode, limits, tools)
 		return
 	}
-	h.handleNonStream(w, r.Context(), resp, sessionID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.ToolNames)
+	h.handleNonStreamWithLimits(w, r.Context(), resp, sessionID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ReasoningMode, limits, tools)
+}
+
+// sharing lets the request share upstream work with identical requests. A
+// request that joins another's completion gives its account slot back.
+func (h *Handler) sharing(r *http.Request, a *auth.RequestAuth) upstream.Sharing {
+	return upstream.Sharing{
+		Cache:     h.Cache,
+		Flights:   h.Flights,
+		CallerKey: auth.RouteInput(r).Key,
+		Release:   func() { h.Auth.Release(a) },
+	}
+}
+
+// writeOpenAIUpstreamError maps a failure from upstream.Open onto the error
+// each stage has always reported.
+func writeOpenAIUpstreamError(w http.ResponseWriter, a *auth.RequestAuth, err error) {
+	status, message := openAIUpstreamError(a, err)
+	writeOpenAIError(w, status, message)
+}
+
+// openAIUpstreamError maps a failure from upstream.Open to the status and
+// message reported to OpenAI clients.
+func openAIUpstreamError(a *auth.RequestAuth, err error) (int, string) {
+	switch upstream.StageOf(err) {
+	case upstream.StageAccount:
+		return http.StatusTooManyRequests, errors.Unwrap(err).Error()
+	case upstream.StageSession:
+		if a.UseConfigToken {
+			return http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin."
+		}
+		return http.StatusUnauthorized, "Invalid token. If this should be a DS2API key, add it to config.keys first."
+	case upstream.StagePow:
+		return http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error)."
+	default:
+		return http.StatusInternalServerError, "Failed to get completion."
+	}
+}
+
+// cachedUsage reports the prompt of usage as cached tokens when the response
+// was replayed from the response cache.
+func cachedUsage(hit bool, usage map[string]any) map[string]any {
+	if hit {
+		return openaifmt.MarkCachedPrompt(usage)
+	}
+	return usage
 }
 
 func (h *Handler) handleNonStream(w http.ResponseWriter, ctx context.Context, resp *http.Response, completionID, model, finalPrompt string, thinkingEnabled bool, toolNames []string) {
+	h.handleNonStreamWithLimits(w, ctx, resp, completionID, model, finalPrompt, thinkingEnabled, false, toolNames, util.ReasoningSeparate, sse.OutputLimits{}, util.ToolCallReader{Dialects: h.toolcallDialects()})
+}
+
+func (h *Handler) handleNonStreamWithLimits(w http.ResponseWriter, ctx context.Context, resp *http.Response, completionID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader) {
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
@@ -84,25 +129,48 @@ func (h *Handler) handleNonStream(w http.ResponseWriter, ctx context.Context, re
 		return
 	}
 	_ = ctx
-	result := sse.CollectStream(resp, thinkingEnabled, true)
+	result := sse.CollectStreamWithLimits(resp, thinkingEnabled, true, limits)
 
 	finalThinking := result.Thinking
-	finalText := result.Text
-	respBody := openaifmt.BuildChatCompletion(completionID, model, finalPrompt, finalThinking, finalText, toolNames)
+	finalText, citations := sse.ResolveCitations(result.Text, result.SearchResults, searchEnabled)
+	respBody := openaifmt.BuildChatCompletionFromChoices(
+		completionID,
+		model,
+		[]map[string]any{buildChatChoiceWithCitations(0, finalThinking, finalText, toolNames, tools, chatFinishReason(result.Limit), citations, reasoningMode)},
+		cachedUsage(respcache.IsHit(w.Header()), openaifmt.BuildChatUsage(finalPrompt, finalThinking, finalText)),
+	)
 	writeJSON(w, http.StatusOK, respBody)
 }
 
+func buildChatChoiceWithCitations(index int, finalThinking, finalText string, toolNames []string, tools util.ToolCallReader, finishReason string, citations []sse.Citation, reasoningMode util.ReasoningMode) map[string]any {
+	choice := openaifmt.BuildChatChoiceWithReasoning(index, finalThinking, finalText, toolNames, tools, finishReason, reasoningMode)
+	// Inline reasoning is a prefix of the content, so citations move past it.
+	_, shownText := util.PresentReasoning(reasoningMode, finalThinking, finalText)
+	openaifmt.AddChatAnnotations(choice, shownText, sse.OffsetCitations(citations, len(shownText)-len(finalText)))
+	return choice
+}
+
+// chatFinishReason reports a max_tokens cut as "length"; a stop sequence is
+// an ordinary "stop" in the OpenAI protocol.
+func chatFinishReason(limit sse.LimitReason) string {
+	if limit == sse.LimitMaxTokens {
+		return "length"
+	}
+	return "stop"
+}
+
 func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *http.Response, completionID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string) {
+	h.handleStreamWithLimits(w, r, resp, completionID, model, finalPrompt, thinkingEnabled, searchEnabled, toolNames, util.ReasoningSeparate, sse.OutputLimits{}, util.ToolCallReader{Dialects: h.toolcallDialects()})
+}
+
+func (h *Handler) handleStreamWithLimits(w http.ResponseWriter, r *http.Request, resp *http.Response, completionID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		writeOpenAIError(w, resp.StatusCode, string(body))
 		return
 	}
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache, no-transform")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
+	setChatStreamHeaders(w)
 	rc := http.NewResponseController(w)
 	_, canFlush := w.(http.Flusher)
 	if !canFlush {
@@ -112,11 +180,6 @@ func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *htt
 	created := time.Now().Unix()
 	bufferToolContent := len(toolNames) > 0 && h.toolcallFeatureMatchEnabled()
 	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence()
-	initialType := "text"
-	if thinkingEnabled {
-		initialType = "thinking"
-	}
-
 	streamRuntime := newChatStreamRuntime(
 		w,
 		rc,
@@ -132,25 +195,9 @@ func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, resp *htt
 		emitEarlyToolDeltas,
 	)
 
-	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
-		Context:             r.Context(),
-		Body:                resp.Body,
-		ThinkingEnabled:     thinkingEnabled,
-		InitialType:         initialType,
-		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
-		IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
-		MaxKeepAliveNoInput: deepseek.MaxKeepaliveCount,
-	}, streamengine.ConsumeHooks{
-		OnKeepAlive: func() {
-			streamRuntime.sendKeepAlive()
-		},
-		OnParsed: streamRuntime.onParsed,
-		OnFinalize: func(reason streamengine.StopReason, _ error) {
-			if string(reason) == "content_filter" {
-				streamRuntime.finalize("content_filter")
-				return
-			}
-			streamRuntime.finalize("stop")
-		},
-	})
+	streamRuntime.cacheHit = respcache.IsHit(w.Header())
+	streamRuntime.limiter = sse.NewOutputLimiter(limits)
+	streamRuntime.reasoning = util.NewReasoningPresenter(reasoningMode)
+	streamRuntime.setToolCallReader(tools)
+	consumeChatStream(r, resp.Body, thinkingEnabled, streamRuntime)
 }
@@ -0,0 +1,142 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/deepseek"
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/sse"
+	streamengine "ds2api/internal/stream"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
+)
+
+// handleChatChoices serves n > 1 by running one upstream completion per
+// choice. If every branch fails the request fails with the first branch's
+// error; otherwise a failed branch is reported in place with
+// finish_reason "error" and the others are returned normally.
+func (h *Handler) handleChatChoices(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, stdReq util.StandardRequest) {
+	branches := upstream.OpenN(r.Context(), h.DS, h.Auth, a, stdReq, stdReq.Choices)
+	defer upstream.ReleaseForked(h.Auth, branches)
+	defer upstream.CloseBranches(branches)
+
+	if err := upstream.FirstError(branches); err != nil {
+		writeOpenAIUpstreamError(w, a, err)
+		return
+	}
+	completionID := ""
+	for _, b := range branches {
+		if b.Err == nil {
+			completionID = b.Completion.SessionID
+			break
+		}
+	}
+	tools := h.toolCallReader(r.Context(), a, stdReq)
+	if stdReq.Stream {
+		h.streamChatChoices(w, r, branches, completionID, stdReq, tools)
+		return
+	}
+
+	choices := make([]map[string]any, len(branches))
+	outputs := make([]openaifmt.ChoiceOutput, len(branches))
+	var wg sync.WaitGroup
+	for i := range branches {
+		b := branches[i]
+		if message := b.Failure(); message != "" {
+			choices[i] = openaifmt.BuildChatFailedChoice(i, message)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, resp *http.Response) {
+			defer wg.Done()
+			result := sse.CollectStreamWithLimits(resp, stdReq.Thinking, false, sse.LimitsFromRequest(stdReq))
+			text, citations := sse.ResolveCitations(result.Text, result.SearchResults, stdReq.Search)
+			outputs[i] = openaifmt.ChoiceOutput{Thinking: result.Thinking, Text: text}
+			choices[i] = buildChatChoiceWithCitations(i, result.Thinking, text, stdReq.ToolNames, tools, chatFinishReason(result.Limit), citations, stdReq.ReasoningMode)
+		}(i, b.Completion.Resp)
+	}
+	wg.Wait()
+	usage := openaifmt.BuildChatUsageForChoices(stdReq.FinalPrompt, outputs)
+	writeJSON(w, http.StatusOK, openaifmt.BuildChatCompletionFromChoices(completionID, stdReq.ResponseModel, choices, usage))
+}
+
+func (h *Handler) streamChatChoices(w http.ResponseWriter, r *http.Request, branches []upstream.Branch, completionID string, stdReq util.StandardRequest, tools util.ToolCallReader) {
+	setChatStreamHeaders(w)
+	lw := &upstream.LockedWriter{ResponseWriter: w}
+	rc := http.NewResponseController(lw)
+	_, canFlush := w.(http.Flusher)
+	created := time.Now().Unix()
+	bufferToolContent := len(stdReq.ToolNames) > 0 && h.toolcallFeatureMatchEnabled()
+	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence()
+
+	runtimes := make([]*chatStreamRuntime, len(branches))
+	var wg sync.WaitGroup
+	for i, b := range branches {
+		rt := newChatStreamRuntime(lw, rc, canFlush, completionID, created, stdReq.ResponseModel, stdReq.FinalPrompt,
+			stdReq.Thinking, stdReq.Search, stdReq.ToolNames, bufferToolContent, emitEarlyToolDeltas)
+		rt.choiceIndex = i
+		rt.multiChoice = true
+		rt.limiter = sse.NewOutputLimiter(sse.LimitsFromRequest(stdReq))
+		rt.reasoning = util.NewReasoningPresenter(stdReq.ReasoningMode)
+		rt.setToolCallReader(tools)
+		runtimes[i] = rt
+		if message := b.Failure(); message != "" {
+			failed := openaifmt.BuildChatStreamFinishChoice(i, "error")
+			failed["error"] = map[string]any{"message": message, "type": "api_error"}
+			rt.sendChunk(openaifmt.BuildChatStreamChunk(completionID, created, stdReq.ResponseModel, []map[string]any{failed}, nil))
+			continue
+		}
+		wg.Add(1)
+		go func(rt *chatStreamRuntime, body io.Reader) {
+			defer wg.Done()
+			consumeChatStream(r, body, stdReq.Thinking, rt)
+		}(rt, b.Completion.Resp.Body)
+	}
+	wg.Wait()
+
+	outputs := make([]openaifmt.ChoiceOutput, 0, len(runtimes))
+	for _, rt := range runtimes {
+		outputs = append(outputs, openaifmt.ChoiceOutput{Thinking: rt.thinking.String(), Text: rt.text.String()})
+	}
+	usage := openaifmt.BuildChatUsageForChoices(stdReq.FinalPrompt, outputs)
+	tail := runtimes[0]
+	tail.sendChunk(openaifmt.BuildChatStreamChunk(completionID, created, stdReq.ResponseModel, []map[string]any{}, usage))
+	tail.sendDone()
+}
+
+func consumeChatStream(r *http.Request, body io.Reader, thinkingEnabled bool, rt *chatStreamRuntime) {
+	initialType := "text"
+	if thinkingEnabled {
+		initialType = "thinking"
+	}
+	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
+		Context:             r.Context(),
+		Body:                body,
+		ThinkingEnabled:     thinkingEnabled,
+		InitialType:         initialType,
+		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
+		IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
+		MaxKeepAliveNoInput: deepseek.MaxKeepaliveCount,
+	}, streamengine.ConsumeHooks{
+		OnKeepAlive: rt.sendKeepAlive,
+		OnParsed:    rt.onParsed,
+		OnFinalize: func(reason streamengine.StopReason, _ error) {
+			if string(reason) == "content_filter" {
+				rt.finalize("content_filter")
+				return
+			}
+			rt.finalize(chatFinishReason(rt.limiter.Reason()))
+		},
+	})
+}
+
+func setChatStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+}
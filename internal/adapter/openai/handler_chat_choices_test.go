@@ -0,0 +1,181 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+// choicesDSStub answers every completion with a fresh body so parallel
+// branches never share a reader. failCall (1-based) makes one call fail and
+// emptyErrorCall makes one answer with a 502 and no body.
+type choicesDSStub struct {
+	calls          *int32
+	failCall       int32
+	emptyErrorCall int32
+}
+
+func (m choicesDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m choicesDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m choicesDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	n := atomic.AddInt32(m.calls, 1)
+	if n == m.failCall {
+		return nil, errors.New("upstream down")
+	}
+	if n == m.emptyErrorCall {
+		return &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"answer"}`, "data: [DONE]"), nil
+}
+
+func serveChoicesRequest(t *testing.T, ds choicesDSStub, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: ds}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestChatCompletionsNonStreamFansOutChoices(t *testing.T) {
+	var calls int32
+	rec := serveChoicesRequest(t, choicesDSStub{calls: &calls},
+		`{"model":"deepseek-chat","messages":[{"role":"user","content":"hi"}],"n":3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 upstream completions, got %d", calls)
+	}
+	var out struct {
+		Choices []struct {
+			Index        int            `json:"index"`
+			Message      map[string]any `json:"message"`
+			FinishReason string         `json:"finish_reason"`
+		} `json:"choices"`
+		Usage map[string]any `json:"usage"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(out.Choices) != 3 {
+		t.Fatalf("expected 3 choices, got %#v", out.Choices)
+	}
+	for i, c := range out.Choices {
+		if c.Index != i || c.Message["content"] != "answer" || c.FinishReason != "stop" {
+			t.Fatalf("unexpected choice %d: %#v", i, c)
+		}
+	}
+	if out.Usage["completion_tokens"].(float64) < 3 {
+		t.Fatalf("expected usage summed across choices, got %#v", out.Usage)
+	}
+}
+
+func TestChatCompletionsNonStreamPartialFailureKeepsIndex(t *testing.T) {
+	var calls int32
+	rec := serveChoicesRequest(t, choicesDSStub{calls: &calls, failCall: 2},
+		`{"model":"deepseek-chat","messages":[{"role":"user","content":"hi"}],"n":2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	choices, _ := out["choices"].([]any)
+	if len(choices) != 2 {
+		t.Fatalf("expected 2 choices, got %#v", out)
+	}
+	reasons := map[string]int{}
+	for _, c := range choices {
+		reasons[c.(map[string]any)["finish_reason"].(string)]++
+	}
+	if reasons["stop"] != 1 || reasons["error"] != 1 {
+		t.Fatalf("expected one stop and one error choice, got %#v", reasons)
+	}
+}
+
+func TestChatCompletionsNonStreamEmptyErrorBodyFailsChoice(t *testing.T) {
+	var calls int32
+	rec := serveChoicesRequest(t, choicesDSStub{calls: &calls, emptyErrorCall: 2},
+		`{"model":"deepseek-chat","messages":[{"role":"user","content":"hi"}],"n":2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	choices, _ := out["choices"].([]any)
+	reasons := map[string]int{}
+	for _, c := range choices {
+		reasons[c.(map[string]any)["finish_reason"].(string)]++
+	}
+	if reasons["stop"] != 1 || reasons["error"] != 1 || !strings.Contains(rec.Body.String(), "upstream status 502") {
+		t.Fatalf("expected the empty 502 reported as an error choice, got %s", rec.Body.String())
+	}
+}
+
+func TestChatCompletionsStreamInterleavesChoiceIndexes(t *testing.T) {
+	var calls int32
+	rec := serveChoicesRequest(t, choicesDSStub{calls: &calls},
+		`{"model":"deepseek-chat","messages":[{"role":"user","content":"hi"}],"n":2,"stream":true}`)
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected a single [DONE], body=%s", rec.Body.String())
+	}
+	finished := map[int]bool{}
+	content := map[int]string{}
+	for _, f := range frames {
+		choices, _ := f["choices"].([]any)
+		for _, c := range choices {
+			choice := c.(map[string]any)
+			idx := int(choice["index"].(float64))
+			if delta, ok := choice["delta"].(map[string]any); ok {
+				if s, ok := delta["content"].(string); ok {
+					content[idx] += s
+				}
+			}
+			if choice["finish_reason"] == "stop" {
+				finished[idx] = true
+			}
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if !finished[i] || content[i] != "answer" {
+			t.Fatalf("choice %d incomplete: finished=%v content=%q", i, finished[i], content[i])
+		}
+	}
+	last := frames[len(frames)-1]
+	if _, ok := last["usage"]; !ok {
+		t.Fatalf("expected trailing usage chunk, got %#v", last)
+	}
+}
+
+func TestChatCompletionsRejectsOutOfRangeN(t *testing.T) {
+	var calls int32
+	rec := serveChoicesRequest(t, choicesDSStub{calls: &calls},
+		fmt.Sprintf(`{"model":"deepseek-chat","messages":[{"role":"user","content":"hi"}],"n":%d}`, 99))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", calls)
+	}
+}
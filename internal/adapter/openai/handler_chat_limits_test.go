@@ -0,0 +1,96 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+func TestHandleStreamStopSequenceAcrossChunks(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"alpha EN"}`,
+		`data: {"p":"response/content","v":"D beta"}`,
+		`data: {"p":"response/content","v":" gamma"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStreamWithLimits(rec, req, resp, "cid-stop", "deepseek-chat", "prompt", false, false, nil, "", sse.OutputLimits{StopSequences: []string{"END"}}, util.ToolCallReader{})
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	content := strings.Builder{}
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			if c, ok := delta["content"].(string); ok {
+				content.WriteString(c)
+			}
+		}
+	}
+	if got := content.String(); got != "alpha " {
+		t.Fatalf("expected content cut before stop sequence, got %q body=%s", got, rec.Body.String())
+	}
+	if streamFinishReason(frames) != "stop" {
+		t.Fatalf("expected finish_reason=stop, body=%s", rec.Body.String())
+	}
+}
+
+func TestHandleNonStreamStopSequenceTruncatesContent(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"one\n\ntwo"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+
+	h.handleNonStreamWithLimits(rec, context.Background(), resp, "cid-stop", "deepseek-chat", "prompt", false, false, nil, "", sse.OutputLimits{StopSequences: []string{"\n\n"}}, util.ToolCallReader{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	choices, _ := out["choices"].([]any)
+	choice, _ := choices[0].(map[string]any)
+	message, _ := choice["message"].(map[string]any)
+	if message["content"] != "one" {
+		t.Fatalf("expected content cut before stop sequence, got %#v", message["content"])
+	}
+	if choice["finish_reason"] != "stop" {
+		t.Fatalf("expected finish_reason=stop, got %#v", choice["finish_reason"])
+	}
+}
+
+func TestHandleStreamMaxTokensFinishesWithLength(t *testing.T) {
+	h := &Handler{}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"abcdefgh"}`,
+		`data: {"p":"response/content","v":"ijklmnop"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStreamWithLimits(rec, req, resp, "cid-len", "deepseek-chat", "prompt", false, false, nil, "", sse.OutputLimits{MaxTokens: 3}, util.ToolCallReader{})
+
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	if streamFinishReason(frames) != "length" {
+		t.Fatalf("expected finish_reason=length, body=%s", rec.Body.String())
+	}
+	last := frames[len(frames)-1]
+	usage, _ := last["usage"].(map[string]any)
+	if usage["completion_tokens"] != float64(3) {
+		t.Fatalf("expected completion_tokens to stay within max_tokens, got %#v", usage)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"ds2api/internal/auth"
+	"ds2api/internal/coalesce"
 	"ds2api/internal/config"
+	"ds2api/internal/respcache"
 	"ds2api/internal/util"
 )
 
@@ -21,11 +23,19 @@ type Handler struct {
 	Store ConfigReader
 	Auth  AuthResolver
 	DS    DeepSeekCaller
+	// Cache answers repeated deterministic requests and Flights lets
+	// concurrent identical ones share a completion; nil disables either.
+	Cache   *respcache.Cache
+	Flights *coalesce.Group
 
 	leaseMu      sync.Mutex
 	streamLeases map[string]streamLease
 	responsesMu  sync.Mutex
 	responses    *responseStore
+	runsMu       sync.Mutex
+	runs         map[string]*responseRun
+	streamsMu    sync.Mutex
+	streams      map[string]*responseEventLog
 }
 
 type streamLease struct {
@@ -39,11 +49,13 @@ func RegisterRoutes(r chi.Router, h *Handler) {
 	r.Post("/v1/chat/completions", h.ChatCompletions)
 	r.Post("/v1/responses", h.Responses)
 	r.Get("/v1/responses/{response_id}", h.GetResponseByID)
+	r.Delete("/v1/responses/{response_id}", h.DeleteResponse)
+	r.Post("/v1/responses/{response_id}/cancel", h.CancelResponse)
 	r.Post("/v1/embeddings", h.Embeddings)
 }
 
 func (h *Handler) ListModels(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, config.OpenAIModelsResponse())
+	writeJSON(w, http.StatusOK, config.OpenAIModelsResponse(h.Store))
 }
 
 func (h *Handler) GetModel(w http.ResponseWriter, r *http.Request) {
@@ -2,19 +2,20 @@ package openai
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
 
 	"github.com/google/uuid"
 
+	"ds2api/internal/prompt"
+	"ds2api/internal/toolsieve"
 	"ds2api/internal/util"
 )
 
-func injectToolPrompt(messages []map[string]any, tools []any, policy util.ToolChoicePolicy) ([]map[string]any, []string) {
+func injectToolPrompt(messages []map[string]any, tools []any, policy util.ToolChoicePolicy, instructions util.ToolInstructions) ([]map[string]any, []string) {
 	if policy.IsNone() {
 		return messages, nil
 	}
-	toolSchemas := make([]string, 0, len(tools))
+	specs := make([]prompt.ToolSpec, 0, len(tools))
 	names := make([]string, 0, len(tools))
 	isAllowed := func(name string) bool {
 		if strings.TrimSpace(name) == "" {
@@ -48,19 +49,12 @@ func injectToolPrompt(messages []map[string]any, tools []any, policy util.ToolCh
 			desc = "No description available"
 		}
 		b, _ := json.Marshal(schema)
-		toolSchemas = append(toolSchemas, fmt.Sprintf("Tool: %s\nDescription: %s\nParameters: %s", name, desc, string(b)))
+		specs = append(specs, prompt.ToolSpec{Name: name, Description: desc, Parameters: string(b)})
 	}
-	if len(toolSchemas) == 0 {
+	if len(specs) == 0 {
 		return messages, names
 	}
-	toolPrompt := "You have access to these tools:\n\n" + strings.Join(toolSchemas, "\n\n") + "\n\nWhen you need to use tools, output ONLY this JSON format (no other text):\n{\"tool_calls\": [{\"name\": \"tool_name\", \"input\": {\"param\": \"value\"}}]}\n\nHistory markers in conversation:\n- [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] means a tool call you already made earlier.\n- [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] means the runtime returned a tool result (not user input).\n\nIMPORTANT:\n1) If calling tools, output ONLY the JSON. The response must start with { and end with }.\n2) After receiving a tool result, you MUST use it to produce the final answer.\n3) Only call another tool when the previous result is missing required data or returned an error.\n4) Do not repeat a tool call that is already satisfied by an existing [TOOL_RESULT_HISTORY] block."
-	if policy.Mode == util.ToolChoiceRequired {
-		toolPrompt += "\n5) For this response, you MUST call at least one tool from the allowed list."
-	}
-	if policy.Mode == util.ToolChoiceForced && strings.TrimSpace(policy.ForcedName) != "" {
-		toolPrompt += "\n5) For this response, you MUST call exactly this tool name: " + strings.TrimSpace(policy.ForcedName)
-		toolPrompt += "\n6) Do not call any other tool."
-	}
+	toolPrompt := instructions.Render(specs, policy)
 
 	for i := range messages {
 		if messages[i]["role"] == "system" {
@@ -73,7 +67,7 @@ func injectToolPrompt(messages []map[string]any, tools []any, policy util.ToolCh
 	return messages, names
 }
 
-func formatIncrementalStreamToolCallDeltas(deltas []toolCallDelta, ids map[int]string) []map[string]any {
+func formatIncrementalStreamToolCallDeltas(deltas []toolsieve.CallDelta, ids map[int]string) []map[string]any {
 	if len(deltas) == 0 {
 		return nil
 	}
@@ -107,7 +101,7 @@ func formatIncrementalStreamToolCallDeltas(deltas []toolCallDelta, ids map[int]s
 	return out
 }
 
-func filterIncrementalToolCallDeltasByAllowed(deltas []toolCallDelta, allowedNames []string, seenNames map[int]string) []toolCallDelta {
+func filterIncrementalToolCallDeltasByAllowed(deltas []toolsieve.CallDelta, allowedNames []string, seenNames map[int]string) []toolsieve.CallDelta {
 	if len(deltas) == 0 {
 		return nil
 	}
@@ -120,7 +114,7 @@ func filterIncrementalToolCallDeltasByAllowed(deltas []toolCallDelta, allowedNam
 		}
 		return nil
 	}
-	out := make([]toolCallDelta, 0, len(deltas))
+	out := make([]toolsieve.CallDelta, 0, len(deltas))
 	for _, d := range deltas {
 		if d.Name != "" {
 			if _, ok := allowed[d.Name]; !ok {
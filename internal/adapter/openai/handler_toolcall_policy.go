@@ -1,6 +1,13 @@
 package openai
 
-import "strings"
+import (
+	"context"
+	"strings"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
+)
 
 func applyOpenAIChatPassThrough(req map[string]any, payload map[string]any) {
 	for k, v := range collectOpenAIChatPassThrough(req) {
@@ -13,7 +20,29 @@ func (h *Handler) toolcallFeatureMatchEnabled() bool {
 		return true
 	}
 	mode := strings.TrimSpace(strings.ToLower(h.Store.ToolcallMode()))
-	return mode == "" || mode == "feature_match"
+	if mode == "off" {
+		return false
+	}
+	_, err := util.ParseToolcallMode(mode)
+	return err == nil
+}
+
+// toolcallDialects returns the tool-call dialects toolcall.mode enables.
+func (h *Handler) toolcallDialects() util.ToolDialects {
+	if h == nil || h.Store == nil {
+		return util.ToolDialectJSON
+	}
+	return util.ToolDialectsForMode(h.Store.ToolcallMode())
+}
+
+// toolCallReader returns the reader for stdReq's tool calls. Invalid calls
+// are repaired on a's account, toolcall.repair_attempts times at most.
+func (h *Handler) toolCallReader(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest) util.ToolCallReader {
+	attempts := 0
+	if h != nil && h.Store != nil {
+		attempts = h.Store.ToolcallRepairAttempts()
+	}
+	return upstream.ToolCallReader(ctx, h.DS, a, stdReq, h.toolcallDialects(), attempts)
 }
 
 func (h *Handler) toolcallEarlyEmitHighConfidence() bool {
@@ -10,17 +10,27 @@ func buildOpenAIFinalPrompt(messagesRaw []any, toolsRaw any, traceID string) (st
 }
 
 func buildOpenAIFinalPromptWithPolicy(messagesRaw []any, toolsRaw any, traceID string, toolPolicy util.ToolChoicePolicy) (string, []string) {
+	messages, toolNames := buildOpenAIPromptMessages(messagesRaw, toolsRaw, traceID, toolPolicy, util.ToolInstructions{})
+	return deepseek.MessagesPrepare(messages), toolNames
+}
+
+// buildOpenAIPromptMessages returns the prompt-ready messages, tool prompt
+// included, before they are joined into the final prompt.
+func buildOpenAIPromptMessages(messagesRaw []any, toolsRaw any, traceID string, toolPolicy util.ToolChoicePolicy, instructions util.ToolInstructions) ([]map[string]any, []string) {
 	messages := normalizeOpenAIMessagesForPrompt(messagesRaw, traceID)
 	toolNames := []string{}
 	if tools, ok := toolsRaw.([]any); ok && len(tools) > 0 {
-		messages, toolNames = injectToolPrompt(messages, tools, toolPolicy)
+		messages, toolNames = injectToolPrompt(messages, tools, toolPolicy, instructions)
 	}
-	return deepseek.MessagesPrepare(messages), toolNames
+	return messages, toolNames
 }
 
-// BuildPromptForAdapter exposes the OpenAI-compatible prompt building flow so
-// other protocol adapters (for example Gemini) can reuse the same tool/history
-// normalization logic and remain behavior-compatible with chat/completions.
-func BuildPromptForAdapter(messagesRaw []any, toolsRaw any, traceID string) (string, []string) {
-	return buildOpenAIFinalPrompt(messagesRaw, toolsRaw, traceID)
+// BuildPromptMessagesForAdapter exposes the OpenAI-compatible prompt building
+// flow so other protocol adapters (for example Gemini) can reuse the same
+// tool/history normalization logic and remain behavior-compatible with
+// chat/completions. The messages are returned unjoined so the adapter can fit
+// them into the context window first; instructions carries the adapter's tool
+// prompt.
+func BuildPromptMessagesForAdapter(messagesRaw []any, toolsRaw any, traceID string, instructions util.ToolInstructions) ([]map[string]any, []string) {
+	return buildOpenAIPromptMessages(messagesRaw, toolsRaw, traceID, util.DefaultToolChoicePolicy(), instructions)
 }
@@ -0,0 +1,125 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ds2api/internal/config"
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+var reasoningUpstreamLines = []string{
+	`data: {"p":"response/thinking_content","v":"先算一下"}`,
+	`data: {"p":"response/thinking_content","v":"\n结论是4"}`,
+	`data: {"p":"response/content","v":"答案是4"}`,
+	`data: [DONE]`,
+}
+
+func collectChatStreamDeltas(t *testing.T, body string) (reasoning, content string) {
+	t.Helper()
+	frames, _ := parseSSEDataFrames(t, body)
+	var r, c strings.Builder
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			delta, _ := item.(map[string]any)["delta"].(map[string]any)
+			if s, ok := delta["reasoning_content"].(string); ok {
+				r.WriteString(s)
+			}
+			if s, ok := delta["content"].(string); ok {
+				c.WriteString(s)
+			}
+		}
+	}
+	return r.String(), c.String()
+}
+
+func TestHandleStreamReasoningModes(t *testing.T) {
+	cases := []struct {
+		mode      util.ReasoningMode
+		reasoning string
+		content   string
+	}{
+		{util.ReasoningSeparate, "先算一下\n结论是4", "答案是4"},
+		{util.ReasoningInlineThinkTags, "", "<think>先算一下\n结论是4</think>\n\n答案是4"},
+		{util.ReasoningHidden, "", "答案是4"},
+		{util.ReasoningSummaryOnly, "结论是4", "答案是4"},
+	}
+	for _, tc := range cases {
+		h := &Handler{}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		h.handleStreamWithLimits(rec, req, makeSSEHTTPResponse(reasoningUpstreamLines...), "cid", "deepseek-reasoner", "prompt", true, false, nil, tc.mode, sse.OutputLimits{}, util.ToolCallReader{})
+
+		reasoning, content := collectChatStreamDeltas(t, rec.Body.String())
+		if reasoning != tc.reasoning || content != tc.content {
+			t.Fatalf("%s: got reasoning=%q content=%q", tc.mode, reasoning, content)
+		}
+	}
+}
+
+func TestHandleNonStreamReasoningModeInline(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	h.handleNonStreamWithLimits(rec, context.Background(), makeSSEHTTPResponse(reasoningUpstreamLines...), "cid", "deepseek-reasoner", "prompt", true, false, nil, util.ReasoningInlineThinkTags, sse.OutputLimits{}, util.ToolCallReader{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	choices, _ := out["choices"].([]any)
+	message, _ := choices[0].(map[string]any)["message"].(map[string]any)
+	if message["content"] != "<think>先算一下\n结论是4</think>\n\n答案是4" {
+		t.Fatalf("expected inline think tags, got %#v", message["content"])
+	}
+	if _, ok := message["reasoning_content"]; ok {
+		t.Fatalf("expected no reasoning_content in inline mode, got %#v", message)
+	}
+	usage, _ := out["usage"].(map[string]any)
+	details, _ := usage["completion_tokens_details"].(map[string]any)
+	if details["reasoning_tokens"] == float64(0) {
+		t.Fatalf("expected reasoning tokens to still be counted, got %#v", usage)
+	}
+}
+
+func TestHandleResponsesNonStreamReasoningModeSummary(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	h.handleResponsesNonStream(rec, makeSSEHTTPResponse(reasoningUpstreamLines...), "owner", "resp_1", "deepseek-reasoner", "prompt", true, false, nil, util.DefaultToolChoicePolicy(), "", util.ReasoningSummaryOnly, sse.OutputLimits{}, util.ToolCallReader{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	if out["output_text"] != "答案是4" {
+		t.Fatalf("unexpected output_text %#v", out["output_text"])
+	}
+	if !strings.Contains(rec.Body.String(), "结论是4") || strings.Contains(rec.Body.String(), "先算一下") {
+		t.Fatalf("expected only the reasoning summary, got %s", rec.Body.String())
+	}
+}
+
+func TestNormalizeOpenAIChatRequestReasoningMode(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"compat":{"reasoning_mode":"hidden"}}`)
+	store := config.LoadStore()
+	req := map[string]any{
+		"model":    "deepseek-reasoner",
+		"messages": []any{map[string]any{"role": "user", "content": "hello"}},
+	}
+	n, err := normalizeOpenAIChatRequest(store, req, config.RouteInput{}, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if n.ReasoningMode != util.ReasoningHidden {
+		t.Fatalf("expected config default, got %q", n.ReasoningMode)
+	}
+
+	req["reasoning_mode"] = "inline_think_tags"
+	n, err = normalizeOpenAIChatRequest(store, req, config.RouteInput{}, "")
+	if err != nil || n.ReasoningMode != util.ReasoningInlineThinkTags {
+		t.Fatalf("expected request override, got %q err=%v", n.ReasoningMode, err)
+	}
+
+	req["reasoning_mode"] = "loud"
+	if _, err := normalizeOpenAIChatRequest(store, req, config.RouteInput{}, ""); err == nil {
+		t.Fatal("expected invalid reasoning_mode to be rejected")
+	}
+}
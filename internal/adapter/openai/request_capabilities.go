@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// applyOpenAIChatCapabilities lets the request override the model defaults:
+// web_search_options turns search on and reasoning_effort toggles thinking.
+func applyOpenAIChatCapabilities(req map[string]any, thinking, search bool) (bool, bool) {
+	if _, ok := req["web_search_options"].(map[string]any); ok {
+		search = true
+	}
+	if enabled, ok := reasoningEffortEnabled(req["reasoning_effort"]); ok {
+		thinking = enabled
+	}
+	return thinking, search
+}
+
+// applyOpenAIResponsesCapabilities does the same for the Responses API, where
+// search is a built-in tool and the effort lives under reasoning.effort.
+func applyOpenAIResponsesCapabilities(req map[string]any, thinking, search bool) (bool, bool) {
+	tools, _ := req["tools"].([]any)
+	for _, t := range tools {
+		if tool, ok := t.(map[string]any); ok && isResponsesWebSearchType(asString(tool["type"])) {
+			search = true
+		}
+	}
+	if reasoning, ok := req["reasoning"].(map[string]any); ok {
+		if enabled, ok := reasoningEffortEnabled(reasoning["effort"]); ok {
+			thinking = enabled
+		}
+	}
+	return thinking, search
+}
+
+// isResponsesWebSearchType matches web_search, web_search_preview and their
+// dated variants.
+func isResponsesWebSearchType(typ string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(typ)), "web_search")
+}
+
+// reasoningEffortEnabled maps an effort level to thinking on or off. Unknown
+// values leave the model default alone.
+func reasoningEffortEnabled(raw any) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(asString(raw))) {
+	case "none", "minimal":
+		return false, true
+	case "low", "medium", "high", "xhigh":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// requestReasoningMode reads reasoning_mode, defaulting to the configured
+// compat default for the caller's key.
+func requestReasoningMode(store ConfigReader, req map[string]any, route config.RouteInput) (util.ReasoningMode, error) {
+	fallback := ""
+	if store != nil {
+		fallback = store.CompatReasoningMode(route.Key)
+	}
+	return util.ReasoningModeFrom(req["reasoning_mode"], fallback)
+}
@@ -0,0 +1,173 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/respcache"
+	"ds2api/internal/util"
+)
+
+type responseCacheConfigStub struct{}
+
+func (responseCacheConfigStub) ResponseCacheConfig() config.ResponseCacheConfig {
+	return config.ResponseCacheConfig{Enabled: true, Backend: "memory", TTLSeconds: 60, MaxBytes: 1 << 20}
+}
+
+// countingDSStub answers every completion with the same text, or with lines
+// when set, and counts the completions opened.
+type countingDSStub struct {
+	calls *int
+	lines []string
+}
+
+func (m countingDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m countingDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m countingDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	*m.calls++
+	if len(m.lines) > 0 {
+		return makeOpenAISSEHTTPResponse(m.lines...), nil
+	}
+	return makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"cached answer"}`, "data: [DONE]"), nil
+}
+
+func TestChatCompletionsReplaysCachedResponse(t *testing.T) {
+	calls := 0
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: countingDSStub{calls: &calls}, Cache: respcache.New(responseCacheConfigStub{})}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	post := func(stream bool, temperature any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]any{
+			"model":       "deepseek-chat",
+			"stream":      stream,
+			"temperature": temperature,
+			"messages":    []any{map[string]any{"role": "user", "content": "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "Bearer direct-token")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(false, 0); rec.Header().Get(respcache.Header) != "miss" {
+		t.Fatalf("expected the first request to miss, got %q %s", rec.Header().Get(respcache.Header), rec.Body.String())
+	}
+	rec := post(false, 0)
+	if calls != 1 || rec.Header().Get(respcache.Header) != "hit" {
+		t.Fatalf("expected a hit without a second upstream call, got %d calls, header %q", calls, rec.Header().Get(respcache.Header))
+	}
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	usage, _ := out["usage"].(map[string]any)
+	details, _ := usage["prompt_tokens_details"].(map[string]any)
+	if details["cached_tokens"] != usage["prompt_tokens"] || !strings.Contains(rec.Body.String(), "cached answer") {
+		t.Fatalf("expected the cached answer with cached prompt tokens, got %s", rec.Body.String())
+	}
+
+	rec = post(true, 0)
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if calls != 1 || !done || len(frames) < 2 || !strings.Contains(rec.Body.String(), "cached answer") {
+		t.Fatalf("expected the hit replayed as a stream, got %d calls: %s", calls, rec.Body.String())
+	}
+
+	if rec := post(false, 0.8); calls != 2 || rec.Header().Get(respcache.Header) != "" {
+		t.Fatalf("expected sampled requests to bypass the cache, got %d calls, header %q", calls, rec.Header().Get(respcache.Header))
+	}
+}
+
+func TestChatCompletionsCacheReplayReappliesStopSequences(t *testing.T) {
+	calls := 0
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: countingDSStub{calls: &calls}, Cache: respcache.New(responseCacheConfigStub{})}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	post := func(extra map[string]any) (string, string) {
+		req := map[string]any{
+			"model":    "deepseek-chat",
+			"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+		}
+		for k, v := range extra {
+			req[k] = v
+		}
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+		httpReq.Header.Set("Authorization", "Bearer direct-token")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httpReq)
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		choice, _ := out["choices"].([]any)[0].(map[string]any)
+		content, _ := choice["message"].(map[string]any)["content"].(string)
+		return rec.Header().Get(respcache.Header), content
+	}
+
+	stop := map[string]any{"stop": []any{" answer"}}
+	if header, content := post(stop); header != "miss" || content != "cached" {
+		t.Fatalf("expected a miss cut at the stop sequence, got %q %q", header, content)
+	}
+	if header, content := post(stop); header != "hit" || content != "cached" || calls != 1 {
+		t.Fatalf("expected the replay to apply the stop sequence again, got %q %q after %d calls", header, content, calls)
+	}
+	if header, content := post(nil); header != "miss" || content != "cached answer" || calls != 2 {
+		t.Fatalf("expected a request without stop sequences to keep its own entry, got %q %q after %d calls", header, content, calls)
+	}
+	if header, _ := post(map[string]any{"tools": []any{map[string]any{"type": "function", "function": map[string]any{"name": "search"}}}}); header != "" || calls != 3 {
+		t.Fatalf("expected requests with tools to bypass the cache, got %q after %d calls", header, calls)
+	}
+}
+
+func TestChatCompletionsCacheReplayReappliesReasoningMode(t *testing.T) {
+	calls := 0
+	cache := respcache.New(responseCacheConfigStub{})
+	post := func(mode util.ReasoningMode) (string, map[string]any) {
+		h := &Handler{Store: mockOpenAIConfig{wideInput: true, reasoning: string(mode)}, Auth: streamStatusAuthStub{}, DS: countingDSStub{calls: &calls, lines: reasoningUpstreamLines}, Cache: cache}
+		r := chi.NewRouter()
+		RegisterRoutes(r, h)
+		body, _ := json.Marshal(map[string]any{
+			"model":    "deepseek-reasoner",
+			"messages": []any{map[string]any{"role": "user", "content": "2+2"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "Bearer direct-token")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		choice, _ := out["choices"].([]any)[0].(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		return rec.Header().Get(respcache.Header), message
+	}
+
+	for _, tc := range []struct {
+		mode      util.ReasoningMode
+		reasoning any
+		content   string
+	}{
+		{util.ReasoningSeparate, "先算一下\n结论是4", "答案是4"},
+		{util.ReasoningInlineThinkTags, nil, "<think>先算一下\n结论是4</think>\n\n答案是4"},
+	} {
+		for _, want := range []string{"miss", "hit"} {
+			header, message := post(tc.mode)
+			if header != want || message["reasoning_content"] != tc.reasoning || message["content"] != tc.content {
+				t.Fatalf("%s: expected %s with reasoning %v and content %q, got %s %#v", tc.mode, want, tc.reasoning, tc.content, header, message)
+			}
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected one upstream call per reasoning mode, got %d", calls)
+	}
+}
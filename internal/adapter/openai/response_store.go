@@ -73,6 +73,22 @@ func (s *responseStore) get(owner, id string) (map[string]any, bool) {
 	return cloneAnyMap(item.Value), true
 }
 
+// delete removes a stored response and reports whether it existed.
+func (s *responseStore) delete(owner, id string) bool {
+	if s == nil || owner == "" || id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(time.Now())
+	key := responseStoreKey(owner, id)
+	if _, ok := s.items[key]; !ok {
+		return false
+	}
+	delete(s.items, key)
+	return true
+}
+
 func (s *responseStore) sweepLocked(now time.Time) {
 	for k, v := range s.items {
 		if now.After(v.ExpiresAt) {
@@ -0,0 +1,217 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/auth"
+	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/sse"
+	"ds2api/internal/upstream"
+	"ds2api/internal/util"
+)
+
+// backgroundProgressInterval throttles how often a background run publishes
+// its partial output to the response store.
+const backgroundProgressInterval = 500 * time.Millisecond
+
+// responseRun is a background response that is still generating. done is
+// closed once the run has stored its final object and released its account.
+type responseRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop cancels the run and waits for it to settle. It returns false if ctx
+// ends first.
+func (run *responseRun) stop(ctx context.Context) bool {
+	run.cancel()
+	select {
+	case <-run.done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (h *Handler) trackResponseRun(owner, id string, run *responseRun) {
+	h.runsMu.Lock()
+	defer h.runsMu.Unlock()
+	if h.runs == nil {
+		h.runs = map[string]*responseRun{}
+	}
+	h.runs[responseStoreKey(owner, id)] = run
+}
+
+func (h *Handler) lookupResponseRun(owner, id string) *responseRun {
+	h.runsMu.Lock()
+	defer h.runsMu.Unlock()
+	return h.runs[responseStoreKey(owner, id)]
+}
+
+func (h *Handler) untrackResponseRun(owner, id string) {
+	h.runsMu.Lock()
+	defer h.runsMu.Unlock()
+	delete(h.runs, responseStoreKey(owner, id))
+}
+
+// startBackgroundResponse answers a background: true request with an
+// in_progress response and generates it detached from the HTTP request. The
+// run owns the account lease from here on. With stream: true the caller
+// follows the run's events instead, and disconnecting does not stop it.
+func (h *Handler) startBackgroundResponse(w http.ResponseWriter, r *http.Request, a *auth.RequestAuth, owner string, stdReq util.StandardRequest, traceID string) {
+	responseID := newResponseID()
+	pending := openaifmt.BuildResponseInProgressObject(responseID, stdReq.ResponseModel)
+	pending["background"] = true
+	h.getResponseStore().put(owner, responseID, pending)
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	run := &responseRun{cancel: cancel, done: make(chan struct{})}
+	events := newResponseEventLog(h.responseStreamGrace(), nil)
+	h.trackResponseRun(owner, responseID, run)
+	h.registerResponseEvents(owner, responseID, events)
+	rt, settle := h.newBackgroundRuntime(owner, responseID, stdReq, traceID, cloneAnyMap(pending))
+	rt.events = events
+	rt.sendCreated()
+	go func() {
+		defer close(run.done)
+		defer h.untrackResponseRun(owner, responseID)
+		defer cancel()
+		defer h.Auth.Release(a)
+		defer events.finish()
+		defer settle()
+		h.runBackgroundResponse(ctx, a, stdReq, rt)
+	}()
+	if stdReq.Stream {
+		followResponseEvents(r.Context(), w, events, 0)
+		return
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+// newBackgroundRuntime builds the runtime for a background run. settle must
+// run once the run is over; it records the response as cancelled if it never
+// reached a final state.
+func (h *Handler) newBackgroundRuntime(owner, responseID string, stdReq util.StandardRequest, traceID string, pending map[string]any) (*responsesStreamRuntime, func()) {
+	st := h.getResponseStore()
+	finished := false
+	rt := newResponsesStreamRuntime(
+		discardResponseWriter{},
+		nil,
+		false,
+		responseID,
+		stdReq.ResponseModel,
+		stdReq.FinalPrompt,
+		stdReq.Thinking,
+		stdReq.Search,
+		stdReq.ToolNames,
+		len(stdReq.ToolNames) > 0 && h.toolcallFeatureMatchEnabled(),
+		h.toolcallEarlyEmitHighConfidence(),
+		stdReq.ToolChoice,
+		traceID,
+		func(obj map[string]any) {
+			obj["background"] = true
+			st.put(owner, responseID, obj)
+			finished = true
+		},
+	)
+	rt.limiter = sse.NewOutputLimiter(sse.LimitsFromRequest(stdReq))
+	rt.reasoning = util.NewReasoningPresenter(stdReq.ReasoningMode)
+	var lastProgress time.Time
+	rt.onProgress = func() {
+		if time.Since(lastProgress) < backgroundProgressInterval {
+			return
+		}
+		lastProgress = time.Now()
+		snapshot := cloneAnyMap(pending)
+		snapshot["output_text"] = rt.visibleText.String()
+		st.put(owner, responseID, snapshot)
+	}
+	settle := func() {
+		if finished {
+			return
+		}
+		// Cancelled before upstream finished: keep what was generated so far,
+		// shaped like a completed response.
+		obj := rt.buildCompletedResponseObject(rt.thinking.String(), rt.text.String(), nil)
+		obj["status"] = "cancelled"
+		obj["background"] = true
+		st.put(owner, responseID, obj)
+	}
+	return rt, settle
+}
+
+func (h *Handler) runBackgroundResponse(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest, rt *responsesStreamRuntime) {
+	completion, err := upstream.Open(ctx, h.DS, a, stdReq)
+	if err != nil {
+		if ctx.Err() == nil {
+			_, message := openAIUpstreamError(a, err)
+			rt.fail(message, "api_error")
+		}
+		return
+	}
+	resp := completion.Resp
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		rt.fail(strings.TrimSpace(string(body)), "api_error")
+		return
+	}
+	rt.setToolCallReader(h.toolCallReader(ctx, a, stdReq))
+	consumeResponsesStream(ctx, resp.Body, stdReq.Thinking, rt)
+}
+
+// CancelResponse stops a running background response and returns its stored
+// object. Cancelling a response that already finished is a no-op.
+func (h *Handler) CancelResponse(w http.ResponseWriter, r *http.Request) {
+	owner, id, ok := h.resolveResponseTarget(w, r)
+	if !ok {
+		return
+	}
+	if run := h.lookupResponseRun(owner, id); run != nil && !run.stop(r.Context()) {
+		return
+	}
+	item, ok := h.getResponseStore().get(owner, id)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, "Response not found.")
+		return
+	}
+	if !util.ToBool(item["background"]) {
+		writeOpenAIError(w, http.StatusBadRequest, "Only background responses can be cancelled.")
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// DeleteResponse removes a stored response, cancelling it first if it is
+// still running in the background.
+func (h *Handler) DeleteResponse(w http.ResponseWriter, r *http.Request) {
+	owner, id, ok := h.resolveResponseTarget(w, r)
+	if !ok {
+		return
+	}
+	if run := h.lookupResponseRun(owner, id); run != nil && !run.stop(r.Context()) {
+		return
+	}
+	h.dropResponseEvents(owner, id)
+	if !h.getResponseStore().delete(owner, id) {
+		writeOpenAIError(w, http.StatusNotFound, "Response not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      id,
+		"object":  "response",
+		"deleted": true,
+	})
+}
+
+// discardResponseWriter lets the stream runtime render a background run that
+// has no client attached; only its persisted objects matter.
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return http.Header{} }
+func (discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
@@ -0,0 +1,181 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+type releaseCountingAuthStub struct {
+	streamStatusAuthStub
+	released *atomic.Int32
+}
+
+func (m releaseCountingAuthStub) Release(_ *auth.RequestAuth) {
+	m.released.Add(1)
+}
+
+// blockingDSStub streams one content chunk and then holds the body open
+// until the consumer closes it.
+type blockingDSStub struct {
+	streamStatusDSStub
+}
+
+func (blockingDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("data: {\"p\":\"response/content\",\"v\":\"partial\"}\n\n"))
+	}()
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: pr}, nil
+}
+
+func newBackgroundTestRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	return r
+}
+
+func doResponsesRequest(t *testing.T, router http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response failed: %v body=%s", err, rec.Body.String())
+	}
+	return rec.Code, out
+}
+
+func waitResponseRun(t *testing.T, h *Handler, id string) {
+	t.Helper()
+	run := h.lookupResponseRun("caller:test", id)
+	if run == nil {
+		return
+	}
+	select {
+	case <-run.done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("background run %s did not finish", id)
+	}
+}
+
+func TestResponsesBackgroundRunsDetachedAndIsPolled(t *testing.T) {
+	released := &atomic.Int32{}
+	h := &Handler{
+		Store: mockOpenAIConfig{wideInput: true},
+		Auth:  releaseCountingAuthStub{released: released},
+		DS:    streamStatusDSStub{resp: makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":"hello"}`, "data: [DONE]")},
+	}
+	router := newBackgroundTestRouter(h)
+
+	code, created := doResponsesRequest(t, router, http.MethodPost, "/v1/responses", `{"model":"deepseek-chat","input":"hi","background":true}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%#v", code, created)
+	}
+	if created["status"] != "in_progress" || created["background"] != true {
+		t.Fatalf("expected in_progress background object, got %#v", created)
+	}
+	id, _ := created["id"].(string)
+	waitResponseRun(t, h, id)
+
+	code, polled := doResponsesRequest(t, router, http.MethodGet, "/v1/responses/"+id, "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 on poll, got %d body=%#v", code, polled)
+	}
+	if polled["status"] != "completed" || polled["output_text"] != "hello" || polled["background"] != true {
+		t.Fatalf("unexpected polled response: %#v", polled)
+	}
+	if released.Load() != 1 {
+		t.Fatalf("expected account released once, got %d", released.Load())
+	}
+}
+
+func TestResponsesCancelStopsBackgroundRun(t *testing.T) {
+	released := &atomic.Int32{}
+	h := &Handler{
+		Store: mockOpenAIConfig{wideInput: true},
+		Auth:  releaseCountingAuthStub{released: released},
+		DS:    blockingDSStub{},
+	}
+	router := newBackgroundTestRouter(h)
+
+	_, created := doResponsesRequest(t, router, http.MethodPost, "/v1/responses", `{"model":"deepseek-chat","input":"hi","background":true}`)
+	id, _ := created["id"].(string)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, polled := doResponsesRequest(t, router, http.MethodGet, "/v1/responses/"+id, "")
+		if polled["output_text"] == "partial" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("partial output was never published: %#v", polled)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code, cancelled := doResponsesRequest(t, router, http.MethodPost, "/v1/responses/"+id+"/cancel", "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 on cancel, got %d body=%#v", code, cancelled)
+	}
+	if cancelled["status"] != "cancelled" || cancelled["output_text"] != "partial" {
+		t.Fatalf("unexpected cancelled response: %#v", cancelled)
+	}
+	output, _ := cancelled["output"].([]any)
+	if len(output) != 1 || output[0].(map[string]any)["type"] != "message" || cancelled["usage"] == nil {
+		t.Fatalf("expected cancelled response shaped like a completed one, got %#v", cancelled)
+	}
+	if released.Load() != 1 {
+		t.Fatalf("expected account released after cancel, got %d", released.Load())
+	}
+	if h.lookupResponseRun("caller:test", id) != nil {
+		t.Fatal("expected run to be untracked after cancel")
+	}
+}
+
+func TestResponsesCancelRejectsForegroundResponse(t *testing.T) {
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}}
+	router := newBackgroundTestRouter(h)
+	h.getResponseStore().put("caller:test", "resp_fg", map[string]any{"id": "resp_fg", "status": "completed"})
+
+	code, out := doResponsesRequest(t, router, http.MethodPost, "/v1/responses/resp_fg/cancel", "")
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d body=%#v", code, out)
+	}
+	code, _ = doResponsesRequest(t, router, http.MethodPost, "/v1/responses/resp_missing/cancel", "")
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown response, got %d", code)
+	}
+}
+
+func TestDeleteResponseRemovesStoredObject(t *testing.T) {
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}}
+	router := newBackgroundTestRouter(h)
+	h.getResponseStore().put("caller:test", "resp_del", map[string]any{"id": "resp_del", "status": "completed"})
+
+	code, out := doResponsesRequest(t, router, http.MethodDelete, "/v1/responses/resp_del", "")
+	if code != http.StatusOK || out["deleted"] != true || out["id"] != "resp_del" {
+		t.Fatalf("unexpected delete result: %d %#v", code, out)
+	}
+	code, _ = doResponsesRequest(t, router, http.MethodGet, "/v1/responses/resp_del", "")
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", code)
+	}
+	code, _ = doResponsesRequest(t, router, http.MethodDelete, "/v1/responses/resp_del", "")
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 on second delete, got %d", code)
+	}
+}
@@ -0,0 +1,252 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseEventLogCapacity bounds how many events one response keeps for
+// resumption; older events fall out of the ring.
+const responseEventLogCapacity = 2048
+
+type responseEvent struct {
+	seq   int
+	frame []byte
+}
+
+// responseEventLog buffers the numbered SSE frames of one Responses run. The
+// generation appends to it and every attached client follows it, so a client
+// that lost its connection can replay what it missed and continue live.
+type responseEventLog struct {
+	mu      sync.Mutex
+	events  []responseEvent
+	head    int
+	done    bool
+	endedAt time.Time
+	wake    chan struct{}
+
+	// onIdle stops a run nobody is following any more. It fires once the
+	// last client has been gone for grace; background runs leave it nil.
+	grace     time.Duration
+	clients   int
+	onIdle    func()
+	idleTimer *time.Timer
+}
+
+func newResponseEventLog(grace time.Duration, onIdle func()) *responseEventLog {
+	return &responseEventLog{wake: make(chan struct{}), grace: grace, onIdle: onIdle}
+}
+
+func (l *responseEventLog) append(seq int, frame []byte) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := responseEvent{seq: seq, frame: frame}
+	if len(l.events) < responseEventLogCapacity {
+		l.events = append(l.events, e)
+	} else {
+		l.events[l.head] = e
+		l.head = (l.head + 1) % len(l.events)
+	}
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// finish marks the run as ended; followers drain the log and stop.
+func (l *responseEventLog) finish() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done {
+		return
+	}
+	l.done = true
+	l.endedAt = time.Now()
+	if l.idleTimer != nil {
+		l.idleTimer.Stop()
+	}
+	close(l.wake)
+}
+
+// since returns the buffered events after seq, whether the run has ended, and
+// a channel closed on the next change. ok is false when events after seq have
+// already fallen out of the ring.
+func (l *responseEventLog) since(seq int) (out []responseEvent, done bool, wake <-chan struct{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.events)
+	if n > 0 && l.events[l.head].seq > seq+1 {
+		return nil, l.done, l.wake, false
+	}
+	for i := 0; i < n; i++ {
+		e := l.events[(l.head+i)%n]
+		if e.seq > seq {
+			out = append(out, e)
+		}
+	}
+	return out, l.done, l.wake, true
+}
+
+func (l *responseEventLog) attach() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clients++
+	if l.idleTimer != nil {
+		l.idleTimer.Stop()
+		l.idleTimer = nil
+	}
+}
+
+func (l *responseEventLog) detach() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clients--
+	if l.clients > 0 || l.done || l.onIdle == nil {
+		return
+	}
+	l.idleTimer = time.AfterFunc(l.grace, func() {
+		l.mu.Lock()
+		idle := l.clients == 0 && !l.done
+		l.mu.Unlock()
+		if idle {
+			l.onIdle()
+		}
+	})
+}
+
+func (l *responseEventLog) expired(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.done && now.Sub(l.endedAt) > l.grace
+}
+
+func (h *Handler) responseStreamGrace() time.Duration {
+	grace := 30
+	if h.Store != nil {
+		grace = h.Store.ResponsesStreamGraceSeconds()
+	}
+	return time.Duration(grace) * time.Second
+}
+
+func (h *Handler) registerResponseEvents(owner, id string, l *responseEventLog) {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	if h.streams == nil {
+		h.streams = map[string]*responseEventLog{}
+	}
+	h.sweepResponseEventsLocked(time.Now())
+	h.streams[responseStoreKey(owner, id)] = l
+}
+
+func (h *Handler) lookupResponseEvents(owner, id string) *responseEventLog {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	h.sweepResponseEventsLocked(time.Now())
+	return h.streams[responseStoreKey(owner, id)]
+}
+
+func (h *Handler) dropResponseEvents(owner, id string) {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	delete(h.streams, responseStoreKey(owner, id))
+}
+
+func (h *Handler) sweepResponseEventsLocked(now time.Time) {
+	for k, l := range h.streams {
+		if l.expired(now) {
+			delete(h.streams, k)
+		}
+	}
+}
+
+// resumeResponseStream serves GET /v1/responses/{id}?stream=true: it replays
+// the buffered events after starting_after and then follows the run live if
+// it is still going.
+func (h *Handler) resumeResponseStream(w http.ResponseWriter, r *http.Request, owner, id string) {
+	after := 0
+	if raw := strings.TrimSpace(r.URL.Query().Get("starting_after")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeOpenAIError(w, http.StatusBadRequest, "starting_after must be a non-negative integer.")
+			return
+		}
+		after = n
+	}
+	events := h.lookupResponseEvents(owner, id)
+	if events == nil {
+		if _, ok := h.getResponseStore().get(owner, id); ok {
+			writeOpenAIError(w, http.StatusBadRequest, "Response events are no longer available for streaming.")
+			return
+		}
+		writeOpenAIError(w, http.StatusNotFound, "Response not found.")
+		return
+	}
+	if _, _, _, ok := events.since(after); !ok {
+		writeOpenAIError(w, http.StatusBadRequest, "starting_after is older than the buffered events.")
+		return
+	}
+	followResponseEvents(r.Context(), w, events, after)
+}
+
+// laggedFollowerFrame is the error event sent to a follower whose next event
+// already fell out of the ring, telling it where to resume from.
+func laggedFollowerFrame(seq int) []byte {
+	b, _ := json.Marshal(map[string]any{
+		"type":    "error",
+		"code":    "stream_lagged",
+		"param":   "starting_after",
+		"message": fmt.Sprintf("The stream fell too far behind; resume with starting_after=%d.", seq),
+	})
+	return []byte("event: error\ndata: " + string(b) + "\n\n")
+}
+
+// followResponseEvents writes the events after seq to the client and keeps
+// following the log until the run ends or the client goes away. Headers must
+// not have been written yet.
+func followResponseEvents(ctx context.Context, w http.ResponseWriter, l *responseEventLog, seq int) {
+	setChatStreamHeaders(w)
+	rc := http.NewResponseController(w)
+	_, canFlush := w.(http.Flusher)
+	l.attach()
+	defer l.detach()
+	for {
+		events, done, wake, ok := l.since(seq)
+		if !ok {
+			// Fell behind the ring; the client has to resume explicitly.
+			_, _ = w.Write(laggedFollowerFrame(seq))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			if canFlush {
+				_ = rc.Flush()
+			}
+			return
+		}
+		for _, e := range events {
+			_, _ = w.Write(e.frame)
+			seq = e.seq
+		}
+		if done {
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}
+		if canFlush {
+			_ = rc.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/auth"
+)
+
+func TestResponseEventLogRingDropsOldestEvents(t *testing.T) {
+	l := newResponseEventLog(time.Second, nil)
+	total := responseEventLogCapacity + 5
+	for seq := 1; seq <= total; seq++ {
+		l.append(seq, []byte("x"))
+	}
+	if _, _, _, ok := l.since(0); ok {
+		t.Fatal("expected events before the ring window to be unavailable")
+	}
+	events, done, _, ok := l.since(5)
+	if !ok || done {
+		t.Fatalf("expected replay window from seq 5, ok=%v done=%v", ok, done)
+	}
+	if len(events) != responseEventLogCapacity || events[0].seq != 6 || events[len(events)-1].seq != total {
+		t.Fatalf("unexpected replay range: len=%d first=%d last=%d", len(events), events[0].seq, events[len(events)-1].seq)
+	}
+}
+
+// stallingWriter blocks its first write until released so a test can run
+// the log ahead of a live follower.
+type stallingWriter struct {
+	*httptest.ResponseRecorder
+	entered chan struct{}
+	release chan struct{}
+	once    bool
+}
+
+func (w *stallingWriter) Write(p []byte) (int, error) {
+	if !w.once {
+		w.once = true
+		close(w.entered)
+		<-w.release
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestFollowResponseEventsReportsOverflowToLaggingFollower(t *testing.T) {
+	l := newResponseEventLog(time.Second, nil)
+	l.append(1, []byte("event: a\ndata: {}\n\n"))
+	w := &stallingWriter{ResponseRecorder: httptest.NewRecorder(), entered: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		followResponseEvents(context.Background(), w, l, 0)
+	}()
+	<-w.entered
+	for seq := 2; seq <= responseEventLogCapacity+10; seq++ {
+		l.append(seq, []byte("x"))
+	}
+	close(w.release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the lagging follower to end")
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "event: error") || !strings.Contains(body, `"code":"stream_lagged"`) || !strings.Contains(body, "starting_after=1") {
+		t.Fatalf("expected a stream_lagged error naming the resume point, got %q", body)
+	}
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("expected the stream to end with [DONE], got %q", body)
+	}
+}
+
+func TestResponseEventLogStopsRunAfterGraceWithoutClients(t *testing.T) {
+	idle := make(chan struct{}, 1)
+	l := newResponseEventLog(20*time.Millisecond, func() { idle <- struct{}{} })
+
+	l.attach()
+	l.detach()
+	l.attach()
+	select {
+	case <-idle:
+		t.Fatal("run stopped although a client reattached within the grace period")
+	case <-time.After(60 * time.Millisecond):
+	}
+
+	l.detach()
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("expected run to stop once the grace period passed without clients")
+	}
+}
+
+// pipeDSStub hands out a completion body the test writes to chunk by chunk.
+type pipeDSStub struct {
+	streamStatusDSStub
+	body io.ReadCloser
+}
+
+func (m pipeDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, _ map[string]any, _ string, _ int) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: m.body}, nil
+}
+
+// sseSummary returns the response id from response.created and the last
+// sequence_number seen in an SSE body.
+func sseSummary(t *testing.T, body string) (string, int) {
+	t.Helper()
+	responseID, last := "", 0
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, "data: {") {
+			continue
+		}
+		var payload map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &payload); err != nil {
+			t.Fatalf("decode event failed: %v line=%s", err, line)
+		}
+		if payload["type"] == "response.created" {
+			responseID, _ = payload["id"].(string)
+		}
+		if n, ok := payload["sequence_number"].(float64); ok {
+			last = int(n)
+		}
+	}
+	return responseID, last
+}
+
+func TestResponsesStreamResumesAfterClientDisconnect(t *testing.T) {
+	pr, pw := io.Pipe()
+	h := &Handler{
+		Store: mockOpenAIConfig{wideInput: true, streamGrace: 30},
+		Auth:  streamStatusAuthStub{},
+		DS:    pipeDSStub{body: pr},
+	}
+	router := newBackgroundTestRouter(h)
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", bytes.NewBufferString(`{"model":"deepseek-chat","input":"hi","stream":true}`)).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		router.ServeHTTP(rec, req)
+	}()
+
+	_, _ = pw.Write([]byte("data: {\"p\":\"response/content\",\"v\":\"first \"}\n\n"))
+	var events *responseEventLog
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		h.streamsMu.Lock()
+		for _, l := range h.streams {
+			events = l
+		}
+		h.streamsMu.Unlock()
+		if events != nil {
+			if got, _, _, _ := events.since(0); len(got) >= 2 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first delta never reached the event log")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	disconnect()
+	<-served
+	responseID, seen := sseSummary(t, rec.Body.String())
+	if responseID == "" || seen == 0 {
+		t.Fatalf("expected numbered events before disconnect, body=%s", rec.Body.String())
+	}
+
+	_, _ = pw.Write([]byte("data: {\"p\":\"response/content\",\"v\":\"second\"}\n\ndata: [DONE]\n\n"))
+	_ = pw.Close()
+
+	for {
+		if _, done, _, _ := events.since(0); done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run did not finish after the client disconnected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	resume := httptest.NewRequest(http.MethodGet, "/v1/responses/"+responseID+"?stream=true&starting_after="+strconv.Itoa(seen), nil)
+	resume.Header.Set("Authorization", "Bearer direct-token")
+	resumeRec := httptest.NewRecorder()
+	router.ServeHTTP(resumeRec, resume)
+	body := resumeRec.Body.String()
+	if resumeRec.Code != http.StatusOK {
+		t.Fatalf("expected 200 on resume, got %d body=%s", resumeRec.Code, body)
+	}
+	if strings.Contains(body, "response.created") || strings.Contains(body, `"delta":"first "`) {
+		t.Fatalf("resume replayed events the client already had: %s", body)
+	}
+	if !strings.Contains(body, `"delta":"second"`) || !strings.Contains(body, "event: response.completed") || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("resume missed the tail of the run: %s", body)
+	}
+
+	stale := httptest.NewRequest(http.MethodGet, "/v1/responses/"+responseID+"?stream=true&starting_after=x", nil)
+	stale.Header.Set("Authorization", "Bearer direct-token")
+	staleRec := httptest.NewRecorder()
+	router.ServeHTTP(staleRec, stale)
+	if staleRec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid starting_after, got %d", staleRec.Code)
+	}
+}
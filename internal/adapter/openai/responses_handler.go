@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -14,35 +15,49 @@ import (
 	"ds2api/internal/config"
 	"ds2api/internal/deepseek"
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/respcache"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/upstream"
 	"ds2api/internal/util"
 )
 
 func (h *Handler) GetResponseByID(w http.ResponseWriter, r *http.Request) {
+	owner, id, ok := h.resolveResponseTarget(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("stream") == "true" {
+		h.resumeResponseStream(w, r, owner, id)
+		return
+	}
+	item, ok := h.getResponseStore().get(owner, id)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, "Response not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// resolveResponseTarget authenticates a caller addressing a stored response
+// and returns the store owner and response id, or writes the error.
+func (h *Handler) resolveResponseTarget(w http.ResponseWriter, r *http.Request) (string, string, bool) {
 	a, err := h.Auth.DetermineCaller(r)
 	if err != nil {
 		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
-		return
+		return "", "", false
 	}
-
 	id := strings.TrimSpace(chi.URLParam(r, "response_id"))
 	if id == "" {
 		writeOpenAIError(w, http.StatusBadRequest, "response_id is required.")
-		return
+		return "", "", false
 	}
 	owner := responseStoreOwner(a)
 	if owner == "" {
 		writeOpenAIError(w, http.StatusUnauthorized, "unauthorized")
-		return
+		return "", "", false
 	}
-	st := h.getResponseStore()
-	item, ok := st.get(owner, id)
-	if !ok {
-		writeOpenAIError(w, http.StatusNotFound, "Response not found.")
-		return
-	}
-	writeJSON(w, http.StatusOK, item)
+	return owner, id, true
 }
 
 func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +71,14 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, status, detail)
 		return
 	}
-	defer h.Auth.Release(a)
+	// Streaming and background runs outlive the request, so they take over
+	// the account lease and release it themselves.
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			h.Auth.Release(a)
+		}
+	}()
 	r = r.WithContext(auth.WithAuth(r.Context(), a))
 	owner := responseStoreOwner(a)
 	if owner == "" {
@@ -70,93 +92,104 @@ func (h *Handler) Responses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	traceID := requestTraceID(r)
-	stdReq, err := normalizeOpenAIResponsesRequest(h.Store, req, traceID)
+	stdReq, err := normalizeOpenAIResponsesRequest(h.Store, req, auth.RouteInput(r), traceID)
 	if err != nil {
 		writeOpenAIError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	stdReq = upstream.FitContext(r.Context(), h.DS, a, w.Header(), stdReq)
 
-	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
-	if err != nil {
-		if a.UseConfigToken {
-			writeOpenAIError(w, http.StatusUnauthorized, "Account token is invalid. Please re-login the account in admin.")
-		} else {
-			writeOpenAIError(w, http.StatusUnauthorized, "Invalid token. If this should be a DS2API key, add it to config.keys first.")
-		}
-		return
-	}
-	pow, err := h.DS.GetPow(r.Context(), a, 3)
-	if err != nil {
-		writeOpenAIError(w, http.StatusUnauthorized, "Failed to get PoW (invalid token or unknown error).")
+	if util.ToBool(req["background"]) {
+		handedOff = true
+		h.startBackgroundResponse(w, r, a, owner, stdReq, traceID)
 		return
 	}
-	payload := stdReq.CompletionPayload(sessionID)
-	resp, err := h.DS.CallCompletion(r.Context(), a, payload, pow, 3)
+
+	completion, err := upstream.OpenShared(r.Context(), h.DS, h.sharing(r, a), a, stdReq, w.Header())
 	if err != nil {
-		writeOpenAIError(w, http.StatusInternalServerError, "Failed to get completion.")
+		writeOpenAIUpstreamError(w, a, err)
 		return
 	}
-
-	responseID := "resp_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	resp := completion.Resp
+	responseID := newResponseID()
+	limits := sse.LimitsFromRequest(stdReq)
 	if stdReq.Stream {
-		h.handleResponsesStream(w, r, resp, owner, responseID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ToolChoice, traceID)
+		handedOff = true
+		// Like the stream itself, repairs carry on after a disconnect.
+		tools := h.toolCallReader(context.WithoutCancel(r.Context()), a, stdReq)
+		h.handleResponsesStreamWithLimits(w, r, resp, owner, responseID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ToolChoice, traceID, stdReq.ReasoningMode, limits, tools, func() {
+			h.Auth.Release(a)
+		})
 		return
 	}
-	h.handleResponsesNonStream(w, resp, owner, responseID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.ToolNames, stdReq.ToolChoice, traceID)
+	h.handleResponsesNonStream(w, resp, owner, responseID, stdReq.ResponseModel, stdReq.FinalPrompt, stdReq.Thinking, stdReq.Search, stdReq.ToolNames, stdReq.ToolChoice, traceID, stdReq.ReasoningMode, limits, h.toolCallReader(r.Context(), a, stdReq))
 }
 
-func (h *Handler) handleResponsesNonStream(w http.ResponseWriter, resp *http.Response, owner, responseID, model, finalPrompt string, thinkingEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, traceID string) {
+func (h *Handler) handleResponsesNonStream(w http.ResponseWriter, resp *http.Response, owner, responseID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, traceID string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		writeOpenAIError(w, resp.StatusCode, strings.TrimSpace(string(body)))
 		return
 	}
-	result := sse.CollectStream(resp, thinkingEnabled, true)
-	textParsed := util.ParseToolCallsDetailed(result.Text, toolNames)
-	thinkingParsed := util.ParseToolCallsDetailed(result.Thinking, toolNames)
+	result := sse.CollectStreamWithLimits(resp, thinkingEnabled, true, limits)
+	var citations []sse.Citation
+	result.Text, citations = sse.ResolveCitations(result.Text, result.SearchResults, searchEnabled)
+	textParsed := tools.ParseDetailed(result.Text, toolNames)
+	thinkingParsed := util.ParseToolCallsDetailedWith(result.Thinking, toolNames, tools.Dialects)
 	logResponsesToolPolicyRejection(traceID, toolChoice, textParsed, "text")
 	logResponsesToolPolicyRejection(traceID, toolChoice, thinkingParsed, "thinking")
 
 	callCount := len(textParsed.Calls)
 	if callCount == 0 {
-		callCount = len(thinkingParsed.Calls)
+		callCount = len(tools.Check(thinkingParsed.Calls))
 	}
 	if toolChoice.IsRequired() && callCount == 0 {
 		writeOpenAIErrorWithCode(w, http.StatusUnprocessableEntity, "tool_choice requires at least one valid tool call.", "tool_choice_violation")
 		return
 	}
 
-	responseObj := openaifmt.BuildResponseObject(responseID, model, finalPrompt, result.Thinking, result.Text, toolNames)
+	responseObj := openaifmt.BuildResponseObjectWithReasoning(responseID, model, finalPrompt, result.Thinking, result.Text, toolNames, tools, reasoningMode)
+	usage, _ := responseObj["usage"].(map[string]any)
+	cachedUsage(respcache.IsHit(w.Header()), usage)
+	_, shownText := util.PresentReasoning(reasoningMode, result.Thinking, result.Text)
+	openaifmt.AddResponsesAnnotations(responseObj, openaifmt.BuildResponsesURLCitations(shownText, sse.OffsetCitations(citations, len(shownText)-len(result.Text))))
+	if result.Limit == sse.LimitMaxTokens {
+		openaifmt.MarkResponseIncomplete(responseObj, "max_output_tokens")
+	}
 	h.getResponseStore().put(owner, responseID, responseObj)
 	writeJSON(w, http.StatusOK, responseObj)
 }
 
 func (h *Handler) handleResponsesStream(w http.ResponseWriter, r *http.Request, resp *http.Response, owner, responseID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, traceID string) {
-	defer resp.Body.Close()
+	h.handleResponsesStreamWithLimits(w, r, resp, owner, responseID, model, finalPrompt, thinkingEnabled, searchEnabled, toolNames, toolChoice, traceID, util.ReasoningSeparate, sse.OutputLimits{}, util.ToolCallReader{Dialects: h.toolcallDialects()}, nil)
+}
+
+// handleResponsesStreamWithLimits generates the stream detached from the
+// request and follows it from the event log. If the client disconnects the
+// run keeps going for the configured grace period so the client can resume;
+// release is called once the run has ended.
+func (h *Handler) handleResponsesStreamWithLimits(w http.ResponseWriter, r *http.Request, resp *http.Response, owner, responseID, model, finalPrompt string, thinkingEnabled, searchEnabled bool, toolNames []string, toolChoice util.ToolChoicePolicy, traceID string, reasoningMode util.ReasoningMode, limits sse.OutputLimits, tools util.ToolCallReader, release func()) {
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if release != nil {
+			defer release()
+		}
 		body, _ := io.ReadAll(resp.Body)
 		writeOpenAIError(w, resp.StatusCode, strings.TrimSpace(string(body)))
 		return
 	}
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache, no-transform")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-	rc := http.NewResponseController(w)
-	_, canFlush := w.(http.Flusher)
 
-	initialType := "text"
-	if thinkingEnabled {
-		initialType = "thinking"
-	}
 	bufferToolContent := len(toolNames) > 0 && h.toolcallFeatureMatchEnabled()
 	emitEarlyToolDeltas := h.toolcallEarlyEmitHighConfidence()
 
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	events := newResponseEventLog(h.responseStreamGrace(), cancel)
+	h.registerResponseEvents(owner, responseID, events)
 	streamRuntime := newResponsesStreamRuntime(
-		w,
-		rc,
-		canFlush,
+		discardResponseWriter{},
+		nil,
+		false,
 		responseID,
 		model,
 		finalPrompt,
@@ -171,24 +204,49 @@ func (h *Handler) handleResponsesStream(w http.ResponseWriter, r *http.Request,
 			h.getResponseStore().put(owner, responseID, obj)
 		},
 	)
+	streamRuntime.events = events
+	streamRuntime.cacheHit = respcache.IsHit(w.Header())
+	streamRuntime.reasoning = util.NewReasoningPresenter(reasoningMode)
+	streamRuntime.limiter = sse.NewOutputLimiter(limits)
+	streamRuntime.setToolCallReader(tools)
 	streamRuntime.sendCreated()
+	go func() {
+		defer cancel()
+		defer resp.Body.Close()
+		if release != nil {
+			defer release()
+		}
+		defer events.finish()
+		consumeResponsesStream(ctx, resp.Body, thinkingEnabled, streamRuntime)
+	}()
+	followResponseEvents(r.Context(), w, events, 0)
+}
 
+func consumeResponsesStream(ctx context.Context, body io.Reader, thinkingEnabled bool, rt *responsesStreamRuntime) {
+	initialType := "text"
+	if thinkingEnabled {
+		initialType = "thinking"
+	}
 	streamengine.ConsumeSSE(streamengine.ConsumeConfig{
-		Context:             r.Context(),
-		Body:                resp.Body,
+		Context:             ctx,
+		Body:                body,
 		ThinkingEnabled:     thinkingEnabled,
 		InitialType:         initialType,
 		KeepAliveInterval:   time.Duration(deepseek.KeepAliveTimeout) * time.Second,
 		IdleTimeout:         time.Duration(deepseek.StreamIdleTimeout) * time.Second,
 		MaxKeepAliveNoInput: deepseek.MaxKeepaliveCount,
 	}, streamengine.ConsumeHooks{
-		OnParsed: streamRuntime.onParsed,
+		OnParsed: rt.onParsed,
 		OnFinalize: func(_ streamengine.StopReason, _ error) {
-			streamRuntime.finalize()
+			rt.finalize()
 		},
 	})
 }
 
+func newResponseID() string {
+	return "resp_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
 func logResponsesToolPolicyRejection(traceID string, policy util.ToolChoicePolicy, parsed util.ToolCallParseResult, channel string) {
 	rejected := filteredRejectedToolNamesForLog(parsed.RejectedToolNames)
 	if !parsed.RejectedByPolicy || len(rejected) == 0 {
@@ -8,6 +8,7 @@ import (
 	openaifmt "ds2api/internal/format/openai"
 	"ds2api/internal/sse"
 	streamengine "ds2api/internal/stream"
+	"ds2api/internal/toolsieve"
 	"ds2api/internal/util"
 )
 
@@ -20,6 +21,7 @@ type responsesStreamRuntime struct {
 	model       string
 	finalPrompt string
 	toolNames   []string
+	tools       util.ToolCallReader
 	traceID     string
 	toolChoice  util.ToolChoicePolicy
 
@@ -31,8 +33,14 @@ type responsesStreamRuntime struct {
 	toolCallsEmitted     bool
 	toolCallsDoneEmitted bool
 
-	sieve             toolStreamSieveState
-	thinkingSieve     toolStreamSieveState
+	// cacheHit reports the prompt as cached tokens in usage.
+	cacheHit          bool
+	limiter           *sse.OutputLimiter
+	citations         *sse.SearchCitations
+	reasoning         *util.ReasoningPresenter
+	annotations       []any
+	sieve             toolsieve.State
+	thinkingSieve     toolsieve.State
 	thinking          strings.Builder
 	text              strings.Builder
 	visibleText       strings.Builder
@@ -48,10 +56,20 @@ type responsesStreamRuntime struct {
 	nextOutputID      int
 	messageAdded      bool
 	messagePartAdded  bool
+	reasoningItemID   string
+	reasoningText     strings.Builder
+	reasoningOutputID int
+	reasoningAdded    bool
+	reasoningDone     bool
 	sequence          int
 	failed            bool
 
 	persistResponse func(obj map[string]any)
+	// events, when set, receives every frame so clients can resume.
+	events *responseEventLog
+	// onProgress, when set, is called after each parsed chunk that carried
+	// content so background runs can publish partial output.
+	onProgress func()
 }
 
 func newResponsesStreamRuntime(
@@ -70,6 +88,10 @@ func newResponsesStreamRuntime(
 	traceID string,
 	persistResponse func(obj map[string]any),
 ) *responsesStreamRuntime {
+	var citations *sse.SearchCitations
+	if searchEnabled {
+		citations = sse.NewSearchCitations()
+	}
 	return &responsesStreamRuntime{
 		w:                   w,
 		rc:                  rc,
@@ -79,6 +101,7 @@ func newResponsesStreamRuntime(
 		finalPrompt:         finalPrompt,
 		thinkingEnabled:     thinkingEnabled,
 		searchEnabled:       searchEnabled,
+		citations:           citations,
 		toolNames:           toolNames,
 		bufferToolContent:   bufferToolContent,
 		emitEarlyToolDeltas: emitEarlyToolDeltas,
@@ -90,26 +113,38 @@ func newResponsesStreamRuntime(
 		functionAdded:       map[int]bool{},
 		functionNames:       map[int]string{},
 		messageOutputID:     -1,
+		reasoningOutputID:   -1,
 		toolChoice:          toolChoice,
 		traceID:             traceID,
 		persistResponse:     persistResponse,
 	}
 }
 
+// setToolCallReader selects how tool calls are read from the output. As in
+// chat streams, argument deltas stream as written and the done events of a
+// call carry its checked arguments.
+func (s *responsesStreamRuntime) setToolCallReader(tools util.ToolCallReader) {
+	s.tools = tools
+	s.sieve.Dialects = tools.Dialects
+	s.thinkingSieve.Dialects = tools.Dialects
+}
+
 func (s *responsesStreamRuntime) finalize() {
+	s.emitParts(s.limiter.Flush())
+	s.emitParts(s.citations.Flush())
 	finalThinking := s.thinking.String()
 	finalText := s.text.String()
 
 	if s.bufferToolContent {
-		s.processToolStreamEvents(flushToolSieve(&s.sieve, s.toolNames), true)
-		s.processToolStreamEvents(flushToolSieve(&s.thinkingSieve, s.toolNames), false)
+		s.processToolStreamEvents(s.sieve.Flush(s.toolNames), true)
+		s.processToolStreamEvents(s.thinkingSieve.Flush(s.toolNames), false)
 	}
 
-	textParsed := util.ParseToolCallsDetailed(finalText, s.toolNames)
-	thinkingParsed := util.ParseToolCallsDetailed(finalThinking, s.toolNames)
+	textParsed := s.tools.ParseDetailed(finalText, s.toolNames)
+	thinkingParsed := util.ParseToolCallsDetailedWith(finalThinking, s.toolNames, s.tools.Dialects)
 	detected := textParsed.Calls
 	if len(detected) == 0 {
-		detected = thinkingParsed.Calls
+		detected = s.tools.Check(thinkingParsed.Calls)
 	}
 	s.logToolPolicyRejections(textParsed, thinkingParsed)
 
@@ -120,40 +155,28 @@ func (s *responsesStreamRuntime) finalize() {
 		}
 	}
 
+	s.endReasoning()
 	s.closeMessageItem()
 
 	if s.toolChoice.IsRequired() && len(detected) == 0 {
-		s.failed = true
-		message := "tool_choice requires at least one valid tool call."
-		failedResp := map[string]any{
-			"id":          s.responseID,
-			"type":        "response",
-			"object":      "response",
-			"model":       s.model,
-			"status":      "failed",
-			"output":      []any{},
-			"output_text": "",
-			"error": map[string]any{
-				"message": message,
-				"type":    "invalid_request_error",
-				"code":    "tool_choice_violation",
-				"param":   nil,
-			},
-		}
-		if s.persistResponse != nil {
-			s.persistResponse(failedResp)
-		}
-		s.sendEvent("response.failed", openaifmt.BuildResponsesFailedPayload(s.responseID, s.model, message, "tool_choice_violation"))
-		s.sendDone()
+		s.fail("tool_choice requires at least one valid tool call.", "tool_choice_violation")
 		return
 	}
 	s.closeIncompleteFunctionItems()
 
 	obj := s.buildCompletedResponseObject(finalThinking, finalText, detected)
+	incomplete := s.limiter.Reason() == sse.LimitMaxTokens
+	if incomplete {
+		openaifmt.MarkResponseIncomplete(obj, "max_output_tokens")
+	}
 	if s.persistResponse != nil {
 		s.persistResponse(obj)
 	}
-	s.sendEvent("response.completed", openaifmt.BuildResponsesCompletedPayload(obj))
+	if incomplete {
+		s.sendEvent("response.incomplete", openaifmt.BuildResponsesIncompletePayload(obj))
+	} else {
+		s.sendEvent("response.completed", openaifmt.BuildResponsesCompletedPayload(obj))
+	}
 	s.sendDone()
 }
 
@@ -192,12 +215,25 @@ func (s *responsesStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Pa
 		return streamengine.ParsedDecision{Stop: true}
 	}
 
+	s.citations.AddResults(parsed.SearchResults)
+	contentSeen := s.emitParts(s.limiter.Apply(parsed.Parts))
+	if contentSeen && s.onProgress != nil {
+		s.onProgress()
+	}
+	if s.limiter.Reached() {
+		return streamengine.ParsedDecision{Stop: true, StopReason: streamengine.StopReasonOutputLimit, ContentSeen: contentSeen}
+	}
+	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+}
+
+func (s *responsesStreamRuntime) emitParts(parts []sse.ContentPart) bool {
 	contentSeen := false
-	for _, p := range parsed.Parts {
-		if p.Text == "" {
-			continue
+	for _, p := range parts {
+		var citations []sse.Citation
+		if p.Type != "thinking" {
+			p.Text, citations = s.citations.Strip(p.Text)
 		}
-		if p.Type != "thinking" && s.searchEnabled && sse.IsCitation(p.Text) {
+		if p.Text == "" && len(citations) == 0 {
 			continue
 		}
 		contentSeen = true
@@ -206,20 +242,21 @@ func (s *responsesStreamRuntime) onParsed(parsed sse.LineResult) streamengine.Pa
 				continue
 			}
 			s.thinking.WriteString(p.Text)
-			s.sendEvent("response.reasoning.delta", openaifmt.BuildResponsesReasoningDeltaPayload(s.responseID, p.Text))
+			s.presentReasoning(s.reasoning.Thinking(p.Text))
 			if s.bufferToolContent {
-				s.processToolStreamEvents(processToolSieveChunk(&s.thinkingSieve, p.Text, s.toolNames), false)
+				s.processToolStreamEvents(s.thinkingSieve.Process(p.Text, s.toolNames), false)
 			}
 			continue
 		}
 
+		s.endReasoning()
 		s.text.WriteString(p.Text)
 		if !s.bufferToolContent {
 			s.emitTextDelta(p.Text)
-			continue
+		} else {
+			s.processToolStreamEvents(s.sieve.Process(p.Text, s.toolNames), true)
 		}
-		s.processToolStreamEvents(processToolSieveChunk(&s.sieve, p.Text, s.toolNames), true)
+		s.emitAnnotations(citations)
 	}
-
-	return streamengine.ParsedDecision{ContentSeen: contentSeen}
+	return contentSeen
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/toolsieve"
 )
 
 func (s *responsesStreamRuntime) nextSequence() int {
@@ -19,27 +20,43 @@ func (s *responsesStreamRuntime) sendEvent(event string, payload map[string]any)
 		payload["sequence_number"] = s.nextSequence()
 	}
 	b, _ := json.Marshal(payload)
-	_, _ = s.w.Write([]byte("event: " + event + "\n"))
-	_, _ = s.w.Write([]byte("data: "))
-	_, _ = s.w.Write(b)
-	_, _ = s.w.Write([]byte("\n\n"))
+	frame := make([]byte, 0, len(event)+len(b)+16)
+	frame = append(frame, "event: "...)
+	frame = append(frame, event...)
+	frame = append(frame, "\ndata: "...)
+	frame = append(frame, b...)
+	frame = append(frame, "\n\n"...)
+	_, _ = s.w.Write(frame)
 	if s.canFlush {
 		_ = s.rc.Flush()
 	}
+	seq, _ := payload["sequence_number"].(int)
+	s.events.append(seq, frame)
 }
 
 func (s *responsesStreamRuntime) sendCreated() {
 	s.sendEvent("response.created", openaifmt.BuildResponsesCreatedPayload(s.responseID, s.model))
 }
 
+// fail ends the stream with response.failed and stores the failed response.
+func (s *responsesStreamRuntime) fail(message, code string) {
+	s.failed = true
+	if s.persistResponse != nil {
+		s.persistResponse(openaifmt.BuildResponseFailedObject(s.responseID, s.model, message, code))
+	}
+	s.sendEvent("response.failed", openaifmt.BuildResponsesFailedPayload(s.responseID, s.model, message, code))
+	s.sendDone()
+}
+
 func (s *responsesStreamRuntime) sendDone() {
 	_, _ = s.w.Write([]byte("data: [DONE]\n\n"))
 	if s.canFlush {
 		_ = s.rc.Flush()
 	}
+	s.events.finish()
 }
 
-func (s *responsesStreamRuntime) processToolStreamEvents(events []toolStreamEvent, emitContent bool) {
+func (s *responsesStreamRuntime) processToolStreamEvents(events []toolsieve.Event, emitContent bool) {
 	for _, evt := range events {
 		if emitContent && evt.Content != "" {
 			s.emitTextDelta(evt.Content)
@@ -54,8 +71,8 @@ func (s *responsesStreamRuntime) processToolStreamEvents(events []toolStreamEven
 			}
 			s.emitFunctionCallDeltaEvents(filtered)
 		}
-		if len(evt.ToolCalls) > 0 {
-			s.emitFunctionCallDoneEvents(evt.ToolCalls)
+		if calls := s.tools.Check(evt.ToolCalls); len(calls) > 0 {
+			s.emitFunctionCallDoneEvents(calls)
 		}
 	}
 }
@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"strings"
+
+	openaifmt "ds2api/internal/format/openai"
+
+	"github.com/google/uuid"
+)
+
+// presentReasoning streams thinking as the reasoning mode shows it: on the
+// reasoning item, or inline as answer text.
+func (s *responsesStreamRuntime) presentReasoning(reasoning, inline string) {
+	if reasoning != "" {
+		s.emitReasoningDelta(reasoning)
+		s.sendEvent("response.reasoning.delta", openaifmt.BuildResponsesReasoningDeltaPayload(s.responseID, reasoning))
+	}
+	if inline != "" {
+		s.emitTextDelta(inline)
+	}
+}
+
+// endReasoning flushes what the reasoning mode holds back until thinking
+// ends, then closes the reasoning item.
+func (s *responsesStreamRuntime) endReasoning() {
+	s.presentReasoning(s.reasoning.EndThinking())
+	s.closeReasoningItem()
+}
+
+// emitReasoningDelta streams thinking text as the summary of a reasoning
+// output item, opening the item on the first delta.
+func (s *responsesStreamRuntime) emitReasoningDelta(text string) {
+	if s.reasoningDone {
+		return
+	}
+	if !s.reasoningAdded {
+		s.reasoningItemID = "rs_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+		s.reasoningOutputID = s.allocateOutputIndex()
+		s.sendEvent(
+			"response.output_item.added",
+			openaifmt.BuildResponsesOutputItemAddedPayload(s.responseID, s.reasoningItemID, s.reasoningOutputID, openaifmt.BuildResponsesReasoningItem(s.reasoningItemID, "", "in_progress")),
+		)
+		s.sendEvent(
+			"response.reasoning_summary_part.added",
+			openaifmt.BuildResponsesReasoningSummaryPartAddedPayload(s.responseID, s.reasoningItemID, s.reasoningOutputID, 0, openaifmt.BuildResponsesReasoningSummaryPart("")),
+		)
+		s.reasoningAdded = true
+	}
+	s.reasoningText.WriteString(text)
+	s.sendEvent(
+		"response.reasoning_summary_text.delta",
+		openaifmt.BuildResponsesReasoningSummaryTextDeltaPayload(s.responseID, s.reasoningItemID, s.reasoningOutputID, 0, text),
+	)
+}
+
+// closeReasoningItem finishes the reasoning item once the answer starts or
+// the stream ends. Thinking that arrives afterwards is still recorded for
+// usage but no longer streamed.
+func (s *responsesStreamRuntime) closeReasoningItem() {
+	if !s.reasoningAdded || s.reasoningDone {
+		return
+	}
+	s.reasoningDone = true
+	text := s.reasoningText.String()
+	s.sendEvent(
+		"response.reasoning_summary_text.done",
+		openaifmt.BuildResponsesReasoningSummaryTextDonePayload(s.responseID, s.reasoningItemID, s.reasoningOutputID, 0, text),
+	)
+	s.sendEvent(
+		"response.reasoning_summary_part.done",
+		openaifmt.BuildResponsesReasoningSummaryPartDonePayload(s.responseID, s.reasoningItemID, s.reasoningOutputID, 0, openaifmt.BuildResponsesReasoningSummaryPart(text)),
+	)
+	s.sendEvent(
+		"response.output_item.done",
+		openaifmt.BuildResponsesOutputItemDonePayload(s.responseID, s.reasoningItemID, s.reasoningOutputID, s.reasoningItem()),
+	)
+}
+
+func (s *responsesStreamRuntime) reasoningItem() map[string]any {
+	return openaifmt.BuildResponsesReasoningItem(s.reasoningItemID, s.reasoningText.String(), "completed")
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	openaifmt "ds2api/internal/format/openai"
+	"ds2api/internal/sse"
+	"ds2api/internal/toolsieve"
 	"ds2api/internal/util"
 
 	"github.com/google/uuid"
@@ -86,6 +88,37 @@ func (s *responsesStreamRuntime) emitTextDelta(content string) {
 	)
 }
 
+func (s *responsesStreamRuntime) emitAnnotations(citations []sse.Citation) {
+	if len(citations) == 0 {
+		return
+	}
+	s.ensureMessageContentPartAdded()
+	inline := s.reasoning.Inline()
+	for _, annotation := range openaifmt.BuildResponsesURLCitations(inline+s.text.String(), sse.OffsetCitations(citations, len(inline))) {
+		s.annotations = append(s.annotations, annotation)
+		s.sendEvent(
+			"response.output_text.annotation.added",
+			openaifmt.BuildResponsesAnnotationAddedPayload(
+				s.responseID,
+				s.ensureMessageItemID(),
+				s.ensureMessageOutputIndex(),
+				0,
+				len(s.annotations)-1,
+				annotation,
+			),
+		)
+	}
+}
+
+// messageTextPart is the output_text part of the streamed message item.
+func (s *responsesStreamRuntime) messageTextPart(text string) map[string]any {
+	part := map[string]any{"type": "output_text", "text": text}
+	if len(s.annotations) > 0 {
+		part["annotations"] = s.annotations
+	}
+	return part
+}
+
 func (s *responsesStreamRuntime) closeMessageItem() {
 	if !s.messageAdded {
 		return
@@ -101,22 +134,17 @@ func (s *responsesStreamRuntime) closeMessageItem() {
 				itemID,
 				outputIndex,
 				0,
-				map[string]any{"type": "output_text", "text": text},
+				s.messageTextPart(text),
 			),
 		)
 		s.messagePartAdded = false
 	}
 	item := map[string]any{
-		"id":     itemID,
-		"type":   "message",
-		"role":   "assistant",
-		"status": "completed",
-		"content": []map[string]any{
-			{
-				"type": "output_text",
-				"text": text,
-			},
-		},
+		"id":      itemID,
+		"type":    "message",
+		"role":    "assistant",
+		"status":  "completed",
+		"content": []map[string]any{s.messageTextPart(text)},
 	}
 	s.sendEvent(
 		"response.output_item.done",
@@ -181,7 +209,7 @@ func (s *responsesStreamRuntime) ensureFunctionItemAdded(callIndex int, name str
 	s.toolCallsEmitted = true
 }
 
-func (s *responsesStreamRuntime) emitFunctionCallDeltaEvents(deltas []toolCallDelta) {
+func (s *responsesStreamRuntime) emitFunctionCallDeltaEvents(deltas []toolsieve.CallDelta) {
 	for _, d := range deltas {
 		s.ensureFunctionItemAdded(d.Index, d.Name)
 		if strings.TrimSpace(d.Arguments) == "" {
@@ -9,6 +9,10 @@ import (
 	"ds2api/internal/util"
 )
 
+// closeIncompleteFunctionItems closes function items whose arguments were
+// streamed but never completed by a call. With schemas declared such an item
+// is a call that failed validation, so it is closed as incomplete rather than
+// handed to the client as a call to run.
 func (s *responsesStreamRuntime) closeIncompleteFunctionItems() {
 	if len(s.functionAdded) == 0 {
 		return
@@ -40,13 +44,17 @@ func (s *responsesStreamRuntime) closeIncompleteFunctionItems() {
 			"response.function_call_arguments.done",
 			openaifmt.BuildResponsesFunctionCallArgumentsDonePayload(s.responseID, itemID, outputIndex, callID, name, args),
 		)
+		status := "completed"
+		if len(s.tools.Schemas) > 0 {
+			status = "incomplete"
+		}
 		item := map[string]any{
 			"id":        itemID,
 			"type":      "function_call",
 			"call_id":   callID,
 			"name":      name,
 			"arguments": args,
-			"status":    "completed",
+			"status":    status,
 		}
 		s.sendEvent(
 			"response.output_item.done",
@@ -62,33 +70,26 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 		index int
 		item  map[string]any
 	}
-	indexed := make([]indexedItem, 0, len(calls)+1)
+	indexed := make([]indexedItem, 0, len(calls)+2)
+
+	if s.reasoningAdded {
+		indexed = append(indexed, indexedItem{index: s.reasoningOutputID, item: s.reasoningItem()})
+	}
 
 	if s.messageAdded {
 		text := s.visibleText.String()
 		indexed = append(indexed, indexedItem{
 			index: s.ensureMessageOutputIndex(),
 			item: map[string]any{
-				"id":     s.ensureMessageItemID(),
-				"type":   "message",
-				"role":   "assistant",
-				"status": "completed",
-				"content": []map[string]any{
-					{
-						"type": "output_text",
-						"text": text,
-					},
-				},
+				"id":      s.ensureMessageItemID(),
+				"type":    "message",
+				"role":    "assistant",
+				"status":  "completed",
+				"content": []map[string]any{s.messageTextPart(text)},
 			},
 		})
 	} else if len(calls) == 0 {
-		content := make([]map[string]any, 0, 2)
-		if strings.TrimSpace(finalThinking) != "" {
-			content = append(content, map[string]any{
-				"type": "reasoning",
-				"text": finalThinking,
-			})
-		}
+		content := make([]map[string]any, 0, 1)
 		if strings.TrimSpace(finalText) != "" {
 			content = append(content, map[string]any{
 				"type": "output_text",
@@ -140,11 +141,11 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 		if strings.TrimSpace(finalText) != "" {
 			outputText = finalText
 		} else if strings.TrimSpace(finalThinking) != "" {
-			outputText = finalThinking
+			outputText = s.reasoning.FinalReasoning(finalThinking)
 		}
 	}
 
-	return openaifmt.BuildResponseObjectFromItems(
+	obj := openaifmt.BuildResponseObjectFromItems(
 		s.responseID,
 		s.model,
 		s.finalPrompt,
@@ -153,4 +154,7 @@ func (s *responsesStreamRuntime) buildCompletedResponseObject(finalThinking, fin
 		output,
 		outputText,
 	)
+	usage, _ := obj["usage"].(map[string]any)
+	cachedUsage(s.cacheHit, usage)
+	return obj
 }
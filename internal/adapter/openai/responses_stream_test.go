@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"ds2api/internal/sse"
 	"ds2api/internal/util"
 )
 
@@ -505,7 +506,7 @@ func TestHandleResponsesNonStreamRequiredToolChoiceViolation(t *testing.T) {
 		Allowed: map[string]struct{}{"read_file": {}},
 	}
 
-	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, []string{"read_file"}, policy, "")
+	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, []string{"read_file"}, policy, "", "", sse.OutputLimits{}, util.ToolCallReader{})
 	if rec.Code != http.StatusUnprocessableEntity {
 		t.Fatalf("expected 422 for required tool_choice violation, got %d body=%s", rec.Code, rec.Body.String())
 	}
@@ -528,7 +529,7 @@ func TestHandleResponsesNonStreamToolChoiceNoneRejectsFunctionCall(t *testing.T)
 	}
 	policy := util.ToolChoicePolicy{Mode: util.ToolChoiceNone}
 
-	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, nil, policy, "")
+	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, nil, policy, "", "", sse.OutputLimits{}, util.ToolCallReader{})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected 200 for tool_choice=none passthrough text, got %d body=%s", rec.Code, rec.Body.String())
 	}
@@ -609,3 +610,100 @@ func asFloat(v any) float64 {
 		return 0
 	}
 }
+
+func TestHandleResponsesStreamMaxOutputTokensEndsIncomplete(t *testing.T) {
+	h := &Handler{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	rec := httptest.NewRecorder()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(
+			`data: {"p":"response/content","v":"abcdefghijklmnop"}` + "\n" +
+				`data: [DONE]` + "\n",
+		)),
+	}
+
+	h.handleResponsesStreamWithLimits(rec, req, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, nil, util.DefaultToolChoicePolicy(), "", "", sse.OutputLimits{MaxTokens: 2, CountThinking: true}, util.ToolCallReader{}, nil)
+
+	if _, ok := extractSSEEventPayload(rec.Body.String(), "response.completed"); ok {
+		t.Fatalf("did not expect response.completed, body=%s", rec.Body.String())
+	}
+	incomplete, ok := extractSSEEventPayload(rec.Body.String(), "response.incomplete")
+	if !ok {
+		t.Fatalf("expected response.incomplete event, body=%s", rec.Body.String())
+	}
+	responseObj, _ := incomplete["response"].(map[string]any)
+	details, _ := responseObj["incomplete_details"].(map[string]any)
+	if responseObj["status"] != "incomplete" || details["reason"] != "max_output_tokens" {
+		t.Fatalf("unexpected incomplete response: %#v", responseObj)
+	}
+	if responseObj["output_text"] != "abcdefghijk" {
+		t.Fatalf("expected output cut at the token budget, got %#v", responseObj["output_text"])
+	}
+}
+
+func TestHandleResponsesNonStreamMaxOutputTokensEndsIncomplete(t *testing.T) {
+	h := &Handler{}
+	rec := httptest.NewRecorder()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(
+			`data: {"p":"response/content","v":"abcdefghijklmnop"}` + "\n" +
+				`data: [DONE]` + "\n",
+		)),
+	}
+
+	h.handleResponsesNonStream(rec, resp, "owner-a", "resp_test", "deepseek-chat", "prompt", false, false, nil, util.DefaultToolChoicePolicy(), "", "", sse.OutputLimits{MaxTokens: 2, CountThinking: true}, util.ToolCallReader{})
+
+	out := decodeJSONBody(t, rec.Body.String())
+	details, _ := out["incomplete_details"].(map[string]any)
+	if out["status"] != "incomplete" || details["reason"] != "max_output_tokens" || out["output_text"] != "abcdefghijk" {
+		t.Fatalf("unexpected incomplete response: %#v", out)
+	}
+}
+
+func TestHandleResponsesStreamEmitsReasoningItem(t *testing.T) {
+	h := &Handler{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	rec := httptest.NewRecorder()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(
+			`data: {"p":"response/thinking_content","v":"let me think"}` + "\n" +
+				`data: {"p":"response/content","v":"answer"}` + "\n" +
+				`data: [DONE]` + "\n",
+		)),
+	}
+
+	h.handleResponsesStream(rec, req, resp, "owner-a", "resp_test", "deepseek-reasoner", "prompt", true, false, nil, util.DefaultToolChoicePolicy(), "")
+
+	body := rec.Body.String()
+	delta, ok := extractSSEEventPayload(body, "response.reasoning_summary_text.delta")
+	if !ok || delta["delta"] != "let me think" || delta["summary_index"] != float64(0) {
+		t.Fatalf("expected reasoning summary delta, body=%s", body)
+	}
+	done, ok := extractSSEEventPayload(body, "response.reasoning_summary_text.done")
+	if !ok || done["text"] != "let me think" {
+		t.Fatalf("expected reasoning summary done, body=%s", body)
+	}
+	if strings.Index(body, "response.reasoning_summary_text.done") > strings.Index(body, "response.output_text.delta") {
+		t.Fatalf("expected reasoning item closed before the answer starts, body=%s", body)
+	}
+	completed, ok := extractSSEEventPayload(body, "response.completed")
+	if !ok {
+		t.Fatalf("expected response.completed, body=%s", body)
+	}
+	responseObj, _ := completed["response"].(map[string]any)
+	output, _ := responseObj["output"].([]any)
+	if len(output) != 2 {
+		t.Fatalf("expected reasoning and message items, got %#v", output)
+	}
+	first, _ := output[0].(map[string]any)
+	if first["type"] != "reasoning" {
+		t.Fatalf("expected reasoning item first, got %#v", first)
+	}
+	second, _ := output[1].(map[string]any)
+	if second["type"] != "message" {
+		t.Fatalf("expected message item second, got %#v", second)
+	}
+}
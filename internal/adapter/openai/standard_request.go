@@ -5,55 +5,94 @@ import (
 	"strings"
 
 	"ds2api/internal/config"
+	"ds2api/internal/upstream"
 	"ds2api/internal/util"
 )
 
-func normalizeOpenAIChatRequest(store ConfigReader, req map[string]any, traceID string) (util.StandardRequest, error) {
+func normalizeOpenAIChatRequest(store ConfigReader, req map[string]any, route config.RouteInput, traceID string) (util.StandardRequest, error) {
 	model, _ := req["model"].(string)
 	messagesRaw, _ := req["messages"].([]any)
 	if strings.TrimSpace(model) == "" || len(messagesRaw) == 0 {
 		return util.StandardRequest{}, fmt.Errorf("Request must include 'model' and 'messages'.")
 	}
-	resolvedModel, ok := config.ResolveModel(store, model)
+	route.Model, route.Surface = model, config.ModelSurfaceOpenAI
+	decision, ok := config.ResolveRoute(store, route)
 	if !ok {
 		return util.StandardRequest{}, fmt.Errorf("Model '%s' is not available.", model)
 	}
-	thinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
+	resolvedModel, thinkingEnabled, searchEnabled := decision.Model, decision.Thinking, decision.Search
+	thinkingEnabled, searchEnabled = applyOpenAIChatCapabilities(req, thinkingEnabled, searchEnabled)
+	reasoningMode, err := requestReasoningMode(store, req, route)
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
 	responseModel := strings.TrimSpace(model)
 	if responseModel == "" {
 		responseModel = resolvedModel
 	}
+	choices, err := upstream.ParseChoiceCount(req["n"], "n")
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
 	toolPolicy := util.DefaultToolChoicePolicy()
-	finalPrompt, toolNames := buildOpenAIFinalPromptWithPolicy(messagesRaw, req["tools"], traceID, toolPolicy)
+	toolPrompt := util.ToolInstructionsFor(store, config.ModelSurfaceOpenAI, resolvedModel, route.Key)
+	promptMessages, toolNames := buildOpenAIPromptMessages(messagesRaw, req["tools"], traceID, toolPolicy, toolPrompt)
 	passThrough := collectOpenAIChatPassThrough(req)
+	maxTokens, maxTokensIncludeThinking := openAIChatMaxTokens(req)
 
-	return util.StandardRequest{
+	stdReq := util.StandardRequest{
 		Surface:        "openai_chat",
 		RequestedModel: strings.TrimSpace(model),
 		ResolvedModel:  resolvedModel,
 		ResponseModel:  responseModel,
 		Messages:       messagesRaw,
-		FinalPrompt:    finalPrompt,
 		ToolNames:      toolNames,
+		ToolSchemas:    util.CompileToolSchemas(req["tools"]),
 		ToolChoice:     toolPolicy,
 		Stream:         util.ToBool(req["stream"]),
 		Thinking:       thinkingEnabled,
 		Search:         searchEnabled,
+		Choices:        choices,
+		StopSequences:  util.StopSequencesFrom(req["stop"]),
 		PassThrough:    passThrough,
-	}, nil
+
+		ReasoningMode:            reasoningMode,
+		MaxOutputTokens:          maxTokens,
+		MaxTokensIncludeThinking: maxTokensIncludeThinking,
+	}
+	stdReq.AccountGroup = decision.AccountGroup
+	stdReq.EndUser, _ = req["user"].(string)
+	stdReq.Template = util.ChatTemplateFor(store, resolvedModel, route.Key)
+	stdReq.FitPrompt(promptMessages, util.NewContextPolicy(store.ContextConfig(), store, resolvedModel))
+	return stdReq, nil
+}
+
+// openAIChatMaxTokens prefers max_completion_tokens, which covers reasoning
+// tokens, over the legacy max_tokens, which only covers visible output.
+func openAIChatMaxTokens(req map[string]any) (int, bool) {
+	if n := util.MaxTokensFrom(req["max_completion_tokens"]); n > 0 {
+		return n, true
+	}
+	return util.MaxTokensFrom(req["max_tokens"]), false
 }
 
-func normalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, traceID string) (util.StandardRequest, error) {
+func normalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, route config.RouteInput, traceID string) (util.StandardRequest, error) {
 	model, _ := req["model"].(string)
 	model = strings.TrimSpace(model)
 	if model == "" {
 		return util.StandardRequest{}, fmt.Errorf("Request must include 'model'.")
 	}
-	resolvedModel, ok := config.ResolveModel(store, model)
+	route.Model, route.Surface = model, config.ModelSurfaceOpenAI
+	decision, ok := config.ResolveRoute(store, route)
 	if !ok {
 		return util.StandardRequest{}, fmt.Errorf("Model '%s' is not available.", model)
 	}
-	thinkingEnabled, searchEnabled, _ := config.GetModelConfig(resolvedModel)
+	resolvedModel, thinkingEnabled, searchEnabled := decision.Model, decision.Thinking, decision.Search
+	thinkingEnabled, searchEnabled = applyOpenAIResponsesCapabilities(req, thinkingEnabled, searchEnabled)
+	reasoningMode, err := requestReasoningMode(store, req, route)
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
 
 	// Keep width-control as an explicit policy hook even if current default is true.
 	allowWideInput := true
@@ -73,7 +112,12 @@ func normalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, tra
 	if err != nil {
 		return util.StandardRequest{}, err
 	}
-	finalPrompt, toolNames := buildOpenAIFinalPromptWithPolicy(messagesRaw, req["tools"], traceID, toolPolicy)
+	contextPolicy, err := util.NewContextPolicy(store.ContextConfig(), store, resolvedModel).WithTruncation(req["truncation"])
+	if err != nil {
+		return util.StandardRequest{}, err
+	}
+	toolPrompt := util.ToolInstructionsFor(store, config.ModelSurfaceOpenAI, resolvedModel, route.Key)
+	promptMessages, toolNames := buildOpenAIPromptMessages(messagesRaw, req["tools"], traceID, toolPolicy, toolPrompt)
 	if toolPolicy.IsNone() {
 		toolNames = nil
 		toolPolicy.Allowed = nil
@@ -82,20 +126,29 @@ func normalizeOpenAIResponsesRequest(store ConfigReader, req map[string]any, tra
 	}
 	passThrough := collectOpenAIChatPassThrough(req)
 
-	return util.StandardRequest{
+	stdReq := util.StandardRequest{
 		Surface:        "openai_responses",
 		RequestedModel: model,
 		ResolvedModel:  resolvedModel,
 		ResponseModel:  model,
 		Messages:       messagesRaw,
-		FinalPrompt:    finalPrompt,
 		ToolNames:      toolNames,
+		ToolSchemas:    util.CompileToolSchemas(req["tools"]),
 		ToolChoice:     toolPolicy,
 		Stream:         util.ToBool(req["stream"]),
 		Thinking:       thinkingEnabled,
 		Search:         searchEnabled,
 		PassThrough:    passThrough,
-	}, nil
+
+		ReasoningMode:            reasoningMode,
+		MaxOutputTokens:          util.MaxTokensFrom(req["max_output_tokens"]),
+		MaxTokensIncludeThinking: true,
+	}
+	stdReq.AccountGroup = decision.AccountGroup
+	stdReq.EndUser, _ = req["user"].(string)
+	stdReq.Template = util.ChatTemplateFor(store, resolvedModel, route.Key)
+	stdReq.FitPrompt(promptMessages, contextPolicy)
+	return stdReq, nil
 }
 
 func collectOpenAIChatPassThrough(req map[string]any) map[string]any {
@@ -158,6 +211,10 @@ func parseToolChoicePolicy(toolChoiceRaw any, toolsRaw any) (util.ToolChoicePoli
 		}
 
 		typ := strings.ToLower(strings.TrimSpace(asString(v["type"])))
+		if isResponsesWebSearchType(typ) {
+			// Search runs upstream on every turn it is enabled; nothing to force.
+			typ = "auto"
+		}
 		switch typ {
 		case "", "auto":
 			if hasFunctionSelector(v) {
@@ -23,7 +23,7 @@ func TestNormalizeOpenAIChatRequest(t *testing.T) {
 		"temperature": 0.3,
 		"stream":      true,
 	}
-	n, err := normalizeOpenAIChatRequest(store, req, "")
+	n, err := normalizeOpenAIChatRequest(store, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -48,7 +48,7 @@ func TestNormalizeOpenAIResponsesRequestInput(t *testing.T) {
 		"input":        "ping",
 		"instructions": "system",
 	}
-	n, err := normalizeOpenAIResponsesRequest(store, req, "")
+	n, err := normalizeOpenAIResponsesRequest(store, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -78,7 +78,7 @@ func TestNormalizeOpenAIResponsesRequestToolChoiceRequired(t *testing.T) {
 		},
 		"tool_choice": "required",
 	}
-	n, err := normalizeOpenAIResponsesRequest(store, req, "")
+	n, err := normalizeOpenAIResponsesRequest(store, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -114,7 +114,7 @@ func TestNormalizeOpenAIResponsesRequestToolChoiceForcedFunction(t *testing.T) {
 			"name": "read_file",
 		},
 	}
-	n, err := normalizeOpenAIResponsesRequest(store, req, "")
+	n, err := normalizeOpenAIResponsesRequest(store, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -147,7 +147,7 @@ func TestNormalizeOpenAIResponsesRequestToolChoiceForcedUndeclaredFails(t *testi
 			"name": "read_file",
 		},
 	}
-	if _, err := normalizeOpenAIResponsesRequest(store, req, ""); err == nil {
+	if _, err := normalizeOpenAIResponsesRequest(store, req, config.RouteInput{}, ""); err == nil {
 		t.Fatalf("expected forced undeclared tool to fail")
 	}
 }
@@ -167,7 +167,7 @@ func TestNormalizeOpenAIResponsesRequestToolChoiceNoneDisablesTools(t *testing.T
 		},
 		"tool_choice": "none",
 	}
-	n, err := normalizeOpenAIResponsesRequest(store, req, "")
+	n, err := normalizeOpenAIResponsesRequest(store, req, config.RouteInput{}, "")
 	if err != nil {
 		t.Fatalf("normalize failed: %v", err)
 	}
@@ -178,3 +178,67 @@ func TestNormalizeOpenAIResponsesRequestToolChoiceNoneDisablesTools(t *testing.T
 		t.Fatalf("expected no tool names when tool_choice=none, got %#v", n.ToolNames)
 	}
 }
+
+func TestNormalizeOpenAIChatRequestMaxTokens(t *testing.T) {
+	store := newEmptyStoreForNormalizeTest(t)
+	messages := []any{map[string]any{"role": "user", "content": "hello"}}
+
+	n, err := normalizeOpenAIChatRequest(store, map[string]any{"model": "deepseek-chat", "messages": messages, "max_tokens": float64(64)}, config.RouteInput{}, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if n.MaxOutputTokens != 64 || n.MaxTokensIncludeThinking {
+		t.Fatalf("expected max_tokens=64 without thinking, got %d %v", n.MaxOutputTokens, n.MaxTokensIncludeThinking)
+	}
+
+	n, err = normalizeOpenAIChatRequest(store, map[string]any{
+		"model":                 "deepseek-chat",
+		"messages":              messages,
+		"max_tokens":            float64(64),
+		"max_completion_tokens": float64(128),
+	}, config.RouteInput{}, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if n.MaxOutputTokens != 128 || !n.MaxTokensIncludeThinking {
+		t.Fatalf("expected max_completion_tokens to win and include thinking, got %d %v", n.MaxOutputTokens, n.MaxTokensIncludeThinking)
+	}
+}
+
+func TestNormalizeOpenAIChatRequestCapabilityToggles(t *testing.T) {
+	store := newEmptyStoreForNormalizeTest(t)
+	req := map[string]any{
+		"model":              "deepseek-reasoner",
+		"messages":           []any{map[string]any{"role": "user", "content": "hello"}},
+		"web_search_options": map[string]any{},
+		"reasoning_effort":   "minimal",
+	}
+	n, err := normalizeOpenAIChatRequest(store, req, config.RouteInput{}, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !n.Search || n.Thinking {
+		t.Fatalf("expected search on and thinking off, got search=%v thinking=%v", n.Search, n.Thinking)
+	}
+}
+
+func TestNormalizeOpenAIResponsesRequestCapabilityToggles(t *testing.T) {
+	store := newEmptyStoreForNormalizeTest(t)
+	req := map[string]any{
+		"model":       "deepseek-chat",
+		"input":       "hello",
+		"tools":       []any{map[string]any{"type": "web_search_preview"}},
+		"tool_choice": map[string]any{"type": "web_search_preview"},
+		"reasoning":   map[string]any{"effort": "high"},
+	}
+	n, err := normalizeOpenAIResponsesRequest(store, req, config.RouteInput{}, "")
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if !n.Search || !n.Thinking {
+		t.Fatalf("expected search and thinking on, got search=%v thinking=%v", n.Search, n.Thinking)
+	}
+	if len(n.ToolNames) != 0 {
+		t.Fatalf("web search tool must not become a function tool: %#v", n.ToolNames)
+	}
+}
@@ -35,6 +35,11 @@ func (streamStatusAuthStub) DetermineCaller(_ *http.Request) (*auth.RequestAuth,
 	}, nil
 }
 
+func (streamStatusAuthStub) Fork(_ context.Context, a *auth.RequestAuth) (*auth.RequestAuth, error) {
+	forked := *a
+	return &forked, nil
+}
+
 func (streamStatusAuthStub) Release(_ *auth.RequestAuth) {}
 
 type streamStatusDSStub struct {
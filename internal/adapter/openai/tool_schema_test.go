@@ -0,0 +1,212 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ds2api/internal/auth"
+)
+
+// toolRepairDSStub answers the request with an invalid tool call, or with
+// output when set, and the repair prompt with a corrected one, counting the
+// repair calls.
+type toolRepairDSStub struct {
+	mu      *sync.Mutex
+	repairs *int
+	output  string
+}
+
+func (m toolRepairDSStub) CreateSession(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "session-id", nil
+}
+
+func (m toolRepairDSStub) GetPow(_ context.Context, _ *auth.RequestAuth, _ int) (string, error) {
+	return "pow", nil
+}
+
+func (m toolRepairDSStub) CallCompletion(_ context.Context, _ *auth.RequestAuth, payload map[string]any, _ string, _ int) (*http.Response, error) {
+	prompt, _ := payload["prompt"].(string)
+	out := `{"tool_calls":[{"name":"search","input":{"limit":"3","extra":true}}]}`
+	if m.output != "" {
+		out = m.output
+	}
+	if strings.Contains(prompt, "do not match its parameter schema") {
+		m.mu.Lock()
+		*m.repairs++
+		m.mu.Unlock()
+		out = `{"tool_calls":[{"name":"search","input":{"query":"golang","limit":3}}]}`
+	}
+	v, _ := json.Marshal(out)
+	return makeOpenAISSEHTTPResponse(`data: {"p":"response/content","v":`+string(v)+`}`, "data: [DONE]"), nil
+}
+
+func postToolSchemaChat(t *testing.T, cfg mockOpenAIConfig, stream bool) (*httptest.ResponseRecorder, int) {
+	t.Helper()
+	return postToolSchemaChatOutput(t, cfg, stream, "")
+}
+
+func postToolSchemaChatOutput(t *testing.T, cfg mockOpenAIConfig, stream bool, output string) (*httptest.ResponseRecorder, int) {
+	t.Helper()
+	repairs := 0
+	h := &Handler{Store: cfg, Auth: streamStatusAuthStub{}, DS: toolRepairDSStub{mu: &sync.Mutex{}, repairs: &repairs, output: output}}
+	r := chi.NewRouter()
+	RegisterRoutes(r, h)
+	body, _ := json.Marshal(map[string]any{
+		"model":    "deepseek-chat",
+		"stream":   stream,
+		"messages": []any{map[string]any{"role": "user", "content": "search for golang"}},
+		"tools": []any{map[string]any{"type": "function", "function": map[string]any{
+			"name":   "search",
+			"strict": true,
+			"parameters": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"query": map[string]any{"type": "string"}, "limit": map[string]any{"type": "integer"}},
+				"required":   []any{"query"},
+			},
+		}}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", "Bearer direct-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec, repairs
+}
+
+func TestChatCompletionsRepairsInvalidToolCall(t *testing.T) {
+	rec, repairs := postToolSchemaChat(t, mockOpenAIConfig{wideInput: true, repairs: 1}, false)
+	if rec.Code != http.StatusOK || repairs != 1 {
+		t.Fatalf("expected one repair round-trip, got %d repairs, %d %s", repairs, rec.Code, rec.Body.String())
+	}
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	choice := out["choices"].([]any)[0].(map[string]any)
+	calls, _ := choice["message"].(map[string]any)["tool_calls"].([]any)
+	if choice["finish_reason"] != "tool_calls" || len(calls) != 1 {
+		t.Fatalf("expected the repaired tool call, got %#v", choice)
+	}
+	args := calls[0].(map[string]any)["function"].(map[string]any)["arguments"]
+	if args != `{"limit":3,"query":"golang"}` {
+		t.Fatalf("unexpected repaired arguments %v", args)
+	}
+}
+
+func TestChatCompletionsStreamDropsUnrepairedToolCall(t *testing.T) {
+	rec, repairs := postToolSchemaChat(t, mockOpenAIConfig{wideInput: true}, true)
+	if repairs != 0 {
+		t.Fatalf("expected no repair with repair_attempts 0, got %d", repairs)
+	}
+	// Argument deltas stream as written, but the call is not closed with a
+	// complete tool_calls chunk and the choice does not finish with tool_calls.
+	streamed, closed, finish := streamedToolCallArguments(t, rec)
+	if streamed != `{"limit":"3","extra":true}` {
+		t.Fatalf("expected argument deltas to stream as written, got %q", streamed)
+	}
+	if len(closed) != 0 || finish != "stop" {
+		t.Fatalf("invalid tool call must not be closed, got %v finish=%q", closed, finish)
+	}
+}
+
+func TestChatCompletionsStreamsDeltasAndClosesWithCheckedArguments(t *testing.T) {
+	output := `{"tool_calls":[{"name":"search","input":{"query":"golang","limit":"3"}}]}`
+	rec, _ := postToolSchemaChatOutput(t, mockOpenAIConfig{wideInput: true}, true, output)
+	streamed, closed, finish := streamedToolCallArguments(t, rec)
+	if streamed != `{"query":"golang","limit":"3"}` {
+		t.Fatalf("expected argument deltas as written, got %q", streamed)
+	}
+	if len(closed) != 1 || closed[0] != `{"limit":3,"query":"golang"}` || finish != "tool_calls" {
+		t.Fatalf("expected closing chunk with coerced arguments, got %v finish=%q", closed, finish)
+	}
+}
+
+// streamedToolCallArguments splits the tool_calls of a chat stream into the
+// concatenated argument deltas and the arguments of closing chunks, which
+// carry the name and the whole arguments together.
+func streamedToolCallArguments(t *testing.T, rec *httptest.ResponseRecorder) (string, []string, string) {
+	t.Helper()
+	frames, done := parseSSEDataFrames(t, rec.Body.String())
+	if !done {
+		t.Fatalf("expected [DONE], body=%s", rec.Body.String())
+	}
+	var streamed strings.Builder
+	var closed []string
+	finish := ""
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, c := range choices {
+			choice, _ := c.(map[string]any)
+			if reason, _ := choice["finish_reason"].(string); reason != "" {
+				finish = reason
+			}
+			delta, _ := choice["delta"].(map[string]any)
+			calls, _ := delta["tool_calls"].([]any)
+			for _, call := range calls {
+				fn, _ := call.(map[string]any)["function"].(map[string]any)
+				args, _ := fn["arguments"].(string)
+				if fn["name"] != nil && args != "" {
+					closed = append(closed, args)
+					continue
+				}
+				streamed.WriteString(args)
+			}
+		}
+	}
+	return streamed.String(), closed, finish
+}
+
+func TestResponsesStreamClosesToolCallsWithCheckedArguments(t *testing.T) {
+	cases := []struct {
+		name, output, status, args string
+	}{
+		{"coerced", `{"tool_calls":[{"name":"search","input":{"query":"golang","limit":"3"}}]}`, "completed", `{"limit":3,"query":"golang"}`},
+		{"invalid", `{"tool_calls":[{"name":"search","input":{"limit":"3","extra":true}}]}`, "incomplete", `{"limit":"3","extra":true}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repairs := 0
+			h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: streamStatusAuthStub{}, DS: toolRepairDSStub{mu: &sync.Mutex{}, repairs: &repairs, output: tc.output}}
+			r := chi.NewRouter()
+			RegisterRoutes(r, h)
+			body, _ := json.Marshal(map[string]any{
+				"model":  "deepseek-chat",
+				"stream": true,
+				"input":  "search for golang",
+				"tools": []any{map[string]any{
+					"type":   "function",
+					"name":   "search",
+					"strict": true,
+					"parameters": map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"query": map[string]any{"type": "string"}, "limit": map[string]any{"type": "integer"}},
+						"required":   []any{"query"},
+					},
+				}},
+			})
+			req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(string(body)))
+			req.Header.Set("Authorization", "Bearer direct-token")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			out := rec.Body.String()
+			if len(extractAllSSEEventPayloads(out, "response.function_call_arguments.delta")) == 0 {
+				t.Fatalf("expected argument deltas, body=%s", out)
+			}
+			done := extractAllSSEEventPayloads(out, "response.output_item.done")
+			var item map[string]any
+			for _, payload := range done {
+				if it, _ := payload["item"].(map[string]any); it["type"] == "function_call" {
+					item = it
+				}
+			}
+			if item == nil || item["status"] != tc.status || item["arguments"] != tc.args {
+				t.Fatalf("expected %s function_call with %s, got %#v", tc.status, tc.args, item)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleStreamXMLToolCallsInterceptedAsOneBatch(t *testing.T) {
+	h := &Handler{Store: mockOpenAIConfig{toolMode: "all"}}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"Checking.\n<tool"}`,
+		`data: {"p":"response/content","v":"_call>{\"name\":\"search\",\"arguments\":{\"q\":\"go\"}}</tool_call>\n"}`,
+		`data: {"p":"response/content","v":"<function=read_file><parameter=path>a.txt</parameter></function>"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-xml", "deepseek-chat", "prompt", false, false, []string{"search", "read_file"})
+
+	frames, _ := parseSSEDataFrames(t, rec.Body.String())
+	content := ""
+	var names []string
+	for _, frame := range frames {
+		choices, _ := frame["choices"].([]any)
+		for _, item := range choices {
+			choice, _ := item.(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			if c, ok := delta["content"].(string); ok {
+				content += c
+			}
+			toolCalls, _ := delta["tool_calls"].([]any)
+			for _, tc := range toolCalls {
+				fn, _ := tc.(map[string]any)["function"].(map[string]any)
+				if name, ok := fn["name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	if strings.Contains(content, "<") || strings.TrimSpace(content) != "Checking." {
+		t.Fatalf("tool tags leaked into content: %q", content)
+	}
+	if strings.Join(names, ",") != "search,read_file" {
+		t.Fatalf("expected both calls in one batch, got %v body=%s", names, rec.Body.String())
+	}
+	if streamFinishReason(frames) != "tool_calls" {
+		t.Fatalf("expected finish_reason=tool_calls, body=%s", rec.Body.String())
+	}
+}
+
+func TestHandleStreamFunctionTagNotParsedInFeatureMatchMode(t *testing.T) {
+	h := &Handler{Store: mockOpenAIConfig{toolMode: "feature_match"}}
+	resp := makeSSEHTTPResponse(
+		`data: {"p":"response/content","v":"<function=search><parameter=q>go</parameter></function>"}`,
+		`data: [DONE]`,
+	)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h.handleStream(rec, req, resp, "cid-json", "deepseek-chat", "prompt", false, false, []string{"search"})
+
+	frames, _ := parseSSEDataFrames(t, rec.Body.String())
+	if streamHasToolCallsDelta(frames) || !strings.Contains(rec.Body.String(), "parameter=q") {
+		t.Fatalf("feature_match must leave XML as text, body=%s", rec.Body.String())
+	}
+}
@@ -1,10 +1,15 @@
 package openai
 
 import (
-	"ds2api/internal/auth"
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
 )
 
 func TestIsVercelStreamPrepareRequest(t *testing.T) {
@@ -81,3 +86,41 @@ func TestStreamLeaseTTL(t *testing.T) {
 		t.Fatalf("expected default ttl on invalid value, got %v", got)
 	}
 }
+
+type proxiedAccountAuthStub struct {
+	streamStatusAuthStub
+	released *int
+}
+
+func (s proxiedAccountAuthStub) Determine(_ *http.Request) (*auth.RequestAuth, error) {
+	return &auth.RequestAuth{
+		UseConfigToken: true,
+		DeepSeekToken:  "account-token",
+		AccountID:      "acc@example.com",
+		Account:        config.Account{Email: "acc@example.com", Proxy: "socks5://10.0.0.3:1080"},
+		TriedAccounts:  map[string]bool{},
+	}, nil
+}
+
+func (s proxiedAccountAuthStub) Release(_ *auth.RequestAuth) { *s.released++ }
+
+func TestVercelPrepareHandsProxiedAccountsToGo(t *testing.T) {
+	t.Setenv("VERCEL", "1")
+	t.Setenv("DS2API_VERCEL_INTERNAL_SECRET", "stream-secret")
+	calls, released := 0, 0
+	h := &Handler{Store: mockOpenAIConfig{wideInput: true}, Auth: proxiedAccountAuthStub{released: &released}, DS: countingDSStub{calls: &calls}}
+	body := `{"model":"deepseek-chat","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?__stream_prepare=1", strings.NewReader(body))
+	req.Header.Set("X-Ds2-Internal-Token", "stream-secret")
+	rec := httptest.NewRecorder()
+	h.ChatCompletions(rec, req)
+
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusOK || out["go_stream"] != true || out["deepseek_token"] != nil {
+		t.Fatalf("expected the prepare to hand the stream to Go, got %d %s", rec.Code, rec.Body.String())
+	}
+	if released != 1 || len(h.streamLeases) != 0 {
+		t.Fatalf("expected the account released without a lease, got %d releases, %d leases", released, len(h.streamLeases))
+	}
+}
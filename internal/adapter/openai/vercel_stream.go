@@ -14,6 +14,7 @@ import (
 
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
+	"ds2api/internal/upstream"
 	"ds2api/internal/util"
 )
 
@@ -56,7 +57,7 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		writeOpenAIError(w, http.StatusBadRequest, "stream must be true")
 		return
 	}
-	stdReq, err := normalizeOpenAIChatRequest(h.Store, req, requestTraceID(r))
+	stdReq, err := normalizeOpenAIChatRequest(h.Store, req, auth.RouteInput(r), requestTraceID(r))
 	if err != nil {
 		writeOpenAIError(w, http.StatusBadRequest, err.Error())
 		return
@@ -65,6 +66,21 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		writeOpenAIError(w, http.StatusBadRequest, "stream must be true")
 		return
 	}
+	if stdReq.Choices > 1 {
+		writeOpenAIError(w, http.StatusBadRequest, "n > 1 is not supported on the Vercel stream path.")
+		return
+	}
+	stdReq = upstream.FitContext(r.Context(), h.DS, a, w.Header(), stdReq)
+	if err := upstream.BindAccount(r.Context(), a, stdReq); err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if a.Account.Proxy != "" {
+		// Node fetch cannot reach DeepSeek through the account's proxy; the
+		// Node side hands the request to the Go stream path instead.
+		writeJSON(w, http.StatusOK, map[string]any{"go_stream": true})
+		return
+	}
 
 	sessionID, err := h.DS.CreateSession(r.Context(), a, 3)
 	if err != nil {
@@ -92,6 +108,7 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	leased = true
+	upstream := config.UpstreamOf(h.Store)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"session_id":               sessionID,
 		"lease_id":                 leaseID,
@@ -99,12 +116,23 @@ func (h *Handler) handleVercelStreamPrepare(w http.ResponseWriter, r *http.Reque
 		"final_prompt":             stdReq.FinalPrompt,
 		"thinking_enabled":         stdReq.Thinking,
 		"search_enabled":           stdReq.Search,
+		"reasoning_mode":           stdReq.ReasoningMode,
 		"tool_names":               stdReq.ToolNames,
 		"toolcall_feature_match":   h.toolcallFeatureMatchEnabled(),
+		"toolcall_dialects":        h.toolcallDialects().Names(),
 		"toolcall_early_emit_high": h.toolcallEarlyEmitHighConfidence(),
+		"toolcall_schemas":         stdReq.ToolSchemas,
 		"deepseek_token":           a.DeepSeekToken,
 		"pow_header":               powHeader,
+		"completion_url":           upstream.URL(config.UpstreamCompletion),
+		"upstream_host":            upstream.Host,
 		"payload":                  payload,
+		"output_limits": map[string]any{
+			"stop_sequences": stdReq.StopSequences,
+			"max_tokens":     stdReq.MaxOutputTokens,
+			"count_thinking": stdReq.MaxTokensIncludeThinking,
+			"citations":      stdReq.Search,
+		},
 	})
 }
 
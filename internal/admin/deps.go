@@ -27,6 +27,10 @@ type ConfigStore interface {
 	RuntimeAccountMaxInflight() int
 	RuntimeAccountMaxQueue(defaultSize int) int
 	RuntimeGlobalMaxInflight(defaultSize int) int
+	Models() []config.ModelConfig
+	ModelAliases() map[string]string
+	RoutingRules() []config.RoutingRule
+	PromptTemplates() []config.PromptTemplate
 }
 
 type PoolController interface {
@@ -57,6 +57,9 @@ func (h *Handler) listAccounts(w http.ResponseWriter, r *http.Request) {
 			"has_token":     token != "",
 			"token_preview": preview,
 			"test_status":   acc.TestStatus,
+			"groups":        append([]string{}, config.NormalizeGroups(acc.Groups)...),
+			"proxy":         config.RedactProxy(acc.Proxy),
+			"profile":       acc.Profile,
 		})
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"items": items, "total": total, "page": page, "page_size": pageSize, "total_pages": totalPages})
@@ -70,6 +73,14 @@ func (h *Handler) addAccount(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "需要 email 或 mobile"})
 		return
 	}
+	if _, err := acc.ProxyURL(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	if acc.Profile != "" && !h.Store.Snapshot().Identity.HasProfile(acc.Profile) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": fmt.Sprintf("未知的客户端身份配置 %q", acc.Profile)})
+		return
+	}
 	err := h.Store.Update(func(c *config.Config) error {
 		for _, a := range c.Accounts {
 			if acc.Email != "" && a.Email == acc.Email {
@@ -90,6 +101,62 @@ func (h *Handler) addAccount(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "total_accounts": len(h.Store.Snapshot().Accounts)})
 }
 
+// updateAccount changes the groups, the outbound proxy and the client
+// identity profile of an account. Fields missing from the body are left as
+// they are.
+func (h *Handler) updateAccount(w http.ResponseWriter, r *http.Request) {
+	identifier := chi.URLParam(r, "identifier")
+	var req map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	_, hasGroups := req["groups"]
+	_, hasProxy := req["proxy"]
+	_, hasProfile := req["profile"]
+	if !hasGroups && !hasProxy && !hasProfile {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "需要 groups、proxy 或 profile"})
+		return
+	}
+	groups := fieldGroups(req)
+	proxy := fieldString(req, "proxy")
+	if _, err := config.ParseProxyURL(proxy); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	profile := fieldString(req, "profile")
+	if profile != "" && !h.Store.Snapshot().Identity.HasProfile(profile) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": fmt.Sprintf("未知的客户端身份配置 %q", profile)})
+		return
+	}
+	var updated config.Account
+	err := h.Store.Update(func(c *config.Config) error {
+		for i, a := range c.Accounts {
+			if accountMatchesIdentifier(a, identifier) {
+				if hasGroups {
+					c.Accounts[i].Groups = groups
+				}
+				if hasProxy {
+					c.Accounts[i].Proxy = proxy
+				}
+				if hasProfile {
+					c.Accounts[i].Profile = profile
+				}
+				updated = c.Accounts[i]
+				return nil
+			}
+		}
+		return fmt.Errorf("账号不存在")
+	})
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"groups":  append([]string{}, config.NormalizeGroups(updated.Groups)...),
+		"proxy":   config.RedactProxy(updated.Proxy),
+		"profile": updated.Profile,
+	})
+}
+
 func (h *Handler) deleteAccount(w http.ResponseWriter, r *http.Request) {
 	identifier := chi.URLParam(r, "identifier")
 	err := h.Store.Update(func(c *config.Config) error {
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAccountGroupsThroughAccountCRUD(t *testing.T) {
+	h := newAdminTestHandler(t, `{"accounts":[{"email":"a@example.com","password":"pwd"}]}`)
+	r := chi.NewRouter()
+	r.Get("/admin/accounts", h.listAccounts)
+	r.Post("/admin/accounts", h.addAccount)
+	r.Put("/admin/accounts/{identifier}", h.updateAccount)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/admin/accounts", `{"email":"b@example.com","password":"pwd","groups":"research, Research ,ops"}`); rec.Code != http.StatusOK {
+		t.Fatalf("add failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if acc, _ := h.Store.FindAccount("b@example.com"); !slices.Equal(acc.Groups, []string{"research", "ops"}) {
+		t.Fatalf("expected normalized groups, got %#v", acc.Groups)
+	}
+	if rec := serve(http.MethodPut, "/admin/accounts/a@example.com", `{"groups":["research"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("update failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/admin/accounts/missing@example.com", `{"groups":["research"]}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown account, got %d", rec.Code)
+	}
+
+	var payload struct {
+		Items []struct {
+			Identifier string   `json:"identifier"`
+			Groups     []string `json:"groups"`
+		} `json:"items"`
+	}
+	_ = json.Unmarshal(serve(http.MethodGet, "/admin/accounts", "").Body.Bytes(), &payload)
+	for _, item := range payload.Items {
+		if !slices.Contains(item.Groups, "research") {
+			t.Fatalf("expected %s listed in research, got %#v", item.Identifier, item.Groups)
+		}
+	}
+}
@@ -107,7 +107,7 @@ func (h *Handler) testAccount(ctx context.Context, acc config.Account, model, me
 		token = newToken
 		_ = h.Store.UpdateAccountToken(acc.Identifier(), token)
 	}
-	authCtx := &authn.RequestAuth{UseConfigToken: false, DeepSeekToken: token}
+	authCtx := &authn.RequestAuth{UseConfigToken: false, DeepSeekToken: token, Account: acc}
 	sessionID, err := h.DS.CreateSession(ctx, authCtx, 1)
 	if err != nil {
 		newToken, loginErr := h.DS.Login(ctx, acc)
@@ -130,7 +130,7 @@ func (h *Handler) testAccount(ctx context.Context, acc config.Account, model, me
 		result["response_time"] = int(time.Since(start).Milliseconds())
 		return result
 	}
-	thinking, search, ok := config.GetModelConfig(model)
+	thinking, search, ok := config.ModelConfigFor(h.Store, model)
 	if !ok {
 		thinking, search = false, false
 	}
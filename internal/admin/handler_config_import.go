@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"ds2api/internal/config"
@@ -105,6 +106,14 @@ func (h *Handler) configImport(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
+			if len(incoming.KeyGroups) > 0 {
+				if next.KeyGroups == nil {
+					next.KeyGroups = map[string]string{}
+				}
+				for k, v := range incoming.KeyGroups {
+					next.KeyGroups[k] = v
+				}
+			}
 			if len(incoming.ModelAliases) > 0 {
 				if next.ModelAliases == nil {
 					next.ModelAliases = map[string]string{}
@@ -113,18 +122,74 @@ func (h *Handler) configImport(w http.ResponseWriter, r *http.Request) {
 					next.ModelAliases[k] = v
 				}
 			}
+			if len(incoming.Models) > 0 {
+				next.Models = mergeImportedModels(next.Models, incoming.Models)
+			}
+			if len(incoming.RoutingRules) > 0 {
+				// Rules are ordered, so an imported list replaces the current one.
+				next.RoutingRules = incoming.RoutingRules
+			}
+			if len(incoming.PromptTemplates) > 0 {
+				// Templates and tool prompts are selected in order too, so they
+				// are replaced as a whole.
+				next.PromptTemplates = incoming.PromptTemplates
+			}
+			if len(incoming.ToolPrompts) > 0 {
+				next.ToolPrompts = incoming.ToolPrompts
+			}
 			if strings.TrimSpace(incoming.Toolcall.Mode) != "" {
 				next.Toolcall.Mode = incoming.Toolcall.Mode
 			}
 			if strings.TrimSpace(incoming.Toolcall.EarlyEmitConfidence) != "" {
 				next.Toolcall.EarlyEmitConfidence = incoming.Toolcall.EarlyEmitConfidence
 			}
+			if incoming.Toolcall.RepairAttempts != nil {
+				next.Toolcall.RepairAttempts = incoming.Toolcall.RepairAttempts
+			}
 			if incoming.Responses.StoreTTLSeconds > 0 {
 				next.Responses.StoreTTLSeconds = incoming.Responses.StoreTTLSeconds
 			}
+			if incoming.Responses.StreamGraceSeconds > 0 {
+				next.Responses.StreamGraceSeconds = incoming.Responses.StreamGraceSeconds
+			}
+			if strings.TrimSpace(incoming.Compat.ReasoningMode) != "" {
+				next.Compat.ReasoningMode = incoming.Compat.ReasoningMode
+			}
+			if incoming.Compat.KeyReasoningModes != nil {
+				next.Compat.KeyReasoningModes = incoming.Compat.KeyReasoningModes
+			}
 			if strings.TrimSpace(incoming.Embeddings.Provider) != "" {
 				next.Embeddings.Provider = incoming.Embeddings.Provider
 			}
+			if strings.TrimSpace(incoming.Context.Strategy) != "" {
+				next.Context.Strategy = incoming.Context.Strategy
+			}
+			if incoming.Context.MaxPromptTokens > 0 {
+				next.Context.MaxPromptTokens = incoming.Context.MaxPromptTokens
+			}
+			if incoming.Context.ToolResultMaxTokens > 0 {
+				next.Context.ToolResultMaxTokens = incoming.Context.ToolResultMaxTokens
+			}
+			if incoming.ResponseCache != (config.ResponseCacheConfig{}) {
+				// The section is replaced as a whole so an import can turn
+				// the cache off.
+				next.ResponseCache = incoming.ResponseCache
+			}
+			if incoming.Coalesce.Enabled || incoming.Coalesce.MaxSubscribers > 0 || incoming.Coalesce.OptOutKeys != nil {
+				next.Coalesce = incoming.Coalesce
+			}
+			if len(incoming.Scheduling.Classes) > 0 || len(incoming.Scheduling.KeyClasses) > 0 || strings.TrimSpace(incoming.Scheduling.DefaultClass) != "" {
+				next.Scheduling = incoming.Scheduling
+			}
+			if incoming.Affinity.Enabled || incoming.Affinity.Sources != nil || incoming.Affinity.FallbackAccounts > 0 {
+				next.Affinity = incoming.Affinity
+			}
+			if incoming.Identity.Profile != "" || incoming.Identity.Diversify || incoming.Identity.Pool != nil || incoming.Identity.Profiles != nil {
+				next.Identity = incoming.Identity
+			}
+			if strings.TrimSpace(incoming.Upstream.BaseURL) != "" || incoming.Upstream.Endpoints != nil {
+				next.Upstream = incoming.Upstream
+			}
 			if strings.TrimSpace(incoming.Admin.PasswordHash) != "" {
 				next.Admin.PasswordHash = incoming.Admin.PasswordHash
 			}
@@ -180,3 +245,20 @@ func (h *Handler) computeSyncHash() string {
 	sum := md5.Sum(b)
 	return fmt.Sprintf("%x", sum)
 }
+
+// mergeImportedModels replaces models with the same id and appends new ones.
+func mergeImportedModels(current, incoming []config.ModelConfig) []config.ModelConfig {
+	out := slices.Clone(current)
+	for _, m := range incoming {
+		id := strings.ToLower(strings.TrimSpace(m.ID))
+		i := slices.IndexFunc(out, func(existing config.ModelConfig) bool {
+			return strings.ToLower(strings.TrimSpace(existing.ID)) == id
+		})
+		if i >= 0 {
+			out[i] = m
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
@@ -3,13 +3,16 @@ package admin
 import (
 	"net/http"
 	"strings"
+
+	"ds2api/internal/config"
 )
 
 func (h *Handler) getConfig(w http.ResponseWriter, _ *http.Request) {
 	snap := h.Store.Snapshot()
 	safe := map[string]any{
-		"keys":     snap.Keys,
-		"accounts": []map[string]any{},
+		"keys":       snap.Keys,
+		"key_groups": snap.KeyGroups,
+		"accounts":   []map[string]any{},
 		"claude_mapping": func() map[string]string {
 			if len(snap.ClaudeMapping) > 0 {
 				return snap.ClaudeMapping
@@ -35,6 +38,9 @@ func (h *Handler) getConfig(w http.ResponseWriter, _ *http.Request) {
 			"has_password":  strings.TrimSpace(acc.Password) != "",
 			"has_token":     token != "",
 			"token_preview": preview,
+			"groups":        append([]string{}, config.NormalizeGroups(acc.Groups)...),
+			"proxy":         config.RedactProxy(acc.Proxy),
+			"profile":       acc.Profile,
 		})
 	}
 	safe["accounts"] = accounts
@@ -42,11 +42,17 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 					if strings.TrimSpace(acc.Token) == "" {
 						acc.Token = prev.Token
 					}
+					if _, ok := m["groups"]; !ok {
+						acc.Groups = prev.Groups
+					}
 				}
 				accounts = append(accounts, acc)
 			}
 			c.Accounts = accounts
 		}
+		if m, ok := req["key_groups"].(map[string]any); ok {
+			c.KeyGroups = keyGroupsFrom(m)
+		}
 		if m, ok := req["claude_mapping"].(map[string]any); ok {
 			newMap := map[string]string{}
 			for k, v := range m {
@@ -80,6 +86,13 @@ func (h *Handler) addKey(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		c.Keys = append(c.Keys, key)
+		if group := fieldString(req, "group"); group != "" {
+			if c.KeyGroups == nil {
+				c.KeyGroups = map[string]string{}
+			}
+			c.KeyGroups[key] = group
+			return config.ValidateAccountGroups(*c)
+		}
 		return nil
 	})
 	if err != nil {
@@ -103,6 +116,7 @@ func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request) {
 			return fmt.Errorf("Key 不存在")
 		}
 		c.Keys = append(c.Keys[:idx], c.Keys[idx+1:]...)
+		delete(c.KeyGroups, key)
 		return nil
 	})
 	if err != nil {
@@ -164,3 +178,14 @@ func (h *Handler) batchImport(w http.ResponseWriter, r *http.Request) {
 	h.Pool.Reset()
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "imported_keys": importedKeys, "imported_accounts": importedAccounts})
 }
+
+// keyGroupsFrom reads a key → group map, dropping entries without a group.
+func keyGroupsFrom(m map[string]any) map[string]string {
+	out := map[string]string{}
+	for k, v := range m {
+		if key, group := strings.TrimSpace(k), strings.TrimSpace(fmt.Sprintf("%v", v)); key != "" && group != "" {
+			out[key] = group
+		}
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/prompt"
+	"ds2api/internal/util"
+)
+
+type promptTemplatePreviewRequest struct {
+	Name       string           `json:"name"`
+	Template   string           `json:"template"`
+	ImageLinks string           `json:"image_links"`
+	Model      string           `json:"model"`
+	Key        string           `json:"key"`
+	Messages   []map[string]any `json:"messages"`
+}
+
+// samplePromptMessages is rendered when a preview request brings no messages.
+var samplePromptMessages = []map[string]any{
+	{"role": "system", "content": "You are a helpful assistant."},
+	{"role": "user", "content": "What is in this picture? ![cat](https://example.com/cat.png)"},
+	{"role": "assistant", "content": "A cat sitting on a windowsill."},
+	{"role": "user", "content": "What colour is it?"},
+	{"role": "user", "content": "Answer in one word."},
+}
+
+// previewPromptTemplate renders messages, or a sample conversation, with a
+// prompt template: the template text in the request, the configured template
+// with the given name, or the one selected for model and key.
+func (h *Handler) previewPromptTemplate(w http.ResponseWriter, r *http.Request) {
+	var req promptTemplatePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid json"})
+		return
+	}
+	tmpl, status, detail := h.previewTemplate(req)
+	if tmpl == nil {
+		writeJSON(w, status, map[string]any{"detail": detail})
+		return
+	}
+	messages := req.Messages
+	if len(messages) == 0 {
+		messages = samplePromptMessages
+	}
+	out, err := tmpl.Render(messages)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "模板渲染失败: " + err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"name": tmpl.Name, "image_links": tmpl.ImageLinks, "prompt": out})
+}
+
+func (h *Handler) previewTemplate(req promptTemplatePreviewRequest) (*prompt.ChatTemplate, int, string) {
+	name := strings.TrimSpace(req.Name)
+	if strings.TrimSpace(req.Template) != "" {
+		if name == "" {
+			name = "preview"
+		}
+		tmpl, err := util.ParsePromptTemplate(config.PromptTemplate{Name: name, Template: req.Template, ImageLinks: req.ImageLinks})
+		if err != nil {
+			return nil, http.StatusBadRequest, "模板无效: " + err.Error()
+		}
+		return tmpl, 0, ""
+	}
+	if name == "" {
+		return util.ChatTemplateFor(h.Store, req.Model, strings.TrimSpace(req.Key)), 0, ""
+	}
+	if strings.EqualFold(name, prompt.DefaultChatTemplateName) {
+		return prompt.DefaultChatTemplate(), 0, ""
+	}
+	for _, t := range h.Store.PromptTemplates() {
+		if strings.EqualFold(strings.TrimSpace(t.Name), name) {
+			tmpl, err := util.ParsePromptTemplate(t)
+			if err != nil {
+				return nil, http.StatusBadRequest, "模板无效: " + err.Error()
+			}
+			return tmpl, 0, ""
+		}
+	}
+	return nil, http.StatusNotFound, "模板不存在: " + name
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postPromptPreview(t *testing.T, h *Handler, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/prompt-templates/preview", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	h.previewPromptTemplate(rec, req)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out
+}
+
+func TestPromptTemplatePreview(t *testing.T) {
+	h := newAdminTestHandler(t, `{
+		"keys":["k1"],
+		"prompt_templates":[{"name":"plain","models":["deepseek-reasoner"],"template":"{{range .Turns}}[{{.Role}}] {{.Text}}\n{{end}}"}]
+	}`)
+
+	code, out := postPromptPreview(t, h, `{}`)
+	prompt, _ := out["prompt"].(string)
+	if code != http.StatusOK || out["name"] != "default" || !strings.Contains(prompt, "<｜Assistant｜>") || !strings.Contains(prompt, "[cat](") {
+		t.Fatalf("expected default template preview of the sample, got %d %#v", code, out)
+	}
+
+	_, out = postPromptPreview(t, h, `{"model":"deepseek-reasoner","messages":[{"role":"user","content":"Hi"}]}`)
+	if out["name"] != "plain" || out["prompt"] != "[user] Hi\n" {
+		t.Fatalf("expected template selected by model, got %#v", out)
+	}
+
+	_, out = postPromptPreview(t, h, `{"name":"plain","messages":[{"role":"assistant","content":"Yo"}]}`)
+	if out["prompt"] != "[assistant] Yo\n" {
+		t.Fatalf("expected named template, got %#v", out)
+	}
+
+	_, out = postPromptPreview(t, h, `{"template":"{{.System}}|{{len .Dialogue}}","image_links":"keep"}`)
+	if out["prompt"] != "You are a helpful assistant.|3" || out["image_links"] != "keep" {
+		t.Fatalf("expected inline template preview, got %#v", out)
+	}
+}
+
+func TestPromptTemplatePreviewErrors(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	if code, _ := postPromptPreview(t, h, `{"template":"{{.Turns"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad template, got %d", code)
+	}
+	if code, _ := postPromptPreview(t, h, `{"template":"{{.Nope}}"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a render error, got %d", code)
+	}
+	if code, _ := postPromptPreview(t, h, `{"name":"missing"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown name, got %d", code)
+	}
+}
+
+func TestImportRejectsInvalidPromptTemplate(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	for _, body := range []string{
+		`{"config":{"prompt_templates":[{"name":"bad","template":"{{.Turns"}]}}`,
+		`{"config":{"prompt_templates":[{"name":"default","template":"x"}]}}`,
+		`{"config":{"tool_prompts":[{"name":"bad","template":"{{range .Tools}}"}]}}`,
+		`{"config":{"tool_prompts":[{"name":"x","surfaces":["bedrock"],"template":"x"}]}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/config/import?mode=merge", bytes.NewReader([]byte(body)))
+		rec := httptest.NewRecorder()
+		h.configImport(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d %s", body, rec.Code, rec.Body.String())
+		}
+	}
+	if snap := h.Store.Snapshot(); len(snap.PromptTemplates) != 0 || len(snap.ToolPrompts) != 0 {
+		t.Fatalf("invalid templates must not be stored")
+	}
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+type routingTestRequest struct {
+	Model   string            `json:"model"`
+	Key     string            `json:"key"`
+	Surface string            `json:"surface"`
+	Headers map[string]string `json:"headers"`
+}
+
+// testRouting reports which routing rule a request would hit and the model
+// it would be served by, without calling upstream.
+func (h *Handler) testRouting(w http.ResponseWriter, r *http.Request) {
+	var req routingTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid json"})
+		return
+	}
+	if strings.TrimSpace(req.Model) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "需要 model"})
+		return
+	}
+	surface := strings.ToLower(strings.TrimSpace(req.Surface))
+	switch surface {
+	case "":
+		surface = config.ModelSurfaceOpenAI
+	case config.ModelSurfaceOpenAI, config.ModelSurfaceClaude, config.ModelSurfaceGemini:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "surface 只能是 openai、claude 或 gemini"})
+		return
+	}
+	in := config.RouteInput{Model: req.Model, Key: strings.TrimSpace(req.Key), Surface: surface, Headers: http.Header{}}
+	for name, value := range req.Headers {
+		in.Headers.Set(name, value)
+	}
+
+	// Claude Messages requests without a matching rule keep claude_mapping.
+	if surface == config.ModelSurfaceClaude {
+		if decision, ok := config.RouteByRule(h.Store.RoutingRules(), h.Store, in); ok {
+			writeJSON(w, http.StatusOK, map[string]any{"matched": true, "decision": decision})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"matched": false, "detail": "no rule matched; claude_mapping decides the model"})
+		return
+	}
+	decision, ok := config.ResolveRoute(h.Store, in)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"matched": false, "detail": "Model '" + req.Model + "' is not available."})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"matched": decision.Rule >= 0, "decision": decision})
+}
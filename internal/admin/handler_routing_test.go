@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func postRoutingTest(t *testing.T, h *Handler, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/routing/test", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	h.testRouting(rec, req)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out
+}
+
+func TestRoutingTestReportsMatchedRule(t *testing.T) {
+	h := newAdminTestHandler(t, `{
+		"keys":["key-x"],
+		"routing_rules":[
+			{"name":"x-mini","model":"*-mini","keys":["key-x"],"target":"deepseek-chat"},
+			{"name":"x-rest","keys":["key-x"],"target":"deepseek-reasoner"}
+		]
+	}`)
+
+	code, out := postRoutingTest(t, h, `{"model":"gpt-4o-mini","key":"key-x"}`)
+	decision, _ := out["decision"].(map[string]any)
+	if code != http.StatusOK || out["matched"] != true || decision["model"] != "deepseek-chat" || decision["rule_name"] != "x-mini" {
+		t.Fatalf("expected x-mini rule, got %d %#v", code, out)
+	}
+
+	_, out = postRoutingTest(t, h, `{"model":"gpt-4.1","key":"key-x","surface":"gemini"}`)
+	decision, _ = out["decision"].(map[string]any)
+	if decision["model"] != "deepseek-reasoner" || decision["rule"] != float64(1) {
+		t.Fatalf("expected x-rest rule, got %#v", out)
+	}
+
+	_, out = postRoutingTest(t, h, `{"model":"gpt-4.1","key":"other"}`)
+	decision, _ = out["decision"].(map[string]any)
+	if out["matched"] != false || decision["model"] != "deepseek-chat" || decision["rule"] != float64(-1) {
+		t.Fatalf("expected alias fallback, got %#v", out)
+	}
+}
+
+func TestRoutingTestRejectsUnknownSurface(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	code, _ := postRoutingTest(t, h, `{"model":"deepseek-chat","surface":"bedrock"}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+}
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ds2api/internal/config"
+)
+
+// mergedIdentitySettings applies the identity section of a settings update
+// to the stored one and validates the result. Fields left out of the
+// section keep their stored values. It returns nil when the update has no
+// identity section.
+func mergedIdentitySettings(snap config.Config, req map[string]any) (*config.IdentityConfig, error) {
+	raw, ok := req["identity"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	b, _ := json.Marshal(raw)
+	var incoming config.IdentityConfig
+	if err := json.Unmarshal(b, &incoming); err != nil {
+		return nil, fmt.Errorf("identity is invalid: %v", err)
+	}
+	next := snap.Identity
+	if _, ok := raw["profile"]; ok {
+		next.Profile = incoming.Profile
+	}
+	if _, ok := raw["diversify"]; ok {
+		next.Diversify = incoming.Diversify
+	}
+	if _, ok := raw["pool"]; ok {
+		next.Pool = incoming.Pool
+	}
+	if _, ok := raw["profiles"]; ok {
+		next.Profiles = incoming.Profiles
+	}
+	snap.Identity = next
+	if err := config.ValidateIdentity(snap); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// identitySettings is the identity section as shown in settings, with the
+// names of every selectable profile.
+func identitySettings(snap config.Config) map[string]any {
+	names := []string{}
+	for _, p := range snap.Identity.ClientProfiles() {
+		names = append(names, p.Name)
+	}
+	return map[string]any{
+		"profile":            snap.Identity.Profile,
+		"diversify":          snap.Identity.Diversify,
+		"pool":               snap.Identity.Pool,
+		"profiles":           snap.Identity.Profiles,
+		"available_profiles": names,
+	}
+}
@@ -5,15 +5,19 @@ import (
 	"strings"
 
 	"ds2api/internal/config"
+	"ds2api/internal/util"
 )
 
-func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *config.RuntimeConfig, *config.ToolcallConfig, *config.ResponsesConfig, *config.EmbeddingsConfig, map[string]string, map[string]string, error) {
+func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *config.RuntimeConfig, *config.ToolcallConfig, *config.ResponsesConfig, *config.EmbeddingsConfig, *config.CompatConfig, *config.ContextConfig, *config.ResponseCacheConfig, map[string]string, map[string]string, error) {
 	var (
 		adminCfg    *config.AdminConfig
 		runtimeCfg  *config.RuntimeConfig
 		toolcallCfg *config.ToolcallConfig
 		respCfg     *config.ResponsesConfig
 		embCfg      *config.EmbeddingsConfig
+		compatCfg   *config.CompatConfig
+		contextCfg  *config.ContextConfig
+		cacheCfg    *config.ResponseCacheConfig
 		claudeMap   map[string]string
 		aliasMap    map[string]string
 	)
@@ -23,7 +27,7 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["jwt_expire_hours"]; exists {
 			n := intFrom(v)
 			if n < 1 || n > 720 {
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("admin.jwt_expire_hours must be between 1 and 720")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("admin.jwt_expire_hours must be between 1 and 720")
 			}
 			cfg.JWTExpireHours = n
 		}
@@ -35,26 +39,26 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["account_max_inflight"]; exists {
 			n := intFrom(v)
 			if n < 1 || n > 256 {
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.account_max_inflight must be between 1 and 256")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.account_max_inflight must be between 1 and 256")
 			}
 			cfg.AccountMaxInflight = n
 		}
 		if v, exists := raw["account_max_queue"]; exists {
 			n := intFrom(v)
 			if n < 1 || n > 200000 {
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.account_max_queue must be between 1 and 200000")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.account_max_queue must be between 1 and 200000")
 			}
 			cfg.AccountMaxQueue = n
 		}
 		if v, exists := raw["global_max_inflight"]; exists {
 			n := intFrom(v)
 			if n < 1 || n > 200000 {
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.global_max_inflight must be between 1 and 200000")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.global_max_inflight must be between 1 and 200000")
 			}
 			cfg.GlobalMaxInflight = n
 		}
 		if cfg.AccountMaxInflight > 0 && cfg.GlobalMaxInflight > 0 && cfg.GlobalMaxInflight < cfg.AccountMaxInflight {
-			return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.global_max_inflight must be >= runtime.account_max_inflight")
+			return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("runtime.global_max_inflight must be >= runtime.account_max_inflight")
 		}
 		runtimeCfg = cfg
 	}
@@ -63,12 +67,10 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		cfg := &config.ToolcallConfig{}
 		if v, exists := raw["mode"]; exists {
 			mode := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
-			switch mode {
-			case "feature_match", "off":
-				cfg.Mode = mode
-			default:
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.mode must be feature_match or off")
+			if _, err := util.ParseToolcallMode(mode); err != nil || mode == "" {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.mode must be feature_match, off, all or a comma-separated list of json, xml and function_tag")
 			}
+			cfg.Mode = mode
 		}
 		if v, exists := raw["early_emit_confidence"]; exists {
 			level := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
@@ -76,9 +78,16 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 			case "high", "low", "off":
 				cfg.EarlyEmitConfidence = level
 			default:
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.early_emit_confidence must be high, low or off")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.early_emit_confidence must be high, low or off")
 			}
 		}
+		if v, exists := raw["repair_attempts"]; exists {
+			n := intFrom(v)
+			if n < 0 || n > util.MaxToolRepairAttempts {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("toolcall.repair_attempts must be between 0 and %d", util.MaxToolRepairAttempts)
+			}
+			cfg.RepairAttempts = &n
+		}
 		toolcallCfg = cfg
 	}
 
@@ -87,10 +96,17 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["store_ttl_seconds"]; exists {
 			n := intFrom(v)
 			if n < 30 || n > 86400 {
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("responses.store_ttl_seconds must be between 30 and 86400")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("responses.store_ttl_seconds must be between 30 and 86400")
 			}
 			cfg.StoreTTLSeconds = n
 		}
+		if v, exists := raw["stream_grace_seconds"]; exists {
+			n := intFrom(v)
+			if n < 1 || n > 3600 {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("responses.stream_grace_seconds must be between 1 and 3600")
+			}
+			cfg.StreamGraceSeconds = n
+		}
 		respCfg = cfg
 	}
 
@@ -99,13 +115,100 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		if v, exists := raw["provider"]; exists {
 			p := strings.TrimSpace(fmt.Sprintf("%v", v))
 			if p == "" {
-				return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("embeddings.provider cannot be empty")
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("embeddings.provider cannot be empty")
 			}
 			cfg.Provider = p
 		}
 		embCfg = cfg
 	}
 
+	if raw, ok := req["compat"].(map[string]any); ok {
+		cfg := &config.CompatConfig{}
+		if v, exists := raw["reasoning_mode"]; exists {
+			mode, err := util.ParseReasoningMode(fmt.Sprintf("%v", v))
+			if err != nil || mode == "" {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("compat.reasoning_mode must be separate, inline_think_tags, hidden or summary_only")
+			}
+			cfg.ReasoningMode = string(mode)
+		}
+		if v, exists := raw["key_reasoning_modes"]; exists {
+			modes, _ := v.(map[string]any)
+			if modes == nil && v != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("compat.key_reasoning_modes must be an object")
+			}
+			cfg.KeyReasoningModes = map[string]string{}
+			for key, mode := range modes {
+				cfg.KeyReasoningModes[strings.TrimSpace(key)] = strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", mode)))
+			}
+			if err := config.ValidateCompat(config.CompatConfig{KeyReasoningModes: cfg.KeyReasoningModes}); err != nil {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+			}
+		}
+		compatCfg = cfg
+	}
+
+	if raw, ok := req["context"].(map[string]any); ok {
+		cfg := &config.ContextConfig{}
+		if v, exists := raw["strategy"]; exists {
+			strategy, err := util.ParseContextStrategy(fmt.Sprintf("%v", v))
+			if err != nil || strategy == "" {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("context.strategy must be off, drop_oldest, truncate_tool_results or summarize")
+			}
+			cfg.Strategy = string(strategy)
+		}
+		if v, exists := raw["max_prompt_tokens"]; exists {
+			n := intFrom(v)
+			if n < 1024 || n > 10000000 {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("context.max_prompt_tokens must be between 1024 and 10000000")
+			}
+			cfg.MaxPromptTokens = n
+		}
+		if v, exists := raw["tool_result_max_tokens"]; exists {
+			n := intFrom(v)
+			if n < 100 || n > 1000000 {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("context.tool_result_max_tokens must be between 100 and 1000000")
+			}
+			cfg.ToolResultMaxTokens = n
+		}
+		contextCfg = cfg
+	}
+
+	if raw, ok := req["response_cache"].(map[string]any); ok {
+		cfg := &config.ResponseCacheConfig{}
+		if v, exists := raw["enabled"]; exists {
+			enabled, ok := v.(bool)
+			if !ok {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("response_cache.enabled must be true or false")
+			}
+			cfg.Enabled = enabled
+		}
+		if v, exists := raw["backend"]; exists {
+			backend := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", v)))
+			if backend != "memory" && backend != "disk" {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("response_cache.backend must be memory or disk")
+			}
+			cfg.Backend = backend
+		}
+		if v, exists := raw["dir"]; exists {
+			cfg.Dir = strings.TrimSpace(fmt.Sprintf("%v", v))
+		}
+		if v, exists := raw["ttl_seconds"]; exists {
+			n := intFrom(v)
+			if n < 1 || n > 2592000 {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("response_cache.ttl_seconds must be between 1 and 2592000")
+			}
+			cfg.TTLSeconds = n
+		}
+		if v, exists := raw["max_bytes"]; exists {
+			n := int64(intFrom(v))
+			if n < 1<<20 || n > 1<<36 {
+				return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("response_cache.max_bytes must be between 1048576 and 68719476736")
+			}
+			cfg.MaxBytes = n
+		}
+		cacheCfg = cfg
+	}
+
 	if raw, ok := req["claude_mapping"].(map[string]any); ok {
 		claudeMap = map[string]string{}
 		for k, v := range raw {
@@ -130,5 +233,5 @@ func parseSettingsUpdateRequest(req map[string]any) (*config.AdminConfig, *confi
 		}
 	}
 
-	return adminCfg, runtimeCfg, toolcallCfg, respCfg, embCfg, claudeMap, aliasMap, nil
+	return adminCfg, runtimeCfg, toolcallCfg, respCfg, embCfg, compatCfg, contextCfg, cacheCfg, claudeMap, aliasMap, nil
 }
@@ -25,9 +25,16 @@ func (h *Handler) getSettings(w http.ResponseWriter, _ *http.Request) {
 			"account_max_queue":    h.Store.RuntimeAccountMaxQueue(recommended),
 			"global_max_inflight":  h.Store.RuntimeGlobalMaxInflight(recommended),
 		},
+		"compat": map[string]any{
+			"reasoning_mode":      settingsReasoningMode(snap.Compat.ReasoningMode),
+			"key_reasoning_modes": snap.Compat.KeyReasoningModes,
+		},
 		"toolcall":          snap.Toolcall,
 		"responses":         snap.Responses,
 		"embeddings":        snap.Embeddings,
+		"context":           snap.Context,
+		"response_cache":    snap.ResponseCache,
+		"identity":          identitySettings(snap),
 		"claude_mapping":    settingsClaudeMapping(snap),
 		"model_aliases":     snap.ModelAliases,
 		"env_backed":        h.Store.IsEnvBacked(),
@@ -1,6 +1,11 @@
 package admin
 
-import "ds2api/internal/config"
+import (
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
 
 func validateMergedRuntimeSettings(current config.RuntimeConfig, incoming *config.RuntimeConfig) error {
 	merged := current
@@ -49,3 +54,13 @@ func settingsClaudeMapping(c config.Config) map[string]string {
 	}
 	return map[string]string{"fast": "deepseek-chat", "slow": "deepseek-reasoner"}
 }
+
+// settingsReasoningMode reports the effective default, which is separate
+// when nothing is configured.
+func settingsReasoningMode(mode string) string {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return string(util.ReasoningSeparate)
+	}
+	return mode
+}
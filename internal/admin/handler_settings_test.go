@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	authn "ds2api/internal/auth"
+	"ds2api/internal/config"
 )
 
 func TestGetSettingsDefaultPasswordWarning(t *testing.T) {
@@ -265,3 +266,163 @@ func TestConfigImportRejectsMergedRuntimeConflict(t *testing.T) {
 		t.Fatalf("runtime should remain unchanged, runtime=%+v", snap.Runtime)
 	}
 }
+
+func TestUpdateSettingsResponsesStreamGrace(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	for _, tc := range []struct {
+		grace int
+		code  int
+	}{
+		{grace: 120, code: http.StatusOK},
+		{grace: 0, code: http.StatusBadRequest},
+	} {
+		b, _ := json.Marshal(map[string]any{
+			"responses": map[string]any{"stream_grace_seconds": tc.grace},
+		})
+		req := httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b))
+		rec := httptest.NewRecorder()
+		h.updateSettings(rec, req)
+		if rec.Code != tc.code {
+			t.Fatalf("grace=%d: expected %d, got %d body=%s", tc.grace, tc.code, rec.Code, rec.Body.String())
+		}
+	}
+	if got := h.Store.Snapshot().Responses.StreamGraceSeconds; got != 120 {
+		t.Fatalf("stream_grace_seconds=%d want=120", got)
+	}
+}
+
+func TestUpdateSettingsCompatReasoningMode(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	put := func(mode string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]any{"compat": map[string]any{"reasoning_mode": mode}})
+		req := httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b))
+		rec := httptest.NewRecorder()
+		h.updateSettings(rec, req)
+		return rec
+	}
+	if rec := put("verbose"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown mode, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := put("Hidden"); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := h.Store.Snapshot().Compat.ReasoningMode; got != "hidden" {
+		t.Fatalf("reasoning_mode=%q want=hidden", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.getSettings(rec, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	var body map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	compat, _ := body["compat"].(map[string]any)
+	if compat["reasoning_mode"] != "hidden" {
+		t.Fatalf("expected compat.reasoning_mode in settings, body=%v", body)
+	}
+
+	b, _ := json.Marshal(map[string]any{"compat": map[string]any{"key_reasoning_modes": map[string]any{"k1": "verbose"}}})
+	rec = httptest.NewRecorder()
+	h.updateSettings(rec, httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown per-key mode, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	b, _ = json.Marshal(map[string]any{"compat": map[string]any{"key_reasoning_modes": map[string]any{"k1": "summary_only"}}})
+	rec = httptest.NewRecorder()
+	h.updateSettings(rec, httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := h.Store.Snapshot().Compat.KeyReasoningModes["k1"]; got != "summary_only" {
+		t.Fatalf("expected per-key reasoning mode for k1, got %q", got)
+	}
+	if got := h.Store.Snapshot().Compat.ReasoningMode; got != "hidden" {
+		t.Fatalf("expected the global mode kept, got %q", got)
+	}
+}
+
+func TestUpdateSettingsContext(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	put := func(section map[string]any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]any{"context": section})
+		req := httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b))
+		rec := httptest.NewRecorder()
+		h.updateSettings(rec, req)
+		return rec
+	}
+	if rec := put(map[string]any{"strategy": "fold"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown strategy, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := put(map[string]any{"max_prompt_tokens": 10}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for tiny budget, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := put(map[string]any{"strategy": "Summarize", "max_prompt_tokens": 32000, "tool_result_max_tokens": 500}); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	got := h.Store.Snapshot().Context
+	if got.Strategy != "summarize" || got.MaxPromptTokens != 32000 || got.ToolResultMaxTokens != 500 {
+		t.Fatalf("unexpected context config %#v", got)
+	}
+}
+
+func TestUpdateSettingsResponseCache(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"]}`)
+	put := func(section map[string]any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]any{"response_cache": section})
+		req := httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b))
+		rec := httptest.NewRecorder()
+		h.updateSettings(rec, req)
+		return rec
+	}
+	if rec := put(map[string]any{"backend": "redis"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown backend, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := put(map[string]any{"enabled": true, "backend": "Disk", "ttl_seconds": 600, "max_bytes": 1 << 24}); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := put(map[string]any{"ttl_seconds": 60}); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	got := h.Store.Snapshot().ResponseCache
+	if !got.Enabled || got.Backend != "disk" || got.TTLSeconds != 60 || got.MaxBytes != 1<<24 {
+		t.Fatalf("unexpected response_cache config %#v", got)
+	}
+	if rec := put(map[string]any{"enabled": false}); rec.Code != http.StatusOK || h.Store.Snapshot().ResponseCache.Enabled {
+		t.Fatalf("expected enabled=false to turn the cache off, got %d %#v", rec.Code, h.Store.Snapshot().ResponseCache)
+	}
+}
+
+func TestUpdateSettingsIdentity(t *testing.T) {
+	h := newAdminTestHandler(t, `{"keys":["k1"],"identity":{"diversify":true}}`)
+	put := func(identity map[string]any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(map[string]any{"identity": identity})
+		req := httptest.NewRequest(http.MethodPut, "/admin/settings", bytes.NewReader(b))
+		rec := httptest.NewRecorder()
+		h.updateSettings(rec, req)
+		return rec
+	}
+	if rec := put(map[string]any{"profile": "missing"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown profile, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	rec := put(map[string]any{
+		"profile":  "tight",
+		"profiles": []any{map[string]any{"name": "tight", "client_hello": "firefox", "headers": map[string]any{"x-client-version": "1.7.0"}}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	identity := h.Store.Snapshot().Identity
+	if identity.Profile != "tight" || !identity.Diversify || len(identity.Profiles) != 1 {
+		t.Fatalf("expected the update merged over the stored section, got %#v", identity)
+	}
+
+	get := httptest.NewRecorder()
+	h.getSettings(get, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	var body struct {
+		Identity struct {
+			Available []string `json:"available_profiles"`
+		} `json:"identity"`
+	}
+	_ = json.Unmarshal(get.Body.Bytes(), &body)
+	if n := len(body.Identity.Available); n != len(config.DefaultClientProfiles())+1 {
+		t.Fatalf("expected built-in and custom profiles listed, got %v", body.Identity.Available)
+	}
+}
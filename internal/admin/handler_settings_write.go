@@ -17,7 +17,12 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminCfg, runtimeCfg, toolcallCfg, responsesCfg, embeddingsCfg, claudeMap, aliasMap, err := parseSettingsUpdateRequest(req)
+	adminCfg, runtimeCfg, toolcallCfg, responsesCfg, embeddingsCfg, compatCfg, contextCfg, cacheCfg, claudeMap, aliasMap, err := parseSettingsUpdateRequest(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	identityCfg, err := mergedIdentitySettings(h.Store.Snapshot(), req)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
 		return
@@ -53,13 +58,56 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 			if strings.TrimSpace(toolcallCfg.EarlyEmitConfidence) != "" {
 				c.Toolcall.EarlyEmitConfidence = strings.TrimSpace(toolcallCfg.EarlyEmitConfidence)
 			}
+			if toolcallCfg.RepairAttempts != nil {
+				c.Toolcall.RepairAttempts = toolcallCfg.RepairAttempts
+			}
 		}
 		if responsesCfg != nil && responsesCfg.StoreTTLSeconds > 0 {
 			c.Responses.StoreTTLSeconds = responsesCfg.StoreTTLSeconds
 		}
+		if responsesCfg != nil && responsesCfg.StreamGraceSeconds > 0 {
+			c.Responses.StreamGraceSeconds = responsesCfg.StreamGraceSeconds
+		}
 		if embeddingsCfg != nil && strings.TrimSpace(embeddingsCfg.Provider) != "" {
 			c.Embeddings.Provider = strings.TrimSpace(embeddingsCfg.Provider)
 		}
+		if compatCfg != nil && compatCfg.ReasoningMode != "" {
+			c.Compat.ReasoningMode = compatCfg.ReasoningMode
+		}
+		if compatCfg != nil && compatCfg.KeyReasoningModes != nil {
+			c.Compat.KeyReasoningModes = compatCfg.KeyReasoningModes
+		}
+		if contextCfg != nil {
+			if contextCfg.Strategy != "" {
+				c.Context.Strategy = contextCfg.Strategy
+			}
+			if contextCfg.MaxPromptTokens > 0 {
+				c.Context.MaxPromptTokens = contextCfg.MaxPromptTokens
+			}
+			if contextCfg.ToolResultMaxTokens > 0 {
+				c.Context.ToolResultMaxTokens = contextCfg.ToolResultMaxTokens
+			}
+		}
+		if cacheCfg != nil {
+			// enabled and dir are applied whenever they are sent, since
+			// false and "" are meaningful values for them.
+			raw, _ := req["response_cache"].(map[string]any)
+			if _, ok := raw["enabled"]; ok {
+				c.ResponseCache.Enabled = cacheCfg.Enabled
+			}
+			if cacheCfg.Backend != "" {
+				c.ResponseCache.Backend = cacheCfg.Backend
+			}
+			if _, ok := raw["dir"]; ok {
+				c.ResponseCache.Dir = cacheCfg.Dir
+			}
+			if cacheCfg.TTLSeconds > 0 {
+				c.ResponseCache.TTLSeconds = cacheCfg.TTLSeconds
+			}
+			if cacheCfg.MaxBytes > 0 {
+				c.ResponseCache.MaxBytes = cacheCfg.MaxBytes
+			}
+		}
 		if claudeMap != nil {
 			c.ClaudeMapping = claudeMap
 			c.ClaudeModelMap = nil
@@ -67,6 +115,9 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 		if aliasMap != nil {
 			c.ModelAliases = aliasMap
 		}
+		if identityCfg != nil {
+			c.Identity = *identityCfg
+		}
 		return nil
 	}); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
@@ -64,9 +64,20 @@ func toAccount(m map[string]any) config.Account {
 		Mobile:   fieldString(m, "mobile"),
 		Password: fieldString(m, "password"),
 		Token:    fieldString(m, "token"),
+		Groups:   fieldGroups(m),
+		Proxy:    fieldString(m, "proxy"),
+		Profile:  fieldString(m, "profile"),
 	}
 }
 
+// fieldGroups reads "groups" as a list or a comma-separated string.
+func fieldGroups(m map[string]any) []string {
+	if groups, ok := toStringSlice(m["groups"]); ok {
+		return config.NormalizeGroups(groups)
+	}
+	return config.NormalizeGroups(strings.Split(fieldString(m, "groups"), ","))
+}
+
 func fieldString(m map[string]any, key string) string {
 	v, ok := m[key]
 	if !ok || v == nil {
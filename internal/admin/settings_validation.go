@@ -2,9 +2,11 @@ package admin
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"ds2api/internal/config"
+	"ds2api/internal/util"
 )
 
 func normalizeSettingsConfig(c *config.Config) {
@@ -15,6 +17,11 @@ func normalizeSettingsConfig(c *config.Config) {
 	c.Toolcall.Mode = strings.ToLower(strings.TrimSpace(c.Toolcall.Mode))
 	c.Toolcall.EarlyEmitConfidence = strings.ToLower(strings.TrimSpace(c.Toolcall.EarlyEmitConfidence))
 	c.Embeddings.Provider = strings.TrimSpace(c.Embeddings.Provider)
+	c.Compat.ReasoningMode = strings.ToLower(strings.TrimSpace(c.Compat.ReasoningMode))
+	c.Context.Strategy = strings.ToLower(strings.TrimSpace(c.Context.Strategy))
+	c.ResponseCache.Backend = strings.ToLower(strings.TrimSpace(c.ResponseCache.Backend))
+	c.ResponseCache.Dir = strings.TrimSpace(c.ResponseCache.Dir)
+	c.Coalesce.OptOutKeys = normalizeOptOutKeys(c.Coalesce.OptOutKeys)
 }
 
 func validateSettingsConfig(c config.Config) error {
@@ -27,11 +34,12 @@ func validateSettingsConfig(c config.Config) error {
 	if c.Responses.StoreTTLSeconds != 0 && (c.Responses.StoreTTLSeconds < 30 || c.Responses.StoreTTLSeconds > 86400) {
 		return fmt.Errorf("responses.store_ttl_seconds must be between 30 and 86400")
 	}
+	if c.Responses.StreamGraceSeconds != 0 && (c.Responses.StreamGraceSeconds < 1 || c.Responses.StreamGraceSeconds > 3600) {
+		return fmt.Errorf("responses.stream_grace_seconds must be between 1 and 3600")
+	}
 	if mode := strings.TrimSpace(c.Toolcall.Mode); mode != "" {
-		switch mode {
-		case "feature_match", "off":
-		default:
-			return fmt.Errorf("toolcall.mode must be feature_match or off")
+		if _, err := util.ParseToolcallMode(mode); err != nil {
+			return fmt.Errorf("toolcall.mode must be feature_match, off, all or a comma-separated list of json, xml and function_tag")
 		}
 	}
 	if level := strings.TrimSpace(c.Toolcall.EarlyEmitConfidence); level != "" {
@@ -41,6 +49,54 @@ func validateSettingsConfig(c config.Config) error {
 			return fmt.Errorf("toolcall.early_emit_confidence must be high, low or off")
 		}
 	}
+	if n := c.Toolcall.RepairAttempts; n != nil && (*n < 0 || *n > util.MaxToolRepairAttempts) {
+		return fmt.Errorf("toolcall.repair_attempts must be between 0 and %d", util.MaxToolRepairAttempts)
+	}
+	if err := config.ValidateCompat(c.Compat); err != nil {
+		return err
+	}
+	if _, err := util.ParseContextStrategy(c.Context.Strategy); err != nil {
+		return err
+	}
+	if c.Context.MaxPromptTokens < 0 || c.Context.ToolResultMaxTokens < 0 {
+		return fmt.Errorf("context token limits must not be negative")
+	}
+	if b := c.ResponseCache.Backend; b != "" && b != "memory" && b != "disk" {
+		return fmt.Errorf("response_cache.backend must be memory or disk")
+	}
+	if c.ResponseCache.TTLSeconds < 0 || c.ResponseCache.MaxBytes < 0 {
+		return fmt.Errorf("response_cache limits must not be negative")
+	}
+	if n := c.Coalesce.MaxSubscribers; n < 0 || n > 1024 {
+		return fmt.Errorf("coalesce.max_subscribers must be between 0 and 1024")
+	}
+	if err := config.ValidateModels(c.Models); err != nil {
+		return err
+	}
+	if err := config.ValidateRoutingRules(c.RoutingRules, config.MergeModels(config.DefaultModels(), c.Models)); err != nil {
+		return err
+	}
+	if err := config.ValidateAccountGroups(c); err != nil {
+		return err
+	}
+	if err := config.ValidateAccountProxies(c.Accounts); err != nil {
+		return err
+	}
+	if err := config.ValidateIdentity(c); err != nil {
+		return err
+	}
+	if err := config.ValidateUpstream(c.Upstream); err != nil {
+		return err
+	}
+	if err := config.ValidateScheduling(c.Scheduling); err != nil {
+		return err
+	}
+	if err := config.ValidateAffinity(c.Affinity); err != nil {
+		return err
+	}
+	if err := validatePromptTemplates(c.PromptTemplates, c.ToolPrompts); err != nil {
+		return err
+	}
 	if c.Embeddings.Provider != "" && strings.TrimSpace(c.Embeddings.Provider) == "" {
 		return fmt.Errorf("embeddings.provider cannot be empty")
 	}
@@ -62,3 +118,36 @@ func validateRuntimeSettings(runtime config.RuntimeConfig) error {
 	}
 	return nil
 }
+
+func validatePromptTemplates(templates []config.PromptTemplate, toolPrompts []config.ToolPrompt) error {
+	if err := config.ValidatePromptTemplates(templates); err != nil {
+		return err
+	}
+	for i, t := range templates {
+		if _, err := util.ParsePromptTemplate(t); err != nil {
+			return fmt.Errorf("prompt_templates[%d] is invalid: %v", i, err)
+		}
+	}
+	if err := config.ValidateToolPrompts(toolPrompts); err != nil {
+		return err
+	}
+	for i, t := range toolPrompts {
+		if _, err := util.ParseToolPrompt(t); err != nil {
+			return fmt.Errorf("tool_prompts[%d] is invalid: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func normalizeOptOutKeys(keys []string) []string {
+	if keys == nil {
+		return nil
+	}
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" && !slices.Contains(out, k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"sync"
 
 	"ds2api/internal/account"
 	"ds2api/internal/config"
@@ -29,6 +30,15 @@ type RequestAuth struct {
 	Account        config.Account
 	TriedAccounts  map[string]bool
 	resolver       *Resolver
+	target         string
+	caller         account.Caller
+	// group is the account group the caller's key is bound to.
+	group string
+	// session is the X-Ds2-Session header, an account affinity source.
+	session string
+	// released is set once the account slot is back in the pool, so a
+	// request that gave its slot up early can still defer Release.
+	released bool
 }
 
 type LoginFunc func(ctx context.Context, acc config.Account) (string, error)
@@ -59,8 +69,13 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 			TriedAccounts:  map[string]bool{},
 		}, nil
 	}
-	target := strings.TrimSpace(req.Header.Get("X-Ds2-Target-Account"))
-	acc, ok := r.Pool.AcquireWait(ctx, target, nil)
+	group := r.Store.KeyGroup(callerKey)
+	target := r.accountTarget(group, req.Header.Get("X-Ds2-Target-Account"))
+	caller := account.Caller{
+		ID:    callerID,
+		Class: r.Store.SchedulingConfig().ClassFor(callerKey, req.Header.Get(config.PriorityHeader)),
+	}
+	acc, ok := r.Pool.AcquireWait(ctx, target, nil, caller)
 	if !ok {
 		return nil, ErrNoAccount
 	}
@@ -71,6 +86,10 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 		Account:        acc,
 		TriedAccounts:  map[string]bool{},
 		resolver:       r,
+		target:         target,
+		caller:         caller,
+		group:          group,
+		session:        strings.TrimSpace(req.Header.Get(config.SessionHeader)),
 	}
 	if acc.Token == "" {
 		if err := r.loginAndPersist(ctx, a); err != nil {
@@ -83,6 +102,50 @@ func (r *Resolver) Determine(req *http.Request) (*RequestAuth, error) {
 	return a, nil
 }
 
+// Fork acquires one more account lease for a request that already holds a.
+// Direct-token callers share their token; managed callers go through the pool
+// with the same target selector as Determine. Fork never waits: the caller
+// already holds a lease, and two fanned-out requests waiting on each other's
+// slots would deadlock, so a busy pool fails the fork with ErrNoAccount.
+func (r *Resolver) Fork(ctx context.Context, a *RequestAuth) (*RequestAuth, error) {
+	if a == nil {
+		return nil, ErrUnauthorized
+	}
+	if !a.UseConfigToken {
+		return &RequestAuth{
+			DeepSeekToken: a.DeepSeekToken,
+			CallerID:      a.CallerID,
+			TriedAccounts: map[string]bool{},
+			resolver:      r,
+		}, nil
+	}
+	acc, ok := r.Pool.Acquire(a.target, nil)
+	if !ok {
+		return nil, ErrNoAccount
+	}
+	forked := &RequestAuth{
+		UseConfigToken: true,
+		CallerID:       a.CallerID,
+		AccountID:      acc.Identifier(),
+		Account:        acc,
+		TriedAccounts:  map[string]bool{},
+		resolver:       r,
+		target:         a.target,
+		caller:         a.caller,
+		group:          a.group,
+		session:        a.session,
+	}
+	if acc.Token == "" {
+		if err := r.loginAndPersist(ctx, forked); err != nil {
+			r.Pool.Release(forked.AccountID)
+			return nil, err
+		}
+	} else {
+		forked.DeepSeekToken = acc.Token
+	}
+	return forked, nil
+}
+
 // DetermineCaller resolves caller identity without acquiring any pooled account.
 // Use this for local-cache lookup routes that only need tenant isolation.
 func (r *Resolver) DetermineCaller(req *http.Request) (*RequestAuth, error) {
@@ -154,12 +217,15 @@ func (r *Resolver) SwitchAccount(ctx context.Context, a *RequestAuth) bool {
 	}
 	if a.AccountID != "" {
 		a.TriedAccounts[a.AccountID] = true
-		r.Pool.Release(a.AccountID)
+		if !a.released {
+			r.Pool.Release(a.AccountID)
+		}
 	}
-	acc, ok := r.Pool.Acquire("", a.TriedAccounts)
+	acc, ok := r.Pool.Acquire(a.switchTarget(), a.TriedAccounts)
 	if !ok {
 		return false
 	}
+	a.released = false
 	a.Account = acc
 	a.AccountID = acc.Identifier()
 	if acc.Token == "" {
@@ -173,12 +239,32 @@ func (r *Resolver) SwitchAccount(ctx context.Context, a *RequestAuth) bool {
 }
 
 func (r *Resolver) Release(a *RequestAuth) {
-	if a == nil || !a.UseConfigToken || a.AccountID == "" {
+	if a == nil || !a.UseConfigToken || a.AccountID == "" || a.released {
 		return
 	}
+	a.released = true
 	r.Pool.Release(a.AccountID)
 }
 
+// HandOff gives the account slot a holds to another owner, such as a shared
+// completion that outlives the request. Release on a no longer frees it; the
+// returned func does, once.
+func (a *RequestAuth) HandOff() func() {
+	if a == nil || a.resolver == nil || !a.UseConfigToken || a.AccountID == "" || a.released {
+		return func() {}
+	}
+	a.released = true
+	pool, id := a.resolver.Pool, a.AccountID
+	var once sync.Once
+	return func() { once.Do(func() { pool.Release(id) }) }
+}
+
+// RouteInput describes req for routing-rule matching. Callers fill in the
+// requested model and the surface.
+func RouteInput(req *http.Request) config.RouteInput {
+	return config.RouteInput{Key: extractCallerToken(req), Headers: req.Header}
+}
+
 func extractCallerToken(req *http.Request) string {
 	authHeader := strings.TrimSpace(req.Header.Get("Authorization"))
 	if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+// BindAffinity moves a managed request onto the account its affinity key
+// hashes to, so one end user or conversation keeps landing on the same
+// account. The key comes from the X-Ds2-Session header, user or the
+// conversation fingerprint, as the affinity section orders them, and is
+// scoped to the caller. Requests pinned to one account stay where they are,
+// and so does a request whose preferred accounts are all saturated.
+func (a *RequestAuth) BindAffinity(ctx context.Context, user, conversation string) error {
+	if a == nil || !a.UseConfigToken || a.resolver == nil || a.AccountID == "" {
+		return nil
+	}
+	r := a.resolver
+	cfg := r.Store.AffinityConfig()
+	if !cfg.Enabled {
+		return nil
+	}
+	key := cfg.KeyFor(a.session, user, conversation)
+	if key == "" {
+		return nil
+	}
+	acc, moved := r.Pool.AcquireAffinity(a.CallerID+"|"+key, a.target, a.AccountID, a.TriedAccounts, cfg.FallbackAccounts)
+	if !moved {
+		return nil
+	}
+	a.Account, a.AccountID = acc, acc.Identifier()
+	a.DeepSeekToken = acc.Token
+	if acc.Token == "" {
+		return r.loginAndPersist(ctx, a)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"ds2api/internal/account"
+	"ds2api/internal/config"
+)
+
+func TestBindAffinityKeepsASessionOnOneAccount(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["k1"],
+		"affinity":{"enabled":true},
+		"accounts":[
+			{"email":"a1@example.com","token":"t1"},
+			{"email":"a2@example.com","token":"t2"},
+			{"email":"a3@example.com","token":"t3"}
+		]
+	}`)
+	store := config.LoadStore()
+	r := NewResolver(store, account.NewPool(store), nil)
+
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer k1")
+		req.Header.Set(config.SessionHeader, "session-1")
+		a, err := r.Determine(req)
+		if err != nil {
+			t.Fatalf("determine failed: %v", err)
+		}
+		if err := a.BindAffinity(context.Background(), "", ""); err != nil {
+			t.Fatalf("bind affinity failed: %v", err)
+		}
+		seen[a.AccountID] = true
+		if a.DeepSeekToken != a.Account.Token {
+			t.Fatalf("expected the token of the bound account, got %q", a.DeepSeekToken)
+		}
+		r.Release(a)
+	}
+	if len(seen) != 1 {
+		t.Fatalf("expected every request of the session on one account, got %v", seen)
+	}
+	if status := r.Pool.Status(); status["in_use"] != 0 {
+		t.Fatalf("expected no slot left behind, got %v", status["in_use"])
+	}
+}
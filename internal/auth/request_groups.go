@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"ds2api/internal/account"
+)
+
+// accountTarget is the pool target of a managed request. A key bound to a
+// group only gets accounts of that group: the X-Ds2-Target-Account header
+// may narrow the choice to one of them but not widen it.
+func (r *Resolver) accountTarget(group, header string) string {
+	header = strings.TrimSpace(header)
+	if group == "" {
+		return header
+	}
+	if g, ok := account.GroupOf(header); ok && strings.EqualFold(g, group) {
+		return header
+	}
+	if acc, ok := r.Store.FindAccount(header); ok && acc.InGroup(group) {
+		return header
+	}
+	return account.GroupTarget(group)
+}
+
+// switchTarget is where SwitchAccount looks for a replacement account: the
+// same group when the request is limited to one, otherwise anywhere.
+func (a *RequestAuth) switchTarget() string {
+	if _, ok := account.GroupOf(a.target); ok {
+		return a.target
+	}
+	if a.group != "" {
+		return account.GroupTarget(a.group)
+	}
+	return ""
+}
+
+// BindGroup moves a managed request onto an account of group, which a
+// routing rule picked after the request had already been given an account.
+// Keys bound to a group keep their own group, and direct-token callers have
+// no pooled account to move.
+func (a *RequestAuth) BindGroup(ctx context.Context, group string) error {
+	group = strings.TrimSpace(group)
+	if a == nil || group == "" || !a.UseConfigToken || a.resolver == nil || a.group != "" {
+		return nil
+	}
+	target := account.GroupTarget(group)
+	if a.Account.InGroup(group) {
+		a.target = target
+		return nil
+	}
+	r := a.resolver
+	// The current account goes back first so requests moving between
+	// groups cannot hold each other's accounts while they wait.
+	r.Pool.Release(a.AccountID)
+	a.AccountID = ""
+	acc, ok := r.Pool.AcquireWait(ctx, target, nil, a.caller)
+	if !ok {
+		return ErrNoAccount
+	}
+	a.Account, a.AccountID, a.target = acc, acc.Identifier(), target
+	a.DeepSeekToken = acc.Token
+	if acc.Token == "" {
+		return r.loginAndPersist(ctx, a)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"ds2api/internal/account"
+	"ds2api/internal/config"
+)
+
+func newGroupTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["open-key","team-key"],
+		"key_groups":{"team-key":"research"},
+		"accounts":[
+			{"email":"shared@example.com","token":"t1"},
+			{"email":"r1@example.com","token":"t2","groups":["research"]},
+			{"email":"r2@example.com","token":"t3","groups":["Research"]}
+		]
+	}`)
+	store := config.LoadStore()
+	return NewResolver(store, account.NewPool(store), func(_ context.Context, _ config.Account) (string, error) {
+		return "fresh-token", nil
+	})
+}
+
+func determineWith(t *testing.T, r *Resolver, key, target string) *RequestAuth {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	if target != "" {
+		req.Header.Set("X-Ds2-Target-Account", target)
+	}
+	a, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("determine failed: %v", err)
+	}
+	return a
+}
+
+func TestDetermineKeepsGroupBoundKeysInTheirGroup(t *testing.T) {
+	r := newGroupTestResolver(t)
+	first := determineWith(t, r, "team-key", "")
+	second := determineWith(t, r, "team-key", "shared@example.com")
+	defer r.Release(first)
+	defer r.Release(second)
+	if !first.Account.InGroup("research") || !second.Account.InGroup("research") || first.AccountID == second.AccountID {
+		t.Fatalf("expected both research accounts in turn, got %s and %s", first.AccountID, second.AccountID)
+	}
+
+	selected := determineWith(t, r, "open-key", "group:research")
+	defer r.Release(selected)
+	if !selected.Account.InGroup("research") {
+		t.Fatalf("expected the group selector to pick a research account, got %s", selected.AccountID)
+	}
+}
+
+func TestBindGroupMovesRequestOntoGroupAccount(t *testing.T) {
+	r := newGroupTestResolver(t)
+	a := determineWith(t, r, "open-key", "shared@example.com")
+	if err := a.BindGroup(context.Background(), "research"); err != nil {
+		t.Fatalf("bind failed: %v", err)
+	}
+	if !a.Account.InGroup("research") || a.DeepSeekToken == "t1" {
+		t.Fatalf("expected a research account, got %s", a.AccountID)
+	}
+	if status := r.Pool.Status(); status["in_use"] != 1 {
+		t.Fatalf("expected the shared account released, got %v", status)
+	}
+	r.Release(a)
+	if status := r.Pool.Status(); status["in_use"] != 0 {
+		t.Fatalf("expected every slot back, got %v", status)
+	}
+
+	bound := determineWith(t, r, "team-key", "")
+	defer r.Release(bound)
+	before := bound.AccountID
+	if err := bound.BindGroup(context.Background(), "other"); err != nil || bound.AccountID != before {
+		t.Fatalf("expected a group-bound key to keep its group, got %s %v", bound.AccountID, err)
+	}
+}
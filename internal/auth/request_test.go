@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"ds2api/internal/account"
 	"ds2api/internal/config"
@@ -193,3 +194,87 @@ func TestDetermineCallerMissingToken(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestForkManagedKeyTakesSecondPoolSlot(t *testing.T) {
+	r := newTestResolver(t)
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer managed-key")
+
+	first, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("determine failed: %v", err)
+	}
+	defer r.Release(first)
+	forked, err := r.Fork(context.Background(), first)
+	if err != nil {
+		t.Fatalf("fork failed: %v", err)
+	}
+	if forked.AccountID != "acc@example.com" || forked.DeepSeekToken != "account-token" {
+		t.Fatalf("unexpected forked auth: %#v", forked)
+	}
+	if got := r.Pool.Status()["in_use"]; got != 2 {
+		t.Fatalf("expected two leased slots, got %v", got)
+	}
+	r.Release(forked)
+	if got := r.Pool.Status()["in_use"]; got != 1 {
+		t.Fatalf("expected forked slot released, got %v", got)
+	}
+}
+
+func TestHandOffMovesTheSlotToTheReturnedRelease(t *testing.T) {
+	r := newTestResolver(t)
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer managed-key")
+
+	a, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("determine failed: %v", err)
+	}
+	release := a.HandOff()
+	r.Release(a)
+	if got := r.Pool.Status()["in_use"]; got != 1 {
+		t.Fatalf("expected the handed-off slot kept after Release, got %v", got)
+	}
+	release()
+	release()
+	if got := r.Pool.Status()["in_use"]; got != 0 {
+		t.Fatalf("expected the slot freed once by the returned release, got %v", got)
+	}
+}
+
+func TestForkFailsWithoutWaitingWhenPoolIsFull(t *testing.T) {
+	r := newTestResolver(t)
+	r.Pool.ApplyRuntimeLimits(1, 4, 0)
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer managed-key")
+
+	first, err := r.Determine(req)
+	if err != nil {
+		t.Fatalf("determine failed: %v", err)
+	}
+	defer r.Release(first)
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Fork(context.Background(), first)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != ErrNoAccount {
+			t.Fatalf("expected ErrNoAccount, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected fork on a full pool to fail instead of waiting")
+	}
+}
+
+func TestForkDirectTokenSharesToken(t *testing.T) {
+	r := newTestResolver(t)
+	forked, err := r.Fork(context.Background(), &RequestAuth{DeepSeekToken: "direct-token", CallerID: "caller:x"})
+	if err != nil {
+		t.Fatalf("fork failed: %v", err)
+	}
+	if forked.UseConfigToken || forked.DeepSeekToken != "direct-token" || forked.CallerID != "caller:x" {
+		t.Fatalf("unexpected forked auth: %#v", forked)
+	}
+}
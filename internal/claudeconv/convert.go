@@ -9,25 +9,7 @@ type ClaudeMappingProvider interface {
 func ConvertClaudeToDeepSeek(claudeReq map[string]any, mappingProvider ClaudeMappingProvider, defaultClaudeModel string) map[string]any {
 	messages, _ := claudeReq["messages"].([]any)
 	model, _ := claudeReq["model"].(string)
-	if model == "" {
-		model = defaultClaudeModel
-	}
-
-	mapping := map[string]string{}
-	if mappingProvider != nil {
-		mapping = mappingProvider.ClaudeMapping()
-	}
-	dsModel := mapping["fast"]
-	if dsModel == "" {
-		dsModel = "deepseek-chat"
-	}
-
-	modelLower := strings.ToLower(model)
-	if strings.Contains(modelLower, "opus") || strings.Contains(modelLower, "reasoner") || strings.Contains(modelLower, "slow") {
-		if slow := mapping["slow"]; slow != "" {
-			dsModel = slow
-		}
-	}
+	dsModel := MapClaudeModel(model, mappingProvider, defaultClaudeModel)
 
 	convertedMessages := make([]any, 0, len(messages)+1)
 	if system, ok := claudeReq["system"].(string); ok && system != "" {
@@ -46,3 +28,26 @@ func ConvertClaudeToDeepSeek(claudeReq map[string]any, mappingProvider ClaudeMap
 	}
 	return out
 }
+
+// MapClaudeModel picks the DeepSeek model for a Claude model through the
+// fast / slow claude_mapping: opus, reasoner and slow models are slow.
+func MapClaudeModel(model string, mappingProvider ClaudeMappingProvider, defaultClaudeModel string) string {
+	if model == "" {
+		model = defaultClaudeModel
+	}
+	mapping := map[string]string{}
+	if mappingProvider != nil {
+		mapping = mappingProvider.ClaudeMapping()
+	}
+	dsModel := mapping["fast"]
+	if dsModel == "" {
+		dsModel = "deepseek-chat"
+	}
+	modelLower := strings.ToLower(model)
+	if strings.Contains(modelLower, "opus") || strings.Contains(modelLower, "reasoner") || strings.Contains(modelLower, "slow") {
+		if slow := mapping["slow"]; slow != "" {
+			dsModel = slow
+		}
+	}
+	return dsModel
+}
@@ -0,0 +1,171 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var errSubscriptionClosed = errors.New("coalesce: subscription closed")
+
+// flight is one shared completion. A pump copies the upstream body into buf
+// and every subscriber reads buf from the start at its own pace. Once the
+// last subscriber leaves an unfinished flight, the upstream call is
+// cancelled.
+type flight struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	ready  chan struct{}
+
+	// Set before ready is closed.
+	status int
+	header http.Header
+	err    error
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []byte
+	done    bool
+	readErr error
+	subs    int
+	joined  int
+}
+
+func newFlight(parent context.Context) *flight {
+	ctx, cancel := context.WithCancel(parent)
+	f := &flight{ctx: ctx, cancel: cancel, ready: make(chan struct{})}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// subscribe adds a subscriber unless the flight has ended or already has
+// maxSubs of them.
+func (f *flight) subscribe(maxSubs int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done || f.ctx.Err() != nil || f.joined >= maxSubs {
+		return false
+	}
+	f.joined++
+	f.subs++
+	return true
+}
+
+// start opens the completion and, on success, starts the pump. finish is
+// called once the flight can take no more subscribers.
+func (f *flight) start(open OpenFunc, finish func()) {
+	resp, release, err := open(f.ctx)
+	if release == nil {
+		release = func() {}
+	}
+	if err != nil {
+		release()
+		f.err = err
+		f.mu.Lock()
+		f.done = true
+		f.mu.Unlock()
+		finish()
+		f.cancel()
+		close(f.ready)
+		return
+	}
+	f.status = resp.StatusCode
+	f.header = resp.Header.Clone()
+	close(f.ready)
+	go f.pump(resp.Body, func() {
+		finish()
+		release()
+	})
+}
+
+func (f *flight) pump(body io.ReadCloser, finish func()) {
+	defer finish()
+	defer f.cancel()
+	// Closed first so a response cache wrapped around body has stored the
+	// stream before the flight stops taking subscribers.
+	defer body.Close()
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(chunk)
+		f.mu.Lock()
+		f.buf = append(f.buf, chunk[:n]...)
+		if err != nil {
+			f.done = true
+			if err != io.EOF {
+				f.readErr = err
+			}
+		}
+		f.cond.Broadcast()
+		f.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait returns the subscriber's response once the completion is open.
+func (f *flight) wait(ctx context.Context) (*http.Response, error) {
+	select {
+	case <-f.ready:
+	case <-ctx.Done():
+		f.leave()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		f.leave()
+		return nil, f.err
+	}
+	return &http.Response{StatusCode: f.status, Header: f.header.Clone(), Body: &subscriber{f: f}}, nil
+}
+
+func (f *flight) leave() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs--
+	if f.subs == 0 && !f.done {
+		f.cancel()
+	}
+	f.cond.Broadcast()
+}
+
+// subscriber reads the shared stream from the start.
+type subscriber struct {
+	f      *flight
+	off    int
+	closed bool
+}
+
+func (s *subscriber) Read(p []byte) (int, error) {
+	f := s.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for s.off >= len(f.buf) && !f.done && !s.closed {
+		f.cond.Wait()
+	}
+	switch {
+	case s.closed:
+		return 0, errSubscriptionClosed
+	case s.off < len(f.buf):
+		n := copy(p, f.buf[s.off:])
+		s.off += n
+		return n, nil
+	case f.readErr != nil:
+		return 0, f.readErr
+	default:
+		return 0, io.EOF
+	}
+}
+
+func (s *subscriber) Close() error {
+	s.f.mu.Lock()
+	if s.closed {
+		s.f.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.f.mu.Unlock()
+	s.f.leave()
+	return nil
+}
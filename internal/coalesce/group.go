@@ -0,0 +1,84 @@
+// Package coalesce lets concurrent identical requests share one upstream
+// completion. The first request opens it; later ones subscribe and read the
+// same upstream stream from the start, each rendering it on its own.
+package coalesce
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// Header marks responses that were served from a completion opened by
+// another request.
+const Header = "X-Ds2api-Coalesced"
+
+type ConfigReader interface {
+	CoalesceConfig() config.CoalesceConfig
+}
+
+// OpenFunc opens the upstream completion of a flight. ctx outlives the
+// request that started the flight and ends once every subscriber is gone.
+// release, when not nil, frees what the completion holds, such as the
+// account slot of the request that opened it; the flight calls it once the
+// upstream stream has ended rather than when that request returns.
+type OpenFunc func(ctx context.Context) (resp *http.Response, release func(), err error)
+
+// Group tracks the completions in flight. A nil Group coalesces nothing.
+type Group struct {
+	Store ConfigReader
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func New(store ConfigReader) *Group {
+	return &Group{Store: store, flights: map[string]*flight{}}
+}
+
+// Key returns the flight key of req and whether req may share a completion:
+// coalescing must be enabled, the request deterministic and callerKey not
+// opted out.
+func (g *Group) Key(req util.StandardRequest, callerKey string) (string, bool) {
+	if g == nil || g.Store == nil {
+		return "", false
+	}
+	cfg := g.Store.CoalesceConfig()
+	if !cfg.Enabled || !req.Deterministic() || slices.Contains(cfg.OptOutKeys, callerKey) {
+		return "", false
+	}
+	return req.Fingerprint(), true
+}
+
+// Do returns a response for the completion identified by key. If one is in
+// flight with room for another subscriber, Do waits for it to open and
+// returns a response reading the shared stream, with joined set. Otherwise
+// it calls open and starts a new flight. ctx is the caller's request
+// context; a joined caller that goes away only drops its subscription.
+func (g *Group) Do(ctx context.Context, key string, open OpenFunc) (*http.Response, bool, error) {
+	maxSubs := g.Store.CoalesceConfig().MaxSubscribers
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok && f.subscribe(maxSubs) {
+		g.mu.Unlock()
+		resp, err := f.wait(ctx)
+		return resp, true, err
+	}
+	f := newFlight(context.WithoutCancel(ctx))
+	f.subscribe(maxSubs)
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.start(open, func() {
+		g.mu.Lock()
+		if g.flights[key] == f {
+			delete(g.flights, key)
+		}
+		g.mu.Unlock()
+	})
+	resp, err := f.wait(ctx)
+	return resp, false, err
+}
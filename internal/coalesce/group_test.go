@@ -0,0 +1,176 @@
+package coalesce
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+type testConfig struct {
+	cfg config.CoalesceConfig
+}
+
+func (t testConfig) CoalesceConfig() config.CoalesceConfig {
+	return t.cfg
+}
+
+func testRequest() util.StandardRequest {
+	return util.StandardRequest{Surface: "openai_chat", ResolvedModel: "deepseek-chat", FinalPrompt: "hi", PassThrough: map[string]any{}}
+}
+
+// gatedOpen returns an OpenFunc whose body yields "data: a\n\n" once gate is
+// closed, counting the completions opened.
+func gatedOpen(calls *atomic.Int32, gate chan struct{}) OpenFunc {
+	return func(ctx context.Context) (*http.Response, func(), error) {
+		calls.Add(1)
+		pr, pw := io.Pipe()
+		go func() {
+			select {
+			case <-gate:
+				_, _ = pw.Write([]byte("data: a\n\n"))
+				_ = pw.Close()
+			case <-ctx.Done():
+				_ = pw.CloseWithError(ctx.Err())
+			}
+		}()
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: pr}, nil, nil
+	}
+}
+
+func TestKeyHonoursEnabledDeterminismAndOptOut(t *testing.T) {
+	g := New(testConfig{cfg: config.CoalesceConfig{Enabled: true, OptOutKeys: []string{"solo"}}})
+	if key, ok := g.Key(testRequest(), "k1"); !ok || key != testRequest().Fingerprint() {
+		t.Fatalf("expected the request fingerprint, got %q %v", key, ok)
+	}
+	if _, ok := g.Key(testRequest(), "solo"); ok {
+		t.Fatalf("expected an opted-out key not to coalesce")
+	}
+	sampled := testRequest()
+	sampled.PassThrough["temperature"] = 0.7
+	if _, ok := g.Key(sampled, "k1"); ok {
+		t.Fatalf("expected a sampled request not to coalesce")
+	}
+	if _, ok := New(testConfig{}).Key(testRequest(), "k1"); ok {
+		t.Fatalf("expected coalescing to be off by default")
+	}
+	var none *Group
+	if _, ok := none.Key(testRequest(), "k1"); ok {
+		t.Fatalf("expected a nil group to coalesce nothing")
+	}
+}
+
+func TestDoSharesOneCompletionUpToMaxSubscribers(t *testing.T) {
+	g := New(testConfig{cfg: config.CoalesceConfig{Enabled: true, MaxSubscribers: 3}})
+	var calls atomic.Int32
+	gate := make(chan struct{})
+	open := gatedOpen(&calls, gate)
+
+	var wg sync.WaitGroup
+	var joined atomic.Int32
+	bodies := make([]string, 4)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, j, err := g.Do(context.Background(), "k", open)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if j {
+				joined.Add(1)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			bodies[i] = string(b)
+		}()
+		// Let each request reach the group before the next one.
+		for deadline := time.Now().Add(time.Second); calls.Load() == 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	if calls.Load() != 2 || joined.Load() != 2 {
+		t.Fatalf("expected three subscribers on one completion and a second completion, got %d calls, %d joined", calls.Load(), joined.Load())
+	}
+	for i, b := range bodies {
+		if b != "data: a\n\n" {
+			t.Fatalf("subscriber %d read %q", i, b)
+		}
+	}
+}
+
+func TestLastSubscriberLeavingCancelsUpstream(t *testing.T) {
+	g := New(testConfig{cfg: config.CoalesceConfig{Enabled: true, MaxSubscribers: 4}})
+	var calls atomic.Int32
+	cancelled := make(chan struct{})
+	block := func(ctx context.Context) (*http.Response, func(), error) {
+		calls.Add(1)
+		pr, _ := io.Pipe()
+		go func() {
+			<-ctx.Done()
+			close(cancelled)
+			_ = pr.CloseWithError(ctx.Err())
+		}()
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: pr}, nil, nil
+	}
+
+	first, _, _ := g.Do(context.Background(), "k", block)
+	second, joined, _ := g.Do(context.Background(), "k", block)
+	if !joined || calls.Load() != 1 {
+		t.Fatalf("expected the second request to join, got joined=%v calls=%d", joined, calls.Load())
+	}
+	_ = first.Body.Close()
+	select {
+	case <-cancelled:
+		t.Fatalf("expected upstream to continue while a subscriber remains")
+	case <-time.After(20 * time.Millisecond):
+	}
+	_ = second.Body.Close()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected upstream to be cancelled once every subscriber left")
+	}
+}
+
+func TestReleaseWaitsForTheStreamToEnd(t *testing.T) {
+	g := New(testConfig{cfg: config.CoalesceConfig{Enabled: true, MaxSubscribers: 4}})
+	released := make(chan struct{})
+	pr, pw := io.Pipe()
+	open := func(context.Context) (*http.Response, func(), error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: pr}, func() { close(released) }, nil
+	}
+
+	first, _, _ := g.Do(context.Background(), "k", open)
+	second, joined, _ := g.Do(context.Background(), "k", open)
+	if !joined {
+		t.Fatalf("expected the second request to join")
+	}
+	_ = first.Body.Close()
+	select {
+	case <-released:
+		t.Fatalf("expected the lease held while a subscriber still reads")
+	case <-time.After(20 * time.Millisecond):
+	}
+	_, _ = pw.Write([]byte("data: a\n\n"))
+	_ = pw.Close()
+	if body, _ := io.ReadAll(second.Body); string(body) != "data: a\n\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the lease released once the stream ended")
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
 	"strings"
 )
 
@@ -22,3 +25,130 @@ func (a Account) Identifier() string {
 	sum := sha256.Sum256([]byte(token))
 	return "token:" + hex.EncodeToString(sum[:8])
 }
+
+// InGroup reports whether the account is tagged with group, ignoring case.
+func (a Account) InGroup(group string) bool {
+	group = strings.TrimSpace(group)
+	if group == "" {
+		return false
+	}
+	return slices.ContainsFunc(a.Groups, func(g string) bool {
+		return strings.EqualFold(strings.TrimSpace(g), group)
+	})
+}
+
+// NormalizeGroups trims group names and drops empty and duplicate ones.
+func NormalizeGroups(groups []string) []string {
+	var out []string
+	for _, g := range groups {
+		g = strings.TrimSpace(g)
+		if g != "" && !slices.ContainsFunc(out, func(o string) bool { return strings.EqualFold(o, g) }) {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// KeyGroup is the account group the API key is bound to, or "".
+func (s *Store) KeyGroup(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return strings.TrimSpace(s.cfg.KeyGroups[strings.TrimSpace(key)])
+}
+
+// AccountGroups lists the group names used by the accounts, sorted.
+func (s *Store) AccountGroups() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []string
+	for _, acc := range s.cfg.Accounts {
+		for _, g := range NormalizeGroups(acc.Groups) {
+			if !slices.Contains(out, g) {
+				out = append(out, g)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// ValidateAccountGroups checks that group bindings name a group some
+// account carries.
+func ValidateAccountGroups(c Config) error {
+	known := func(group string) bool {
+		return slices.ContainsFunc(c.Accounts, func(a Account) bool { return a.InGroup(group) })
+	}
+	for key, group := range c.KeyGroups {
+		if !known(group) {
+			return fmt.Errorf("key_groups[%q] names group %q that no account belongs to", key, group)
+		}
+	}
+	for i, r := range c.RoutingRules {
+		if g := strings.TrimSpace(r.AccountGroup); g != "" && !known(g) {
+			return fmt.Errorf("routing_rules[%d].account_group %q names a group no account belongs to", i, r.AccountGroup)
+		}
+	}
+	return nil
+}
+
+func cloneAccounts(in []Account) []Account {
+	if in == nil {
+		return nil
+	}
+	out := slices.Clone(in)
+	for i := range out {
+		out[i].Groups = slices.Clone(out[i].Groups)
+	}
+	return out
+}
+
+// ProxyURL parses the account's outbound proxy. It returns nil when the
+// account egresses directly.
+func (a Account) ProxyURL() (*url.URL, error) {
+	return ParseProxyURL(a.Proxy)
+}
+
+// ParseProxyURL parses an outbound proxy URL. Only http (CONNECT), socks5
+// and socks5h proxies are accepted; an empty string yields nil.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %v", err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	switch u.Scheme {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy scheme must be http, socks5 or socks5h")
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy url is missing a host")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return nil, fmt.Errorf("proxy url must not have a path or query")
+	}
+	return u, nil
+}
+
+// ValidateAccountProxies checks that every account proxy parses.
+func ValidateAccountProxies(accounts []Account) error {
+	for _, acc := range accounts {
+		if _, err := acc.ProxyURL(); err != nil {
+			return fmt.Errorf("accounts[%s].proxy: %v", acc.Identifier(), err)
+		}
+	}
+	return nil
+}
+
+// RedactProxy hides the password of a proxy URL for display.
+func RedactProxy(raw string) string {
+	u, err := ParseProxyURL(raw)
+	if err != nil || u == nil {
+		return strings.TrimSpace(raw)
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// SessionHeader names the client's session for account affinity.
+	SessionHeader = "X-Ds2-Session"
+
+	AffinitySourceSession      = "session"
+	AffinitySourceUser         = "user"
+	AffinitySourceConversation = "conversation"
+)
+
+// AffinitySources are the affinity key sources in their default order.
+var AffinitySources = []string{AffinitySourceSession, AffinitySourceUser, AffinitySourceConversation}
+
+// AffinityConfig is the affinity section with the default sources and two
+// fallback accounts filled in.
+func (s *Store) AffinityConfig() AffinityConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.Affinity.clone()
+	if len(out.Sources) == 0 {
+		out.Sources = slices.Clone(AffinitySources)
+	}
+	if out.FallbackAccounts <= 0 {
+		out.FallbackAccounts = 2
+	}
+	return out
+}
+
+// KeyFor picks the affinity key from the first source with a value: the
+// X-Ds2-Session header, the end user the client names, or the conversation
+// fingerprint. The source is part of the key so values of different
+// sources never share an account by accident.
+func (c AffinityConfig) KeyFor(session, user, conversation string) string {
+	values := map[string]string{
+		AffinitySourceSession:      session,
+		AffinitySourceUser:         user,
+		AffinitySourceConversation: conversation,
+	}
+	for _, source := range c.Sources {
+		source = lower(strings.TrimSpace(source))
+		if v := strings.TrimSpace(values[source]); v != "" {
+			return source + ":" + v
+		}
+	}
+	return ""
+}
+
+// ValidateAffinity checks the key sources and the fallback depth.
+func ValidateAffinity(c AffinityConfig) error {
+	for i, source := range c.Sources {
+		if !slices.Contains(AffinitySources, lower(strings.TrimSpace(source))) {
+			return fmt.Errorf("affinity.sources[%d] must be session, user or conversation", i)
+		}
+	}
+	if c.FallbackAccounts < 0 || c.FallbackAccounts > 64 {
+		return fmt.Errorf("affinity.fallback_accounts must be between 0 and 64")
+	}
+	return nil
+}
+
+func (c AffinityConfig) clone() AffinityConfig {
+	c.Sources = slices.Clone(c.Sources)
+	return c
+}
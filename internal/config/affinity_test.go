@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func TestAffinityKeyForFollowsSourceOrder(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"affinity":{"enabled":true}}`)
+	c := LoadStore().AffinityConfig()
+	if c.FallbackAccounts != 2 || len(c.Sources) != 3 {
+		t.Fatalf("expected the default sources and fallback depth, got %#v", c)
+	}
+	cases := []struct{ session, user, conversation, want string }{
+		{"s1", "alice", "conv", "session:s1"},
+		{"", "alice", "conv", "user:alice"},
+		{"", " ", "conv", "conversation:conv"},
+		{"", "", "", ""},
+	}
+	for _, tc := range cases {
+		if got := c.KeyFor(tc.session, tc.user, tc.conversation); got != tc.want {
+			t.Fatalf("KeyFor(%q, %q, %q) = %q, want %q", tc.session, tc.user, tc.conversation, got, tc.want)
+		}
+	}
+	userOnly := AffinityConfig{Sources: []string{"User"}}
+	if got := userOnly.KeyFor("s1", "", "conv"); got != "" {
+		t.Fatalf("expected sources outside the list to be ignored, got %q", got)
+	}
+}
+
+func TestValidateAffinity(t *testing.T) {
+	if err := ValidateAffinity(AffinityConfig{Sources: []string{"session", "Conversation"}, FallbackAccounts: 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, c := range map[string]AffinityConfig{
+		"source":   {Sources: []string{"cookie"}},
+		"fallback": {FallbackAccounts: 65},
+	} {
+		if err := ValidateAffinity(c); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
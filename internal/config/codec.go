@@ -1,9 +1,7 @@
 package config
 
 import (
-	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -17,6 +15,9 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if len(c.Keys) > 0 {
 		m["keys"] = c.Keys
 	}
+	if len(c.KeyGroups) > 0 {
+		m["key_groups"] = c.KeyGroups
+	}
 	if len(c.Accounts) > 0 {
 		m["accounts"] = c.Accounts
 	}
@@ -29,24 +30,57 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if len(c.ModelAliases) > 0 {
 		m["model_aliases"] = c.ModelAliases
 	}
+	if len(c.Models) > 0 {
+		m["models"] = c.Models
+	}
+	if len(c.RoutingRules) > 0 {
+		m["routing_rules"] = c.RoutingRules
+	}
+	if len(c.PromptTemplates) > 0 {
+		m["prompt_templates"] = c.PromptTemplates
+	}
+	if len(c.ToolPrompts) > 0 {
+		m["tool_prompts"] = c.ToolPrompts
+	}
 	if strings.TrimSpace(c.Admin.PasswordHash) != "" || c.Admin.JWTExpireHours > 0 || c.Admin.JWTValidAfterUnix > 0 {
 		m["admin"] = c.Admin
 	}
 	if c.Runtime.AccountMaxInflight > 0 || c.Runtime.AccountMaxQueue > 0 || c.Runtime.GlobalMaxInflight > 0 {
 		m["runtime"] = c.Runtime
 	}
-	if c.Compat.WideInputStrictOutput != nil {
+	if c.Compat.WideInputStrictOutput != nil || strings.TrimSpace(c.Compat.ReasoningMode) != "" || len(c.Compat.KeyReasoningModes) > 0 {
 		m["compat"] = c.Compat
 	}
-	if strings.TrimSpace(c.Toolcall.Mode) != "" || strings.TrimSpace(c.Toolcall.EarlyEmitConfidence) != "" {
+	if strings.TrimSpace(c.Toolcall.Mode) != "" || strings.TrimSpace(c.Toolcall.EarlyEmitConfidence) != "" || c.Toolcall.RepairAttempts != nil {
 		m["toolcall"] = c.Toolcall
 	}
-	if c.Responses.StoreTTLSeconds > 0 {
+	if c.Responses.StoreTTLSeconds > 0 || c.Responses.StreamGraceSeconds > 0 {
 		m["responses"] = c.Responses
 	}
 	if strings.TrimSpace(c.Embeddings.Provider) != "" {
 		m["embeddings"] = c.Embeddings
 	}
+	if strings.TrimSpace(c.Context.Strategy) != "" || c.Context.MaxPromptTokens > 0 || c.Context.ToolResultMaxTokens > 0 {
+		m["context"] = c.Context
+	}
+	if c.ResponseCache != (ResponseCacheConfig{}) {
+		m["response_cache"] = c.ResponseCache
+	}
+	if c.Coalesce.Enabled || c.Coalesce.MaxSubscribers > 0 || len(c.Coalesce.OptOutKeys) > 0 {
+		m["coalesce"] = c.Coalesce
+	}
+	if len(c.Scheduling.Classes) > 0 || len(c.Scheduling.KeyClasses) > 0 || strings.TrimSpace(c.Scheduling.DefaultClass) != "" {
+		m["scheduling"] = c.Scheduling
+	}
+	if c.Affinity.Enabled || len(c.Affinity.Sources) > 0 || c.Affinity.FallbackAccounts > 0 {
+		m["affinity"] = c.Affinity
+	}
+	if strings.TrimSpace(c.Identity.Profile) != "" || c.Identity.Diversify || len(c.Identity.Pool) > 0 || len(c.Identity.Profiles) > 0 {
+		m["identity"] = c.Identity
+	}
+	if strings.TrimSpace(c.Upstream.BaseURL) != "" || len(c.Upstream.Endpoints) > 0 {
+		m["upstream"] = c.Upstream
+	}
 	if c.VercelSyncHash != "" {
 		m["_vercel_sync_hash"] = c.VercelSyncHash
 	}
@@ -68,6 +102,10 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.Keys); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "key_groups":
+			if err := json.Unmarshal(v, &c.KeyGroups); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "accounts":
 			if err := json.Unmarshal(v, &c.Accounts); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -84,6 +122,22 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.ModelAliases); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "models":
+			if err := json.Unmarshal(v, &c.Models); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "routing_rules":
+			if err := json.Unmarshal(v, &c.RoutingRules); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "prompt_templates":
+			if err := json.Unmarshal(v, &c.PromptTemplates); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "tool_prompts":
+			if err := json.Unmarshal(v, &c.ToolPrompts); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "admin":
 			if err := json.Unmarshal(v, &c.Admin); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -108,6 +162,34 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 			if err := json.Unmarshal(v, &c.Embeddings); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
 			}
+		case "context":
+			if err := json.Unmarshal(v, &c.Context); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "response_cache":
+			if err := json.Unmarshal(v, &c.ResponseCache); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "coalesce":
+			if err := json.Unmarshal(v, &c.Coalesce); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "scheduling":
+			if err := json.Unmarshal(v, &c.Scheduling); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "affinity":
+			if err := json.Unmarshal(v, &c.Affinity); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "identity":
+			if err := json.Unmarshal(v, &c.Identity); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
+		case "upstream":
+			if err := json.Unmarshal(v, &c.Upstream); err != nil {
+				return fmt.Errorf("invalid field %q: %w", k, err)
+			}
 		case "_vercel_sync_hash":
 			if err := json.Unmarshal(v, &c.VercelSyncHash); err != nil {
 				return fmt.Errorf("invalid field %q: %w", k, err)
@@ -128,19 +210,41 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 
 func (c Config) Clone() Config {
 	clone := Config{
-		Keys:           slices.Clone(c.Keys),
-		Accounts:       slices.Clone(c.Accounts),
-		ClaudeMapping:  cloneStringMap(c.ClaudeMapping),
-		ClaudeModelMap: cloneStringMap(c.ClaudeModelMap),
-		ModelAliases:   cloneStringMap(c.ModelAliases),
-		Admin:          c.Admin,
-		Runtime:        c.Runtime,
+		Keys:            slices.Clone(c.Keys),
+		KeyGroups:       cloneStringMap(c.KeyGroups),
+		Accounts:        cloneAccounts(c.Accounts),
+		ClaudeMapping:   cloneStringMap(c.ClaudeMapping),
+		ClaudeModelMap:  cloneStringMap(c.ClaudeModelMap),
+		ModelAliases:    cloneStringMap(c.ModelAliases),
+		Models:          cloneModels(c.Models),
+		RoutingRules:    cloneRoutingRules(c.RoutingRules),
+		PromptTemplates: clonePromptTemplates(c.PromptTemplates),
+		ToolPrompts:     cloneToolPrompts(c.ToolPrompts),
+		Admin:           c.Admin,
+		Runtime:         c.Runtime,
 		Compat: CompatConfig{
 			WideInputStrictOutput: cloneBoolPtr(c.Compat.WideInputStrictOutput),
+			ReasoningMode:         c.Compat.ReasoningMode,
+			KeyReasoningModes:     cloneStringMap(c.Compat.KeyReasoningModes),
+		},
+		Toolcall: ToolcallConfig{
+			Mode:                c.Toolcall.Mode,
+			EarlyEmitConfidence: c.Toolcall.EarlyEmitConfidence,
+			RepairAttempts:      cloneIntPtr(c.Toolcall.RepairAttempts),
 		},
-		Toolcall:         c.Toolcall,
-		Responses:        c.Responses,
-		Embeddings:       c.Embeddings,
+		Responses:     c.Responses,
+		Embeddings:    c.Embeddings,
+		Context:       c.Context,
+		ResponseCache: c.ResponseCache,
+		Coalesce: CoalesceConfig{
+			Enabled:        c.Coalesce.Enabled,
+			MaxSubscribers: c.Coalesce.MaxSubscribers,
+			OptOutKeys:     slices.Clone(c.Coalesce.OptOutKeys),
+		},
+		Scheduling:       c.Scheduling.clone(),
+		Affinity:         c.Affinity.clone(),
+		Identity:         c.Identity.clone(),
+		Upstream:         c.Upstream.clone(),
 		VercelSyncHash:   c.VercelSyncHash,
 		VercelSyncTime:   c.VercelSyncTime,
 		AdditionalFields: map[string]any{},
@@ -170,72 +274,10 @@ func cloneBoolPtr(in *bool) *bool {
 	return &v
 }
 
-func parseConfigString(raw string) (Config, error) {
-	var cfg Config
-	candidates := []string{raw}
-	if normalized := normalizeConfigInput(raw); normalized != raw {
-		candidates = append(candidates, normalized)
-	}
-	for _, candidate := range candidates {
-		if err := json.Unmarshal([]byte(candidate), &cfg); err == nil {
-			return cfg, nil
-		}
-	}
-
-	base64Input := candidates[len(candidates)-1]
-	decoded, err := decodeConfigBase64(base64Input)
-	if err != nil {
-		return Config{}, fmt.Errorf("invalid DS2API_CONFIG_JSON: %w", err)
-	}
-	if err := json.Unmarshal(decoded, &cfg); err != nil {
-		return Config{}, fmt.Errorf("invalid DS2API_CONFIG_JSON decoded JSON: %w", err)
-	}
-	return cfg, nil
-}
-
-func normalizeConfigInput(raw string) string {
-	normalized := strings.TrimSpace(raw)
-	if normalized == "" {
-		return normalized
-	}
-	for {
-		changed := false
-		if len(normalized) >= 2 {
-			first := normalized[0]
-			last := normalized[len(normalized)-1]
-			if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
-				normalized = strings.TrimSpace(normalized[1 : len(normalized)-1])
-				changed = true
-			}
-		}
-		if strings.HasPrefix(strings.ToLower(normalized), "base64:") {
-			normalized = strings.TrimSpace(normalized[len("base64:"):])
-			changed = true
-		}
-		if !changed {
-			break
-		}
-	}
-	return strings.TrimSpace(normalized)
-}
-
-func decodeConfigBase64(raw string) ([]byte, error) {
-	encodings := []*base64.Encoding{
-		base64.StdEncoding,
-		base64.RawStdEncoding,
-		base64.URLEncoding,
-		base64.RawURLEncoding,
-	}
-	var lastErr error
-	for _, enc := range encodings {
-		decoded, err := enc.DecodeString(raw)
-		if err == nil {
-			return decoded, nil
-		}
-		lastErr = err
-	}
-	if lastErr != nil {
-		return nil, lastErr
+func cloneIntPtr(in *int) *int {
+	if in == nil {
+		return nil
 	}
-	return nil, errors.New("base64 decode failed")
+	v := *in
+	return &v
 }
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ReasoningModes are the accepted reasoning presentations; an empty value
+// means "separate".
+var ReasoningModes = []string{"separate", "inline_think_tags", "hidden", "summary_only"}
+
+// ValidateCompat checks the global and per-key reasoning modes.
+func ValidateCompat(c CompatConfig) error {
+	if !validReasoningMode(c.ReasoningMode) {
+		return fmt.Errorf("compat.reasoning_mode must be one of %s", strings.Join(ReasoningModes, ", "))
+	}
+	for key, mode := range c.KeyReasoningModes {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("compat.key_reasoning_modes has an empty key")
+		}
+		if strings.TrimSpace(mode) == "" || !validReasoningMode(mode) {
+			return fmt.Errorf("compat.key_reasoning_modes[%q] must be one of %s", key, strings.Join(ReasoningModes, ", "))
+		}
+	}
+	return nil
+}
+
+func validReasoningMode(mode string) bool {
+	mode = lower(strings.TrimSpace(mode))
+	return mode == "" || slices.Contains(ReasoningModes, mode)
+}
@@ -1,20 +1,32 @@
 package config
 
 type Config struct {
-	Keys             []string          `json:"keys,omitempty"`
-	Accounts         []Account         `json:"accounts,omitempty"`
-	ClaudeMapping    map[string]string `json:"claude_mapping,omitempty"`
-	ClaudeModelMap   map[string]string `json:"claude_model_mapping,omitempty"`
-	ModelAliases     map[string]string `json:"model_aliases,omitempty"`
-	Admin            AdminConfig       `json:"admin,omitempty"`
-	Runtime          RuntimeConfig     `json:"runtime,omitempty"`
-	Compat           CompatConfig      `json:"compat,omitempty"`
-	Toolcall         ToolcallConfig    `json:"toolcall,omitempty"`
-	Responses        ResponsesConfig   `json:"responses,omitempty"`
-	Embeddings       EmbeddingsConfig  `json:"embeddings,omitempty"`
-	VercelSyncHash   string            `json:"_vercel_sync_hash,omitempty"`
-	VercelSyncTime   int64             `json:"_vercel_sync_time,omitempty"`
-	AdditionalFields map[string]any    `json:"-"`
+	Keys             []string            `json:"keys,omitempty"`
+	KeyGroups        map[string]string   `json:"key_groups,omitempty"`
+	Accounts         []Account           `json:"accounts,omitempty"`
+	ClaudeMapping    map[string]string   `json:"claude_mapping,omitempty"`
+	ClaudeModelMap   map[string]string   `json:"claude_model_mapping,omitempty"`
+	ModelAliases     map[string]string   `json:"model_aliases,omitempty"`
+	Models           []ModelConfig       `json:"models,omitempty"`
+	RoutingRules     []RoutingRule       `json:"routing_rules,omitempty"`
+	PromptTemplates  []PromptTemplate    `json:"prompt_templates,omitempty"`
+	ToolPrompts      []ToolPrompt        `json:"tool_prompts,omitempty"`
+	Admin            AdminConfig         `json:"admin,omitempty"`
+	Runtime          RuntimeConfig       `json:"runtime,omitempty"`
+	Compat           CompatConfig        `json:"compat,omitempty"`
+	Toolcall         ToolcallConfig      `json:"toolcall,omitempty"`
+	Responses        ResponsesConfig     `json:"responses,omitempty"`
+	Embeddings       EmbeddingsConfig    `json:"embeddings,omitempty"`
+	Context          ContextConfig       `json:"context,omitempty"`
+	ResponseCache    ResponseCacheConfig `json:"response_cache,omitempty"`
+	Coalesce         CoalesceConfig      `json:"coalesce,omitempty"`
+	Scheduling       SchedulingConfig    `json:"scheduling,omitempty"`
+	Affinity         AffinityConfig      `json:"affinity,omitempty"`
+	Identity         IdentityConfig      `json:"identity,omitempty"`
+	Upstream         UpstreamConfig      `json:"upstream,omitempty"`
+	VercelSyncHash   string              `json:"_vercel_sync_hash,omitempty"`
+	VercelSyncTime   int64               `json:"_vercel_sync_time,omitempty"`
+	AdditionalFields map[string]any      `json:"-"`
 }
 
 type Account struct {
@@ -23,10 +35,75 @@ type Account struct {
 	Password   string `json:"password,omitempty"`
 	Token      string `json:"token,omitempty"`
 	TestStatus string `json:"test_status,omitempty"`
+	// Groups tag the account so keys and routing rules can be limited to
+	// the accounts of a group.
+	Groups []string `json:"groups,omitempty"`
+	// Proxy is the outbound proxy the account reaches DeepSeek through,
+	// an http:// (CONNECT) or socks5:// URL with optional credentials.
+	Proxy string `json:"proxy,omitempty"`
+	// Profile names the client identity profile of the account.
+	Profile string `json:"profile,omitempty"`
 }
 
+// ModelConfig is one entry of the model registry. Entries with the id of a
+// built-in model replace it; other entries are added after the built-ins.
+type ModelConfig struct {
+	ID              string   `json:"id"`
+	OwnedBy         string   `json:"owned_by,omitempty"`
+	DisplayName     string   `json:"display_name,omitempty"`
+	Thinking        bool     `json:"thinking,omitempty"`
+	Search          bool     `json:"search,omitempty"`
+	ContextWindow   int      `json:"context_window,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Surfaces        []string `json:"surfaces,omitempty"`
+	Created         int64    `json:"created,omitempty"`
+	Disabled        bool     `json:"disabled,omitempty"`
+}
+
+// RoutingRule sends matching requests to a registry model. Every condition
+// that is set must match; a rule without conditions matches everything.
+type RoutingRule struct {
+	Name       string            `json:"name,omitempty"`
+	Model      string            `json:"model,omitempty"`
+	ModelRegex string            `json:"model_regex,omitempty"`
+	Keys       []string          `json:"keys,omitempty"`
+	Surfaces   []string          `json:"surfaces,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Target     string            `json:"target"`
+	Thinking   *bool             `json:"thinking,omitempty"`
+	Search     *bool             `json:"search,omitempty"`
+	// AccountGroup limits matching requests to the accounts of a group.
+	AccountGroup string `json:"account_group,omitempty"`
+}
+
+// PromptTemplate is a named text/template that flattens chat messages into
+// the upstream prompt. It is used for requests whose model and key match its
+// conditions; a template without conditions matches everything.
+type PromptTemplate struct {
+	Name       string   `json:"name"`
+	Template   string   `json:"template"`
+	ImageLinks string   `json:"image_links,omitempty"`
+	Models     []string `json:"models,omitempty"`
+	Keys       []string `json:"keys,omitempty"`
+}
+
+// ToolPrompt is a named text/template for the tool instructions added to the
+// system prompt when a request carries tools. It is used for requests whose
+// surface, model and key match its conditions.
+type ToolPrompt struct {
+	Name     string   `json:"name"`
+	Template string   `json:"template"`
+	Surfaces []string `json:"surfaces,omitempty"`
+	Models   []string `json:"models,omitempty"`
+	Keys     []string `json:"keys,omitempty"`
+}
+
+// CompatConfig holds client compatibility defaults. KeyReasoningModes
+// overrides ReasoningMode for the requests of individual API keys.
 type CompatConfig struct {
-	WideInputStrictOutput *bool `json:"wide_input_strict_output,omitempty"`
+	WideInputStrictOutput *bool             `json:"wide_input_strict_output,omitempty"`
+	ReasoningMode         string            `json:"reasoning_mode,omitempty"`
+	KeyReasoningModes     map[string]string `json:"key_reasoning_modes,omitempty"`
 }
 
 type AdminConfig struct {
@@ -44,12 +121,104 @@ type RuntimeConfig struct {
 type ToolcallConfig struct {
 	Mode                string `json:"mode,omitempty"`
 	EarlyEmitConfidence string `json:"early_emit_confidence,omitempty"`
+	// RepairAttempts is how many times a tool call that fails its schema is
+	// sent back to the model; nil means one, zero turns repair off.
+	RepairAttempts *int `json:"repair_attempts,omitempty"`
 }
 
 type ResponsesConfig struct {
-	StoreTTLSeconds int `json:"store_ttl_seconds,omitempty"`
+	StoreTTLSeconds    int `json:"store_ttl_seconds,omitempty"`
+	StreamGraceSeconds int `json:"stream_grace_seconds,omitempty"`
 }
 
 type EmbeddingsConfig struct {
 	Provider string `json:"provider,omitempty"`
 }
+
+// ContextConfig controls how oversized conversations are fitted into the
+// model's context window before they are sent upstream.
+type ContextConfig struct {
+	Strategy            string `json:"strategy,omitempty"`
+	MaxPromptTokens     int    `json:"max_prompt_tokens,omitempty"`
+	ToolResultMaxTokens int    `json:"tool_result_max_tokens,omitempty"`
+}
+
+// ResponseCacheConfig controls the exact-match cache of deterministic
+// completions. Backend is memory or disk; Dir is only used by the disk
+// backend.
+type ResponseCacheConfig struct {
+	Enabled    bool   `json:"enabled,omitempty"`
+	Backend    string `json:"backend,omitempty"`
+	Dir        string `json:"dir,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+}
+
+// CoalesceConfig controls sharing one upstream completion between concurrent
+// identical requests. Callers whose API key is in OptOutKeys always get a
+// completion of their own.
+type CoalesceConfig struct {
+	Enabled        bool     `json:"enabled,omitempty"`
+	MaxSubscribers int      `json:"max_subscribers,omitempty"`
+	OptOutKeys     []string `json:"opt_out_keys,omitempty"`
+}
+
+// SchedulingConfig splits the account wait queue into priority classes.
+// KeyClasses assigns API keys to classes; other callers may pick one with
+// the X-Ds2api-Priority header and otherwise land in DefaultClass.
+type SchedulingConfig struct {
+	Classes      []PriorityClass   `json:"classes,omitempty"`
+	KeyClasses   map[string]string `json:"key_classes,omitempty"`
+	DefaultClass string            `json:"default_class,omitempty"`
+}
+
+// PriorityClass is one class of waiters. Classes share freed slots in
+// proportion to Weight; the limits are off when zero.
+type PriorityClass struct {
+	Name               string `json:"name"`
+	Weight             int    `json:"weight,omitempty"`
+	MaxQueue           int    `json:"max_queue,omitempty"`
+	MaxQueuePerCaller  int    `json:"max_queue_per_caller,omitempty"`
+	WaitTimeoutSeconds int    `json:"wait_timeout_seconds,omitempty"`
+}
+
+// AffinityConfig keeps the requests of one end user or conversation on the
+// same account. Sources lists where the affinity key is read from, first
+// match wins; FallbackAccounts is how many further accounts on the hash ring
+// are tried while the preferred one is saturated.
+type AffinityConfig struct {
+	Enabled          bool     `json:"enabled,omitempty"`
+	Sources          []string `json:"sources,omitempty"`
+	FallbackAccounts int      `json:"fallback_accounts,omitempty"`
+}
+
+// IdentityConfig selects the client identity DeepSeek sees. Profile is the
+// global default; with Diversify set, accounts without a profile of their
+// own get a stable pick from Pool, or from all profiles when Pool is empty.
+// Profiles adds to or replaces the built-in profiles.
+type IdentityConfig struct {
+	Profile   string          `json:"profile,omitempty"`
+	Diversify bool            `json:"diversify,omitempty"`
+	Pool      []string        `json:"pool,omitempty"`
+	Profiles  []ClientProfile `json:"profiles,omitempty"`
+}
+
+// UpstreamConfig points ds2api at another DeepSeek host, such as a
+// regional mirror, an egress gateway or a local mock. BaseURL is
+// scheme://host[:port]; Endpoints overrides the path of login,
+// create_session, create_pow or completion.
+type UpstreamConfig struct {
+	BaseURL   string            `json:"base_url,omitempty"`
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+}
+
+// ClientProfile is a named client identity: the TLS ClientHello, the ALPN
+// protocols offered, the HTTP version, and headers laid over the base
+// headers. A header with an empty value is removed.
+type ClientProfile struct {
+	Name        string            `json:"name"`
+	ClientHello string            `json:"client_hello"`
+	ALPN        []string          `json:"alpn,omitempty"`
+	HTTPVersion string            `json:"http_version,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
@@ -328,6 +328,33 @@ func TestStoreCompatWideInputStrictOutputDefaultTrue(t *testing.T) {
 	}
 }
 
+func TestStoreCompatReasoningModePerKey(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1","k2"],"compat":{"reasoning_mode":"hidden","key_reasoning_modes":{"k2":"Inline_Think_Tags"}}}`)
+	store := LoadStore()
+	if got := store.CompatReasoningMode("k1"); got != "hidden" {
+		t.Fatalf("expected the global default for k1, got %q", got)
+	}
+	if got := store.CompatReasoningMode("k2"); got != "inline_think_tags" {
+		t.Fatalf("expected the per-key default for k2, got %q", got)
+	}
+}
+
+func TestValidateCompat(t *testing.T) {
+	for _, bad := range []CompatConfig{
+		{ReasoningMode: "verbose"},
+		{KeyReasoningModes: map[string]string{"k": "verbose"}},
+		{KeyReasoningModes: map[string]string{"k": ""}},
+		{KeyReasoningModes: map[string]string{" ": "hidden"}},
+	} {
+		if ValidateCompat(bad) == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+	if err := ValidateCompat(CompatConfig{ReasoningMode: "Hidden", KeyReasoningModes: map[string]string{"k": "summary_only"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestStoreCompatWideInputStrictOutputCanDisable(t *testing.T) {
 	t.Setenv("DS2API_CONFIG_JSON", `{"keys":["k1"],"accounts":[],"compat":{"wide_input_strict_output":false}}`)
 	store := LoadStore()
@@ -450,7 +477,7 @@ func TestStoreExportJSONAndBase64(t *testing.T) {
 // ─── OpenAIModelsResponse / ClaudeModelsResponse ─────────────────────
 
 func TestOpenAIModelsResponse(t *testing.T) {
-	resp := OpenAIModelsResponse()
+	resp := OpenAIModelsResponse(nil)
 	if resp["object"] != "list" {
 		t.Fatalf("unexpected object: %v", resp["object"])
 	}
@@ -464,7 +491,7 @@ func TestOpenAIModelsResponse(t *testing.T) {
 }
 
 func TestClaudeModelsResponse(t *testing.T) {
-	resp := ClaudeModelsResponse()
+	resp := ClaudeModelsResponse(nil)
 	if resp["object"] != "list" {
 		t.Fatalf("unexpected object: %v", resp["object"])
 	}
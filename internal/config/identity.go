@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DefaultClientProfile is the profile used when nothing else is selected:
+// the Safari ClientHello with the Android app headers.
+const DefaultClientProfile = "default"
+
+// ClientHellos are the TLS ClientHello fingerprints a profile can use.
+// "golang" is the standard library handshake, the only one that can speak
+// HTTP/2; the others are uTLS fingerprints limited to HTTP/1.1.
+var ClientHellos = []string{"safari", "chrome", "firefox", "edge", "ios", "android_okhttp", "golang"}
+
+// DefaultClientProfiles are the built-in client identity profiles. Their
+// headers are laid over the base headers from constants_shared.json.
+func DefaultClientProfiles() []ClientProfile {
+	return []ClientProfile{
+		{Name: DefaultClientProfile, ClientHello: "safari"},
+		{Name: "android_okhttp", ClientHello: "android_okhttp"},
+		{
+			Name:        "ios_app",
+			ClientHello: "ios",
+			Headers: map[string]string{
+				"User-Agent":        "DeepSeek/1.6.11 iOS/18.5",
+				"x-client-platform": "ios",
+			},
+		},
+		{
+			Name:        "chrome_web",
+			ClientHello: "chrome",
+			Headers: map[string]string{
+				"User-Agent":        "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+				"x-client-platform": "web",
+				"Origin":            DefaultUpstreamBaseURL,
+				"Referer":           DefaultUpstreamBaseURL + "/",
+			},
+		},
+		{
+			Name:        "firefox_web",
+			ClientHello: "firefox",
+			Headers: map[string]string{
+				"User-Agent":        "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0",
+				"x-client-platform": "web",
+				"Origin":            DefaultUpstreamBaseURL,
+				"Referer":           DefaultUpstreamBaseURL + "/",
+			},
+		},
+	}
+}
+
+// MergeClientProfiles lays configured profiles over the built-ins: one with
+// the name of a built-in replaces it, others are appended.
+func MergeClientProfiles(defaults, custom []ClientProfile) []ClientProfile {
+	out := cloneClientProfiles(defaults)
+	for _, p := range custom {
+		p.Name = strings.TrimSpace(p.Name)
+		if i := slices.IndexFunc(out, func(d ClientProfile) bool { return strings.EqualFold(d.Name, p.Name) }); i >= 0 {
+			out[i] = p.clone()
+			continue
+		}
+		out = append(out, p.clone())
+	}
+	return out
+}
+
+// IdentityConfig is the identity section.
+func (s *Store) IdentityConfig() IdentityConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Identity.clone()
+}
+
+// ClientProfiles lists the built-in and configured profiles.
+func (c IdentityConfig) ClientProfiles() []ClientProfile {
+	return MergeClientProfiles(DefaultClientProfiles(), c.Profiles)
+}
+
+// ProfileFor picks the profile for acc: the account's own profile, else a
+// stable pick from the pool when diversifying, else the global profile.
+// Direct-token callers pass the zero Account and get the global profile.
+func (c IdentityConfig) ProfileFor(acc Account) ClientProfile {
+	profiles := c.ClientProfiles()
+	find := func(name string) (ClientProfile, bool) {
+		i := slices.IndexFunc(profiles, func(p ClientProfile) bool { return strings.EqualFold(p.Name, strings.TrimSpace(name)) })
+		if i < 0 {
+			return ClientProfile{}, false
+		}
+		return profiles[i], true
+	}
+	if p, ok := find(acc.Profile); ok {
+		return p
+	}
+	if id := acc.Identifier(); c.Diversify && id != "" {
+		pool := c.Pool
+		if len(pool) == 0 {
+			for _, p := range profiles {
+				pool = append(pool, p.Name)
+			}
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(id))
+		if p, ok := find(pool[int(h.Sum32()%uint32(len(pool)))]); ok {
+			return p
+		}
+	}
+	if p, ok := find(c.Profile); ok {
+		return p
+	}
+	p, _ := find(DefaultClientProfile)
+	return p
+}
+
+// ForUpstream points the Origin and Referer headers that name the default
+// DeepSeek site at the origin of u, so the web profiles follow a configured
+// base URL. Other values are kept as configured.
+func (p ClientProfile) ForUpstream(u Upstream) ClientProfile {
+	p = p.clone()
+	origin := u.Origin()
+	for k, v := range p.Headers {
+		switch {
+		case strings.EqualFold(k, "Origin") && v == DefaultUpstreamBaseURL:
+			p.Headers[k] = origin
+		case strings.EqualFold(k, "Referer") && strings.HasPrefix(v, DefaultUpstreamBaseURL+"/"):
+			p.Headers[k] = origin + strings.TrimPrefix(v, DefaultUpstreamBaseURL)
+		}
+	}
+	return p
+}
+
+// HasProfile reports whether name is a built-in or configured profile.
+func (c IdentityConfig) HasProfile(name string) bool {
+	name = strings.TrimSpace(name)
+	return slices.ContainsFunc(c.ClientProfiles(), func(p ClientProfile) bool { return strings.EqualFold(p.Name, name) })
+}
+
+// UsesHTTP2 reports whether the profile asks for HTTP/2.
+func (p ClientProfile) UsesHTTP2() bool {
+	return strings.TrimSpace(p.HTTPVersion) == "2"
+}
+
+// ValidateIdentity checks the profiles and every reference to a profile
+// name from the identity section and the accounts.
+func ValidateIdentity(c Config) error {
+	for i, p := range c.Identity.Profiles {
+		if strings.TrimSpace(p.Name) == "" {
+			return fmt.Errorf("identity.profiles[%d].name is required", i)
+		}
+		if err := validateClientProfile(p); err != nil {
+			return fmt.Errorf("identity.profiles[%d] (%s): %v", i, p.Name, err)
+		}
+	}
+	known := c.Identity.HasProfile
+	if name := strings.TrimSpace(c.Identity.Profile); name != "" && !known(name) {
+		return fmt.Errorf("identity.profile names unknown profile %q", name)
+	}
+	for i, name := range c.Identity.Pool {
+		if !known(name) {
+			return fmt.Errorf("identity.pool[%d] names unknown profile %q", i, name)
+		}
+	}
+	for _, acc := range c.Accounts {
+		if name := strings.TrimSpace(acc.Profile); name != "" && !known(name) {
+			return fmt.Errorf("accounts[%s].profile names unknown profile %q", acc.Identifier(), name)
+		}
+	}
+	return nil
+}
+
+func validateClientProfile(p ClientProfile) error {
+	hello := lower(strings.TrimSpace(p.ClientHello))
+	if !slices.Contains(ClientHellos, hello) {
+		return fmt.Errorf("client_hello must be one of %s", strings.Join(ClientHellos, ", "))
+	}
+	switch strings.TrimSpace(p.HTTPVersion) {
+	case "", "1.1":
+	case "2":
+		if hello != "golang" {
+			return fmt.Errorf("http_version 2 needs client_hello golang")
+		}
+	default:
+		return fmt.Errorf("http_version must be 1.1 or 2")
+	}
+	if hello != "golang" && slices.Contains(p.ALPN, "h2") {
+		return fmt.Errorf("alpn may only offer h2 with client_hello golang")
+	}
+	for k := range p.Headers {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("header names must not be empty")
+		}
+	}
+	return nil
+}
+
+func (c IdentityConfig) clone() IdentityConfig {
+	c.Pool = slices.Clone(c.Pool)
+	c.Profiles = cloneClientProfiles(c.Profiles)
+	return c
+}
+
+func (p ClientProfile) clone() ClientProfile {
+	p.ALPN = slices.Clone(p.ALPN)
+	p.Headers = maps.Clone(p.Headers)
+	return p
+}
+
+func cloneClientProfiles(in []ClientProfile) []ClientProfile {
+	if in == nil {
+		return nil
+	}
+	out := make([]ClientProfile, len(in))
+	for i, p := range in {
+		out[i] = p.clone()
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIdentityProfileForPrecedence(t *testing.T) {
+	c := IdentityConfig{Profile: "chrome_web"}
+	if got := c.ProfileFor(Account{}).Name; got != "chrome_web" {
+		t.Fatalf("expected the global profile for direct callers, got %q", got)
+	}
+	if got := c.ProfileFor(Account{Email: "a@example.com", Profile: "ios_app"}).Name; got != "ios_app" {
+		t.Fatalf("expected the account profile to win, got %q", got)
+	}
+	if got := (IdentityConfig{}).ProfileFor(Account{Email: "a@example.com"}); got.Name != DefaultClientProfile || got.ClientHello != "safari" {
+		t.Fatalf("expected the default profile, got %#v", got)
+	}
+}
+
+func TestIdentityDiversifyIsStableAndSpread(t *testing.T) {
+	c := IdentityConfig{Profile: "default", Diversify: true, Pool: []string{"android_okhttp", "ios_app", "chrome_web"}}
+	seen := map[string]bool{}
+	for i := 0; i < 30; i++ {
+		acc := Account{Email: fmt.Sprintf("user%d@example.com", i)}
+		name := c.ProfileFor(acc).Name
+		if again := c.ProfileFor(acc).Name; again != name {
+			t.Fatalf("expected a stable pick, got %q then %q", name, again)
+		}
+		seen[name] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected accounts spread over the pool, got %v", seen)
+	}
+	if got := c.ProfileFor(Account{}).Name; got != "default" {
+		t.Fatalf("expected direct callers on the global profile, got %q", got)
+	}
+}
+
+func TestValidateIdentity(t *testing.T) {
+	ok := Config{
+		Identity: IdentityConfig{
+			Profile:  "edge_h2",
+			Profiles: []ClientProfile{{Name: "edge_h2", ClientHello: "golang", HTTPVersion: "2"}},
+		},
+		Accounts: []Account{{Email: "a@example.com", Profile: "ios_app"}},
+	}
+	if err := ValidateIdentity(ok); err != nil {
+		t.Fatalf("expected valid identity, got %v", err)
+	}
+	cases := map[string]Config{
+		"client_hello":  {Identity: IdentityConfig{Profiles: []ClientProfile{{Name: "x", ClientHello: "netscape"}}}},
+		"http_version":  {Identity: IdentityConfig{Profiles: []ClientProfile{{Name: "x", ClientHello: "chrome", HTTPVersion: "2"}}}},
+		"alpn":          {Identity: IdentityConfig{Profiles: []ClientProfile{{Name: "x", ClientHello: "chrome", ALPN: []string{"h2"}}}}},
+		"identity.pool": {Identity: IdentityConfig{Pool: []string{"missing"}}},
+		"accounts[":     {Accounts: []Account{{Email: "a@example.com", Profile: "missing"}}},
+	}
+	for want, c := range cases {
+		if err := ValidateIdentity(c); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected an error mentioning %q, got %v", want, err)
+		}
+	}
+}
+
+func TestProfileForUpstreamFollowsTheBaseURL(t *testing.T) {
+	up := Upstream{Scheme: "http", Host: "127.0.0.1:9000"}
+	web := (IdentityConfig{Profile: "chrome_web"}).ProfileFor(Account{})
+	got := web.ForUpstream(up)
+	if got.Headers["Origin"] != "http://127.0.0.1:9000" || got.Headers["Referer"] != "http://127.0.0.1:9000/" {
+		t.Fatalf("expected Origin and Referer on the configured upstream, got %#v", got.Headers)
+	}
+	if web.Headers["Origin"] != DefaultUpstreamBaseURL {
+		t.Fatalf("expected the source profile left untouched, got %#v", web.Headers)
+	}
+	custom := ClientProfile{Name: "own", Headers: map[string]string{"origin": "https://example.com"}}
+	if got := custom.ForUpstream(up); got.Headers["origin"] != "https://example.com" {
+		t.Fatalf("expected a configured Origin kept, got %#v", got.Headers)
+	}
+}
@@ -31,7 +31,7 @@ func TestResolveModelUnknown(t *testing.T) {
 }
 
 func TestClaudeModelsResponsePaginationFields(t *testing.T) {
-	resp := ClaudeModelsResponse()
+	resp := ClaudeModelsResponse(nil)
 	if _, ok := resp["first_id"]; !ok {
 		t.Fatalf("expected first_id in response: %#v", resp)
 	}
@@ -42,3 +42,63 @@ func TestClaudeModelsResponsePaginationFields(t *testing.T) {
 		t.Fatalf("expected has_more in response: %#v", resp)
 	}
 }
+
+func TestResolveModelUsesConfiguredRegistry(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{"models":[
+		{"id":"DeepSeek-Fast","owned_by":"me","search":true,"context_window":32000,"surfaces":["openai"]},
+		{"id":"deepseek-chat-search","disabled":true}
+	]}`)
+	store := LoadStore()
+
+	got, ok := ResolveModel(store, "deepseek-fast")
+	if !ok || got != "deepseek-fast" {
+		t.Fatalf("expected configured model to resolve, got ok=%v model=%q", ok, got)
+	}
+	if thinking, search, ok := ModelConfigFor(store, got); !ok || thinking || !search {
+		t.Fatalf("expected configured upstream mode, got thinking=%v search=%v ok=%v", thinking, search, ok)
+	}
+	if _, ok := ResolveModel(store, "deepseek-chat-search"); ok {
+		t.Fatal("expected disabled model to stop resolving")
+	}
+	if _, ok := ResolveModel(store, "gpt-4o-search"); ok {
+		t.Fatal("expected heuristic fallback to a disabled model to fail")
+	}
+
+	data, _ := OpenAIModelsResponse(store)["data"].([]ModelInfo)
+	ids := map[string]ModelInfo{}
+	for _, m := range data {
+		ids[m.ID] = m
+	}
+	if ids["deepseek-fast"].ContextWindow != 32000 || ids["deepseek-fast"].OwnedBy != "me" {
+		t.Fatalf("expected configured model listed with its metadata, got %#v", data)
+	}
+	if _, ok := ids["deepseek-chat-search"]; ok {
+		t.Fatalf("expected disabled model unlisted, got %#v", data)
+	}
+	if _, ok := ids["claude-sonnet-4-5"]; ok {
+		t.Fatalf("expected claude models only on the claude surface, got %#v", data)
+	}
+}
+
+func TestGeminiModelsResponseListsRegistry(t *testing.T) {
+	models, _ := GeminiModelsResponse(nil)["models"].([]map[string]any)
+	if len(models) != 4 {
+		t.Fatalf("expected the four deepseek models, got %#v", models)
+	}
+	first := models[0]
+	if first["name"] != "models/deepseek-chat" || first["inputTokenLimit"] != deepSeekContextWindow || first["thinking"] != false {
+		t.Fatalf("unexpected gemini model %#v", first)
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	if err := ValidateModels([]ModelConfig{{ID: "a"}, {ID: "A"}}); err == nil {
+		t.Fatal("expected duplicate ids to be rejected")
+	}
+	if err := ValidateModels([]ModelConfig{{ID: "a", Surfaces: []string{"bard"}}}); err == nil {
+		t.Fatal("expected unknown surface to be rejected")
+	}
+	if err := ValidateModels([]ModelConfig{{ID: "a", Surfaces: []string{"Gemini"}}}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Surfaces a registry model can be listed on. A model without surfaces is
+// listed on all of them.
+const (
+	ModelSurfaceOpenAI = "openai"
+	ModelSurfaceClaude = "claude"
+	ModelSurfaceGemini = "gemini"
+)
+
+// ModelRegistryReader is implemented by stores that carry a models section.
+// Alias readers without it resolve against the built-in DefaultModels.
+type ModelRegistryReader interface {
+	Models() []ModelConfig
+}
+
+const (
+	deepSeekModelCreated  = 1677610602
+	claudeModelCreated    = 1715635200
+	deepSeekContextWindow = 131072
+)
+
+var defaultClaudeModelIDs = []string{
+	// Current aliases
+	"claude-opus-4-6", "claude-sonnet-4-5", "claude-haiku-4-5",
+
+	// Current snapshots
+	"claude-opus-4-5-20251101", "claude-opus-4-1", "claude-opus-4-1-20250805",
+	"claude-opus-4-0", "claude-opus-4-20250514", "claude-sonnet-4-5-20250929",
+	"claude-sonnet-4-0", "claude-sonnet-4-20250514", "claude-haiku-4-5-20251001",
+
+	// Claude 3.x (legacy/deprecated snapshots and aliases)
+	"claude-3-7-sonnet-latest", "claude-3-7-sonnet-20250219", "claude-3-5-sonnet-latest",
+	"claude-3-5-sonnet-20240620", "claude-3-5-sonnet-20241022", "claude-3-opus-20240229",
+	"claude-3-sonnet-20240229", "claude-3-5-haiku-latest", "claude-3-5-haiku-20241022",
+	"claude-3-haiku-20240307",
+
+	// Claude 2.x and 1.x (retired but accepted for compatibility)
+	"claude-2.1", "claude-2.0", "claude-1.3", "claude-1.2", "claude-1.1", "claude-1.0",
+	"claude-instant-1.2", "claude-instant-1.1", "claude-instant-1.0",
+}
+
+// DefaultModels is the registry used when config has no models section: the
+// four DeepSeek modes for OpenAI and Gemini clients, and the Claude model ids
+// Anthropic clients expect, with opus variants thinking.
+func DefaultModels() []ModelConfig {
+	deepseek := func(id, name string, thinking, search bool, maxOutput int) ModelConfig {
+		return ModelConfig{
+			ID: id, OwnedBy: "deepseek", DisplayName: name, Thinking: thinking, Search: search,
+			ContextWindow: deepSeekContextWindow, MaxOutputTokens: maxOutput,
+			Surfaces: []string{ModelSurfaceOpenAI, ModelSurfaceGemini}, Created: deepSeekModelCreated,
+		}
+	}
+	models := []ModelConfig{
+		deepseek("deepseek-chat", "DeepSeek Chat", false, false, 8192),
+		deepseek("deepseek-reasoner", "DeepSeek Reasoner", true, false, 65536),
+		deepseek("deepseek-chat-search", "DeepSeek Chat (Search)", false, true, 8192),
+		deepseek("deepseek-reasoner-search", "DeepSeek Reasoner (Search)", true, true, 65536),
+	}
+	for _, id := range defaultClaudeModelIDs {
+		models = append(models, ModelConfig{
+			ID: id, OwnedBy: "anthropic", Thinking: strings.Contains(id, "opus"),
+			Surfaces: []string{ModelSurfaceClaude}, Created: claudeModelCreated,
+		})
+	}
+	return models
+}
+
+// MergeModels overlays configured entries on the defaults by id. Disabled
+// entries remove the model and entries without an id are ignored.
+func MergeModels(defaults, configured []ModelConfig) []ModelConfig {
+	out := make([]ModelConfig, 0, len(defaults)+len(configured))
+	index := map[string]int{}
+	for _, m := range slices.Concat(defaults, configured) {
+		m = normalizeModelConfig(m)
+		if m.ID == "" {
+			continue
+		}
+		if i, ok := index[m.ID]; ok {
+			out[i] = m
+			continue
+		}
+		index[m.ID] = len(out)
+		out = append(out, m)
+	}
+	return slices.DeleteFunc(out, func(m ModelConfig) bool { return m.Disabled })
+}
+
+// ValidateModels checks a configured models section.
+func ValidateModels(models []ModelConfig) error {
+	seen := map[string]bool{}
+	for i, m := range models {
+		id := lower(strings.TrimSpace(m.ID))
+		if id == "" {
+			return fmt.Errorf("models[%d].id is required", i)
+		}
+		if seen[id] {
+			return fmt.Errorf("models[%d].id %q is duplicated", i, id)
+		}
+		seen[id] = true
+		if m.ContextWindow < 0 || m.MaxOutputTokens < 0 {
+			return fmt.Errorf("models[%d] token limits must not be negative", i)
+		}
+		for _, surface := range m.Surfaces {
+			switch lower(strings.TrimSpace(surface)) {
+			case ModelSurfaceOpenAI, ModelSurfaceClaude, ModelSurfaceGemini:
+			default:
+				return fmt.Errorf("models[%d].surfaces must contain only openai, claude or gemini", i)
+			}
+		}
+	}
+	return nil
+}
+
+func normalizeModelConfig(m ModelConfig) ModelConfig {
+	m.ID = lower(strings.TrimSpace(m.ID))
+	m.OwnedBy = strings.TrimSpace(m.OwnedBy)
+	m.DisplayName = strings.TrimSpace(m.DisplayName)
+	surfaces := make([]string, 0, len(m.Surfaces))
+	for _, s := range m.Surfaces {
+		if s = lower(strings.TrimSpace(s)); s != "" {
+			surfaces = append(surfaces, s)
+		}
+	}
+	m.Surfaces = surfaces
+	return m
+}
+
+// ListedOn reports whether the model appears in surface's model listing.
+func (m ModelConfig) ListedOn(surface string) bool {
+	return len(m.Surfaces) == 0 || slices.Contains(m.Surfaces, surface)
+}
+
+// ModelRegistry returns the models reader serves; a nil reader serves the
+// defaults.
+func ModelRegistry(reader ModelRegistryReader) []ModelConfig {
+	if reader == nil {
+		return DefaultModels()
+	}
+	return reader.Models()
+}
+
+// LookupModel finds a registry model by exact id, ignoring case.
+func LookupModel(reader ModelRegistryReader, id string) (ModelConfig, bool) {
+	id = lower(strings.TrimSpace(id))
+	if id == "" {
+		return ModelConfig{}, false
+	}
+	for _, m := range ModelRegistry(reader) {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return ModelConfig{}, false
+}
+
+// ModelConfigFor reports the upstream mode of a registry model.
+func ModelConfigFor(reader ModelRegistryReader, model string) (thinking bool, search bool, ok bool) {
+	m, ok := LookupModel(reader, model)
+	return m.Thinking, m.Search, ok
+}
+
+func cloneModels(in []ModelConfig) []ModelConfig {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]ModelConfig, len(in))
+	for i, m := range in {
+		m.Surfaces = slices.Clone(m.Surfaces)
+		out[i] = m
+	}
+	return out
+}
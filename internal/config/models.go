@@ -3,83 +3,22 @@ package config
 import "strings"
 
 type ModelInfo struct {
-	ID         string `json:"id"`
-	Object     string `json:"object"`
-	Created    int64  `json:"created"`
-	OwnedBy    string `json:"owned_by"`
-	Permission []any  `json:"permission,omitempty"`
+	ID              string `json:"id"`
+	Object          string `json:"object"`
+	Created         int64  `json:"created"`
+	OwnedBy         string `json:"owned_by"`
+	DisplayName     string `json:"display_name,omitempty"`
+	ContextWindow   int    `json:"context_window,omitempty"`
+	MaxOutputTokens int    `json:"max_output_tokens,omitempty"`
 }
 
 type ModelAliasReader interface {
 	ModelAliases() map[string]string
 }
 
-var DeepSeekModels = []ModelInfo{
-	{ID: "deepseek-chat", Object: "model", Created: 1677610602, OwnedBy: "deepseek", Permission: []any{}},
-	{ID: "deepseek-reasoner", Object: "model", Created: 1677610602, OwnedBy: "deepseek", Permission: []any{}},
-	{ID: "deepseek-chat-search", Object: "model", Created: 1677610602, OwnedBy: "deepseek", Permission: []any{}},
-	{ID: "deepseek-reasoner-search", Object: "model", Created: 1677610602, OwnedBy: "deepseek", Permission: []any{}},
-}
-
-var ClaudeModels = []ModelInfo{
-	// Current aliases
-	{ID: "claude-opus-4-6", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-sonnet-4-5", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-haiku-4-5", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-
-	// Current snapshots
-	{ID: "claude-opus-4-5-20251101", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-opus-4-1", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-opus-4-1-20250805", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-opus-4-0", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-opus-4-20250514", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-sonnet-4-5-20250929", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-sonnet-4-0", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-sonnet-4-20250514", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-haiku-4-5-20251001", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-
-	// Claude 3.x (legacy/deprecated snapshots and aliases)
-	{ID: "claude-3-7-sonnet-latest", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-7-sonnet-20250219", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-5-sonnet-latest", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-5-sonnet-20240620", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-5-sonnet-20241022", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-opus-20240229", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-sonnet-20240229", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-5-haiku-latest", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-5-haiku-20241022", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-3-haiku-20240307", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-
-	// Claude 2.x and 1.x (retired but accepted for compatibility)
-	{ID: "claude-2.1", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-2.0", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-1.3", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-1.2", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-1.1", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-1.0", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-instant-1.2", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-instant-1.1", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-	{ID: "claude-instant-1.0", Object: "model", Created: 1715635200, OwnedBy: "anthropic"},
-}
-
+// GetModelConfig reports the upstream mode of a built-in model.
 func GetModelConfig(model string) (thinking bool, search bool, ok bool) {
-	switch lower(model) {
-	case "deepseek-chat":
-		return false, false, true
-	case "deepseek-reasoner":
-		return true, false, true
-	case "deepseek-chat-search":
-		return false, true, true
-	case "deepseek-reasoner-search":
-		return true, true, true
-	default:
-		return false, false, false
-	}
-}
-
-func IsSupportedDeepSeekModel(model string) bool {
-	_, _, ok := GetModelConfig(model)
-	return ok
+	return ModelConfigFor(nil, model)
 }
 
 func DefaultModelAliases() map[string]string {
@@ -113,7 +52,8 @@ func ResolveModel(store ModelAliasReader, requested string) (string, bool) {
 	if model == "" {
 		return "", false
 	}
-	if IsSupportedDeepSeekModel(model) {
+	registry, _ := store.(ModelRegistryReader)
+	if _, ok := LookupModel(registry, model); ok {
 		return model, true
 	}
 	aliases := DefaultModelAliases()
@@ -122,8 +62,10 @@ func ResolveModel(store ModelAliasReader, requested string) (string, bool) {
 			aliases[lower(strings.TrimSpace(k))] = lower(strings.TrimSpace(v))
 		}
 	}
-	if mapped, ok := aliases[model]; ok && IsSupportedDeepSeekModel(mapped) {
-		return mapped, true
+	if mapped, ok := aliases[model]; ok {
+		if _, ok := LookupModel(registry, mapped); ok {
+			return mapped, true
+		}
 	}
 	if strings.HasPrefix(model, "deepseek-") {
 		return "", false
@@ -150,16 +92,19 @@ func ResolveModel(store ModelAliasReader, requested string) (string, bool) {
 		strings.Contains(model, "r1")
 	useSearch := strings.Contains(model, "search")
 
+	fallback := "deepseek-chat"
 	switch {
 	case useReasoner && useSearch:
-		return "deepseek-reasoner-search", true
+		fallback = "deepseek-reasoner-search"
 	case useReasoner:
-		return "deepseek-reasoner", true
+		fallback = "deepseek-reasoner"
 	case useSearch:
-		return "deepseek-chat-search", true
-	default:
-		return "deepseek-chat", true
+		fallback = "deepseek-chat-search"
+	}
+	if _, ok := LookupModel(registry, fallback); !ok {
+		return "", false
 	}
+	return fallback, true
 }
 
 func lower(s string) string {
@@ -172,8 +117,30 @@ func lower(s string) string {
 	return string(b)
 }
 
-func OpenAIModelsResponse() map[string]any {
-	return map[string]any{"object": "list", "data": DeepSeekModels}
+func (m ModelConfig) info() ModelInfo {
+	return ModelInfo{
+		ID:              m.ID,
+		Object:          "model",
+		Created:         m.Created,
+		OwnedBy:         m.OwnedBy,
+		DisplayName:     m.DisplayName,
+		ContextWindow:   m.ContextWindow,
+		MaxOutputTokens: m.MaxOutputTokens,
+	}
+}
+
+func listedModels(reader ModelRegistryReader, surface string) []ModelInfo {
+	out := []ModelInfo{}
+	for _, m := range ModelRegistry(reader) {
+		if m.ListedOn(surface) {
+			out = append(out, m.info())
+		}
+	}
+	return out
+}
+
+func OpenAIModelsResponse(reader ModelRegistryReader) map[string]any {
+	return map[string]any{"object": "list", "data": listedModels(reader, ModelSurfaceOpenAI)}
 }
 
 func OpenAIModelByID(store ModelAliasReader, id string) (ModelInfo, bool) {
@@ -181,19 +148,20 @@ func OpenAIModelByID(store ModelAliasReader, id string) (ModelInfo, bool) {
 	if !ok {
 		return ModelInfo{}, false
 	}
-	for _, model := range DeepSeekModels {
-		if model.ID == canonical {
-			return model, true
-		}
+	registry, _ := store.(ModelRegistryReader)
+	model, ok := LookupModel(registry, canonical)
+	if !ok {
+		return ModelInfo{}, false
 	}
-	return ModelInfo{}, false
+	return model.info(), true
 }
 
-func ClaudeModelsResponse() map[string]any {
-	resp := map[string]any{"object": "list", "data": ClaudeModels}
-	if len(ClaudeModels) > 0 {
-		resp["first_id"] = ClaudeModels[0].ID
-		resp["last_id"] = ClaudeModels[len(ClaudeModels)-1].ID
+func ClaudeModelsResponse(reader ModelRegistryReader) map[string]any {
+	models := listedModels(reader, ModelSurfaceClaude)
+	resp := map[string]any{"object": "list", "data": models}
+	if len(models) > 0 {
+		resp["first_id"] = models[0].ID
+		resp["last_id"] = models[len(models)-1].ID
 	} else {
 		resp["first_id"] = nil
 		resp["last_id"] = nil
@@ -201,3 +169,33 @@ func ClaudeModelsResponse() map[string]any {
 	resp["has_more"] = false
 	return resp
 }
+
+// GeminiModelsResponse lists the registry in the shape of Gemini's
+// models.list.
+func GeminiModelsResponse(reader ModelRegistryReader) map[string]any {
+	models := []map[string]any{}
+	for _, m := range ModelRegistry(reader) {
+		if !m.ListedOn(ModelSurfaceGemini) {
+			continue
+		}
+		displayName := m.DisplayName
+		if displayName == "" {
+			displayName = m.ID
+		}
+		item := map[string]any{
+			"name":                       "models/" + m.ID,
+			"baseModelId":                m.ID,
+			"displayName":                displayName,
+			"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent"},
+			"thinking":                   m.Thinking,
+		}
+		if m.ContextWindow > 0 {
+			item["inputTokenLimit"] = m.ContextWindow
+		}
+		if m.MaxOutputTokens > 0 {
+			item["outputTokenLimit"] = m.MaxOutputTokens
+		}
+		models = append(models, item)
+	}
+	return map[string]any{"models": models}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PromptTemplateReader is implemented by stores that carry prompt templates.
+type PromptTemplateReader interface {
+	PromptTemplates() []PromptTemplate
+}
+
+// Matches reports whether the template applies to a request for model made
+// with key. Models are case-insensitive globs.
+func (t PromptTemplate) Matches(model, key string) bool {
+	model = lower(strings.TrimSpace(model))
+	if len(t.Models) > 0 && !slices.ContainsFunc(t.Models, func(glob string) bool {
+		return globMatch(strings.TrimSpace(glob), model)
+	}) {
+		return false
+	}
+	if len(t.Keys) > 0 && !slices.Contains(t.Keys, strings.TrimSpace(key)) {
+		return false
+	}
+	return true
+}
+
+// SelectPromptTemplate returns the first template that matches model and key.
+func SelectPromptTemplate(templates []PromptTemplate, model, key string) (PromptTemplate, bool) {
+	for _, t := range templates {
+		if t.Matches(model, key) {
+			return t, true
+		}
+	}
+	return PromptTemplate{}, false
+}
+
+// ValidatePromptTemplates checks names and conditions. Template syntax is
+// checked by the caller, which knows the template functions.
+func ValidatePromptTemplates(templates []PromptTemplate) error {
+	seen := map[string]bool{}
+	for i, t := range templates {
+		if err := validateTemplateEntry("prompt_templates", i, t.Name, t.Template, t.Models, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTemplateEntry checks what prompt templates and tool prompts have in
+// common: a unique name other than the reserved "default", a template and
+// non-empty model patterns.
+func validateTemplateEntry(section string, i int, name, text string, models []string, seen map[string]bool) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%s[%d].name is required", section, i)
+	}
+	if lower(name) == "default" {
+		return fmt.Errorf("%s[%d].name %q is reserved for the built-in template", section, i, name)
+	}
+	if seen[lower(name)] {
+		return fmt.Errorf("%s[%d].name %q is duplicated", section, i, name)
+	}
+	seen[lower(name)] = true
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("%s[%d].template is required", section, i)
+	}
+	for _, glob := range models {
+		if strings.TrimSpace(glob) == "" {
+			return fmt.Errorf("%s[%d].models must not contain empty patterns", section, i)
+		}
+	}
+	return nil
+}
+
+func clonePromptTemplates(in []PromptTemplate) []PromptTemplate {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]PromptTemplate, len(in))
+	for i, t := range in {
+		t.Models = slices.Clone(t.Models)
+		t.Keys = slices.Clone(t.Keys)
+		out[i] = t
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"ds2api/internal/memo"
+)
+
+// RoutingReader is implemented by stores that carry routing rules.
+type RoutingReader interface {
+	RoutingRules() []RoutingRule
+}
+
+// RouteInput is what routing rules are matched against.
+type RouteInput struct {
+	Model   string
+	Key     string
+	Surface string
+	Headers http.Header
+}
+
+// RouteDecision is the model a request is served by and its upstream mode.
+// Rule is the index of the routing rule that decided it, or -1, and
+// AccountGroup the account group that rule binds the request to.
+type RouteDecision struct {
+	Model        string `json:"model"`
+	Thinking     bool   `json:"thinking"`
+	Search       bool   `json:"search"`
+	Rule         int    `json:"rule"`
+	RuleName     string `json:"rule_name,omitempty"`
+	AccountGroup string `json:"account_group,omitempty"`
+}
+
+// routingRegexCache holds compiled rule patterns. It is bounded so patterns
+// edited out of config do not accumulate.
+var routingRegexCache = memo.New[string, *regexp.Regexp](256)
+
+// Matches reports whether every condition set on the rule holds for in.
+func (r RoutingRule) Matches(in RouteInput) bool {
+	model := lower(strings.TrimSpace(in.Model))
+	if glob := strings.TrimSpace(r.Model); glob != "" && !globMatch(glob, model) {
+		return false
+	}
+	if pattern := strings.TrimSpace(r.ModelRegex); pattern != "" {
+		re, err := routingRegex(pattern)
+		if err != nil || !re.MatchString(model) {
+			return false
+		}
+	}
+	if len(r.Keys) > 0 && !slices.Contains(r.Keys, strings.TrimSpace(in.Key)) {
+		return false
+	}
+	if len(r.Surfaces) > 0 && !slices.ContainsFunc(r.Surfaces, func(s string) bool {
+		return lower(strings.TrimSpace(s)) == in.Surface
+	}) {
+		return false
+	}
+	for name, glob := range r.Headers {
+		values := in.Headers.Values(name)
+		if len(values) == 0 {
+			return false
+		}
+		if !globMatch(glob, strings.TrimSpace(values[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteByRule applies the first rule that matches in and whose target is a
+// registry model. ok is false when no rule applies.
+func RouteByRule(rules []RoutingRule, registry ModelRegistryReader, in RouteInput) (RouteDecision, bool) {
+	for i, rule := range rules {
+		if !rule.Matches(in) {
+			continue
+		}
+		target, ok := LookupModel(registry, rule.Target)
+		if !ok {
+			continue
+		}
+		decision := RouteDecision{Model: target.ID, Thinking: target.Thinking, Search: target.Search, Rule: i, RuleName: rule.Name, AccountGroup: strings.TrimSpace(rule.AccountGroup)}
+		if rule.Thinking != nil {
+			decision.Thinking = *rule.Thinking
+		}
+		if rule.Search != nil {
+			decision.Search = *rule.Search
+		}
+		return decision, true
+	}
+	return RouteDecision{}, false
+}
+
+// ResolveRoute picks the model for a request: the first applicable routing
+// rule, otherwise ResolveModel.
+func ResolveRoute(store ModelAliasReader, in RouteInput) (RouteDecision, bool) {
+	registry, _ := store.(ModelRegistryReader)
+	if routing, ok := store.(RoutingReader); ok && routing != nil {
+		if decision, ok := RouteByRule(routing.RoutingRules(), registry, in); ok {
+			return decision, true
+		}
+	}
+	model, ok := ResolveModel(store, in.Model)
+	if !ok {
+		return RouteDecision{}, false
+	}
+	thinking, search, _ := ModelConfigFor(registry, model)
+	return RouteDecision{Model: model, Thinking: thinking, Search: search, Rule: -1}, true
+}
+
+// ValidateRoutingRules checks routing rules against the registry models.
+func ValidateRoutingRules(rules []RoutingRule, models []ModelConfig) error {
+	for i, r := range rules {
+		target := lower(strings.TrimSpace(r.Target))
+		if !slices.ContainsFunc(models, func(m ModelConfig) bool { return m.ID == target }) {
+			return fmt.Errorf("routing_rules[%d].target %q is not a registry model", i, r.Target)
+		}
+		if pattern := strings.TrimSpace(r.ModelRegex); pattern != "" {
+			if _, err := routingRegex(pattern); err != nil {
+				return fmt.Errorf("routing_rules[%d].model_regex is invalid: %v", i, err)
+			}
+		}
+		for name, glob := range r.Headers {
+			if strings.TrimSpace(name) == "" || strings.TrimSpace(glob) == "" {
+				return fmt.Errorf("routing_rules[%d].headers needs a name and a value pattern", i)
+			}
+		}
+		for _, surface := range r.Surfaces {
+			switch lower(strings.TrimSpace(surface)) {
+			case ModelSurfaceOpenAI, ModelSurfaceClaude, ModelSurfaceGemini:
+			default:
+				return fmt.Errorf("routing_rules[%d].surfaces must contain only openai, claude or gemini", i)
+			}
+		}
+	}
+	return nil
+}
+
+// globMatch matches s against a case-insensitive glob where * is any run of
+// characters, including "/", and ? is any single character.
+func globMatch(glob, s string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re, err := routingRegex(b.String())
+	return err == nil && re.MatchString(s)
+}
+
+// routingRegex compiles a case-insensitive pattern once per pattern.
+func routingRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := routingRegexCache.Get(pattern); ok {
+		return cached, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	routingRegexCache.Put(pattern, re)
+	return re, nil
+}
+
+func cloneRoutingRules(in []RoutingRule) []RoutingRule {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]RoutingRule, len(in))
+	for i, r := range in {
+		r.Keys = slices.Clone(r.Keys)
+		r.Surfaces = slices.Clone(r.Surfaces)
+		r.Headers = cloneStringMap(r.Headers)
+		r.Thinking = cloneBoolPtr(r.Thinking)
+		r.Search = cloneBoolPtr(r.Search)
+		out[i] = r
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func teamRoutingRules() []RoutingRule {
+	return []RoutingRule{
+		{Name: "team-x-mini", Model: "*-mini", Keys: []string{"key-x"}, Target: "deepseek-chat"},
+		{Name: "team-x", Model: "*", Keys: []string{"key-x"}, Target: "deepseek-reasoner"},
+	}
+}
+
+func TestRouteByRuleFirstMatchWins(t *testing.T) {
+	rules := teamRoutingRules()
+	cases := []struct {
+		model, key, want string
+		rule             int
+	}{
+		{"gpt-4o-mini", "key-x", "deepseek-chat", 0},
+		{"GPT-4.1", "key-x", "deepseek-reasoner", 1},
+		{"o3-mini", "key-x", "deepseek-chat", 0},
+	}
+	for _, tc := range cases {
+		got, ok := RouteByRule(rules, nil, RouteInput{Model: tc.model, Key: tc.key, Surface: ModelSurfaceOpenAI})
+		if !ok || got.Model != tc.want || got.Rule != tc.rule {
+			t.Fatalf("%s: expected %s via rule %d, got ok=%v %#v", tc.model, tc.want, tc.rule, ok, got)
+		}
+	}
+	if _, ok := RouteByRule(rules, nil, RouteInput{Model: "gpt-4o-mini", Key: "key-y"}); ok {
+		t.Fatal("expected rules scoped to key-x not to match key-y")
+	}
+}
+
+func TestRouteByRuleRegexSurfaceAndHeaders(t *testing.T) {
+	search := true
+	rules := []RoutingRule{
+		{ModelRegex: `^gemini-2\.5-(pro|flash)$`, Surfaces: []string{"gemini"}, Target: "deepseek-chat", Search: &search},
+		{Headers: map[string]string{"X-Team": "research/*"}, Target: "deepseek-reasoner-search"},
+	}
+	got, ok := RouteByRule(rules, nil, RouteInput{Model: "gemini-2.5-pro", Surface: ModelSurfaceGemini})
+	if !ok || got.Model != "deepseek-chat" || !got.Search || got.Thinking {
+		t.Fatalf("expected regex rule with search override, got ok=%v %#v", ok, got)
+	}
+	if _, ok := RouteByRule(rules[:1], nil, RouteInput{Model: "gemini-2.5-pro", Surface: ModelSurfaceOpenAI}); ok {
+		t.Fatal("expected gemini-only rule not to match openai surface")
+	}
+	headers := http.Header{}
+	headers.Set("x-team", "Research/alpha")
+	got, ok = RouteByRule(rules, nil, RouteInput{Model: "anything", Headers: headers})
+	if !ok || got.Model != "deepseek-reasoner-search" || got.Rule != 1 {
+		t.Fatalf("expected header rule, got ok=%v %#v", ok, got)
+	}
+}
+
+func TestResolveRouteFallsBackWhenRuleTargetMissing(t *testing.T) {
+	store := &Store{cfg: Config{RoutingRules: []RoutingRule{{Model: "gpt-*", Target: "not-a-model"}}}}
+	got, ok := ResolveRoute(store, RouteInput{Model: "gpt-4.1"})
+	if !ok || got.Model != "deepseek-chat" || got.Rule != -1 {
+		t.Fatalf("expected alias fallback, got ok=%v %#v", ok, got)
+	}
+}
+
+func TestValidateRoutingRules(t *testing.T) {
+	models := DefaultModels()
+	if err := ValidateRoutingRules(teamRoutingRules(), models); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bad := [][]RoutingRule{
+		{{Target: "missing"}},
+		{{ModelRegex: "(", Target: "deepseek-chat"}},
+		{{Surfaces: []string{"bedrock"}, Target: "deepseek-chat"}},
+		{{Headers: map[string]string{"X-Team": ""}, Target: "deepseek-chat"}},
+	}
+	for i, rules := range bad {
+		if err := ValidateRoutingRules(rules, models); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestRoutingRuleAccountGroup(t *testing.T) {
+	rules := []RoutingRule{{Keys: []string{"key-x"}, Target: "deepseek-chat", AccountGroup: " research "}}
+	got, ok := RouteByRule(rules, nil, RouteInput{Model: "gpt-4o", Key: "key-x"})
+	if !ok || got.AccountGroup != "research" {
+		t.Fatalf("expected the rule's account group, got %#v", got)
+	}
+	c := Config{RoutingRules: rules, Accounts: []Account{{Email: "a@example.com", Groups: []string{"Research"}}}}
+	if err := ValidateAccountGroups(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.KeyGroups = map[string]string{"key-y": "ops"}
+	if err := ValidateAccountGroups(c); err == nil {
+		t.Fatal("expected a key bound to a group without accounts to be rejected")
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// DefaultPriorityClass is the class of callers nothing else assigns.
+	DefaultPriorityClass = "default"
+	// PriorityHeader lets a caller without an assigned class pick one.
+	PriorityHeader = "X-Ds2api-Priority"
+)
+
+// SchedulingConfig is the scheduling section with the default class name
+// filled in.
+func (s *Store) SchedulingConfig() SchedulingConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.Scheduling.clone()
+	if strings.TrimSpace(out.DefaultClass) == "" {
+		out.DefaultClass = DefaultPriorityClass
+	}
+	return out
+}
+
+// ClassFor returns the class name of a caller: the class its key is assigned
+// to, else the requested class if one by that name exists, else the default.
+func (c SchedulingConfig) ClassFor(key, requested string) string {
+	if name, ok := c.KeyClasses[strings.TrimSpace(key)]; ok {
+		return lower(strings.TrimSpace(name))
+	}
+	if requested = lower(strings.TrimSpace(requested)); requested != "" {
+		if _, ok := c.Class(requested); ok {
+			return requested
+		}
+	}
+	return c.defaultClass()
+}
+
+// Class returns the class called name. The default class exists even when
+// it is not configured, with weight 1 and no limits of its own.
+func (c SchedulingConfig) Class(name string) (PriorityClass, bool) {
+	name = lower(strings.TrimSpace(name))
+	for _, class := range c.Classes {
+		if lower(strings.TrimSpace(class.Name)) == name {
+			class.Name = name
+			if class.Weight <= 0 {
+				class.Weight = 1
+			}
+			return class, true
+		}
+	}
+	if name == c.defaultClass() {
+		return PriorityClass{Name: name, Weight: 1}, true
+	}
+	return PriorityClass{}, false
+}
+
+// ClassNames lists the configured classes and the default class.
+func (c SchedulingConfig) ClassNames() []string {
+	names := make([]string, 0, len(c.Classes)+1)
+	for _, class := range c.Classes {
+		names = append(names, lower(strings.TrimSpace(class.Name)))
+	}
+	if def := c.defaultClass(); !slices.Contains(names, def) {
+		names = append(names, def)
+	}
+	return names
+}
+
+func (c SchedulingConfig) defaultClass() string {
+	if name := lower(strings.TrimSpace(c.DefaultClass)); name != "" {
+		return name
+	}
+	return DefaultPriorityClass
+}
+
+// ValidateScheduling checks class names, limits and class references.
+func ValidateScheduling(c SchedulingConfig) error {
+	seen := map[string]bool{}
+	for i, class := range c.Classes {
+		name := lower(strings.TrimSpace(class.Name))
+		if name == "" {
+			return fmt.Errorf("scheduling.classes[%d].name is required", i)
+		}
+		if seen[name] {
+			return fmt.Errorf("scheduling.classes[%d].name %q is duplicated", i, class.Name)
+		}
+		seen[name] = true
+		if class.Weight < 0 || class.Weight > 1000 {
+			return fmt.Errorf("scheduling.classes[%d].weight must be between 1 and 1000", i)
+		}
+		if class.MaxQueue < 0 || class.MaxQueuePerCaller < 0 || class.WaitTimeoutSeconds < 0 {
+			return fmt.Errorf("scheduling.classes[%d] limits must not be negative", i)
+		}
+	}
+	if def := strings.TrimSpace(c.DefaultClass); def != "" && !seen[lower(def)] {
+		return fmt.Errorf("scheduling.default_class %q is not a configured class", def)
+	}
+	for key, name := range c.KeyClasses {
+		if _, ok := c.Class(name); !ok {
+			return fmt.Errorf("scheduling.key_classes[%q] names unknown class %q", key, name)
+		}
+	}
+	return nil
+}
+
+func (c SchedulingConfig) clone() SchedulingConfig {
+	c.Classes = slices.Clone(c.Classes)
+	if c.KeyClasses != nil {
+		c.KeyClasses = maps.Clone(c.KeyClasses)
+	}
+	return c
+}
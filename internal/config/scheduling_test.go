@@ -0,0 +1,50 @@
+package config
+
+import "testing"
+
+func TestSchedulingClassFor(t *testing.T) {
+	c := SchedulingConfig{
+		Classes:    []PriorityClass{{Name: "Interactive", Weight: 4}, {Name: "batch"}},
+		KeyClasses: map[string]string{"bulk-key": "batch"},
+	}
+	cases := []struct{ key, requested, want string }{
+		{"bulk-key", "interactive", "batch"},
+		{"other", "INTERACTIVE", "interactive"},
+		{"other", "missing", DefaultPriorityClass},
+		{"other", "", DefaultPriorityClass},
+	}
+	for _, tc := range cases {
+		if got := c.ClassFor(tc.key, tc.requested); got != tc.want {
+			t.Fatalf("ClassFor(%q, %q) = %q, want %q", tc.key, tc.requested, got, tc.want)
+		}
+	}
+	if class, ok := c.Class("batch"); !ok || class.Weight != 1 {
+		t.Fatalf("expected batch to default to weight 1, got %#v", class)
+	}
+	if names := c.ClassNames(); len(names) != 3 || names[2] != DefaultPriorityClass {
+		t.Fatalf("expected the default class listed last, got %v", names)
+	}
+}
+
+func TestValidateScheduling(t *testing.T) {
+	for name, c := range map[string]SchedulingConfig{
+		"duplicate":     {Classes: []PriorityClass{{Name: "a"}, {Name: "A"}}},
+		"weight":        {Classes: []PriorityClass{{Name: "a", Weight: 5000}}},
+		"default":       {DefaultClass: "missing"},
+		"key_class":     {KeyClasses: map[string]string{"k": "missing"}},
+		"negative":      {Classes: []PriorityClass{{Name: "a", MaxQueue: -1}}},
+		"unnamed_class": {Classes: []PriorityClass{{Weight: 2}}},
+	} {
+		if err := ValidateScheduling(c); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+	c := SchedulingConfig{Classes: []PriorityClass{{Name: "batch", Weight: 1}}, KeyClasses: map[string]string{"k": "default"}, DefaultClass: "batch"}
+	if err := ValidateScheduling(c); err == nil {
+		t.Fatalf("expected default to be unknown once default_class is renamed")
+	}
+	c.KeyClasses = map[string]string{"k": "batch"}
+	if err := ValidateScheduling(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -17,6 +17,8 @@ type Store struct {
 	fromEnv bool
 	keyMap  map[string]struct{} // O(1) API key lookup index
 	accMap  map[string]int      // O(1) account lookup: identifier -> slice index
+	// revision counts Replace and Update calls.
+	revision uint64
 }
 
 func LoadStore() *Store {
@@ -27,6 +29,9 @@ func LoadStore() *Store {
 	if len(cfg.Keys) == 0 && len(cfg.Accounts) == 0 {
 		Logger.Warn("[config] empty config loaded")
 	}
+	if err := ValidateCompat(cfg.Compat); err != nil {
+		Logger.Warn("[config] invalid compat settings, falling back to the default reasoning mode", "error", err)
+	}
 	s := &Store{cfg: cfg, path: ConfigPath(), fromEnv: fromEnv}
 	s.rebuildIndexes()
 	return s
@@ -84,7 +89,7 @@ func (s *Store) Keys() []string {
 func (s *Store) Accounts() []Account {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return slices.Clone(s.cfg.Accounts)
+	return cloneAccounts(s.cfg.Accounts)
 }
 
 func (s *Store) FindAccount(identifier string) (Account, bool) {
@@ -138,6 +143,7 @@ func (s *Store) Replace(cfg Config) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.cfg = cfg.Clone()
+	s.revision++
 	s.rebuildIndexes()
 	return s.saveLocked()
 }
@@ -150,10 +156,19 @@ func (s *Store) Update(mutator func(*Config) error) error {
 		return err
 	}
 	s.cfg = cfg
+	s.revision++
 	s.rebuildIndexes()
 	return s.saveLocked()
 }
 
+// Revision changes whenever the config is replaced or updated, so state
+// built from it can tell when it is stale.
+func (s *Store) Revision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
+
 func (s *Store) Save() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -33,6 +35,81 @@ func (s *Store) ModelAliases() map[string]string {
 	return out
 }
 
+// Models is the model registry: the built-in models overlaid with the
+// configured models section.
+func (s *Store) Models() []ModelConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return MergeModels(DefaultModels(), s.cfg.Models)
+}
+
+func (s *Store) RoutingRules() []RoutingRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneRoutingRules(s.cfg.RoutingRules)
+}
+
+func (s *Store) PromptTemplates() []PromptTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return clonePromptTemplates(s.cfg.PromptTemplates)
+}
+
+func (s *Store) ToolPrompts() []ToolPrompt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneToolPrompts(s.cfg.ToolPrompts)
+}
+
+// ContextConfig is the context-window section; the strategy is lowercased and
+// an empty strategy leaves prompts untouched.
+func (s *Store) ContextConfig() ContextConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.Context
+	out.Strategy = strings.TrimSpace(strings.ToLower(out.Strategy))
+	return out
+}
+
+// ResponseCacheConfig is the response-cache section with defaults filled in:
+// the memory backend, a one-hour TTL, 64 MiB and, for the disk backend, a
+// directory under the working directory.
+func (s *Store) ResponseCacheConfig() ResponseCacheConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.ResponseCache
+	out.Backend = strings.TrimSpace(strings.ToLower(out.Backend))
+	if out.Backend == "" {
+		out.Backend = "memory"
+	}
+	if out.TTLSeconds <= 0 {
+		out.TTLSeconds = 3600
+	}
+	if out.MaxBytes <= 0 {
+		out.MaxBytes = 64 << 20
+	}
+	out.Dir = strings.TrimSpace(out.Dir)
+	if out.Dir == "" {
+		out.Dir = filepath.Join(BaseDir(), "data", "response-cache")
+	} else if !filepath.IsAbs(out.Dir) {
+		out.Dir = filepath.Join(BaseDir(), out.Dir)
+	}
+	return out
+}
+
+// CoalesceConfig is the coalescing section; at most 32 requests share a
+// completion unless max_subscribers says otherwise.
+func (s *Store) CoalesceConfig() CoalesceConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg.Coalesce
+	out.OptOutKeys = slices.Clone(out.OptOutKeys)
+	if out.MaxSubscribers <= 0 {
+		out.MaxSubscribers = 32
+	}
+	return out
+}
+
 func (s *Store) CompatWideInputStrictOutput() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -42,6 +119,18 @@ func (s *Store) CompatWideInputStrictOutput() bool {
 	return *s.cfg.Compat.WideInputStrictOutput
 }
 
+// CompatReasoningMode is the default reasoning presentation for requests
+// made with key that do not set reasoning_mode themselves: the key's own
+// default, else the global one.
+func (s *Store) CompatReasoningMode(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if mode := strings.TrimSpace(s.cfg.Compat.KeyReasoningModes[strings.TrimSpace(key)]); mode != "" {
+		return strings.ToLower(mode)
+	}
+	return strings.TrimSpace(strings.ToLower(s.cfg.Compat.ReasoningMode))
+}
+
 func (s *Store) ToolcallMode() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -62,6 +151,17 @@ func (s *Store) ToolcallEarlyEmitConfidence() string {
 	return level
 }
 
+// ToolcallRepairAttempts is the number of repair round-trips an invalid tool
+// call gets.
+func (s *Store) ToolcallRepairAttempts() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Toolcall.RepairAttempts == nil {
+		return 1
+	}
+	return max(*s.cfg.Toolcall.RepairAttempts, 0)
+}
+
 func (s *Store) ResponsesStoreTTLSeconds() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -71,6 +171,18 @@ func (s *Store) ResponsesStoreTTLSeconds() int {
 	return 900
 }
 
+// ResponsesStreamGraceSeconds is how long a Responses stream keeps generating
+// after its last client disconnects, and how long its events stay resumable
+// after it ends.
+func (s *Store) ResponsesStreamGraceSeconds() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cfg.Responses.StreamGraceSeconds > 0 {
+		return s.cfg.Responses.StreamGraceSeconds
+	}
+	return 30
+}
+
 func (s *Store) EmbeddingsProvider() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
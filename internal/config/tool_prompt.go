@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ToolPromptReader is implemented by stores that carry tool prompts.
+type ToolPromptReader interface {
+	ToolPrompts() []ToolPrompt
+}
+
+// Matches reports whether the tool prompt applies to a request on surface for
+// model made with key. Models are case-insensitive globs.
+func (t ToolPrompt) Matches(surface, model, key string) bool {
+	if len(t.Surfaces) > 0 && !slices.ContainsFunc(t.Surfaces, func(s string) bool {
+		return lower(strings.TrimSpace(s)) == surface
+	}) {
+		return false
+	}
+	return PromptTemplate{Models: t.Models, Keys: t.Keys}.Matches(model, key)
+}
+
+// SelectToolPrompt returns the first tool prompt that matches the request.
+func SelectToolPrompt(prompts []ToolPrompt, surface, model, key string) (ToolPrompt, bool) {
+	for _, t := range prompts {
+		if t.Matches(surface, model, key) {
+			return t, true
+		}
+	}
+	return ToolPrompt{}, false
+}
+
+// ValidateToolPrompts checks names, surfaces and conditions. Template syntax
+// is checked by the caller.
+func ValidateToolPrompts(prompts []ToolPrompt) error {
+	seen := map[string]bool{}
+	for i, t := range prompts {
+		if err := validateTemplateEntry("tool_prompts", i, t.Name, t.Template, t.Models, seen); err != nil {
+			return err
+		}
+		for _, surface := range t.Surfaces {
+			switch lower(strings.TrimSpace(surface)) {
+			case ModelSurfaceOpenAI, ModelSurfaceClaude, ModelSurfaceGemini:
+			default:
+				return fmt.Errorf("tool_prompts[%d].surfaces must contain only openai, claude or gemini", i)
+			}
+		}
+	}
+	return nil
+}
+
+func cloneToolPrompts(in []ToolPrompt) []ToolPrompt {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]ToolPrompt, len(in))
+	for i, t := range in {
+		t.Surfaces = slices.Clone(t.Surfaces)
+		t.Models = slices.Clone(t.Models)
+		t.Keys = slices.Clone(t.Keys)
+		out[i] = t
+	}
+	return out
+}
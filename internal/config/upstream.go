@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultUpstreamBaseURL is the DeepSeek web API host.
+const DefaultUpstreamBaseURL = "https://chat.deepseek.com"
+
+// Upstream endpoint names.
+const (
+	UpstreamLogin         = "login"
+	UpstreamCreateSession = "create_session"
+	UpstreamCreatePow     = "create_pow"
+	UpstreamCompletion    = "completion"
+)
+
+// DefaultUpstreamEndpoints are the paths of the DeepSeek endpoints.
+var DefaultUpstreamEndpoints = map[string]string{
+	UpstreamLogin:         "/api/v0/users/login",
+	UpstreamCreateSession: "/api/v0/chat_session/create",
+	UpstreamCreatePow:     "/api/v0/chat/create_pow_challenge",
+	UpstreamCompletion:    "/api/v0/chat/completion",
+}
+
+// UpstreamReader is implemented by stores that carry an upstream section.
+// Other stores resolve to the environment and the defaults.
+type UpstreamReader interface {
+	Upstream() Upstream
+}
+
+// Upstream is the resolved upstream: the scheme and host calls go to and
+// the path of every endpoint.
+type Upstream struct {
+	Scheme string
+	Host   string
+	Paths  map[string]string
+}
+
+// URL is the full URL of the endpoint name.
+func (u Upstream) URL(name string) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path(name)}).String()
+}
+
+// Origin is the scheme and host of the upstream, as sent in Origin headers.
+func (u Upstream) Origin() string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+// Path is the path of the endpoint name, as sent in the PoW target_path.
+func (u Upstream) Path(name string) string {
+	if p := u.Paths[name]; p != "" {
+		return p
+	}
+	return DefaultUpstreamEndpoints[name]
+}
+
+// Upstream resolves the upstream section. Config values win over the
+// DS2API_UPSTREAM_BASE_URL and DS2API_UPSTREAM_<NAME>_PATH environment
+// variables, which win over the defaults.
+func (s *Store) Upstream() Upstream {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return resolveUpstream(s.cfg.Upstream)
+}
+
+// UpstreamOf resolves the upstream of store, which may be nil.
+func UpstreamOf(store any) Upstream {
+	if r, ok := store.(UpstreamReader); ok && r != nil {
+		return r.Upstream()
+	}
+	return resolveUpstream(UpstreamConfig{})
+}
+
+func resolveUpstream(c UpstreamConfig) Upstream {
+	base := strings.TrimSpace(c.BaseURL)
+	if base == "" {
+		base = strings.TrimSpace(os.Getenv("DS2API_UPSTREAM_BASE_URL"))
+	}
+	u, err := parseUpstreamBaseURL(base)
+	if err != nil || u == nil {
+		if err != nil {
+			Logger.Warn("[config] invalid upstream base url, using default", "base_url", base, "error", err)
+		}
+		u, _ = url.Parse(DefaultUpstreamBaseURL)
+	}
+	out := Upstream{Scheme: u.Scheme, Host: u.Host, Paths: maps.Clone(DefaultUpstreamEndpoints)}
+	for name := range DefaultUpstreamEndpoints {
+		p := strings.TrimSpace(c.Endpoints[name])
+		if p != "" && !strings.HasPrefix(p, "/") {
+			Logger.Warn("[config] invalid upstream endpoint path, ignoring", "endpoint", name, "path", p)
+			p = ""
+		}
+		if p == "" {
+			p = strings.TrimSpace(os.Getenv("DS2API_UPSTREAM_" + strings.ToUpper(name) + "_PATH"))
+		}
+		if strings.HasPrefix(p, "/") {
+			out.Paths[name] = p
+		}
+	}
+	return out
+}
+
+func parseUpstreamBaseURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = lower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return nil, fmt.Errorf("base url must be scheme://host[:port] only")
+	}
+	return u, nil
+}
+
+// ValidateUpstream checks the base URL and the endpoint map.
+func ValidateUpstream(c UpstreamConfig) error {
+	if _, err := parseUpstreamBaseURL(strings.TrimSpace(c.BaseURL)); err != nil {
+		return fmt.Errorf("upstream.base_url: %v", err)
+	}
+	for name, path := range c.Endpoints {
+		if _, ok := DefaultUpstreamEndpoints[name]; !ok {
+			names := slices.Sorted(maps.Keys(DefaultUpstreamEndpoints))
+			return fmt.Errorf("upstream.endpoints has unknown endpoint %q, expected one of %s", name, strings.Join(names, ", "))
+		}
+		if !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return fmt.Errorf("upstream.endpoints.%s must be a path starting with /", name)
+		}
+	}
+	return nil
+}
+
+func (c UpstreamConfig) clone() UpstreamConfig {
+	c.Endpoints = maps.Clone(c.Endpoints)
+	return c
+}
@@ -25,7 +25,11 @@ func (c *Client) Login(ctx context.Context, acc config.Account) (string, error)
 	} else {
 		return "", errors.New("missing email/mobile")
 	}
-	resp, err := c.postJSON(ctx, c.regular, DeepSeekLoginURL, BaseHeaders, payload)
+	rt, err := c.routeFor(acc)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.postJSON(ctx, rt.egress, rt.url(config.UpstreamLogin), rt.headers, payload)
 	if err != nil {
 		return "", err
 	}
@@ -53,8 +57,12 @@ func (c *Client) CreateSession(ctx context.Context, a *auth.RequestAuth, maxAtte
 	attempts := 0
 	refreshed := false
 	for attempts < maxAttempts {
-		headers := c.authHeaders(a.DeepSeekToken)
-		resp, status, err := c.postJSONWithStatus(ctx, c.regular, DeepSeekCreateSessionURL, headers, map[string]any{"agent": "chat"})
+		rt, err := c.routeFor(a.Account)
+		if err != nil {
+			return "", err
+		}
+		headers := rt.authHeaders(a.DeepSeekToken)
+		resp, status, err := c.postJSONWithStatus(ctx, rt.egress, rt.url(config.UpstreamCreateSession), headers, map[string]any{"agent": "chat"})
 		if err != nil {
 			config.Logger.Warn("[create_session] request error", "error", err, "account", a.AccountID)
 			attempts++
@@ -95,8 +103,12 @@ func (c *Client) GetPow(ctx context.Context, a *auth.RequestAuth, maxAttempts in
 	}
 	attempts := 0
 	for attempts < maxAttempts {
-		headers := c.authHeaders(a.DeepSeekToken)
-		resp, status, err := c.postJSONWithStatus(ctx, c.regular, DeepSeekCreatePowURL, headers, map[string]any{"target_path": "/api/v0/chat/completion"})
+		rt, err := c.routeFor(a.Account)
+		if err != nil {
+			return "", err
+		}
+		headers := rt.authHeaders(a.DeepSeekToken)
+		resp, status, err := c.postJSONWithStatus(ctx, rt.egress, rt.url(config.UpstreamCreatePow), headers, map[string]any{"target_path": rt.upstream.Path(config.UpstreamCompletion)})
 		if err != nil {
 			config.Logger.Warn("[get_pow] request error", "error", err, "account", a.AccountID)
 			attempts++
@@ -132,15 +144,6 @@ func (c *Client) GetPow(ctx context.Context, a *auth.RequestAuth, maxAttempts in
 	return "", errors.New("get pow failed")
 }
 
-func (c *Client) authHeaders(token string) map[string]string {
-	headers := make(map[string]string, len(BaseHeaders)+1)
-	for k, v := range BaseHeaders {
-		headers[k] = v
-	}
-	headers["authorization"] = "Bearer " + token
-	return headers
-}
-
 func isTokenInvalid(status int, code int, msg string) bool {
 	msg = strings.ToLower(msg)
 	if status == http.StatusUnauthorized || status == http.StatusForbidden {
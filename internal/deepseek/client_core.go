@@ -2,12 +2,10 @@ package deepseek
 
 import (
 	"context"
-	"net/http"
-	"time"
+	"sync"
 
 	"ds2api/internal/auth"
 	"ds2api/internal/config"
-	trans "ds2api/internal/deepseek/transport"
 	"ds2api/internal/devcapture"
 	"ds2api/internal/util"
 )
@@ -19,10 +17,9 @@ type Client struct {
 	Store      *config.Store
 	Auth       *auth.Resolver
 	capture    *devcapture.Store
-	regular    trans.Doer
-	stream     trans.Doer
-	fallback   *http.Client
-	fallbackS  *http.Client
+	egressMu   sync.Mutex
+	egresses   map[string]*egress
+	egressRev  uint64
 	powSolver  *PowSolver
 	maxRetries int
 }
@@ -32,10 +29,7 @@ func NewClient(store *config.Store, resolver *auth.Resolver) *Client {
 		Store:      store,
 		Auth:       resolver,
 		capture:    devcapture.Global(),
-		regular:    trans.New(60 * time.Second),
-		stream:     trans.New(0),
-		fallback:   &http.Client{Timeout: 60 * time.Second},
-		fallbackS:  &http.Client{Timeout: 0},
+		egresses:   map[string]*egress{},
 		powSolver:  NewPowSolver(config.WASMPath()),
 		maxRetries: 3,
 	}
@@ -0,0 +1,147 @@
+package deepseek
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"ds2api/internal/config"
+	trans "ds2api/internal/deepseek/transport"
+)
+
+// egress is the set of HTTP clients that reach DeepSeek over one route:
+// the fingerprinted transports and their standard-library fallbacks.
+type egress struct {
+	regular   trans.Doer
+	stream    trans.Doer
+	fallback  *http.Client
+	fallbackS *http.Client
+}
+
+// route is how one account talks to DeepSeek: its egress, the base
+// headers of its client identity profile and the upstream endpoints.
+type route struct {
+	*egress
+	headers  map[string]string
+	upstream config.Upstream
+}
+
+func newEgress(opts trans.Options) *egress {
+	std := func(timeout time.Duration) *http.Client {
+		if opts.Proxy == nil {
+			return &http.Client{Timeout: timeout}
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = http.ProxyURL(opts.Proxy)
+		return &http.Client{Timeout: timeout, Transport: t}
+	}
+	return &egress{
+		regular:   trans.NewWithOptions(60*time.Second, opts),
+		stream:    trans.NewWithOptions(0, opts),
+		fallback:  std(60 * time.Second),
+		fallbackS: std(0),
+	}
+}
+
+// closeIdle closes the idle connections of every client of the egress;
+// requests in flight finish on theirs.
+func (eg *egress) closeIdle() {
+	for _, d := range []trans.Doer{eg.regular, eg.stream} {
+		if closer, ok := d.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+	eg.fallback.CloseIdleConnections()
+	eg.fallbackS.CloseIdleConnections()
+}
+
+// routeFor resolves the proxy and client identity profile of acc. Egresses
+// are shared by accounts with the same proxy and TLS shape; direct token
+// callers pass the zero Account and get the global profile.
+func (c *Client) routeFor(acc config.Account) (route, error) {
+	proxy, err := acc.ProxyURL()
+	if err != nil {
+		return route{}, err
+	}
+	var identity config.IdentityConfig
+	var revision uint64
+	upstream := config.UpstreamOf(nil)
+	if c.Store != nil {
+		identity = c.Store.IdentityConfig()
+		upstream = c.Store.Upstream()
+		revision = c.Store.Revision()
+	}
+	profile := identity.ProfileFor(acc).ForUpstream(upstream)
+	opts := trans.Options{
+		Proxy:       proxy,
+		ClientHello: strings.ToLower(strings.TrimSpace(profile.ClientHello)),
+		ALPN:        profile.ALPN,
+		HTTP2:       profile.UsesHTTP2(),
+	}
+	headers := profileHeaders(profile)
+	for k := range headers {
+		if strings.EqualFold(k, "Host") {
+			headers[k] = upstream.Host
+		}
+	}
+	return route{egress: c.egressFor(opts, revision), headers: headers, upstream: upstream}, nil
+}
+
+// url is the full URL of the upstream endpoint name.
+func (r route) url(name string) string {
+	return r.upstream.URL(name)
+}
+
+// egressFor returns the egress for opts. The cache only holds egresses of
+// config revision; once the config changes, egresses built for routes that
+// may no longer exist are dropped and their idle connections closed.
+func (c *Client) egressFor(opts trans.Options, revision uint64) *egress {
+	key := opts.ClientHello + "|" + strings.Join(opts.ALPN, ",")
+	if opts.HTTP2 {
+		key += "|h2"
+	}
+	if opts.Proxy != nil {
+		key += "|" + opts.Proxy.String()
+	}
+	c.egressMu.Lock()
+	defer c.egressMu.Unlock()
+	if revision != c.egressRev {
+		for _, eg := range c.egresses {
+			eg.closeIdle()
+		}
+		c.egresses = map[string]*egress{}
+		c.egressRev = revision
+	}
+	if eg, ok := c.egresses[key]; ok {
+		return eg
+	}
+	eg := newEgress(opts)
+	c.egresses[key] = eg
+	return eg
+}
+
+// profileHeaders lays the profile headers over BaseHeaders; empty values
+// remove a base header.
+func profileHeaders(profile config.ClientProfile) map[string]string {
+	headers := cloneStringMap(BaseHeaders)
+	for k, v := range profile.Headers {
+		for base := range headers {
+			if strings.EqualFold(base, k) {
+				delete(headers, base)
+			}
+		}
+		if v != "" {
+			headers[k] = v
+		}
+	}
+	return headers
+}
+
+func (r route) authHeaders(token string) map[string]string {
+	headers := make(map[string]string, len(r.headers)+1)
+	for k, v := range r.headers {
+		headers[k] = v
+	}
+	headers["authorization"] = "Bearer " + token
+	return headers
+}
@@ -0,0 +1,140 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+)
+
+func TestRouteForAppliesProfileHeadersAndSharesEgress(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"identity": {
+			"profile": "chrome_web",
+			"profiles": [{"name": "bare", "client_hello": "chrome", "headers": {"x-client-locale": "", "X-Extra": "1"}}]
+		},
+		"accounts": [
+			{"email": "a@example.com", "password": "p"},
+			{"email": "b@example.com", "password": "p", "profile": "bare"},
+			{"email": "c@example.com", "password": "p", "profile": "bare", "proxy": "socks5://127.0.0.1:1080"}
+		]
+	}`)
+	client := NewClient(config.LoadStore(), nil)
+	a, _ := client.Store.FindAccount("a@example.com")
+	b, _ := client.Store.FindAccount("b@example.com")
+	c, _ := client.Store.FindAccount("c@example.com")
+
+	ra, err := client.routeFor(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.headers["x-client-platform"] != "web" || ra.headers["Host"] != BaseHeaders["Host"] {
+		t.Fatalf("expected chrome_web headers over the base headers, got %#v", ra.headers)
+	}
+	rb, _ := client.routeFor(b)
+	if _, ok := rb.headers["x-client-locale"]; ok || rb.headers["X-Extra"] != "1" {
+		t.Fatalf("expected profile headers to remove and add, got %#v", rb.headers)
+	}
+	if rb.egress != ra.egress {
+		t.Fatal("expected profiles with the same TLS shape to share an egress")
+	}
+	if rc, _ := client.routeFor(c); rc.egress == rb.egress {
+		t.Fatal("expected a proxied account to get its own egress")
+	}
+	if BaseHeaders["x-client-platform"] != "android" {
+		t.Fatal("expected the base headers left untouched")
+	}
+}
+
+func TestRouteForDropsEgressesOfAnOlderConfig(t *testing.T) {
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"accounts": [{"email": "a@example.com", "password": "p", "proxy": "socks5://127.0.0.1:1080"}]
+	}`)
+	client := NewClient(config.LoadStore(), nil)
+	a, _ := client.Store.FindAccount("a@example.com")
+	first, _ := client.routeFor(a)
+	if again, _ := client.routeFor(a); again.egress != first.egress {
+		t.Fatal("expected the egress reused while the config is unchanged")
+	}
+
+	if err := client.Store.Update(func(cfg *config.Config) error {
+		cfg.Accounts[0].Proxy = "socks5://127.0.0.1:1081"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	a, _ = client.Store.FindAccount("a@example.com")
+	if next, _ := client.routeFor(a); next.egress == first.egress {
+		t.Fatal("expected a new egress after the config changed")
+	}
+	if len(client.egresses) != 1 {
+		t.Fatalf("expected the stale egress dropped, got %d cached", len(client.egresses))
+	}
+}
+
+func TestClientFollowsConfiguredUpstream(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]string{}
+	var powBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits[r.URL.Path] = r.Host
+		switch r.URL.Path {
+		case "/mirror/login":
+			_, _ = io.WriteString(w, `{"code":0,"data":{"biz_code":0,"biz_data":{"user":{"token":"tok"}}}}`)
+		case "/api/v0/chat/create_pow_challenge":
+			_ = json.NewDecoder(r.Body).Decode(&powBody)
+			_, _ = io.WriteString(w, `{"code":1,"msg":"mock"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("DS2API_UPSTREAM_COMPLETION_PATH", "/mirror/completion")
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"upstream": {"base_url": "`+srv.URL+`", "endpoints": {"login": "/mirror/login"}},
+		"accounts": [{"email": "a@example.com", "password": "p"}]
+	}`)
+	client := NewClient(config.LoadStore(), nil)
+	acc, _ := client.Store.FindAccount("a@example.com")
+
+	token, err := client.Login(context.Background(), acc)
+	if err != nil || token != "tok" {
+		t.Fatalf("expected login against the mock upstream, got %q %v", token, err)
+	}
+	_, _ = client.GetPow(context.Background(), &auth.RequestAuth{Account: acc, DeepSeekToken: token}, 1)
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/mirror/login"] != host || hits["/api/v0/chat/create_pow_challenge"] != host {
+		t.Fatalf("expected configured paths with the upstream host, got %#v", hits)
+	}
+	if powBody["target_path"] != "/mirror/completion" {
+		t.Fatalf("expected target_path to follow the completion path, got %#v", powBody)
+	}
+	rt, _ := client.routeFor(acc)
+	if rt.headers["Host"] != host {
+		t.Fatalf("expected the Host header to follow the upstream, got %q", rt.headers["Host"])
+	}
+
+	if err := client.Store.Update(func(cfg *config.Config) error {
+		cfg.Identity.Profile = "chrome_web"
+		cfg.Identity.Profiles = []config.ClientProfile{{Name: "chrome_web", ClientHello: "chrome", Headers: map[string]string{"host": "chat.deepseek.com", "Origin": config.DefaultUpstreamBaseURL}}}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rt, _ = client.routeFor(acc)
+	if rt.headers["host"] != host || rt.headers["Origin"] != srv.URL {
+		t.Fatalf("expected a lower-case host header and the Origin to follow the upstream, got %#v", rt.headers)
+	}
+}
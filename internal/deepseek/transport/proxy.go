@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// proxyDialer returns a dialer that reaches addr through an http (CONNECT)
+// or socks5 proxy, dialing the proxy itself with dial.
+func proxyDialer(proxy *url.URL, dial dialFunc) dialFunc {
+	proxyAddr := proxyHostPort(proxy)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, "tcp", proxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("dial proxy %s: %w", proxyAddr, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		} else {
+			_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+		}
+		switch proxy.Scheme {
+		case "socks5", "socks5h":
+			err = socks5Connect(conn, proxy.User, addr)
+		default:
+			err = httpConnect(conn, proxy.User, addr)
+		}
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("proxy %s: %w", proxyAddr, err)
+		}
+		_ = conn.SetDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+func proxyHostPort(proxy *url.URL) string {
+	if port := proxy.Port(); port != "" {
+		return proxy.Host
+	}
+	port := "80"
+	if proxy.Scheme == "socks5" || proxy.Scheme == "socks5h" {
+		port = "1080"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+func httpConnect(conn net.Conn, user *url.Userinfo, addr string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user != nil {
+		password, _ := user.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT failed: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return errors.New("CONNECT response carried unexpected data")
+	}
+	return nil
+}
+
+func socks5Connect(conn net.Conn, user *url.Userinfo, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 0xffff {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return errors.New("socks5: unexpected protocol version")
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if user == nil {
+			return errors.New("socks5: proxy requires authentication")
+		}
+		if err := socks5Auth(conn, user); err != nil {
+			return err
+		}
+	default:
+		return errors.New("socks5: no acceptable authentication method")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, 0x01), ip4...)
+		} else {
+			req = append(append(req, 0x04), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("socks5: host name too long")
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("socks5: connect failed with code %d", head[1])
+	}
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return errors.New("socks5: unknown bound address type")
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+func socks5Auth(conn net.Conn, user *url.Userinfo) error {
+	name := user.Username()
+	password, _ := user.Password()
+	if len(name) > 255 || len(password) > 255 {
+		return errors.New("socks5: credentials too long")
+	}
+	msg := append([]byte{0x01, byte(len(name))}, name...)
+	msg = append(append(msg, byte(len(password))), password...)
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return errors.New("socks5: authentication failed")
+	}
+	return nil
+}
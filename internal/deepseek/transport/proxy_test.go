@@ -0,0 +1,166 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+// startEcho serves a line echo so tunnelled connections can be checked.
+func startEcho(t *testing.T) string {
+	t.Helper()
+	return serve(t, func(conn net.Conn) {
+		_, _ = io.Copy(conn, conn)
+	})
+}
+
+func serve(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func splice(client net.Conn, target string) {
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() { _, _ = io.Copy(upstream, client) }()
+	_, _ = io.Copy(client, upstream)
+}
+
+func assertTunnel(t *testing.T, proxy *url.URL, target string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := proxyDialer(proxy, (&net.Dialer{}).DialContext)(ctx, "tcp", target)
+	if err != nil {
+		t.Fatalf("dial through %s: %v", proxy.Scheme, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("expected echo through the tunnel, got %q %v", line, err)
+	}
+}
+
+func TestProxyDialerHTTPConnect(t *testing.T) {
+	echo := startEcho(t)
+	gotAuth := make(chan string, 1)
+	proxyAddr := serve(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		gotAuth <- req.Header.Get("Proxy-Authorization")
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		splice(conn, req.Host)
+	})
+	assertTunnel(t, &url.URL{Scheme: "http", Host: proxyAddr, User: url.UserPassword("u", "p")}, echo)
+	if auth := <-gotAuth; auth != "Basic dTpw" {
+		t.Fatalf("expected basic proxy credentials, got %q", auth)
+	}
+}
+
+func TestProxyDialerSOCKS5WithAuth(t *testing.T) {
+	echo := startEcho(t)
+	proxyAddr := serve(t, func(conn net.Conn) {
+		head := make([]byte, 2)
+		if _, err := io.ReadFull(conn, head); err != nil {
+			return
+		}
+		_, _ = io.ReadFull(conn, make([]byte, head[1]))
+		_, _ = conn.Write([]byte{0x05, 0x02})
+		ulen := make([]byte, 2)
+		_, _ = io.ReadFull(conn, ulen)
+		user := make([]byte, ulen[1])
+		_, _ = io.ReadFull(conn, user)
+		plen := make([]byte, 1)
+		_, _ = io.ReadFull(conn, plen)
+		pass := make([]byte, plen[0])
+		_, _ = io.ReadFull(conn, pass)
+		if string(user) != "u" || string(pass) != "p" {
+			_, _ = conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		_, _ = conn.Write([]byte{0x01, 0x00})
+		req := make([]byte, 4)
+		_, _ = io.ReadFull(conn, req)
+		var host string
+		switch req[3] {
+		case 0x01:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			n := make([]byte, 1)
+			_, _ = io.ReadFull(conn, n)
+			name := make([]byte, n[0])
+			_, _ = io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			return
+		}
+		port := make([]byte, 2)
+		_, _ = io.ReadFull(conn, port)
+		_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		splice(conn, net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	})
+	assertTunnel(t, &url.URL{Scheme: "socks5", Host: proxyAddr, User: url.UserPassword("u", "p")}, echo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bad := &url.URL{Scheme: "socks5", Host: proxyAddr, User: url.UserPassword("u", "wrong")}
+	if _, err := proxyDialer(bad, (&net.Dialer{}).DialContext)(ctx, "tcp", echo); err == nil {
+		t.Fatal("expected wrong socks5 credentials to fail")
+	}
+}
+
+func TestProxyHostPortDefaults(t *testing.T) {
+	if got := proxyHostPort(&url.URL{Scheme: "socks5", Host: "proxy.example"}); got != "proxy.example:1080" {
+		t.Fatalf("unexpected socks5 default %q", got)
+	}
+	if got := proxyHostPort(&url.URL{Scheme: "http", Host: "proxy.example"}); got != "proxy.example:80" {
+		t.Fatalf("unexpected http default %q", got)
+	}
+}
+
+func TestClientHelloCoversConfiguredNames(t *testing.T) {
+	for _, name := range config.ClientHellos {
+		if name == "golang" {
+			continue
+		}
+		if name != "safari" && ClientHello(name) == ClientHello("safari") {
+			t.Fatalf("client hello %q falls back to safari", name)
+		}
+	}
+}
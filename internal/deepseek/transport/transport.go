@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 
 	utls "github.com/refraction-networking/utls"
@@ -19,35 +20,101 @@ type Client struct {
 	http *http.Client
 }
 
+// Options select the route and the client identity of a Client.
+type Options struct {
+	// Proxy tunnels connections through an http or socks5 proxy; nil uses
+	// the environment proxy settings.
+	Proxy *url.URL
+	// ClientHello names the TLS fingerprint (see ClientHello); empty means
+	// Safari. "golang" uses the standard library handshake.
+	ClientHello string
+	// ALPN lists the protocols offered in the ClientHello; empty offers
+	// only http/1.1.
+	ALPN []string
+	// HTTP2 lets the standard library handshake negotiate HTTP/2.
+	HTTP2 bool
+}
+
 func New(timeout time.Duration) *Client {
+	return NewWithOptions(timeout, Options{})
+}
+
+// NewWithOptions returns a client for opts. Proxied connections open the
+// tunnel before the uTLS handshake, so the fingerprint is unchanged.
+func NewWithOptions(timeout time.Duration, opts Options) *Client {
+	dial := (&net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	alpn := opts.ALPN
+	if len(alpn) == 0 {
+		alpn = []string{"http/1.1"}
+	}
 	base := &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		ForceAttemptHTTP2:   false,
 		MaxIdleConns:        200,
 		MaxIdleConnsPerHost: 100,
 		IdleConnTimeout:     90 * time.Second,
-		DialContext:         (&net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
-		DialTLSContext:      safariTLSDialer(),
+		DialContext:         dial,
 		TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12},
 	}
+	if opts.ClientHello == "golang" {
+		if opts.Proxy != nil {
+			base.Proxy = http.ProxyURL(opts.Proxy)
+		}
+		base.ForceAttemptHTTP2 = opts.HTTP2
+		if !opts.HTTP2 {
+			base.TLSClientConfig.NextProtos = alpn
+		}
+		return &Client{http: &http.Client{Timeout: timeout, Transport: base}}
+	}
+	if opts.Proxy != nil {
+		// The transport hands custom TLS dialers the proxy address, so the
+		// tunnel is opened by the dialer itself instead.
+		base.Proxy = nil
+		dial = proxyDialer(opts.Proxy, dial)
+		base.DialContext = dial
+	}
+	base.DialTLSContext = uTLSDialer(dial, ClientHello(opts.ClientHello), alpn)
 	return &Client{http: &http.Client{Timeout: timeout, Transport: base}}
 }
 
+// ClientHello maps a fingerprint name to its uTLS ClientHello, falling
+// back to Safari for unknown names.
+func ClientHello(name string) utls.ClientHelloID {
+	switch name {
+	case "chrome":
+		return utls.HelloChrome_Auto
+	case "firefox":
+		return utls.HelloFirefox_Auto
+	case "edge":
+		return utls.HelloEdge_Auto
+	case "ios":
+		return utls.HelloIOS_Auto
+	case "android_okhttp":
+		return utls.HelloAndroid_11_OkHttp
+	default:
+		return utls.HelloSafari_Auto
+	}
+}
+
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return c.http.Do(req)
 }
 
-func safariTLSDialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
-	var dialer net.Dialer
+// CloseIdleConnections closes the idle pooled connections of the client.
+func (c *Client) CloseIdleConnections() {
+	c.http.CloseIdleConnections()
+}
+
+func uTLSDialer(dial dialFunc, hello utls.ClientHelloID, alpn []string) dialFunc {
 	return func(ctx context.Context, network, addr string) (net.Conn, error) {
-		plainConn, err := dialer.DialContext(ctx, network, addr)
+		plainConn, err := dial(ctx, network, addr)
 		if err != nil {
 			return nil, err
 		}
 		host, _, _ := net.SplitHostPort(addr)
 		uCfg := &utls.Config{ServerName: host}
-		uConn := utls.UClient(plainConn, uCfg, utls.HelloSafari_Auto)
-		if err := forceHTTP11ALPN(uConn); err != nil {
+		uConn := utls.UClient(plainConn, uCfg, hello)
+		if err := setALPN(uConn, alpn); err != nil {
 			_ = plainConn.Close()
 			return nil, err
 		}
@@ -64,7 +131,10 @@ func safariTLSDialer() func(ctx context.Context, network, addr string) (net.Conn
 	}
 }
 
-func forceHTTP11ALPN(uConn *utls.UConn) error {
+// setALPN replaces the protocols the fingerprint offers. Only HTTP/1.1 can
+// be spoken over a uTLS connection, so any other negotiated protocol fails
+// the dial.
+func setALPN(uConn *utls.UConn, alpn []string) error {
 	if err := uConn.BuildHandshakeState(); err != nil {
 		return err
 	}
@@ -73,7 +143,7 @@ func forceHTTP11ALPN(uConn *utls.UConn) error {
 		if !ok {
 			continue
 		}
-		alpnExt.AlpnProtocols = []string{"http/1.1"}
+		alpnExt.AlpnProtocols = alpn
 		return nil
 	}
 	return nil
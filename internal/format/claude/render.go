@@ -8,15 +8,22 @@ import (
 )
 
 func BuildMessageResponse(messageID, model string, normalizedMessages []any, finalThinking, finalText string, toolNames []string) map[string]any {
-	detected := util.ParseToolCalls(finalText, toolNames)
+	return BuildMessageResponseWithStop(messageID, model, normalizedMessages, finalThinking, finalText, toolNames, util.ToolCallReader{}, "end_turn", "")
+}
+
+// BuildMessageResponseWithStop is BuildMessageResponse for a message that
+// ended for a reason other than end_turn, with tool calls read by
+// tools. Detected tool calls still report tool_use; stopSequence is only
+// echoed with stop_reason "stop_sequence".
+func BuildMessageResponseWithStop(messageID, model string, normalizedMessages []any, finalThinking, finalText string, toolNames []string, tools util.ToolCallReader, stopReason, stopSequence string) map[string]any {
+	detected := tools.Parse(finalText, toolNames)
 	if len(detected) == 0 && finalText == "" && finalThinking != "" {
-		detected = util.ParseToolCalls(finalThinking, toolNames)
+		detected = tools.Parse(finalThinking, toolNames)
 	}
 	content := make([]map[string]any, 0, 4)
 	if finalThinking != "" {
 		content = append(content, map[string]any{"type": "thinking", "thinking": finalThinking})
 	}
-	stopReason := "end_turn"
 	if len(detected) > 0 {
 		stopReason = "tool_use"
 		for i, tc := range detected {
@@ -28,11 +35,15 @@ func BuildMessageResponse(messageID, model string, normalizedMessages []any, fin
 			})
 		}
 	} else {
-		if finalText == "" {
+		if finalText == "" && stopReason == "end_turn" {
 			finalText = "抱歉，没有生成有效的响应内容。"
 		}
 		content = append(content, map[string]any{"type": "text", "text": finalText})
 	}
+	var stopSeq any
+	if stopReason == "stop_sequence" && stopSequence != "" {
+		stopSeq = stopSequence
+	}
 	return map[string]any{
 		"id":            messageID,
 		"type":          "message",
@@ -40,10 +51,39 @@ func BuildMessageResponse(messageID, model string, normalizedMessages []any, fin
 		"model":         model,
 		"content":       content,
 		"stop_reason":   stopReason,
-		"stop_sequence": nil,
+		"stop_sequence": stopSeq,
 		"usage": map[string]any{
 			"input_tokens":  util.EstimateTokens(fmt.Sprintf("%v", normalizedMessages)),
 			"output_tokens": util.EstimateTokens(finalThinking) + util.EstimateTokens(finalText),
 		},
 	}
 }
+
+// ApplyReasoningMode presents the thinking block of a message built by
+// BuildMessageResponseWithStop per mode: kept, summarised, dropped or turned
+// into a leading <think> text block. Usage stays as counted.
+func ApplyReasoningMode(message map[string]any, mode util.ReasoningMode) {
+	content, _ := message["content"].([]map[string]any)
+	if len(content) == 0 || content[0]["type"] != "thinking" {
+		return
+	}
+	thinking, _ := content[0]["thinking"].(string)
+	reasoning, inline := util.PresentReasoning(mode, thinking, "")
+	switch {
+	case reasoning != "":
+		content[0]["thinking"] = reasoning
+	case inline != "":
+		content[0] = map[string]any{"type": "text", "text": inline}
+	default:
+		content = content[1:]
+	}
+	message["content"] = content
+}
+
+// MarkCachedInput reports the input tokens of usage as read from the cache,
+// for responses replayed from the response cache.
+func MarkCachedInput(usage map[string]any) map[string]any {
+	usage["cache_read_input_tokens"] = usage["input_tokens"]
+	usage["input_tokens"] = 0
+	return usage
+}
@@ -0,0 +1,70 @@
+package claude
+
+import (
+	"strconv"
+
+	"ds2api/internal/sse"
+)
+
+// BuildWebSearchCitation renders a search citation the way Anthropic reports
+// web_search_result_location citations. cited_text is the source snippet when
+// DeepSeek returned one, otherwise the cited span of the answer.
+func BuildWebSearchCitation(text string, c sse.Citation) map[string]any {
+	cited := c.Result.Snippet
+	if cited == "" && c.Start <= c.End && c.End <= len(text) {
+		cited = text[c.Start:c.End]
+	}
+	return map[string]any{
+		"type":            "web_search_result_location",
+		"url":             c.Result.URL,
+		"title":           c.Result.Title,
+		"cited_text":      cited,
+		"encrypted_index": strconv.Itoa(c.Result.Index),
+	}
+}
+
+// SplitCitedText splits text into text blocks so every cited span becomes its
+// own block carrying its citations. Without citations it is a single block.
+func SplitCitedText(text string, citations []sse.Citation) []map[string]any {
+	blocks := make([]map[string]any, 0, 2*len(citations)+1)
+	pos := 0
+	for i := 0; i < len(citations); {
+		start, end := citations[i].Start, citations[i].End
+		if start < pos || end > len(text) || start >= end {
+			i++
+			continue
+		}
+		group := make([]any, 0, 1)
+		for ; i < len(citations) && citations[i].Start == start && citations[i].End == end; i++ {
+			group = append(group, BuildWebSearchCitation(text, citations[i]))
+		}
+		if start > pos {
+			blocks = append(blocks, map[string]any{"type": "text", "text": text[pos:start]})
+		}
+		blocks = append(blocks, map[string]any{"type": "text", "text": text[start:end], "citations": group})
+		pos = end
+	}
+	if pos < len(text) || len(blocks) == 0 {
+		blocks = append(blocks, map[string]any{"type": "text", "text": text[pos:]})
+	}
+	return blocks
+}
+
+// AddMessageCitations replaces the text block of a message built by
+// BuildMessageResponseWithStop with cited blocks.
+func AddMessageCitations(message map[string]any, citations []sse.Citation) {
+	if len(citations) == 0 {
+		return
+	}
+	content, _ := message["content"].([]map[string]any)
+	out := make([]map[string]any, 0, len(content)+2*len(citations))
+	for _, block := range content {
+		text, _ := block["text"].(string)
+		if block["type"] != "text" {
+			out = append(out, block)
+			continue
+		}
+		out = append(out, SplitCitedText(text, citations)...)
+	}
+	message["content"] = out
+}
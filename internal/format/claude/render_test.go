@@ -1,6 +1,11 @@
 package claude
 
-import "testing"
+import (
+	"testing"
+
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
 
 func TestBuildMessageResponseDetectsToolCallsFromThinkingFallback(t *testing.T) {
 	resp := BuildMessageResponse(
@@ -60,3 +65,59 @@ func TestBuildMessageResponseSkipsThinkingFallbackWhenFinalTextExists(t *testing
 		t.Fatalf("unexpected tool_use block when finalText exists, got=%#v", resp["content"])
 	}
 }
+
+func TestBuildMessageResponseWithStopEchoesStopSequence(t *testing.T) {
+	resp := BuildMessageResponseWithStop("msg_1", "claude-sonnet-4-5", []any{}, "", "partial", nil, util.ToolCallReader{}, "stop_sequence", "###")
+	if resp["stop_reason"] != "stop_sequence" || resp["stop_sequence"] != "###" {
+		t.Fatalf("expected stop_sequence echoed, got %#v / %#v", resp["stop_reason"], resp["stop_sequence"])
+	}
+	resp = BuildMessageResponse("msg_2", "claude-sonnet-4-5", []any{}, "", "done", nil)
+	if resp["stop_reason"] != "end_turn" || resp["stop_sequence"] != nil {
+		t.Fatalf("expected end_turn without stop_sequence, got %#v / %#v", resp["stop_reason"], resp["stop_sequence"])
+	}
+}
+
+func TestSplitCitedTextSeparatesCitedSpans(t *testing.T) {
+	text := "intro\nsky is blue."
+	cites := []sse.Citation{
+		{Result: sse.SearchResult{Index: 1, URL: "https://a.example"}, Start: 6, End: 17},
+		{Result: sse.SearchResult{Index: 2, URL: "https://b.example"}, Start: 6, End: 17},
+	}
+	blocks := SplitCitedText(text, cites)
+	if len(blocks) != 3 {
+		t.Fatalf("expected three blocks, got %#v", blocks)
+	}
+	if blocks[0]["text"] != "intro\n" || blocks[1]["text"] != "sky is blue" || blocks[2]["text"] != "." {
+		t.Fatalf("unexpected split %#v", blocks)
+	}
+	if got, _ := blocks[1]["citations"].([]any); len(got) != 2 {
+		t.Fatalf("expected both citations on the cited block, got %#v", blocks[1])
+	}
+}
+
+func TestApplyReasoningModeRewritesThinkingBlock(t *testing.T) {
+	build := func() map[string]any {
+		return BuildMessageResponse("msg_1", "claude-sonnet-4-5", nil, "想一想\n得4", "答案4", nil)
+	}
+
+	inline := build()
+	ApplyReasoningMode(inline, util.ReasoningInlineThinkTags)
+	content, _ := inline["content"].([]map[string]any)
+	if len(content) != 2 || content[0]["type"] != "text" || content[0]["text"] != "<think>想一想\n得4</think>\n\n" {
+		t.Fatalf("expected inline think text block, got %#v", content)
+	}
+
+	hidden := build()
+	ApplyReasoningMode(hidden, util.ReasoningHidden)
+	content, _ = hidden["content"].([]map[string]any)
+	if len(content) != 1 || content[0]["text"] != "答案4" {
+		t.Fatalf("expected thinking block dropped, got %#v", content)
+	}
+
+	summary := build()
+	ApplyReasoningMode(summary, util.ReasoningSummaryOnly)
+	content, _ = summary["content"].([]map[string]any)
+	if content[0]["type"] != "thinking" || content[0]["thinking"] != "得4" {
+		t.Fatalf("expected summarized thinking block, got %#v", content[0])
+	}
+}
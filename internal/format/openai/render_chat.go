@@ -8,25 +8,65 @@ import (
 )
 
 func BuildChatCompletion(completionID, model, finalPrompt, finalThinking, finalText string, toolNames []string) map[string]any {
-	detected := util.ParseToolCalls(finalText, toolNames)
-	finishReason := "stop"
-	messageObj := map[string]any{"role": "assistant", "content": finalText}
-	if strings.TrimSpace(finalThinking) != "" {
-		messageObj["reasoning_content"] = finalThinking
+	return BuildChatCompletionFromChoices(
+		completionID,
+		model,
+		[]map[string]any{BuildChatChoice(0, finalThinking, finalText, toolNames)},
+		BuildChatUsage(finalPrompt, finalThinking, finalText),
+	)
+}
+
+func BuildChatChoice(index int, finalThinking, finalText string, toolNames []string) map[string]any {
+	return BuildChatChoiceWithReason(index, finalThinking, finalText, toolNames, "stop")
+}
+
+// BuildChatChoiceWithReason is BuildChatChoice for output that ended for a
+// reason other than a natural stop, such as "length". Detected tool calls
+// still report "tool_calls".
+func BuildChatChoiceWithReason(index int, finalThinking, finalText string, toolNames []string, finishReason string) map[string]any {
+	return BuildChatChoiceWithReasoning(index, finalThinking, finalText, toolNames, util.ToolCallReader{}, finishReason, util.ReasoningSeparate)
+}
+
+// BuildChatChoiceWithReasoning is BuildChatChoiceWithReason with reasoning
+// presented per mode and tool calls read by tools. Inline reasoning
+// stays as content ahead of tool calls, as it does when streamed.
+func BuildChatChoiceWithReasoning(index int, finalThinking, finalText string, toolNames []string, tools util.ToolCallReader, finishReason string, mode util.ReasoningMode) map[string]any {
+	detected := tools.Parse(finalText, toolNames)
+	reasoning, content := util.PresentReasoning(mode, finalThinking, finalText)
+	messageObj := map[string]any{"role": "assistant", "content": content}
+	if strings.TrimSpace(reasoning) != "" {
+		messageObj["reasoning_content"] = reasoning
 	}
 	if len(detected) > 0 {
 		finishReason = "tool_calls"
 		messageObj["tool_calls"] = util.FormatOpenAIToolCalls(detected)
 		messageObj["content"] = nil
+		if prefix := strings.TrimSuffix(content, finalText); prefix != "" {
+			messageObj["content"] = prefix
+		}
 	}
+	return map[string]any{"index": index, "message": messageObj, "finish_reason": finishReason}
+}
 
+// BuildChatFailedChoice reports a choice whose upstream completion failed while
+// other choices of the same n > 1 request succeeded.
+func BuildChatFailedChoice(index int, message string) map[string]any {
+	return map[string]any{
+		"index":         index,
+		"message":       map[string]any{"role": "assistant", "content": nil},
+		"finish_reason": "error",
+		"error":         map[string]any{"message": message, "type": "api_error"},
+	}
+}
+
+func BuildChatCompletionFromChoices(completionID, model string, choices []map[string]any, usage map[string]any) map[string]any {
 	return map[string]any{
 		"id":      completionID,
 		"object":  "chat.completion",
 		"created": time.Now().Unix(),
 		"model":   model,
-		"choices": []map[string]any{{"index": 0, "message": messageObj, "finish_reason": finishReason}},
-		"usage":   BuildChatUsage(finalPrompt, finalThinking, finalText),
+		"choices": choices,
+		"usage":   usage,
 	}
 }
 
@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"unicode/utf8"
+
+	"ds2api/internal/sse"
+)
+
+// BuildChatURLCitations renders search citations as chat completion
+// url_citation annotations. text is the content the citation offsets refer
+// to; OpenAI counts characters, not bytes.
+func BuildChatURLCitations(text string, citations []sse.Citation) []any {
+	out := make([]any, 0, len(citations))
+	for _, c := range citations {
+		start, end := citationCharRange(text, c)
+		out = append(out, map[string]any{
+			"type": "url_citation",
+			"url_citation": map[string]any{
+				"url":         c.Result.URL,
+				"title":       c.Result.Title,
+				"start_index": start,
+				"end_index":   end,
+			},
+		})
+	}
+	return out
+}
+
+// BuildResponsesURLCitations renders search citations as Responses
+// output_text annotations.
+func BuildResponsesURLCitations(text string, citations []sse.Citation) []any {
+	out := make([]any, 0, len(citations))
+	for _, c := range citations {
+		start, end := citationCharRange(text, c)
+		out = append(out, map[string]any{
+			"type":        "url_citation",
+			"url":         c.Result.URL,
+			"title":       c.Result.Title,
+			"start_index": start,
+			"end_index":   end,
+		})
+	}
+	return out
+}
+
+// AddChatAnnotations attaches url_citation annotations to a chat choice built
+// from text. Choices that ended up as tool calls carry no content to cite.
+func AddChatAnnotations(choice map[string]any, text string, citations []sse.Citation) {
+	message, _ := choice["message"].(map[string]any)
+	if len(citations) == 0 || message == nil || message["content"] == nil {
+		return
+	}
+	message["annotations"] = BuildChatURLCitations(text, citations)
+}
+
+// AddResponsesAnnotations attaches annotations to the output_text part of the
+// response's message item.
+func AddResponsesAnnotations(response map[string]any, annotations []any) {
+	if len(annotations) == 0 {
+		return
+	}
+	output, _ := response["output"].([]any)
+	for _, item := range output {
+		m, _ := item.(map[string]any)
+		if m == nil || m["type"] != "message" {
+			continue
+		}
+		switch content := m["content"].(type) {
+		case []any:
+			for _, part := range content {
+				if p, _ := part.(map[string]any); p != nil && p["type"] == "output_text" {
+					p["annotations"] = annotations
+				}
+			}
+		case []map[string]any:
+			for _, p := range content {
+				if p["type"] == "output_text" {
+					p["annotations"] = annotations
+				}
+			}
+		}
+	}
+}
+
+func citationCharRange(text string, c sse.Citation) (int, int) {
+	start, end := min(c.Start, len(text)), min(c.End, len(text))
+	return utf8.RuneCountInString(text[:start]), utf8.RuneCountInString(text[:end])
+}
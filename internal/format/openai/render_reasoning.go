@@ -0,0 +1,58 @@
+package openai
+
+// BuildResponsesReasoningItem renders reasoning as a Responses reasoning output
+// item whose summary carries the thinking text.
+func BuildResponsesReasoningItem(itemID, text, status string) map[string]any {
+	summary := []any{}
+	if text != "" {
+		summary = append(summary, BuildResponsesReasoningSummaryPart(text))
+	}
+	item := map[string]any{
+		"id":      itemID,
+		"type":    "reasoning",
+		"summary": summary,
+	}
+	if status != "" {
+		item["status"] = status
+	}
+	return item
+}
+
+func BuildResponsesReasoningSummaryPart(text string) map[string]any {
+	return map[string]any{"type": "summary_text", "text": text}
+}
+
+func BuildResponsesReasoningSummaryPartAddedPayload(responseID, itemID string, outputIndex, summaryIndex int, part map[string]any) map[string]any {
+	payload := reasoningSummaryPayload("response.reasoning_summary_part.added", responseID, itemID, outputIndex, summaryIndex)
+	payload["part"] = part
+	return payload
+}
+
+func BuildResponsesReasoningSummaryPartDonePayload(responseID, itemID string, outputIndex, summaryIndex int, part map[string]any) map[string]any {
+	payload := reasoningSummaryPayload("response.reasoning_summary_part.done", responseID, itemID, outputIndex, summaryIndex)
+	payload["part"] = part
+	return payload
+}
+
+func BuildResponsesReasoningSummaryTextDeltaPayload(responseID, itemID string, outputIndex, summaryIndex int, delta string) map[string]any {
+	payload := reasoningSummaryPayload("response.reasoning_summary_text.delta", responseID, itemID, outputIndex, summaryIndex)
+	payload["delta"] = delta
+	return payload
+}
+
+func BuildResponsesReasoningSummaryTextDonePayload(responseID, itemID string, outputIndex, summaryIndex int, text string) map[string]any {
+	payload := reasoningSummaryPayload("response.reasoning_summary_text.done", responseID, itemID, outputIndex, summaryIndex)
+	payload["text"] = text
+	return payload
+}
+
+func reasoningSummaryPayload(eventType, responseID, itemID string, outputIndex, summaryIndex int) map[string]any {
+	return map[string]any{
+		"type":          eventType,
+		"id":            responseID,
+		"response_id":   responseID,
+		"item_id":       itemID,
+		"output_index":  outputIndex,
+		"summary_index": summaryIndex,
+	}
+}
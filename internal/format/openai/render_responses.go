@@ -11,40 +11,43 @@ import (
 )
 
 func BuildResponseObject(responseID, model, finalPrompt, finalThinking, finalText string, toolNames []string) map[string]any {
+	return BuildResponseObjectWithReasoning(responseID, model, finalPrompt, finalThinking, finalText, toolNames, util.ToolCallReader{}, util.ReasoningSeparate)
+}
+
+// BuildResponseObjectWithReasoning is BuildResponseObject with reasoning
+// presented per mode and tool calls read by tools. Usage still
+// counts the full reasoning.
+func BuildResponseObjectWithReasoning(responseID, model, finalPrompt, finalThinking, finalText string, toolNames []string, tools util.ToolCallReader, mode util.ReasoningMode) map[string]any {
 	// Align responses tool-call semantics with chat/completions:
 	// mixed prose + tool_call payloads should still be interpreted as tool calls.
-	detected := util.ParseToolCalls(finalText, toolNames)
+	detected := tools.Parse(finalText, toolNames)
+	callsFromThinking := false
 	if len(detected) == 0 && strings.TrimSpace(finalThinking) != "" {
-		detected = util.ParseToolCalls(finalThinking, toolNames)
+		detected = tools.Parse(finalThinking, toolNames)
+		callsFromThinking = len(detected) > 0
 	}
-	exposedOutputText := finalText
+	shownThinking, shownText := finalThinking, finalText
+	if !callsFromThinking {
+		shownThinking, shownText = util.PresentReasoning(mode, finalThinking, finalText)
+	}
+	exposedOutputText := shownText
 	output := make([]any, 0, 2)
+	// Thinking that is itself the tool call payload is not repeated as reasoning.
+	if shownThinking != "" && !callsFromThinking {
+		output = append(output, BuildResponsesReasoningItem("rs_"+strings.ReplaceAll(uuid.NewString(), "-", ""), shownThinking, ""))
+	}
 	if len(detected) > 0 {
 		exposedOutputText = ""
-		output = append(output, toResponsesFunctionCallItems(detected)...)
-	} else {
-		content := make([]any, 0, 2)
-		if finalThinking != "" {
-			content = append([]any{map[string]any{
-				"type": "reasoning",
-				"text": finalThinking,
-			}}, content...)
-		}
-		if strings.TrimSpace(finalText) != "" {
-			content = append(content, map[string]any{
-				"type": "output_text",
-				"text": finalText,
-			})
-		}
-		if strings.TrimSpace(finalText) == "" && strings.TrimSpace(finalThinking) != "" {
-			exposedOutputText = finalThinking
+		// Inline reasoning stays ahead of the calls, as it does when streamed.
+		if prefix := strings.TrimSuffix(shownText, finalText); prefix != "" {
+			output = append(output, buildResponsesMessageItem(prefix))
+			exposedOutputText = prefix
 		}
-		output = append(output, map[string]any{
-			"type":    "message",
-			"id":      "msg_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
-			"role":    "assistant",
-			"content": content,
-		})
+		output = append(output, toResponsesFunctionCallItems(detected)...)
+	} else if strings.TrimSpace(shownText) != "" || shownThinking == "" {
+		output = append(output, buildResponsesMessageItem(shownText))
+	} else if strings.TrimSpace(shownThinking) != "" {
+		exposedOutputText = shownThinking
 	}
 	return BuildResponseObjectFromItems(
 		responseID,
@@ -57,6 +60,22 @@ func BuildResponseObject(responseID, model, finalPrompt, finalThinking, finalTex
 	)
 }
 
+func buildResponsesMessageItem(text string) map[string]any {
+	content := make([]any, 0, 1)
+	if strings.TrimSpace(text) != "" {
+		content = append(content, map[string]any{
+			"type": "output_text",
+			"text": text,
+		})
+	}
+	return map[string]any{
+		"type":    "message",
+		"id":      "msg_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		"role":    "assistant",
+		"content": content,
+	}
+}
+
 func BuildResponseObjectFromItems(responseID, model, finalPrompt, finalThinking, finalText string, output []any, outputText string) map[string]any {
 	if output == nil {
 		output = []any{}
@@ -74,6 +93,38 @@ func BuildResponseObjectFromItems(responseID, model, finalPrompt, finalThinking,
 	}
 }
 
+// BuildResponseInProgressObject is the placeholder stored for a response that
+// is still being generated, e.g. one started with background: true.
+func BuildResponseInProgressObject(responseID, model string) map[string]any {
+	return map[string]any{
+		"id":          responseID,
+		"type":        "response",
+		"object":      "response",
+		"created_at":  time.Now().Unix(),
+		"status":      "in_progress",
+		"model":       model,
+		"output":      []any{},
+		"output_text": "",
+	}
+}
+
+func BuildResponseFailedObject(responseID, model, message, code string) map[string]any {
+	failed := BuildResponsesFailedPayload(responseID, model, message, code)
+	delete(failed, "response_id")
+	failed["type"] = "response"
+	failed["created_at"] = time.Now().Unix()
+	failed["output"] = []any{}
+	failed["output_text"] = ""
+	return failed
+}
+
+// MarkResponseIncomplete flags a response whose output was cut short, e.g. by
+// max_output_tokens.
+func MarkResponseIncomplete(response map[string]any, reason string) {
+	response["status"] = "incomplete"
+	response["incomplete_details"] = map[string]any{"reason": reason}
+}
+
 func toResponsesFunctionCallItems(toolCalls []util.ParsedToolCall) []any {
 	if len(toolCalls) == 0 {
 		return nil
@@ -71,6 +71,19 @@ func BuildResponsesTextDeltaPayload(responseID, itemID string, outputIndex, cont
 	}
 }
 
+func BuildResponsesAnnotationAddedPayload(responseID, itemID string, outputIndex, contentIndex, annotationIndex int, annotation any) map[string]any {
+	return map[string]any{
+		"type":             "response.output_text.annotation.added",
+		"id":               responseID,
+		"response_id":      responseID,
+		"item_id":          itemID,
+		"output_index":     outputIndex,
+		"content_index":    contentIndex,
+		"annotation_index": annotationIndex,
+		"annotation":       annotation,
+	}
+}
+
 func BuildResponsesReasoningDeltaPayload(responseID, delta string) map[string]any {
 	return map[string]any{
 		"type":        "response.reasoning.delta",
@@ -134,3 +147,12 @@ func BuildResponsesCompletedPayload(response map[string]any) map[string]any {
 		"response":    response,
 	}
 }
+
+func BuildResponsesIncompletePayload(response map[string]any) map[string]any {
+	responseID, _ := response["id"].(string)
+	return map[string]any{
+		"type":        "response.incomplete",
+		"response_id": responseID,
+		"response":    response,
+	}
+}
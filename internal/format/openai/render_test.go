@@ -114,16 +114,33 @@ func TestBuildResponseObjectReasoningOnlyFallsBackToOutputText(t *testing.T) {
 		t.Fatalf("expected one output item, got %#v", obj["output"])
 	}
 	first, _ := output[0].(map[string]any)
-	if first["type"] != "message" {
-		t.Fatalf("expected output type message, got %#v", first["type"])
+	if first["type"] != "reasoning" {
+		t.Fatalf("expected output type reasoning, got %#v", first["type"])
+	}
+	summary, _ := first["summary"].([]any)
+	if len(summary) != 1 {
+		t.Fatalf("expected one summary part, got %#v", first["summary"])
+	}
+	part0, _ := summary[0].(map[string]any)
+	if part0["type"] != "summary_text" || part0["text"] != "internal thinking content" {
+		t.Fatalf("unexpected summary part %#v", part0)
+	}
+}
+
+func TestBuildResponseObjectPutsReasoningItemBeforeMessage(t *testing.T) {
+	obj := BuildResponseObject("resp_test", "gpt-4o", "prompt", "thought", "answer", nil)
+	output, _ := obj["output"].([]any)
+	if len(output) != 2 {
+		t.Fatalf("expected reasoning and message items, got %#v", output)
 	}
-	content, _ := first["content"].([]any)
-	if len(content) == 0 {
-		t.Fatalf("expected reasoning content, got %#v", first["content"])
+	first, _ := output[0].(map[string]any)
+	second, _ := output[1].(map[string]any)
+	if first["type"] != "reasoning" || second["type"] != "message" {
+		t.Fatalf("unexpected output order %#v", output)
 	}
-	block0, _ := content[0].(map[string]any)
-	if block0["type"] != "reasoning" {
-		t.Fatalf("expected first content block reasoning, got %#v", block0["type"])
+	content, _ := second["content"].([]any)
+	if len(content) != 1 {
+		t.Fatalf("expected only output_text in message, got %#v", content)
 	}
 }
 
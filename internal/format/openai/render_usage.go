@@ -16,6 +16,32 @@ func BuildChatUsage(finalPrompt, finalThinking, finalText string) map[string]any
 	}
 }
 
+// ChoiceOutput is the collected thinking and text of one completion choice.
+type ChoiceOutput struct {
+	Thinking string
+	Text     string
+}
+
+// BuildChatUsageForChoices counts the prompt once and sums the output of
+// every choice, matching how n > 1 requests are billed.
+func BuildChatUsageForChoices(finalPrompt string, outputs []ChoiceOutput) map[string]any {
+	promptTokens := util.EstimateTokens(finalPrompt)
+	reasoningTokens := 0
+	completionTokens := 0
+	for _, out := range outputs {
+		reasoningTokens += util.EstimateTokens(out.Thinking)
+		completionTokens += util.EstimateTokens(out.Text)
+	}
+	return map[string]any{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": reasoningTokens + completionTokens,
+		"total_tokens":      promptTokens + reasoningTokens + completionTokens,
+		"completion_tokens_details": map[string]any{
+			"reasoning_tokens": reasoningTokens,
+		},
+	}
+}
+
 func BuildResponsesUsage(finalPrompt, finalThinking, finalText string) map[string]any {
 	promptTokens := util.EstimateTokens(finalPrompt)
 	reasoningTokens := util.EstimateTokens(finalThinking)
@@ -26,3 +52,16 @@ func BuildResponsesUsage(finalPrompt, finalThinking, finalText string) map[strin
 		"total_tokens":  promptTokens + reasoningTokens + completionTokens,
 	}
 }
+
+// MarkCachedPrompt reports the whole prompt of a Chat Completions or
+// Responses usage object as cached tokens, for responses replayed from the
+// response cache.
+func MarkCachedPrompt(usage map[string]any) map[string]any {
+	if n, ok := usage["prompt_tokens"]; ok {
+		usage["prompt_tokens_details"] = map[string]any{"cached_tokens": n}
+	}
+	if n, ok := usage["input_tokens"]; ok {
+		usage["input_tokens_details"] = map[string]any{"cached_tokens": n}
+	}
+	return usage
+}
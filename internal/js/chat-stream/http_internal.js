@@ -9,7 +9,7 @@ function setCorsHeaders(res) {
   res.setHeader('Access-Control-Allow-Methods', 'GET, POST, OPTIONS, PUT, DELETE');
   res.setHeader(
     'Access-Control-Allow-Headers',
-    'Content-Type, Authorization, X-API-Key, X-Ds2-Target-Account, X-Vercel-Protection-Bypass',
+    'Content-Type, Authorization, X-API-Key, X-Ds2-Target-Account, X-Ds2-Session, X-Vercel-Protection-Bypass',
   );
 }
 
@@ -115,6 +115,7 @@ function buildInternalGoHeaders(req, opts = {}) {
     authorization: asString(header(req, 'authorization')),
     'x-api-key': asString(header(req, 'x-api-key')),
     'x-ds2-target-account': asString(header(req, 'x-ds2-target-account')),
+    'x-ds2-session': asString(header(req, 'x-ds2-session')),
     'x-vercel-protection-bypass': resolveProtectionBypass(req),
   };
   if (opts.withInternalToken) {
@@ -58,7 +58,8 @@ async function handler(req, res) {
   }
 
   // Keep all non-stream behavior on Go side to avoid compatibility regressions.
-  if (!toBool(payload.stream)) {
+  // n > 1 fans out to several upstream sessions, which only the Go side does.
+  if (!toBool(payload.stream) || Number(payload.n) > 1) {
     await proxyToGo(req, res, rawBody);
     return;
   }
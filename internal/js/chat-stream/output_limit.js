@@ -0,0 +1,199 @@
+'use strict';
+
+const { CITATION_MARKER, PARTIAL_MARKER } = require('./search_citations');
+
+// Mirrors internal/sse/output_limit.go so the Vercel stream path cuts output
+// at the same place as the Go runtime.
+function createOutputLimiter(raw) {
+  const limits = raw && typeof raw === 'object' ? raw : {};
+  const stops = Array.isArray(limits.stop_sequences)
+    ? limits.stop_sequences.filter((s) => typeof s === 'string' && s !== '')
+    : [];
+  const maxTokens = Number.isInteger(limits.max_tokens) && limits.max_tokens > 0 ? limits.max_tokens : 0;
+  const countThinking = limits.count_thinking === true;
+  const citations = limits.citations === true;
+  const thinkingCounter = createTokenCounter();
+  const textCounter = createTokenCounter();
+  let pending = '';
+  let marker = '';
+  let reason = '';
+
+  // addText leaves [citation:N] markers, even split ones, out of the count.
+  const addText = (text, limit) => {
+    if (!citations) {
+      return textCounter.addWithin(text, limit);
+    }
+    const combined = marker + text;
+    const offset = marker.length;
+    marker = '';
+    const fit = (from, to) => {
+      const n = textCounter.addWithin(combined.slice(from, to), limit);
+      return { n: Math.max(from + n - offset, 0), ok: n === to - from };
+    };
+    let last = 0;
+    for (const m of combined.matchAll(CITATION_MARKER)) {
+      const r = fit(last, m.index);
+      if (!r.ok) {
+        return r.n;
+      }
+      last = m.index + m[0].length;
+    }
+    const held = combined.slice(last).match(PARTIAL_MARKER);
+    const end = held ? last + held.index : combined.length;
+    const r = fit(last, end);
+    if (!r.ok) {
+      return r.n;
+    }
+    marker = combined.slice(end);
+    return text.length;
+  };
+
+  const releasePending = () => {
+    if (!pending) {
+      return [];
+    }
+    const text = pending;
+    pending = '';
+    return [{ text, type: 'text' }];
+  };
+
+  const charge = (parts) => {
+    if (maxTokens <= 0) {
+      return parts;
+    }
+    for (let i = 0; i < parts.length; i += 1) {
+      const p = parts[i];
+      if (p.type === 'thinking' && !countThinking) {
+        continue;
+      }
+      const counter = p.type === 'thinking' ? thinkingCounter : textCounter;
+      const other = p.type === 'thinking' ? textCounter : thinkingCounter;
+      const limit = maxTokens - (countThinking ? other.tokens() : 0);
+      const n = p.type === 'thinking' ? counter.addWithin(p.text, limit) : addText(p.text, limit);
+      if (n === p.text.length) {
+        continue;
+      }
+      reason = 'max_tokens';
+      pending = '';
+      const out = parts.slice(0, i);
+      if (n > 0) {
+        out.push({ text: p.text.slice(0, n), type: p.type });
+      }
+      return out;
+    }
+    return parts;
+  };
+
+  const applyText = (p) => {
+    const combined = pending + p.text;
+    const idx = firstMatch(combined, stops);
+    const cut = idx >= 0 ? idx : combined.length - heldSuffixLen(combined, stops);
+    const head = Math.min(cut, pending.length);
+    const out = [];
+    if (head > 0) {
+      out.push({ text: combined.slice(0, head), type: 'text' });
+    }
+    if (cut > head) {
+      out.push({ text: combined.slice(head, cut), type: p.type });
+    }
+    if (idx >= 0) {
+      reason = 'stop_sequence';
+      pending = '';
+    } else {
+      pending = combined.slice(cut);
+    }
+    return out;
+  };
+
+  return {
+    apply(parts) {
+      const out = [];
+      for (const p of parts) {
+        if (reason) {
+          break;
+        }
+        if (p.type === 'thinking') {
+          out.push(...charge([...releasePending(), p]));
+          continue;
+        }
+        out.push(...charge(applyText(p)));
+      }
+      return out;
+    },
+    flush() {
+      return reason ? [] : charge(releasePending());
+    },
+    reached() {
+      return reason !== '';
+    },
+    finishReason() {
+      return reason === 'max_tokens' ? 'length' : 'stop';
+    },
+  };
+}
+
+function firstMatch(text, stops) {
+  let best = -1;
+  for (const stop of stops) {
+    const idx = text.indexOf(stop);
+    if (idx >= 0 && (best < 0 || idx < best)) {
+      best = idx;
+    }
+  }
+  return best;
+}
+
+function heldSuffixLen(text, stops) {
+  let held = 0;
+  for (const stop of stops) {
+    for (let n = Math.min(stop.length - 1, text.length); n > held; n -= 1) {
+      if (text.endsWith(stop.slice(0, n))) {
+        held = n;
+        break;
+      }
+    }
+  }
+  return held;
+}
+
+// Same estimate as token_usage.estimateTokens, kept as running counts.
+function createTokenCounter() {
+  let asciiChars = 0;
+  let nonASCIIChars = 0;
+  const tokens = () => {
+    if (asciiChars === 0 && nonASCIIChars === 0) {
+      return 0;
+    }
+    const n = Math.floor(asciiChars / 4) + Math.floor((nonASCIIChars * 10 + 7) / 13);
+    return n < 1 ? 1 : n;
+  };
+  return {
+    tokens,
+    // addWithin returns how many UTF-16 units of text fit within limit.
+    addWithin(text, limit) {
+      let offset = 0;
+      for (const ch of text) {
+        const ascii = ch.charCodeAt(0) < 128;
+        if (ascii) {
+          asciiChars += 1;
+        } else {
+          nonASCIIChars += 1;
+        }
+        if (tokens() > limit) {
+          if (ascii) {
+            asciiChars -= 1;
+          } else {
+            nonASCIIChars -= 1;
+          }
+          return offset;
+        }
+        offset += ch.length;
+      }
+      return text.length;
+    },
+  };
+}
+
+module.exports = {
+  createOutputLimiter,
+};
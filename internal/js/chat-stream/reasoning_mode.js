@@ -0,0 +1,84 @@
+'use strict';
+
+// Mirrors internal/util/reasoning_mode.go: decides how streamed thinking is
+// shown for a reasoning_mode. Usage keeps counting the raw thinking.
+const THINK_OPEN = '<think>';
+const THINK_CLOSE = '</think>\n\n';
+const SUMMARY_MAX_CHARS = 400;
+
+function summarizeReasoning(thinking) {
+  const lines = String(thinking || '').trim().split('\n');
+  for (let i = lines.length - 1; i >= 0; i -= 1) {
+    const line = lines[i].trim();
+    if (!line) {
+      continue;
+    }
+    const chars = Array.from(line);
+    return chars.length > SUMMARY_MAX_CHARS ? `${chars.slice(0, SUMMARY_MAX_CHARS).join('')}…` : line;
+  }
+  return '';
+}
+
+// createReasoningPresenter returns chat completion deltas, or null when
+// nothing is to be sent, for thinking text and for the end of thinking.
+function createReasoningPresenter(rawMode) {
+  const mode = String(rawMode || '').trim().toLowerCase() || 'separate';
+  let held = '';
+  let inline = '';
+  let open = false;
+  let ended = false;
+
+  return {
+    thinking(text) {
+      if (mode === 'separate') {
+        return text ? { reasoning_content: text } : null;
+      }
+      if (ended || !text) {
+        return null;
+      }
+      if (mode === 'inline_think_tags') {
+        const out = open ? text : THINK_OPEN + text;
+        open = true;
+        inline += out;
+        return { content: out };
+      }
+      if (mode === 'summary_only') {
+        held += text;
+      }
+      return null;
+    },
+    // end is called when the answer starts and when the stream ends.
+    end() {
+      if (mode === 'separate' || ended) {
+        return null;
+      }
+      ended = true;
+      if (mode === 'inline_think_tags' && open) {
+        inline += THINK_CLOSE;
+        return { content: THINK_CLOSE };
+      }
+      const summary = mode === 'summary_only' ? summarizeReasoning(held) : '';
+      return summary ? { reasoning_content: summary } : null;
+    },
+    // shift moves url_citation offsets past reasoning written inline.
+    shift(annotations) {
+      const n = Array.from(inline).length;
+      if (!n || !Array.isArray(annotations)) {
+        return annotations;
+      }
+      return annotations.map((a) => ({
+        ...a,
+        url_citation: {
+          ...a.url_citation,
+          start_index: a.url_citation.start_index + n,
+          end_index: a.url_citation.end_index + n,
+        },
+      }));
+    },
+  };
+}
+
+module.exports = {
+  createReasoningPresenter,
+  summarizeReasoning,
+};
@@ -0,0 +1,149 @@
+'use strict';
+
+// Mirrors internal/sse/search.go: collects the web results of a search stream
+// and turns [citation:N] markers into OpenAI url_citation annotations. Offsets
+// count characters of the emitted content, like the Go chat runtime.
+const CITATION_MARKER = /\[citation:(\d+)\]/g;
+const PARTIAL_MARKER = /\[(?:c(?:i(?:t(?:a(?:t(?:i(?:o(?:n(?::\d*)?)?)?)?)?)?)?)?)?$/;
+const FRAGMENT_RESULTS_PATH = /^response\/fragments\/-?\d+\/results$/;
+
+function extractSearchResults(chunk) {
+  if (!chunk || typeof chunk !== 'object') {
+    return [];
+  }
+  const path = typeof chunk.p === 'string' ? chunk.p : '';
+  const v = chunk.v;
+  if (path === 'response/search_results' || FRAGMENT_RESULTS_PATH.test(path)) {
+    return parseSearchResults(v);
+  }
+  if (path === 'response/fragments') {
+    return resultsFromFragments(v);
+  }
+  if (path === 'response') {
+    const nested = Array.isArray(v) ? v : [];
+    return nested
+      .filter((it) => it && typeof it === 'object' && it.p === 'fragments')
+      .flatMap((it) => resultsFromFragments(it.v));
+  }
+  if (v && typeof v === 'object' && !Array.isArray(v)) {
+    const m = v.response && typeof v.response === 'object' ? v.response : v;
+    return resultsFromFragments(m.fragments);
+  }
+  return [];
+}
+
+function resultsFromFragments(frags) {
+  if (!Array.isArray(frags)) {
+    return [];
+  }
+  return frags
+    .filter((f) => f && typeof f === 'object' && String(f.type || '').toUpperCase() === 'SEARCH')
+    .flatMap((f) => parseSearchResults(f.results));
+}
+
+function parseSearchResults(items) {
+  if (!Array.isArray(items)) {
+    return [];
+  }
+  const out = [];
+  items.forEach((it, i) => {
+    if (!it || typeof it !== 'object' || typeof it.url !== 'string' || !it.url.trim()) {
+      return;
+    }
+    const index = Number.isInteger(it.cite_index) && it.cite_index > 0 ? it.cite_index : i + 1;
+    out.push({
+      index,
+      url: it.url,
+      title: typeof it.title === 'string' ? it.title : '',
+    });
+  });
+  return out;
+}
+
+// createSearchCitations returns a pass-through tracker when search is off.
+function createSearchCitations(enabled) {
+  const results = new Map();
+  let emitted = 0;
+  let spanStart = 0;
+  let lastStart = 0;
+  let lastEnd = -1;
+  let pending = '';
+  let flushed = false;
+
+  const advance = (segment) => {
+    const chars = Array.from(segment);
+    const nl = chars.lastIndexOf('\n');
+    if (nl >= 0) {
+      spanStart = emitted + nl + 1;
+    }
+    emitted += chars.length;
+    return segment;
+  };
+
+  return {
+    addChunk(chunk) {
+      if (!enabled) {
+        return;
+      }
+      for (const r of extractSearchResults(chunk)) {
+        results.set(r.index, r);
+      }
+    },
+    // strip returns the part with markers removed and, when it completed any
+    // citations, their annotations.
+    strip(part) {
+      if (!enabled || part.type === 'thinking') {
+        return part;
+      }
+      let text = pending + String(part.text || '');
+      pending = '';
+      if (!flushed) {
+        // Hold back a marker the next chunk may still complete.
+        const held = text.match(PARTIAL_MARKER);
+        if (held) {
+          pending = held[0];
+          text = text.slice(0, held.index);
+        }
+      }
+      let visible = '';
+      let last = 0;
+      const annotations = [];
+      for (const m of text.matchAll(CITATION_MARKER)) {
+        visible += advance(text.slice(last, m.index));
+        last = m.index + m[0].length;
+        const result = results.get(Number(m[1]));
+        if (!result) {
+          continue;
+        }
+        const start = lastEnd === emitted ? lastStart : spanStart;
+        annotations.push({
+          type: 'url_citation',
+          url_citation: { url: result.url, title: result.title, start_index: start, end_index: emitted },
+        });
+        lastStart = start;
+        lastEnd = emitted;
+        spanStart = emitted;
+      }
+      visible += advance(text.slice(last));
+      const out = { ...part, text: visible };
+      if (annotations.length > 0) {
+        out.annotations = annotations;
+      }
+      return out;
+    },
+    // flush ends the stream and returns the text still held back.
+    flush() {
+      flushed = true;
+      const text = pending;
+      pending = '';
+      return enabled && text ? [{ text, type: 'text' }] : [];
+    },
+  };
+}
+
+module.exports = {
+  createSearchCitations,
+  extractSearchResults,
+  CITATION_MARKER,
+  PARTIAL_MARKER,
+};
@@ -14,6 +14,9 @@ function createChatCompletionEmitter({ res, sessionID, created, model, isClosed
   };
 
   const sendDeltaFrame = (delta) => {
+    if (!delta) {
+      return;
+    }
     const payloadDelta = { ...delta };
     if (!firstChunkSent) {
       payloadDelta.role = 'assistant';
@@ -28,12 +31,36 @@ function createChatCompletionEmitter({ res, sessionID, created, model, isClosed
     });
   };
 
+  const sendFinishFrame = (reason, usage) => {
+    sendFrame({
+      id: sessionID,
+      object: 'chat.completion.chunk',
+      created,
+      model,
+      choices: [{ delta: {}, index: 0, finish_reason: reason }],
+      usage,
+    });
+  };
+
   return {
     sendFrame,
     sendDeltaFrame,
+    sendFinishFrame,
   };
 }
 
+function startEventStream(res) {
+  res.statusCode = 200;
+  res.setHeader('Content-Type', 'text/event-stream');
+  res.setHeader('Cache-Control', 'no-cache, no-transform');
+  res.setHeader('Connection', 'keep-alive');
+  res.setHeader('X-Accel-Buffering', 'no');
+  if (typeof res.flushHeaders === 'function') {
+    res.flushHeaders();
+  }
+}
+
 module.exports = {
   createChatCompletionEmitter,
+  startEventStream,
 };
@@ -4,20 +4,52 @@ const crypto = require('crypto');
 
 const {
   extractToolNames,
+  checkToolCalls,
+  hasToolSchemas,
 } = require('../helpers/stream-tool-sieve');
 
 function resolveToolcallPolicy(prepBody, payloadTools) {
   const preparedToolNames = normalizePreparedToolNames(prepBody && prepBody.tool_names);
   const toolNames = preparedToolNames.length > 0 ? preparedToolNames : extractToolNames(payloadTools);
   const featureMatchEnabled = boolDefaultTrue(prepBody && prepBody.toolcall_feature_match);
+  const toolDialects = Array.isArray(prepBody && prepBody.toolcall_dialects) ? prepBody.toolcall_dialects : [];
+  const toolSchemas = hasToolSchemas(prepBody && prepBody.toolcall_schemas) ? prepBody.toolcall_schemas : null;
+  // Argument deltas stream as written; calls are checked when they close.
   const emitEarlyToolDeltas = boolDefaultTrue(prepBody && prepBody.toolcall_early_emit_high);
+  const checkCalls = (calls) => (toolSchemas ? checkToolCalls(calls, toolSchemas) : calls);
+  let rejected = false;
   return {
     toolNames,
+    toolDialects,
     toolSieveEnabled: toolNames.length > 0 && featureMatchEnabled,
     emitEarlyToolDeltas,
+    checkCalls,
+    checkEvents: (events) => checkToolEvents(events, checkCalls, () => { rejected = true; }),
+    // rejected reports whether a call failed its schema when it closed, so
+    // its streamed deltas do not end the choice with tool_calls.
+    rejected: () => rejected,
   };
 }
 
+// checkToolEvents checks the calls of sieve tool_calls events and drops the
+// events left without a valid call, reporting each drop to onReject.
+function checkToolEvents(events, checkCalls, onReject) {
+  const out = [];
+  for (const evt of events || []) {
+    if (evt && evt.type === 'tool_calls') {
+      const calls = checkCalls(evt.calls);
+      if (Array.isArray(calls) && calls.length > 0) {
+        out.push({ ...evt, calls });
+      } else if (onReject) {
+        onReject();
+      }
+      continue;
+    }
+    out.push(evt);
+  }
+  return out;
+}
+
 function normalizePreparedToolNames(v) {
   if (!Array.isArray(v) || v.length === 0) {
     return [];
@@ -8,27 +8,27 @@ const {
   parseToolCalls,
   formatOpenAIStreamToolCalls,
 } = require('../helpers/stream-tool-sieve');
-const {
-  BASE_HEADERS,
-} = require('../shared/deepseek-constants');
+const { BASE_HEADERS } = require('../shared/deepseek-constants');
 
-const {
-  writeOpenAIError,
-} = require('./error_shape');
-const {
-  parseChunkForContent,
-  isCitation,
-} = require('./sse_parse');
-const {
-  buildUsage,
-} = require('./token_usage');
+const { writeOpenAIError } = require('./error_shape');
+const { parseChunkForContent } = require('./sse_parse');
+const { buildUsage } = require('./token_usage');
 const {
   resolveToolcallPolicy,
   formatIncrementalToolCallDeltas,
 } = require('./toolcall_policy');
 const {
   createChatCompletionEmitter,
+  startEventStream,
 } = require('./stream_emitter');
+const {
+  createOutputLimiter,
+} = require('./output_limit');
+const {
+  createSearchCitations,
+} = require('./search_citations');
+const { createReasoningPresenter } = require('./reasoning_mode');
+const { proxyToGo } = require('./proxy_go');
 const {
   asString,
   isAbortError,
@@ -46,6 +46,11 @@ async function handleVercelStream(req, res, rawBody, payload) {
     relayPreparedFailure(res, prep);
     return;
   }
+  if (prep.body.go_stream === true) {
+    // The account needs what Node fetch cannot do, such as its proxy.
+    await proxyToGo(req, res, rawBody);
+    return;
+  }
 
   const model = asString(prep.body.model) || asString(payload.model);
   const sessionID = asString(prep.body.session_id) || `chatcmpl-${Date.now()}`;
@@ -55,6 +60,7 @@ async function handleVercelStream(req, res, rawBody, payload) {
   const completionPayload = prep.body.payload && typeof prep.body.payload === 'object' ? prep.body.payload : null;
   const finalPrompt = asString(prep.body.final_prompt);
   const thinkingEnabled = toBool(prep.body.thinking_enabled);
+  const reasoning = createReasoningPresenter(prep.body.reasoning_mode);
   const searchEnabled = toBool(prep.body.search_enabled);
   const toolPolicy = resolveToolcallPolicy(prep.body, payload.tools);
   const toolNames = toolPolicy.toolNames;
@@ -90,10 +96,11 @@ async function handleVercelStream(req, res, rawBody, payload) {
   try {
     let completionRes;
     try {
-      completionRes = await fetch(DEEPSEEK_COMPLETION_URL, {
+      completionRes = await fetch(asString(prep.body.completion_url) || DEEPSEEK_COMPLETION_URL, {
         method: 'POST',
         headers: {
           ...BASE_HEADERS,
+          ...(prep.body.upstream_host ? { Host: asString(prep.body.upstream_host) } : {}),
           authorization: `Bearer ${deepseekToken}`,
           'x-ds-pow-response': powHeader,
         },
@@ -116,29 +123,23 @@ async function handleVercelStream(req, res, rawBody, payload) {
       return;
     }
 
-    res.statusCode = 200;
-    res.setHeader('Content-Type', 'text/event-stream');
-    res.setHeader('Cache-Control', 'no-cache, no-transform');
-    res.setHeader('Connection', 'keep-alive');
-    res.setHeader('X-Accel-Buffering', 'no');
-    if (typeof res.flushHeaders === 'function') {
-      res.flushHeaders();
-    }
-
+    startEventStream(res);
     const created = Math.floor(Date.now() / 1000);
     let currentType = thinkingEnabled ? 'thinking' : 'text';
     let thinkingText = '';
     let outputText = '';
     const toolSieveEnabled = toolPolicy.toolSieveEnabled;
     const emitEarlyToolDeltas = toolPolicy.emitEarlyToolDeltas;
-    const toolSieveState = createToolSieveState();
+    const toolSieveState = createToolSieveState(toolPolicy.toolDialects);
     let toolCallsEmitted = false;
     const streamToolCallIDs = new Map();
+    const limiter = createOutputLimiter(prep.body.output_limits);
+    const citations = createSearchCitations(searchEnabled);
     const decoder = new TextDecoder();
     reader = completionRes.body.getReader();
     let buffered = '';
     let ended = false;
-    const { sendFrame, sendDeltaFrame } = createChatCompletionEmitter({
+    const { sendDeltaFrame, sendFinishFrame } = createChatCompletionEmitter({
       res,
       sessionID,
       created,
@@ -146,6 +147,49 @@ async function handleVercelStream(req, res, rawBody, payload) {
       isClosed: () => clientClosed,
     });
 
+    const emitPart = (raw) => {
+      const p = citations.strip(raw);
+      if (!p.text && !p.annotations) {
+        return;
+      }
+      if (p.type === 'thinking') {
+        if (thinkingEnabled) {
+          thinkingText += p.text;
+          sendDeltaFrame(reasoning.thinking(p.text));
+        }
+      } else {
+        sendDeltaFrame(reasoning.end());
+        outputText += p.text;
+        const delta = p.annotations ? { annotations: reasoning.shift(p.annotations) } : {};
+        if (!toolSieveEnabled) {
+          sendDeltaFrame(p.text ? { ...delta, content: p.text } : delta);
+          return;
+        }
+        if (p.annotations) {
+          sendDeltaFrame(delta);
+        }
+        const events = toolPolicy.checkEvents(processToolSieveChunk(toolSieveState, p.text, toolNames));
+        for (const evt of events) {
+          if (evt.type === 'tool_call_deltas' && Array.isArray(evt.deltas) && evt.deltas.length > 0) {
+            if (!emitEarlyToolDeltas) {
+              continue;
+            }
+            toolCallsEmitted = true;
+            sendDeltaFrame({ tool_calls: formatIncrementalToolCallDeltas(evt.deltas, streamToolCallIDs) });
+            continue;
+          }
+          if (evt.type === 'tool_calls') {
+            toolCallsEmitted = true;
+            sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(evt.calls) });
+            continue;
+          }
+          if (evt.text) {
+            sendDeltaFrame({ content: evt.text });
+          }
+        }
+      }
+    };
+
     const finish = async (reason) => {
       if (ended) {
         return;
@@ -155,7 +199,9 @@ async function handleVercelStream(req, res, rawBody, payload) {
         await releaseLease();
         return;
       }
-      const detected = parseToolCalls(outputText, toolNames);
+      [limiter, citations].forEach((held) => held.flush().forEach(emitPart));
+      sendDeltaFrame(reasoning.end());
+      const detected = toolPolicy.checkCalls(parseToolCalls(outputText, toolNames, toolPolicy.toolDialects));
       if (detected.length > 0 && !toolCallsEmitted) {
         toolCallsEmitted = true;
         sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(detected) });
@@ -167,17 +213,10 @@ async function handleVercelStream(req, res, rawBody, payload) {
           }
         }
       }
-      if (detected.length > 0 || toolCallsEmitted) {
+      if (detected.length > 0 || (toolCallsEmitted && !toolPolicy.rejected())) {
         reason = 'tool_calls';
       }
-      sendFrame({
-        id: sessionID,
-        object: 'chat.completion.chunk',
-        created,
-        model,
-        choices: [{ delta: {}, index: 0, finish_reason: reason }],
-        usage: buildUsage(finalPrompt, thinkingText, outputText),
-      });
+      sendFinishFrame(reason, buildUsage(finalPrompt, thinkingText, outputText));
       if (!res.writableEnded && !res.destroyed) {
         res.write('data: [DONE]\n\n');
       }
@@ -225,60 +264,22 @@ async function handleVercelStream(req, res, rawBody, payload) {
             await finish('content_filter');
             return;
           }
+          citations.addChunk(chunk);
           const parsed = parseChunkForContent(chunk, thinkingEnabled, currentType);
           currentType = parsed.newType;
           if (parsed.finished) {
             await finish('stop');
             return;
           }
-
-          for (const p of parsed.parts) {
-            if (!p.text) {
-              continue;
-            }
-            if (searchEnabled && isCitation(p.text)) {
-              continue;
-            }
-            if (p.type === 'thinking') {
-              if (thinkingEnabled) {
-                thinkingText += p.text;
-                sendDeltaFrame({ reasoning_content: p.text });
-              }
-            } else {
-              outputText += p.text;
-              if (!toolSieveEnabled) {
-                sendDeltaFrame({ content: p.text });
-                continue;
-              }
-              const events = processToolSieveChunk(toolSieveState, p.text, toolNames);
-              for (const evt of events) {
-                if (evt.type === 'tool_call_deltas' && Array.isArray(evt.deltas) && evt.deltas.length > 0) {
-                  if (!emitEarlyToolDeltas) {
-                    continue;
-                  }
-                  toolCallsEmitted = true;
-                  sendDeltaFrame({ tool_calls: formatIncrementalToolCallDeltas(evt.deltas, streamToolCallIDs) });
-                  continue;
-                }
-                if (evt.type === 'tool_calls') {
-                  toolCallsEmitted = true;
-                  sendDeltaFrame({ tool_calls: formatOpenAIStreamToolCalls(evt.calls) });
-                  continue;
-                }
-                if (evt.text) {
-                  sendDeltaFrame({ content: evt.text });
-                }
-              }
-            }
+          limiter.apply(parsed.parts).forEach(emitPart);
+          if (limiter.reached()) {
+            await finish(limiter.finishReason());
+            return;
           }
         }
       }
       await finish('stop');
-    } catch (err) {
-      if (clientClosed || isAbortError(err)) {
-        await finish('stop');
-        return;
-      }
+    } catch (_err) {
       await finish('stop');
     }
   } finally {
@@ -9,6 +9,9 @@ const {
   parseJSONStringLiteral,
   skipSpaces,
 } = require('./jsonscan');
+const {
+  toolTagCaptureStart,
+} = require('./segments');
 
 function buildIncrementalToolDeltas(state) {
   const captured = state.capture || '';
@@ -18,6 +21,11 @@ function buildIncrementalToolDeltas(state) {
   if (looksLikeToolExampleContext(state.recentTextTail)) {
     return [];
   }
+  if (toolTagCaptureStart(state, captured) >= 0) {
+    // Tag dialects carry no JSON to stream; the run is sent whole once its
+    // closing tag arrives.
+    return [];
+  }
   const lower = captured.toLowerCase();
   const keyIdx = lower.indexOf('tool_calls');
   if (keyIdx < 0) {
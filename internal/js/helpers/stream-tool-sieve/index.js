@@ -15,6 +15,10 @@ const {
 const {
   formatOpenAIStreamToolCalls,
 } = require('./format');
+const {
+  checkToolCalls,
+  hasToolSchemas,
+} = require('./schema');
 
 module.exports = {
   extractToolNames,
@@ -24,4 +28,6 @@ module.exports = {
   parseToolCalls,
   parseStandaloneToolCalls,
   formatOpenAIStreamToolCalls,
+  checkToolCalls,
+  hasToolSchemas,
 };
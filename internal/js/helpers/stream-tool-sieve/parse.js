@@ -9,6 +9,14 @@ const {
 const {
   extractJSONObjectFrom,
 } = require('./jsonscan');
+const {
+  parseToolCallsPayload,
+} = require('./payload');
+const {
+  normalizeToolDialects,
+  findToolTagStart,
+  parseToolTagCalls,
+} = require('./tags');
 
 function extractToolNames(tools) {
   if (!Array.isArray(tools) || tools.length === 0) {
@@ -28,7 +36,9 @@ function extractToolNames(tools) {
   return out;
 }
 
-function parseToolCalls(text, toolNames) {
+// parseToolCalls mirrors Go util.ParseToolCallsWith: tool-call tags of the
+// enabled dialects are tried before JSON candidates.
+function parseToolCalls(text, toolNames, dialects) {
   if (!toStringSafe(text)) {
     return [];
   }
@@ -36,12 +46,14 @@ function parseToolCalls(text, toolNames) {
   if (!toStringSafe(sanitized)) {
     return [];
   }
-  const candidates = buildToolCallCandidates(sanitized);
-  let parsed = [];
-  for (const c of candidates) {
-    parsed = parseToolCallsPayload(c);
-    if (parsed.length > 0) {
-      break;
+  const dialectSet = normalizeToolDialects(dialects);
+  let parsed = parseToolTagCalls(sanitized, dialectSet);
+  if (parsed.length === 0 && dialectSet.has('json')) {
+    for (const c of buildToolCallCandidates(sanitized)) {
+      parsed = parseToolCallsPayload(c);
+      if (parsed.length > 0) {
+        break;
+      }
     }
   }
   if (parsed.length === 0) {
@@ -58,7 +70,7 @@ function stripFencedCodeBlocks(text) {
   return t.replace(/```[\s\S]*?```/g, ' ');
 }
 
-function parseStandaloneToolCalls(text, toolNames) {
+function parseStandaloneToolCalls(text, toolNames, dialects) {
   const trimmed = toStringSafe(text);
   if (!trimmed) {
     return [];
@@ -69,6 +81,13 @@ function parseStandaloneToolCalls(text, toolNames) {
   if (looksLikeToolExampleContext(trimmed)) {
     return [];
   }
+  const dialectSet = normalizeToolDialects(dialects);
+  if (findToolTagStart(trimmed, dialectSet) === 0) {
+    return filterToolCalls(parseToolTagCalls(trimmed, dialectSet), toolNames);
+  }
+  if (!dialectSet.has('json')) {
+    return [];
+  }
   const candidates = [trimmed];
   if (trimmed.startsWith('```') && trimmed.endsWith('```')) {
     const m = trimmed.match(/```(?:json)?\s*([\s\S]*?)\s*```/i);
@@ -149,108 +168,6 @@ function extractToolCallObjects(text) {
   return out;
 }
 
-function parseToolCallsPayload(payload) {
-  let decoded;
-  try {
-    decoded = JSON.parse(payload);
-  } catch (_err) {
-    return [];
-  }
-  if (Array.isArray(decoded)) {
-    return parseToolCallList(decoded);
-  }
-  if (!decoded || typeof decoded !== 'object') {
-    return [];
-  }
-  if (decoded.tool_calls) {
-    return parseToolCallList(decoded.tool_calls);
-  }
-  const one = parseToolCallItem(decoded);
-  return one ? [one] : [];
-}
-
-function parseToolCallList(v) {
-  if (!Array.isArray(v)) {
-    return [];
-  }
-  const out = [];
-  for (const item of v) {
-    if (!item || typeof item !== 'object') {
-      continue;
-    }
-    const one = parseToolCallItem(item);
-    if (one) {
-      out.push(one);
-    }
-  }
-  return out;
-}
-
-function parseToolCallItem(m) {
-  let name = toStringSafe(m.name);
-  let inputRaw = m.input;
-  let hasInput = Object.prototype.hasOwnProperty.call(m, 'input');
-  const fn = m.function && typeof m.function === 'object' ? m.function : null;
-  if (fn) {
-    if (!name) {
-      name = toStringSafe(fn.name);
-    }
-    if (!hasInput && Object.prototype.hasOwnProperty.call(fn, 'arguments')) {
-      inputRaw = fn.arguments;
-      hasInput = true;
-    }
-  }
-  if (!hasInput) {
-    for (const k of ['arguments', 'args', 'parameters', 'params']) {
-      if (Object.prototype.hasOwnProperty.call(m, k)) {
-        inputRaw = m[k];
-        hasInput = true;
-        break;
-      }
-    }
-  }
-  if (!name) {
-    return null;
-  }
-  return {
-    name,
-    input: parseToolCallInput(inputRaw),
-  };
-}
-
-function parseToolCallInput(v) {
-  if (v == null) {
-    return {};
-  }
-  if (typeof v === 'string') {
-    const raw = toStringSafe(v);
-    if (!raw) {
-      return {};
-    }
-    try {
-      const parsed = JSON.parse(raw);
-      if (parsed && typeof parsed === 'object' && !Array.isArray(parsed)) {
-        return parsed;
-      }
-      return { _raw: raw };
-    } catch (_err) {
-      return { _raw: raw };
-    }
-  }
-  if (typeof v === 'object' && !Array.isArray(v)) {
-    return v;
-  }
-  try {
-    const parsed = JSON.parse(JSON.stringify(v));
-    if (parsed && typeof parsed === 'object' && !Array.isArray(parsed)) {
-      return parsed;
-    }
-  } catch (_err) {
-    return {};
-  }
-  return {};
-}
-
 function filterToolCalls(parsed, toolNames) {
   const allowed = new Set((toolNames || []).filter(Boolean));
   const out = [];
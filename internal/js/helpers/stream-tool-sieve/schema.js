@@ -0,0 +1,165 @@
+'use strict';
+
+// checkToolCalls mirrors Go util.ToolCallReader.Check without the repair
+// round-trip: calls are coerced to the compiled schemas the prepare step
+// sends, and calls that still do not match are dropped.
+function checkToolCalls(calls, schemas) {
+  if (!Array.isArray(calls) || calls.length === 0 || !schemas || typeof schemas !== 'object') {
+    return calls;
+  }
+  const out = [];
+  for (const call of calls) {
+    const schema = schemas[call.name];
+    if (!schema) {
+      out.push(call);
+      continue;
+    }
+    const problems = [];
+    const input = checkValue(schema, cloneJSON(call.input || {}), 'arguments', problems);
+    if (problems.length === 0) {
+      out.push({ ...call, input });
+    }
+  }
+  return out;
+}
+
+function hasToolSchemas(schemas) {
+  return !!schemas && typeof schemas === 'object' && Object.keys(schemas).length > 0;
+}
+
+function checkValue(schema, v, path, problems) {
+  const types = Array.isArray(schema.type) ? schema.type : [];
+  if (types.length > 0 && !matchesType(types, v)) {
+    const coerced = coerce(schema, types, v);
+    if (!coerced.ok) {
+      problems.push(`${path} must be ${types.join(' or ')}, got ${jsonTypeName(v)}`);
+      return v;
+    }
+    v = coerced.value;
+  }
+  if (Array.isArray(schema.enum) && schema.enum.length > 0 && !schema.enum.some((e) => JSON.stringify(e) === JSON.stringify(v))) {
+    problems.push(`${path} must be one of ${JSON.stringify(schema.enum)}`);
+  }
+  if (isObject(v)) {
+    for (const name of schema.required || []) {
+      if (!(name in v)) {
+        problems.push(`${path}.${name} is required`);
+      }
+    }
+    const props = schema.properties || {};
+    for (const k of Object.keys(v).sort()) {
+      if (props[k]) {
+        v[k] = checkValue(props[k], v[k], `${path}.${k}`, problems);
+      } else if (schema.closed) {
+        problems.push(`${path}.${k} is not an allowed property`);
+      }
+    }
+  } else if (Array.isArray(v) && schema.items) {
+    for (let i = 0; i < v.length; i += 1) {
+      v[i] = checkValue(schema.items, v[i], `${path}[${i}]`, problems);
+    }
+  }
+  return v;
+}
+
+function matchesType(types, v) {
+  return types.some((t) => {
+    switch (t) {
+      case 'object':
+        return isObject(v);
+      case 'array':
+        return Array.isArray(v);
+      case 'string':
+        return typeof v === 'string';
+      case 'boolean':
+        return typeof v === 'boolean';
+      case 'number':
+        return typeof v === 'number' && Number.isFinite(v);
+      case 'integer':
+        return Number.isInteger(v);
+      case 'null':
+        return v === null;
+      default:
+        return false;
+    }
+  });
+}
+
+function coerce(schema, types, v) {
+  const str = typeof v === 'string' ? v.trim() : null;
+  for (const t of types) {
+    switch (t) {
+      case 'number':
+      case 'integer': {
+        if (str === null || str === '') {
+          break;
+        }
+        const n = Number(str);
+        if (Number.isFinite(n) && (t === 'number' || Number.isInteger(n))) {
+          return { ok: true, value: n };
+        }
+        break;
+      }
+      case 'boolean':
+        if (str === 'true' || str === 'false') {
+          return { ok: true, value: str === 'true' };
+        }
+        break;
+      case 'string':
+        if (typeof v === 'number' || typeof v === 'boolean') {
+          return { ok: true, value: String(v) };
+        }
+        break;
+      case 'object':
+      case 'array': {
+        if (str !== null) {
+          try {
+            const decoded = JSON.parse(str);
+            if (matchesType(types, decoded)) {
+              return { ok: true, value: decoded };
+            }
+          } catch (_err) {
+            // not JSON; try the next type
+          }
+        }
+        if (t === 'array' && v !== null && v !== undefined) {
+          const items = schema.items;
+          if (items && Array.isArray(items.type) && items.type.length > 0 && !matchesType(items.type, v) && !coerce(items, items.type, v).ok) {
+            break;
+          }
+          return { ok: true, value: [v] };
+        }
+        break;
+      }
+      default:
+        break;
+    }
+  }
+  return { ok: false, value: v };
+}
+
+function isObject(v) {
+  return !!v && typeof v === 'object' && !Array.isArray(v);
+}
+
+function cloneJSON(v) {
+  return JSON.parse(JSON.stringify(v));
+}
+
+function jsonTypeName(v) {
+  if (v === null || v === undefined) {
+    return 'null';
+  }
+  if (Array.isArray(v)) {
+    return 'array';
+  }
+  if (typeof v === 'number') {
+    return Number.isInteger(v) ? 'integer' : 'number';
+  }
+  return typeof v;
+}
+
+module.exports = {
+  checkToolCalls,
+  hasToolSchemas,
+};
@@ -0,0 +1,97 @@
+'use strict';
+
+const {
+  insideCodeFence,
+} = require('./state');
+const {
+  normalizeToolDialects,
+  hasToolTagDialect,
+  findToolTagStart,
+} = require('./tags');
+
+function findToolSegmentStart(s, dialects) {
+  if (!s) {
+    return -1;
+  }
+  const dialectSet = normalizeToolDialects(dialects);
+  const jsonStart = dialectSet.has('json') ? findToolJSONSegmentStart(s) : -1;
+  const tagStart = findToolTagSegmentStart(s, dialectSet);
+  if (tagStart >= 0 && (jsonStart < 0 || tagStart < jsonStart)) {
+    return tagStart;
+  }
+  return jsonStart;
+}
+
+function findToolJSONSegmentStart(s) {
+  const lower = s.toLowerCase();
+  let offset = 0;
+  // eslint-disable-next-line no-constant-condition
+  while (true) {
+    const key = findToolJSONKey(lower.slice(offset));
+    if (key.idx < 0) {
+      return -1;
+    }
+    const keyIdx = offset + key.idx;
+    const start = s.slice(0, keyIdx).lastIndexOf('{');
+    const candidateStart = start >= 0 ? start : keyIdx;
+    if (!insideCodeFence(s.slice(0, candidateStart))) {
+      return candidateStart;
+    }
+    offset = keyIdx + key.length;
+  }
+}
+
+// findToolJSONKey returns the first key that marks a JSON tool call:
+// "tool_calls", or an OpenAI-style "function_call" object.
+function findToolJSONKey(lower) {
+  let idx = lower.indexOf('tool_calls');
+  let length = 'tool_calls'.length;
+  const fc = lower.indexOf('"function_call"');
+  if (fc >= 0 && (idx < 0 || fc < idx)) {
+    idx = fc;
+    length = '"function_call"'.length;
+  }
+  return { idx, length };
+}
+
+function findToolTagSegmentStart(s, dialects) {
+  if (!hasToolTagDialect(dialects)) {
+    return -1;
+  }
+  let offset = 0;
+  while (offset < s.length) {
+    const rel = findToolTagStart(s.slice(offset), dialects);
+    if (rel < 0) {
+      return -1;
+    }
+    const start = offset + rel;
+    if (!insideCodeFence(s.slice(0, start))) {
+      return start;
+    }
+    offset = start + 1;
+  }
+  return -1;
+}
+
+// toolTagCaptureStart reports where a capture's tool-call tag opens when the
+// capture is a tag rather than a JSON payload, or -1.
+function toolTagCaptureStart(state, captured) {
+  const dialectSet = normalizeToolDialects(state.dialects);
+  const tagStart = findToolTagSegmentStart(captured, dialectSet);
+  if (tagStart < 0) {
+    return -1;
+  }
+  if (dialectSet.has('json')) {
+    const jsonStart = findToolJSONSegmentStart(captured);
+    if (jsonStart >= 0 && jsonStart < tagStart) {
+      return -1;
+    }
+  }
+  return tagStart;
+}
+
+module.exports = {
+  findToolSegmentStart,
+  findToolJSONKey,
+  toolTagCaptureStart,
+};
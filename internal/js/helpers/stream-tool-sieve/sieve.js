@@ -15,6 +15,15 @@ const {
 const {
   extractJSONObjectFrom,
 } = require('./jsonscan');
+const {
+  toolTagPrefixStart,
+  toolTagRunEnd,
+} = require('./tags');
+const {
+  findToolSegmentStart,
+  findToolJSONKey,
+  toolTagCaptureStart,
+} = require('./segments');
 
 function processToolSieveChunk(state, chunk, toolNames) {
   if (!state) {
@@ -67,7 +76,7 @@ function processToolSieveChunk(state, chunk, toolNames) {
       break;
     }
 
-    const start = findToolSegmentStart(state.pending);
+    const start = findToolSegmentStart(state.pending, state.dialects);
     if (start >= 0) {
       const prefix = state.pending.slice(0, start);
       if (prefix) {
@@ -81,7 +90,7 @@ function processToolSieveChunk(state, chunk, toolNames) {
       continue;
     }
 
-    const [safe, hold] = splitSafeContentForToolDetection(state.pending);
+    const [safe, hold] = splitSafeContentForToolDetection(state.pending, state.dialects);
     if (!safe) {
       break;
     }
@@ -98,7 +107,9 @@ function flushToolSieve(state, toolNames) {
   }
   const events = processToolSieveChunk(state, '', toolNames);
   if (state.capturing) {
+    state.flushing = true;
     const consumed = consumeToolCapture(state, toolNames);
+    state.flushing = false;
     if (consumed.ready) {
       if (consumed.prefix) {
         noteText(state, consumed.prefix);
@@ -127,12 +138,16 @@ function flushToolSieve(state, toolNames) {
   return events;
 }
 
-function splitSafeContentForToolDetection(s) {
+function splitSafeContentForToolDetection(s, dialects) {
   const text = s || '';
   if (!text) {
     return ['', ''];
   }
-  const suspiciousStart = findSuspiciousPrefixStart(text);
+  let suspiciousStart = findSuspiciousPrefixStart(text);
+  const tagStart = toolTagPrefixStart(text, dialects);
+  if (tagStart >= 0 && (suspiciousStart < 0 || tagStart < suspiciousStart)) {
+    suspiciousStart = tagStart;
+  }
   if (suspiciousStart < 0) {
     return [text, ''];
   }
@@ -155,35 +170,16 @@ function findSuspiciousPrefixStart(s) {
   return start;
 }
 
-function findToolSegmentStart(s) {
-  if (!s) {
-    return -1;
-  }
-  const lower = s.toLowerCase();
-  let offset = 0;
-  // eslint-disable-next-line no-constant-condition
-  while (true) {
-    const keyRel = lower.indexOf('tool_calls', offset);
-    if (keyRel < 0) {
-      return -1;
-    }
-    const keyIdx = keyRel;
-    const start = s.slice(0, keyIdx).lastIndexOf('{');
-    const candidateStart = start >= 0 ? start : keyIdx;
-    if (!insideCodeFence(s.slice(0, candidateStart))) {
-      return candidateStart;
-    }
-    offset = keyIdx + 'tool_calls'.length;
-  }
-}
-
 function consumeToolCapture(state, toolNames) {
   const captured = state.capture;
   if (!captured) {
     return { ready: false, prefix: '', calls: [], suffix: '' };
   }
-  const lower = captured.toLowerCase();
-  const keyIdx = lower.indexOf('tool_calls');
+  const tagStart = toolTagCaptureStart(state, captured);
+  if (tagStart >= 0) {
+    return consumeToolTagCapture(state, toolNames, captured, tagStart);
+  }
+  const keyIdx = findToolJSONKey(captured.toLowerCase()).idx;
   if (keyIdx < 0) {
     return { ready: false, prefix: '', calls: [], suffix: '' };
   }
@@ -205,8 +201,8 @@ function consumeToolCapture(state, toolNames) {
       suffix: '',
     };
   }
-  const rawParsed = parseStandaloneToolCalls(captured.slice(start, obj.end), []);
-  const parsed = parseStandaloneToolCalls(captured.slice(start, obj.end), toolNames);
+  const rawParsed = parseStandaloneToolCalls(captured.slice(start, obj.end), [], state.dialects);
+  const parsed = parseStandaloneToolCalls(captured.slice(start, obj.end), toolNames, state.dialects);
   if (parsed.length === 0) {
     if (rawParsed.length > 0 && Array.isArray(toolNames) && toolNames.length > 0) {
       return {
@@ -255,6 +251,30 @@ function consumeToolCapture(state, toolNames) {
   };
 }
 
+// consumeToolTagCapture waits for the run of tool-call tags opening at start
+// to close and parses it as one batch, like the Go sieve.
+function consumeToolTagCapture(state, toolNames, captured, start) {
+  const run = toolTagRunEnd(captured, start, state.dialects, state.flushing);
+  if (!run.ok) {
+    return { ready: false, prefix: '', calls: [], suffix: '' };
+  }
+  const prefixPart = captured.slice(0, start);
+  const suffixPart = captured.slice(run.end);
+  if (insideCodeFence((state.recentTextTail || '') + prefixPart)) {
+    return { ready: true, prefix: captured, calls: [], suffix: '' };
+  }
+  const block = captured.slice(start, run.end);
+  const parsed = parseStandaloneToolCalls(block, toolNames, state.dialects);
+  if (parsed.length === 0) {
+    const rejected = Array.isArray(toolNames) && toolNames.length > 0
+      && parseStandaloneToolCalls(block, [], state.dialects).length > 0;
+    return rejected
+      ? { ready: true, prefix: prefixPart, calls: [], suffix: suffixPart }
+      : { ready: true, prefix: captured, calls: [], suffix: '' };
+  }
+  return { ready: true, prefix: prefixPart, calls: parsed, suffix: suffixPart };
+}
+
 module.exports = {
   processToolSieveChunk,
   flushToolSieve,
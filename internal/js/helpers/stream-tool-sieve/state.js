@@ -3,11 +3,15 @@
 const TOOL_SIEVE_CAPTURE_LIMIT = 8 * 1024;
 const TOOL_SIEVE_CONTEXT_TAIL_LIMIT = 256;
 
-function createToolSieveState() {
+// createToolSieveState takes the prepared tool-call dialect names; without
+// them only JSON tool calls are recognised.
+function createToolSieveState(dialects) {
   return {
+    dialects: Array.isArray(dialects) ? dialects : [],
     pending: '',
     capture: '',
     capturing: false,
+    flushing: false,
     recentTextTail: '',
     toolNameSent: false,
     toolName: '',
@@ -0,0 +1,206 @@
+'use strict';
+
+const {
+  parseToolCallsPayload,
+  parseToolCallInput,
+} = require('./payload');
+
+const TOOL_DIALECTS = ['json', 'xml', 'function_tag'];
+const TOOL_TAG_OPEN_SOURCE = '<(tool_calls|tool_call|function_calls)\\b[^>]*>|<function=\\s*["\']?([^"\'>\\s]+)["\']?\\s*>';
+const TOOL_TAG_OPENERS = [
+  { text: '<tool_call', dialect: 'xml' },
+  { text: '<function_calls', dialect: 'xml' },
+  { text: '<function=', dialect: 'function_tag' },
+];
+const TOOL_TAG_NAME_PATTERN = /<name>([\s\S]*?)<\/name>/i;
+const TOOL_TAG_ARGS_PATTERN = /<(?:arguments|parameters|input|args)>([\s\S]*?)<\/(?:arguments|parameters|input|args)>/i;
+const TOOL_TAG_INVOKE_PATTERN = /<invoke\s+name\s*=\s*["']?([^"'>\s]+)["']?\s*>([\s\S]*?)<\/invoke>/gi;
+const TOOL_TAG_PARAM_PATTERN = /<parameter\s+name\s*=\s*["']?([^"'>\s]+)["']?\s*>([\s\S]*?)<\/parameter>/gi;
+const FUNCTION_TAG_PARAM_PATTERN = /<parameter=\s*["']?([^"'>\s]+)["']?\s*>([\s\S]*?)<\/parameter>/gi;
+
+// normalizeToolDialects turns the prepared dialect list into a Set. Missing
+// or empty lists mean JSON only, like the Go parser's zero value.
+function normalizeToolDialects(v) {
+  if (v instanceof Set) {
+    return v;
+  }
+  const out = new Set();
+  for (const item of Array.isArray(v) ? v : []) {
+    const name = typeof item === 'string' ? item.trim().toLowerCase() : '';
+    if (TOOL_DIALECTS.includes(name)) {
+      out.add(name);
+    }
+  }
+  if (out.size === 0) {
+    out.add('json');
+  }
+  return out;
+}
+
+function hasToolTagDialect(dialects) {
+  const set = normalizeToolDialects(dialects);
+  return set.has('xml') || set.has('function_tag');
+}
+
+function nextToolTag(text, from, dialects) {
+  const set = normalizeToolDialects(dialects);
+  const re = new RegExp(TOOL_TAG_OPEN_SOURCE, 'gi');
+  re.lastIndex = from;
+  let m;
+  while ((m = re.exec(text)) !== null) {
+    const block = { tag: '', fn: '', start: m.index, bodyStart: m.index + m[0].length, bodyEnd: -1, end: -1 };
+    let dialect = 'xml';
+    let closing = '';
+    if (m[1]) {
+      block.tag = m[1].toLowerCase();
+      closing = `</${block.tag}>`;
+    } else {
+      block.tag = 'function';
+      block.fn = m[2];
+      dialect = 'function_tag';
+      closing = '</function>';
+    }
+    if (!set.has(dialect)) {
+      continue;
+    }
+    const idx = text.slice(block.bodyStart).toLowerCase().indexOf(closing);
+    if (idx < 0) {
+      return { found: true, closed: false, block };
+    }
+    block.bodyEnd = block.bodyStart + idx;
+    block.end = block.bodyEnd + closing.length;
+    return { found: true, closed: true, block };
+  }
+  return { found: false, closed: false, block: null };
+}
+
+function findToolTagStart(text, dialects) {
+  const next = nextToolTag(text || '', 0, dialects);
+  return next.found ? next.block.start : -1;
+}
+
+function toolTagPrefixStart(text, dialects) {
+  const set = normalizeToolDialects(dialects);
+  const idx = (text || '').lastIndexOf('<');
+  if (idx < 0) {
+    return -1;
+  }
+  const tail = text.slice(idx).toLowerCase();
+  for (const o of TOOL_TAG_OPENERS) {
+    if (!set.has(o.dialect)) {
+      continue;
+    }
+    if (o.text.startsWith(tail) || (tail.startsWith(o.text) && !tail.includes('>'))) {
+      return idx;
+    }
+  }
+  return -1;
+}
+
+// toolTagRunEnd mirrors Go util.ToolTagRunEnd: consecutive tags separated by
+// whitespace form one run, which is not complete until other text follows
+// or the stream ends.
+function toolTagRunEnd(text, start, dialects, final) {
+  let end = -1;
+  let pos = start;
+  // eslint-disable-next-line no-constant-condition
+  while (true) {
+    const next = nextToolTag(text, pos, dialects);
+    if (!next.found || next.block.start !== pos) {
+      break;
+    }
+    if (!next.closed) {
+      if (final && end >= 0) {
+        break;
+      }
+      return { end: 0, ok: false };
+    }
+    end = next.block.end;
+    pos = end;
+    while (pos < text.length && ' \t\r\n'.includes(text[pos])) {
+      pos += 1;
+    }
+    if (pos === text.length || toolTagPrefixStart(text.slice(pos), dialects) === 0) {
+      if (!final) {
+        return { end: 0, ok: false };
+      }
+      break;
+    }
+  }
+  return { end, ok: end >= 0 };
+}
+
+function parseToolTagCalls(text, dialects) {
+  const out = [];
+  let pos = 0;
+  // eslint-disable-next-line no-constant-condition
+  while (true) {
+    const next = nextToolTag(text, pos, dialects);
+    if (!next.found || !next.closed) {
+      return out;
+    }
+    out.push(...parseToolTagBlock(text.slice(next.block.bodyStart, next.block.bodyEnd), next.block));
+    pos = next.block.end;
+  }
+}
+
+function parseToolTagBlock(body, block) {
+  const trimmed = body.trim();
+  switch (block.tag) {
+    case 'tool_calls': {
+      const calls = parseToolTagCalls(body, new Set(TOOL_DIALECTS));
+      return calls.length > 0 ? calls : parseToolCallsPayload(trimmed);
+    }
+    case 'tool_call': {
+      if (trimmed.startsWith('{') || trimmed.startsWith('[')) {
+        return parseToolCallsPayload(trimmed);
+      }
+      const calls = parseToolTagCalls(body, new Set(['function_tag']));
+      if (calls.length > 0) {
+        return calls;
+      }
+      const m = body.match(TOOL_TAG_NAME_PATTERN);
+      if (!m || !m[1].trim()) {
+        return [];
+      }
+      const args = body.match(TOOL_TAG_ARGS_PATTERN);
+      return [{ name: m[1].trim(), input: parseToolCallInput(args ? args[1] : null) }];
+    }
+    case 'function_calls': {
+      const out = [];
+      for (const m of body.matchAll(TOOL_TAG_INVOKE_PATTERN)) {
+        out.push({ name: m[1].trim(), input: parseTagParameters(m[2], TOOL_TAG_PARAM_PATTERN) });
+      }
+      return out;
+    }
+    case 'function':
+      if (trimmed.startsWith('{')) {
+        return [{ name: block.fn.trim(), input: parseToolCallInput(trimmed) }];
+      }
+      return [{ name: block.fn.trim(), input: parseTagParameters(body, FUNCTION_TAG_PARAM_PATTERN) }];
+    default:
+      return [];
+  }
+}
+
+function parseTagParameters(body, pattern) {
+  const out = {};
+  for (const m of body.matchAll(pattern)) {
+    const raw = m[2].trim();
+    try {
+      out[m[1]] = JSON.parse(raw);
+    } catch (_err) {
+      out[m[1]] = raw;
+    }
+  }
+  return out;
+}
+
+module.exports = {
+  normalizeToolDialects,
+  hasToolTagDialect,
+  findToolTagStart,
+  toolTagPrefixStart,
+  toolTagRunEnd,
+  parseToolTagCalls,
+};
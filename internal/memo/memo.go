@@ -0,0 +1,50 @@
+// Package memo caches values derived from configuration, such as parsed
+// templates and compiled patterns, without growing with every config edit.
+package memo
+
+import "sync"
+
+// Cache maps keys to derived values and holds at most limit of them. Once
+// full it is emptied before the next store, so entries for values removed
+// from config are dropped eventually while live ones are simply rebuilt.
+type Cache[K comparable, V any] struct {
+	mu    sync.Mutex
+	limit int
+	m     map[K]V
+}
+
+// New returns a cache holding at most limit entries.
+func New[K comparable, V any](limit int) *Cache[K, V] {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Cache[K, V]{limit: limit}
+}
+
+// Get returns the value stored for key.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+// Put stores v for key, emptying the cache first when it is full.
+func (c *Cache[K, V]) Put(key K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.m[key]; !ok && len(c.m) >= c.limit {
+		c.m = nil
+	}
+	if c.m == nil {
+		c.m = make(map[K]V, c.limit)
+	}
+	c.m[key] = v
+}
+
+// Len reports the number of entries held.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.m)
+}
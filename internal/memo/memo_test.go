@@ -0,0 +1,23 @@
+package memo
+
+import "testing"
+
+func TestCacheStaysWithinLimit(t *testing.T) {
+	c := New[string, int](2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("a", 3)
+	if v, ok := c.Get("a"); !ok || v != 3 || c.Len() != 2 {
+		t.Fatalf("expected overwrite in place, got %d %v len=%d", v, ok, c.Len())
+	}
+	c.Put("c", 4)
+	if c.Len() != 1 {
+		t.Fatalf("expected full cache to be emptied, len=%d", c.Len())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected stale entry to be dropped")
+	}
+	if v, ok := c.Get("c"); !ok || v != 4 {
+		t.Fatalf("expected new entry, got %d %v", v, ok)
+	}
+}
@@ -9,45 +9,10 @@ import (
 
 var markdownImagePattern = regexp.MustCompile(`!\[(.*?)\]\((.*?)\)`)
 
+// MessagesPrepare flattens messages with the default chat template.
 func MessagesPrepare(messages []map[string]any) string {
-	type block struct {
-		Role string
-		Text string
-	}
-	processed := make([]block, 0, len(messages))
-	for _, m := range messages {
-		role, _ := m["role"].(string)
-		text := NormalizeContent(m["content"])
-		processed = append(processed, block{Role: role, Text: text})
-	}
-	if len(processed) == 0 {
-		return ""
-	}
-	merged := make([]block, 0, len(processed))
-	for _, msg := range processed {
-		if len(merged) > 0 && merged[len(merged)-1].Role == msg.Role {
-			merged[len(merged)-1].Text += "\n\n" + msg.Text
-			continue
-		}
-		merged = append(merged, msg)
-	}
-	parts := make([]string, 0, len(merged))
-	for i, m := range merged {
-		switch m.Role {
-		case "assistant":
-			parts = append(parts, "<｜Assistant｜>"+m.Text+"<｜end▁of▁sentence｜>")
-		case "user", "system":
-			if i > 0 {
-				parts = append(parts, "<｜User｜>"+m.Text)
-			} else {
-				parts = append(parts, m.Text)
-			}
-		default:
-			parts = append(parts, m.Text)
-		}
-	}
-	out := strings.Join(parts, "")
-	return markdownImagePattern.ReplaceAllString(out, `[${1}](${2})`)
+	out, _ := defaultChatTemplate.Render(messages)
+	return out
 }
 
 func NormalizeContent(v any) string {
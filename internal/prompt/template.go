@@ -0,0 +1,142 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultChatTemplateName names the built-in template MessagesPrepare uses.
+const DefaultChatTemplateName = "default"
+
+// Image link handling applied to a rendered prompt.
+const (
+	// ImageLinksRewrite turns markdown images into plain links, which the
+	// upstream web chat would otherwise try to render.
+	ImageLinksRewrite = "rewrite"
+	// ImageLinksKeep leaves markdown images as written.
+	ImageLinksKeep = "keep"
+)
+
+// DefaultChatTemplateText is the DeepSeek web chat flattening: a leading
+// system or user turn is raw text, later user and system turns start with the
+// user marker and assistant turns are wrapped in assistant markers.
+const DefaultChatTemplateText = `{{range .Turns}}` +
+	`{{if eq .Role "assistant"}}<｜Assistant｜>{{.Text}}<｜end▁of▁sentence｜>` +
+	`{{else if and (not .First) (or (eq .Role "user") (eq .Role "system"))}}<｜User｜>{{.Text}}` +
+	`{{else}}{{.Text}}{{end}}` +
+	`{{end}}`
+
+// ChatTurn is one message as seen by a chat template.
+type ChatTurn struct {
+	Role  string
+	Text  string
+	Index int
+	First bool
+	Last  bool
+}
+
+// ChatTemplateData is what a chat template is executed with. Turns merges
+// consecutive messages of the same role with a blank line; Messages keeps
+// them apart. System is the leading system turn, if any, and Dialogue the
+// turns after it.
+type ChatTemplateData struct {
+	Messages []ChatTurn
+	Turns    []ChatTurn
+	System   string
+	Dialogue []ChatTurn
+}
+
+// ChatTemplate flattens chat messages into a single prompt.
+type ChatTemplate struct {
+	Name       string
+	ImageLinks string
+	tmpl       *template.Template
+}
+
+var chatTemplateFuncs = template.FuncMap{
+	"trim":    strings.TrimSpace,
+	"join":    strings.Join,
+	"replace": strings.ReplaceAll,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+}
+
+var defaultChatTemplate = mustParseChatTemplate(DefaultChatTemplateName, DefaultChatTemplateText, ImageLinksRewrite)
+
+// DefaultChatTemplate returns the built-in template.
+func DefaultChatTemplate() *ChatTemplate {
+	return defaultChatTemplate
+}
+
+// ParseChatTemplate parses a text/template chat template. imageLinks is
+// ImageLinksRewrite, ImageLinksKeep or empty for ImageLinksRewrite.
+func ParseChatTemplate(name, text, imageLinks string) (*ChatTemplate, error) {
+	imageLinks = strings.ToLower(strings.TrimSpace(imageLinks))
+	switch imageLinks {
+	case "":
+		imageLinks = ImageLinksRewrite
+	case ImageLinksRewrite, ImageLinksKeep:
+	default:
+		return nil, fmt.Errorf("image_links must be rewrite or keep")
+	}
+	tmpl, err := template.New(name).Funcs(chatTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatTemplate{Name: name, ImageLinks: imageLinks, tmpl: tmpl}, nil
+}
+
+func mustParseChatTemplate(name, text, imageLinks string) *ChatTemplate {
+	t, err := ParseChatTemplate(name, text, imageLinks)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render flattens messages with the template.
+func (t *ChatTemplate) Render(messages []map[string]any) (string, error) {
+	if len(messages) == 0 {
+		return "", nil
+	}
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, newChatTemplateData(messages)); err != nil {
+		return "", err
+	}
+	out := b.String()
+	if t.ImageLinks == ImageLinksRewrite {
+		out = markdownImagePattern.ReplaceAllString(out, `[${1}](${2})`)
+	}
+	return out, nil
+}
+
+func newChatTemplateData(messages []map[string]any) ChatTemplateData {
+	var data ChatTemplateData
+	for _, m := range messages {
+		role, _ := m["role"].(string)
+		text := NormalizeContent(m["content"])
+		data.Messages = append(data.Messages, ChatTurn{Role: role, Text: text})
+		if n := len(data.Turns); n > 0 && data.Turns[n-1].Role == role {
+			data.Turns[n-1].Text += "\n\n" + text
+			continue
+		}
+		data.Turns = append(data.Turns, ChatTurn{Role: role, Text: text})
+	}
+	markChatTurns(data.Messages)
+	markChatTurns(data.Turns)
+	data.Dialogue = data.Turns
+	if data.Turns[0].Role == "system" {
+		data.System = data.Turns[0].Text
+		data.Dialogue = data.Turns[1:]
+	}
+	return data
+}
+
+func markChatTurns(turns []ChatTurn) {
+	for i := range turns {
+		turns[i].Index = i
+		turns[i].First = i == 0
+		turns[i].Last = i == len(turns)-1
+	}
+}
@@ -0,0 +1,150 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChatTemplateRender(t *testing.T) {
+	messages := []map[string]any{
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": "hi"},
+		{"role": "user", "content": []any{map[string]any{"type": "text", "text": "there"}}},
+		{"role": "assistant", "content": "see ![cat](http://x/cat.png)"},
+	}
+	cases := []struct {
+		name       string
+		text       string
+		imageLinks string
+		messages   []map[string]any
+		want       string
+	}{
+		{
+			name:     "default template",
+			text:     DefaultChatTemplateText,
+			messages: messages,
+			want:     "be brief<｜User｜>hi\n\nthere<｜Assistant｜>see [cat](http://x/cat.png)<｜end▁of▁sentence｜>",
+		},
+		{
+			name:       "keep image links",
+			text:       `{{range .Dialogue}}{{if .Last}}{{.Text}}{{end}}{{end}}`,
+			imageLinks: "Keep",
+			messages:   messages,
+			want:       "see ![cat](http://x/cat.png)",
+		},
+		{
+			name:     "system and dialogue",
+			text:     `[{{.System}}]{{range .Dialogue}}{{.Index}}:{{upper .Role}} {{end}}`,
+			messages: messages,
+			want:     "[be brief]1:USER 2:ASSISTANT ",
+		},
+		{
+			name:     "messages stay apart",
+			text:     `{{len .Messages}}/{{len .Turns}}`,
+			messages: messages,
+			want:     "4/3",
+		},
+		{
+			name:     "no leading system turn",
+			text:     `{{printf "%q" .System}}{{len .Dialogue}}`,
+			messages: messages[1:2],
+			want:     `""1`,
+		},
+		{
+			name:     "no messages",
+			text:     `{{.System}}`,
+			messages: nil,
+			want:     "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseChatTemplate(tc.name, tc.text, tc.imageLinks)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := tmpl.Render(tc.messages)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChatTemplateErrors(t *testing.T) {
+	messages := []map[string]any{{"role": "user", "content": "hi"}}
+	cases := []struct {
+		name       string
+		text       string
+		imageLinks string
+		parseErr   string
+		renderErr  string
+	}{
+		{name: "unclosed action", text: `{{range .Turns}}`, parseErr: "unexpected EOF"},
+		{name: "unknown function", text: `{{shout .System}}`, parseErr: `function "shout" not defined`},
+		{name: "bad image links", text: `{{.System}}`, imageLinks: "drop", parseErr: "image_links"},
+		{name: "missing field", text: `{{.Prompt}}`, renderErr: "can't evaluate field Prompt"},
+		{name: "missing turn field", text: `{{range .Turns}}{{.Name}}{{end}}`, renderErr: "can't evaluate field Name"},
+		{name: "index out of range", text: `{{(index .Dialogue 3).Text}}`, renderErr: "out of range"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseChatTemplate(tc.name, tc.text, tc.imageLinks)
+			if tc.parseErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.parseErr) {
+					t.Fatalf("expected parse error containing %q, got %v", tc.parseErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if _, err := tmpl.Render(messages); err == nil || !strings.Contains(err.Error(), tc.renderErr) {
+				t.Fatalf("expected render error containing %q, got %v", tc.renderErr, err)
+			}
+		})
+	}
+}
+
+func TestToolPromptRenderAndErrors(t *testing.T) {
+	data := ToolPromptData{
+		Tools:     []ToolSpec{{Name: "search"}},
+		ToolNames: []string{"search", "fetch"},
+		Mode:      "auto",
+		Surface:   "openai",
+	}
+	cases := []struct {
+		name string
+		text string
+		want string
+		err  string
+	}{
+		{name: "names", text: `  {{join .ToolNames ", "}} ({{.Mode}})  `, want: "search, fetch (auto)"},
+		{name: "forced name empty", text: `[{{.ForcedName}}]`, want: "[]"},
+		{name: "missing field", text: `{{.Schema}}`, err: "can't evaluate field Schema"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tp, err := ParseToolPrompt(tc.name, tc.text)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := tp.Render(data)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("got %q %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+	if _, err := ParseToolPrompt("bad", `{{if}}`); err == nil {
+		t.Fatal("expected parse error for empty if")
+	}
+}
@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"strings"
+	"text/template"
+)
+
+// toolCallHistoryRules is shared by the built-in OpenAI tool prompt: how the
+// model must answer and how to read tool history markers.
+const toolCallHistoryRules = `When you need to use tools, output ONLY this JSON format (no other text):
+{"tool_calls": [{"name": "tool_name", "input": {"param": "value"}}]}
+
+History markers in conversation:
+- [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] means a tool call you already made earlier.
+- [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] means the runtime returned a tool result (not user input).
+
+IMPORTANT:
+1) If calling tools, output ONLY the JSON. The response must start with { and end with }.
+2) After receiving a tool result, you MUST use it to produce the final answer.
+3) Only call another tool when the previous result is missing required data or returned an error.
+4) Do not repeat a tool call that is already satisfied by an existing [TOOL_RESULT_HISTORY] block.`
+
+// DefaultToolPromptText is the built-in tool instructions for the OpenAI and
+// Gemini surfaces.
+const DefaultToolPromptText = "You have access to these tools:\n\n" +
+	`{{range $i, $t := .Tools}}{{if $i}}` + "\n\n" + `{{end}}Tool: {{$t.Name}}` + "\n" +
+	`Description: {{$t.Description}}` + "\n" + `Parameters: {{$t.Parameters}}{{end}}` + "\n\n" +
+	toolCallHistoryRules +
+	`{{if eq .Mode "required"}}` + "\n" + `5) For this response, you MUST call at least one tool from the allowed list.` +
+	`{{else if and (eq .Mode "forced") .ForcedName}}` + "\n" + `5) For this response, you MUST call exactly this tool name: {{.ForcedName}}` +
+	"\n" + `6) Do not call any other tool.{{end}}`
+
+// ClaudeToolPromptText is the built-in tool instructions for the Claude
+// surface.
+const ClaudeToolPromptText = "You are Claude, a helpful AI assistant. You have access to these tools:" +
+	`{{range .Tools}}` + "\n\n" + `Tool: {{.Name}}` + "\n" + `Description: {{.Description}}` + "\n" + `Parameters: {{.Parameters}}{{end}}` + "\n\n" +
+	`When you need to use tools, you can call multiple tools in one response. Output ONLY JSON like {"tool_calls":[{"name":"tool","input":{}}]}` + "\n\n" +
+	`History markers in conversation: [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] are your previous tool calls; [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] are runtime tool outputs, not user input.` + "\n\n" +
+	`After a valid [TOOL_RESULT_HISTORY], continue with final answer instead of repeating the same call unless required fields are still missing.`
+
+// ToolSpec is one tool as seen by a tool prompt. Parameters is the JSON
+// schema of its input.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  string
+}
+
+// ToolPromptData is what a tool prompt is executed with. Mode is auto,
+// required or forced; ForcedName is set in forced mode.
+type ToolPromptData struct {
+	Tools      []ToolSpec
+	ToolNames  []string
+	Mode       string
+	ForcedName string
+	Surface    string
+	Model      string
+}
+
+// ToolPrompt renders the tool instructions injected into the system prompt.
+type ToolPrompt struct {
+	Name string
+	tmpl *template.Template
+}
+
+var (
+	defaultToolPrompt = mustParseToolPrompt("default", DefaultToolPromptText)
+	claudeToolPrompt  = mustParseToolPrompt("default", ClaudeToolPromptText)
+)
+
+// DefaultToolPrompt returns the built-in tool prompt for surface.
+func DefaultToolPrompt(surface string) *ToolPrompt {
+	if surface == "claude" {
+		return claudeToolPrompt
+	}
+	return defaultToolPrompt
+}
+
+// ParseToolPrompt parses a text/template tool prompt.
+func ParseToolPrompt(name, text string) (*ToolPrompt, error) {
+	tmpl, err := template.New(name).Funcs(chatTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolPrompt{Name: name, tmpl: tmpl}, nil
+}
+
+func mustParseToolPrompt(name, text string) *ToolPrompt {
+	t, err := ParseToolPrompt(name, text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the tool prompt; the result is trimmed.
+func (t *ToolPrompt) Render(data ToolPromptData) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
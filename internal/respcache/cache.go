@@ -0,0 +1,162 @@
+// Package respcache caches the upstream streams of deterministic
+// completions so identical requests can be answered without a round trip.
+package respcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+// Header reports whether a response was served from the cache ("hit") or
+// recorded into it ("miss"). Uncacheable requests do not get the header.
+const Header = "X-Ds2api-Cache"
+
+type ConfigReader interface {
+	ResponseCacheConfig() config.ResponseCacheConfig
+}
+
+// backend stores cached bodies until they expire; it drops entries on its
+// own to stay within its size limit.
+type backend interface {
+	get(key string, now time.Time) ([]byte, bool)
+	put(key string, body []byte, expires time.Time)
+}
+
+// Cache is the response cache. A nil Cache caches nothing. The backend is
+// rebuilt when its settings change, which empties the memory backend.
+type Cache struct {
+	Store ConfigReader
+
+	mu       sync.Mutex
+	backend  backend
+	settings config.ResponseCacheConfig
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+func New(store ConfigReader) *Cache {
+	return &Cache{Store: store}
+}
+
+// Key returns the cache key of req and whether req may be cached at all:
+// the cache must be enabled and the request deterministic.
+func (c *Cache) Key(req util.StandardRequest) (string, bool) {
+	if c == nil || c.Store == nil || !c.Store.ResponseCacheConfig().Enabled || !req.Deterministic() {
+		return "", false
+	}
+	return req.Fingerprint(), true
+}
+
+// Get returns the stored upstream body for key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	b, _ := c.current()
+	if b == nil {
+		return nil, false
+	}
+	return b.get(key, c.clock())
+}
+
+// Record passes body through and, when it is closed, stores what was read
+// if complete reports it as a whole, successful stream. Bodies larger than
+// the size limit or that failed to read are not stored.
+func (c *Cache) Record(key string, body io.ReadCloser, complete func([]byte) bool) io.ReadCloser {
+	b, cfg := c.current()
+	if b == nil {
+		return body
+	}
+	return &recorder{
+		ReadCloser: body,
+		limit:      cfg.MaxBytes,
+		store: func(body []byte) {
+			if complete(body) {
+				b.put(key, body, c.clock().Add(time.Duration(cfg.TTLSeconds)*time.Second))
+			}
+		},
+	}
+}
+
+// Replay returns a stored body as an upstream response, so the stream
+// runtimes re-chunk it exactly as they did the original.
+func Replay(body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+// IsHit reports whether the response being written was served from the
+// cache, so usage can report the prompt as cached tokens.
+func IsHit(h http.Header) bool {
+	return h.Get(Header) == "hit"
+}
+
+// current returns the backend for the present settings, or nil when the
+// cache is disabled.
+func (c *Cache) current() (backend, config.ResponseCacheConfig) {
+	if c == nil || c.Store == nil {
+		return nil, config.ResponseCacheConfig{}
+	}
+	cfg := c.Store.ResponseCacheConfig()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !cfg.Enabled {
+		c.backend = nil
+		return nil, cfg
+	}
+	if c.backend == nil || cfg.Backend != c.settings.Backend || cfg.Dir != c.settings.Dir || cfg.MaxBytes != c.settings.MaxBytes {
+		if cfg.Backend == "disk" {
+			c.backend = newDiskBackend(cfg.Dir, cfg.MaxBytes)
+		} else {
+			c.backend = newMemoryBackend(cfg.MaxBytes)
+		}
+	}
+	c.settings = cfg
+	return c.backend, cfg
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+type recorder struct {
+	io.ReadCloser
+	limit int64
+	store func([]byte)
+	buf   bytes.Buffer
+	skip  bool
+	done  bool
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && !r.skip {
+		if int64(r.buf.Len()+n) > r.limit {
+			r.skip = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p[:n])
+		}
+	}
+	if err != nil && err != io.EOF {
+		r.skip = true
+	}
+	return n, err
+}
+
+func (r *recorder) Close() error {
+	if !r.skip && !r.done && r.buf.Len() > 0 {
+		r.done = true
+		r.store(bytes.Clone(r.buf.Bytes()))
+	}
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,146 @@
+package respcache
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"ds2api/internal/config"
+	"ds2api/internal/prompt"
+	"ds2api/internal/util"
+)
+
+type testConfig struct {
+	cfg config.ResponseCacheConfig
+}
+
+func (t *testConfig) ResponseCacheConfig() config.ResponseCacheConfig {
+	return t.cfg
+}
+
+func newTestCache(cfg config.ResponseCacheConfig) (*Cache, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	c := New(&testConfig{cfg: cfg})
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func testRequest() util.StandardRequest {
+	return util.StandardRequest{Surface: "openai_chat", ResolvedModel: "deepseek-chat", FinalPrompt: "hi", PassThrough: map[string]any{}}
+}
+
+func TestKeyOnlyForDeterministicRequests(t *testing.T) {
+	c, _ := newTestCache(config.ResponseCacheConfig{Enabled: true})
+	key, ok := c.Key(testRequest())
+	if !ok || len(key) != 64 {
+		t.Fatalf("expected a sha256 key, got %q %v", key, ok)
+	}
+	if again, _ := c.Key(testRequest()); again != key {
+		t.Fatalf("expected identical requests to share a key")
+	}
+	other := testRequest()
+	other.FinalPrompt = "hello"
+	if k, _ := c.Key(other); k == key {
+		t.Fatalf("expected a different prompt to change the key")
+	}
+	zero := testRequest()
+	zero.PassThrough["temperature"] = 0.0
+	if _, ok := c.Key(zero); !ok {
+		t.Fatalf("expected temperature 0 to be cacheable")
+	}
+	for name, mutate := range map[string]func(*util.StandardRequest){
+		"temperature": func(r *util.StandardRequest) { r.PassThrough["temperature"] = 0.7 },
+		"search":      func(r *util.StandardRequest) { r.Search = true },
+		"choices":     func(r *util.StandardRequest) { r.Choices = 2 },
+		"tools":       func(r *util.StandardRequest) { r.ToolNames = []string{"search"} },
+		"tool_choice": func(r *util.StandardRequest) { r.ToolChoice = util.ToolChoicePolicy{Mode: util.ToolChoiceRequired} },
+	} {
+		req := testRequest()
+		mutate(&req)
+		if _, ok := c.Key(req); ok {
+			t.Fatalf("expected %s to make the request uncacheable", name)
+		}
+	}
+	for name, mutate := range map[string]func(*util.StandardRequest){
+		"template":       func(r *util.StandardRequest) { r.Template = prompt.DefaultChatTemplate() },
+		"reasoning_mode": func(r *util.StandardRequest) { r.ReasoningMode = util.ReasoningHidden },
+		"stop":           func(r *util.StandardRequest) { r.StopSequences = []string{"END"} },
+	} {
+		req := testRequest()
+		mutate(&req)
+		if k, ok := c.Key(req); !ok || k == key {
+			t.Fatalf("expected %s to change the key", name)
+		}
+	}
+	var disabled *Cache
+	if _, ok := disabled.Key(testRequest()); ok {
+		t.Fatalf("expected a nil cache to cache nothing")
+	}
+}
+
+// whole treats bodies ending in a blank line as complete streams.
+func whole(body []byte) bool {
+	return strings.HasSuffix(string(body), "\n\n")
+}
+
+func TestRecordStoresCompleteBodiesUntilExpiry(t *testing.T) {
+	for _, backend := range []string{"memory", "disk"} {
+		t.Run(backend, func(t *testing.T) {
+			c, now := newTestCache(config.ResponseCacheConfig{Enabled: true, Backend: backend, Dir: t.TempDir(), TTLSeconds: 60, MaxBytes: 1 << 20})
+			body := c.Record("k", io.NopCloser(strings.NewReader("data: a\n\n")), whole)
+			if b, _ := io.ReadAll(body); string(b) != "data: a\n\n" {
+				t.Fatalf("expected the body to pass through, got %q", b)
+			}
+			if _, ok := c.Get("k"); ok {
+				t.Fatalf("expected nothing stored before the body is closed")
+			}
+			_ = body.Close()
+			got, ok := c.Get("k")
+			if !ok || string(got) != "data: a\n\n" {
+				t.Fatalf("expected the stored body, got %q %v", got, ok)
+			}
+			*now = now.Add(61 * time.Second)
+			if _, ok := c.Get("k"); ok {
+				t.Fatalf("expected the entry to expire")
+			}
+		})
+	}
+}
+
+func TestRecordSkipsIncompleteAndOversizedBodies(t *testing.T) {
+	c, _ := newTestCache(config.ResponseCacheConfig{Enabled: true, Backend: "memory", TTLSeconds: 60, MaxBytes: 16})
+	partial := c.Record("partial", io.NopCloser(strings.NewReader("data: a\n\n")), whole)
+	_, _ = partial.Read(make([]byte, 3))
+	_ = partial.Close()
+	if _, ok := c.Get("partial"); ok {
+		t.Fatalf("expected a body closed early not to be stored")
+	}
+	big := c.Record("big", io.NopCloser(strings.NewReader("data: 0123456789\n\n")), whole)
+	_, _ = io.ReadAll(big)
+	_ = big.Close()
+	if _, ok := c.Get("big"); ok {
+		t.Fatalf("expected a body over max_bytes not to be stored")
+	}
+}
+
+func TestBackendsEvictToStayWithinMaxBytes(t *testing.T) {
+	expires := time.Unix(1_700_000_000, 0)
+	now := expires.Add(-time.Hour)
+	for name, b := range map[string]backend{"memory": newMemoryBackend(10), "disk": newDiskBackend(t.TempDir(), 10)} {
+		b.put("a", []byte("aaaa"), expires)
+		b.put("b", []byte("bbbb"), expires.Add(time.Second))
+		if name == "memory" {
+			b.get("a", now)
+		}
+		b.put("c", []byte("cccc"), expires.Add(2*time.Second))
+		_, hasA := b.get("a", now)
+		_, hasB := b.get("b", now)
+		if _, hasC := b.get("c", now); !hasC || hasA == hasB {
+			t.Fatalf("%s: expected one older entry evicted, got a=%v b=%v", name, hasA, hasB)
+		}
+		if name == "memory" && !hasA {
+			t.Fatalf("memory: expected the recently read entry to survive")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package respcache
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ds2api/internal/config"
+)
+
+// diskBackend keeps one file per key in dir, so cached bodies survive
+// restarts. A file's modification time is its expiry. Once the directory
+// grows past maxBytes, expired files go first and then those closest to
+// expiring.
+type diskBackend struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+}
+
+func newDiskBackend(dir string, maxBytes int64) *diskBackend {
+	return &diskBackend{dir: dir, maxBytes: maxBytes}
+}
+
+func (d *diskBackend) get(key string, now time.Time) ([]byte, bool) {
+	path := filepath.Join(d.dir, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if !now.Before(info.ModTime()) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+func (d *diskBackend) put(key string, body []byte, expires time.Time) {
+	if int64(len(body)) > d.maxBytes {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.write(key, body, expires); err != nil {
+		config.Logger.Warn("[response_cache] write failed", "dir", d.dir, "error", err)
+		return
+	}
+	d.evict()
+}
+
+// write stores body through a temporary file so readers never see a
+// partial entry.
+func (d *diskBackend) write(key string, body []byte, expires time.Time) error {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), expires, expires); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(d.dir, key))
+}
+
+func (d *diskBackend) evict() {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+	if size <= d.maxBytes {
+		return
+	}
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, info := range files {
+		if size <= d.maxBytes {
+			break
+		}
+		if os.Remove(filepath.Join(d.dir, info.Name())) == nil {
+			size -= info.Size()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package respcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memoryBackend keeps bodies in process, evicting the least recently used
+// entries once their total size exceeds maxBytes.
+type memoryBackend struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func newMemoryBackend(maxBytes int64) *memoryBackend {
+	return &memoryBackend{maxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (m *memoryBackend) get(key string, now time.Time) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if !now.Before(entry.expires) {
+		m.remove(el)
+		return nil, false
+	}
+	m.order.MoveToFront(el)
+	return entry.body, true
+}
+
+func (m *memoryBackend) put(key string, body []byte, expires time.Time) {
+	if int64(len(body)) > m.maxBytes {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, body: body, expires: expires})
+	m.size += int64(len(body))
+	for m.size > m.maxBytes {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *memoryBackend) remove(el *list.Element) {
+	entry := m.order.Remove(el).(*memoryEntry)
+	delete(m.entries, entry.key)
+	m.size -= int64(len(entry.body))
+}
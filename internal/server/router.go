@@ -16,8 +16,10 @@ import (
 	"ds2api/internal/adapter/openai"
 	"ds2api/internal/admin"
 	"ds2api/internal/auth"
+	"ds2api/internal/coalesce"
 	"ds2api/internal/config"
 	"ds2api/internal/deepseek"
+	"ds2api/internal/respcache"
 	"ds2api/internal/webui"
 )
 
@@ -43,9 +45,11 @@ func NewApp() *App {
 		config.Logger.Info("[WASM] module preloaded", "path", config.WASMPath())
 	}
 
-	openaiHandler := &openai.Handler{Store: store, Auth: resolver, DS: dsClient}
-	claudeHandler := &claude.Handler{Store: store, Auth: resolver, DS: dsClient}
-	geminiHandler := &gemini.Handler{Store: store, Auth: resolver, DS: dsClient}
+	cache := respcache.New(store)
+	flights := coalesce.New(store)
+	openaiHandler := &openai.Handler{Store: store, Auth: resolver, DS: dsClient, Cache: cache, Flights: flights}
+	claudeHandler := &claude.Handler{Store: store, Auth: resolver, DS: dsClient, Cache: cache, Flights: flights}
+	geminiHandler := &gemini.Handler{Store: store, Auth: resolver, DS: dsClient, Cache: cache, Flights: flights}
 	adminHandler := &admin.Handler{Store: store, Pool: pool, DS: dsClient}
 	webuiHandler := webui.NewHandler()
 
@@ -95,7 +99,8 @@ func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Ds2-Target-Account, X-Vercel-Protection-Bypass")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Ds2-Target-Account, X-Ds2-Session, X-Vercel-Protection-Bypass")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Ds2api-Context")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
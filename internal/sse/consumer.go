@@ -12,6 +12,12 @@ import (
 type CollectResult struct {
 	Text     string
 	Thinking string
+	// Limit is set when OutputLimits cut the stream before upstream finished.
+	Limit        LimitReason
+	StopSequence string
+	// SearchResults holds the web results seen on the stream. Text still
+	// carries the raw [citation:N] markers; see ResolveCitations.
+	SearchResults []SearchResult
 }
 
 // CollectStream fully consumes a DeepSeek SSE response and separates
@@ -21,32 +27,51 @@ type CollectResult struct {
 //
 // The caller is responsible for closing resp.Body unless closeBody is true.
 func CollectStream(resp *http.Response, thinkingEnabled bool, closeBody bool) CollectResult {
+	return CollectStreamWithLimits(resp, thinkingEnabled, closeBody, OutputLimits{})
+}
+
+// CollectStreamWithLimits is CollectStream with client-side OutputLimits
+// applied; reading stops as soon as a limit is reached.
+func CollectStreamWithLimits(resp *http.Response, thinkingEnabled bool, closeBody bool, limits OutputLimits) CollectResult {
 	if closeBody {
 		defer resp.Body.Close()
 	}
+	limiter := NewOutputLimiter(limits)
 	text := strings.Builder{}
 	thinking := strings.Builder{}
+	collect := func(parts []ContentPart) {
+		for _, p := range parts {
+			if p.Type == "thinking" {
+				thinking.WriteString(p.Text)
+			} else {
+				text.WriteString(p.Text)
+			}
+		}
+	}
 	currentType := "text"
 	if thinkingEnabled {
 		currentType = "thinking"
 	}
+	var searchResults []SearchResult
 	_ = deepseek.ScanSSELines(resp, func(line []byte) bool {
 		result := ParseDeepSeekContentLine(line, thinkingEnabled, currentType)
 		currentType = result.NextType
 		if !result.Parsed {
 			return true
 		}
+		searchResults = append(searchResults, result.SearchResults...)
 		if result.Stop {
 			return false
 		}
-		for _, p := range result.Parts {
-			if p.Type == "thinking" {
-				thinking.WriteString(p.Text)
-			} else {
-				text.WriteString(p.Text)
-			}
-		}
-		return true
+		collect(limiter.Apply(result.Parts))
+		return !limiter.Reached()
 	})
-	return CollectResult{Text: text.String(), Thinking: thinking.String()}
+	collect(limiter.Flush())
+	return CollectResult{
+		Text:          text.String(),
+		Thinking:      thinking.String(),
+		Limit:         limiter.Reason(),
+		StopSequence:  limiter.StopSequence(),
+		SearchResults: searchResults,
+	}
 }
@@ -10,6 +10,8 @@ type LineResult struct {
 	ErrorMessage  string
 	Parts         []ContentPart
 	NextType      string
+	// SearchResults are web results announced by this line, if any.
+	SearchResults []SearchResult
 }
 
 // ParseDeepSeekContentLine centralizes one-line DeepSeek SSE parsing for both
@@ -41,9 +43,10 @@ func ParseDeepSeekContentLine(raw []byte, thinkingEnabled bool, currentType stri
 	}
 	parts, finished, nextType := ParseSSEChunkForContent(chunk, thinkingEnabled, currentType)
 	return LineResult{
-		Parsed:   true,
-		Stop:     finished,
-		Parts:    parts,
-		NextType: nextType,
+		Parsed:        true,
+		Stop:          finished,
+		Parts:         parts,
+		NextType:      nextType,
+		SearchResults: extractSearchResults(chunk),
 	}
 }
@@ -0,0 +1,256 @@
+package sse
+
+import (
+	"strings"
+
+	"ds2api/internal/util"
+)
+
+// LimitReason tells why an OutputLimiter cut the stream short.
+type LimitReason string
+
+const (
+	LimitNone         LimitReason = ""
+	LimitStopSequence LimitReason = "stop_sequence"
+	LimitMaxTokens    LimitReason = "max_tokens"
+)
+
+// OutputLimits are the client-side generation bounds DeepSeek does not
+// enforce itself, so they are applied while the stream is consumed.
+type OutputLimits struct {
+	StopSequences []string
+	// MaxTokens caps the estimated output tokens; zero means no cap.
+	MaxTokens int
+	// CountThinking charges reasoning text against MaxTokens.
+	CountThinking bool
+	// Citations marks text that carries [citation:N] markers, which are
+	// stripped or rewritten before the client sees them and so are not
+	// charged against MaxTokens.
+	Citations bool
+}
+
+// LimitsFromRequest picks the locally enforced limits out of a normalized
+// request.
+func LimitsFromRequest(r util.StandardRequest) OutputLimits {
+	return OutputLimits{
+		StopSequences: r.StopSequences,
+		MaxTokens:     r.MaxOutputTokens,
+		CountThinking: r.MaxTokensIncludeThinking,
+		Citations:     r.Search,
+	}
+}
+
+// OutputLimiter applies OutputLimits to parsed content parts. Text that could
+// still be the start of a stop sequence is held back until the next chunk
+// proves otherwise, so matches are found across chunk boundaries. Released
+// text is then charged against the token budget with the same estimate used
+// for usage. A nil limiter passes every part through.
+type OutputLimiter struct {
+	stops         []string
+	maxTokens     int
+	countThinking bool
+	citations     bool
+
+	pending string
+	// marker is the start of a citation marker at the end of the text
+	// charged so far, held out of the count until it is known to be one.
+	marker   string
+	reason   LimitReason
+	matched  string
+	thinking util.TokenCounter
+	text     util.TokenCounter
+}
+
+func NewOutputLimiter(limits OutputLimits) *OutputLimiter {
+	stops := make([]string, 0, len(limits.StopSequences))
+	for _, s := range limits.StopSequences {
+		if s != "" {
+			stops = append(stops, s)
+		}
+	}
+	if len(stops) == 0 && limits.MaxTokens <= 0 {
+		return nil
+	}
+	return &OutputLimiter{stops: stops, maxTokens: limits.MaxTokens, countThinking: limits.CountThinking, citations: limits.Citations}
+}
+
+// Apply returns the parts that may be emitted now. Once a limit is reached it
+// returns only the text before the match and drops everything after.
+func (l *OutputLimiter) Apply(parts []ContentPart) []ContentPart {
+	if l == nil {
+		return parts
+	}
+	out := make([]ContentPart, 0, len(parts)+1)
+	for _, p := range parts {
+		if l.reason != LimitNone {
+			break
+		}
+		if p.Type == "thinking" {
+			out = append(out, l.charge(append(l.releasePending(), p))...)
+			continue
+		}
+		out = append(out, l.charge(l.applyText(p))...)
+	}
+	return out
+}
+
+// Flush releases text that was held back as a possible stop-sequence prefix.
+// Call it once upstream finishes without a match.
+func (l *OutputLimiter) Flush() []ContentPart {
+	if l == nil || l.reason != LimitNone {
+		return nil
+	}
+	return l.charge(l.releasePending())
+}
+
+// Reached reports whether a limit has stopped the stream.
+func (l *OutputLimiter) Reached() bool {
+	return l != nil && l.reason != LimitNone
+}
+
+func (l *OutputLimiter) Reason() LimitReason {
+	if l == nil {
+		return LimitNone
+	}
+	return l.reason
+}
+
+// StopSequence is the stop string that matched, if any.
+func (l *OutputLimiter) StopSequence() string {
+	if l == nil {
+		return ""
+	}
+	return l.matched
+}
+
+func (l *OutputLimiter) releasePending() []ContentPart {
+	if l.pending == "" {
+		return nil
+	}
+	text := l.pending
+	l.pending = ""
+	return []ContentPart{{Text: text, Type: "text"}}
+}
+
+// charge counts parts against the token budget and cuts at the first part
+// that does not fit. Running out of tokens wins over a stop sequence found in
+// the same batch, since the cut then lands before the match.
+func (l *OutputLimiter) charge(parts []ContentPart) []ContentPart {
+	if l.maxTokens <= 0 {
+		return parts
+	}
+	for i, p := range parts {
+		counter, other := &l.text, &l.thinking
+		if p.Type == "thinking" {
+			if !l.countThinking {
+				continue
+			}
+			counter, other = &l.thinking, &l.text
+		}
+		if !l.countThinking {
+			other = &util.TokenCounter{}
+		}
+		var n int
+		if p.Type == "thinking" {
+			n = counter.AddWithin(p.Text, l.maxTokens-other.Tokens())
+		} else {
+			n = l.addText(p.Text, l.maxTokens-other.Tokens())
+		}
+		if n == len(p.Text) {
+			continue
+		}
+		l.reason = LimitMaxTokens
+		l.matched = ""
+		l.pending = ""
+		out := parts[:i]
+		if n > 0 {
+			out = append(out, ContentPart{Text: p.Text[:n], Type: p.Type})
+		}
+		return out
+	}
+	return parts
+}
+
+// addText charges text against the text counter and returns how much of it
+// fits. With citations on, [citation:N] markers are not charged, including
+// markers split across parts.
+func (l *OutputLimiter) addText(text string, limit int) int {
+	if !l.citations {
+		return l.text.AddWithin(text, limit)
+	}
+	combined := l.marker + text
+	offset := len(l.marker)
+	l.marker = ""
+	fit := func(from, to int) (int, bool) {
+		n := l.text.AddWithin(combined[from:to], limit)
+		return max(from+n-offset, 0), n == to-from
+	}
+	last := 0
+	for _, loc := range citationMarkerPattern.FindAllStringIndex(combined, -1) {
+		if n, ok := fit(last, loc[0]); !ok {
+			return n
+		}
+		last = loc[1]
+	}
+	end := max(len(combined)-partialCitationLen(combined), last)
+	if n, ok := fit(last, end); !ok {
+		return n
+	}
+	l.marker = combined[end:]
+	return len(text)
+}
+
+func (l *OutputLimiter) applyText(p ContentPart) []ContentPart {
+	combined := l.pending + p.Text
+	if idx, stop := l.firstMatch(combined); idx >= 0 {
+		l.reason = LimitStopSequence
+		l.matched = stop
+		out := l.split(combined, idx, p.Type)
+		l.pending = ""
+		return out
+	}
+	keep := len(combined) - l.heldSuffixLen(combined)
+	out := l.split(combined, keep, p.Type)
+	l.pending = combined[keep:]
+	return out
+}
+
+// split emits combined[:cut] while keeping the boundary between previously
+// pending text and the new part, so per-part checks such as citation
+// detection still see the upstream chunk as it arrived.
+func (l *OutputLimiter) split(combined string, cut int, partType string) []ContentPart {
+	out := make([]ContentPart, 0, 2)
+	head := min(cut, len(l.pending))
+	if head > 0 {
+		out = append(out, ContentPart{Text: combined[:head], Type: "text"})
+	}
+	if cut > head {
+		out = append(out, ContentPart{Text: combined[head:cut], Type: partType})
+	}
+	return out
+}
+
+func (l *OutputLimiter) firstMatch(text string) (int, string) {
+	best, bestStop := -1, ""
+	for _, stop := range l.stops {
+		if idx := strings.Index(text, stop); idx >= 0 && (best < 0 || idx < best) {
+			best, bestStop = idx, stop
+		}
+	}
+	return best, bestStop
+}
+
+// heldSuffixLen is the length of the longest suffix of text that is a proper
+// prefix of some stop sequence.
+func (l *OutputLimiter) heldSuffixLen(text string) int {
+	held := 0
+	for _, stop := range l.stops {
+		for n := min(len(stop)-1, len(text)); n > held; n-- {
+			if strings.HasSuffix(text, stop[:n]) {
+				held = n
+				break
+			}
+		}
+	}
+	return held
+}
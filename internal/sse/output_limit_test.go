@@ -0,0 +1,130 @@
+package sse
+
+import "testing"
+
+func collectLimited(l *OutputLimiter, chunks ...string) (string, bool) {
+	out := ""
+	for _, c := range chunks {
+		for _, p := range l.Apply([]ContentPart{{Text: c, Type: "text"}}) {
+			out += p.Text
+		}
+		if l.Reached() {
+			return out, true
+		}
+	}
+	for _, p := range l.Flush() {
+		out += p.Text
+	}
+	return out, false
+}
+
+func TestOutputLimiterStopSequenceWithinChunk(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{StopSequences: []string{"END"}})
+	out, reached := collectLimited(l, "hello END world")
+	if !reached || out != "hello " {
+		t.Fatalf("expected cut before stop, got %q reached=%v", out, reached)
+	}
+	if l.Reason() != LimitStopSequence || l.StopSequence() != "END" {
+		t.Fatalf("unexpected reason=%q stop=%q", l.Reason(), l.StopSequence())
+	}
+}
+
+func TestOutputLimiterStopSequenceAcrossChunks(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{StopSequences: []string{"</answer>"}})
+	out, reached := collectLimited(l, "value</ans", "wer> trailing")
+	if !reached || out != "value" {
+		t.Fatalf("expected cross-chunk match, got %q reached=%v", out, reached)
+	}
+}
+
+func TestOutputLimiterReleasesHeldPrefixWithoutMatch(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{StopSequences: []string{"STOP"}})
+	out, reached := collectLimited(l, "abc ST", "ART", " end S")
+	if reached || out != "abc START end S" {
+		t.Fatalf("expected full text after flush, got %q reached=%v", out, reached)
+	}
+}
+
+func TestOutputLimiterPicksEarliestStop(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{StopSequences: []string{"bb", "a"}})
+	out, _ := collectLimited(l, "xxbbxa")
+	if out != "xx" || l.StopSequence() != "bb" {
+		t.Fatalf("expected earliest stop bb, got out=%q stop=%q", out, l.StopSequence())
+	}
+}
+
+func TestOutputLimiterPassesThinkingThrough(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{StopSequences: []string{"END"}})
+	parts := l.Apply([]ContentPart{{Text: "END in thought", Type: "thinking"}})
+	if len(parts) != 1 || parts[0].Text != "END in thought" || l.Reached() {
+		t.Fatalf("thinking should not trigger stop sequences, got %#v", parts)
+	}
+}
+
+func TestOutputLimiterNilPassesThrough(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{})
+	if l != nil {
+		t.Fatalf("expected nil limiter without limits")
+	}
+	parts := []ContentPart{{Text: "x", Type: "text"}}
+	if got := l.Apply(parts); len(got) != 1 || l.Reached() || l.Flush() != nil {
+		t.Fatalf("nil limiter should be a no-op")
+	}
+}
+
+func TestCollectStreamWithLimitsStopsAtStopSequence(t *testing.T) {
+	resp := makeHTTPResponse(
+		"data: {\"p\":\"response/content\",\"v\":\"Hello ##\"}\n" +
+			"data: {\"p\":\"response/content\",\"v\":\"# World\"}\n" +
+			"data: [DONE]\n",
+	)
+	result := CollectStreamWithLimits(resp, false, true, OutputLimits{StopSequences: []string{"###"}})
+	if result.Text != "Hello " || result.Limit != LimitStopSequence || result.StopSequence != "###" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+}
+
+func TestOutputLimiterMaxTokensCutsMidChunk(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{MaxTokens: 2})
+	out, reached := collectLimited(l, "abcdef", "ghijklmnop")
+	if !reached || out != "abcdefghijk" || l.Reason() != LimitMaxTokens {
+		t.Fatalf("expected cut at 2 tokens, got %q reached=%v reason=%q", out, reached, l.Reason())
+	}
+}
+
+func TestOutputLimiterMaxTokensSkipsCitationMarkers(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{MaxTokens: 2, Citations: true})
+	out, reached := collectLimited(l, "abcd[cita", "tion:1]efgh")
+	if reached || out != "abcd[citation:1]efgh" {
+		t.Fatalf("expected markers left out of the budget, got %q reached=%v", out, reached)
+	}
+	l = NewOutputLimiter(OutputLimits{MaxTokens: 2})
+	if out, reached := collectLimited(l, "abcd[cita", "tion:1]efgh"); !reached || out != "abcd[citati" {
+		t.Fatalf("expected markers charged without search, got %q reached=%v", out, reached)
+	}
+}
+
+func TestOutputLimiterMaxTokensCountsThinkingWhenAsked(t *testing.T) {
+	thought := ContentPart{Text: "abcdefgh", Type: "thinking"}
+	answer := ContentPart{Text: "ijklmnop", Type: "text"}
+
+	l := NewOutputLimiter(OutputLimits{MaxTokens: 3, CountThinking: true})
+	parts := l.Apply([]ContentPart{thought, answer})
+	if !l.Reached() || len(parts) != 2 || parts[1].Text != "ijklmno" {
+		t.Fatalf("expected thinking to consume budget, got %#v", parts)
+	}
+
+	l = NewOutputLimiter(OutputLimits{MaxTokens: 3})
+	parts = l.Apply([]ContentPart{thought, answer})
+	if l.Reached() || len(parts) != 2 || parts[1].Text != "ijklmnop" {
+		t.Fatalf("expected thinking to be free, got %#v", parts)
+	}
+}
+
+func TestOutputLimiterMaxTokensBeforeStopSequenceWins(t *testing.T) {
+	l := NewOutputLimiter(OutputLimits{StopSequences: []string{"END"}, MaxTokens: 1})
+	out, reached := collectLimited(l, "abcdefgh END")
+	if !reached || out != "abcdefg" || l.Reason() != LimitMaxTokens || l.StopSequence() != "" {
+		t.Fatalf("expected token cut before stop, got %q reason=%q stop=%q", out, l.Reason(), l.StopSequence())
+	}
+}
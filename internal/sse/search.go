@@ -0,0 +1,277 @@
+package sse
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SearchResult is one web page returned by DeepSeek's search. Index is the
+// number the model cites it by in [citation:N] markers.
+type SearchResult struct {
+	Index   int
+	URL     string
+	Title   string
+	Snippet string
+}
+
+// Citation links a span of the emitted text to the search result it cites.
+// Start and End are byte offsets into the text with markers removed.
+type Citation struct {
+	Result SearchResult
+	Start  int
+	End    int
+}
+
+var citationMarkerPattern = regexp.MustCompile(`\[citation:(\d+)\]`)
+
+var searchResultsPathPattern = regexp.MustCompile(`^response/fragments/-?\d+/results$`)
+
+// extractSearchResults picks search results out of one upstream chunk. They
+// arrive either on response/search_results or as SEARCH fragments, the latter
+// sometimes filled in later through response/fragments/N/results.
+func extractSearchResults(chunk map[string]any) []SearchResult {
+	path, _ := chunk["p"].(string)
+	v := chunk["v"]
+	switch {
+	case path == "response/search_results" || searchResultsPathPattern.MatchString(path):
+		items, _ := v.([]any)
+		return parseSearchResults(items)
+	case path == "response/fragments":
+		frags, _ := v.([]any)
+		return searchResultsFromFragments(frags)
+	case path == "response":
+		nested, _ := v.([]any)
+		var out []SearchResult
+		for _, it := range nested {
+			m, ok := it.(map[string]any)
+			if !ok || m["p"] != "fragments" {
+				continue
+			}
+			frags, _ := m["v"].([]any)
+			out = append(out, searchResultsFromFragments(frags)...)
+		}
+		return out
+	}
+	if m, ok := v.(map[string]any); ok {
+		if wrapped, ok := m["response"].(map[string]any); ok {
+			m = wrapped
+		}
+		frags, _ := m["fragments"].([]any)
+		return searchResultsFromFragments(frags)
+	}
+	return nil
+}
+
+func searchResultsFromFragments(frags []any) []SearchResult {
+	var out []SearchResult
+	for _, frag := range frags {
+		m, ok := frag.(map[string]any)
+		if !ok {
+			continue
+		}
+		if typeName, _ := m["type"].(string); !strings.EqualFold(typeName, "SEARCH") {
+			continue
+		}
+		items, _ := m["results"].([]any)
+		out = append(out, parseSearchResults(items)...)
+	}
+	return out
+}
+
+func parseSearchResults(items []any) []SearchResult {
+	out := make([]SearchResult, 0, len(items))
+	for i, it := range items {
+		m, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		url, _ := m["url"].(string)
+		if strings.TrimSpace(url) == "" {
+			continue
+		}
+		index := i + 1
+		if n, ok := m["cite_index"].(float64); ok && n > 0 {
+			index = int(n)
+		}
+		title, _ := m["title"].(string)
+		snippet, _ := m["snippet"].(string)
+		out = append(out, SearchResult{Index: index, URL: url, Title: title, Snippet: snippet})
+	}
+	return out
+}
+
+// SearchCitations collects the search results of one stream and turns the
+// [citation:N] markers in its text into Citations. A cited span runs from the
+// previous line break or citation up to the marker; consecutive markers share
+// the same span. A nil tracker leaves text untouched.
+//
+// A marker split across chunks is held back until the chunk that completes
+// it; Flush releases whatever is still held once the stream ends.
+type SearchCitations struct {
+	results   map[int]SearchResult
+	citations []Citation
+	emitted   int
+	spanStart int
+	lastStart int
+	lastEnd   int
+	pending   string
+	flushed   bool
+}
+
+func NewSearchCitations() *SearchCitations {
+	return &SearchCitations{results: map[int]SearchResult{}, lastEnd: -1}
+}
+
+func (c *SearchCitations) AddResults(results []SearchResult) {
+	if c == nil {
+		return
+	}
+	for _, r := range results {
+		c.results[r.Index] = r
+	}
+}
+
+// Strip removes citation markers from the next piece of emitted text and
+// returns the visible text with the citations it completed. Markers for
+// results that were never seen are dropped without a citation.
+func (c *SearchCitations) Strip(text string) (string, []Citation) {
+	if c == nil {
+		return text, nil
+	}
+	text = c.pending + text
+	c.pending = ""
+	if !c.flushed {
+		keep := len(text) - partialCitationLen(text)
+		text, c.pending = text[:keep], text[keep:]
+	}
+	var out strings.Builder
+	var added []Citation
+	last := 0
+	for _, loc := range citationMarkerPattern.FindAllStringSubmatchIndex(text, -1) {
+		c.advance(text[last:loc[0]], &out)
+		last = loc[1]
+		n, _ := strconv.Atoi(text[loc[2]:loc[3]])
+		result, ok := c.results[n]
+		if !ok {
+			continue
+		}
+		start := c.spanStart
+		if c.lastEnd == c.emitted {
+			start = c.lastStart
+		}
+		cite := Citation{Result: result, Start: start, End: c.emitted}
+		c.citations = append(c.citations, cite)
+		added = append(added, cite)
+		c.lastStart, c.lastEnd = start, c.emitted
+		c.spanStart = c.emitted
+	}
+	c.advance(text[last:], &out)
+	return out.String(), added
+}
+
+// Flush ends the stream: it returns the text still held back as a possible
+// marker, and later calls to Strip hold nothing back.
+func (c *SearchCitations) Flush() []ContentPart {
+	if c == nil || c.flushed {
+		return nil
+	}
+	c.flushed = true
+	text := c.pending
+	c.pending = ""
+	if text == "" {
+		return nil
+	}
+	return []ContentPart{{Text: text, Type: "text"}}
+}
+
+// partialCitationLen is the length of the trailing "[citation:N" prefix of
+// text that the next chunk may still complete into a marker.
+func partialCitationLen(text string) int {
+	const marker = "[citation:"
+	i := strings.LastIndexByte(text, '[')
+	if i < 0 {
+		return 0
+	}
+	tail := text[i:]
+	if len(tail) <= len(marker) {
+		if strings.HasPrefix(marker, tail) {
+			return len(tail)
+		}
+		return 0
+	}
+	if !strings.HasPrefix(tail, marker) {
+		return 0
+	}
+	for _, r := range tail[len(marker):] {
+		if r < '0' || r > '9' {
+			return 0
+		}
+	}
+	return len(tail)
+}
+
+func (c *SearchCitations) advance(segment string, out *strings.Builder) {
+	if i := strings.LastIndexByte(segment, '\n'); i >= 0 {
+		c.spanStart = c.emitted + i + 1
+	}
+	c.emitted += len(segment)
+	out.WriteString(segment)
+}
+
+// Results returns every captured result ordered by citation index.
+func (c *SearchCitations) Results() []SearchResult {
+	if c == nil {
+		return nil
+	}
+	out := make([]SearchResult, 0, len(c.results))
+	for _, r := range c.results {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Index < out[j].Index })
+	return out
+}
+
+func (c *SearchCitations) Citations() []Citation {
+	if c == nil {
+		return nil
+	}
+	return c.citations
+}
+
+// ResolveCitations strips the markers from fully collected text, for the
+// non-streaming paths. With search off the text is returned as written, the
+// way the streaming paths leave it.
+func ResolveCitations(text string, results []SearchResult, search bool) (string, []Citation) {
+	visible, c := ResolveSearchCitations(text, results, search)
+	return visible, c.Citations()
+}
+
+// ResolveSearchCitations is ResolveCitations returning the tracker, for
+// callers that also need its results. The tracker is nil with search off.
+func ResolveSearchCitations(text string, results []SearchResult, search bool) (string, *SearchCitations) {
+	if !search {
+		return text, nil
+	}
+	c := NewSearchCitations()
+	c.AddResults(results)
+	c.Flush()
+	visible, _ := c.Strip(text)
+	return visible, c
+}
+
+// OffsetCitations moves citations n bytes later, for text that gets a prefix
+// such as inline reasoning.
+func OffsetCitations(citations []Citation, n int) []Citation {
+	if n == 0 || len(citations) == 0 {
+		return citations
+	}
+	out := make([]Citation, len(citations))
+	for i, c := range citations {
+		c.Start += n
+		c.End += n
+		out[i] = c
+	}
+	return out
+}
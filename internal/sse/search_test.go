@@ -0,0 +1,101 @@
+package sse
+
+import "testing"
+
+func TestParseDeepSeekContentLineCapturesSearchResults(t *testing.T) {
+	line := []byte(`data: {"p":"response/search_results","v":[{"url":"https://a.example","title":"A","snippet":"alpha","cite_index":2},{"url":"","title":"empty"}]}`)
+	res := ParseDeepSeekContentLine(line, false, "text")
+	if len(res.SearchResults) != 1 {
+		t.Fatalf("expected one result, got %#v", res.SearchResults)
+	}
+	got := res.SearchResults[0]
+	if got.Index != 2 || got.URL != "https://a.example" || got.Title != "A" || got.Snippet != "alpha" {
+		t.Fatalf("unexpected result %#v", got)
+	}
+	if len(res.Parts) != 0 {
+		t.Fatalf("search results must not become content, got %#v", res.Parts)
+	}
+}
+
+func TestExtractSearchResultsFromSearchFragment(t *testing.T) {
+	chunk := map[string]any{
+		"p": "response/fragments",
+		"o": "APPEND",
+		"v": []any{map[string]any{
+			"type":    "SEARCH",
+			"results": []any{map[string]any{"url": "https://b.example", "title": "B"}},
+		}},
+	}
+	got := extractSearchResults(chunk)
+	if len(got) != 1 || got[0].Index != 1 || got[0].URL != "https://b.example" {
+		t.Fatalf("unexpected results %#v", got)
+	}
+}
+
+func TestSearchCitationsStripAcrossChunks(t *testing.T) {
+	c := NewSearchCitations()
+	c.AddResults([]SearchResult{{Index: 1, URL: "https://a.example"}, {Index: 2, URL: "https://b.example"}})
+
+	var visible string
+	var cites []Citation
+	for _, chunk := range []string{"intro\nfirst fact", "[citation:1][citation:2]", " second", "[citation:9]."} {
+		text, added := c.Strip(chunk)
+		visible += text
+		cites = append(cites, added...)
+	}
+	if visible != "intro\nfirst fact second." {
+		t.Fatalf("unexpected visible text %q", visible)
+	}
+	if len(cites) != 2 {
+		t.Fatalf("expected two citations, got %#v", cites)
+	}
+	for _, cite := range cites {
+		if visible[cite.Start:cite.End] != "first fact" {
+			t.Fatalf("unexpected span %q for %#v", visible[cite.Start:cite.End], cite)
+		}
+	}
+	if len(c.Citations()) != 2 || len(c.Results()) != 2 {
+		t.Fatalf("unexpected tracker state %#v", c)
+	}
+}
+
+func TestSearchCitationsStripMarkerSplitInside(t *testing.T) {
+	c := NewSearchCitations()
+	c.AddResults([]SearchResult{{Index: 3, URL: "https://c.example"}})
+
+	var visible string
+	var cites []Citation
+	for _, chunk := range []string{"fact[cit", "ation:", "3] more [", "x] tail["} {
+		text, added := c.Strip(chunk)
+		visible += text
+		cites = append(cites, added...)
+	}
+	for _, p := range c.Flush() {
+		text, _ := c.Strip(p.Text)
+		visible += text
+	}
+	if visible != "fact more [x] tail[" {
+		t.Fatalf("unexpected visible text %q", visible)
+	}
+	if len(cites) != 1 || visible[cites[0].Start:cites[0].End] != "fact" {
+		t.Fatalf("expected the split marker to cite %q, got %#v", "fact", cites)
+	}
+}
+
+func TestSearchCitationsDropsPartialMarker(t *testing.T) {
+	c := NewSearchCitations()
+	if text, _ := c.Strip("[citation:"); text != "" {
+		t.Fatalf("expected partial marker dropped, got %q", text)
+	}
+	var nilTracker *SearchCitations
+	if text, _ := nilTracker.Strip("a[citation:1]"); text != "a[citation:1]" {
+		t.Fatalf("nil tracker must pass text through, got %q", text)
+	}
+}
+
+func TestResolveCitationsForCollectedText(t *testing.T) {
+	text, cites := ResolveCitations("sky is blue[citation:1]", []SearchResult{{Index: 1, URL: "https://a.example"}}, true)
+	if text != "sky is blue" || len(cites) != 1 || cites[0].Start != 0 || cites[0].End != len(text) {
+		t.Fatalf("unexpected resolve result %q %#v", text, cites)
+	}
+}
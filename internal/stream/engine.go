@@ -17,6 +17,9 @@ const (
 	StopReasonIdleTimeout       StopReason = "idle_timeout"
 	StopReasonUpstreamCompleted StopReason = "upstream_completed"
 	StopReasonHandlerRequested  StopReason = "handler_requested"
+	// StopReasonOutputLimit means a locally enforced stop sequence or token
+	// budget ended the stream before upstream did.
+	StopReasonOutputLimit StopReason = "output_limit"
 )
 
 type ConsumeConfig struct {
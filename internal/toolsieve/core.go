@@ -1,4 +1,4 @@
-package openai
+package toolsieve
 
 import (
 	"strings"
@@ -6,14 +6,16 @@ import (
 	"ds2api/internal/util"
 )
 
-func processToolSieveChunk(state *toolStreamSieveState, chunk string, toolNames []string) []toolStreamEvent {
+// Process feeds chunk through the sieve and returns what can be released.
+// Only calls to toolNames are returned as tool calls.
+func (state *State) Process(chunk string, toolNames []string) []Event {
 	if state == nil {
 		return nil
 	}
 	if chunk != "" {
 		state.pending.WriteString(chunk)
 	}
-	events := make([]toolStreamEvent, 0, 2)
+	events := make([]Event, 0, 2)
 
 	for {
 		if state.capturing {
@@ -22,17 +24,19 @@ func processToolSieveChunk(state *toolStreamSieveState, chunk string, toolNames
 				state.pending.Reset()
 			}
 			if deltas := buildIncrementalToolDeltas(state); len(deltas) > 0 {
-				events = append(events, toolStreamEvent{ToolCallDeltas: deltas})
+				events = append(events, Event{ToolCallDeltas: deltas})
 			}
 			prefix, calls, suffix, ready := consumeToolCapture(state, toolNames)
 			if !ready {
-				if state.capture.Len() > toolSieveCaptureLimit {
+				// A call whose name has been streamed is committed to, so
+				// long arguments (a file being written) are not cut off.
+				if state.capture.Len() > toolSieveCaptureLimit && !state.toolNameSent {
 					content := state.capture.String()
 					state.capture.Reset()
 					state.capturing = false
 					state.resetIncrementalToolState()
 					state.noteText(content)
-					events = append(events, toolStreamEvent{Content: content})
+					events = append(events, Event{Content: content})
 					continue
 				}
 				break
@@ -42,10 +46,10 @@ func processToolSieveChunk(state *toolStreamSieveState, chunk string, toolNames
 			state.resetIncrementalToolState()
 			if prefix != "" {
 				state.noteText(prefix)
-				events = append(events, toolStreamEvent{Content: prefix})
+				events = append(events, Event{Content: prefix})
 			}
 			if len(calls) > 0 {
-				events = append(events, toolStreamEvent{ToolCalls: calls})
+				events = append(events, Event{ToolCalls: calls})
 			}
 			if suffix != "" {
 				state.pending.WriteString(suffix)
@@ -57,12 +61,12 @@ func processToolSieveChunk(state *toolStreamSieveState, chunk string, toolNames
 		if pending == "" {
 			break
 		}
-		start := findToolSegmentStart(pending)
+		start := findToolSegmentStart(pending, state.Dialects)
 		if start >= 0 {
 			prefix := pending[:start]
 			if prefix != "" {
 				state.noteText(prefix)
-				events = append(events, toolStreamEvent{Content: prefix})
+				events = append(events, Event{Content: prefix})
 			}
 			state.pending.Reset()
 			state.capture.WriteString(pending[start:])
@@ -71,43 +75,46 @@ func processToolSieveChunk(state *toolStreamSieveState, chunk string, toolNames
 			continue
 		}
 
-		safe, hold := splitSafeContentForToolDetection(pending)
+		safe, hold := splitSafeContentForToolDetection(pending, state.Dialects)
 		if safe == "" {
 			break
 		}
 		state.pending.Reset()
 		state.pending.WriteString(hold)
 		state.noteText(safe)
-		events = append(events, toolStreamEvent{Content: safe})
+		events = append(events, Event{Content: safe})
 	}
 
 	return events
 }
 
-func flushToolSieve(state *toolStreamSieveState, toolNames []string) []toolStreamEvent {
+// Flush releases everything the sieve still holds at the end of the output.
+func (state *State) Flush(toolNames []string) []Event {
 	if state == nil {
 		return nil
 	}
-	events := processToolSieveChunk(state, "", toolNames)
+	events := state.Process("", toolNames)
 	if state.capturing {
+		state.flushing = true
 		consumedPrefix, consumedCalls, consumedSuffix, ready := consumeToolCapture(state, toolNames)
+		state.flushing = false
 		if ready {
 			if consumedPrefix != "" {
 				state.noteText(consumedPrefix)
-				events = append(events, toolStreamEvent{Content: consumedPrefix})
+				events = append(events, Event{Content: consumedPrefix})
 			}
 			if len(consumedCalls) > 0 {
-				events = append(events, toolStreamEvent{ToolCalls: consumedCalls})
+				events = append(events, Event{ToolCalls: consumedCalls})
 			}
 			if consumedSuffix != "" {
 				state.noteText(consumedSuffix)
-				events = append(events, toolStreamEvent{Content: consumedSuffix})
+				events = append(events, Event{Content: consumedSuffix})
 			}
 		} else {
 			content := state.capture.String()
 			if content != "" {
 				state.noteText(content)
-				events = append(events, toolStreamEvent{Content: content})
+				events = append(events, Event{Content: content})
 			}
 		}
 		state.capture.Reset()
@@ -117,17 +124,20 @@ func flushToolSieve(state *toolStreamSieveState, toolNames []string) []toolStrea
 	if state.pending.Len() > 0 {
 		content := state.pending.String()
 		state.noteText(content)
-		events = append(events, toolStreamEvent{Content: content})
+		events = append(events, Event{Content: content})
 		state.pending.Reset()
 	}
 	return events
 }
 
-func splitSafeContentForToolDetection(s string) (safe, hold string) {
+func splitSafeContentForToolDetection(s string, dialects util.ToolDialects) (safe, hold string) {
 	if s == "" {
 		return "", ""
 	}
 	suspiciousStart := findSuspiciousPrefixStart(s)
+	if tagStart := util.ToolTagPrefixStart(s, dialects); tagStart >= 0 && (suspiciousStart < 0 || tagStart < suspiciousStart) {
+		suspiciousStart = tagStart
+	}
 	if suspiciousStart < 0 {
 		return s, ""
 	}
@@ -154,14 +164,25 @@ func findSuspiciousPrefixStart(s string) int {
 	return start
 }
 
-func findToolSegmentStart(s string) int {
+func findToolSegmentStart(s string, dialects util.ToolDialects) int {
 	if s == "" {
 		return -1
 	}
+	jsonStart := -1
+	if dialects.Has(util.ToolDialectJSON) {
+		jsonStart = findToolJSONSegmentStart(s)
+	}
+	if tagStart := findToolTagSegmentStart(s, dialects); tagStart >= 0 && (jsonStart < 0 || tagStart < jsonStart) {
+		return tagStart
+	}
+	return jsonStart
+}
+
+func findToolJSONSegmentStart(s string) int {
 	lower := strings.ToLower(s)
 	offset := 0
 	for {
-		keyRel := strings.Index(lower[offset:], "tool_calls")
+		keyRel, keyLen := findToolJSONKey(lower[offset:])
 		if keyRel < 0 {
 			return -1
 		}
@@ -173,17 +194,29 @@ func findToolSegmentStart(s string) int {
 		if !insideCodeFence(s[:start]) {
 			return start
 		}
-		offset = keyIdx + len("tool_calls")
+		offset = keyIdx + keyLen
 	}
 }
 
-func consumeToolCapture(state *toolStreamSieveState, toolNames []string) (prefix string, calls []util.ParsedToolCall, suffix string, ready bool) {
+// findToolJSONKey returns the first key that marks a JSON tool call in lower:
+// "tool_calls", or an OpenAI-style "function_call" object.
+func findToolJSONKey(lower string) (int, int) {
+	idx, keyLen := strings.Index(lower, "tool_calls"), len("tool_calls")
+	if fc := strings.Index(lower, `"function_call"`); fc >= 0 && (idx < 0 || fc < idx) {
+		idx, keyLen = fc, len(`"function_call"`)
+	}
+	return idx, keyLen
+}
+
+func consumeToolCapture(state *State, toolNames []string) (prefix string, calls []util.ParsedToolCall, suffix string, ready bool) {
 	captured := state.capture.String()
 	if captured == "" {
 		return "", nil, "", false
 	}
-	lower := strings.ToLower(captured)
-	keyIdx := strings.Index(lower, "tool_calls")
+	if tagStart, ok := state.toolTagCaptureStart(captured); ok {
+		return consumeToolTagCapture(state, toolNames, captured, tagStart)
+	}
+	keyIdx, _ := findToolJSONKey(strings.ToLower(captured))
 	if keyIdx < 0 {
 		return "", nil, "", false
 	}
@@ -200,7 +233,7 @@ func consumeToolCapture(state *toolStreamSieveState, toolNames []string) (prefix
 	if insideCodeFence(state.recentTextTail + prefixPart) {
 		return captured, nil, "", true
 	}
-	parsed := util.ParseStandaloneToolCallsDetailed(obj, toolNames)
+	parsed := util.ParseStandaloneToolCallsDetailedWith(obj, toolNames, state.Dialects)
 	if len(parsed.Calls) == 0 {
 		if parsed.SawToolCallSyntax && parsed.RejectedByPolicy {
 			// Parsed as tool-call payload but rejected by schema/policy:
@@ -1,8 +1,8 @@
-package openai
+package toolsieve
 
 import "strings"
 
-func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
+func buildIncrementalToolDeltas(state *State) []CallDelta {
 	if state.disableDeltas {
 		return nil
 	}
@@ -10,6 +10,12 @@ func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
 	if captured == "" {
 		return nil
 	}
+	if _, ok := state.toolTagCaptureStart(captured); ok {
+		// Tag dialects carry no JSON to stream; the run is sent whole once
+		// its closing tag arrives.
+		state.disableDeltas = true
+		return nil
+	}
 	lower := strings.ToLower(captured)
 	keyIdx := strings.Index(lower, "tool_calls")
 	if keyIdx < 0 {
@@ -24,19 +30,23 @@ func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
 	}
 	certainSingle, hasMultiple := classifyToolCallsIncrementalSafety(captured, keyIdx)
 	if hasMultiple {
-		state.disableDeltas = true
-		return nil
-	}
-	if !certainSingle {
-		// In uncertain phases (e.g. first call arrived but array not closed yet),
-		// avoid speculative deltas and wait for final parsed tool_calls payload.
+		if !state.toolNameSent {
+			state.disableDeltas = true
+			return nil
+		}
+		// The first call is already streaming: finish its (now complete)
+		// arguments, then stop.
+		defer func() { state.disableDeltas = true }()
+	} else if !certainSingle && !state.StreamOpenCall {
+		// Until the array closes another call may follow; wait for the final
+		// parsed tool_calls payload.
 		return nil
 	}
 	callStart, ok := findFirstToolCallObjectStart(captured, keyIdx)
 	if !ok {
 		return nil
 	}
-	deltas := make([]toolCallDelta, 0, 2)
+	deltas := make([]CallDelta, 0, 2)
 	if state.toolName == "" {
 		name, ok := extractToolCallName(captured, callStart)
 		if !ok || name == "" {
@@ -61,7 +71,7 @@ func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
 			return nil
 		}
 		state.toolNameSent = true
-		deltas = append(deltas, toolCallDelta{Index: 0, Name: state.toolName})
+		deltas = append(deltas, CallDelta{Index: 0, Name: state.toolName})
 	}
 	if state.toolArgsStart < 0 || state.toolArgsDone {
 		return deltas
@@ -71,9 +81,10 @@ func buildIncrementalToolDeltas(state *toolStreamSieveState) []toolCallDelta {
 		return deltas
 	}
 	if end > state.toolArgsSent {
-		deltas = append(deltas, toolCallDelta{
+		deltas = append(deltas, CallDelta{
 			Index:     0,
 			Arguments: captured[state.toolArgsSent:end],
+			Escaped:   state.toolArgsString,
 		})
 		state.toolArgsSent = end
 	}
@@ -281,11 +292,3 @@ func scanToolCallArgsProgress(text string, start int, stringMode bool) (int, boo
 	}
 	return len(text), false, true
 }
-
-func findFunctionObjectStart(text string, callStart int) (int, bool) {
-	valueStart, ok := findObjectFieldValueStart(text, callStart, []string{"function"})
-	if !ok || valueStart >= len(text) || text[valueStart] != '{' {
-		return -1, false
-	}
-	return valueStart, true
-}
@@ -1,4 +1,4 @@
-package openai
+package toolsieve
 
 import "strings"
 
@@ -105,6 +105,14 @@ func findObjectFieldValueStart(text string, objStart int, keys []string) (int, b
 	return 0, false
 }
 
+func findFunctionObjectStart(text string, callStart int) (int, bool) {
+	valueStart, ok := findObjectFieldValueStart(text, callStart, []string{"function"})
+	if !ok || valueStart >= len(text) || text[valueStart] != '{' {
+		return -1, false
+	}
+	return valueStart, true
+}
+
 func parseJSONStringLiteral(text string, start int) (string, int, bool) {
 	if start < 0 || start >= len(text) || text[start] != '"' {
 		return "", 0, false
@@ -0,0 +1,86 @@
+package toolsieve
+
+import (
+	"strings"
+	"testing"
+)
+
+func runSieve(state *State, chunks []string, toolNames []string) []Event {
+	var events []Event
+	for _, chunk := range chunks {
+		events = append(events, state.Process(chunk, toolNames)...)
+	}
+	return append(events, state.Flush(toolNames)...)
+}
+
+func collectSieved(events []Event) (text, args string, names []string, calls int) {
+	var b, a strings.Builder
+	for _, evt := range events {
+		b.WriteString(evt.Content)
+		for _, d := range evt.ToolCallDeltas {
+			if d.Name != "" {
+				names = append(names, d.Name)
+			}
+			a.WriteString(d.Arguments)
+		}
+		calls += len(evt.ToolCalls)
+	}
+	return b.String(), a.String(), names, calls
+}
+
+func TestSieveReleasesProseBeforeCall(t *testing.T) {
+	events := runSieve(&State{}, []string{"Let me look.\n", `{"tool_calls":[{"name":"search","input":{"q":"go"}}]}`}, []string{"search"})
+	text, _, _, calls := collectSieved(events)
+	if strings.TrimSpace(text) != "Let me look." || calls != 1 {
+		t.Fatalf("expected prose then one call, got text=%q calls=%d", text, calls)
+	}
+	if events[0].Content == "" {
+		t.Fatalf("expected prose to be released before the call, got %#v", events[0])
+	}
+}
+
+func TestSieveStreamOpenCallArguments(t *testing.T) {
+	chunks := []string{`{"tool_calls":[{"name":"write","input":{"path":"a.txt",`, `"body":"hello`, ` world"}}]}`}
+	state := &State{StreamOpenCall: true}
+	var early []Event
+	for _, chunk := range chunks[:2] {
+		early = append(early, state.Process(chunk, []string{"write"})...)
+	}
+	_, args, names, _ := collectSieved(early)
+	if len(names) != 1 || names[0] != "write" || !strings.HasPrefix(args, `{"path":"a.txt","body":"hello`) {
+		t.Fatalf("expected the open call to stream, got names=%v args=%q", names, args)
+	}
+	rest := append(state.Process(chunks[2], []string{"write"}), state.Flush([]string{"write"})...)
+	_, tail, _, calls := collectSieved(rest)
+	if args+tail != `{"path":"a.txt","body":"hello world"}` || calls != 1 {
+		t.Fatalf("expected the arguments streamed exactly once, got %q and %d calls", args+tail, calls)
+	}
+
+	_, args, names, _ = collectSieved(runSieve(&State{}, chunks[:2], []string{"write"}))
+	if len(names) != 0 || args != "" {
+		t.Fatalf("expected no deltas before the array closes by default, got %v %q", names, args)
+	}
+}
+
+func TestSieveStreamOpenCallStopsAtSecondCall(t *testing.T) {
+	state := &State{StreamOpenCall: true}
+	events := runSieve(state, []string{
+		`{"tool_calls":[{"name":"a","input":{"x":`,
+		`1}},{"name":"b","input":{"y":2}}]}`,
+	}, []string{"a", "b"})
+	_, args, names, calls := collectSieved(events)
+	if len(names) != 1 || args != `{"x":1}` || calls != 2 {
+		t.Fatalf("expected only the first call streamed and both returned, got names=%v args=%q calls=%d", names, args, calls)
+	}
+}
+
+func TestSieveKeepsLongStreamingCall(t *testing.T) {
+	body := strings.Repeat("x", toolSieveCaptureLimit+100)
+	events := runSieve(&State{StreamOpenCall: true}, []string{
+		`{"tool_calls":[{"name":"write","input":{"body":"`, body, `"}}]}`,
+	}, []string{"write"})
+	text, _, _, calls := collectSieved(events)
+	if text != "" || calls != 1 {
+		t.Fatalf("expected a long streaming call to stay a call, got %d bytes of text and %d calls", len(text), calls)
+	}
+}
@@ -0,0 +1,94 @@
+// Package toolsieve separates tool calls from the text of a streaming model
+// output. Text is released as soon as it cannot be part of a call, and a
+// single JSON call streams its name and arguments while they arrive.
+package toolsieve
+
+import (
+	"strings"
+
+	"ds2api/internal/util"
+)
+
+// State is the sieve of one output stream. The zero value reads JSON calls
+// only; set Dialects before the first chunk to read other styles.
+type State struct {
+	Dialects util.ToolDialects
+	// StreamOpenCall streams the first call of a tool_calls array while it
+	// is written instead of once the array closes. If another call follows,
+	// deltas stop and the final event carries every call.
+	StreamOpenCall bool
+
+	pending        strings.Builder
+	capture        strings.Builder
+	capturing      bool
+	flushing       bool
+	recentTextTail string
+	disableDeltas  bool
+	toolNameSent   bool
+	toolName       string
+	toolArgsStart  int
+	toolArgsSent   int
+	toolArgsString bool
+	toolArgsDone   bool
+}
+
+// Event is one step of sieved output: text to release, complete tool calls,
+// or the name and argument fragments of a call still being written.
+type Event struct {
+	Content        string
+	ToolCalls      []util.ParsedToolCall
+	ToolCallDeltas []CallDelta
+}
+
+// CallDelta is a fragment of a streaming call. The first delta of a call
+// carries its Name, later ones carry Arguments as the model wrote them.
+// Escaped is set when the arguments are a JSON string, so Arguments holds
+// escaped string content rather than raw JSON.
+type CallDelta struct {
+	Index     int
+	Name      string
+	Arguments string
+	Escaped   bool
+}
+
+const toolSieveCaptureLimit = 8 * 1024
+const toolSieveContextTailLimit = 256
+
+func (s *State) resetIncrementalToolState() {
+	s.disableDeltas = false
+	s.toolNameSent = false
+	s.toolName = ""
+	s.toolArgsStart = -1
+	s.toolArgsSent = -1
+	s.toolArgsString = false
+	s.toolArgsDone = false
+}
+
+func (s *State) noteText(content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	s.recentTextTail = appendTail(s.recentTextTail, content, toolSieveContextTailLimit)
+}
+
+func appendTail(prev, next string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	combined := prev + next
+	if len(combined) <= max {
+		return combined
+	}
+	return combined[len(combined)-max:]
+}
+
+func looksLikeToolExampleContext(text string) bool {
+	return insideCodeFence(text)
+}
+
+func insideCodeFence(text string) bool {
+	if text == "" {
+		return false
+	}
+	return strings.Count(text, "```")%2 == 1
+}
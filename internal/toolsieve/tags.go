@@ -0,0 +1,63 @@
+package toolsieve
+
+import "ds2api/internal/util"
+
+// findToolTagSegmentStart returns where the first tool-call tag outside a
+// code fence opens, or -1. Only the XML and function-tag dialects have tags.
+func findToolTagSegmentStart(s string, dialects util.ToolDialects) int {
+	if !dialects.Has(util.ToolDialectXML) && !dialects.Has(util.ToolDialectFunctionTag) {
+		return -1
+	}
+	offset := 0
+	for offset < len(s) {
+		rel := util.FindToolTagStart(s[offset:], dialects)
+		if rel < 0 {
+			return -1
+		}
+		start := offset + rel
+		if !insideCodeFence(s[:start]) {
+			return start
+		}
+		offset = start + 1
+	}
+	return -1
+}
+
+// toolTagCaptureStart reports whether the capture holds a tool-call tag
+// rather than a JSON payload, and where the tag opens. A JSON payload that
+// starts first wins.
+func (s *State) toolTagCaptureStart(captured string) (int, bool) {
+	tagStart := findToolTagSegmentStart(captured, s.Dialects)
+	if tagStart < 0 {
+		return -1, false
+	}
+	if s.Dialects.Has(util.ToolDialectJSON) {
+		if jsonStart := findToolJSONSegmentStart(captured); jsonStart >= 0 && jsonStart < tagStart {
+			return -1, false
+		}
+	}
+	return tagStart, true
+}
+
+// consumeToolTagCapture waits for the run of tool-call tags opening at start
+// to close, then parses it as one batch so consecutive calls share a single
+// tool_calls event.
+func consumeToolTagCapture(state *State, toolNames []string, captured string, start int) (prefix string, calls []util.ParsedToolCall, suffix string, ready bool) {
+	end, ok := util.ToolTagRunEnd(captured, start, state.Dialects, state.flushing)
+	if !ok {
+		return "", nil, "", false
+	}
+	prefixPart := captured[:start]
+	suffixPart := captured[end:]
+	if insideCodeFence(state.recentTextTail + prefixPart) {
+		return captured, nil, "", true
+	}
+	parsed := util.ParseStandaloneToolCallsDetailedWith(captured[start:end], toolNames, state.Dialects)
+	if len(parsed.Calls) == 0 {
+		if parsed.SawToolCallSyntax && parsed.RejectedByPolicy {
+			return prefixPart, nil, suffixPart, true
+		}
+		return captured, nil, "", true
+	}
+	return prefixPart, parsed.Calls, suffixPart, true
+}
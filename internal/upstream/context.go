@@ -0,0 +1,63 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/prompt"
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+const historySummaryInstruction = "Summarize the conversation below for an assistant that will continue it without seeing it. " +
+	"Keep the user's goals, decisions, facts, file names, identifiers and the outcome of tool calls. " +
+	"Reply with the summary only.\n\n"
+
+// FitContext finishes fitting stdReq into the context window. Under the
+// summarize strategy it asks upstream to summarize the dropped turns; when
+// that fails they simply stay dropped. What was trimmed is reported on header.
+func FitContext(ctx context.Context, ds Caller, a *auth.RequestAuth, header http.Header, stdReq util.StandardRequest) util.StandardRequest {
+	if len(stdReq.DroppedHistory) > 0 {
+		summary, err := summarizeHistory(ctx, ds, a, stdReq.DroppedHistory)
+		if err != nil {
+			config.Logger.Warn("[context] history summary failed, dropping turns", "messages", len(stdReq.DroppedHistory), "error", err)
+		}
+		stdReq.ApplyHistorySummary(summary)
+		stdReq.DroppedHistory = nil
+	}
+	if v := stdReq.Context.Header(); v != "" {
+		header.Set(util.ContextHeader, v)
+	}
+	return stdReq
+}
+
+func summarizeHistory(ctx context.Context, ds Caller, a *auth.RequestAuth, history []map[string]any) (string, error) {
+	var transcript strings.Builder
+	for _, m := range history {
+		role, _ := m["role"].(string)
+		fmt.Fprintf(&transcript, "%s: %s\n\n", role, prompt.NormalizeContent(m["content"]))
+	}
+	req := util.StandardRequest{FinalPrompt: historySummaryInstruction + strings.TrimSpace(transcript.String())}
+	return collectText(ctx, ds, a, req)
+}
+
+// collectText runs req as an extra completion on the request's account and
+// returns its answer text.
+func collectText(ctx context.Context, ds Caller, a *auth.RequestAuth, req util.StandardRequest) (string, error) {
+	completion, err := openOnAccount(ctx, ds, a, req)
+	if err != nil {
+		return "", err
+	}
+	if completion.Resp.StatusCode != http.StatusOK {
+		defer completion.Resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(completion.Resp.Body, 512))
+		return "", fmt.Errorf("status %d: %s", completion.Resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	result := sse.CollectStream(completion.Resp, false, true)
+	return result.Text, nil
+}
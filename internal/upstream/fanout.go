@@ -0,0 +1,175 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/util"
+)
+
+// MaxChoices caps n / candidateCount so one request cannot drain the pool.
+const MaxChoices = 8
+
+// ParseChoiceCount validates a client supplied n / candidateCount value.
+// A missing value means one completion.
+func ParseChoiceCount(raw any, field string) (int, error) {
+	if raw == nil {
+		return 1, nil
+	}
+	f, ok := raw.(float64)
+	if !ok || f != math.Trunc(f) || f < 1 || f > MaxChoices {
+		return 0, fmt.Errorf("'%s' must be an integer between 1 and %d.", field, MaxChoices)
+	}
+	return int(f), nil
+}
+
+// Forker hands out additional account leases for a request that fans out to
+// several upstream completions.
+type Forker interface {
+	Fork(ctx context.Context, a *auth.RequestAuth) (*auth.RequestAuth, error)
+	Release(a *auth.RequestAuth)
+}
+
+// Branch is one of the parallel completions opened by OpenN. Err is set when
+// the branch could not get an account or failed to open.
+type Branch struct {
+	Index      int
+	Auth       *auth.RequestAuth
+	Completion Completion
+	Err        error
+}
+
+// OpenN opens n completions in parallel, each with its own session and PoW.
+// Branch 0 runs on the caller's lease; the others take a free slot through
+// forker without waiting, so branches beyond the free capacity fail instead
+// of holding the request open. The caller's lease is bound once, before the
+// forks copy it.
+func OpenN(ctx context.Context, ds Caller, forker Forker, a *auth.RequestAuth, stdReq util.StandardRequest, n int) []Branch {
+	if n < 1 {
+		n = 1
+	}
+	branches := make([]Branch, n)
+	if err := BindAccount(ctx, a, stdReq); err != nil {
+		for i := range branches {
+			branches[i].Index = i
+			branches[i].Err = &Error{Stage: StageAccount, Err: err}
+		}
+		return branches
+	}
+	var wg sync.WaitGroup
+	for i := range branches {
+		branches[i].Index = i
+		wg.Add(1)
+		go func(b *Branch) {
+			defer wg.Done()
+			branchAuth := a
+			if b.Index > 0 {
+				forked, err := forker.Fork(ctx, a)
+				if err != nil {
+					b.Err = err
+					return
+				}
+				branchAuth = forked
+			}
+			b.Auth = branchAuth
+			b.Completion, b.Err = openOnAccount(ctx, ds, branchAuth, stdReq)
+		}(&branches[i])
+	}
+	wg.Wait()
+	return branches
+}
+
+// ReleaseForked returns the leases taken by OpenN for branches other than the
+// first, which belongs to the caller.
+func ReleaseForked(forker Forker, branches []Branch) {
+	for _, b := range branches {
+		if b.Index == 0 || b.Auth == nil {
+			continue
+		}
+		forker.Release(b.Auth)
+	}
+}
+
+// FirstError returns the first branch error, or nil when any branch opened.
+func FirstError(branches []Branch) error {
+	var first error
+	for _, b := range branches {
+		if b.Err == nil {
+			return nil
+		}
+		if first == nil {
+			first = b.Err
+		}
+	}
+	return first
+}
+
+// Failure returns the error message for a branch that cannot be rendered,
+// reading the upstream body when DeepSeek answered with a non-200 status and
+// naming the status when that body is empty.
+// Open errors are reported by stage without their internal detail.
+func (b Branch) Failure() string {
+	if b.Err != nil {
+		return failureMessage(b.Err)
+	}
+	resp := b.Completion.Resp
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return msg
+		}
+		return fmt.Sprintf("upstream status %d", resp.StatusCode)
+	}
+	return ""
+}
+
+func failureMessage(err error) string {
+	if errors.Is(err, auth.ErrNoAccount) {
+		return "No account is free for this choice."
+	}
+	switch StageOf(err) {
+	case StageAccount:
+		return "Failed to bind an account for this choice."
+	case StageSession:
+		return "Failed to create an upstream session for this choice."
+	case StagePow:
+		return "Failed to get PoW for this choice."
+	default:
+		return "Failed to get a completion for this choice."
+	}
+}
+
+// CloseBranches closes the upstream bodies of every opened branch.
+func CloseBranches(branches []Branch) {
+	for _, b := range branches {
+		if b.Err == nil && b.Completion.Resp != nil {
+			_ = b.Completion.Resp.Body.Close()
+		}
+	}
+}
+
+// LockedWriter serialises writes and flushes from concurrent branch runtimes
+// sharing one response so SSE frames never interleave.
+type LockedWriter struct {
+	http.ResponseWriter
+	mu sync.Mutex
+}
+
+func (w *LockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *LockedWriter) FlushError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
@@ -0,0 +1,97 @@
+// Package upstream opens DeepSeek completions on behalf of the protocol
+// adapters: session creation, PoW and the completion call itself.
+package upstream
+
+import (
+	"context"
+	"net/http"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/util"
+)
+
+// Caller is the subset of the DeepSeek client needed to open a completion.
+type Caller interface {
+	CreateSession(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
+	GetPow(ctx context.Context, a *auth.RequestAuth, maxAttempts int) (string, error)
+	CallCompletion(ctx context.Context, a *auth.RequestAuth, payload map[string]any, powResp string, maxAttempts int) (*http.Response, error)
+}
+
+type Stage string
+
+const (
+	StageAccount    Stage = "account"
+	StageSession    Stage = "session"
+	StagePow        Stage = "pow"
+	StageCompletion Stage = "completion"
+)
+
+// Error records which step of opening a completion failed so adapters can
+// map it onto their own status codes and messages.
+type Error struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return "upstream " + string(e.Stage) + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StageOf reports the failing stage of an error returned by Open.
+func StageOf(err error) Stage {
+	if e, ok := err.(*Error); ok {
+		return e.Stage
+	}
+	return StageCompletion
+}
+
+// Completion is an opened upstream completion. Resp is never nil on success;
+// the caller owns Resp.Body.
+type Completion struct {
+	SessionID string
+	Resp      *http.Response
+}
+
+const maxAttempts = 3
+
+// BindAccount moves a to the account stdReq should run on: one of the group
+// a routing rule bound it to, then the account its affinity key prefers.
+func BindAccount(ctx context.Context, a *auth.RequestAuth, stdReq util.StandardRequest) error {
+	if err := a.BindGroup(ctx, stdReq.AccountGroup); err != nil {
+		return err
+	}
+	return a.BindAffinity(ctx, stdReq.EndUser, stdReq.ConversationKey())
+}
+
+// Open creates a fresh chat session, solves its PoW and starts the completion
+// for stdReq using the given account, first moving to the account
+// BindAccount picks. Only the request's own completion binds: extra
+// completions on the same lease go through openOnAccount, so they never move
+// the account from under a live stream.
+func Open(ctx context.Context, ds Caller, a *auth.RequestAuth, stdReq util.StandardRequest) (Completion, error) {
+	if err := BindAccount(ctx, a, stdReq); err != nil {
+		return Completion{}, &Error{Stage: StageAccount, Err: err}
+	}
+	return openOnAccount(ctx, ds, a, stdReq)
+}
+
+// openOnAccount is Open on the account a already holds.
+func openOnAccount(ctx context.Context, ds Caller, a *auth.RequestAuth, stdReq util.StandardRequest) (Completion, error) {
+	sessionID, err := ds.CreateSession(ctx, a, maxAttempts)
+	if err != nil {
+		return Completion{}, &Error{Stage: StageSession, Err: err}
+	}
+	pow, err := ds.GetPow(ctx, a, maxAttempts)
+	if err != nil {
+		return Completion{}, &Error{Stage: StagePow, Err: err}
+	}
+	resp, err := ds.CallCompletion(ctx, a, stdReq.CompletionPayload(sessionID), pow, maxAttempts)
+	if err != nil {
+		return Completion{}, &Error{Stage: StageCompletion, Err: err}
+	}
+	return Completion{SessionID: sessionID, Resp: resp}, nil
+}
@@ -0,0 +1,95 @@
+package upstream
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ds2api/internal/account"
+	"ds2api/internal/auth"
+	"ds2api/internal/config"
+	"ds2api/internal/util"
+)
+
+type openCallerStub struct{}
+
+func (openCallerStub) CreateSession(context.Context, *auth.RequestAuth, int) (string, error) {
+	return "session", nil
+}
+
+func (openCallerStub) GetPow(context.Context, *auth.RequestAuth, int) (string, error) {
+	return "pow", nil
+}
+
+func (openCallerStub) CallCompletion(context.Context, *auth.RequestAuth, map[string]any, string, int) (*http.Response, error) {
+	body := `data: {"p":"response/content","v":"{\"tool_calls\":[{\"name\":\"search\",\"input\":{\"query\":\"go\"}}]}"}` + "\n"
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestRepairDuringStreamKeepsTheFallbackAccount(t *testing.T) {
+	t.Setenv("DS2API_ACCOUNT_MAX_INFLIGHT", "1")
+	t.Setenv("DS2API_CONFIG_JSON", `{
+		"keys":["k1"],
+		"affinity":{"enabled":true,"fallback_accounts":1},
+		"accounts":[
+			{"email":"a1@example.com","token":"t1"},
+			{"email":"a2@example.com","token":"t2"},
+			{"email":"a3@example.com","token":"t3"}
+		]
+	}`)
+	store := config.LoadStore()
+	pool := account.NewPool(store)
+	r := auth.NewResolver(store, pool, nil)
+	stdReq := util.StandardRequest{
+		FinalPrompt: "hi",
+		ToolSchemas: util.CompileToolSchemas([]any{map[string]any{"name": "search", "input_schema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"query": map[string]any{"type": "string"}},
+			"required":   []any{"query"},
+		}}}),
+	}
+	open := func() (*auth.RequestAuth, Completion) {
+		req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer k1")
+		req.Header.Set(config.SessionHeader, "session-1")
+		a, err := r.Determine(req)
+		if err != nil {
+			t.Fatalf("determine failed: %v", err)
+		}
+		completion, err := Open(context.Background(), openCallerStub{}, a, stdReq)
+		if err != nil {
+			t.Fatalf("open failed: %v", err)
+		}
+		return a, completion
+	}
+
+	preferred, first := open()
+	stream, second := open()
+	if stream.AccountID == preferred.AccountID {
+		t.Fatal("expected the second stream on a fallback account while the preferred one is busy")
+	}
+	_ = first.Resp.Body.Close()
+	r.Release(preferred)
+
+	// The preferred account is free again while the fallback stream is live.
+	fallback := stream.AccountID
+	reader := ToolCallReader(context.Background(), openCallerStub{}, stream, stdReq, util.ToolDialectJSON, 1)
+	if _, err := reader.Repair(util.ParsedToolCall{Name: "search", Input: map[string]any{}}, []string{"arguments.query is required"}); err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	if stream.AccountID != fallback {
+		t.Fatalf("expected the repair to stay on %s, moved to %s", fallback, stream.AccountID)
+	}
+	status := pool.Status()
+	if status["in_use"] != 1 || !reflect.DeepEqual(status["in_use_accounts"], []string{fallback}) {
+		t.Fatalf("expected only the live stream's slot held, got %v", status)
+	}
+	_ = second.Resp.Body.Close()
+	r.Release(stream)
+	if status := pool.Status(); status["in_use"] != 0 {
+		t.Fatalf("expected no slot left behind, got %v", status["in_use"])
+	}
+}
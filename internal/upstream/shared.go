@@ -0,0 +1,95 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/coalesce"
+	"ds2api/internal/respcache"
+	"ds2api/internal/sse"
+	"ds2api/internal/util"
+)
+
+// Sharing is what lets identical requests share upstream work: the response
+// cache and the coalescing of concurrent requests. The zero value shares
+// nothing.
+type Sharing struct {
+	Cache   *respcache.Cache
+	Flights *coalesce.Group
+	// CallerKey is the caller's API key, checked against the coalescing
+	// opt-out list.
+	CallerKey string
+	// Release frees the caller's account slot once the request has joined a
+	// completion another request opened.
+	Release func()
+}
+
+// OpenShared is Open behind the response cache and request coalescing. A
+// cache hit replays the stored upstream stream without opening a session.
+// Otherwise a request identical to one in flight reads that completion's
+// stream instead of opening its own. Cache outcomes are reported in the
+// respcache.Header of header and joined completions in coalesce.Header.
+func OpenShared(ctx context.Context, ds Caller, s Sharing, a *auth.RequestAuth, stdReq util.StandardRequest, header http.Header) (Completion, error) {
+	cacheKey, cacheable := s.Cache.Key(stdReq)
+	if cacheable {
+		if body, hit := s.Cache.Get(cacheKey); hit {
+			header.Set(respcache.Header, "hit")
+			return Completion{SessionID: uuid.NewString(), Resp: respcache.Replay(body)}, nil
+		}
+	}
+	open := func(ctx context.Context) (Completion, error) {
+		completion, err := Open(ctx, ds, a, stdReq)
+		if err == nil && cacheable && completion.Resp.StatusCode == http.StatusOK {
+			completion.Resp.Body = s.Cache.Record(cacheKey, completion.Resp.Body, completeStream)
+		}
+		return completion, err
+	}
+	if cacheable {
+		header.Set(respcache.Header, "miss")
+	}
+	flightKey, ok := s.Flights.Key(stdReq, s.CallerKey)
+	if !ok {
+		return open(ctx)
+	}
+	sessionID := ""
+	resp, joined, err := s.Flights.Do(ctx, flightKey, func(ctx context.Context) (*http.Response, func(), error) {
+		completion, err := open(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		sessionID = completion.SessionID
+		// The completion keeps reading on the account after this request
+		// returns, so the flight holds the slot until the stream ends.
+		return completion.Resp, a.HandOff(), nil
+	})
+	if err != nil {
+		return Completion{}, err
+	}
+	if joined {
+		header.Set(coalesce.Header, "true")
+		sessionID = uuid.NewString()
+		if s.Release != nil {
+			s.Release()
+		}
+	}
+	return Completion{SessionID: sessionID, Resp: resp}, nil
+}
+
+// completeStream reports whether body is a whole, successful DeepSeek
+// stream: it reaches the line that finishes it and carries no error or
+// content filter, which must not be replayed.
+func completeStream(body []byte) bool {
+	finished := false
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		result := sse.ParseDeepSeekContentLine(line, false, "text")
+		if result.ErrorMessage != "" {
+			return false
+		}
+		finished = finished || result.Stop
+	}
+	return finished
+}
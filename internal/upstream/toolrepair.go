@@ -0,0 +1,33 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+
+	"ds2api/internal/auth"
+	"ds2api/internal/util"
+)
+
+// ToolCallReader returns the reader for stdReq's tool calls. With attempts
+// above zero, a call that fails its schema is sent back to the model with
+// the validation problems through an extra completion on the request's
+// account.
+func ToolCallReader(ctx context.Context, ds Caller, a *auth.RequestAuth, stdReq util.StandardRequest, dialects util.ToolDialects, attempts int) util.ToolCallReader {
+	reader := util.NewToolCallReader(dialects, stdReq.ToolSchemas)
+	if attempts <= 0 || a == nil || len(stdReq.ToolSchemas) == 0 {
+		return reader
+	}
+	reader.Attempts = min(attempts, util.MaxToolRepairAttempts)
+	reader.Repair = func(call util.ParsedToolCall, problems []string) (util.ParsedToolCall, error) {
+		text, err := collectText(ctx, ds, a, stdReq.ToolRepairRequest(call, problems))
+		if err != nil {
+			return util.ParsedToolCall{}, err
+		}
+		calls := util.ParseToolCallsWith(text, []string{call.Name}, dialects|util.ToolDialectJSON)
+		if len(calls) == 0 {
+			return util.ParsedToolCall{}, fmt.Errorf("no call to %s in the repair reply", call.Name)
+		}
+		return calls[0], nil
+	}
+	return reader
+}
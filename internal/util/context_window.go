@@ -0,0 +1,287 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"ds2api/internal/config"
+)
+
+// ContextStrategy is how a conversation that does not fit the context window
+// is cut down before it is sent upstream.
+type ContextStrategy string
+
+const (
+	// ContextOff sends the conversation as is.
+	ContextOff ContextStrategy = "off"
+	// ContextDropOldest drops the oldest turns, keeping system messages and
+	// the latest user turn with the tool exchanges that follow it.
+	ContextDropOldest ContextStrategy = "drop_oldest"
+	// ContextTruncateToolResults shortens oversized tool results, oldest
+	// first, and drops the oldest turns if that is not enough.
+	ContextTruncateToolResults ContextStrategy = "truncate_tool_results"
+	// ContextSummarize drops the oldest turns like ContextDropOldest and
+	// replaces them with a summary written by an extra upstream call.
+	ContextSummarize ContextStrategy = "summarize"
+)
+
+// ContextHeader reports on the response what was trimmed from the prompt.
+const ContextHeader = "X-Ds2api-Context"
+
+const (
+	defaultToolResultMaxTokens = 2000
+	// contextMessageOverheadTokens covers the role markers MessagesPrepare
+	// adds around each message.
+	contextMessageOverheadTokens = 4
+
+	toolResultOpen      = "[TOOL_RESULT_HISTORY]"
+	toolResultContent   = "\ncontent: "
+	toolResultClose     = "\n[/TOOL_RESULT_HISTORY]"
+	toolResultCutMarker = "\n…[tool result truncated to fit the context window]"
+	historySummaryIntro = "Summary of the earlier conversation, which was shortened to fit the context window:\n"
+)
+
+// ParseContextStrategy validates a configured strategy. An empty value is
+// accepted and means ContextOff.
+func ParseContextStrategy(raw string) (ContextStrategy, error) {
+	strategy := ContextStrategy(strings.ToLower(strings.TrimSpace(raw)))
+	switch strategy {
+	case "", ContextOff, ContextDropOldest, ContextTruncateToolResults, ContextSummarize:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("context.strategy must be one of off, drop_oldest, truncate_tool_results or summarize")
+	}
+}
+
+// ContextPolicy is the trimming applied to one request. MaxTokens is the
+// prompt budget; zero means unlimited.
+type ContextPolicy struct {
+	Strategy            ContextStrategy
+	MaxTokens           int
+	ToolResultMaxTokens int
+}
+
+// NewContextPolicy builds the policy for a request served by model. Without a
+// configured max_prompt_tokens the budget is the registry context window
+// minus the model's output allowance.
+func NewContextPolicy(cfg config.ContextConfig, registry config.ModelRegistryReader, model string) ContextPolicy {
+	strategy, _ := ParseContextStrategy(cfg.Strategy)
+	policy := ContextPolicy{Strategy: strategy, MaxTokens: cfg.MaxPromptTokens, ToolResultMaxTokens: cfg.ToolResultMaxTokens}
+	if policy.MaxTokens <= 0 {
+		if m, ok := config.LookupModel(registry, model); ok && m.ContextWindow > m.MaxOutputTokens {
+			policy.MaxTokens = m.ContextWindow - m.MaxOutputTokens
+		}
+	}
+	if policy.ToolResultMaxTokens <= 0 {
+		policy.ToolResultMaxTokens = defaultToolResultMaxTokens
+	}
+	return policy
+}
+
+// WithTruncation applies the Responses truncation parameter: "auto" trims
+// with the configured strategy, dropping the oldest turns when none is
+// configured, and "disabled" sends the conversation as is.
+func (p ContextPolicy) WithTruncation(raw any) (ContextPolicy, error) {
+	if raw == nil {
+		return p, nil
+	}
+	s, _ := raw.(string)
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "auto":
+		if p.Strategy == "" || p.Strategy == ContextOff {
+			p.Strategy = ContextDropOldest
+		}
+	case "disabled":
+		p.Strategy = ContextOff
+	default:
+		return p, fmt.Errorf("truncation must be auto or disabled")
+	}
+	return p, nil
+}
+
+// ContextReport records what fitting the prompt removed.
+type ContextReport struct {
+	Strategy             ContextStrategy
+	PromptTokens         int
+	DroppedMessages      int
+	TruncatedToolResults int
+	SummarizedMessages   int
+}
+
+// Trimmed reports whether anything was removed from the prompt.
+func (r ContextReport) Trimmed() bool {
+	return r.DroppedMessages > 0 || r.TruncatedToolResults > 0 || r.SummarizedMessages > 0
+}
+
+// Header is the ContextHeader value, or "" when nothing was trimmed.
+func (r ContextReport) Header() string {
+	if !r.Trimmed() {
+		return ""
+	}
+	return fmt.Sprintf("strategy=%s; dropped_messages=%d; truncated_tool_results=%d; summarized_messages=%d; prompt_tokens=%d",
+		r.Strategy, r.DroppedMessages, r.TruncatedToolResults, r.SummarizedMessages, r.PromptTokens)
+}
+
+// FitPrompt builds FinalPrompt from the prompt messages with Template,
+// trimming them to the policy's budget first. Under ContextSummarize the dropped turns are kept in
+// DroppedHistory until ApplyHistorySummary replaces them.
+func (r *StandardRequest) FitPrompt(messages []map[string]any, policy ContextPolicy) {
+	kept, dropped, report := fitContextMessages(messages, policy)
+	r.PromptMessages = kept
+	r.DroppedHistory = nil
+	if policy.Strategy == ContextSummarize && len(dropped) > 0 {
+		r.DroppedHistory = dropped
+	}
+	r.FinalPrompt = r.renderPrompt(kept)
+	report.PromptTokens = EstimateTokens(r.FinalPrompt)
+	r.Context = report
+}
+
+// ApplyHistorySummary puts summary in place of DroppedHistory, after the
+// leading system messages.
+func (r *StandardRequest) ApplyHistorySummary(summary string) {
+	summary = strings.TrimSpace(summary)
+	if summary == "" || len(r.DroppedHistory) == 0 {
+		return
+	}
+	at := 0
+	for at < len(r.PromptMessages) && r.PromptMessages[at]["role"] == "system" {
+		at++
+	}
+	messages := make([]map[string]any, 0, len(r.PromptMessages)+1)
+	messages = append(messages, r.PromptMessages[:at]...)
+	messages = append(messages, map[string]any{"role": "system", "content": historySummaryIntro + summary})
+	messages = append(messages, r.PromptMessages[at:]...)
+	r.PromptMessages = messages
+	r.Context.SummarizedMessages = len(r.DroppedHistory)
+	r.Context.DroppedMessages -= len(r.DroppedHistory)
+	r.DroppedHistory = nil
+	r.FinalPrompt = r.renderPrompt(messages)
+	r.Context.PromptTokens = EstimateTokens(r.FinalPrompt)
+}
+
+func fitContextMessages(messages []map[string]any, policy ContextPolicy) ([]map[string]any, []map[string]any, ContextReport) {
+	report := ContextReport{Strategy: policy.Strategy}
+	if policy.Strategy == "" || policy.Strategy == ContextOff || policy.MaxTokens <= 0 {
+		return messages, nil, report
+	}
+	costs := make([]int, len(messages))
+	total := 0
+	for i, m := range messages {
+		costs[i] = contextMessageTokens(m)
+		total += costs[i]
+	}
+	if total <= policy.MaxTokens {
+		return messages, nil, report
+	}
+
+	out := make([]map[string]any, len(messages))
+	copy(out, messages)
+	if policy.Strategy == ContextTruncateToolResults {
+		for i, m := range out {
+			if total <= policy.MaxTokens {
+				break
+			}
+			text, ok := m["content"].(string)
+			if !ok {
+				continue
+			}
+			cut, n := truncateToolResults(text, policy.ToolResultMaxTokens)
+			if n == 0 {
+				continue
+			}
+			out[i] = cloneMessageWithContent(m, cut)
+			report.TruncatedToolResults += n
+			cost := contextMessageTokens(out[i])
+			total += cost - costs[i]
+			costs[i] = cost
+		}
+	}
+
+	drop := make([]bool, len(out))
+	for i, end := 0, latestTurnStart(out); i < end && total > policy.MaxTokens; i++ {
+		if out[i]["role"] == "system" {
+			continue
+		}
+		drop[i] = true
+		total -= costs[i]
+		report.DroppedMessages++
+	}
+	if report.DroppedMessages == 0 {
+		return out, nil, report
+	}
+	kept := make([]map[string]any, 0, len(out)-report.DroppedMessages)
+	dropped := make([]map[string]any, 0, report.DroppedMessages)
+	for i, m := range out {
+		if drop[i] {
+			dropped = append(dropped, m)
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	return kept, dropped, report
+}
+
+func contextMessageTokens(m map[string]any) int {
+	return EstimateTokens(normalizeContent(m["content"])) + contextMessageOverheadTokens
+}
+
+// latestTurnStart is the index of the last user message that is not a tool
+// result: it and everything after it are never dropped.
+func latestTurnStart(messages []map[string]any) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] != "user" {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(normalizeContent(messages[i]["content"])), toolResultOpen) {
+			return i
+		}
+	}
+	return max(len(messages)-1, 0)
+}
+
+// truncateToolResults shortens the content of every tool result block in text
+// that is over maxTokens and reports how many it shortened.
+func truncateToolResults(text string, maxTokens int) (string, int) {
+	var b strings.Builder
+	count := 0
+	rest := text
+	for {
+		start := strings.Index(rest, toolResultOpen)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], toolResultClose)
+		if end < 0 {
+			break
+		}
+		end += start
+		block := rest[start:end]
+		b.WriteString(rest[:start])
+		if i := strings.Index(block, toolResultContent); i >= 0 {
+			content := block[i+len(toolResultContent):]
+			var c TokenCounter
+			if n := c.AddWithin(content, maxTokens); n < len(content) {
+				block = block[:i+len(toolResultContent)] + content[:n] + toolResultCutMarker
+				count++
+			}
+		}
+		b.WriteString(block)
+		b.WriteString(toolResultClose)
+		rest = rest[end+len(toolResultClose):]
+	}
+	if count == 0 {
+		return text, 0
+	}
+	b.WriteString(rest)
+	return b.String(), count
+}
+
+func cloneMessageWithContent(m map[string]any, content string) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	out["content"] = content
+	return out
+}
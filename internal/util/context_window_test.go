@@ -0,0 +1,108 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"ds2api/internal/config"
+)
+
+func contextTestMessages() []map[string]any {
+	long := strings.Repeat("history ", 200)
+	return []map[string]any{
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": "first " + long},
+		{"role": "assistant", "content": "reply " + long},
+		{"role": "user", "content": "latest question"},
+		{"role": "assistant", "content": "[TOOL_CALL_HISTORY]\nfunction.name: search\n[/TOOL_CALL_HISTORY]"},
+		{"role": "user", "content": "[TOOL_RESULT_HISTORY]\nname: search\ncontent: " + long + "\n[/TOOL_RESULT_HISTORY]"},
+	}
+}
+
+func TestFitPromptDropOldestKeepsSystemAndLatestTurn(t *testing.T) {
+	var r StandardRequest
+	r.FitPrompt(contextTestMessages(), ContextPolicy{Strategy: ContextDropOldest, MaxTokens: 500})
+	if r.Context.DroppedMessages != 2 || len(r.PromptMessages) != 4 {
+		t.Fatalf("expected the two oldest turns dropped, got %#v", r.Context)
+	}
+	if !strings.HasPrefix(r.FinalPrompt, "be brief") || !strings.Contains(r.FinalPrompt, "latest question") || strings.Contains(r.FinalPrompt, "first history") {
+		t.Fatalf("unexpected prompt: %q", r.FinalPrompt)
+	}
+	if r.DroppedHistory != nil {
+		t.Fatalf("drop_oldest should not keep history for a summary")
+	}
+	if h := r.Context.Header(); !strings.Contains(h, "strategy=drop_oldest") || !strings.Contains(h, "dropped_messages=2") {
+		t.Fatalf("unexpected header %q", h)
+	}
+}
+
+func TestFitPromptTruncatesToolResultsBeforeDropping(t *testing.T) {
+	var r StandardRequest
+	messages := contextTestMessages()[3:]
+	r.FitPrompt(messages, ContextPolicy{Strategy: ContextTruncateToolResults, MaxTokens: 120, ToolResultMaxTokens: 20})
+	if r.Context.TruncatedToolResults != 1 || r.Context.DroppedMessages != 0 {
+		t.Fatalf("expected one truncated tool result, got %#v", r.Context)
+	}
+	if !strings.Contains(r.FinalPrompt, toolResultCutMarker+toolResultClose) {
+		t.Fatalf("expected truncation marker inside the block: %q", r.FinalPrompt)
+	}
+	if messages[2]["content"] == r.PromptMessages[2]["content"] {
+		t.Fatal("expected the caller's messages to be left untouched")
+	}
+}
+
+func TestFitPromptUnderBudgetOrOffIsUntouched(t *testing.T) {
+	for _, policy := range []ContextPolicy{
+		{Strategy: ContextDropOldest, MaxTokens: 1 << 20},
+		{Strategy: ContextOff, MaxTokens: 10},
+		{Strategy: ContextDropOldest},
+	} {
+		var r StandardRequest
+		r.FitPrompt(contextTestMessages(), policy)
+		if r.Context.Trimmed() || len(r.PromptMessages) != 6 || r.Context.Header() != "" {
+			t.Fatalf("policy %#v: expected no trimming, got %#v", policy, r.Context)
+		}
+	}
+}
+
+func TestApplyHistorySummaryReplacesDroppedTurns(t *testing.T) {
+	var r StandardRequest
+	r.FitPrompt(contextTestMessages(), ContextPolicy{Strategy: ContextSummarize, MaxTokens: 500})
+	if len(r.DroppedHistory) != 2 {
+		t.Fatalf("expected dropped history kept for summary, got %d", len(r.DroppedHistory))
+	}
+	r.ApplyHistorySummary("user asked about history")
+	if r.Context.SummarizedMessages != 2 || r.Context.DroppedMessages != 0 || r.DroppedHistory != nil {
+		t.Fatalf("unexpected report %#v", r.Context)
+	}
+	if r.PromptMessages[1]["role"] != "system" || !strings.Contains(r.FinalPrompt, historySummaryIntro+"user asked about history") {
+		t.Fatalf("expected summary after the system prompt, got %q", r.FinalPrompt)
+	}
+}
+
+func TestContextPolicyBudgetAndTruncation(t *testing.T) {
+	policy := NewContextPolicy(config.ContextConfig{}, nil, "deepseek-chat")
+	if policy.MaxTokens != 131072-8192 || policy.ToolResultMaxTokens != defaultToolResultMaxTokens || policy.Strategy != "" {
+		t.Fatalf("unexpected default policy %#v", policy)
+	}
+	if p := NewContextPolicy(config.ContextConfig{MaxPromptTokens: 4096}, nil, "deepseek-chat"); p.MaxTokens != 4096 {
+		t.Fatalf("expected configured budget, got %d", p.MaxTokens)
+	}
+	auto, err := policy.WithTruncation("auto")
+	if err != nil || auto.Strategy != ContextDropOldest {
+		t.Fatalf("expected auto to drop oldest, got %#v err=%v", auto, err)
+	}
+	summarize := ContextPolicy{Strategy: ContextSummarize}
+	if p, _ := summarize.WithTruncation("auto"); p.Strategy != ContextSummarize {
+		t.Fatalf("expected auto to keep the configured strategy, got %q", p.Strategy)
+	}
+	if p, _ := summarize.WithTruncation("disabled"); p.Strategy != ContextOff {
+		t.Fatalf("expected disabled to turn trimming off, got %q", p.Strategy)
+	}
+	if _, err := policy.WithTruncation("always"); err == nil {
+		t.Fatal("expected invalid truncation to fail")
+	}
+	if _, err := ParseContextStrategy("fold"); err == nil {
+		t.Fatal("expected invalid strategy to fail")
+	}
+}
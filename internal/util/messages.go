@@ -30,22 +30,7 @@ func ConvertClaudeToDeepSeek(claudeReq map[string]any, store *config.Store) map[
 // For non-ASCII text (Chinese, Japanese, Korean, etc.) we use ~1.3 chars per token,
 // which better reflects typical BPE tokenizer behavior for CJK scripts.
 func EstimateTokens(text string) int {
-	if text == "" {
-		return 0
-	}
-	asciiChars := 0
-	nonASCIIChars := 0
-	for _, r := range text {
-		if r < 128 {
-			asciiChars++
-		} else {
-			nonASCIIChars++
-		}
-	}
-	// ASCII: ~4 chars per token; non-ASCII (CJK): ~1.3 chars per token
-	n := asciiChars/4 + (nonASCIIChars*10+7)/13
-	if n < 1 {
-		return 1
-	}
-	return n
+	var c TokenCounter
+	c.Add(text)
+	return c.Tokens()
 }
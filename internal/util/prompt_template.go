@@ -0,0 +1,62 @@
+package util
+
+import (
+	"ds2api/internal/config"
+	"ds2api/internal/memo"
+	"ds2api/internal/prompt"
+)
+
+// maxCachedTemplates bounds chatTemplateCache so templates edited out of
+// config do not accumulate.
+const maxCachedTemplates = 128
+
+// chatTemplateCache holds parsed prompt and tool templates keyed by their
+// config, so a config change is picked up without invalidation.
+var chatTemplateCache = memo.New[string, any](maxCachedTemplates)
+
+// ParsePromptTemplate parses a configured prompt template.
+func ParsePromptTemplate(t config.PromptTemplate) (*prompt.ChatTemplate, error) {
+	key := t.Name + "\x00" + t.ImageLinks + "\x00" + t.Template
+	if cached, ok := chatTemplateCache.Get(key); ok {
+		return cached.(*prompt.ChatTemplate), nil
+	}
+	parsed, err := prompt.ParseChatTemplate(t.Name, t.Template, t.ImageLinks)
+	if err != nil {
+		return nil, err
+	}
+	chatTemplateCache.Put(key, parsed)
+	return parsed, nil
+}
+
+// ChatTemplateFor returns the prompt template for a request served by model
+// and made with key: the first matching configured template, otherwise the
+// built-in one. A template that no longer parses is skipped with a warning.
+func ChatTemplateFor(reader config.PromptTemplateReader, model, key string) *prompt.ChatTemplate {
+	if reader == nil {
+		return prompt.DefaultChatTemplate()
+	}
+	t, ok := config.SelectPromptTemplate(reader.PromptTemplates(), model, key)
+	if !ok {
+		return prompt.DefaultChatTemplate()
+	}
+	parsed, err := ParsePromptTemplate(t)
+	if err != nil {
+		config.Logger.Warn("[prompt_template] invalid template, using default", "name", t.Name, "error", err)
+		return prompt.DefaultChatTemplate()
+	}
+	return parsed
+}
+
+// renderPrompt flattens messages with the request's template, falling back to
+// the built-in template when it fails to execute.
+func (r *StandardRequest) renderPrompt(messages []map[string]any) string {
+	if r.Template == nil {
+		return MessagesPrepare(messages)
+	}
+	out, err := r.Template.Render(messages)
+	if err != nil {
+		config.Logger.Warn("[prompt_template] render failed, using default", "name", r.Template.Name, "error", err)
+		return MessagesPrepare(messages)
+	}
+	return out
+}
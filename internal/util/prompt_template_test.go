@@ -0,0 +1,88 @@
+package util
+
+import (
+	"testing"
+
+	"ds2api/internal/config"
+)
+
+type promptTemplateStore []config.PromptTemplate
+
+func (s promptTemplateStore) PromptTemplates() []config.PromptTemplate { return s }
+
+var promptTemplateFixture = []map[string]any{
+	{"role": "system", "content": "S"},
+	{"role": "user", "content": "U1 ![a](https://example.com/a.png)"},
+	{"role": "assistant", "content": "A1"},
+	{"role": "user", "content": "U2"},
+	{"role": "user", "content": []any{map[string]any{"type": "text", "text": "U3"}}},
+	{"role": "tool", "content": "T"},
+	{"role": "system", "content": "S2"},
+	{"role": "assistant", "content": "A2"},
+}
+
+func TestDefaultPromptTemplateMatchesLegacyFlattening(t *testing.T) {
+	want := "S" +
+		"<｜User｜>U1 [a](https://example.com/a.png)" +
+		"<｜Assistant｜>A1<｜end▁of▁sentence｜>" +
+		"<｜User｜>U2\n\nU3" +
+		"T" +
+		"<｜User｜>S2" +
+		"<｜Assistant｜>A2<｜end▁of▁sentence｜>"
+	if got := MessagesPrepare(promptTemplateFixture); got != want {
+		t.Fatalf("default template changed the prompt:\n got %q\nwant %q", got, want)
+	}
+	req := StandardRequest{}
+	req.FitPrompt(promptTemplateFixture, ContextPolicy{})
+	if req.FinalPrompt != want {
+		t.Fatalf("FitPrompt without a template should use the default, got %q", req.FinalPrompt)
+	}
+}
+
+func TestConfiguredPromptTemplateRendersMessages(t *testing.T) {
+	store := promptTemplateStore{{
+		Name:       "chatml",
+		Models:     []string{"deepseek-reasoner*"},
+		ImageLinks: "keep",
+		Template: `{{if .System}}<|system|>{{.System}}
+{{end}}{{range .Dialogue}}<|{{.Role}}|>{{trim .Text}}{{if not .Last}}
+{{end}}{{end}}`,
+	}}
+	req := StandardRequest{Template: ChatTemplateFor(store, "deepseek-reasoner", "")}
+	req.FitPrompt(promptTemplateFixture[:4], ContextPolicy{})
+	want := "<|system|>S\n<|user|>U1 ![a](https://example.com/a.png)\n<|assistant|>A1\n<|user|>U2"
+	if req.FinalPrompt != want {
+		t.Fatalf("unexpected prompt:\n got %q\nwant %q", req.FinalPrompt, want)
+	}
+}
+
+func TestChatTemplateForSelectsByModelAndKey(t *testing.T) {
+	store := promptTemplateStore{
+		{Name: "by-key", Keys: []string{"key-a"}, Template: "key:{{len .Turns}}"},
+		{Name: "by-model", Models: []string{"*-chat"}, Template: "model:{{len .Turns}}"},
+	}
+	cases := []struct{ model, key, want string }{
+		{"deepseek-chat", "key-a", "by-key"},
+		{"deepseek-chat", "key-b", "by-model"},
+		{"deepseek-reasoner", "key-b", "default"},
+	}
+	for _, tc := range cases {
+		if got := ChatTemplateFor(store, tc.model, tc.key).Name; got != tc.want {
+			t.Fatalf("%s/%s: expected %s, got %s", tc.model, tc.key, tc.want, got)
+		}
+	}
+}
+
+func TestPromptTemplateFallsBackToDefault(t *testing.T) {
+	broken := promptTemplateStore{{Name: "broken", Template: "{{.Nope"}}
+	if got := ChatTemplateFor(broken, "deepseek-chat", "").Name; got != "default" {
+		t.Fatalf("unparsable template should fall back to default, got %s", got)
+	}
+
+	failing := promptTemplateStore{{Name: "failing", Template: "{{index .Turns 9}}"}}
+	req := StandardRequest{Template: ChatTemplateFor(failing, "deepseek-chat", "")}
+	req.FitPrompt([]map[string]any{{"role": "user", "content": "Hi"}}, ContextPolicy{})
+	if req.FinalPrompt != "Hi" {
+		t.Fatalf("render failure should fall back to default, got %q", req.FinalPrompt)
+	}
+}
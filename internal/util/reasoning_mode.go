@@ -0,0 +1,198 @@
+package util
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"ds2api/internal/config"
+)
+
+// ReasoningMode is how reasoning text is shown to the client. It only changes
+// presentation: the upstream still thinks, and usage still counts it.
+type ReasoningMode string
+
+const (
+	// ReasoningSeparate sends reasoning on the protocol's own channel
+	// (reasoning_content, thinking blocks, reasoning items, thought parts).
+	ReasoningSeparate ReasoningMode = "separate"
+	// ReasoningInlineThinkTags prepends reasoning to the answer text wrapped
+	// in <think>...</think>.
+	ReasoningInlineThinkTags ReasoningMode = "inline_think_tags"
+	// ReasoningHidden drops reasoning from the output.
+	ReasoningHidden ReasoningMode = "hidden"
+	// ReasoningSummaryOnly sends one condensed summary on the reasoning
+	// channel once thinking ends instead of streaming it.
+	ReasoningSummaryOnly ReasoningMode = "summary_only"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>\n\n"
+
+	reasoningSummaryMaxRunes = 400
+)
+
+// ParseReasoningMode validates a configured or requested mode. An empty value
+// is accepted and means the default.
+func ParseReasoningMode(raw string) (ReasoningMode, error) {
+	mode := ReasoningMode(strings.ToLower(strings.TrimSpace(raw)))
+	if mode != "" && !slices.Contains(config.ReasoningModes, string(mode)) {
+		return "", fmt.Errorf("reasoning_mode must be one of separate, inline_think_tags, hidden or summary_only")
+	}
+	return mode, nil
+}
+
+// ReasoningModeFrom reads the request-level reasoning_mode field, falling
+// back to the configured default when the request does not set one. The
+// default is validated when the config is loaded or saved; an invalid one
+// that slipped through still means the default presentation.
+func ReasoningModeFrom(raw any, fallback string) (ReasoningMode, error) {
+	if raw != nil {
+		s, ok := raw.(string)
+		if !ok {
+			return "", fmt.Errorf("reasoning_mode must be a string")
+		}
+		if strings.TrimSpace(s) != "" {
+			return ParseReasoningMode(s)
+		}
+	}
+	mode, err := ParseReasoningMode(fallback)
+	if err != nil {
+		return "", nil
+	}
+	return mode, nil
+}
+
+// InlineThinkTags prepends reasoning to text as a <think> block. Blank
+// reasoning leaves text unchanged.
+func InlineThinkTags(thinking, text string) string {
+	if strings.TrimSpace(thinking) == "" {
+		return text
+	}
+	return thinkOpenTag + thinking + thinkCloseTag + text
+}
+
+// SummarizeReasoning condenses reasoning to its last non-empty line, where
+// the model usually states its conclusion, capped at a few hundred runes.
+func SummarizeReasoning(thinking string) string {
+	lines := strings.Split(strings.TrimSpace(thinking), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > reasoningSummaryMaxRunes {
+			line = string([]rune(line)[:reasoningSummaryMaxRunes]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// PresentReasoning applies mode to a finished completion. It returns the
+// reasoning for the protocol's reasoning channel and the text to show as the
+// answer.
+func PresentReasoning(mode ReasoningMode, thinking, text string) (string, string) {
+	switch mode {
+	case ReasoningHidden:
+		return "", text
+	case ReasoningSummaryOnly:
+		return SummarizeReasoning(thinking), text
+	case ReasoningInlineThinkTags:
+		return "", InlineThinkTags(thinking, text)
+	default:
+		return thinking, text
+	}
+}
+
+// ReasoningPresenter applies a ReasoningMode to streamed thinking. Runtimes
+// keep recording the raw thinking for usage and tool detection and ask the
+// presenter what to write out. A nil presenter behaves as ReasoningSeparate.
+type ReasoningPresenter struct {
+	mode     ReasoningMode
+	thinking strings.Builder
+	inline   strings.Builder
+	open     bool
+	ended    bool
+}
+
+// NewReasoningPresenter returns nil for the default separate mode.
+func NewReasoningPresenter(mode ReasoningMode) *ReasoningPresenter {
+	if mode == "" || mode == ReasoningSeparate {
+		return nil
+	}
+	return &ReasoningPresenter{mode: mode}
+}
+
+// Thinking takes a thinking delta and returns what to stream for it on the
+// reasoning channel and inline in the answer text. Once the answer has
+// started, further thinking is no longer shown outside separate mode.
+func (p *ReasoningPresenter) Thinking(text string) (reasoning, inline string) {
+	if p == nil {
+		return text, ""
+	}
+	if p.ended || text == "" {
+		return "", ""
+	}
+	switch p.mode {
+	case ReasoningInlineThinkTags:
+		if !p.open {
+			p.open = true
+			text = thinkOpenTag + text
+		}
+		p.inline.WriteString(text)
+		return "", text
+	case ReasoningSummaryOnly:
+		p.thinking.WriteString(text)
+	}
+	return "", ""
+}
+
+// EndThinking is called when the answer starts and again when the stream
+// ends. It returns the closing </think> tag or the reasoning summary the
+// first time there is one to send.
+func (p *ReasoningPresenter) EndThinking() (reasoning, inline string) {
+	if p == nil || p.ended {
+		return "", ""
+	}
+	p.ended = true
+	switch p.mode {
+	case ReasoningInlineThinkTags:
+		if p.open {
+			p.inline.WriteString(thinkCloseTag)
+			return "", thinkCloseTag
+		}
+	case ReasoningSummaryOnly:
+		return SummarizeReasoning(p.thinking.String()), ""
+	}
+	return "", ""
+}
+
+// Inline is the reasoning text already written into the answer, so citation
+// offsets into the answer can be shifted past it.
+func (p *ReasoningPresenter) Inline() string {
+	if p == nil {
+		return ""
+	}
+	return p.inline.String()
+}
+
+// FinalReasoning is what the reasoning channel shows for the complete
+// thinking, for fallbacks built once the stream is over.
+func (p *ReasoningPresenter) FinalReasoning(thinking string) string {
+	if p == nil {
+		return thinking
+	}
+	reasoning, _ := PresentReasoning(p.mode, thinking, "")
+	return reasoning
+}
+
+// Mode reports the presenter's mode; nil is ReasoningSeparate.
+func (p *ReasoningPresenter) Mode() ReasoningMode {
+	if p == nil {
+		return ReasoningSeparate
+	}
+	return p.mode
+}
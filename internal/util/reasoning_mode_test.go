@@ -0,0 +1,100 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReasoningMode(t *testing.T) {
+	mode, err := ParseReasoningMode(" Inline_Think_Tags ")
+	if err != nil || mode != ReasoningInlineThinkTags {
+		t.Fatalf("expected inline_think_tags, got %q err=%v", mode, err)
+	}
+	if mode, err := ParseReasoningMode(""); err != nil || mode != "" {
+		t.Fatalf("expected empty mode to be accepted, got %q err=%v", mode, err)
+	}
+	if _, err := ParseReasoningMode("verbose"); err == nil {
+		t.Fatal("expected unknown mode to be rejected")
+	}
+}
+
+func TestReasoningModeFromFallsBackToConfig(t *testing.T) {
+	mode, err := ReasoningModeFrom(nil, "hidden")
+	if err != nil || mode != ReasoningHidden {
+		t.Fatalf("expected config default, got %q err=%v", mode, err)
+	}
+	mode, err = ReasoningModeFrom("summary_only", "hidden")
+	if err != nil || mode != ReasoningSummaryOnly {
+		t.Fatalf("expected request value to win, got %q err=%v", mode, err)
+	}
+	if _, err := ReasoningModeFrom(true, ""); err == nil {
+		t.Fatal("expected non-string reasoning_mode to be rejected")
+	}
+}
+
+func TestPresentReasoning(t *testing.T) {
+	cases := []struct {
+		mode      ReasoningMode
+		reasoning string
+		text      string
+	}{
+		{ReasoningSeparate, "step 1\nso 4", "4"},
+		{ReasoningHidden, "", "4"},
+		{ReasoningSummaryOnly, "so 4", "4"},
+		{ReasoningInlineThinkTags, "", "<think>step 1\nso 4</think>\n\n4"},
+	}
+	for _, tc := range cases {
+		reasoning, text := PresentReasoning(tc.mode, "step 1\nso 4", "4")
+		if reasoning != tc.reasoning || text != tc.text {
+			t.Fatalf("%s: got reasoning=%q text=%q", tc.mode, reasoning, text)
+		}
+	}
+}
+
+func TestSummarizeReasoningCapsLongLines(t *testing.T) {
+	got := SummarizeReasoning("first\n" + strings.Repeat("字", 500) + "\n\n")
+	if !strings.HasSuffix(got, "…") || len([]rune(got)) != 401 {
+		t.Fatalf("expected capped summary, got %d runes", len([]rune(got)))
+	}
+}
+
+func TestReasoningPresenterInlineStream(t *testing.T) {
+	p := NewReasoningPresenter(ReasoningInlineThinkTags)
+	var out strings.Builder
+	for _, delta := range []string{"a", "b"} {
+		reasoning, inline := p.Thinking(delta)
+		if reasoning != "" {
+			t.Fatalf("inline mode must not use the reasoning channel, got %q", reasoning)
+		}
+		out.WriteString(inline)
+	}
+	_, closing := p.EndThinking()
+	out.WriteString(closing)
+	if out.String() != "<think>ab</think>\n\n" || p.Inline() != out.String() {
+		t.Fatalf("unexpected inline output %q (recorded %q)", out.String(), p.Inline())
+	}
+	if r, i := p.EndThinking(); r != "" || i != "" {
+		t.Fatalf("expected EndThinking to run once, got %q %q", r, i)
+	}
+	if r, i := p.Thinking("late"); r != "" || i != "" {
+		t.Fatalf("expected thinking after the answer to be dropped, got %q %q", r, i)
+	}
+}
+
+func TestReasoningPresenterSummaryAndSeparate(t *testing.T) {
+	p := NewReasoningPresenter(ReasoningSummaryOnly)
+	if r, _ := p.Thinking("look\nanswer is 4"); r != "" {
+		t.Fatalf("expected summary mode to hold thinking, got %q", r)
+	}
+	if r, _ := p.EndThinking(); r != "answer is 4" {
+		t.Fatalf("expected summary at end of thinking, got %q", r)
+	}
+
+	separate := NewReasoningPresenter(ReasoningSeparate)
+	if separate != nil {
+		t.Fatal("expected separate mode to need no presenter")
+	}
+	if r, i := separate.Thinking("x"); r != "x" || i != "" || separate.Mode() != ReasoningSeparate {
+		t.Fatalf("nil presenter must pass thinking through, got %q %q", r, i)
+	}
+}
@@ -1,5 +1,13 @@
 package util
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"ds2api/internal/prompt"
+)
+
 type StandardRequest struct {
 	Surface        string
 	RequestedModel string
@@ -7,12 +15,49 @@ type StandardRequest struct {
 	ResponseModel  string
 	Messages       []any
 	FinalPrompt    string
-	ToolNames      []string
-	ToolChoice     ToolChoicePolicy
-	Stream         bool
-	Thinking       bool
-	Search         bool
-	PassThrough    map[string]any
+	// PromptMessages are the messages FinalPrompt was built from, after
+	// context fitting. DroppedHistory holds the turns the summarize strategy
+	// still has to replace with a summary.
+	PromptMessages []map[string]any
+	DroppedHistory []map[string]any
+	// Template flattens PromptMessages into FinalPrompt; nil means the
+	// built-in template.
+	Template *prompt.ChatTemplate
+	// Context reports what fitting the prompt into the context window
+	// removed.
+	Context   ContextReport
+	ToolNames []string
+	// ToolSchemas are the argument schemas of the declared tools, which
+	// parsed calls are checked against.
+	ToolSchemas ToolSchemas
+	ToolChoice  ToolChoicePolicy
+	Stream      bool
+	Thinking    bool
+	Search      bool
+	// IncludeThoughts asks for reasoning as Gemini thought parts
+	// (thinkingConfig.includeThoughts).
+	IncludeThoughts bool
+	// ReasoningMode is how reasoning is presented to the client; empty
+	// means ReasoningSeparate.
+	ReasoningMode ReasoningMode
+	// Choices is the number of parallel completions requested via n /
+	// candidateCount. Zero and one both mean a single completion.
+	Choices int
+	// StopSequences are enforced locally because the DeepSeek web endpoint
+	// ignores them.
+	StopSequences []string
+	// MaxOutputTokens caps the estimated output locally; zero means no cap.
+	// MaxTokensIncludeThinking charges reasoning text against the cap, as
+	// max_completion_tokens, max_output_tokens, Claude and Gemini do.
+	MaxOutputTokens          int
+	MaxTokensIncludeThinking bool
+	// AccountGroup is the account group a routing rule binds the request
+	// to; empty means any account the caller may use.
+	AccountGroup string
+	// EndUser is the end user the client names (OpenAI user, Claude
+	// metadata.user_id), which account affinity may key on.
+	EndUser     string
+	PassThrough map[string]any
 }
 
 type ToolChoiceMode string
@@ -64,3 +109,109 @@ func (r StandardRequest) CompletionPayload(sessionID string) map[string]any {
 	}
 	return payload
 }
+
+// Fingerprint identifies the output r asks for: requests with the same
+// fingerprint send the same completion payload from the same surface and
+// shape the stream the same way. The template, reasoning mode and stop
+// sequences are applied locally, and again on a replay, but are part of the
+// fingerprint so requests that differ only in them keep separate entries.
+func (r StandardRequest) Fingerprint() string {
+	template := ""
+	if r.Template != nil {
+		template = r.Template.Name + "\x00" + r.Template.ImageLinks
+	}
+	b, _ := json.Marshal(map[string]any{
+		"surface":        r.Surface,
+		"model":          r.ResolvedModel,
+		"prompt":         r.FinalPrompt,
+		"template":       template,
+		"tools":          r.ToolNames,
+		"thinking":       r.Thinking,
+		"search":         r.Search,
+		"reasoning_mode": r.ReasoningMode,
+		"stop":           r.StopSequences,
+		"pass_through":   r.PassThrough,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ConversationKey identifies the conversation r continues by its first user
+// turn, which stays the same as the conversation grows. It is empty when r
+// has no messages.
+func (r StandardRequest) ConversationKey() string {
+	var first any
+	for _, m := range r.Messages {
+		msg, _ := m.(map[string]any)
+		if role, _ := msg["role"].(string); role == "user" {
+			first = msg
+			break
+		}
+	}
+	if first == nil {
+		if len(r.Messages) == 0 {
+			return ""
+		}
+		first = r.Messages[0]
+	}
+	b, _ := json.Marshal(map[string]any{"surface": r.Surface, "first": first})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Deterministic reports whether identical requests may share one output: a
+// single choice, temperature zero or unset, no web search, whose results
+// change between calls, and no tools or required tool choice, whose calls
+// act on the client's side and must not be answered from another request.
+func (r StandardRequest) Deterministic() bool {
+	if r.Search || r.Choices > 1 || len(r.ToolNames) > 0 || r.ToolChoice.IsRequired() {
+		return false
+	}
+	switch t := r.PassThrough["temperature"].(type) {
+	case nil:
+		return true
+	case float64:
+		return t == 0
+	case int:
+		return t == 0
+	case json.Number:
+		f, err := t.Float64()
+		return err == nil && f == 0
+	default:
+		return false
+	}
+}
+
+// StopSequencesFrom reads a stop value that may be a single string or a list
+// of strings (OpenAI stop, Claude stop_sequences, Gemini stopSequences).
+func StopSequencesFrom(raw any) []string {
+	switch v := raw.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// MaxTokensFrom reads a client token cap. Anything but a positive number
+// means no cap.
+func MaxTokensFrom(raw any) int {
+	if n := IntFrom(raw); n > 0 {
+		return n
+	}
+	return 0
+}
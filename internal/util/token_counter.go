@@ -0,0 +1,51 @@
+package util
+
+// TokenCounter accumulates the EstimateTokens approximation over text that
+// arrives in pieces, so a running total always matches EstimateTokens of the
+// concatenated text.
+type TokenCounter struct {
+	asciiChars    int
+	nonASCIIChars int
+}
+
+// Tokens returns the estimate for everything added so far.
+func (c *TokenCounter) Tokens() int {
+	if c.asciiChars == 0 && c.nonASCIIChars == 0 {
+		return 0
+	}
+	// ASCII: ~4 chars per token; non-ASCII (CJK): ~1.3 chars per token
+	n := c.asciiChars/4 + (c.nonASCIIChars*10+7)/13
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (c *TokenCounter) Add(text string) {
+	for _, r := range text {
+		c.addRune(r)
+	}
+}
+
+// AddWithin adds the longest prefix of text that keeps Tokens() at or below
+// limit and returns its length in bytes. The prefix always ends on a rune
+// boundary.
+func (c *TokenCounter) AddWithin(text string, limit int) int {
+	for i, r := range text {
+		saved := *c
+		c.addRune(r)
+		if c.Tokens() > limit {
+			*c = saved
+			return i
+		}
+	}
+	return len(text)
+}
+
+func (c *TokenCounter) addRune(r rune) {
+	if r < 128 {
+		c.asciiChars++
+	} else {
+		c.nonASCIIChars++
+	}
+}
@@ -0,0 +1,39 @@
+package util
+
+import "testing"
+
+func TestTokenCounterMatchesEstimateTokens(t *testing.T) {
+	pieces := []string{"hello ", "世界", ", this is ", "流式输出", " text"}
+	var c TokenCounter
+	joined := ""
+	for _, p := range pieces {
+		c.Add(p)
+		joined += p
+		if got, want := c.Tokens(), EstimateTokens(joined); got != want {
+			t.Fatalf("after %q: counter=%d estimate=%d", joined, got, want)
+		}
+	}
+}
+
+func TestTokenCounterAddWithinStopsAtLimit(t *testing.T) {
+	var c TokenCounter
+	n := c.AddWithin("abcdefghijklmnop", 2)
+	if n != 11 || c.Tokens() != 2 {
+		t.Fatalf("expected 11 bytes within 2 tokens, got n=%d tokens=%d", n, c.Tokens())
+	}
+	if n := c.AddWithin("xyz", 2); n != 0 {
+		t.Fatalf("expected nothing to fit once the limit is reached, got %d", n)
+	}
+	if n := c.AddWithin("xyz", 3); n != 3 {
+		t.Fatalf("expected a larger limit to admit more text, got %d", n)
+	}
+}
+
+func TestTokenCounterAddWithinKeepsRuneBoundary(t *testing.T) {
+	var c TokenCounter
+	text := "你好世界"
+	n := c.AddWithin(text, 2)
+	if n != len("你好世") {
+		t.Fatalf("expected three CJK runes to fit in 2 tokens, got %d bytes", n)
+	}
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"strings"
+
+	"ds2api/internal/config"
+	"ds2api/internal/prompt"
+)
+
+// ToolInstructions is the tool prompt selected for one request.
+type ToolInstructions struct {
+	Prompt  *prompt.ToolPrompt
+	Surface string
+	Model   string
+}
+
+// ParseToolPrompt parses a configured tool prompt.
+func ParseToolPrompt(t config.ToolPrompt) (*prompt.ToolPrompt, error) {
+	key := "tool\x00" + t.Name + "\x00" + t.Template
+	if cached, ok := chatTemplateCache.Get(key); ok {
+		return cached.(*prompt.ToolPrompt), nil
+	}
+	parsed, err := prompt.ParseToolPrompt(t.Name, t.Template)
+	if err != nil {
+		return nil, err
+	}
+	chatTemplateCache.Put(key, parsed)
+	return parsed, nil
+}
+
+// ToolInstructionsFor selects the tool prompt for a request on surface served
+// by model and made with key: the first matching configured tool prompt,
+// otherwise the surface's built-in one.
+func ToolInstructionsFor(reader config.ToolPromptReader, surface, model, key string) ToolInstructions {
+	out := ToolInstructions{Prompt: prompt.DefaultToolPrompt(surface), Surface: surface, Model: model}
+	if reader == nil {
+		return out
+	}
+	t, ok := config.SelectToolPrompt(reader.ToolPrompts(), surface, model, key)
+	if !ok {
+		return out
+	}
+	parsed, err := ParseToolPrompt(t)
+	if err != nil {
+		config.Logger.Warn("[tool_prompt] invalid template, using default", "name", t.Name, "error", err)
+		return out
+	}
+	out.Prompt = parsed
+	return out
+}
+
+// Render writes the tool instructions for tools under policy, falling back
+// to the surface's built-in prompt when the template fails to execute.
+func (t ToolInstructions) Render(tools []prompt.ToolSpec, policy ToolChoicePolicy) string {
+	data := prompt.ToolPromptData{Tools: tools, Mode: string(policy.Mode), Surface: t.Surface, Model: t.Model}
+	if data.Mode == "" {
+		data.Mode = string(ToolChoiceAuto)
+	}
+	if policy.Mode == ToolChoiceForced {
+		data.ForcedName = strings.TrimSpace(policy.ForcedName)
+	}
+	for _, tool := range tools {
+		data.ToolNames = append(data.ToolNames, tool.Name)
+	}
+	builtin := prompt.DefaultToolPrompt(t.Surface)
+	tp := t.Prompt
+	if tp == nil {
+		tp = builtin
+	}
+	out, err := tp.Render(data)
+	if err != nil {
+		config.Logger.Warn("[tool_prompt] render failed, using default", "name", tp.Name, "error", err)
+		out, _ = builtin.Render(data)
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"ds2api/internal/config"
+	"ds2api/internal/prompt"
+)
+
+type toolPromptStore []config.ToolPrompt
+
+func (s toolPromptStore) ToolPrompts() []config.ToolPrompt { return s }
+
+var toolPromptFixture = []prompt.ToolSpec{
+	{Name: "search", Description: "Search the web", Parameters: `{"type":"object"}`},
+	{Name: "fetch", Description: "Fetch a page", Parameters: "null"},
+}
+
+const legacyToolRules = "\n\nWhen you need to use tools, output ONLY this JSON format (no other text):\n{\"tool_calls\": [{\"name\": \"tool_name\", \"input\": {\"param\": \"value\"}}]}\n\nHistory markers in conversation:\n- [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] means a tool call you already made earlier.\n- [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] means the runtime returned a tool result (not user input).\n\nIMPORTANT:\n1) If calling tools, output ONLY the JSON. The response must start with { and end with }.\n2) After receiving a tool result, you MUST use it to produce the final answer.\n3) Only call another tool when the previous result is missing required data or returned an error.\n4) Do not repeat a tool call that is already satisfied by an existing [TOOL_RESULT_HISTORY] block."
+
+func TestDefaultToolPromptsMatchLegacyText(t *testing.T) {
+	tools := "You have access to these tools:\n\nTool: search\nDescription: Search the web\nParameters: {\"type\":\"object\"}\n\nTool: fetch\nDescription: Fetch a page\nParameters: null"
+	cases := []struct {
+		policy ToolChoicePolicy
+		want   string
+	}{
+		{DefaultToolChoicePolicy(), tools + legacyToolRules},
+		{ToolChoicePolicy{Mode: ToolChoiceRequired}, tools + legacyToolRules + "\n5) For this response, you MUST call at least one tool from the allowed list."},
+		{ToolChoicePolicy{Mode: ToolChoiceForced, ForcedName: " search "}, tools + legacyToolRules + "\n5) For this response, you MUST call exactly this tool name: search\n6) Do not call any other tool."},
+	}
+	for _, surface := range []string{config.ModelSurfaceOpenAI, config.ModelSurfaceGemini} {
+		instructions := ToolInstructionsFor(nil, surface, "deepseek-chat", "")
+		for _, tc := range cases {
+			if got := instructions.Render(toolPromptFixture, tc.policy); got != tc.want {
+				t.Fatalf("%s %s prompt changed:\n got %q\nwant %q", surface, tc.policy.Mode, got, tc.want)
+			}
+		}
+	}
+
+	claude := ToolInstructionsFor(nil, config.ModelSurfaceClaude, "deepseek-chat", "").Render(toolPromptFixture, DefaultToolChoicePolicy())
+	want := "You are Claude, a helpful AI assistant. You have access to these tools:\n\n" +
+		"Tool: search\nDescription: Search the web\nParameters: {\"type\":\"object\"}\n\n" +
+		"Tool: fetch\nDescription: Fetch a page\nParameters: null\n\n" +
+		"When you need to use tools, you can call multiple tools in one response. Output ONLY JSON like {\"tool_calls\":[{\"name\":\"tool\",\"input\":{}}]}\n\n" +
+		"History markers in conversation: [TOOL_CALL_HISTORY]...[/TOOL_CALL_HISTORY] are your previous tool calls; [TOOL_RESULT_HISTORY]...[/TOOL_RESULT_HISTORY] are runtime tool outputs, not user input.\n\n" +
+		"After a valid [TOOL_RESULT_HISTORY], continue with final answer instead of repeating the same call unless required fields are still missing."
+	if claude != want {
+		t.Fatalf("claude prompt changed:\n got %q\nwant %q", claude, want)
+	}
+}
+
+func TestToolInstructionsForSelectsBySurfaceModelAndKey(t *testing.T) {
+	store := toolPromptStore{
+		{Name: "claude-terse", Surfaces: []string{"claude"}, Template: "claude"},
+		{Name: "reasoner", Models: []string{"deepseek-reasoner*"}, Template: "Tools: {{join .ToolNames \", \"}} ({{.Mode}}{{with .ForcedName}} {{.}}{{end}}) on {{.Surface}}/{{.Model}}"},
+		{Name: "vip", Keys: []string{"key-vip"}, Template: "vip"},
+	}
+	forced := ToolChoicePolicy{Mode: ToolChoiceForced, ForcedName: "fetch"}
+	if got := ToolInstructionsFor(store, config.ModelSurfaceOpenAI, "deepseek-reasoner", "").Render(toolPromptFixture, forced); got != "Tools: search, fetch (forced fetch) on openai/deepseek-reasoner" {
+		t.Fatalf("unexpected reasoner prompt %q", got)
+	}
+	if got := ToolInstructionsFor(store, config.ModelSurfaceClaude, "deepseek-reasoner", "").Prompt.Name; got != "claude-terse" {
+		t.Fatalf("expected surface match first, got %s", got)
+	}
+	if got := ToolInstructionsFor(store, config.ModelSurfaceGemini, "deepseek-chat", "key-vip").Prompt.Name; got != "vip" {
+		t.Fatalf("expected key match, got %s", got)
+	}
+	if got := ToolInstructionsFor(store, config.ModelSurfaceGemini, "deepseek-chat", "").Render(toolPromptFixture, DefaultToolChoicePolicy()); !strings.HasPrefix(got, "You have access to these tools:") {
+		t.Fatalf("expected built-in prompt, got %q", got)
+	}
+}
+
+func TestToolInstructionsFallBackToDefault(t *testing.T) {
+	broken := toolPromptStore{{Name: "broken", Template: "{{.Tools"}}
+	if got := ToolInstructionsFor(broken, config.ModelSurfaceClaude, "deepseek-chat", "").Prompt.Name; got != "default" {
+		t.Fatalf("unparsable tool prompt should fall back to default, got %s", got)
+	}
+	failing := toolPromptStore{{Name: "failing", Template: "{{index .Tools 5}}"}}
+	got := ToolInstructionsFor(failing, config.ModelSurfaceClaude, "deepseek-chat", "").Render(toolPromptFixture, DefaultToolChoicePolicy())
+	if !strings.HasPrefix(got, "You are Claude") {
+		t.Fatalf("render failure should fall back to the surface default, got %q", got)
+	}
+}
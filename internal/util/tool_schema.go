@@ -0,0 +1,148 @@
+package util
+
+import "strings"
+
+// ToolSchema is the part of a tool's JSON schema that tool-call arguments
+// are checked against: type, properties, required, enum and items.
+type ToolSchema struct {
+	Types      []string               `json:"type,omitempty"`
+	Properties map[string]*ToolSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Enum       []any                  `json:"enum,omitempty"`
+	Items      *ToolSchema            `json:"items,omitempty"`
+	// Closed rejects undeclared properties. It is set by
+	// additionalProperties: false and on every object of a strict tool.
+	Closed bool `json:"closed,omitempty"`
+}
+
+// ToolSchemas are the compiled argument schemas of a request's tools, keyed
+// by tool name. Tools without a schema have no entry.
+type ToolSchemas map[string]*ToolSchema
+
+// CompileToolSchemas compiles the argument schemas of declared tools. It
+// accepts OpenAI tools ({"type":"function","function":{...}} or flat),
+// Claude tools with input_schema and converted Gemini declarations.
+func CompileToolSchemas(raw any) ToolSchemas {
+	tools, _ := raw.([]any)
+	out := ToolSchemas{}
+	for _, item := range tools {
+		tool, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		fn, _ := tool["function"].(map[string]any)
+		if len(fn) == 0 {
+			fn = tool
+		}
+		name, _ := fn["name"].(string)
+		name = strings.TrimSpace(name)
+		schema, ok := fn["parameters"].(map[string]any)
+		if !ok {
+			schema, ok = fn["input_schema"].(map[string]any)
+		}
+		if name == "" || !ok {
+			continue
+		}
+		out[name] = compileToolSchema(schema, ToBool(fn["strict"]))
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func compileToolSchema(raw map[string]any, strict bool) *ToolSchema {
+	s := &ToolSchema{}
+	switch t := raw["type"].(type) {
+	case string:
+		s.Types = []string{strings.ToLower(t)}
+	case []any:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				s.Types = append(s.Types, strings.ToLower(name))
+			}
+		}
+	}
+	if props, ok := raw["properties"].(map[string]any); ok {
+		s.Properties = make(map[string]*ToolSchema, len(props))
+		for name, v := range props {
+			if sub, ok := v.(map[string]any); ok {
+				s.Properties[name] = compileToolSchema(sub, strict)
+			}
+		}
+		if len(s.Types) == 0 {
+			s.Types = []string{"object"}
+		}
+	}
+	if items, ok := raw["items"].(map[string]any); ok {
+		s.Items = compileToolSchema(items, strict)
+	}
+	if required, ok := raw["required"].([]any); ok {
+		for _, v := range required {
+			if name, ok := v.(string); ok {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	if enum, ok := raw["enum"].([]any); ok {
+		s.Enum = enum
+	}
+	if extra, ok := raw["additionalProperties"].(bool); ok && !extra {
+		s.Closed = true
+	}
+	if strict && s.hasType("object") {
+		s.Closed = true
+	}
+	return s
+}
+
+// Validate checks call against the schema of its tool, coercing values that
+// are unambiguously meant as the declared type: "42" for a number, "true"
+// for a boolean, a single value for an array, a JSON string for an object.
+// It returns the coerced call and what is still wrong with it.
+func (s ToolSchemas) Validate(call ParsedToolCall) (ParsedToolCall, []string) {
+	schema := s[call.Name]
+	if schema == nil {
+		return call, nil
+	}
+	var problems []string
+	input, _ := cloneJSONValue(call.Input).(map[string]any)
+	if input == nil {
+		input = map[string]any{}
+	}
+	if coerced, ok := schema.check(input, "arguments", &problems).(map[string]any); ok {
+		input = coerced
+	}
+	call.Input = input
+	return call, problems
+}
+
+// cloneJSONValue copies decoded JSON so coercion leaves the parsed call as
+// it was.
+func cloneJSONValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = cloneJSONValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = cloneJSONValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (s *ToolSchema) hasType(name string) bool {
+	for _, t := range s.Types {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// check validates v at path, appending problems, and returns v coerced
+// toward the schema.
+func (s *ToolSchema) check(v any, path string, problems *[]string) any {
+	if len(s.Types) > 0 && !s.matchesType(v) {
+		coerced, ok := s.coerce(v)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be %s, got %s", path, strings.Join(s.Types, " or "), jsonTypeName(v)))
+			return v
+		}
+		v = coerced
+	}
+	if len(s.Enum) > 0 && !enumContains(s.Enum, v) {
+		allowed, _ := json.Marshal(s.Enum)
+		*problems = append(*problems, fmt.Sprintf("%s must be one of %s", path, allowed))
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s is required", path, name))
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, declared := s.Properties[k]
+			switch {
+			case declared:
+				val[k] = sub.check(val[k], path+"."+k, problems)
+			case s.Closed:
+				*problems = append(*problems, fmt.Sprintf("%s.%s is not an allowed property", path, k))
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i := range val {
+				val[i] = s.Items.check(val[i], fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+	return v
+}
+
+func (s *ToolSchema) matchesType(v any) bool {
+	for _, t := range s.Types {
+		switch t {
+		case "object":
+			if _, ok := v.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]any); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "null":
+			if v == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// coerce converts v to the first declared type it unambiguously stands for.
+func (s *ToolSchema) coerce(v any) (any, bool) {
+	str, isString := v.(string)
+	str = strings.TrimSpace(str)
+	for _, t := range s.Types {
+		switch t {
+		case "number", "integer":
+			if !isString {
+				continue
+			}
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || (t == "integer" && f != math.Trunc(f)) {
+				continue
+			}
+			return f, true
+		case "boolean":
+			if isString && (str == "true" || str == "false") {
+				return str == "true", true
+			}
+		case "string":
+			switch val := v.(type) {
+			case float64:
+				return strconv.FormatFloat(val, 'f', -1, 64), true
+			case bool:
+				return strconv.FormatBool(val), true
+			}
+		case "object", "array":
+			if isString {
+				var decoded any
+				if err := json.Unmarshal([]byte(str), &decoded); err == nil && s.matchesType(decoded) {
+					return decoded, true
+				}
+			}
+			if t == "array" && v != nil {
+				if s.Items != nil && len(s.Items.Types) > 0 && !s.Items.matchesType(v) {
+					if _, ok := s.Items.coerce(v); !ok {
+						continue
+					}
+				}
+				return []any{v}, true
+			}
+		}
+	}
+	return v, false
+}
+
+func enumContains(enum []any, v any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeName(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testToolSchemas() ToolSchemas {
+	return CompileToolSchemas([]any{
+		map[string]any{"type": "function", "function": map[string]any{
+			"name": "search",
+			"parameters": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{"type": "string"},
+					"limit": map[string]any{"type": "integer"},
+					"exact": map[string]any{"type": "boolean"},
+					"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"mode":  map[string]any{"type": "string", "enum": []any{"web", "news"}},
+				},
+				"required": []any{"query"},
+			},
+		}},
+		map[string]any{"name": "read_file", "strict": true, "input_schema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"path": map[string]any{"type": "string"}},
+			"required":   []any{"path"},
+		}},
+		map[string]any{"name": "no_schema"},
+	})
+}
+
+func TestCompileToolSchemasShapes(t *testing.T) {
+	schemas := testToolSchemas()
+	if len(schemas) != 2 || schemas["search"] == nil || schemas["read_file"] == nil {
+		t.Fatalf("expected schemas for search and read_file, got %#v", schemas)
+	}
+	if schemas["search"].Closed || !schemas["read_file"].Closed {
+		t.Fatalf("expected only the strict tool to be closed")
+	}
+	gemini := CompileToolSchemas([]any{map[string]any{"function": map[string]any{"name": "f", "parameters": map[string]any{
+		"type": "OBJECT", "properties": map[string]any{"n": map[string]any{"type": "NUMBER"}},
+	}}}})
+	if got := gemini["f"].Properties["n"].Types; !reflect.DeepEqual(got, []string{"number"}) {
+		t.Fatalf("expected Gemini type names lower-cased, got %v", got)
+	}
+}
+
+func TestToolSchemasValidateCoerces(t *testing.T) {
+	call := ParsedToolCall{Name: "search", Input: map[string]any{"query": 42.0, "limit": "5", "exact": "true", "tags": "go"}}
+	got, problems := testToolSchemas().Validate(call)
+	if len(problems) != 0 {
+		t.Fatalf("expected recoverable values to be coerced, got %v", problems)
+	}
+	want := map[string]any{"query": "42", "limit": 5.0, "exact": true, "tags": []any{"go"}}
+	if !reflect.DeepEqual(got.Input, want) {
+		t.Fatalf("unexpected coerced input %#v", got.Input)
+	}
+	if call.Input["limit"] != "5" {
+		t.Fatalf("coercion must not modify the parsed call")
+	}
+}
+
+func TestToolSchemasValidateReportsProblems(t *testing.T) {
+	schemas := testToolSchemas()
+	_, problems := schemas.Validate(ParsedToolCall{Name: "search", Input: map[string]any{"limit": "five", "mode": "images"}})
+	joined := strings.Join(problems, "; ")
+	for _, want := range []string{"arguments.query is required", "arguments.limit must be integer", `arguments.mode must be one of ["web","news"]`} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in %q", want, joined)
+		}
+	}
+	_, problems = schemas.Validate(ParsedToolCall{Name: "read_file", Input: map[string]any{"path": "a", "mode": "r"}})
+	if len(problems) != 1 || problems[0] != "arguments.mode is not an allowed property" {
+		t.Fatalf("expected strict tool to reject extra properties, got %v", problems)
+	}
+	if _, problems = schemas.Validate(ParsedToolCall{Name: "no_schema", Input: map[string]any{"x": 1.0}}); problems != nil {
+		t.Fatalf("expected tools without a schema to pass, got %v", problems)
+	}
+}
+
+func TestToolCallReaderRepairsAndDrops(t *testing.T) {
+	reader := NewToolCallReader(ToolDialectJSON, testToolSchemas())
+	repairs := 0
+	reader.Attempts = 2
+	reader.Repair = func(call ParsedToolCall, problems []string) (ParsedToolCall, error) {
+		repairs++
+		if call.Name == "read_file" {
+			return ParsedToolCall{}, errors.New("upstream down")
+		}
+		return ParsedToolCall{Name: "search", Input: map[string]any{"query": "fixed"}}, nil
+	}
+	text := `{"tool_calls":[{"name":"search","input":{"limit":3}},{"name":"read_file","input":{}},{"name":"search","input":{"query":"ok","limit":"2"}}]}`
+	calls := reader.Parse(text, []string{"search", "read_file"})
+	if len(calls) != 2 || calls[0].Input["query"] != "fixed" || calls[1].Input["limit"] != 2.0 {
+		t.Fatalf("expected the repaired call and the coerced call, got %#v", calls)
+	}
+	if repairs != 2 {
+		t.Fatalf("expected one repair per invalid call, got %d", repairs)
+	}
+	// The same output read again, as at the end of a stream, is not repaired twice.
+	if again := reader.Parse(text, []string{"search", "read_file"}); len(again) != 2 || repairs != 2 {
+		t.Fatalf("expected checked calls to be remembered, got %d calls after %d repairs", len(again), repairs)
+	}
+}
+
+func TestToolRepairRequestAppendsTurns(t *testing.T) {
+	req := StandardRequest{PromptMessages: []map[string]any{{"role": "user", "content": "find go"}}}
+	out := req.ToolRepairRequest(ParsedToolCall{Name: "search", Input: map[string]any{}}, []string{"arguments.query is required"})
+	for _, want := range []string{"find go", `{"tool_calls":[{"input":{},"name":"search"}]}`, "- arguments.query is required", "Call search again"} {
+		if !strings.Contains(out.FinalPrompt, want) {
+			t.Fatalf("expected %q in repair prompt %q", want, out.FinalPrompt)
+		}
+	}
+	if len(req.PromptMessages) != 1 {
+		t.Fatalf("repair request must not modify the original messages")
+	}
+}
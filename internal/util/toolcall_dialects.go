@@ -0,0 +1,92 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToolDialects is the set of tool-call styles recognised in model output.
+// The zero value means JSON only, which is what the parser always accepted.
+type ToolDialects uint8
+
+const (
+	// ToolDialectJSON is {"tool_calls":[...]}, bare call objects and
+	// OpenAI-style {"function_call":{...}} objects.
+	ToolDialectJSON ToolDialects = 1 << iota
+	// ToolDialectXML is <tool_call>...</tool_call>, optionally wrapped in
+	// <tool_calls>, and <function_calls><invoke name="...">...</invoke>.
+	ToolDialectXML
+	// ToolDialectFunctionTag is <function=name>...</function>.
+	ToolDialectFunctionTag
+)
+
+// AllToolDialects enables every supported dialect.
+const AllToolDialects = ToolDialectJSON | ToolDialectXML | ToolDialectFunctionTag
+
+var toolDialectNames = []struct {
+	name    string
+	dialect ToolDialects
+}{
+	{"json", ToolDialectJSON},
+	{"xml", ToolDialectXML},
+	{"function_tag", ToolDialectFunctionTag},
+}
+
+// ParseToolcallMode maps toolcall.mode to the dialects it enables.
+// "feature_match" (the default) and "off" keep the JSON dialect; "all"
+// enables every dialect; otherwise the mode is a comma-separated list of
+// json, xml and function_tag.
+func ParseToolcallMode(mode string) (ToolDialects, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", "feature_match", "off":
+		return ToolDialectJSON, nil
+	case "all":
+		return AllToolDialects, nil
+	}
+	var out ToolDialects
+	for _, part := range strings.Split(mode, ",") {
+		part = strings.TrimSpace(part)
+		found := false
+		for _, d := range toolDialectNames {
+			if d.name == part {
+				out |= d.dialect
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown tool-call dialect %q", part)
+		}
+	}
+	return out, nil
+}
+
+// ToolDialectsForMode is ParseToolcallMode for runtime use: an invalid mode
+// falls back to JSON.
+func ToolDialectsForMode(mode string) ToolDialects {
+	d, err := ParseToolcallMode(mode)
+	if err != nil {
+		return ToolDialectJSON
+	}
+	return d
+}
+
+// Has reports whether d enables dialect.
+func (d ToolDialects) Has(dialect ToolDialects) bool {
+	if d == 0 {
+		d = ToolDialectJSON
+	}
+	return d&dialect != 0
+}
+
+// Names lists the enabled dialects by their toolcall.mode names.
+func (d ToolDialects) Names() []string {
+	out := make([]string, 0, len(toolDialectNames))
+	for _, n := range toolDialectNames {
+		if d.Has(n.dialect) {
+			out = append(out, n.name)
+		}
+	}
+	return out
+}
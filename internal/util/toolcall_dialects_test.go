@@ -0,0 +1,85 @@
+package util
+
+import "testing"
+
+func TestParseToolcallMode(t *testing.T) {
+	cases := []struct {
+		mode string
+		want ToolDialects
+	}{
+		{"", ToolDialectJSON},
+		{"feature_match", ToolDialectJSON},
+		{"off", ToolDialectJSON},
+		{"all", AllToolDialects},
+		{"json, xml", ToolDialectJSON | ToolDialectXML},
+		{"function_tag", ToolDialectFunctionTag},
+	}
+	for _, tc := range cases {
+		got, err := ParseToolcallMode(tc.mode)
+		if err != nil || got != tc.want {
+			t.Fatalf("%q: expected %v, got %v (%v)", tc.mode, tc.want, got, err)
+		}
+	}
+	if _, err := ParseToolcallMode("json,yaml"); err == nil {
+		t.Fatalf("expected unknown dialect to be rejected")
+	}
+}
+
+func TestParseToolCallsXMLDialect(t *testing.T) {
+	text := "Let me check.\n<tool_call>\n{\"name\": \"search\", \"arguments\": {\"q\": \"go\"}}\n</tool_call>\n<tool_call><name>read_file</name><arguments>{\"path\":\"a.txt\"}</arguments></tool_call>"
+	if calls := ParseToolCalls(text, []string{"search", "read_file"}); len(calls) != 0 {
+		t.Fatalf("JSON-only parsing must ignore tags, got %#v", calls)
+	}
+	calls := ParseToolCallsWith(text, []string{"search", "read_file"}, AllToolDialects)
+	if len(calls) != 2 || calls[0].Name != "search" || calls[0].Input["q"] != "go" || calls[1].Name != "read_file" || calls[1].Input["path"] != "a.txt" {
+		t.Fatalf("unexpected calls: %#v", calls)
+	}
+}
+
+func TestParseToolCallsInvokeDialect(t *testing.T) {
+	text := `<function_calls><invoke name="search"><parameter name="q">golang</parameter><parameter name="limit">5</parameter></invoke></function_calls>`
+	calls := ParseToolCallsWith(text, []string{"search"}, ToolDialectXML)
+	if len(calls) != 1 || calls[0].Input["q"] != "golang" || calls[0].Input["limit"] != float64(5) {
+		t.Fatalf("unexpected calls: %#v", calls)
+	}
+}
+
+func TestParseToolCallsFunctionTagDialect(t *testing.T) {
+	text := "<function=search>\n<parameter=q>\nweather in Paris\n</parameter>\n</function>\n<function=read_file>{\"path\":\"b.txt\"}</function>"
+	if calls := ParseToolCallsWith(text, []string{"search"}, ToolDialectXML); len(calls) != 0 {
+		t.Fatalf("function tags need their own dialect, got %#v", calls)
+	}
+	calls := ParseToolCallsWith(text, []string{"search", "read_file"}, ToolDialectFunctionTag)
+	if len(calls) != 2 || calls[0].Input["q"] != "weather in Paris" || calls[1].Input["path"] != "b.txt" {
+		t.Fatalf("unexpected calls: %#v", calls)
+	}
+}
+
+func TestParseToolCallsFunctionCallObject(t *testing.T) {
+	text := `{"function_call": {"name": "search", "arguments": "{\"q\":\"go\"}"}}`
+	calls := ParseToolCalls(text, []string{"search"})
+	if len(calls) != 1 || calls[0].Input["q"] != "go" {
+		t.Fatalf("unexpected calls: %#v", calls)
+	}
+}
+
+func TestParseToolCallsIgnoresFencedTags(t *testing.T) {
+	text := "Example:\n```xml\n<tool_call>{\"name\":\"search\",\"arguments\":{}}</tool_call>\n```"
+	if calls := ParseToolCallsWith(text, []string{"search"}, AllToolDialects); len(calls) != 0 {
+		t.Fatalf("fenced tag example must be ignored, got %#v", calls)
+	}
+}
+
+func TestToolTagRunEnd(t *testing.T) {
+	text := "<tool_call>{}</tool_call>\n<tool_call>{}</tool_call> done"
+	if end, ok := ToolTagRunEnd(text, 0, AllToolDialects, false); !ok || text[end:] != " done" {
+		t.Fatalf("expected run to end before trailing text, got %d %v", end, ok)
+	}
+	partial := "<tool_call>{}</tool_call>\n<tool_"
+	if _, ok := ToolTagRunEnd(partial, 0, AllToolDialects, false); ok {
+		t.Fatalf("a partial opener may continue the run")
+	}
+	if end, ok := ToolTagRunEnd(partial, 0, AllToolDialects, true); !ok || end != len("<tool_call>{}</tool_call>") {
+		t.Fatalf("final run should end after the last closed tag, got %d %v", end, ok)
+	}
+}
@@ -0,0 +1,156 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"ds2api/internal/config"
+)
+
+// MaxToolRepairAttempts caps toolcall.repair_attempts.
+const MaxToolRepairAttempts = 3
+
+const toolRepairInstruction = "The arguments of your call to %s do not match its parameter schema:\n- %s\n\n" +
+	"Call %s again with corrected arguments. Output ONLY JSON like {\"tool_calls\":[{\"name\":\"%s\",\"input\":{}}]}."
+
+// ToolCallRepairFunc asks the model to correct call, which failed its
+// schema with problems, and returns the corrected call.
+type ToolCallRepairFunc func(call ParsedToolCall, problems []string) (ParsedToolCall, error)
+
+// ToolCallReader reads the tool calls of one request out of model output:
+// it parses the enabled dialects and checks the calls against the declared
+// schemas. The zero value parses JSON and checks nothing.
+type ToolCallReader struct {
+	Dialects ToolDialects
+	Schemas  ToolSchemas
+	// Repair, when set, gives a call that still fails its schema after
+	// coercion up to Attempts round-trips to the model.
+	Repair   ToolCallRepairFunc
+	Attempts int
+
+	checked *toolCallMemo
+}
+
+// toolCallMemo remembers checked calls so a call seen by the stream sieve and
+// again in the final text is repaired once.
+type toolCallMemo struct {
+	mu      sync.Mutex
+	results map[string]toolCallCheck
+}
+
+type toolCallCheck struct {
+	call ParsedToolCall
+	ok   bool
+}
+
+// NewToolCallReader returns a reader for one request. Checked calls are
+// remembered for the lifetime of the reader.
+func NewToolCallReader(dialects ToolDialects, schemas ToolSchemas) ToolCallReader {
+	return ToolCallReader{Dialects: dialects, Schemas: schemas, checked: &toolCallMemo{results: map[string]toolCallCheck{}}}
+}
+
+// Parse returns the checked tool calls in text.
+func (r ToolCallReader) Parse(text string, toolNames []string) []ParsedToolCall {
+	return r.ParseDetailed(text, toolNames).Calls
+}
+
+// ParseDetailed is ParseToolCallsDetailedWith followed by Check.
+func (r ToolCallReader) ParseDetailed(text string, toolNames []string) ToolCallParseResult {
+	result := ParseToolCallsDetailedWith(text, toolNames, r.Dialects)
+	result.Calls = r.Check(result.Calls)
+	return result
+}
+
+// Check validates calls against their schemas. Recoverable mistakes are
+// coerced, invalid calls go through the repair round-trip and calls that are
+// still invalid are dropped, so clients never receive arguments their tool
+// did not declare.
+func (r ToolCallReader) Check(calls []ParsedToolCall) []ParsedToolCall {
+	if len(r.Schemas) == 0 || len(calls) == 0 {
+		return calls
+	}
+	out := make([]ParsedToolCall, 0, len(calls))
+	for _, call := range calls {
+		if checked, ok := r.checkOne(call); ok {
+			out = append(out, checked)
+		}
+	}
+	return out
+}
+
+// CheckWritten checks a call whose arguments the client already has as the
+// model wrote them, such as one streamed as argument deltas. Coercion and
+// repair cannot reach those arguments, so a call that needs either fails.
+func (r ToolCallReader) CheckWritten(call ParsedToolCall) []string {
+	if call.Input == nil {
+		call.Input = map[string]any{}
+	}
+	checked, problems := r.Schemas.Validate(call)
+	if len(problems) == 0 && !reflect.DeepEqual(checked.Input, call.Input) {
+		problems = append(problems, "arguments do not have the declared types")
+	}
+	if len(problems) > 0 {
+		config.Logger.Warn("[toolcall] streamed tool call has invalid arguments", "tool", call.Name, "problems", strings.Join(problems, "; "))
+	}
+	return problems
+}
+
+func (r ToolCallReader) checkOne(call ParsedToolCall) (ParsedToolCall, bool) {
+	key := ""
+	if r.checked != nil {
+		input, _ := json.Marshal(call.Input)
+		key = call.Name + "\x00" + string(input)
+		r.checked.mu.Lock()
+		prev, seen := r.checked.results[key]
+		r.checked.mu.Unlock()
+		if seen {
+			return prev.call, prev.ok
+		}
+	}
+	checked, problems := r.Schemas.Validate(call)
+	for attempt := 0; len(problems) > 0 && r.Repair != nil && attempt < r.Attempts; attempt++ {
+		repaired, err := r.Repair(checked, problems)
+		if err != nil {
+			config.Logger.Warn("[toolcall] repair failed", "tool", call.Name, "attempt", attempt+1, "error", err)
+			break
+		}
+		if repaired.Name != call.Name {
+			continue
+		}
+		checked, problems = r.Schemas.Validate(repaired)
+	}
+	ok := len(problems) == 0
+	if !ok {
+		config.Logger.Warn("[toolcall] dropped tool call with invalid arguments", "tool", call.Name, "problems", strings.Join(problems, "; "))
+	}
+	if r.checked != nil {
+		r.checked.mu.Lock()
+		r.checked.results[key] = toolCallCheck{call: checked, ok: ok}
+		r.checked.mu.Unlock()
+	}
+	return checked, ok
+}
+
+// ToolRepairRequest is the request that asks the model to correct call: the
+// original conversation, the invalid call as the assistant's reply and the
+// validation problems as the next user turn.
+func (r StandardRequest) ToolRepairRequest(call ParsedToolCall, problems []string) StandardRequest {
+	input, _ := json.Marshal(call.Input)
+	reply, _ := json.Marshal(map[string]any{"tool_calls": []any{map[string]any{"name": call.Name, "input": json.RawMessage(input)}}})
+	messages := append(slices.Clone(r.PromptMessages),
+		map[string]any{"role": "assistant", "content": string(reply)},
+		map[string]any{"role": "user", "content": fmt.Sprintf(toolRepairInstruction, call.Name, strings.Join(problems, "\n- "), call.Name, call.Name)},
+	)
+	out := StandardRequest{
+		Surface:       r.Surface,
+		ResolvedModel: r.ResolvedModel,
+		Template:      r.Template,
+		PassThrough:   r.PassThrough,
+	}
+	out.FinalPrompt = out.renderPrompt(messages)
+	return out
+}
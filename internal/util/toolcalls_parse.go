@@ -22,6 +22,18 @@ func ParseToolCalls(text string, availableToolNames []string) []ParsedToolCall {
 }
 
 func ParseToolCallsDetailed(text string, availableToolNames []string) ToolCallParseResult {
+	return ParseToolCallsDetailedWith(text, availableToolNames, ToolDialectJSON)
+}
+
+// ParseToolCallsWith is ParseToolCalls for the given dialects.
+func ParseToolCallsWith(text string, availableToolNames []string, dialects ToolDialects) []ParsedToolCall {
+	return ParseToolCallsDetailedWith(text, availableToolNames, dialects).Calls
+}
+
+// ParseToolCallsDetailedWith is ParseToolCallsDetailed for the given
+// dialects. Tool-call tags are explicit, so they are tried before the JSON
+// candidates, which would otherwise pick up a JSON body inside a tag.
+func ParseToolCallsDetailedWith(text string, availableToolNames []string, dialects ToolDialects) ToolCallParseResult {
 	result := ToolCallParseResult{}
 	if strings.TrimSpace(text) == "" {
 		return result
@@ -30,20 +42,21 @@ func ParseToolCallsDetailed(text string, availableToolNames []string) ToolCallPa
 	if strings.TrimSpace(text) == "" {
 		return result
 	}
-	result.SawToolCallSyntax = strings.Contains(strings.ToLower(text), "tool_calls")
+	result.SawToolCallSyntax = sawToolCallSyntax(text, dialects)
 
-	candidates := buildToolCallCandidates(text)
-	var parsed []ParsedToolCall
-	for _, candidate := range candidates {
-		if tc := parseToolCallsPayload(candidate); len(tc) > 0 {
-			parsed = tc
-			result.SawToolCallSyntax = true
-			break
+	parsed := parseToolTagCalls(text, dialects)
+	if len(parsed) == 0 && dialects.Has(ToolDialectJSON) {
+		for _, candidate := range buildToolCallCandidates(text) {
+			if tc := parseToolCallsPayload(candidate); len(tc) > 0 {
+				parsed = tc
+				break
+			}
 		}
 	}
 	if len(parsed) == 0 {
 		return result
 	}
+	result.SawToolCallSyntax = true
 
 	calls, rejectedNames := filterToolCallsDetailed(parsed, availableToolNames)
 	result.Calls = calls
@@ -57,6 +70,13 @@ func ParseStandaloneToolCalls(text string, availableToolNames []string) []Parsed
 }
 
 func ParseStandaloneToolCallsDetailed(text string, availableToolNames []string) ToolCallParseResult {
+	return ParseStandaloneToolCallsDetailedWith(text, availableToolNames, ToolDialectJSON)
+}
+
+// ParseStandaloneToolCallsDetailedWith is ParseStandaloneToolCallsDetailed
+// for the given dialects: text must be a single JSON payload or start with a
+// tool-call tag.
+func ParseStandaloneToolCallsDetailedWith(text string, availableToolNames []string, dialects ToolDialects) ToolCallParseResult {
 	result := ToolCallParseResult{}
 	trimmed := strings.TrimSpace(text)
 	if trimmed == "" {
@@ -65,28 +85,34 @@ func ParseStandaloneToolCallsDetailed(text string, availableToolNames []string)
 	if looksLikeToolExampleContext(trimmed) {
 		return result
 	}
-	result.SawToolCallSyntax = strings.Contains(strings.ToLower(trimmed), "tool_calls")
-	candidates := []string{trimmed}
-	for _, candidate := range candidates {
-		candidate = strings.TrimSpace(candidate)
-		if candidate == "" {
-			continue
-		}
-		if !strings.HasPrefix(candidate, "{") && !strings.HasPrefix(candidate, "[") {
-			continue
-		}
-		if parsed := parseToolCallsPayload(candidate); len(parsed) > 0 {
-			result.SawToolCallSyntax = true
-			calls, rejectedNames := filterToolCallsDetailed(parsed, availableToolNames)
-			result.Calls = calls
-			result.RejectedToolNames = rejectedNames
-			result.RejectedByPolicy = len(rejectedNames) > 0 && len(calls) == 0
-			return result
+	result.SawToolCallSyntax = sawToolCallSyntax(trimmed, dialects)
+	var parsed []ParsedToolCall
+	switch {
+	case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+		if dialects.Has(ToolDialectJSON) {
+			parsed = parseToolCallsPayload(trimmed)
 		}
+	case FindToolTagStart(trimmed, dialects) == 0:
+		parsed = parseToolTagCalls(trimmed, dialects)
+	}
+	if len(parsed) == 0 {
+		return result
 	}
+	result.SawToolCallSyntax = true
+	calls, rejectedNames := filterToolCallsDetailed(parsed, availableToolNames)
+	result.Calls = calls
+	result.RejectedToolNames = rejectedNames
+	result.RejectedByPolicy = len(rejectedNames) > 0 && len(calls) == 0
 	return result
 }
 
+func sawToolCallSyntax(text string, dialects ToolDialects) bool {
+	if dialects.Has(ToolDialectJSON) && strings.Contains(strings.ToLower(text), "tool_calls") {
+		return true
+	}
+	return FindToolTagStart(text, dialects) >= 0
+}
+
 func filterToolCallsDetailed(parsed []ParsedToolCall, availableToolNames []string) ([]ParsedToolCall, []string) {
 	allowed := map[string]struct{}{}
 	allowedCanonical := map[string]string{}
@@ -154,6 +180,11 @@ func parseToolCallsPayload(payload string) []ParsedToolCall {
 		if tc, ok := v["tool_calls"]; ok {
 			return parseToolCallList(tc)
 		}
+		if fc, ok := v["function_call"].(map[string]any); ok {
+			if parsed, ok := parseToolCallItem(fc); ok {
+				return []ParsedToolCall{parsed}
+			}
+		}
 		if parsed, ok := parseToolCallItem(v); ok {
 			return []ParsedToolCall{parsed}
 		}
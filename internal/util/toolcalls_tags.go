@@ -0,0 +1,205 @@
+package util
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var (
+	toolTagOpenPattern    = regexp.MustCompile(`(?i)<(tool_calls|tool_call|function_calls)\b[^>]*>|<function=\s*["']?([^"'>\s]+)["']?\s*>`)
+	toolTagNamePattern    = regexp.MustCompile(`(?is)<name>(.*?)</name>`)
+	toolTagArgsPattern    = regexp.MustCompile(`(?is)<(?:arguments|parameters|input|args)>(.*?)</(?:arguments|parameters|input|args)>`)
+	toolTagInvokePattern  = regexp.MustCompile(`(?is)<invoke\s+name\s*=\s*["']?([^"'>\s]+)["']?\s*>(.*?)</invoke>`)
+	toolTagParamPattern   = regexp.MustCompile(`(?is)<parameter\s+name\s*=\s*["']?([^"'>\s]+)["']?\s*>(.*?)</parameter>`)
+	functionTagParamRegex = regexp.MustCompile(`(?is)<parameter=\s*["']?([^"'>\s]+)["']?\s*>(.*?)</parameter>`)
+)
+
+// toolTagOpeners are the literal starts of the tags the tag dialects open
+// with, used to hold back a partial opener at the end of a stream chunk.
+var toolTagOpeners = []struct {
+	text    string
+	dialect ToolDialects
+}{
+	{"<tool_call", ToolDialectXML},
+	{"<function_calls", ToolDialectXML},
+	{"<function=", ToolDialectFunctionTag},
+}
+
+// toolTagBlock is one tool-call tag found in text. tag is the lower-cased tag
+// name, "function" for <function=name>, whose name is kept in fn.
+type toolTagBlock struct {
+	tag       string
+	fn        string
+	start     int
+	bodyStart int
+	bodyEnd   int
+	end       int
+}
+
+// nextToolTag finds the first opening tag of an enabled tag dialect at or
+// after from. closed reports whether its closing tag is in text as well.
+func nextToolTag(text string, from int, dialects ToolDialects) (block toolTagBlock, found, closed bool) {
+	for from <= len(text) {
+		loc := toolTagOpenPattern.FindStringSubmatchIndex(text[from:])
+		if loc == nil {
+			return toolTagBlock{}, false, false
+		}
+		block = toolTagBlock{start: from + loc[0], bodyStart: from + loc[1]}
+		dialect := ToolDialectXML
+		closing := ""
+		if loc[2] >= 0 {
+			block.tag = strings.ToLower(text[from+loc[2] : from+loc[3]])
+			closing = "</" + block.tag + ">"
+		} else {
+			block.tag = "function"
+			block.fn = text[from+loc[4] : from+loc[5]]
+			dialect = ToolDialectFunctionTag
+			closing = "</function>"
+		}
+		if !dialects.Has(dialect) {
+			from = block.bodyStart
+			continue
+		}
+		idx := strings.Index(strings.ToLower(text[block.bodyStart:]), closing)
+		if idx < 0 {
+			return block, true, false
+		}
+		block.bodyEnd = block.bodyStart + idx
+		block.end = block.bodyEnd + len(closing)
+		return block, true, true
+	}
+	return toolTagBlock{}, false, false
+}
+
+// FindToolTagStart returns the index of the first tool-call tag of an enabled
+// tag dialect in text, or -1.
+func FindToolTagStart(text string, dialects ToolDialects) int {
+	block, found, _ := nextToolTag(text, 0, dialects)
+	if !found {
+		return -1
+	}
+	return block.start
+}
+
+// ToolTagPrefixStart returns where a tool-call tag may be starting at the end
+// of text, cut off before its opening tag is complete, or -1.
+func ToolTagPrefixStart(text string, dialects ToolDialects) int {
+	idx := strings.LastIndex(text, "<")
+	if idx < 0 {
+		return -1
+	}
+	tail := strings.ToLower(text[idx:])
+	for _, o := range toolTagOpeners {
+		if !dialects.Has(o.dialect) {
+			continue
+		}
+		if strings.HasPrefix(o.text, tail) || (strings.HasPrefix(tail, o.text) && !strings.Contains(tail, ">")) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// ToolTagRunEnd returns the end of the run of tool-call tags that opens at
+// start: consecutive tags separated only by whitespace. ok is false while the
+// run may still grow; with final set, a run that is cut short after a closed
+// tag ends there.
+func ToolTagRunEnd(text string, start int, dialects ToolDialects, final bool) (end int, ok bool) {
+	end = -1
+	pos := start
+	for {
+		block, found, closed := nextToolTag(text, pos, dialects)
+		if !found || block.start != pos {
+			break
+		}
+		if !closed {
+			if final && end >= 0 {
+				break
+			}
+			return 0, false
+		}
+		end = block.end
+		pos = end
+		for pos < len(text) && strings.ContainsRune(" \t\r\n", rune(text[pos])) {
+			pos++
+		}
+		if pos == len(text) || ToolTagPrefixStart(text[pos:], dialects) == 0 {
+			if !final {
+				return 0, false
+			}
+			break
+		}
+	}
+	return end, end >= 0
+}
+
+// parseToolTagCalls parses every tool-call tag of the enabled tag dialects in
+// text.
+func parseToolTagCalls(text string, dialects ToolDialects) []ParsedToolCall {
+	var out []ParsedToolCall
+	pos := 0
+	for {
+		block, found, closed := nextToolTag(text, pos, dialects)
+		if !found || !closed {
+			return out
+		}
+		out = append(out, parseToolTagBlock(text[block.bodyStart:block.bodyEnd], block)...)
+		pos = block.end
+	}
+}
+
+func parseToolTagBlock(body string, block toolTagBlock) []ParsedToolCall {
+	trimmed := strings.TrimSpace(body)
+	switch block.tag {
+	case "tool_calls":
+		if calls := parseToolTagCalls(body, AllToolDialects); len(calls) > 0 {
+			return calls
+		}
+		return parseToolCallsPayload(trimmed)
+	case "tool_call":
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			return parseToolCallsPayload(trimmed)
+		}
+		if calls := parseToolTagCalls(body, ToolDialectFunctionTag); len(calls) > 0 {
+			return calls
+		}
+		m := toolTagNamePattern.FindStringSubmatch(body)
+		if m == nil || strings.TrimSpace(m[1]) == "" {
+			return nil
+		}
+		var input any
+		if args := toolTagArgsPattern.FindStringSubmatch(body); args != nil {
+			input = args[1]
+		}
+		return []ParsedToolCall{{Name: strings.TrimSpace(m[1]), Input: parseToolCallInput(input)}}
+	case "function_calls":
+		var out []ParsedToolCall
+		for _, m := range toolTagInvokePattern.FindAllStringSubmatch(body, -1) {
+			out = append(out, ParsedToolCall{Name: strings.TrimSpace(m[1]), Input: parseTagParameters(m[2], toolTagParamPattern)})
+		}
+		return out
+	case "function":
+		if strings.HasPrefix(trimmed, "{") {
+			return []ParsedToolCall{{Name: strings.TrimSpace(block.fn), Input: parseToolCallInput(trimmed)}}
+		}
+		return []ParsedToolCall{{Name: strings.TrimSpace(block.fn), Input: parseTagParameters(body, functionTagParamRegex)}}
+	}
+	return nil
+}
+
+// parseTagParameters collects parameter tags into an input object. Values
+// that are valid JSON are decoded; anything else is kept as a string.
+func parseTagParameters(body string, pattern *regexp.Regexp) map[string]any {
+	out := map[string]any{}
+	for _, m := range pattern.FindAllStringSubmatch(body, -1) {
+		raw := strings.TrimSpace(m[2])
+		var decoded any
+		if err := json.Unmarshal([]byte(raw), &decoded); err == nil {
+			out[m[1]] = decoded
+			continue
+		}
+		out[m[1]] = raw
+	}
+	return out
+}
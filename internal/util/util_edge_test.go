@@ -427,3 +427,27 @@ func TestLooksLikeToolExampleContextFenced(t *testing.T) {
 		t.Fatal("expected true for fenced code block context")
 	}
 }
+
+// ─── StandardRequest.ConversationKey ─────────────────────────────────
+
+func TestConversationKeyStaysStableAsConversationGrows(t *testing.T) {
+	first := StandardRequest{Surface: "openai_chat", Messages: []any{
+		map[string]any{"role": "system", "content": "be brief"},
+		map[string]any{"role": "user", "content": "plan a trip"},
+	}}
+	later := first
+	later.Messages = append(append([]any{}, first.Messages...),
+		map[string]any{"role": "assistant", "content": "where to?"},
+		map[string]any{"role": "user", "content": "Lisbon"},
+	)
+	other := StandardRequest{Surface: "openai_chat", Messages: []any{map[string]any{"role": "user", "content": "hello"}}}
+	if first.ConversationKey() == "" || first.ConversationKey() != later.ConversationKey() {
+		t.Fatalf("expected later turns to keep the conversation key")
+	}
+	if first.ConversationKey() == other.ConversationKey() {
+		t.Fatalf("expected different conversations to get different keys")
+	}
+	if (StandardRequest{}).ConversationKey() != "" {
+		t.Fatalf("expected no key without messages")
+	}
+}
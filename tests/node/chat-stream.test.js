@@ -9,6 +9,9 @@ const {
   processToolSieveChunk,
   flushToolSieve,
 } = require('../../internal/js/helpers/stream-tool-sieve.js');
+const { createOutputLimiter } = require('../../internal/js/chat-stream/output_limit.js');
+const { createSearchCitations } = require('../../internal/js/chat-stream/search_citations.js');
+const { createReasoningPresenter } = require('../../internal/js/chat-stream/reasoning_mode.js');
 
 const {
   parseChunkForContent,
@@ -28,6 +31,7 @@ test('resolveToolcallPolicy defaults to feature-match + early emit when prepare
     [{ type: 'function', function: { name: 'read_file', parameters: { type: 'object' } } }],
   );
   assert.deepEqual(policy.toolNames, ['read_file']);
+  assert.deepEqual(policy.toolDialects, []);
   assert.equal(policy.toolSieveEnabled, true);
   assert.equal(policy.emitEarlyToolDeltas, true);
 });
@@ -38,14 +42,38 @@ test('resolveToolcallPolicy respects prepare flags and prepared tool names', ()
       tool_names: [' prepped_tool ', '', null],
       toolcall_feature_match: false,
       toolcall_early_emit_high: false,
+      toolcall_dialects: ['json', 'xml'],
     },
     [{ type: 'function', function: { name: 'fallback_tool', parameters: { type: 'object' } } }],
   );
   assert.deepEqual(policy.toolNames, ['prepped_tool']);
+  assert.deepEqual(policy.toolDialects, ['json', 'xml']);
   assert.equal(policy.toolSieveEnabled, false);
   assert.equal(policy.emitEarlyToolDeltas, false);
 });
 
+test('resolveToolcallPolicy checks prepared schemas when calls close and keeps early deltas', () => {
+  const policy = resolveToolcallPolicy(
+    {
+      tool_names: ['search'],
+      toolcall_schemas: { search: { type: ['object'], required: ['query'] } },
+    },
+    [],
+  );
+  assert.equal(policy.emitEarlyToolDeltas, true);
+  assert.equal(policy.rejected(), false);
+  const events = policy.checkEvents([
+    { type: 'text', text: 'a' },
+    { type: 'tool_calls', calls: [{ name: 'search', input: {} }] },
+    { type: 'tool_calls', calls: [{ name: 'search', input: { query: 'go' } }] },
+  ]);
+  assert.deepEqual(events, [
+    { type: 'text', text: 'a' },
+    { type: 'tool_calls', calls: [{ name: 'search', input: { query: 'go' } }] },
+  ]);
+  assert.equal(policy.rejected(), true);
+});
+
 test('normalizePreparedToolNames filters empty values', () => {
   assert.deepEqual(normalizePreparedToolNames([' a ', '', null, 'b']), ['a', 'b']);
 });
@@ -167,3 +195,115 @@ test('parseChunkForContent supports wrapped response.fragments object shape', ()
   assert.equal(parsed.finished, false);
   assert.equal(parsed.parts.map((p) => p.text).join(''), 'AB');
 });
+
+test('createOutputLimiter cuts at a stop sequence split across chunks', () => {
+  const limiter = createOutputLimiter({ stop_sequences: ['</answer>'] });
+  const first = limiter.apply([{ text: 'value</ans', type: 'text' }]);
+  assert.equal(first.map((p) => p.text).join(''), 'value');
+  assert.equal(limiter.reached(), false);
+  const second = limiter.apply([{ text: 'wer> trailing', type: 'text' }]);
+  assert.deepEqual(second, []);
+  assert.equal(limiter.reached(), true);
+  assert.equal(limiter.finishReason(), 'stop');
+});
+
+test('createOutputLimiter enforces max_tokens with the usage estimate', () => {
+  const limiter = createOutputLimiter({ max_tokens: 2 });
+  const parts = [
+    ...limiter.apply([{ text: 'abcdef', type: 'text' }]),
+    ...limiter.apply([{ text: 'ghijklmnop', type: 'text' }]),
+  ];
+  assert.equal(parts.map((p) => p.text).join(''), 'abcdefghijk');
+  assert.equal(limiter.finishReason(), 'length');
+  assert.deepEqual(limiter.flush(), []);
+});
+
+test('createOutputLimiter leaves citation markers out of max_tokens', () => {
+  const run = (limits) => {
+    const limiter = createOutputLimiter(limits);
+    const parts = [
+      ...limiter.apply([{ text: 'abcd[cita', type: 'text' }]),
+      ...limiter.apply([{ text: 'tion:1]efgh', type: 'text' }]),
+      ...limiter.flush(),
+    ];
+    return { text: parts.map((p) => p.text).join(''), reached: limiter.reached() };
+  };
+  assert.deepEqual(run({ max_tokens: 2, citations: true }), { text: 'abcd[citation:1]efgh', reached: false });
+  assert.deepEqual(run({ max_tokens: 2 }), { text: 'abcd[citati', reached: true });
+});
+
+test('createOutputLimiter only charges thinking when count_thinking is set', () => {
+  const parts = [{ text: 'abcdefgh', type: 'thinking' }, { text: 'ijklmnop', type: 'text' }];
+  const counted = createOutputLimiter({ max_tokens: 3, count_thinking: true }).apply(parts);
+  assert.equal(counted[1].text, 'ijklmno');
+  const free = createOutputLimiter({ max_tokens: 3 }).apply(parts);
+  assert.equal(free[1].text, 'ijklmnop');
+});
+
+test('createSearchCitations turns markers into url_citation annotations', () => {
+  const citations = createSearchCitations(true);
+  citations.addChunk({
+    p: 'response/search_results',
+    v: [{ url: 'https://example.com/a', title: 'A', cite_index: 1 }],
+  });
+  const first = citations.strip({ text: '天气晴朗', type: 'text' });
+  assert.equal(first.annotations, undefined);
+  const second = citations.strip({ text: '[citation:1]。', type: 'text' });
+  assert.equal(second.text, '。');
+  assert.deepEqual(second.annotations, [{
+    type: 'url_citation',
+    url_citation: { url: 'https://example.com/a', title: 'A', start_index: 0, end_index: 4 },
+  }]);
+  assert.equal(citations.strip({ text: '[citation:', type: 'text' }).text, '');
+});
+
+test('createSearchCitations holds back a marker split inside a chunk', () => {
+  const citations = createSearchCitations(true);
+  citations.addChunk({ p: 'response/search_results', v: [{ url: 'https://example.com/c', title: 'C', cite_index: 3 }] });
+  const first = citations.strip({ text: 'fact[cit', type: 'text' });
+  assert.equal(first.text, 'fact');
+  assert.equal(citations.strip({ text: 'ation:', type: 'text' }).text, '');
+  const third = citations.strip({ text: '3] tail[', type: 'text' });
+  assert.equal(third.text, ' tail');
+  assert.equal(third.annotations[0].url_citation.end_index, 4);
+  const rest = citations.flush().map((p) => citations.strip(p).text).join('');
+  assert.equal(rest, '[');
+});
+
+test('createSearchCitations passes parts through when search is off', () => {
+  const citations = createSearchCitations(false);
+  const part = { text: 'x[citation:1]', type: 'text' };
+  assert.equal(citations.strip(part), part);
+});
+
+test('createReasoningPresenter keeps reasoning_content in separate mode', () => {
+  const reasoning = createReasoningPresenter('');
+  assert.deepEqual(reasoning.thinking('想'), { reasoning_content: '想' });
+  assert.equal(reasoning.end(), null);
+});
+
+test('createReasoningPresenter wraps thinking in think tags inline', () => {
+  const reasoning = createReasoningPresenter('inline_think_tags');
+  assert.deepEqual(reasoning.thinking('a'), { content: '<think>a' });
+  assert.deepEqual(reasoning.thinking('b'), { content: 'b' });
+  assert.deepEqual(reasoning.end(), { content: '</think>\n\n' });
+  assert.equal(reasoning.end(), null);
+  assert.equal(reasoning.thinking('late'), null);
+  const [shifted] = reasoning.shift([{
+    type: 'url_citation',
+    url_citation: { url: 'u', title: 't', start_index: 0, end_index: 2 },
+  }]);
+  assert.equal(shifted.url_citation.start_index, 19);
+  assert.equal(shifted.url_citation.end_index, 21);
+});
+
+test('createReasoningPresenter hides or summarizes thinking', () => {
+  const hidden = createReasoningPresenter('hidden');
+  assert.equal(hidden.thinking('secret'), null);
+  assert.equal(hidden.end(), null);
+
+  const summary = createReasoningPresenter('summary_only');
+  assert.equal(summary.thinking('step one\n'), null);
+  assert.equal(summary.thinking('so the answer is 4\n'), null);
+  assert.deepEqual(summary.end(), { reasoning_content: 'so the answer is 4' });
+});
@@ -10,10 +10,11 @@ const {
   flushToolSieve,
   parseToolCalls,
   parseStandaloneToolCalls,
+  checkToolCalls,
 } = require('../../internal/js/helpers/stream-tool-sieve.js');
 
-function runSieve(chunks, toolNames) {
-  const state = createToolSieveState();
+function runSieve(chunks, toolNames, dialects) {
+  const state = createToolSieveState(dialects);
   const events = [];
   for (const chunk of chunks) {
     events.push(...processToolSieveChunk(state, chunk, toolNames));
@@ -215,3 +216,67 @@ test('sieve intercepts tool call and preserves trailing same-chunk text', () =>
   assert.equal(leakedText.includes('然后继续解释。'), true);
   assert.equal(leakedText.toLowerCase().includes('tool_calls'), false);
 });
+
+test('parseToolCalls reads XML and function-tag dialects only when enabled', () => {
+  const text = '<tool_call>{"name":"read_file","arguments":{"path":"a.txt"}}</tool_call>\n<function=search><parameter=q>go</parameter><parameter=limit>5</parameter></function>';
+  const all = parseToolCalls(text, ['read_file', 'search'], ['json', 'xml', 'function_tag']);
+  assert.deepEqual(all, [
+    { name: 'read_file', input: { path: 'a.txt' } },
+    { name: 'search', input: { q: 'go', limit: 5 } },
+  ]);
+  const invoke = parseToolCalls('<function_calls><invoke name="search"><parameter name="q">go</parameter></invoke></function_calls>', ['search'], ['xml']);
+  assert.deepEqual(invoke, [{ name: 'search', input: { q: 'go' } }]);
+  assert.deepEqual(parseToolCalls('<function=search><parameter=q>go</parameter></function>', ['search']), []);
+});
+
+test('parseToolCalls accepts OpenAI-style function_call objects', () => {
+  const calls = parseToolCalls('{"function_call":{"name":"search","arguments":"{\\"q\\":\\"go\\"}"}}', ['search']);
+  assert.deepEqual(calls, [{ name: 'search', input: { q: 'go' } }]);
+});
+
+test('sieve intercepts split XML tool-call tags as one batch without leaking tags', () => {
+  const events = runSieve(
+    [
+      '先查一下。<tool',
+      '_call>{"name":"read_file","arguments":{"path":"README.MD"}}</tool_call>\n',
+      '<function=search><parameter=q>go</parameter></function>',
+      '然后继续。',
+    ],
+    ['read_file', 'search'],
+    ['json', 'xml', 'function_tag'],
+  );
+  const batches = events.filter((evt) => evt.type === 'tool_calls');
+  assert.equal(batches.length, 1);
+  assert.deepEqual(batches[0].calls.map((c) => c.name), ['read_file', 'search']);
+  assert.equal(events.some((evt) => evt.type === 'tool_call_deltas'), false);
+  const leakedText = collectText(events);
+  assert.equal(leakedText, '先查一下。然后继续。');
+});
+
+test('sieve leaves tags as text when only JSON is enabled', () => {
+  const text = '<function=search><parameter=q>go</parameter></function>';
+  const events = runSieve([text], ['search']);
+  assert.equal(events.some((evt) => evt.type === 'tool_calls'), false);
+  assert.equal(collectText(events), text);
+});
+
+test('checkToolCalls coerces arguments and drops calls that fail their schema', () => {
+  const schemas = {
+    search: {
+      type: ['object'],
+      properties: { query: { type: ['string'] }, limit: { type: ['integer'] }, tags: { type: ['array'], items: { type: ['string'] } } },
+      required: ['query'],
+      closed: true,
+    },
+  };
+  const calls = checkToolCalls([
+    { name: 'search', input: { query: 42, limit: '3', tags: 'go' } },
+    { name: 'search', input: { limit: 3 } },
+    { name: 'search', input: { query: 'go', extra: true } },
+    { name: 'other', input: { anything: 1 } },
+  ], schemas);
+  assert.deepEqual(calls, [
+    { name: 'search', input: { query: '42', limit: 3, tags: ['go'] } },
+    { name: 'other', input: { anything: 1 } },
+  ]);
+});
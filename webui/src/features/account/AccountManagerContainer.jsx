@@ -33,6 +33,8 @@ export default function AccountManagerContainer({ config, onRefresh, onMessage,
         setShowAddAccount,
         newKey,
         setNewKey,
+        newKeyGroup,
+        setNewKeyGroup,
         copiedKey,
         setCopiedKey,
         newAccount,
@@ -45,6 +47,7 @@ export default function AccountManagerContainer({ config, onRefresh, onMessage,
         deleteKey,
         addAccount,
         deleteAccount,
+        updateAccountGroups,
         testAccount,
         testAllAccounts,
     } = useAccountActions({
@@ -88,6 +91,7 @@ export default function AccountManagerContainer({ config, onRefresh, onMessage,
                 onShowAddAccount={() => setShowAddAccount(true)}
                 onTestAccount={testAccount}
                 onDeleteAccount={deleteAccount}
+                onEditGroups={updateAccountGroups}
                 onPrevPage={() => fetchAccounts(page - 1)}
                 onNextPage={() => fetchAccounts(page + 1)}
                 onPageSizeChange={changePageSize}
@@ -98,6 +102,8 @@ export default function AccountManagerContainer({ config, onRefresh, onMessage,
                 t={t}
                 newKey={newKey}
                 setNewKey={setNewKey}
+                newKeyGroup={newKeyGroup}
+                setNewKeyGroup={setNewKeyGroup}
                 loading={loading}
                 onClose={() => setShowAddKey(false)}
                 onAdd={addKey}
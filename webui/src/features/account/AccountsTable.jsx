@@ -1,5 +1,5 @@
 import { useState } from 'react'
-import { ChevronLeft, ChevronRight, Check, Copy, Play, Plus, Trash2 } from 'lucide-react'
+import { ChevronLeft, ChevronRight, Check, Copy, Play, Plus, Tags, Trash2 } from 'lucide-react'
 import clsx from 'clsx'
 
 export default function AccountsTable({
@@ -18,6 +18,7 @@ export default function AccountsTable({
     onShowAddAccount,
     onTestAccount,
     onDeleteAccount,
+    onEditGroups,
     onPrevPage,
     onNextPage,
     onPageSizeChange,
@@ -116,6 +117,16 @@ export default function AccountsTable({
                                                     {acc.token_preview}
                                                 </span>
                                             )}
+                                            {acc.proxy && (
+                                                <span className="bg-muted px-1.5 py-0.5 rounded text-[10px] font-mono" title={acc.proxy}>
+                                                    {t('accountManager.proxyBadge')}
+                                                </span>
+                                            )}
+                                            {(acc.groups || []).map(group => (
+                                                <span key={group} className="bg-primary/10 text-primary px-1.5 py-0.5 rounded text-[10px]">
+                                                    {group}
+                                                </span>
+                                            ))}
                                         </div>
                                     </div>
                                 </div>
@@ -127,6 +138,13 @@ export default function AccountsTable({
                                     >
                                         {testing[id] ? t('actions.testing') : t('actions.test')}
                                     </button>
+                                    <button
+                                        onClick={() => onEditGroups(acc)}
+                                        className="p-1 lg:p-1.5 text-muted-foreground hover:text-primary hover:bg-primary/10 rounded-md transition-colors"
+                                        title={t('accountManager.editGroups')}
+                                    >
+                                        <Tags className="w-3.5 h-3.5 lg:w-4 lg:h-4" />
+                                    </button>
                                     <button
                                         onClick={() => onDeleteAccount(id)}
                                         className="p-1 lg:p-1.5 text-muted-foreground hover:text-destructive hover:bg-destructive/10 rounded-md transition-colors"
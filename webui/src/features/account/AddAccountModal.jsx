@@ -53,6 +53,26 @@ export default function AddAccountModal({
                             onChange={e => setNewAccount({ ...newAccount, password: e.target.value })}
                         />
                     </div>
+                    <div>
+                        <label className="block text-sm font-medium mb-1.5">{t('accountManager.groupsLabel')}</label>
+                        <input
+                            type="text"
+                            className="input-field"
+                            placeholder={t('accountManager.groupsPlaceholder')}
+                            value={newAccount.groups}
+                            onChange={e => setNewAccount({ ...newAccount, groups: e.target.value })}
+                        />
+                    </div>
+                    <div>
+                        <label className="block text-sm font-medium mb-1.5">{t('accountManager.proxyLabel')}</label>
+                        <input
+                            type="text"
+                            className="input-field"
+                            placeholder={t('accountManager.proxyPlaceholder')}
+                            value={newAccount.proxy}
+                            onChange={e => setNewAccount({ ...newAccount, proxy: e.target.value })}
+                        />
+                    </div>
                     <div className="flex justify-end gap-2 pt-2">
                         <button onClick={onClose} className="px-4 py-2 rounded-lg border border-border hover:bg-secondary transition-colors text-sm font-medium">{t('actions.cancel')}</button>
                         <button onClick={onAdd} disabled={loading} className="px-4 py-2 bg-primary text-primary-foreground rounded-lg hover:bg-primary/90 transition-colors text-sm font-medium disabled:opacity-50">
@@ -1,6 +1,6 @@
 import { X } from 'lucide-react'
 
-export default function AddKeyModal({ show, t, newKey, setNewKey, loading, onClose, onAdd }) {
+export default function AddKeyModal({ show, t, newKey, setNewKey, newKeyGroup, setNewKeyGroup, loading, onClose, onAdd }) {
     if (!show) {
         return null
     }
@@ -36,6 +36,16 @@ export default function AddKeyModal({ show, t, newKey, setNewKey, loading, onClo
                         </div>
                         <p className="text-xs text-muted-foreground mt-1.5">{t('accountManager.generateHint')}</p>
                     </div>
+                    <div>
+                        <label className="block text-sm font-medium mb-1.5">{t('accountManager.keyGroupLabel')}</label>
+                        <input
+                            type="text"
+                            className="input-field"
+                            placeholder={t('accountManager.keyGroupPlaceholder')}
+                            value={newKeyGroup}
+                            onChange={e => setNewKeyGroup(e.target.value)}
+                        />
+                    </div>
                     <div className="flex justify-end gap-2 pt-2">
                         <button onClick={onClose} className="px-4 py-2 rounded-lg border border-border hover:bg-secondary transition-colors text-sm font-medium">{t('actions.cancel')}</button>
                         <button onClick={onAdd} disabled={loading} className="px-4 py-2 bg-primary text-primary-foreground rounded-lg hover:bg-primary/90 transition-colors text-sm font-medium disabled:opacity-50">
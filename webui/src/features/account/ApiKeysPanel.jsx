@@ -45,6 +45,11 @@ export default function ApiKeysPanel({
                                     <div className="font-mono text-sm bg-muted/50 px-3 py-1 rounded inline-block">
                                         {key.slice(0, 16)}****
                                     </div>
+                                    {config.key_groups?.[key] && (
+                                        <span className="bg-primary/10 text-primary px-1.5 py-0.5 rounded text-xs">
+                                            {config.key_groups[key]}
+                                        </span>
+                                    )}
                                     {copiedKey === key && (
                                         <span className="text-xs text-green-500 animate-pulse">{t('accountManager.copied')}</span>
                                     )}
@@ -4,8 +4,9 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
     const [showAddKey, setShowAddKey] = useState(false)
     const [showAddAccount, setShowAddAccount] = useState(false)
     const [newKey, setNewKey] = useState('')
+    const [newKeyGroup, setNewKeyGroup] = useState('')
     const [copiedKey, setCopiedKey] = useState(null)
-    const [newAccount, setNewAccount] = useState({ email: '', mobile: '', password: '' })
+    const [newAccount, setNewAccount] = useState({ email: '', mobile: '', password: '', groups: '', proxy: '' })
     const [loading, setLoading] = useState(false)
     const [testing, setTesting] = useState({})
     const [testingAll, setTestingAll] = useState(false)
@@ -18,11 +19,12 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
             const res = await apiFetch('/admin/keys', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ key: newKey.trim() }),
+                body: JSON.stringify({ key: newKey.trim(), group: newKeyGroup.trim() }),
             })
             if (res.ok) {
                 onMessage('success', t('accountManager.addKeySuccess'))
                 setNewKey('')
+                setNewKeyGroup('')
                 setShowAddKey(false)
                 onRefresh()
             } else {
@@ -65,7 +67,7 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
             })
             if (res.ok) {
                 onMessage('success', t('accountManager.addAccountSuccess'))
-                setNewAccount({ email: '', mobile: '', password: '' })
+                setNewAccount({ email: '', mobile: '', password: '', groups: '', proxy: '' })
                 setShowAddAccount(false)
                 fetchAccounts(1)
                 onRefresh()
@@ -101,6 +103,33 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
         }
     }
 
+    const updateAccountGroups = async (acc) => {
+        const identifier = resolveAccountIdentifier(acc)
+        if (!identifier) {
+            onMessage('error', t('accountManager.invalidIdentifier'))
+            return
+        }
+        const input = prompt(t('accountManager.editGroupsPrompt'), (acc.groups || []).join(', '))
+        if (input === null) return
+        try {
+            const res = await apiFetch(`/admin/accounts/${encodeURIComponent(identifier)}`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ groups: input }),
+            })
+            if (res.ok) {
+                onMessage('success', t('accountManager.updateGroupsSuccess'))
+                fetchAccounts()
+                onRefresh()
+            } else {
+                const data = await res.json()
+                onMessage('error', data.detail || t('messages.requestFailed'))
+            }
+        } catch (e) {
+            onMessage('error', t('messages.networkError'))
+        }
+    }
+
     const testAccount = async (identifier) => {
         const accountID = String(identifier || '').trim()
         if (!accountID) {
@@ -177,6 +206,8 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
         setShowAddAccount,
         newKey,
         setNewKey,
+        newKeyGroup,
+        setNewKeyGroup,
         copiedKey,
         setCopiedKey,
         newAccount,
@@ -189,6 +220,7 @@ export function useAccountActions({ apiFetch, t, onMessage, onRefresh, config, f
         deleteKey,
         addAccount,
         deleteAccount,
+        updateAccountGroups,
         testAccount,
         testAllAccounts,
     }
@@ -1,3 +1,7 @@
+// Other toolcall.mode values, such as "json,xml", come from the config file
+// and are shown as they are.
+const TOOLCALL_MODES = ['feature_match', 'all', 'off']
+
 export default function BehaviorSection({ t, form, setForm }) {
     return (
         <div className="bg-card border border-border rounded-xl p-5 space-y-4">
@@ -14,7 +18,11 @@ export default function BehaviorSection({ t, form, setForm }) {
                         className="w-full bg-background border border-border rounded-lg px-3 py-2"
                     >
                         <option value="feature_match">feature_match</option>
+                        <option value="all">all</option>
                         <option value="off">off</option>
+                        {!TOOLCALL_MODES.includes(form.toolcall.mode) && (
+                            <option value={form.toolcall.mode}>{form.toolcall.mode}</option>
+                        )}
                     </select>
                 </label>
                 <label className="text-sm space-y-2">
@@ -32,6 +40,20 @@ export default function BehaviorSection({ t, form, setForm }) {
                         <option value="off">off</option>
                     </select>
                 </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.toolcallRepairAttempts')}</span>
+                    <input
+                        type="number"
+                        min={0}
+                        max={3}
+                        value={form.toolcall.repair_attempts}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            toolcall: { ...prev.toolcall, repair_attempts: Number(e.target.value || 0) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
                 <label className="text-sm space-y-2">
                     <span className="text-muted-foreground">{t('settings.responsesTTL')}</span>
                     <input
@@ -45,6 +67,20 @@ export default function BehaviorSection({ t, form, setForm }) {
                         className="w-full bg-background border border-border rounded-lg px-3 py-2"
                     />
                 </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.responsesStreamGrace')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        max={3600}
+                        value={form.responses.stream_grace_seconds}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            responses: { ...prev.responses, stream_grace_seconds: Number(e.target.value || 30) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
                 <label className="text-sm space-y-2">
                     <span className="text-muted-foreground">{t('settings.embeddingsProvider')}</span>
                     <input
@@ -57,6 +93,134 @@ export default function BehaviorSection({ t, form, setForm }) {
                         className="w-full bg-background border border-border rounded-lg px-3 py-2"
                     />
                 </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.reasoningMode')}</span>
+                    <select
+                        value={form.compat.reasoning_mode}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            compat: { ...prev.compat, reasoning_mode: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        <option value="separate">separate</option>
+                        <option value="inline_think_tags">inline_think_tags</option>
+                        <option value="hidden">hidden</option>
+                        <option value="summary_only">summary_only</option>
+                    </select>
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.contextStrategy')}</span>
+                    <select
+                        value={form.context.strategy}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            context: { ...prev.context, strategy: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        <option value="off">off</option>
+                        <option value="drop_oldest">drop_oldest</option>
+                        <option value="truncate_tool_results">truncate_tool_results</option>
+                        <option value="summarize">summarize</option>
+                    </select>
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.contextMaxPromptTokens')}</span>
+                    <input
+                        type="number"
+                        min={0}
+                        value={form.context.max_prompt_tokens}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            context: { ...prev.context, max_prompt_tokens: Number(e.target.value || 0) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.contextToolResultMaxTokens')}</span>
+                    <input
+                        type="number"
+                        min={100}
+                        value={form.context.tool_result_max_tokens}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            context: { ...prev.context, tool_result_max_tokens: Number(e.target.value || 2000) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.responseCacheMode')}</span>
+                    <select
+                        value={form.response_cache.mode}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            response_cache: { ...prev.response_cache, mode: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        <option value="off">off</option>
+                        <option value="memory">memory</option>
+                        <option value="disk">disk</option>
+                    </select>
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.responseCacheTTL')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        value={form.response_cache.ttl_seconds}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            response_cache: { ...prev.response_cache, ttl_seconds: Number(e.target.value || 3600) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.responseCacheMaxMiB')}</span>
+                    <input
+                        type="number"
+                        min={1}
+                        value={form.response_cache.max_mib}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            response_cache: { ...prev.response_cache, max_mib: Number(e.target.value || 64) },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    />
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.identityProfile')}</span>
+                    <select
+                        value={form.identity.profile}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            identity: { ...prev.identity, profile: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        {form.identity.available_profiles.map((name) => (
+                            <option key={name} value={name}>{name}</option>
+                        ))}
+                    </select>
+                </label>
+                <label className="text-sm space-y-2">
+                    <span className="text-muted-foreground">{t('settings.identityDiversify')}</span>
+                    <select
+                        value={form.identity.diversify}
+                        onChange={(e) => setForm((prev) => ({
+                            ...prev,
+                            identity: { ...prev.identity, diversify: e.target.value },
+                        }))}
+                        className="w-full bg-background border border-border rounded-lg px-3 py-2"
+                    >
+                        <option value="off">off</option>
+                        <option value="on">on</option>
+                    </select>
+                </label>
             </div>
         </div>
     )
@@ -0,0 +1,130 @@
+const MIB = 1024 * 1024
+
+export const DEFAULT_FORM = {
+    admin: { jwt_expire_hours: 24 },
+    runtime: { account_max_inflight: 2, account_max_queue: 10, global_max_inflight: 10 },
+    toolcall: { mode: 'feature_match', early_emit_confidence: 'high', repair_attempts: 1 },
+    responses: { store_ttl_seconds: 900, stream_grace_seconds: 30 },
+    embeddings: { provider: '' },
+    compat: { reasoning_mode: 'separate' },
+    context: { strategy: 'off', max_prompt_tokens: 0, tool_result_max_tokens: 2000 },
+    response_cache: { mode: 'off', ttl_seconds: 3600, max_mib: 64 },
+    identity: { profile: 'default', diversify: 'off', available_profiles: ['default'] },
+    claude_mapping_text: '{\n  "fast": "deepseek-chat",\n  "slow": "deepseek-reasoner"\n}',
+    model_aliases_text: '{}',
+}
+
+export function parseJSONMap(raw, fieldName, t) {
+    const text = String(raw || '').trim()
+    if (!text) {
+        return {}
+    }
+    let parsed
+    try {
+        parsed = JSON.parse(text)
+    } catch (_e) {
+        throw new Error(t('settings.invalidJsonField', { field: fieldName }))
+    }
+    if (!parsed || typeof parsed !== 'object' || Array.isArray(parsed)) {
+        throw new Error(t('settings.invalidJsonField', { field: fieldName }))
+    }
+    return parsed
+}
+
+export function fromServerForm(data) {
+    return {
+        admin: { jwt_expire_hours: Number(data.admin?.jwt_expire_hours || 24) },
+        runtime: {
+            account_max_inflight: Number(data.runtime?.account_max_inflight || 2),
+            account_max_queue: Number(data.runtime?.account_max_queue || 10),
+            global_max_inflight: Number(data.runtime?.global_max_inflight || 10),
+        },
+        toolcall: {
+            mode: data.toolcall?.mode || 'feature_match',
+            early_emit_confidence: data.toolcall?.early_emit_confidence || 'high',
+            repair_attempts: Number(data.toolcall?.repair_attempts ?? 1),
+        },
+        responses: {
+            store_ttl_seconds: Number(data.responses?.store_ttl_seconds || 900),
+            stream_grace_seconds: Number(data.responses?.stream_grace_seconds || 30),
+        },
+        embeddings: {
+            provider: data.embeddings?.provider || '',
+        },
+        compat: {
+            reasoning_mode: data.compat?.reasoning_mode || 'separate',
+        },
+        context: {
+            strategy: data.context?.strategy || 'off',
+            max_prompt_tokens: Number(data.context?.max_prompt_tokens || 0),
+            tool_result_max_tokens: Number(data.context?.tool_result_max_tokens || 2000),
+        },
+        response_cache: {
+            mode: data.response_cache?.enabled ? (data.response_cache?.backend || 'memory') : 'off',
+            ttl_seconds: Number(data.response_cache?.ttl_seconds || 3600),
+            max_mib: Math.round(Number(data.response_cache?.max_bytes || 64 * MIB) / MIB),
+        },
+        identity: {
+            profile: data.identity?.profile || 'default',
+            diversify: data.identity?.diversify ? 'on' : 'off',
+            available_profiles: data.identity?.available_profiles || ['default'],
+        },
+        claude_mapping_text: JSON.stringify(data.claude_mapping || {}, null, 2),
+        model_aliases_text: JSON.stringify(data.model_aliases || {}, null, 2),
+    }
+}
+
+export function toServerPayload(form) {
+    return {
+        admin: { jwt_expire_hours: Number(form.admin.jwt_expire_hours) },
+        runtime: {
+            account_max_inflight: Number(form.runtime.account_max_inflight),
+            account_max_queue: Number(form.runtime.account_max_queue),
+            global_max_inflight: Number(form.runtime.global_max_inflight),
+        },
+        toolcall: {
+            mode: String(form.toolcall.mode || '').trim(),
+            early_emit_confidence: String(form.toolcall.early_emit_confidence || '').trim(),
+            repair_attempts: Number(form.toolcall.repair_attempts),
+        },
+        responses: {
+            store_ttl_seconds: Number(form.responses.store_ttl_seconds),
+            stream_grace_seconds: Number(form.responses.stream_grace_seconds),
+        },
+        embeddings: { provider: String(form.embeddings.provider || '').trim() },
+        compat: { reasoning_mode: String(form.compat.reasoning_mode || 'separate').trim() },
+        context: toServerContext(form.context),
+        response_cache: toServerResponseCache(form.response_cache),
+        // The pool and custom profiles come from the config file and are kept.
+        identity: {
+            profile: String(form.identity.profile || 'default').trim(),
+            diversify: form.identity.diversify === 'on',
+        },
+    }
+}
+
+// max_prompt_tokens is left out when unset so the model's context window applies.
+function toServerContext(context) {
+    const out = {
+        strategy: String(context.strategy || 'off').trim(),
+        tool_result_max_tokens: Number(context.tool_result_max_tokens || 2000),
+    }
+    if (Number(context.max_prompt_tokens) > 0) {
+        out.max_prompt_tokens = Number(context.max_prompt_tokens)
+    }
+    return out
+}
+
+// The form folds enabled and backend into one mode; "off" keeps the backend
+// that is configured.
+function toServerResponseCache(cache) {
+    const out = {
+        enabled: cache.mode !== 'off',
+        ttl_seconds: Number(cache.ttl_seconds || 3600),
+        max_bytes: Number(cache.max_mib || 64) * MIB,
+    }
+    if (cache.mode !== 'off') {
+        out.backend = cache.mode
+    }
+    return out
+}
@@ -7,76 +7,10 @@ import {
     postPassword,
     putSettings,
 } from './settingsApi'
+import { DEFAULT_FORM, fromServerForm, parseJSONMap, toServerPayload } from './settingsForm'
 
 const MAX_AUTO_FETCH_FAILURES = 3
 
-const DEFAULT_FORM = {
-    admin: { jwt_expire_hours: 24 },
-    runtime: { account_max_inflight: 2, account_max_queue: 10, global_max_inflight: 10 },
-    toolcall: { mode: 'feature_match', early_emit_confidence: 'high' },
-    responses: { store_ttl_seconds: 900 },
-    embeddings: { provider: '' },
-    claude_mapping_text: '{\n  "fast": "deepseek-chat",\n  "slow": "deepseek-reasoner"\n}',
-    model_aliases_text: '{}',
-}
-
-function parseJSONMap(raw, fieldName, t) {
-    const text = String(raw || '').trim()
-    if (!text) {
-        return {}
-    }
-    let parsed
-    try {
-        parsed = JSON.parse(text)
-    } catch (_e) {
-        throw new Error(t('settings.invalidJsonField', { field: fieldName }))
-    }
-    if (!parsed || typeof parsed !== 'object' || Array.isArray(parsed)) {
-        throw new Error(t('settings.invalidJsonField', { field: fieldName }))
-    }
-    return parsed
-}
-
-function fromServerForm(data) {
-    return {
-        admin: { jwt_expire_hours: Number(data.admin?.jwt_expire_hours || 24) },
-        runtime: {
-            account_max_inflight: Number(data.runtime?.account_max_inflight || 2),
-            account_max_queue: Number(data.runtime?.account_max_queue || 10),
-            global_max_inflight: Number(data.runtime?.global_max_inflight || 10),
-        },
-        toolcall: {
-            mode: data.toolcall?.mode || 'feature_match',
-            early_emit_confidence: data.toolcall?.early_emit_confidence || 'high',
-        },
-        responses: {
-            store_ttl_seconds: Number(data.responses?.store_ttl_seconds || 900),
-        },
-        embeddings: {
-            provider: data.embeddings?.provider || '',
-        },
-        claude_mapping_text: JSON.stringify(data.claude_mapping || {}, null, 2),
-        model_aliases_text: JSON.stringify(data.model_aliases || {}, null, 2),
-    }
-}
-
-function toServerPayload(form) {
-    return {
-        admin: { jwt_expire_hours: Number(form.admin.jwt_expire_hours) },
-        runtime: {
-            account_max_inflight: Number(form.runtime.account_max_inflight),
-            account_max_queue: Number(form.runtime.account_max_queue),
-            global_max_inflight: Number(form.runtime.global_max_inflight),
-        },
-        toolcall: {
-            mode: String(form.toolcall.mode || '').trim(),
-            early_emit_confidence: String(form.toolcall.early_emit_confidence || '').trim(),
-        },
-        responses: { store_ttl_seconds: Number(form.responses.store_ttl_seconds) },
-        embeddings: { provider: String(form.embeddings.provider || '').trim() },
-    }
-}
-
 export function useSettingsForm({ apiFetch, t, onMessage, onRefresh, onForceLogout, isVercel = false }) {
     const [loading, setLoading] = useState(false)
     const [saving, setSaving] = useState(false)